@@ -0,0 +1,236 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/mkoziy/genome/exporter/internal/models"
+	"github.com/mkoziy/genome/exporter/internal/repositories"
+)
+
+// rsIDAnnotation is one rsID of an annotate input joined to the database.
+type rsIDAnnotation struct {
+	// Query is the rsID as read; the SNP's rsID differs from it when the
+	// query was merged into another.
+	Query string      `json:"query"`
+	Found bool        `json:"found"`
+	SNP   *models.SNP `json:"snp,omitempty"`
+}
+
+// annotationWriter writes annotations in one annotate format.
+type annotationWriter interface {
+	Write(a rsIDAnnotation) error
+	Close() error
+}
+
+func newAnnotateCmd(opts *rootOptions) *cobra.Command {
+	var (
+		input     string
+		format    string
+		out       string
+		batchSize int
+	)
+	cmd := &cobra.Command{
+		Use:   "annotate",
+		Short: "Join a list of rsIDs against the database, as JSON lines or CSV",
+		Long: `Read rsIDs, one per line, and write what the database knows of each, in input
+order, as JSON lines of full SNPs or as CSV with the export columns plus the
+clinical significances and conditions asserted. Only the first field of a line
+counts, so the first column of a TSV or CSV works as input; blank lines and
+lines starting with # are skipped. rsIDs merged into another are followed and
+rsIDs not in the database are written as not found. The input is read in
+batches, so lists of any length stream.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if format != "json" && format != "csv" {
+				return fmt.Errorf("unknown --format %q (want json or csv)", format)
+			}
+			if batchSize <= 0 {
+				return errors.New("--batch-size must be positive")
+			}
+			src := cmd.InOrStdin()
+			if input != "-" {
+				f, err := os.Open(input)
+				if err != nil {
+					return err
+				}
+				defer func() {
+					_ = f.Close()
+				}()
+				src = f
+			}
+
+			db, err := opts.openDB()
+			if err != nil {
+				return err
+			}
+			defer func() {
+				_ = db.Close()
+			}()
+
+			dst := cmd.OutOrStdout()
+			if out != "" {
+				f, err := os.Create(out)
+				if err != nil {
+					return fmt.Errorf("create output: %w", err)
+				}
+				defer func() {
+					_ = f.Close()
+				}()
+				dst = f
+			}
+			bw := bufio.NewWriter(dst)
+			var w annotationWriter = &annotationJSONWriter{enc: json.NewEncoder(bw)}
+			if format == "csv" {
+				w = &annotationCSVWriter{w: csv.NewWriter(bw)}
+			}
+
+			ctx := cmd.Context()
+			var total, missing int
+			flush := func(batch []string) error {
+				aliases, err := repositories.ResolveRsIDs(ctx, db, batch)
+				if err != nil {
+					return err
+				}
+				lookup := make([]string, len(batch))
+				for i, rsID := range batch {
+					lookup[i] = rsID
+					if current, ok := aliases[rsID]; ok {
+						lookup[i] = current
+					}
+				}
+				found, err := repositories.GetSNPsByRsIDs(ctx, db, lookup)
+				if err != nil {
+					return err
+				}
+				for i, rsID := range batch {
+					snp := found[lookup[i]]
+					if snp == nil {
+						missing++
+					}
+					if err := w.Write(rsIDAnnotation{Query: rsID, Found: snp != nil, SNP: snp}); err != nil {
+						return err
+					}
+				}
+				total += len(batch)
+				return nil
+			}
+
+			sc := bufio.NewScanner(src)
+			batch := make([]string, 0, batchSize)
+			for sc.Scan() {
+				rsID, ok := parseRsIDLine(sc.Text())
+				if !ok {
+					continue
+				}
+				if batch = append(batch, rsID); len(batch) == batchSize {
+					if err := flush(batch); err != nil {
+						return fmt.Errorf("annotate: %w", err)
+					}
+					batch = batch[:0]
+				}
+			}
+			if err := sc.Err(); err != nil {
+				return fmt.Errorf("read input: %w", err)
+			}
+			if err := flush(batch); err != nil {
+				return fmt.Errorf("annotate: %w", err)
+			}
+			if err := w.Close(); err != nil {
+				return err
+			}
+			if err := bw.Flush(); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.ErrOrStderr(), "Annotated %d rsIDs, %d not found\n", total, missing)
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&input, "input", "i", "-", "file of rsIDs, one per line; - for stdin")
+	cmd.Flags().StringVar(&format, "format", "json", "output format: json (JSON lines) or csv")
+	cmd.Flags().StringVarP(&out, "out", "o", "", "file to write (defaults to stdout)")
+	cmd.Flags().IntVar(&batchSize, "batch-size", 1000, "rsIDs looked up per batch")
+	return cmd
+}
+
+// parseRsIDLine returns the rsID in the first field of a line, in canonical
+// form if it is one, or false for blank and comment lines.
+func parseRsIDLine(line string) (string, bool) {
+	fields := strings.FieldsFunc(line, func(r rune) bool {
+		return r == ',' || r == '\t' || r == ' ' || r == ';'
+	})
+	if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+		return "", false
+	}
+	if rsID, err := models.NormalizeRsID(fields[0]); err == nil {
+		return rsID, true
+	}
+	return fields[0], true
+}
+
+type annotationJSONWriter struct {
+	enc *json.Encoder
+}
+
+func (w *annotationJSONWriter) Write(a rsIDAnnotation) error {
+	return w.enc.Encode(a)
+}
+
+func (w *annotationJSONWriter) Close() error {
+	return nil
+}
+
+// annotationCSVWriter writes the export CSV columns of each SNP, after the
+// query and whether it was found, with the distinct clinical significances
+// and conditions of its assertions joined by semicolons.
+type annotationCSVWriter struct {
+	w           *csv.Writer
+	wroteHeader bool
+}
+
+func (w *annotationCSVWriter) Write(a rsIDAnnotation) error {
+	if !w.wroteHeader {
+		if err := w.writeHeader(); err != nil {
+			return err
+		}
+	}
+	row := []string{a.Query, fmt.Sprint(a.Found)}
+	if a.SNP == nil {
+		return w.w.Write(append(row, make([]string, len(csvHeader)+2)...))
+	}
+	var significances, conditions []string
+	for _, c := range a.SNP.ClinicalData {
+		if !slices.Contains(significances, string(c.ClinicalSignificance)) {
+			significances = append(significances, string(c.ClinicalSignificance))
+		}
+		if !slices.Contains(conditions, c.ConditionName) {
+			conditions = append(conditions, c.ConditionName)
+		}
+	}
+	row = append(row, csvRow(a.SNP)...)
+	return w.w.Write(append(row, strings.Join(significances, ";"), strings.Join(conditions, ";")))
+}
+
+func (w *annotationCSVWriter) writeHeader() error {
+	w.wroteHeader = true
+	header := append([]string{"query", "found"}, csvHeader...)
+	return w.w.Write(append(header, "clinical_significances", "conditions"))
+}
+
+func (w *annotationCSVWriter) Close() error {
+	if !w.wroteHeader {
+		if err := w.writeHeader(); err != nil {
+			return err
+		}
+	}
+	w.w.Flush()
+	return w.w.Error()
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/uptrace/bun"
+
+	"github.com/mkoziy/genome/exporter/internal/attribution"
+)
+
+func newAttributionCmd(opts *rootOptions) *cobra.Command {
+	var (
+		format string
+		out    string
+	)
+	cmd := &cobra.Command{
+		Use:   "attribution",
+		Short: "Write the attribution manifest of the database's sources",
+		Long: `Write the attribution manifest of the database: for every source it holds data
+from, the source's version, licence and terms of use, when it was last fetched
+and how many records it contributed. Redistribution terms differ per source,
+e.g. SNPedia's data may not be used commercially. json is the manifest
+embedded in exports; text is meant as a NOTICE file.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if format != "text" && format != "json" {
+				return fmt.Errorf("unknown --format %q (want text or json)", format)
+			}
+			db, err := opts.openDB()
+			if err != nil {
+				return err
+			}
+			defer func() {
+				_ = db.Close()
+			}()
+
+			manifest, err := attribution.Build(cmd.Context(), db)
+			if err != nil {
+				return fmt.Errorf("attribution: %w", err)
+			}
+			dst := cmd.OutOrStdout()
+			if out != "" {
+				f, err := os.Create(out)
+				if err != nil {
+					return fmt.Errorf("create output: %w", err)
+				}
+				defer func() {
+					_ = f.Close()
+				}()
+				dst = f
+			}
+			if format == "json" {
+				return manifest.WriteJSON(dst)
+			}
+			return manifest.WriteText(dst)
+		},
+	}
+	cmd.Flags().StringVar(&format, "format", "text", "output format: text or json")
+	cmd.Flags().StringVarP(&out, "out", "o", "", "file to write (defaults to stdout)")
+	return cmd
+}
+
+// writeAttribution writes the attribution manifest of db to path next to an
+// export, and warns on w of sources whose terms restrict redistribution.
+func writeAttribution(ctx context.Context, db *bun.DB, path string, w io.Writer) error {
+	manifest, err := attribution.Build(ctx, db)
+	if err != nil {
+		return fmt.Errorf("attribution: %w", err)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("create attribution: %w", err)
+	}
+	if err := manifest.WriteJSON(f); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("write attribution: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("write attribution: %w", err)
+	}
+	for _, s := range manifest.Restricted() {
+		fmt.Fprintf(w, "Note: %s data is redistributed on conditions (%s); see %s\n",
+			s.Title, strings.Join(s.Conditions, ", "), cmp.Or(s.TermsURL, s.URL))
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/mkoziy/genome/exporter/internal/database"
+)
+
+func newBackupCmd(opts *rootOptions) *cobra.Command {
+	var out string
+	cmd := &cobra.Command{
+		Use:   "backup",
+		Short: "Write a consistent snapshot of the database",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if out == "" {
+				return errors.New("--out is required")
+			}
+
+			db, err := opts.openDB()
+			if err != nil {
+				return err
+			}
+			defer func() {
+				_ = db.Close()
+			}()
+
+			if err := database.Backup(cmd.Context(), db, out); err != nil {
+				return fmt.Errorf("backup: %w", err)
+			}
+
+			fmt.Fprintf(cmd.OutOrStdout(), "Backup written to %s\n", out)
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&out, "out", "", "path of the snapshot to write (must not exist)")
+	return cmd
+}
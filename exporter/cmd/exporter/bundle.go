@@ -0,0 +1,167 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+
+	"github.com/mkoziy/genome/exporter/internal/bundle"
+	"github.com/mkoziy/genome/exporter/internal/verify"
+)
+
+func newBundleCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "bundle",
+		Short: "Write checksum manifests of distribution artifacts and their signing keys",
+		Long: `A manifest lists the SHA-256 checksum of every file of a bundle; signed, the
+Ed25519 signature of its exact bytes is stored base64-encoded in
+<manifest>.sig. export-mobile writes one for the database it exports. Check
+a bundle with verify-bundle.`,
+	}
+	cmd.AddCommand(newBundleKeygenCmd(), newBundleManifestCmd())
+	return cmd
+}
+
+func newBundleKeygenCmd() *cobra.Command {
+	var out string
+	cmd := &cobra.Command{
+		Use:   "keygen",
+		Short: "Generate an Ed25519 key pair for signing manifests",
+		Long: `Write a new Ed25519 key pair to OUT.key, readable by its owner only, and
+OUT.pub, PEM encoded as openssl writes them. Keep the private key with
+whoever publishes releases and embed the public key in the apps.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if out == "" {
+				return errors.New("--out is required")
+			}
+			pub, err := bundle.GenerateKey(out+".key", out+".pub")
+			if err != nil {
+				return fmt.Errorf("keygen: %w", err)
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Wrote %s.key and %s.pub (key id %s)\n", out, out, bundle.KeyID(pub))
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&out, "out", "o", "", "path of the key files, without extension")
+	return cmd
+}
+
+func newBundleManifestCmd() *cobra.Command {
+	var (
+		out     string
+		signKey string
+	)
+	cmd := &cobra.Command{
+		Use:   "manifest PATH...",
+		Short: "Write the checksum manifest of files and directories",
+		Long: `Write the manifest of the files given, and of every file under the
+directories given, to --out. Their paths are recorded relative to the
+manifest's directory, which they must be under. With --sign-key the
+manifest is signed.`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if out == "" {
+				return errors.New("--out is required")
+			}
+			m, err := writeManifest(out, signKey, args...)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Wrote manifest of %d files to %s\n", len(m.Files), out)
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&out, "out", "o", "", "path of the manifest to write")
+	cmd.Flags().StringVar(&signKey, "sign-key", "", "PEM Ed25519 private key to sign the manifest with")
+	return cmd
+}
+
+// writeManifest writes the manifest of paths to out, signed with the key at
+// signKey unless it is empty.
+func writeManifest(out, signKey string, paths ...string) (*bundle.Manifest, error) {
+	var key ed25519.PrivateKey
+	if signKey != "" {
+		var err error
+		if key, err = bundle.ReadPrivateKey(signKey); err != nil {
+			return nil, fmt.Errorf("read signing key: %w", err)
+		}
+	}
+	// Paths given on the command line are relative to the working directory.
+	abs := make([]string, len(paths))
+	for i, p := range paths {
+		var err error
+		if abs[i], err = filepath.Abs(p); err != nil {
+			return nil, err
+		}
+	}
+	m, err := bundle.Build(filepath.Dir(out), abs, out, out+bundle.SignatureSuffix)
+	if err != nil {
+		return nil, fmt.Errorf("manifest: %w", err)
+	}
+	if err := m.Write(out, key); err != nil {
+		return nil, fmt.Errorf("write manifest: %w", err)
+	}
+	return m, nil
+}
+
+func newVerifyBundleCmd() *cobra.Command {
+	var (
+		publicKey string
+		asJSON    bool
+	)
+	cmd := &cobra.Command{
+		Use:   "verify-bundle MANIFEST",
+		Short: "Check the files of a bundle against its manifest and signature",
+		Long: `Check that every file listed in the manifest is present next to it with
+the checksum recorded. With --public-key the manifest must also carry a
+valid signature by that key. Exits 0 if the bundle is intact, 1 if files are
+missing or differ and 2 if the signature is invalid or the check failed.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var key ed25519.PublicKey
+			if publicKey != "" {
+				var err error
+				if key, err = bundle.ReadPublicKey(publicKey); err != nil {
+					fmt.Fprintf(os.Stderr, "read public key: %v\n", err)
+					return exitCode(verify.ExitError)
+				}
+			}
+			res, err := bundle.Verify(args[0], key)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "verify-bundle: %v\n", err)
+				return exitCode(verify.ExitError)
+			}
+
+			w := cmd.OutOrStdout()
+			if asJSON {
+				enc := json.NewEncoder(w)
+				enc.SetIndent("", "  ")
+				if err := enc.Encode(res); err != nil {
+					return err
+				}
+			} else {
+				for _, problem := range res.Problems {
+					fmt.Fprintln(w, problem)
+				}
+				state := "unsigned"
+				if res.Signed {
+					state = "signed by key " + res.Manifest.KeyID
+				}
+				fmt.Fprintf(w, "%d files checked, %d problems; manifest %s\n", len(res.Manifest.Files), len(res.Problems), state)
+			}
+			if !res.OK() {
+				return exitCode(verify.ExitIssues)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&publicKey, "public-key", "", "PEM Ed25519 public key the manifest must be signed with")
+	cmd.Flags().BoolVar(&asJSON, "json", false, "write the result as JSON")
+	return cmd
+}
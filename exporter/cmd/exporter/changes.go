@@ -0,0 +1,200 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/uptrace/bun"
+
+	"github.com/mkoziy/genome/exporter/internal/models"
+	"github.com/mkoziy/genome/exporter/internal/repositories"
+)
+
+// snpChange is a line of changes --snps: the current state of a SNP changed
+// since the cursor, or its deletion. ID is the latest change to it and the
+// cursor to resume after.
+type snpChange struct {
+	ID        int64       `json:"id"`
+	RsID      string      `json:"rsid,omitempty"`
+	Operation string      `json:"operation"`
+	SNP       *models.SNP `json:"snp,omitempty"`
+}
+
+func newChangesCmd(opts *rootOptions) *cobra.Command {
+	var (
+		since    int64
+		limit    int
+		follow   bool
+		interval time.Duration
+		snps     bool
+		latest   bool
+		out      string
+	)
+	cmd := &cobra.Command{
+		Use:   "changes",
+		Short: "Stream the change feed as JSON lines",
+		Long: `Write the rows of the SNP tables inserted, updated or deleted since a cursor
+as JSON lines, oldest first, so a search index or cache can be updated
+incrementally. Every change carries an increasing id; pass the last one read
+as --since to resume. With --snps each changed SNP is written once per page
+with all it is annotated with, or as deleted, instead of the row changes.
+--follow keeps polling for new changes.
+
+To start following a dataset loaded in full, take the cursor first with
+--latest.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if limit <= 0 {
+				return errors.New("--limit must be positive")
+			}
+			db, err := opts.openDB()
+			if err != nil {
+				return err
+			}
+			defer func() {
+				_ = db.Close()
+			}()
+			ctx := cmd.Context()
+
+			if latest {
+				id, err := repositories.LastChangeID(ctx, db)
+				if err != nil {
+					return fmt.Errorf("changes: %w", err)
+				}
+				fmt.Fprintln(cmd.OutOrStdout(), id)
+				return nil
+			}
+
+			dst := cmd.OutOrStdout()
+			if out != "" {
+				f, err := os.Create(out)
+				if err != nil {
+					return fmt.Errorf("create output: %w", err)
+				}
+				defer func() {
+					_ = f.Close()
+				}()
+				dst = f
+			}
+			enc := json.NewEncoder(dst)
+
+			cursor := since
+			for {
+				changes, err := repositories.ListChanges(ctx, db, cursor, limit)
+				if err != nil {
+					return fmt.Errorf("changes: %w", err)
+				}
+				if len(changes) == 0 {
+					if !follow {
+						return nil
+					}
+					select {
+					case <-ctx.Done():
+						return nil
+					case <-time.After(interval):
+					}
+					continue
+				}
+				if snps {
+					err = writeSNPChanges(ctx, db, enc, changes)
+				} else {
+					for _, c := range changes {
+						if err = enc.Encode(c); err != nil {
+							break
+						}
+					}
+				}
+				if err != nil {
+					return fmt.Errorf("changes: %w", err)
+				}
+				cursor = changes[len(changes)-1].ID
+			}
+		},
+	}
+	cmd.Flags().Int64Var(&since, "since", 0, "write the changes after this change id")
+	cmd.Flags().IntVar(&limit, "limit", 1000, "changes read per page")
+	cmd.Flags().BoolVarP(&follow, "follow", "f", false, "keep polling for new changes")
+	cmd.Flags().DurationVar(&interval, "interval", 5*time.Second, "how often --follow polls")
+	cmd.Flags().BoolVar(&snps, "snps", false, "write each changed SNP instead of the row changes")
+	cmd.Flags().BoolVar(&latest, "latest", false, "print the id of the latest change and exit")
+	cmd.Flags().StringVarP(&out, "out", "o", "", "file to write (defaults to stdout)")
+	cmd.AddCommand(newChangesPruneCmd(opts))
+	return cmd
+}
+
+// writeSNPChanges writes each SNP a page of changes touched once, in the
+// order of its latest change.
+func writeSNPChanges(ctx context.Context, db *bun.DB, enc *json.Encoder, changes []*models.Change) error {
+	type touched struct {
+		id   int64
+		rsID string
+	}
+	latest := make(map[int64]*touched)
+	var ids []int64
+	for _, c := range changes {
+		t, ok := latest[c.SNPID]
+		if !ok {
+			t = &touched{}
+			latest[c.SNPID] = t
+			ids = append(ids, c.SNPID)
+		}
+		t.id = c.ID
+		// Rows deleted along with their SNP no longer know its rsID.
+		if c.RsID != nil {
+			t.rsID = *c.RsID
+		}
+	}
+	current, err := repositories.GetSNPsByIDs(ctx, db, ids)
+	if err != nil {
+		return err
+	}
+	slices.SortFunc(ids, func(a, b int64) int { return cmp.Compare(latest[a].id, latest[b].id) })
+	for _, id := range ids {
+		line := snpChange{ID: latest[id].id, RsID: latest[id].rsID, Operation: models.ChangeDelete}
+		if snp, ok := current[id]; ok {
+			line.RsID, line.Operation, line.SNP = snp.RsID, "upsert", snp
+		}
+		if err := enc.Encode(line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func newChangesPruneCmd(opts *rootOptions) *cobra.Command {
+	var olderThan time.Duration
+	cmd := &cobra.Command{
+		Use:   "prune",
+		Short: "Delete changes older than --older-than from the change feed",
+		Long: `Delete the changes recorded longer ago than --older-than. Consumers whose
+cursor falls before the oldest remaining change must reload the full dataset.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if olderThan <= 0 {
+				return errors.New("--older-than must be positive")
+			}
+			db, err := opts.openDB()
+			if err != nil {
+				return err
+			}
+			defer func() {
+				_ = db.Close()
+			}()
+			n, err := repositories.PruneChanges(cmd.Context(), db, time.Now().Add(-olderThan))
+			if err != nil {
+				return fmt.Errorf("prune changes: %w", err)
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Pruned %d changes\n", n)
+			return nil
+		},
+	}
+	cmd.Flags().DurationVar(&olderThan, "older-than", 30*24*time.Hour, "age of the changes to delete")
+	return cmd
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/mkoziy/genome/exporter/internal/models"
+	"github.com/mkoziy/genome/exporter/internal/ontology"
+	"github.com/mkoziy/genome/exporter/internal/repositories"
+)
+
+func newConditionsCmd(opts *rootOptions) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "conditions",
+		Short: "Maintain the condition hierarchy and query conditions with their subtypes",
+		Long: `Conditions are the terms of the MONDO and MedGen disease ontologies, with
+the is-a relations that make one a subtype of another. Clinical assertions
+name conditions by MedGen ID; MONDO terms reach them through the MedGen ID
+they map to. With a hierarchy loaded, querying a condition such as
+cardiomyopathy takes in every SNP asserted for any of its subtypes.`,
+	}
+	cmd.AddCommand(newConditionsLoadCmd(opts), newConditionsShowCmd(opts), newConditionsSNPsCmd(opts))
+	return cmd
+}
+
+func newConditionsLoadCmd(opts *rootOptions) *cobra.Command {
+	var mondo, medgenNames, medgenRelations string
+	cmd := &cobra.Command{
+		Use:   "load",
+		Short: "Load a condition hierarchy from MONDO or MedGen files",
+		Long: `Load the conditions and is-a relations of MONDO from its OBO file
+(mondo.obo), or of MedGen from its NAMES.RRF and MGREL.RRF files, gzipped or
+not. Conditions already known are updated and their parents replaced.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var (
+				h   *ontology.Hierarchy
+				err error
+			)
+			switch {
+			case mondo != "" && (medgenNames != "" || medgenRelations != ""):
+				return errors.New("load --mondo and the MedGen files separately")
+			case mondo != "":
+				h, err = parseFiles(func(f []*os.File) (*ontology.Hierarchy, error) { return ontology.ParseMONDO(f[0]) }, mondo)
+			case medgenNames != "" && medgenRelations != "":
+				h, err = parseFiles(func(f []*os.File) (*ontology.Hierarchy, error) { return ontology.ParseMedGen(f[0], f[1]) }, medgenNames, medgenRelations)
+			default:
+				return errors.New("--mondo, or --medgen-names and --medgen-relations, are required")
+			}
+			if err != nil {
+				return err
+			}
+			db, err := opts.openDB()
+			if err != nil {
+				return err
+			}
+			defer func() {
+				_ = db.Close()
+			}()
+			if err := repositories.SaveConditions(cmd.Context(), db, h.Conditions, h.Relations); err != nil {
+				return fmt.Errorf("load conditions: %w", err)
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Loaded %d conditions and %d relations\n", len(h.Conditions), len(h.Relations))
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&mondo, "mondo", "", "MONDO OBO file")
+	cmd.Flags().StringVar(&medgenNames, "medgen-names", "", "MedGen NAMES.RRF file")
+	cmd.Flags().StringVar(&medgenRelations, "medgen-relations", "", "MedGen MGREL.RRF file")
+	return cmd
+}
+
+// parseFiles opens paths and parses them together with parse.
+func parseFiles(parse func([]*os.File) (*ontology.Hierarchy, error), paths ...string) (*ontology.Hierarchy, error) {
+	files := make([]*os.File, 0, len(paths))
+	defer func() {
+		for _, f := range files {
+			_ = f.Close()
+		}
+	}()
+	for _, path := range paths {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, f)
+	}
+	h, err := parse(files)
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", strings.Join(paths, ", "), err)
+	}
+	return h, nil
+}
+
+// conditionTree is a condition with its parents and direct subtypes.
+type conditionTree struct {
+	*models.Condition
+	Parents  []*models.Condition `json:"parents"`
+	Children []*models.Condition `json:"children"`
+	Subtypes int                 `json:"subtypes"`
+}
+
+func newConditionsShowCmd(opts *rootOptions) *cobra.Command {
+	var asJSON bool
+	cmd := &cobra.Command{
+		Use:   "show CONDITION",
+		Short: "Show a condition with its parents and subtypes",
+		Long: `Show the condition CONDITION names, by ID, MedGen ID or name, with the
+conditions it is a subtype of, its direct subtypes and how many subtypes it
+has at any depth.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			db, err := opts.openDB()
+			if err != nil {
+				return err
+			}
+			defer func() {
+				_ = db.Close()
+			}()
+			ctx := cmd.Context()
+			c, err := repositories.FindCondition(ctx, db, args[0])
+			if errors.Is(err, sql.ErrNoRows) {
+				return fmt.Errorf("no condition %q", args[0])
+			} else if err != nil {
+				return err
+			}
+			tree := conditionTree{Condition: c}
+			if tree.Parents, err = repositories.GetConditionParents(ctx, db, c.ID); err != nil {
+				return err
+			}
+			if tree.Children, err = repositories.GetConditionChildren(ctx, db, c.ID); err != nil {
+				return err
+			}
+			subtypes, err := repositories.GetConditionSubtypes(ctx, db, c.ID)
+			if err != nil {
+				return err
+			}
+			tree.Subtypes = len(subtypes)
+
+			if asJSON {
+				enc := json.NewEncoder(cmd.OutOrStdout())
+				enc.SetIndent("", "  ")
+				return enc.Encode(tree)
+			}
+			w := cmd.OutOrStdout()
+			fmt.Fprintf(w, "%s %s", c.ID, c.Name)
+			if c.MedGenID != nil {
+				fmt.Fprintf(w, " (MedGen %s)", *c.MedGenID)
+			}
+			fmt.Fprintln(w)
+			for _, p := range tree.Parents {
+				fmt.Fprintf(w, "  is a %s %s\n", p.ID, p.Name)
+			}
+			for _, ch := range tree.Children {
+				fmt.Fprintf(w, "  subtype %s %s\n", ch.ID, ch.Name)
+			}
+			fmt.Fprintf(w, "Subtypes at any depth: %d\n", tree.Subtypes)
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&asJSON, "json", false, "write the condition as JSON")
+	return cmd
+}
+
+func newConditionsSNPsCmd(opts *rootOptions) *cobra.Command {
+	var asJSON bool
+	cmd := &cobra.Command{
+		Use:   "snps CONDITION",
+		Short: "List the SNPs of a condition and all of its subtypes",
+		Long: `List every SNP with a clinical assertion for the condition CONDITION names,
+by ID, MedGen ID or name, or for any of its subtypes at any depth, highest
+scored first.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			db, err := opts.openDB()
+			if err != nil {
+				return err
+			}
+			defer func() {
+				_ = db.Close()
+			}()
+			report, err := repositories.GetConditionRollup(cmd.Context(), db, args[0])
+			if errors.Is(err, sql.ErrNoRows) {
+				return fmt.Errorf("no condition %q", args[0])
+			} else if err != nil {
+				return err
+			}
+			if asJSON {
+				enc := json.NewEncoder(cmd.OutOrStdout())
+				enc.SetIndent("", "  ")
+				return enc.Encode(report)
+			}
+			w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "RSID\tGENE\tSCORE\tSIGNIFICANCE\tCONDITION")
+			for _, v := range report.Variants {
+				gene, score := "-", "-"
+				if v.SNP.GeneSymbol != nil {
+					gene = *v.SNP.GeneSymbol
+				}
+				if v.SNP.Significance != nil {
+					score = fmt.Sprintf("%.1f", v.SNP.Significance.TotalScore)
+				}
+				var significances, conditions []string
+				for _, a := range v.Assertions {
+					if !slices.Contains(significances, string(a.ClinicalSignificance)) {
+						significances = append(significances, string(a.ClinicalSignificance))
+					}
+					if !slices.Contains(conditions, a.ConditionName) {
+						conditions = append(conditions, a.ConditionName)
+					}
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", v.SNP.RsID, gene, score, strings.Join(significances, ";"), strings.Join(conditions, ";"))
+			}
+			if err := w.Flush(); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "%d SNPs under %s %s\n", len(report.Variants), report.ConditionID, report.ConditionName)
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&asJSON, "json", false, "write the SNPs with their assertions as JSON")
+	return cmd
+}
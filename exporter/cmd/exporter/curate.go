@@ -0,0 +1,149 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/mkoziy/genome/exporter/internal/models"
+	"github.com/mkoziy/genome/exporter/internal/repositories"
+)
+
+func newCurateCmd(opts *rootOptions) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "curate",
+		Short: "Retract variants, or what a source says about them, and restore them",
+		Long: `Retracting a SNP hides it and its annotations from queries and exports,
+as deleting it would, but keeps the rows so the retraction can be restored.
+Retracting with --source hides only that source's annotations of the SNP,
+such as ClinVar records withdrawn by their submitters. Each retraction is
+recorded with its reason and curator, and stays listed once restored.`,
+	}
+	cmd.AddCommand(newCurateRetractCmd(opts), newCurateRestoreCmd(opts), newCurateListCmd(opts))
+	return cmd
+}
+
+func newCurateRetractCmd(opts *rootOptions) *cobra.Command {
+	var (
+		source  string
+		reason  string
+		curator string
+	)
+	cmd := &cobra.Command{
+		Use:   "retract RSID...",
+		Short: "Retract SNPs, or a source's annotations of them",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			retraction := models.Retraction{Source: models.DataSource(source), Reason: reason}
+			if curator != "" {
+				retraction.Curator = &curator
+			}
+			db, err := opts.openDB()
+			if err != nil {
+				return err
+			}
+			defer func() {
+				_ = db.Close()
+			}()
+			recorded, missing, err := repositories.RetractSNPs(cmd.Context(), db, args, retraction)
+			if err != nil {
+				return fmt.Errorf("retract: %w", err)
+			}
+			out := cmd.OutOrStdout()
+			if source != "" {
+				fmt.Fprintf(out, "Retracted the %s annotations of %d SNPs\n", source, len(recorded))
+			} else {
+				fmt.Fprintf(out, "Retracted %d SNPs\n", len(recorded))
+			}
+			if len(missing) > 0 {
+				fmt.Fprintf(out, "Not found or already retracted: %s\n", strings.Join(missing, ", "))
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&source, "source", "", "retract only the annotations of this source")
+	cmd.Flags().StringVar(&reason, "reason", "", "why the SNPs are retracted (required)")
+	cmd.Flags().StringVar(&curator, "curator", "", "who retracts them")
+	return cmd
+}
+
+func newCurateRestoreCmd(opts *rootOptions) *cobra.Command {
+	var source string
+	cmd := &cobra.Command{
+		Use:   "restore RSID...",
+		Short: "Undo the retraction of SNPs, or of a source's annotations of them",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if source != "" && !models.DataSource(source).IsValid() {
+				return fmt.Errorf("unknown source %q", source)
+			}
+			db, err := opts.openDB()
+			if err != nil {
+				return err
+			}
+			defer func() {
+				_ = db.Close()
+			}()
+			restored, err := repositories.RestoreSNPs(cmd.Context(), db, args, models.DataSource(source))
+			if err != nil {
+				return fmt.Errorf("restore: %w", err)
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Restored %d of %d SNPs\n", len(restored), len(args))
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&source, "source", "", "restore only the annotations of this source")
+	return cmd
+}
+
+func newCurateListCmd(opts *rootOptions) *cobra.Command {
+	var (
+		all    bool
+		asJSON bool
+	)
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List the standing retractions, newest first",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			db, err := opts.openDB()
+			if err != nil {
+				return err
+			}
+			defer func() {
+				_ = db.Close()
+			}()
+			retractions, err := repositories.ListRetractions(cmd.Context(), db, all)
+			if err != nil {
+				return fmt.Errorf("list retractions: %w", err)
+			}
+			if asJSON {
+				enc := json.NewEncoder(cmd.OutOrStdout())
+				enc.SetIndent("", "  ")
+				return enc.Encode(retractions)
+			}
+			w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "RSID\tSOURCE\tRETRACTED\tCURATOR\tRESTORED\tREASON")
+			for _, r := range retractions {
+				source, curator, restored := "*", "-", "-"
+				if r.Source != "" {
+					source = string(r.Source)
+				}
+				if r.Curator != nil {
+					curator = *r.Curator
+				}
+				if r.RestoredAt != nil {
+					restored = r.RestoredAt.Format("2006-01-02")
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", r.RsID, source, r.RetractedAt.Format("2006-01-02"), curator, restored, r.Reason)
+			}
+			return w.Flush()
+		},
+	}
+	cmd.Flags().BoolVar(&all, "all", false, "also list restored retractions")
+	cmd.Flags().BoolVar(&asJSON, "json", false, "write the retractions as JSON")
+	return cmd
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/mkoziy/genome/exporter/internal/repositories"
+)
+
+func newDedupeCmd(opts *rootOptions) *cobra.Command {
+	var dryRun bool
+	cmd := &cobra.Command{
+		Use:   "dedupe",
+		Short: "Merge SNPs with the same normalized variant under different rsIDs",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			db, err := opts.openDB()
+			if err != nil {
+				return err
+			}
+			defer func() {
+				_ = db.Close()
+			}()
+
+			ctx := cmd.Context()
+			out := cmd.OutOrStdout()
+			if dryRun {
+				groups, err := repositories.FindDuplicateSNPs(ctx, db)
+				if err != nil {
+					return fmt.Errorf("find duplicates: %w", err)
+				}
+				for _, group := range groups {
+					fmt.Fprintf(out, "%s keep=%s merge=", group.VariantKey, group.SNPs[0].RsID)
+					for i, snp := range group.SNPs[1:] {
+						if i > 0 {
+							fmt.Fprint(out, ",")
+						}
+						fmt.Fprint(out, snp.RsID)
+					}
+					fmt.Fprintln(out)
+				}
+				fmt.Fprintf(out, "%d duplicate groups\n", len(groups))
+				return nil
+			}
+
+			result, err := repositories.MergeDuplicates(ctx, db)
+			if err != nil {
+				return fmt.Errorf("dedupe: %w", err)
+			}
+
+			fmt.Fprintf(out, "Merged %d SNPs in %d duplicate groups\n", result.Merged, result.Groups)
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "list duplicate groups without merging")
+	return cmd
+}
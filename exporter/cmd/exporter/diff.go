@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/uptrace/bun"
+
+	"github.com/mkoziy/genome/exporter/internal/database"
+	"github.com/mkoziy/genome/exporter/internal/diff"
+)
+
+func newDiffCmd(opts *rootOptions) *cobra.Command {
+	var (
+		format   string
+		out      string
+		maxItems int
+	)
+	cmd := &cobra.Command{
+		Use:   "diff OLD.db NEW.db",
+		Short: "Report the SNPs added, removed, changed and reclassified between two databases",
+		Long: `Compare two generated databases, typically consecutive releases, and report the
+SNPs added and removed, those whose coordinates, gene, functional class or
+significance level changed, and the clinical assertions whose significance
+changed, e.g. uncertain significance to pathogenic. The markdown format is
+meant as release notes; text lists one change per line and json is the full
+structured report.`,
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if format != "text" && format != "json" && format != "markdown" {
+				return fmt.Errorf("unknown --format %q (want text, json or markdown)", format)
+			}
+			oldDB, err := openExisting(args[0], opts.cfg.Database.Debug)
+			if err != nil {
+				return err
+			}
+			defer func() {
+				_ = oldDB.Close()
+			}()
+			newDB, err := openExisting(args[1], opts.cfg.Database.Debug)
+			if err != nil {
+				return err
+			}
+			defer func() {
+				_ = newDB.Close()
+			}()
+
+			report, err := diff.Compare(cmd.Context(), oldDB, newDB, diff.Options{MaxItems: maxItems})
+			if err != nil {
+				return fmt.Errorf("diff: %w", err)
+			}
+
+			dst := cmd.OutOrStdout()
+			if out != "" {
+				f, err := os.Create(out)
+				if err != nil {
+					return fmt.Errorf("create output: %w", err)
+				}
+				defer func() {
+					_ = f.Close()
+				}()
+				dst = f
+			}
+			switch format {
+			case "json":
+				return report.WriteJSON(dst)
+			case "markdown":
+				return report.WriteMarkdown(dst)
+			}
+			return report.WriteText(dst)
+		},
+	}
+	cmd.Flags().StringVar(&format, "format", "text", "output format: text, json or markdown")
+	cmd.Flags().StringVarP(&out, "out", "o", "", "file to write (defaults to stdout)")
+	cmd.Flags().IntVar(&maxItems, "max-items", 0, "list at most this many entries of each kind; counts stay complete (0 lists all)")
+	return cmd
+}
+
+// openExisting opens the database file at path, which unlike --db must
+// exist, so a mistyped path is not compared as an empty database.
+func openExisting(path string, debug bool) (*bun.DB, error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, err
+	}
+	db, err := database.NewDB(path, debug)
+	if err != nil {
+		return nil, fmt.Errorf("open %s: %w", path, err)
+	}
+	return db, nil
+}
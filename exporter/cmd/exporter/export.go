@@ -0,0 +1,324 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/uptrace/bun"
+
+	"github.com/mkoziy/genome/exporter/internal/config"
+	"github.com/mkoziy/genome/exporter/internal/database"
+	"github.com/mkoziy/genome/exporter/internal/models"
+	"github.com/mkoziy/genome/exporter/internal/repositories"
+	"github.com/mkoziy/genome/exporter/internal/summary"
+)
+
+// exportFormats maps each export format to a constructor for its row writer.
+// The sqlite format writes a database rather than rows; see exportSubset.
+var exportFormats = map[string]func(io.Writer) snpWriter{
+	"jsonl": newJSONLinesWriter,
+	"csv":   newCSVWriter,
+}
+
+// snpWriter writes SNPs in one export format.
+type snpWriter interface {
+	Write(snp *models.SNP) error
+	Close() error
+}
+
+func newExportCmd(opts *rootOptions) *cobra.Command {
+	var (
+		out        string
+		credits    string
+		filterSpec string
+		batchSize  int
+	)
+	cmd := &cobra.Command{
+		Use:   "export [format]",
+		Short: "Write SNPs as JSON lines, CSV or a SQLite database (default from config)",
+		Long: `Write every SNP as JSON lines or CSV, or a copy of the database as SQLite.
+
+--filter restricts the export to the SNPs an SNPFilter spec matches, given
+inline as JSON or read from a file as @path, for example:
+
+  export sqlite -o pathogenic.db --filter '{"clinical_significances":["pathogenic"],"review_statuses":["reviewed_by_expert_panel"],"min_score":60}'
+
+The sqlite format requires --out and keeps, of every table belonging to a
+SNP, the rows of the SNPs matched; catalogs keyed by rsID are kept whole.`,
+		Args:      cobra.MatchAll(cobra.MaximumNArgs(1), cobra.OnlyValidArgs),
+		ValidArgs: config.ExportFormats,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			format := opts.cfg.Export.Format
+			if len(args) == 1 {
+				format = args[0]
+			}
+			if !cmd.Flags().Changed("batch-size") {
+				batchSize = opts.cfg.Export.BatchSize
+			}
+			if format == "sqlite" && out == "" {
+				return errors.New("--out is required for the sqlite format")
+			}
+			var filter repositories.SNPFilter
+			if filterSpec != "" {
+				var err error
+				if filter, err = parseSNPFilter(filterSpec); err != nil {
+					return fmt.Errorf("--filter: %w", err)
+				}
+			}
+
+			db, err := opts.openDB()
+			if err != nil {
+				return err
+			}
+			defer func() {
+				_ = db.Close()
+			}()
+
+			var exported int
+			if format == "sqlite" {
+				if exported, err = exportSubset(cmd.Context(), db, out, filter); err != nil {
+					return fmt.Errorf("export: %w", err)
+				}
+			} else if exported, err = exportRows(cmd.Context(), db, cmd.OutOrStdout(), format, out, batchSize, filter); err != nil {
+				return err
+			}
+
+			if out != "" {
+				fmt.Fprintf(cmd.OutOrStdout(), "Exported %d SNPs to %s\n", exported, out)
+			}
+			if credits == "" && out != "" {
+				credits = out + ".attribution.json"
+			}
+			if credits != "" {
+				if err := writeAttribution(cmd.Context(), db, credits, cmd.ErrOrStderr()); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&out, "out", "o", "", "file to write (defaults to stdout; required for sqlite)")
+	cmd.Flags().StringVar(&credits, "attribution", "", "file to write the sources' attribution manifest to (defaults to OUT.attribution.json with --out)")
+	cmd.Flags().StringVar(&filterSpec, "filter", "", "export only the SNPs matching this SNPFilter, as JSON or @file")
+	cmd.Flags().IntVar(&batchSize, "batch-size", config.DefaultConfig().Export.BatchSize, "SNPs loaded per batch")
+	return cmd
+}
+
+// parseSNPFilter decodes an SNPFilter from spec, JSON or @ followed by the
+// path of a file of it. Unknown fields are refused, so a misspelled
+// condition is not silently dropped and the whole database exported.
+func parseSNPFilter(spec string) (repositories.SNPFilter, error) {
+	var filter repositories.SNPFilter
+	data := []byte(spec)
+	if path, ok := strings.CutPrefix(spec, "@"); ok {
+		var err error
+		if data, err = os.ReadFile(path); err != nil {
+			return filter, err
+		}
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&filter); err != nil {
+		return filter, fmt.Errorf("decode: %w", err)
+	}
+	if err := filter.Validate(); err != nil {
+		return filter, err
+	}
+	return filter, nil
+}
+
+// exportRows writes the SNPs filter matches to out, or stdout, in a row
+// format, and returns how many it wrote.
+func exportRows(ctx context.Context, db *bun.DB, dst io.Writer, format, out string, batchSize int, filter repositories.SNPFilter) (int, error) {
+	if out != "" {
+		f, err := os.Create(out)
+		if err != nil {
+			return 0, fmt.Errorf("create output: %w", err)
+		}
+		defer func() {
+			_ = f.Close()
+		}()
+		dst = f
+	}
+
+	w := exportFormats[format](dst)
+	var exported int
+	err := repositories.ForEachMatchingSNP(ctx, db, batchSize, filter, func(batch []*models.SNP) error {
+		for _, snp := range batch {
+			if err := w.Write(snp); err != nil {
+				return err
+			}
+			exported++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("export: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return 0, fmt.Errorf("export: %w", err)
+	}
+	return exported, nil
+}
+
+// exportSubset writes to path a copy of db holding only the SNPs filter
+// matches, with the summaries refreshed over them, and returns how many it
+// holds. The copy is built beside path and moved there once complete, so a
+// failed export never leaves the whole database at path.
+func exportSubset(ctx context.Context, db *bun.DB, path string, filter repositories.SNPFilter) (int, error) {
+	if _, err := os.Stat(path); err == nil {
+		return 0, fmt.Errorf("%s already exists", path)
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return 0, err
+	}
+	tmp := path + ".subset"
+	removeTmp := func() {
+		for _, suffix := range []string{"", "-wal", "-shm"} {
+			_ = os.Remove(tmp + suffix)
+		}
+	}
+	removeTmp()
+	if err := database.Backup(ctx, db, tmp); err != nil {
+		return 0, err
+	}
+
+	kept, err := subsetDB(ctx, tmp, filter)
+	if err != nil {
+		removeTmp()
+		return 0, err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		removeTmp()
+		return 0, fmt.Errorf("rename subset: %w", err)
+	}
+	return kept, nil
+}
+
+// subsetDB trims the database at path to the SNPs filter matches and leaves
+// it a single self-contained file.
+func subsetDB(ctx context.Context, path string, filter repositories.SNPFilter) (int, error) {
+	sub, err := database.NewDB(path, false)
+	if err != nil {
+		return 0, fmt.Errorf("open %s: %w", path, err)
+	}
+	defer func() {
+		_ = sub.Close()
+	}()
+
+	kept, err := repositories.Subset(ctx, sub, filter)
+	if err != nil {
+		return 0, fmt.Errorf("subset: %w", err)
+	}
+	if err := summary.Refresh(ctx, sub); err != nil {
+		return 0, fmt.Errorf("refresh summaries: %w", err)
+	}
+	// No WAL, no free pages left by the deletions.
+	if _, err := sub.ExecContext(ctx, "PRAGMA journal_mode = DELETE"); err != nil {
+		return 0, fmt.Errorf("leave wal mode: %w", err)
+	}
+	if _, err := sub.ExecContext(ctx, "VACUUM"); err != nil {
+		return 0, fmt.Errorf("vacuum: %w", err)
+	}
+	if err := sub.Close(); err != nil {
+		return 0, fmt.Errorf("close %s: %w", path, err)
+	}
+	return kept, nil
+}
+
+// jsonLinesWriter writes one SNP with all its relations per line.
+type jsonLinesWriter struct {
+	enc *json.Encoder
+}
+
+func newJSONLinesWriter(w io.Writer) snpWriter {
+	return &jsonLinesWriter{enc: json.NewEncoder(w)}
+}
+
+func (w *jsonLinesWriter) Write(snp *models.SNP) error {
+	return w.enc.Encode(snp)
+}
+
+func (w *jsonLinesWriter) Close() error {
+	return nil
+}
+
+// csvHeader lists the flat SNP columns written by csvWriter.
+var csvHeader = []string{
+	"rsid", "chromosome", "position", "reference_allele", "alternate_alleles",
+	"gene_symbol", "variant_type", "functional_class", "total_score", "significance_level",
+	"quality_score", "global_maf",
+}
+
+// csvWriter writes the SNP columns and score; relations do not fit a flat row.
+type csvWriter struct {
+	w           *csv.Writer
+	wroteHeader bool
+}
+
+func newCSVWriter(w io.Writer) snpWriter {
+	return &csvWriter{w: csv.NewWriter(w)}
+}
+
+func (w *csvWriter) Write(snp *models.SNP) error {
+	if !w.wroteHeader {
+		if err := w.w.Write(csvHeader); err != nil {
+			return err
+		}
+		w.wroteHeader = true
+	}
+	return w.w.Write(csvRow(snp))
+}
+
+// csvRow returns the csvHeader columns of snp.
+func csvRow(snp *models.SNP) []string {
+	var gene, class, score, level, quality, maf string
+	if snp.GeneSymbol != nil {
+		gene = *snp.GeneSymbol
+	}
+	if snp.FunctionalClass != nil {
+		class = string(*snp.FunctionalClass)
+	}
+	if snp.Significance != nil {
+		score = strconv.FormatFloat(snp.Significance.TotalScore, 'f', 1, 64)
+		level = snp.Significance.SignificanceLevel()
+	}
+	if snp.Quality != nil {
+		quality = strconv.FormatFloat(snp.Quality.Score, 'f', 1, 64)
+	}
+	if snp.Frequency != nil && snp.Frequency.GlobalMAF != nil {
+		maf = strconv.FormatFloat(*snp.Frequency.GlobalMAF, 'f', -1, 64)
+	}
+	return []string{
+		snp.RsID,
+		snp.Chromosome,
+		strconv.FormatInt(snp.Position, 10),
+		snp.ReferenceAllele,
+		strings.Join(snp.AlternateAlleles, ","),
+		gene,
+		string(snp.VariantType),
+		class,
+		score,
+		level,
+		quality,
+		maf,
+	}
+}
+
+func (w *csvWriter) Close() error {
+	if !w.wroteHeader {
+		if err := w.w.Write(csvHeader); err != nil {
+			return err
+		}
+	}
+	w.w.Flush()
+	return w.w.Error()
+}
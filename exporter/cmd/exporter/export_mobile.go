@@ -0,0 +1,73 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/mkoziy/genome/exporter/internal/bundle"
+	"github.com/mkoziy/genome/exporter/internal/config"
+	"github.com/mkoziy/genome/exporter/internal/mobile"
+)
+
+func newExportMobileCmd(opts *rootOptions) *cobra.Command {
+	var (
+		out     string
+		signKey string
+		mopts   mobile.Options
+	)
+	cmd := &cobra.Command{
+		Use:   "export-mobile",
+		Short: "Write the compact SQLite database embedded by the mobile app",
+		Long: `Write a trimmed SQLite database keyed by numeric rsID holding only each SNP's
+gene and score, its risk alleles with their effects, and its translated texts,
+sized for embedding in a mobile app. Use --min-score, --min-quality and --lang
+to trim it further.
+
+The SHA-256 checksum of the database is written to OUT.manifest.json, signed
+with --sign-key if given, for the app to check with the public key before
+opening it; see bundle and verify-bundle.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if out == "" {
+				return errors.New("--out is required")
+			}
+			if signKey != "" {
+				// Fail before exporting rather than after.
+				if _, err := bundle.ReadPrivateKey(signKey); err != nil {
+					return fmt.Errorf("read signing key: %w", err)
+				}
+			}
+			if !cmd.Flags().Changed("batch-size") {
+				mopts.BatchSize = opts.cfg.Export.BatchSize
+			}
+
+			db, err := opts.openDB()
+			if err != nil {
+				return err
+			}
+			defer func() {
+				_ = db.Close()
+			}()
+
+			stats, err := mobile.Export(cmd.Context(), db, out, mopts)
+			if err != nil {
+				return fmt.Errorf("export: %w", err)
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Exported %d SNPs, %d effects and %d texts to %s (%d bytes)\n",
+				stats.SNPs, stats.Effects, stats.Texts, out, stats.Bytes)
+			if _, err := writeManifest(out+".manifest.json", signKey, out); err != nil {
+				return err
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&out, "out", "o", "", "path of the database to write (must not exist)")
+	cmd.Flags().Float64Var(&mopts.MinScore, "min-score", 0, "leave out SNPs scoring below this, and unscored SNPs when positive")
+	cmd.Flags().Float64Var(&mopts.MinQuality, "min-quality", 0, "leave out SNPs whose data quality is below this, and unassessed SNPs when positive")
+	cmd.Flags().StringSliceVar(&mopts.Languages, "lang", nil, "languages of the texts to include (all when empty)")
+	cmd.Flags().StringVar(&signKey, "sign-key", "", "PEM Ed25519 private key to sign the manifest with")
+	cmd.Flags().IntVar(&mopts.BatchSize, "batch-size", config.DefaultConfig().Export.BatchSize, "SNPs loaded per batch")
+	return cmd
+}
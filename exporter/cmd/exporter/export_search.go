@@ -0,0 +1,104 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/mkoziy/genome/exporter/internal/config"
+	"github.com/mkoziy/genome/exporter/internal/repositories"
+	"github.com/mkoziy/genome/exporter/internal/search"
+)
+
+func newExportSearchCmd(opts *rootOptions) *cobra.Command {
+	var (
+		scfg         search.Config
+		batchSize    int
+		since        int64
+		printMapping bool
+	)
+	cmd := &cobra.Command{
+		Use:   "export-search",
+		Short: "Bulk-index the SNPs into Elasticsearch or OpenSearch",
+		Long: `Index every SNP into an Elasticsearch or OpenSearch index as a document keyed
+by rsID, with its clinical assertions and phenotype associations as nested
+documents. A missing index is created with the bundled mapping; print it with
+--print-mapping to install it yourself, e.g. as an index template.
+
+A full export prints the change feed cursor taken before it started. Pass it
+as --since to index only the SNPs changed since, and delete those removed;
+each incremental export prints the cursor to resume after.
+
+The cluster is configured in the search section of the config file; the
+flags override it.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if printMapping {
+				_, err := cmd.OutOrStdout().Write(search.Mapping)
+				return err
+			}
+			cfg := opts.cfg.Search
+			if cmd.Flags().Changed("url") {
+				cfg.URL = scfg.URL
+			}
+			if cmd.Flags().Changed("index") {
+				cfg.Index = scfg.Index
+			}
+			if err := cfg.Validate(); err != nil {
+				return err
+			}
+			if cfg.URL == "" {
+				return errors.New("--url or search.url is required")
+			}
+			if !cmd.Flags().Changed("batch-size") {
+				batchSize = opts.cfg.Export.BatchSize
+			}
+			client, err := search.NewClient(cfg)
+			if err != nil {
+				return err
+			}
+
+			db, err := opts.openDB()
+			if err != nil {
+				return err
+			}
+			defer func() {
+				_ = db.Close()
+			}()
+			ctx := cmd.Context()
+
+			if cmd.Flags().Changed("since") {
+				stats, err := search.Sync(ctx, db, client, since, batchSize)
+				if err != nil {
+					if stats != nil && stats.Cursor > since {
+						return fmt.Errorf("export: %w (resume with --since %d)", err, stats.Cursor)
+					}
+					return fmt.Errorf("export: %w", err)
+				}
+				fmt.Fprintf(cmd.OutOrStdout(), "Indexed %d and deleted %d SNPs in %s; changes applied up to %d\n",
+					stats.Indexed, stats.Deleted, client.Index(), stats.Cursor)
+				return nil
+			}
+
+			// Changes made while the export runs are applied again by the
+			// next incremental export, which is harmless.
+			cursor, err := repositories.LastChangeID(ctx, db)
+			if err != nil {
+				return fmt.Errorf("export: %w", err)
+			}
+			stats, err := search.Export(ctx, db, client, batchSize)
+			if err != nil {
+				return fmt.Errorf("export: %w", err)
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Indexed %d SNPs in %s; continue with --since %d\n", stats.Indexed, client.Index(), cursor)
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&scfg.URL, "url", "", "URL of the cluster (defaults to search.url)")
+	cmd.Flags().StringVar(&scfg.Index, "index", search.DefaultIndex, "index to write (defaults to search.index)")
+	cmd.Flags().IntVar(&batchSize, "batch-size", config.DefaultConfig().Export.BatchSize, "SNPs, or changes with --since, per bulk request")
+	cmd.Flags().Int64Var(&since, "since", 0, "index only the SNPs changed after this change feed cursor")
+	cmd.Flags().BoolVar(&printMapping, "print-mapping", false, "print the index mapping and exit")
+	return cmd
+}
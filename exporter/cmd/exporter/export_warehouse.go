@@ -0,0 +1,164 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+
+	"github.com/mkoziy/genome/exporter/internal/config"
+	"github.com/mkoziy/genome/exporter/internal/warehouse"
+)
+
+func newExportDuckDBCmd(opts *rootOptions) *cobra.Command {
+	var (
+		out        string
+		dataDir    string
+		bin        string
+		scriptOnly bool
+		batchSize  int
+	)
+	cmd := &cobra.Command{
+		Use:   "export-duckdb",
+		Short: "Write the SNPs and their annotations into a DuckDB database",
+		Long: `Write a DuckDB database with a table per kind of record, named like those of
+the SQLite database: snps, and snp_clinical, snp_phenotypes, snp_references,
+snp_populations, risk_alleles, genotype_effects and clinical_agreements joined
+to it by snp_id or rsid.
+
+The tables are written as JSON lines to --data-dir, a temporary directory
+unless set, with the load.sql that loads them, which the duckdb CLI then
+runs. With --script-only the files are left in --data-dir for you to load
+with "duckdb FILE < load.sql" from there.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if scriptOnly {
+				if dataDir == "" {
+					return errors.New("--script-only requires --data-dir")
+				}
+			} else {
+				if out == "" {
+					return errors.New("--out is required")
+				}
+				path, err := exec.LookPath(bin)
+				if err != nil {
+					return fmt.Errorf("duckdb CLI not found (set --duckdb, or use --script-only): %w", err)
+				}
+				bin = path
+			}
+			if !cmd.Flags().Changed("batch-size") {
+				batchSize = opts.cfg.Export.BatchSize
+			}
+			if dataDir == "" {
+				tmp, err := os.MkdirTemp("", "exporter-duckdb-")
+				if err != nil {
+					return err
+				}
+				defer func() {
+					_ = os.RemoveAll(tmp)
+				}()
+				dataDir = tmp
+			}
+
+			db, err := opts.openDB()
+			if err != nil {
+				return err
+			}
+			defer func() {
+				_ = db.Close()
+			}()
+			ctx := cmd.Context()
+
+			stats, err := warehouse.Export(ctx, db, dataDir, batchSize)
+			if err != nil {
+				return fmt.Errorf("export: %w", err)
+			}
+			if scriptOnly {
+				script := filepath.Join(dataDir, warehouse.DuckDBScript)
+				f, err := os.Create(script)
+				if err != nil {
+					return fmt.Errorf("create output: %w", err)
+				}
+				if err := warehouse.WriteDuckDBScript(f); err != nil {
+					_ = f.Close()
+					return fmt.Errorf("write %s: %w", script, err)
+				}
+				if err := f.Close(); err != nil {
+					return err
+				}
+				fmt.Fprintf(cmd.OutOrStdout(), "Exported %d SNPs to %s\n", stats.Rows["snps"], dataDir)
+				return nil
+			}
+			if err := warehouse.BuildDuckDB(ctx, bin, dataDir, out); err != nil {
+				return fmt.Errorf("export: %w", err)
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Exported %d SNPs to %s\n", stats.Rows["snps"], out)
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&out, "out", "o", "", "path of the DuckDB database to write (replaced if it exists)")
+	cmd.Flags().StringVar(&dataDir, "data-dir", "", "directory to write the table files and load.sql to (a temporary one by default)")
+	cmd.Flags().StringVar(&bin, "duckdb", "duckdb", "duckdb CLI to load the files with")
+	cmd.Flags().BoolVar(&scriptOnly, "script-only", false, "only write the files and load.sql to --data-dir")
+	cmd.Flags().IntVar(&batchSize, "batch-size", config.DefaultConfig().Export.BatchSize, "SNPs loaded per batch")
+	return cmd
+}
+
+func newExportBigQueryCmd(opts *rootOptions) *cobra.Command {
+	var (
+		outDir    string
+		target    warehouse.BigQueryTarget
+		batchSize int
+	)
+	cmd := &cobra.Command{
+		Use:   "export-bigquery",
+		Short: "Write the SNPs and their annotations as BigQuery load jobs",
+		Long: `Write to --out-dir what loads the database into a BigQuery dataset: a
+newline-delimited JSON file per table, named like those of the SQLite
+database, with its schema (<table>.schema.json) and the jobs.insert body of
+its load job (<table>.job.json) reading it from under --gcs-prefix.
+
+load.sh uploads the files with gcloud and loads them with bq, replacing the
+tables' rows; or upload them yourself and submit the jobs through the API.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if outDir == "" {
+				return errors.New("--out-dir is required")
+			}
+			if err := target.Validate(); err != nil {
+				return err
+			}
+			if !cmd.Flags().Changed("batch-size") {
+				batchSize = opts.cfg.Export.BatchSize
+			}
+
+			db, err := opts.openDB()
+			if err != nil {
+				return err
+			}
+			defer func() {
+				_ = db.Close()
+			}()
+
+			stats, err := warehouse.Export(cmd.Context(), db, outDir, batchSize)
+			if err != nil {
+				return fmt.Errorf("export: %w", err)
+			}
+			if err := warehouse.WriteBigQuery(outDir, target); err != nil {
+				return fmt.Errorf("export: %w", err)
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Exported %d SNPs to %s; run %s there to load them into %s.%s\n",
+				stats.Rows["snps"], outDir, warehouse.BigQueryScript, target.Project, target.Dataset)
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&outDir, "out-dir", "", "directory to write the files to")
+	cmd.Flags().StringVar(&target.GCSPrefix, "gcs-prefix", "", "gs://bucket/path the files are uploaded under")
+	cmd.Flags().StringVar(&target.Project, "project", "", "Google Cloud project of the dataset")
+	cmd.Flags().StringVar(&target.Dataset, "dataset", "", "BigQuery dataset to load into")
+	cmd.Flags().IntVar(&batchSize, "batch-size", config.DefaultConfig().Export.BatchSize, "SNPs loaded per batch")
+	return cmd
+}
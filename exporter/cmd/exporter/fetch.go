@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/mkoziy/genome/exporter/internal/pipeline"
+	"github.com/mkoziy/genome/exporter/internal/ratelimit"
+	"github.com/mkoziy/genome/exporter/internal/scoring"
+	"github.com/mkoziy/genome/exporter/internal/sources/clinvar"
+)
+
+// fetchSources are the sources the fetch command can download from.
+var fetchSources = []string{"clinvar"}
+
+func newFetchCmd(opts *rootOptions) *cobra.Command {
+	var (
+		sopts       sourceOptions
+		restart     bool
+		dryRun      bool
+		incremental bool
+		bulk        bool
+	)
+	cmd := &cobra.Command{
+		Use:       "fetch [source]",
+		Short:     "Download SNPs from a source and write them to the database",
+		Args:      cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
+		ValidArgs: fetchSources,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			source := args[0]
+			newFetcher, src, err := sopts.clinvarFetchers(cmd, opts)
+			if err != nil {
+				return err
+			}
+
+			fetcher := newFetcher()
+			if dryRun {
+				if incremental {
+					if err := planSince(cmd.Context(), opts, fetcher); err != nil {
+						return err
+					}
+				}
+				plans, err := fetcher.Plan(cmd.Context())
+				if err != nil {
+					return fmt.Errorf("%s: %w", source, err)
+				}
+				writeFetchPlan(cmd.OutOrStdout(), plans, src.RateLimit)
+				return nil
+			}
+
+			db, err := opts.openDB()
+			if err != nil {
+				return err
+			}
+			defer func() {
+				_ = db.Close()
+			}()
+
+			stages := []pipeline.Stage{pipeline.ClinVarStage(fetcher, pipeline.ClinVarOptions{
+				Writer:      sopts.writer(cmd, opts),
+				Incremental: incremental,
+				Bulk:        bulk,
+				RateLimit:   src.RateLimit,
+			})}
+			if incremental {
+				// The stale-score triggers flag exactly the SNPs the delta touched.
+				stages = append(stages, pipeline.ScoringStage(scoring.New(opts.cfg.Scoring), pipeline.ScoreOptions{}))
+			}
+			stages = append(stages,
+				pipeline.FrequencyStage(opts.cfg.Export.BatchSize, []string{pipeline.StageClinVar}),
+				pipeline.QualityStage(opts.cfg.Export.BatchSize, []string{pipeline.StageClinVar}))
+			p, err := pipeline.New(db, stages...)
+			if err != nil {
+				return err
+			}
+			report, err := p.Run(cmd.Context(), pipeline.Config{
+				Restart:     restart,
+				ErrorBudget: opts.cfg.ErrorBudget,
+				PlanBudget:  opts.cfg.PlanBudget,
+			})
+			if report != nil {
+				writeRunReport(cmd.OutOrStdout(), report)
+			}
+			if err != nil {
+				return err
+			}
+			return nil
+		},
+	}
+	sopts.register(cmd)
+	cmd.Flags().BoolVar(&restart, "restart", false, "ignore the checkpoint of an interrupted run and start over")
+	cmd.Flags().BoolVar(&incremental, "incremental", false, "only fetch variants modified since the last successful fetch, then rescore them")
+	cmd.Flags().BoolVar(&bulk, "bulk", false, "load with secondary indexes dropped and fsyncs off, rebuilding the indexes afterwards; for initial builds")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "report what each query would fetch and how long it would take, writing nothing")
+	return cmd
+}
+
+// planSince limits fetcher to what an incremental fetch would download. A
+// database that has never completed a fetch is planned in full.
+func planSince(ctx context.Context, opts *rootOptions, fetcher *clinvar.Fetcher) error {
+	db, err := opts.openDB()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = db.Close()
+	}()
+
+	since, ok, err := pipeline.LastSuccess(ctx, db, pipeline.StageClinVar)
+	if err != nil {
+		return err
+	}
+	if ok {
+		fetcher.WithModifiedSince(since)
+	}
+	return nil
+}
+
+// writeFetchPlan prints each query's variant count and the time the rate limit
+// alone would take to make every request, a lower bound for the real run.
+func writeFetchPlan(w io.Writer, plans []clinvar.QueryPlan, limits ratelimit.Config) {
+	var variants, requests int
+	for _, plan := range plans {
+		fmt.Fprintf(w, "%8d variants  %6d requests  %s\n", plan.Count, plan.Requests, plan.Query)
+		variants += plan.Count
+		requests += plan.Requests
+	}
+	fmt.Fprintf(w, "%8d variants  %6d requests  total, at least %s at %s\n",
+		variants, requests, limits.EstimateDuration(requests).Round(time.Second), describeLimits(limits))
+}
+
+func describeLimits(limits ratelimit.Config) string {
+	if limits.Strategy == ratelimit.StrategyFixedDelay {
+		return fmt.Sprintf("one request per %s", limits.FixedDelay)
+	}
+	return fmt.Sprintf("%g requests/s", limits.RequestsPerSec)
+}
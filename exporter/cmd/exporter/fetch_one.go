@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/mkoziy/genome/exporter/internal/models"
+	"github.com/mkoziy/genome/exporter/internal/repositories"
+	"github.com/mkoziy/genome/exporter/internal/sources"
+)
+
+// fetchedRecord is a record as fetch-one prints it: the mapped SNP with its
+// related rows attached, in the shape query prints stored SNPs.
+type fetchedRecord struct {
+	Source models.DataSource `json:"source"`
+	SNP    *models.SNP       `json:"snp"`
+	// Invalid is why the writer would quarantine the record.
+	Invalid string `json:"invalid,omitempty"`
+	Raw     any    `json:"raw,omitempty"`
+}
+
+func newFetchOneCmd(opts *rootOptions) *cobra.Command {
+	var (
+		sopts sourceOptions
+		write bool
+		raw   bool
+	)
+	cmd := &cobra.Command{
+		Use:   "fetch-one rsID",
+		Short: "Download a single SNP and print the mapped records as JSON",
+		Long: `Download a single SNP from ClinVar and from every enrichment source of the
+build profile that can look up single variants, and print each record as it
+would be written. Records failing validation say why. With --write the records
+are upserted, or quarantined, as a full fetch would; the database is not
+touched otherwise.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			rsID, err := models.NormalizeRsID(args[0])
+			if err != nil {
+				return err
+			}
+			newFetcher, _, err := sopts.clinvarFetchers(cmd, opts)
+			if err != nil {
+				return err
+			}
+			newPlugins, err := sopts.pluginSources(cmd, opts)
+			if err != nil {
+				return err
+			}
+			prof, err := sopts.buildProfile(cmd, opts)
+			if err != nil {
+				return err
+			}
+
+			data, failures, err := newFetcher().FetchRsID(ctx, rsID)
+			if err != nil {
+				return fmt.Errorf("clinvar: %w", err)
+			}
+			for _, fail := range failures {
+				fmt.Fprintf(os.Stderr, "clinvar: %s: %v\n", fail.Accession, fail.Err)
+			}
+			plugins, err := newPlugins()
+			if err != nil {
+				return err
+			}
+			for _, plugin := range plugins {
+				vf, ok := plugin.(sources.VariantFetcher)
+				if !ok || !prof.Includes(plugin.Name()) {
+					continue
+				}
+				more, err := vf.FetchRsID(ctx, rsID)
+				if err != nil {
+					return fmt.Errorf("%s: %w", plugin.Name(), err)
+				}
+				data = append(data, more...)
+			}
+			if len(data) == 0 {
+				fmt.Fprintf(os.Stderr, "%s: not found\n", rsID)
+				return exitCode(1)
+			}
+
+			enc := json.NewEncoder(cmd.OutOrStdout())
+			enc.SetIndent("", "  ")
+			for _, d := range data {
+				if err := enc.Encode(newFetchedRecord(d, raw)); err != nil {
+					return err
+				}
+			}
+			if !write {
+				return nil
+			}
+			return writeFetched(ctx, opts, sopts.writer(cmd, opts), data)
+		},
+	}
+	sopts.register(cmd)
+	cmd.Flags().BoolVar(&write, "write", false, "upsert the fetched records into the database")
+	cmd.Flags().BoolVar(&raw, "raw", false, "include the source record each SNP was mapped from")
+	return cmd
+}
+
+// newFetchedRecord attaches the rows of d to a copy of its SNP, leaving d as
+// the writer expects it.
+func newFetchedRecord(d models.SNPData, raw bool) fetchedRecord {
+	rec := fetchedRecord{Source: d.Source}
+	if err := d.Validate(); err != nil {
+		rec.Invalid = err.Error()
+	}
+	if raw {
+		rec.Raw = d.Raw
+	}
+	if d.SNP == nil {
+		return rec
+	}
+	snp := *d.SNP
+	for i := range d.Clinical {
+		snp.ClinicalData = append(snp.ClinicalData, &d.Clinical[i])
+	}
+	for i := range d.References {
+		snp.References = append(snp.References, &d.References[i])
+	}
+	for i := range d.Phenotypes {
+		snp.Phenotypes = append(snp.Phenotypes, &d.Phenotypes[i])
+	}
+	for i := range d.PopulationData {
+		snp.PopulationData = append(snp.PopulationData, &d.PopulationData[i])
+	}
+	for i := range d.RiskAlleles {
+		snp.RiskAlleles = append(snp.RiskAlleles, &d.RiskAlleles[i])
+	}
+	for i := range d.GenotypeEffects {
+		snp.GenotypeEffects = append(snp.GenotypeEffects, &d.GenotypeEffects[i])
+	}
+	for i := range d.Agreements {
+		snp.ClinicalAgreements = append(snp.ClinicalAgreements, &d.Agreements[i])
+	}
+	rec.SNP = &snp
+	return rec
+}
+
+// writeFetched upserts data through the batch writer, so invalid records are
+// quarantined as in a full fetch.
+func writeFetched(ctx context.Context, opts *rootOptions, cfg repositories.BatchWriterConfig, data []models.SNPData) error {
+	db, err := opts.openDB()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = db.Close()
+	}()
+
+	in := make(chan models.SNPData, len(data))
+	for _, d := range data {
+		in <- d
+	}
+	close(in)
+	stats, err := repositories.NewBatchWriter(db, cfg).Run(ctx, in)
+	if err != nil {
+		return fmt.Errorf("write: %w", err)
+	}
+	fmt.Fprintf(os.Stderr, "Wrote %d SNPs, %d quarantined\n", stats.SNPs, stats.Quarantined)
+	return nil
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/mkoziy/genome/exporter/internal/config"
+	"github.com/mkoziy/genome/exporter/internal/models"
+	"github.com/mkoziy/genome/exporter/internal/pipeline"
+)
+
+func newFrequenciesCmd(opts *rootOptions) *cobra.Command {
+	var batchSize int
+	cmd := &cobra.Command{
+		Use:   "frequencies",
+		Short: "Reaggregate the canonical minor allele frequencies of every SNP",
+		Long: `Reaggregate the population frequencies every source reports for each SNP
+into one global minor allele frequency, the MAF of each superpopulation (AFR,
+AMR, EAS, EUR and SAS) and the highest and lowest of those. Each comes from
+the first of gnomAD, 1000 Genomes and ALFA to report it, other sources after
+them. The genotypes each population counts are tested against Hardy–Weinberg
+equilibrium too: populations deviating from it with p < 1e-6 point to
+genotyping errors. Runs do this once the sources have loaded; this command is
+for after editing the database by hand.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if !cmd.Flags().Changed("batch-size") {
+				batchSize = opts.cfg.Export.BatchSize
+			}
+			db, err := opts.openDB()
+			if err != nil {
+				return err
+			}
+			defer func() {
+				_ = db.Close()
+			}()
+
+			aggregated, err := pipeline.AggregateFrequencies(cmd.Context(), db, batchSize)
+			if err != nil {
+				return fmt.Errorf("frequencies: %w", err)
+			}
+			known, err := db.NewSelect().Model((*models.Frequency)(nil)).Where("global_maf IS NOT NULL").Count(cmd.Context())
+			if err != nil {
+				return err
+			}
+			deviating, err := db.NewSelect().Model((*models.HardyWeinberg)(nil)).Where("p_value < ?", models.HWEDeviationPValue).Count(cmd.Context())
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Aggregated %d SNPs, %d with a global MAF, %d populations out of Hardy–Weinberg equilibrium\n", aggregated, known, deviating)
+			return nil
+		},
+	}
+	cmd.Flags().IntVar(&batchSize, "batch-size", config.DefaultConfig().Export.BatchSize, "SNPs loaded per batch")
+	return cmd
+}
@@ -0,0 +1,314 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/mkoziy/genome/exporter/internal/config"
+	"github.com/mkoziy/genome/exporter/internal/models"
+	"github.com/mkoziy/genome/exporter/internal/repositories"
+	"github.com/mkoziy/genome/exporter/internal/translate"
+	"github.com/mkoziy/genome/exporter/internal/verify"
+)
+
+func newGlossaryCmd(opts *rootOptions) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "glossary",
+		Short: "Maintain the terms whose translations are fixed",
+		Long: `The glossary fixes the translation of terms into each language: clinical
+terms such as "pathogenic" with their one approved translation, and gene
+symbols kept as they are. Machine translation leaves the terms to the
+glossary, imported translations lacking them stay machine drafts, and
+"translations check" flags the translations that lack them.`,
+	}
+	cmd.AddCommand(newGlossaryListCmd(opts), newGlossarySetCmd(opts), newGlossaryRemoveCmd(opts), newGlossaryLoadCmd(opts))
+	return cmd
+}
+
+func newGlossaryListCmd(opts *rootOptions) *cobra.Command {
+	var (
+		lang   string
+		asJSON bool
+	)
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List the glossary of a language, or of all of them",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			db, err := opts.openDB()
+			if err != nil {
+				return err
+			}
+			defer func() {
+				_ = db.Close()
+			}()
+			terms, err := repositories.ListGlossaryTerms(cmd.Context(), db, lang)
+			if err != nil {
+				return fmt.Errorf("list glossary: %w", err)
+			}
+			if asJSON {
+				enc := json.NewEncoder(cmd.OutOrStdout())
+				enc.SetIndent("", "  ")
+				return enc.Encode(terms)
+			}
+			w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "LANGUAGE\tTERM\tTRANSLATION\tCASE")
+			for _, t := range terms {
+				matching := "any"
+				if t.CaseSensitive {
+					matching = "exact"
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", t.LanguageCode, t.Term, t.Translation, matching)
+			}
+			return w.Flush()
+		},
+	}
+	cmd.Flags().StringVar(&lang, "lang", "", "language code of the glossary (all by default)")
+	cmd.Flags().BoolVar(&asJSON, "json", false, "write the terms as JSON")
+	return cmd
+}
+
+func newGlossarySetCmd(opts *rootOptions) *cobra.Command {
+	var (
+		lang          string
+		caseSensitive bool
+		note          string
+	)
+	cmd := &cobra.Command{
+		Use:   "set TERM TRANSLATION",
+		Short: "Fix the translation of a term",
+		Long: `Fix the translation of TERM into --lang, replacing the one fixed before.
+Terms match as whole words in any case unless --case-sensitive, as gene
+symbols should be.`,
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if lang == "" {
+				return errors.New("--lang is required")
+			}
+			term := &models.GlossaryTerm{Term: args[0], LanguageCode: lang, Translation: args[1], CaseSensitive: caseSensitive}
+			if note != "" {
+				term.Note = &note
+			}
+			db, err := opts.openDB()
+			if err != nil {
+				return err
+			}
+			defer func() {
+				_ = db.Close()
+			}()
+			if err := repositories.SaveGlossaryTerms(cmd.Context(), db, []*models.GlossaryTerm{term}); err != nil {
+				return fmt.Errorf("set glossary term: %w", err)
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "%q is translated into %s as %q\n", term.Term, lang, term.Translation)
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&lang, "lang", "", "language code of the translation")
+	cmd.Flags().BoolVar(&caseSensitive, "case-sensitive", false, "match the term and its translation only as written")
+	cmd.Flags().StringVar(&note, "note", "", "why the translation is fixed, for translators")
+	return cmd
+}
+
+func newGlossaryRemoveCmd(opts *rootOptions) *cobra.Command {
+	var lang string
+	cmd := &cobra.Command{
+		Use:   "remove TERM",
+		Short: "Remove a term from the glossary of a language",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if lang == "" {
+				return errors.New("--lang is required")
+			}
+			db, err := opts.openDB()
+			if err != nil {
+				return err
+			}
+			defer func() {
+				_ = db.Close()
+			}()
+			ok, err := repositories.DeleteGlossaryTerm(cmd.Context(), db, args[0], lang)
+			if err != nil {
+				return fmt.Errorf("remove glossary term: %w", err)
+			}
+			if !ok {
+				return fmt.Errorf("%q is not in the %s glossary", args[0], lang)
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Removed %q from the %s glossary\n", args[0], lang)
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&lang, "lang", "", "language code of the glossary")
+	return cmd
+}
+
+// glossaryColumns are the columns of a glossary CSV file; the last two are
+// optional.
+var glossaryColumns = []string{"term", "language", "translation", "case_sensitive", "note"}
+
+func newGlossaryLoadCmd(opts *rootOptions) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "load FILE",
+		Short: "Load glossary terms from a CSV file",
+		Long: `Load the terms of FILE, a CSV file with a header row naming the columns
+term, language and translation, and optionally case_sensitive (true or
+false) and note. Terms already in the glossary are replaced.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			f, err := os.Open(args[0])
+			if err != nil {
+				return err
+			}
+			terms, err := readGlossaryCSV(f)
+			_ = f.Close()
+			if err != nil {
+				return fmt.Errorf("read %s: %w", args[0], err)
+			}
+			db, err := opts.openDB()
+			if err != nil {
+				return err
+			}
+			defer func() {
+				_ = db.Close()
+			}()
+			if err := repositories.SaveGlossaryTerms(cmd.Context(), db, terms); err != nil {
+				return fmt.Errorf("load glossary: %w", err)
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Loaded %d glossary terms\n", len(terms))
+			return nil
+		},
+	}
+	return cmd
+}
+
+func readGlossaryCSV(r io.Reader) ([]*models.GlossaryTerm, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	header, err := cr.Read()
+	if err != nil {
+		return nil, err
+	}
+	index := make(map[string]int)
+	for i, name := range header {
+		index[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range glossaryColumns[:3] {
+		if _, ok := index[name]; !ok {
+			return nil, fmt.Errorf("missing column %q", name)
+		}
+	}
+	var terms []*models.GlossaryTerm
+	for line := 2; ; line++ {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			return terms, nil
+		} else if err != nil {
+			return nil, err
+		}
+		field := func(name string) string {
+			if i, ok := index[name]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		term := &models.GlossaryTerm{Term: field("term"), LanguageCode: field("language"), Translation: field("translation")}
+		if v := field("case_sensitive"); v != "" {
+			if term.CaseSensitive, err = strconv.ParseBool(v); err != nil {
+				return nil, fmt.Errorf("line %d: invalid case_sensitive %q", line, v)
+			}
+		}
+		if note := field("note"); note != "" {
+			term.Note = &note
+		}
+		if err := term.Validate(); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		terms = append(terms, term)
+	}
+}
+
+func newTranslationsCheckCmd(opts *rootOptions) *cobra.Command {
+	var (
+		lang      string
+		asJSON    bool
+		batchSize int
+	)
+	cmd := &cobra.Command{
+		Use:   "check",
+		Short: "Flag translations that deviate from the glossary",
+		Long: `Check the translations into --lang, or into every language with a
+glossary, and list those of text containing a glossary term that lack its
+fixed translation. Exits 1 if any are found, so a release can be held back
+until they are fixed.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if !cmd.Flags().Changed("batch-size") {
+				batchSize = opts.cfg.Export.BatchSize
+			}
+			db, err := opts.openDB()
+			if err != nil {
+				return err
+			}
+			defer func() {
+				_ = db.Close()
+			}()
+			ctx := cmd.Context()
+
+			langs := []string{lang}
+			if lang == "" {
+				terms, err := repositories.ListGlossaryTerms(ctx, db, "")
+				if err != nil {
+					return fmt.Errorf("list glossary: %w", err)
+				}
+				langs = nil
+				for _, t := range terms {
+					if len(langs) == 0 || langs[len(langs)-1] != t.LanguageCode {
+						langs = append(langs, t.LanguageCode)
+					}
+				}
+			}
+			found := make(map[string][]translate.Violation)
+			total := 0
+			for _, l := range langs {
+				violations, err := translate.CheckTranslations(ctx, db, l, batchSize)
+				if err != nil {
+					return fmt.Errorf("check %s translations: %w", l, err)
+				}
+				found[l] = violations
+				total += len(violations)
+			}
+
+			w := cmd.OutOrStdout()
+			if asJSON {
+				enc := json.NewEncoder(w)
+				enc.SetIndent("", "  ")
+				if err := enc.Encode(found); err != nil {
+					return err
+				}
+			} else {
+				for _, l := range langs {
+					for _, v := range found[l] {
+						fmt.Fprintf(w, "%s %s (%s): %q must be translated as %q: %s\n", l, v.Key, v.Status, v.Term, v.Translation, v.Target)
+					}
+				}
+				fmt.Fprintf(w, "%d translations deviate from the glossary in %d languages\n", total, len(langs))
+			}
+			if total > 0 {
+				return exitCode(verify.ExitIssues)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&lang, "lang", "", "language code to check (every language with a glossary by default)")
+	cmd.Flags().BoolVar(&asJSON, "json", false, "write the violations by language as JSON")
+	cmd.Flags().IntVar(&batchSize, "batch-size", config.DefaultConfig().Export.BatchSize, "SNPs loaded per batch")
+	return cmd
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/mkoziy/genome/exporter/internal/haplotype"
+	"github.com/mkoziy/genome/exporter/internal/repositories"
+)
+
+func newHaplotypesCmd(opts *rootOptions) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "haplotypes",
+		Short: "Maintain the haplotypes whose member SNPs are read together",
+		Long: `Haplotypes are named alleles of genes that single SNPs cannot represent:
+the APOE ε alleles, HLA alleles typed by tag SNPs and pharmacogene star
+alleles, each requiring one allele of every member SNP. Members name their
+SNPs by rsID, so haplotypes can be loaded before or after the SNPs are.`,
+	}
+	cmd.AddCommand(newHaplotypesListCmd(opts), newHaplotypesLoadCmd(opts))
+	return cmd
+}
+
+func newHaplotypesListCmd(opts *rootOptions) *cobra.Command {
+	var (
+		gene   string
+		asJSON bool
+	)
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List the haplotypes of a gene, or of all of them",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			db, err := opts.openDB()
+			if err != nil {
+				return err
+			}
+			defer func() {
+				_ = db.Close()
+			}()
+			haplotypes, err := repositories.ListHaplotypes(cmd.Context(), db, gene)
+			if err != nil {
+				return fmt.Errorf("list haplotypes: %w", err)
+			}
+			if asJSON {
+				enc := json.NewEncoder(cmd.OutOrStdout())
+				enc.SetIndent("", "  ")
+				return enc.Encode(haplotypes)
+			}
+			w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "HAPLOTYPE\tKIND\tALLELES\tFUNCTION")
+			for _, h := range haplotypes {
+				alleles := make([]string, len(h.SNPs))
+				for i, m := range h.SNPs {
+					alleles[i] = m.RsID + ":" + m.Allele
+				}
+				function := "-"
+				if h.Function != nil {
+					function = *h.Function
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", h.FullName(), h.Kind, strings.Join(alleles, " "), function)
+			}
+			return w.Flush()
+		},
+	}
+	cmd.Flags().StringVar(&gene, "gene", "", "gene symbol of the haplotypes (all by default)")
+	cmd.Flags().BoolVar(&asJSON, "json", false, "write the haplotypes as JSON")
+	return cmd
+}
+
+func newHaplotypesLoadCmd(opts *rootOptions) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "load",
+		Short: "Load the built-in haplotypes",
+		Long: `Load the haplotypes known out of the box: APOE ε2, ε3 and ε4, the HLA
+alleles with established tag SNPs and the star alleles of the pharmacogenes
+reports call. Haplotypes already defined are replaced.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			db, err := opts.openDB()
+			if err != nil {
+				return err
+			}
+			defer func() {
+				_ = db.Close()
+			}()
+			haplotypes := haplotype.Builtin()
+			if err := repositories.SaveHaplotypes(cmd.Context(), db, haplotypes); err != nil {
+				return fmt.Errorf("load haplotypes: %w", err)
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Loaded %d haplotypes\n", len(haplotypes))
+			return nil
+		},
+	}
+	return cmd
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/mkoziy/genome/exporter/internal/liftover"
+	"github.com/mkoziy/genome/exporter/internal/repositories"
+	"github.com/mkoziy/genome/exporter/internal/variant"
+)
+
+// liftedLocus is one liftover input converted, with the SNPs at the GRCh38
+// position.
+type liftedLocus struct {
+	Query string `json:"query"`
+	liftover.Locus
+	Assembly string   `json:"assembly"`
+	RsIDs    []string `json:"rsids,omitempty"`
+}
+
+func newLiftoverCmd(opts *rootOptions) *cobra.Command {
+	var from string
+	cmd := &cobra.Command{
+		Use:   "liftover CHROM:POS...",
+		Short: "Convert positions between GRCh37 and GRCh38 as JSON",
+		Long: `Convert positions such as "chr19:45411941" from one assembly to the other with
+the chain file liftover.chain_file configures, and print each as JSON with the
+rsIDs of the SNPs in the database at its GRCh38 position. Positions are
+1-based; ones in sequence the other assembly lacks are reported as unmapped.`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if from = liftover.NormalizeAssembly(from); from == "" {
+				return errors.New("unknown --from (want GRCh37 or GRCh38)")
+			}
+			if opts.cfg.Liftover.ChainFile == "" {
+				return errors.New("liftover.chain_file is not configured")
+			}
+			chain, err := liftover.Open(opts.cfg.Liftover.ChainFile, liftover.GRCh37, liftover.GRCh38)
+			if err != nil {
+				return fmt.Errorf("open chain file: %w", err)
+			}
+			if from == liftover.GRCh38 {
+				chain = chain.Invert()
+			}
+
+			db, err := opts.openDB()
+			if err != nil {
+				return err
+			}
+			defer func() {
+				_ = db.Close()
+			}()
+
+			ctx := cmd.Context()
+			enc := json.NewEncoder(cmd.OutOrStdout())
+			enc.SetIndent("", "  ")
+			var unmapped int
+			for _, arg := range args {
+				chrom, pos, err := parseLocus(arg)
+				if err != nil {
+					return err
+				}
+				locus, ok := chain.Map(chrom, pos)
+				if !ok {
+					fmt.Fprintf(os.Stderr, "%s: unmapped on %s\n", arg, chain.To)
+					unmapped++
+					continue
+				}
+				// The database's positions are on GRCh38: the input's when
+				// converting from it.
+				chrom38, pos38 := locus.Chromosome, locus.Position
+				if from == liftover.GRCh38 {
+					chrom38, pos38 = variant.NormalizeChromosome(chrom), pos
+				}
+				snps, err := repositories.GetSNPsInRegion(ctx, db, chrom38, pos38, pos38, repositories.SignificanceFilter{})
+				if err != nil {
+					return fmt.Errorf("look up %s: %w", arg, err)
+				}
+				out := liftedLocus{Query: arg, Locus: locus, Assembly: chain.To}
+				for _, snp := range snps {
+					out.RsIDs = append(out.RsIDs, snp.RsID)
+				}
+				if err := enc.Encode(out); err != nil {
+					return err
+				}
+			}
+
+			if unmapped > 0 {
+				return exitCode(1)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&from, "from", liftover.GRCh37, "assembly of the positions given, GRCh37 or GRCh38")
+	return cmd
+}
+
+// parseLocus parses a 1-based position written CHROM:POS, such as
+// "chr19:45411941", allowing thousands separators in the position.
+func parseLocus(s string) (string, int64, error) {
+	chrom, pos, ok := strings.Cut(s, ":")
+	n, err := strconv.ParseInt(strings.ReplaceAll(pos, ",", ""), 10, 64)
+	if !ok || chrom == "" || err != nil || n <= 0 {
+		return "", 0, fmt.Errorf("%q is not a CHROM:POS position", s)
+	}
+	return chrom, n, nil
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/uptrace/bun"
+
+	"github.com/mkoziy/genome/exporter/internal/config"
+	"github.com/mkoziy/genome/exporter/internal/database"
+	"github.com/mkoziy/genome/exporter/internal/httpcache"
+	"github.com/mkoziy/genome/exporter/internal/logging"
+	"github.com/mkoziy/genome/exporter/internal/notify"
+	"github.com/mkoziy/genome/exporter/internal/pipeline"
+	"github.com/mkoziy/genome/exporter/internal/progress"
+	"github.com/mkoziy/genome/exporter/internal/tracing"
+)
+
+// exitCode carries a specific process exit status out of a command, for
+// commands such as verify whose status means more than success or failure.
+type exitCode int
+
+func (c exitCode) Error() string {
+	return fmt.Sprintf("exit status %d", int(c))
+}
+
+// rootOptions holds the flags shared by every command and the configuration
+// they override.
+type rootOptions struct {
+	configPath string
+	cfg        config.Config
+	progress   string
+
+	dsn             string
+	debug           bool
+	httpCache       string
+	httpCacheMaxAge time.Duration
+
+	// shutdownTracing flushes the spans of the command once it returns.
+	shutdownTracing func(context.Context) error
+}
+
+// loadConfig loads --config and applies the root flags that were set on top,
+// then sets up logging and tracing as the config selects.
+func (o *rootOptions) loadConfig(cmd *cobra.Command) error {
+	cfg, err := config.Load(o.configPath, os.Getenv)
+	if err != nil {
+		return err
+	}
+	flags := cmd.Flags()
+	if flags.Changed("db") {
+		cfg.Database.DSN = o.dsn
+	}
+	if flags.Changed("debug") {
+		cfg.Database.Debug = o.debug
+	}
+	if flags.Changed("http-cache") {
+		cfg.HTTPCache.Dir = o.httpCache
+	}
+	if flags.Changed("http-cache-max-age") {
+		cfg.HTTPCache.MaxAge = o.httpCacheMaxAge
+	}
+	o.cfg = cfg
+	if err := logging.Setup(os.Stderr, cfg.Log); err != nil {
+		return err
+	}
+	shutdown, err := tracing.Setup(cmd.Context(), cfg.Tracing)
+	if err != nil {
+		return err
+	}
+	o.shutdownTracing = shutdown
+	return nil
+}
+
+// flushTracing exports the spans still buffered, giving up after a few
+// seconds so an unreachable collector does not hold up the exit.
+func (o *rootOptions) flushTracing() {
+	if o.shutdownTracing == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := o.shutdownTracing(ctx); err != nil {
+		slog.Error("Exporting traces failed", "error", err)
+	}
+}
+
+func (o *rootOptions) openDB() (*bun.DB, error) {
+	db, err := database.NewDB(o.cfg.Database.DSN, o.cfg.Database.Debug)
+	if err != nil {
+		return nil, fmt.Errorf("open database: %w", err)
+	}
+	return db, nil
+}
+
+// httpTransport returns the transport source clients share: an on-disk
+// response cache when one is configured, otherwise nil for the default.
+func (o *rootOptions) httpTransport() (http.RoundTripper, error) {
+	if o.cfg.HTTPCache.Dir == "" {
+		return nil, nil
+	}
+	cache, err := httpcache.New(o.cfg.HTTPCache.Dir, o.cfg.HTTPCache.MaxAge)
+	if err != nil {
+		return nil, fmt.Errorf("open http cache: %w", err)
+	}
+	return cache, nil
+}
+
+// notifyRun sends the configured notifications about a finished run, even
+// when the run was interrupted. A notification that cannot be delivered is
+// logged rather than failing the command.
+func (o *rootOptions) notifyRun(ctx context.Context, job string, report *pipeline.Report, runErr error) {
+	n := notify.New(o.cfg.Notifications)
+	if !n.Enabled() {
+		return
+	}
+	if err := n.Notify(context.WithoutCancel(ctx), notify.NewSummary(job, report, runErr)); err != nil {
+		slog.ErrorContext(ctx, "Sending notifications failed", "error", err)
+	}
+}
+
+// progressReporter returns the reporter selected by --progress, writing to stderr
+// so it never mixes with command output.
+func (o *rootOptions) progressReporter() (progress.Reporter, error) {
+	switch o.progress {
+	case "text":
+		return progress.NewTerminal(os.Stderr), nil
+	case "json":
+		return progress.NewJSON(os.Stderr), nil
+	case "none":
+		return progress.Discard, nil
+	}
+	return nil, fmt.Errorf("unknown --progress %q (want text, json or none)", o.progress)
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		// Restore the default handlers so a second signal kills the process.
+		stop()
+		fmt.Fprintln(os.Stderr, "exporter: shutting down, committing in-flight batches (signal again to abort)")
+	}()
+	os.Exit(execute(ctx, os.Args[1:]))
+}
+
+func execute(ctx context.Context, args []string) int {
+	root, opts := newRootCmd()
+	root.SetArgs(args)
+
+	err := root.ExecuteContext(ctx)
+	opts.flushTracing()
+	var code exitCode
+	switch {
+	case err == nil:
+		return 0
+	case errors.As(err, &code):
+		return int(code)
+	case ctx.Err() != nil && errors.Is(err, context.Canceled):
+		fmt.Fprintln(os.Stderr, "exporter: interrupted")
+		return 130
+	default:
+		fmt.Fprintf(os.Stderr, "exporter: %v\n", err)
+		return 1
+	}
+}
+
+func newRootCmd() (*cobra.Command, *rootOptions) {
+	opts := &rootOptions{}
+	def := config.DefaultConfig()
+	root := &cobra.Command{
+		Use:           "exporter",
+		Short:         "Build and maintain the SNP significance database",
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			return opts.loadConfig(cmd)
+		},
+	}
+	root.PersistentFlags().StringVar(&opts.configPath, "config", os.Getenv("EXPORTER_CONFIG"), "YAML config file; flags override its settings")
+	root.PersistentFlags().StringVar(&opts.dsn, "db", def.Database.DSN, "SQLite database path or DSN")
+	root.PersistentFlags().BoolVar(&opts.debug, "debug", false, "log every SQL query")
+	root.PersistentFlags().StringVar(&opts.progress, "progress", "text", "progress output on stderr: text, json or none")
+	root.PersistentFlags().StringVar(&opts.httpCache, "http-cache", "", "directory caching source API responses across runs (disabled when empty)")
+	root.PersistentFlags().DurationVar(&opts.httpCacheMaxAge, "http-cache-max-age", def.HTTPCache.MaxAge, "serve cached responses this recent without revalidating")
+
+	root.AddCommand(
+		newMigrateCmd(opts),
+		newFetchCmd(opts),
+		newFetchOneCmd(opts),
+		newRunCmd(opts),
+		newServeCmd(opts),
+		newRetryFailedCmd(opts),
+		newScoreCmd(opts),
+		newQualityCmd(opts),
+		newFrequenciesCmd(opts),
+		newExportCmd(opts),
+		newExportMobileCmd(opts),
+		newExportSearchCmd(opts),
+		newExportDuckDBCmd(opts),
+		newExportBigQueryCmd(opts),
+		newAttributionCmd(opts),
+		newSchemaCmd(opts),
+		newChangesCmd(opts),
+		newStatusCmd(opts),
+		newStatsCmd(opts),
+		newQueryCmd(opts),
+		newLiftoverCmd(opts),
+		newHaplotypesCmd(opts),
+		newConditionsCmd(opts),
+		newAnnotateCmd(opts),
+		newTranslationsCmd(opts),
+		newTranslateCmd(opts),
+		newReportCmd(opts),
+		newPRSCmd(opts),
+		newBackupCmd(opts),
+		newDedupeCmd(opts),
+		newCurateCmd(opts),
+		newVerifyCmd(opts),
+		newBundleCmd(),
+		newVerifyBundleCmd(),
+		newDiffCmd(opts),
+	)
+	return root, opts
+}
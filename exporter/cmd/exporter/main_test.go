@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/uptrace/bun"
+
+	"github.com/mkoziy/genome/exporter/internal/database"
+	"github.com/mkoziy/genome/exporter/internal/migrations"
+	"github.com/mkoziy/genome/exporter/internal/models"
+	"github.com/mkoziy/genome/exporter/internal/verify"
+)
+
+// testDSN names an in-memory database shared by every connection of the
+// test, so commands opening their own see what the test wrote.
+func testDSN(t *testing.T) string {
+	return "file:" + t.Name() + "?mode=memory&cache=shared"
+}
+
+func newTestDB(t *testing.T) *bun.DB {
+	t.Helper()
+	db, err := database.NewDB(testDSN(t), false)
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	if err := migrations.RunMigrations(context.Background(), db); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	return db
+}
+
+// run executes the exporter with args against dsn, ignoring any config the
+// environment points at.
+func run(ctx context.Context, dsn string, args ...string) int {
+	return execute(ctx, append([]string{"--config=", "--db", dsn, "--progress", "none"}, args...))
+}
+
+func TestExecuteExitCodes(t *testing.T) {
+	dsn := testDSN(t)
+	newTestDB(t)
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		args []string
+		want int
+	}{
+		{"success", context.Background(), []string{"migrate"}, 0},
+		{"unknown command", context.Background(), []string{"nonsense"}, 1},
+		{"interrupted", cancelled, []string{"query", "rs1"}, 130},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := run(tt.ctx, dsn, tt.args...); got != tt.want {
+				t.Fatalf("expected exit code %d, got %d", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestExitCodeCarriesStatus(t *testing.T) {
+	err := fmt.Errorf("verify: %w", exitCode(verify.ExitIssues))
+	var code exitCode
+	if !errors.As(err, &code) || int(code) != verify.ExitIssues {
+		t.Fatalf("expected exit code %d from %v, got %d", verify.ExitIssues, err, code)
+	}
+	if err.Error() != "verify: exit status 1" {
+		t.Fatalf("unexpected message %q", err.Error())
+	}
+}
+
+func TestQueryExitCodes(t *testing.T) {
+	ctx := context.Background()
+	dsn := testDSN(t)
+	db := newTestDB(t)
+	snp := &models.SNP{RsID: "rs429358", Chromosome: "19", Position: 44908684, ReferenceAllele: "C", AlternateAlleles: models.StringArray{"T"}, VariantType: models.VariantSNV}
+	if _, err := db.NewInsert().Model(snp).Exec(ctx); err != nil {
+		t.Fatalf("insert snp: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		args []string
+		want int
+	}{
+		{"found", []string{"rs429358"}, 0},
+		{"not found", []string{"rs1"}, 1},
+		{"some not found", []string{"rs429358", "rs1"}, 1},
+		{"not an rsID", []string{"foo"}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := run(ctx, dsn, append([]string{"query"}, tt.args...)...); got != tt.want {
+				t.Fatalf("expected exit code %d, got %d", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestVerifyExitCodes(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("clean", func(t *testing.T) {
+		newTestDB(t)
+		if got := run(ctx, testDSN(t), "verify"); got != verify.ExitOK {
+			t.Fatalf("expected exit code %d, got %d", verify.ExitOK, got)
+		}
+	})
+
+	t.Run("issues", func(t *testing.T) {
+		db := newTestDB(t)
+		if _, err := db.ExecContext(ctx, `INSERT INTO snp_clinical (snp_id, clinical_significance, review_status, condition_name, source)
+			VALUES (999, 'pathogenic', 'reviewed_by_expert_panel', 'X', 'clinvar')`); err != nil {
+			t.Fatalf("insert clinical: %v", err)
+		}
+		if got := run(ctx, testDSN(t), "verify"); got != verify.ExitIssues {
+			t.Fatalf("expected exit code %d, got %d", verify.ExitIssues, got)
+		}
+	})
+
+	t.Run("error", func(t *testing.T) {
+		dsn := filepath.Join(t.TempDir(), "missing", "snps.db")
+		if got := run(ctx, dsn, "verify"); got != verify.ExitError {
+			t.Fatalf("expected exit code %d, got %d", verify.ExitError, got)
+		}
+	})
+}
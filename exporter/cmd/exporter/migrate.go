@@ -0,0 +1,31 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/mkoziy/genome/exporter/internal/migrations"
+)
+
+func newMigrateCmd(opts *rootOptions) *cobra.Command {
+	return &cobra.Command{
+		Use:   "migrate",
+		Short: "Create the schema or apply pending migrations",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			db, err := opts.openDB()
+			if err != nil {
+				return err
+			}
+			defer func() {
+				_ = db.Close()
+			}()
+
+			if err := migrations.RunMigrations(cmd.Context(), db); err != nil {
+				return fmt.Errorf("migrate: %w", err)
+			}
+			return nil
+		},
+	}
+}
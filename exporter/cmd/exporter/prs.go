@@ -0,0 +1,231 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/mkoziy/genome/exporter/internal/genotype"
+	"github.com/mkoziy/genome/exporter/internal/models"
+	"github.com/mkoziy/genome/exporter/internal/prs"
+	"github.com/mkoziy/genome/exporter/internal/repositories"
+)
+
+func newPRSCmd(opts *rootOptions) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "prs",
+		Short: "Import polygenic risk score models and score genotype files",
+	}
+	cmd.AddCommand(
+		newPRSImportCmd(opts),
+		newPRSListCmd(opts),
+		newPRSReferenceCmd(opts),
+		newPRSScoreCmd(opts),
+	)
+	return cmd
+}
+
+func newPRSImportCmd(opts *rootOptions) *cobra.Command {
+	return &cobra.Command{
+		Use:   "import FILE...",
+		Short: "Import PGS Catalog scoring files, replacing models imported before",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			db, err := opts.openDB()
+			if err != nil {
+				return err
+			}
+			defer func() {
+				_ = db.Close()
+			}()
+
+			for _, path := range args {
+				def, err := readPGSFile(path)
+				if err != nil {
+					return err
+				}
+				if err := repositories.SavePRSModel(cmd.Context(), db, def.Model, def.Weights); err != nil {
+					return fmt.Errorf("save %s: %w", def.Model.PGSID, err)
+				}
+				fmt.Fprintf(cmd.OutOrStdout(), "Imported %s (%s): %d variants, %d skipped\n",
+					def.Model.PGSID, def.Model.TraitReported, len(def.Weights), def.Skipped)
+			}
+			return nil
+		},
+	}
+}
+
+func readPGSFile(path string) (*prs.Definition, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	def, err := prs.ParsePGSCatalog(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return def, nil
+}
+
+func newPRSListCmd(opts *rootOptions) *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "List imported models and their reference populations",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			db, err := opts.openDB()
+			if err != nil {
+				return err
+			}
+			defer func() {
+				_ = db.Close()
+			}()
+
+			list, err := repositories.ListPRSModels(cmd.Context(), db)
+			if err != nil {
+				return err
+			}
+			w := cmd.OutOrStdout()
+			for _, m := range list {
+				fmt.Fprintf(w, "%s\t%s\t%d variants", m.PGSID, m.TraitReported, m.VariantCount)
+				for _, d := range m.Distributions {
+					fmt.Fprintf(w, "\t%s", d.Population)
+				}
+				fmt.Fprintln(w)
+			}
+			return nil
+		},
+	}
+}
+
+func newPRSReferenceCmd(opts *rootOptions) *cobra.Command {
+	var (
+		population string
+		mean, sd   float64
+		sampleSize int
+	)
+	cmd := &cobra.Command{
+		Use:   "reference PGS_ID",
+		Short: "Store the distribution of a model's score in a reference population",
+		Long: `Store the distribution of a model's score in a reference population, used to
+place scores as percentiles. With --mean and --sd the published distribution is
+stored. Otherwise it is derived from the population's allele frequencies in the
+database, assuming Hardy-Weinberg equilibrium and independent variants, which
+overstates the spread of models with variants in linkage disequilibrium.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if population == "" {
+				return errors.New("--population is required")
+			}
+			ctx := cmd.Context()
+			db, err := opts.openDB()
+			if err != nil {
+				return err
+			}
+			defer func() {
+				_ = db.Close()
+			}()
+
+			model, err := repositories.GetPRSModel(ctx, db, args[0])
+			if errors.Is(err, sql.ErrNoRows) {
+				return fmt.Errorf("model %s not imported", args[0])
+			}
+			if err != nil {
+				return err
+			}
+			d := &models.PRSDistribution{ModelID: model.ID, Population: population, Mean: mean, SD: sd, Method: "published"}
+			if sampleSize > 0 {
+				d.SampleSize = &sampleSize
+			}
+			if !cmd.Flags().Changed("sd") {
+				rsIDs := make([]string, len(model.Weights))
+				for i, w := range model.Weights {
+					rsIDs[i] = w.RsID
+				}
+				freqs, err := repositories.GetAlleleFrequencies(ctx, db, rsIDs, population)
+				if err != nil {
+					return fmt.Errorf("load frequencies: %w", err)
+				}
+				var used int
+				d.Mean, d.SD, used = prs.FrequencyDistribution(model.Weights, freqs)
+				d.Method = prs.MethodAlleleFrequencies
+				fmt.Fprintf(cmd.ErrOrStderr(), "%d of %d variants have %s frequencies\n", used, len(model.Weights), population)
+			}
+			if err := d.Validate(); err != nil {
+				return err
+			}
+			if err := repositories.SavePRSDistribution(ctx, db, d); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "%s in %s: mean %.4g, sd %.4g (%s)\n", model.PGSID, population, d.Mean, d.SD, d.Method)
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&population, "population", "", "population code, e.g. nfe, as stored in population_data")
+	cmd.Flags().Float64Var(&mean, "mean", 0, "published mean of the score")
+	cmd.Flags().Float64Var(&sd, "sd", 0, "published standard deviation of the score")
+	cmd.Flags().IntVar(&sampleSize, "sample-size", 0, "size of the sample the published distribution comes from")
+	return cmd
+}
+
+func newPRSScoreCmd(opts *rootOptions) *cobra.Command {
+	return &cobra.Command{
+		Use:   "score FILE PGS_ID...",
+		Short: "Score a raw data file with imported models and print the scores as JSON",
+		Long: `Score a raw data file (23andMe, AncestryDNA, MyHeritage, FamilyTreeDNA) with
+each named model and print the score, how many of the model's variants the file
+covers, and its percentile in every stored reference population, as JSON.`,
+		Args: cobra.MinimumNArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			f, err := os.Open(args[0])
+			if err != nil {
+				return err
+			}
+			defer func() {
+				_ = f.Close()
+			}()
+			_, calls, err := genotype.Parse(f)
+			if err != nil {
+				return fmt.Errorf("%s: %w", args[0], err)
+			}
+
+			db, err := opts.openDB()
+			if err != nil {
+				return err
+			}
+			defer func() {
+				_ = db.Close()
+			}()
+
+			enc := json.NewEncoder(cmd.OutOrStdout())
+			enc.SetIndent("", "  ")
+			var missing int
+			for _, pgsID := range args[1:] {
+				model, err := repositories.GetPRSModel(ctx, db, pgsID)
+				if errors.Is(err, sql.ErrNoRows) {
+					fmt.Fprintf(os.Stderr, "%s: not imported\n", pgsID)
+					missing++
+					continue
+				}
+				if err != nil {
+					return fmt.Errorf("load %s: %w", pgsID, err)
+				}
+				if err := enc.Encode(prs.Compute(model, calls)); err != nil {
+					return err
+				}
+			}
+			if missing > 0 {
+				return exitCode(1)
+			}
+			return nil
+		},
+	}
+}
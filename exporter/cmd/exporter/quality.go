@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/mkoziy/genome/exporter/internal/config"
+	"github.com/mkoziy/genome/exporter/internal/models"
+	"github.com/mkoziy/genome/exporter/internal/pipeline"
+)
+
+func newQualityCmd(opts *rootOptions) *cobra.Command {
+	var batchSize int
+	cmd := &cobra.Command{
+		Use:   "quality",
+		Short: "Reassess how completely every SNP is characterized",
+		Long: `Reassess the quality score of every SNP, from 0 to 100: a quarter each for
+its descriptive fields known, the sources contributing to it, the review
+status of its best clinical assertion and the populations its frequency was
+measured in. Runs do this as their last stage; this command is for after
+editing the database by hand. SNPs scoring at least 60 count as well
+characterized.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if !cmd.Flags().Changed("batch-size") {
+				batchSize = opts.cfg.Export.BatchSize
+			}
+			db, err := opts.openDB()
+			if err != nil {
+				return err
+			}
+			defer func() {
+				_ = db.Close()
+			}()
+
+			assessed, err := pipeline.AssessQuality(cmd.Context(), db, batchSize)
+			if err != nil {
+				return fmt.Errorf("quality: %w", err)
+			}
+			well, err := db.NewSelect().Model((*models.Quality)(nil)).Where("score >= ?", models.WellCharacterizedQuality).Count(cmd.Context())
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Assessed %d SNPs, %d well characterized\n", assessed, well)
+			return nil
+		},
+	}
+	cmd.Flags().IntVar(&batchSize, "batch-size", config.DefaultConfig().Export.BatchSize, "SNPs loaded per batch")
+	return cmd
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/mkoziy/genome/exporter/internal/models"
+	"github.com/mkoziy/genome/exporter/internal/repositories"
+	"github.com/mkoziy/genome/exporter/internal/variant"
+)
+
+func newQueryCmd(opts *rootOptions) *cobra.Command {
+	return &cobra.Command{
+		Use:   "query rsID|HGVS...",
+		Short: "Print SNPs with all related data as JSON",
+		Long: `Print the SNPs of rsIDs, or of HGVS descriptions such as
+"NM_000546.6:c.215C>G", with all related data as JSON. A description's
+transcript may be given without its version, and a protein change on a gene,
+such as "TP53:p.Pro72Arg", matches the gene's SNPs with that change. rsIDs may
+be given in any case or as bare numbers, and ones merged into another are
+followed.`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			db, err := opts.openDB()
+			if err != nil {
+				return err
+			}
+			defer func() {
+				_ = db.Close()
+			}()
+
+			ctx := cmd.Context()
+			enc := json.NewEncoder(cmd.OutOrStdout())
+			enc.SetIndent("", "  ")
+			var missing int
+			for _, arg := range args {
+				if h, err := variant.ParseHGVS(arg); err == nil {
+					snps, err := repositories.GetSNPsByHGVS(ctx, db, h)
+					if err != nil {
+						return fmt.Errorf("query %s: %w", arg, err)
+					}
+					if len(snps) == 0 {
+						fmt.Fprintf(os.Stderr, "%s: not found\n", arg)
+						missing++
+					}
+					for _, snp := range snps {
+						if err := enc.Encode(snp); err != nil {
+							return err
+						}
+					}
+					continue
+				}
+
+				canonical, err := models.CanonicalRsIDs(ctx, []string{arg}, repositories.RsIDResolver(db))
+				if errors.Is(err, models.ErrInvalidRsID) {
+					fmt.Fprintf(os.Stderr, "%s: not an rsID or HGVS description\n", arg)
+					missing++
+					continue
+				}
+				if err != nil {
+					return fmt.Errorf("resolve %s: %w", arg, err)
+				}
+
+				snp, err := repositories.GetSNPByRsID(ctx, db, canonical[0])
+				if errors.Is(err, sql.ErrNoRows) {
+					fmt.Fprintf(os.Stderr, "%s: not found\n", arg)
+					missing++
+					continue
+				}
+				if err != nil {
+					return fmt.Errorf("query %s: %w", arg, err)
+				}
+				if err := enc.Encode(snp); err != nil {
+					return err
+				}
+			}
+
+			if missing > 0 {
+				return exitCode(1)
+			}
+			return nil
+		},
+	}
+}
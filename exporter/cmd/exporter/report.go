@@ -0,0 +1,181 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/mkoziy/genome/exporter/internal/genotype"
+	"github.com/mkoziy/genome/exporter/internal/liftover"
+	"github.com/mkoziy/genome/exporter/internal/pgx"
+	"github.com/mkoziy/genome/exporter/internal/report"
+	"github.com/mkoziy/genome/exporter/internal/repositories"
+)
+
+func newReportCmd(opts *rootOptions) *cobra.Command {
+	var (
+		format      string
+		out         string
+		lang        string
+		sample      string
+		title       string
+		carriedOnly bool
+		pdfCommand  string
+		assembly    string
+		sex         string
+	)
+	cmd := &cobra.Command{
+		Use:   "report FILE",
+		Short: "Render a genotype file annotated against the database as a report",
+		Long: `Annotate a raw data file (23andMe, AncestryDNA, MyHeritage, FamilyTreeDNA)
+or a VCF against the database and render the variants found as a report
+grouped into clinical findings, drug response and traits, as Markdown, HTML or
+PDF. A VCF is taken to be on the assembly its header names, or on GRCh38 if it
+names none, unless --assembly says otherwise; GRCh37 files are lifted over to
+GRCh38 with the chain file liftover.chain_file configures, skipping the sites
+that do not map. Calls on X and Y are read for the sex --sex gives, or
+else, for raw data files, the sex inferred from the heterozygosity of X; calls
+on the mitochondrion are read as one copy, or as heteroplasmy when the copies
+differ. Drug response opens with the CYP2C19, CYP2C9 and CYP2D6 diplotypes called
+from the file, their metabolizer phenotypes and CPIC recommendations. PDF is converted from the HTML by an external command reading HTML on stdin
+and writing PDF on stdout, wkhtmltopdf unless --pdf-command says otherwise.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			switch report.Format(format) {
+			case report.FormatMarkdown, report.FormatHTML, report.FormatPDF:
+			default:
+				return fmt.Errorf("unknown --format %q (want markdown, html or pdf)", format)
+			}
+			if assembly != "" {
+				if assembly = liftover.NormalizeAssembly(assembly); assembly == "" {
+					return errors.New("unknown --assembly (want GRCh37 or GRCh38)")
+				}
+			}
+			sampleSex, err := genotype.ParseSex(sex)
+			if err != nil {
+				return err
+			}
+			ctx := cmd.Context()
+
+			db, err := opts.openDB()
+			if err != nil {
+				return err
+			}
+			defer func() {
+				_ = db.Close()
+			}()
+
+			repos := repositories.NewBunRepositories(db)
+			var snps repositories.SNPRepository = repos.SNPs
+			if lang != "" {
+				snps = repositories.NewLocalizedSNPRepository(snps, repos.Translations, lang, opts.cfg.Localization)
+			}
+			in, err := annotateFile(ctx, snps, args[0], sample, assembly, sampleSex, opts.cfg.Liftover)
+			if err != nil {
+				return err
+			}
+			ropts := report.Options{Title: title, Language: lang, CarriedOnly: carriedOnly}
+			r := report.Build(in, ropts)
+
+			dst := cmd.OutOrStdout()
+			if out != "" {
+				f, err := os.Create(out)
+				if err != nil {
+					return fmt.Errorf("create output: %w", err)
+				}
+				defer func() {
+					_ = f.Close()
+				}()
+				dst = f
+			}
+			switch report.Format(format) {
+			case report.FormatHTML:
+				return r.WriteHTML(dst)
+			case report.FormatPDF:
+				return report.PDFConverter{Command: strings.Fields(pdfCommand)}.WritePDF(ctx, dst, r)
+			}
+			return r.WriteMarkdown(dst)
+		},
+	}
+	cmd.Flags().StringVar(&format, "format", string(report.FormatMarkdown), "output format: markdown, html or pdf")
+	cmd.Flags().StringVarP(&out, "output", "o", "", "write the report to this file instead of stdout")
+	cmd.Flags().StringVar(&lang, "lang", "", "language to report in, e.g. de or pt-BR, falling back as localization.fallbacks configures, then to English")
+	cmd.Flags().StringVar(&sample, "sample", "", "VCF sample to report on (the first when empty)")
+	cmd.Flags().StringVar(&title, "title", "", "report title")
+	cmd.Flags().BoolVar(&carriedOnly, "carried-only", false, "report only variants where a risk allele is carried")
+	cmd.Flags().StringVar(&assembly, "assembly", "", "assembly of a VCF, GRCh37 or GRCh38 (as its header names when empty)")
+	cmd.Flags().StringVar(&sex, "sex", "auto", "sex of the sample, male or female, or auto to infer it from a raw data file")
+	cmd.Flags().StringVar(&pdfCommand, "pdf-command", strings.Join(report.DefaultPDFCommand, " "), "command converting HTML on stdin to PDF on stdout")
+	return cmd
+}
+
+// annotateFile annotates the genotype file at path against snps, as a VCF
+// if it starts with the VCF header line and as a raw data file of a detected
+// format otherwise. A VCF on GRCh37, as assembly or else its header says, is
+// lifted over with lc's chain file. Raw data files match by rsID, whatever
+// their assembly. Calls on X and Y are read for sex, inferred for raw data
+// files when unknown.
+func annotateFile(ctx context.Context, snps repositories.SNPRepository, path, sample, assembly string, sex genotype.Sex, lc liftover.Config) (report.Input, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return report.Input{}, err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	br := bufio.NewReader(f)
+	head, err := br.Peek(len("##fileformat=VCF"))
+	if err != nil && !errors.Is(err, io.EOF) {
+		return report.Input{}, fmt.Errorf("read %s: %w", path, err)
+	}
+	if bytes.Equal(head, []byte("##fileformat=VCF")) {
+		vr, err := genotype.NewVCFReader(br, sample)
+		if err != nil {
+			return report.Input{}, fmt.Errorf("%s: %w", path, err)
+		}
+		if assembly == "" {
+			assembly = vr.Assembly
+		}
+		if assembly == liftover.GRCh37 {
+			if lc.ChainFile == "" {
+				return report.Input{}, fmt.Errorf("%s is on GRCh37 but liftover.chain_file is not configured", path)
+			}
+			chain, err := liftover.Open(lc.ChainFile, liftover.GRCh37, liftover.GRCh38)
+			if err != nil {
+				return report.Input{}, fmt.Errorf("open chain file: %w", err)
+			}
+			vr.LiftOver(chain)
+		}
+		vr.Sex = sex
+		result, err := genotype.AnnotateVCF(ctx, snps, vr)
+		if err != nil {
+			return report.Input{}, fmt.Errorf("%s: %w", path, err)
+		}
+		in := report.FromVCF(result)
+		in.Diplotypes = pgx.CallAll(pgx.Genes, pgx.FromVCF(result))
+		return in, nil
+	}
+
+	_, calls, err := genotype.Parse(br)
+	if err != nil {
+		return report.Input{}, fmt.Errorf("%s: %w", path, err)
+	}
+	if sex == genotype.SexUnknown {
+		sex = genotype.InferSex(calls)
+	}
+	result, err := genotype.Annotate(ctx, snps, calls, sex)
+	if err != nil {
+		return report.Input{}, err
+	}
+	in := report.FromResult(result)
+	in.Diplotypes = pgx.CallAll(pgx.Genes, pgx.FromCalls(calls))
+	return in, nil
+}
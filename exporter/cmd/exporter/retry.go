@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/mkoziy/genome/exporter/internal/pipeline"
+)
+
+func newRetryFailedCmd(opts *rootOptions) *cobra.Command {
+	var (
+		sopts       sourceOptions
+		maxAttempts int
+	)
+	cmd := &cobra.Command{
+		Use:   "retry-failed",
+		Short: "Download again the records that earlier runs failed to fetch or map",
+		Long: `Download again the records queued in failed_items by runs whose requests
+failed or whose records could not be mapped. Records that succeed are written
+and removed from the queue; those that fail again stay queued with their
+attempt count incremented. Run score afterwards to score the recovered SNPs.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			newFetcher, _, err := sopts.clinvarFetchers(cmd, opts)
+			if err != nil {
+				return err
+			}
+
+			db, err := opts.openDB()
+			if err != nil {
+				return err
+			}
+			defer func() {
+				_ = db.Close()
+			}()
+
+			result, err := pipeline.RetryFailed(cmd.Context(), db, newFetcher(), pipeline.RetryOptions{
+				Writer:      sopts.writer(cmd, opts),
+				MaxAttempts: maxAttempts,
+			})
+			fmt.Fprintf(cmd.OutOrStdout(), "Retried %d items: %d resolved, %d failed again, %d SNPs written, %d quarantined\n",
+				result.Retried, result.Resolved, result.Failed, result.SNPs, result.Quarantined)
+			if err != nil {
+				return fmt.Errorf("retry failed items: %w", err)
+			}
+			return nil
+		},
+	}
+	sopts.register(cmd)
+	cmd.Flags().IntVar(&maxAttempts, "max-attempts", 5, "skip items that already failed this many times (0 retries all)")
+	return cmd
+}
@@ -0,0 +1,364 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/uptrace/bun"
+
+	"github.com/mkoziy/genome/exporter/internal/config"
+	"github.com/mkoziy/genome/exporter/internal/liftover"
+	"github.com/mkoziy/genome/exporter/internal/pipeline"
+	"github.com/mkoziy/genome/exporter/internal/profile"
+	"github.com/mkoziy/genome/exporter/internal/ratelimit"
+	"github.com/mkoziy/genome/exporter/internal/repositories"
+	"github.com/mkoziy/genome/exporter/internal/scoring"
+	"github.com/mkoziy/genome/exporter/internal/sources"
+	"github.com/mkoziy/genome/exporter/internal/sources/clinvar"
+	"github.com/mkoziy/genome/exporter/internal/translate"
+)
+
+// sourceOptions are the flags that override source and writer settings of
+// the config for commands that download.
+type sourceOptions struct {
+	apiKey    string
+	email     string
+	chunkSize int
+	workers   int
+	profile   string
+}
+
+func (o *sourceOptions) register(cmd *cobra.Command) {
+	def := config.DefaultConfig()
+	cmd.Flags().StringVar(&o.apiKey, "api-key", "", "NCBI API key for higher request limits (default from config or NCBI_API_KEY)")
+	cmd.Flags().StringVar(&o.email, "email", "", "contact email sent with NCBI requests (default from config or NCBI_EMAIL)")
+	cmd.Flags().IntVar(&o.chunkSize, "chunk-size", def.Writer.ChunkSize, "SNPs committed per transaction")
+	cmd.Flags().IntVar(&o.workers, "workers", clinvar.DefaultWorkers, "batches downloaded concurrently")
+	cmd.Flags().StringVar(&o.profile, "profile", def.Profile, "dataset build profile: "+strings.Join(profile.Names(), ", "))
+}
+
+// buildProfile returns the profile the flag, or else the config, selects.
+func (o *sourceOptions) buildProfile(cmd *cobra.Command, root *rootOptions) (profile.Profile, error) {
+	name := root.cfg.Profile
+	if cmd.Flags().Changed("profile") {
+		name = o.profile
+	}
+	return profile.Get(name)
+}
+
+// source returns the configuration of the named source with the flags that
+// were set applied. A source without queries of its own gets those of prof.
+func (o *sourceOptions) source(cmd *cobra.Command, root *rootOptions, prof profile.Profile, name string) config.SourceConfig {
+	src := root.cfg.Sources[name]
+	if len(src.Queries) == 0 {
+		src.Queries = prof.Queries[name]
+	}
+	flags := cmd.Flags()
+	if flags.Changed("api-key") {
+		src.APIKey = o.apiKey
+	}
+	if flags.Changed("email") {
+		src.Email = o.email
+	}
+	if flags.Changed("workers") {
+		src.Workers = o.workers
+	}
+	return src
+}
+
+func (o *sourceOptions) writer(cmd *cobra.Command, root *rootOptions) repositories.BatchWriterConfig {
+	w := root.cfg.Writer
+	if cmd.Flags().Changed("chunk-size") {
+		w.ChunkSize = o.chunkSize
+	}
+	return w
+}
+
+// clinvarFetchers returns a function creating a ClinVar fetcher per run, since
+// fetchers keep per-run state. The runs share one rate limiter.
+func (o *sourceOptions) clinvarFetchers(cmd *cobra.Command, root *rootOptions) (func() *clinvar.Fetcher, config.SourceConfig, error) {
+	prof, err := o.buildProfile(cmd, root)
+	if err != nil {
+		return nil, config.SourceConfig{}, err
+	}
+	src := o.source(cmd, root, prof, pipeline.StageClinVar)
+	reporter, err := root.progressReporter()
+	if err != nil {
+		return nil, src, err
+	}
+	transport, err := root.httpTransport()
+	if err != nil {
+		return nil, src, err
+	}
+	limiter := ratelimit.NewLimiter(src.RateLimit)
+
+	return func() *clinvar.Fetcher {
+		client := clinvar.NewClient(limiter, src.APIKey, src.Email).WithTransport(transport)
+		return clinvar.NewFetcher(client).WithWorkers(src.Workers).WithQueries(src.Queries).
+			WithReferences(prof.References).WithProgress(reporter)
+	}, src, nil
+}
+
+// pluginSources returns a function creating an instance of each registered
+// source the config configures per run, since sources may keep per-run
+// state. The runs of a source share one rate limiter.
+func (o *sourceOptions) pluginSources(cmd *cobra.Command, root *rootOptions) (func() ([]sources.Source, error), error) {
+	prof, err := o.buildProfile(cmd, root)
+	if err != nil {
+		return nil, err
+	}
+	transport, err := root.httpTransport()
+	if err != nil {
+		return nil, err
+	}
+	names := configuredPlugins(root.cfg)
+	configs := make([]sources.Config, len(names))
+	for i, name := range names {
+		src := root.cfg.Sources[name]
+		if len(src.Queries) == 0 {
+			src.Queries = prof.Queries[name]
+		}
+		configs[i] = sources.Config{
+			Queries:   src.Queries,
+			APIKey:    src.APIKey,
+			Email:     src.Email,
+			Workers:   src.Workers,
+			Options:   src.Options,
+			Limiter:   ratelimit.NewLimiter(src.RateLimit),
+			Transport: transport,
+		}
+	}
+
+	return func() ([]sources.Source, error) {
+		srcs := make([]sources.Source, len(names))
+		for i, name := range names {
+			src, err := sources.New(name)
+			if err != nil {
+				return nil, err
+			}
+			if err := src.Configure(configs[i]); err != nil {
+				return nil, fmt.Errorf("configure %s: %w", name, err)
+			}
+			srcs[i] = src
+		}
+		return srcs, nil
+	}, nil
+}
+
+// configuredPlugins lists the registered sources the config has a section for.
+func configuredPlugins(cfg config.Config) []string {
+	var names []string
+	for _, name := range sources.Names() {
+		if _, ok := cfg.Sources[name]; ok {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// pipelineStages lists the stages of the pipeline pipelineBuilder builds.
+func pipelineStages(cfg config.Config) []string {
+	stages := []string{pipeline.StageClinVar}
+	stages = append(stages, configuredPlugins(cfg)...)
+	stages = append(stages, pipeline.StageScoring, pipeline.StageFrequencies)
+	if cfg.TranslationSync.Platform != "" {
+		stages = append(stages, pipeline.StageTranslationSync)
+	}
+	if cfg.Liftover.ChainFile != "" {
+		stages = append(stages, pipeline.StageLiftover)
+	}
+	return append(stages, pipeline.StageQuality)
+}
+
+// pipelineBuilder returns a function building a fresh pipeline for each run.
+// Registered sources the config configures run alongside ClinVar, and
+// scoring waits for all of them, as do the aggregation of frequencies and
+// the sync with the translation platform and the liftover if they are
+// configured. Quality is assessed last, after the liftover too.
+func (o *sourceOptions) pipelineBuilder(cmd *cobra.Command, root *rootOptions) (func(db *bun.DB, incremental, full, bulk bool) (*pipeline.Pipeline, error), error) {
+	newFetcher, src, err := o.clinvarFetchers(cmd, root)
+	if err != nil {
+		return nil, err
+	}
+	newPlugins, err := o.pluginSources(cmd, root)
+	if err != nil {
+		return nil, err
+	}
+	scorer := scoring.New(root.cfg.Scoring)
+	writer := o.writer(cmd, root)
+	var platform translate.Platform
+	if root.cfg.TranslationSync.Platform != "" {
+		if platform, err = translate.NewPlatform(root.cfg.TranslationSync); err != nil {
+			return nil, fmt.Errorf("translation_sync: %w", err)
+		}
+	}
+	var toGRCh37 *liftover.Chain
+	if root.cfg.Liftover.ChainFile != "" {
+		chain, err := liftover.Open(root.cfg.Liftover.ChainFile, liftover.GRCh37, liftover.GRCh38)
+		if err != nil {
+			return nil, fmt.Errorf("liftover: %w", err)
+		}
+		toGRCh37 = chain.Invert()
+	}
+
+	return func(db *bun.DB, incremental, full, bulk bool) (*pipeline.Pipeline, error) {
+		plugins, err := newPlugins()
+		if err != nil {
+			return nil, err
+		}
+		stages := []pipeline.Stage{pipeline.ClinVarStage(newFetcher(), pipeline.ClinVarOptions{
+			Writer:      writer,
+			Incremental: incremental,
+			Bulk:        bulk,
+			RateLimit:   src.RateLimit,
+		})}
+		score := pipeline.ScoringStage(scorer, pipeline.ScoreOptions{Full: full})
+		for _, plugin := range plugins {
+			stages = append(stages, pipeline.SourceStage(plugin, pipeline.SourceOptions{Writer: writer}))
+			score.DependsOn = append(score.DependsOn, plugin.Name())
+		}
+		stages = append(stages, score, pipeline.FrequencyStage(root.cfg.Export.BatchSize, slices.Clone(score.DependsOn)))
+		if platform != nil {
+			stages = append(stages, pipeline.TranslationSyncStage(platform, translate.SyncOptions{
+				Languages: root.cfg.TranslationSync.Languages,
+				Push:      true,
+				Pull:      true,
+				BatchSize: root.cfg.Export.BatchSize,
+			}, slices.Clone(score.DependsOn)))
+		}
+		quality := slices.Clone(score.DependsOn)
+		if toGRCh37 != nil {
+			stages = append(stages, pipeline.LiftoverStage(toGRCh37, root.cfg.Export.BatchSize, slices.Clone(score.DependsOn)))
+			quality = append(quality, pipeline.StageLiftover)
+		}
+		stages = append(stages, pipeline.QualityStage(root.cfg.Export.BatchSize, quality))
+		return pipeline.New(db, stages...)
+	}, nil
+}
+
+// configuredStages returns the pipeline config enabling the stages prof
+// includes of the sources the config enables, with the configured error and
+// plan budgets.
+func configuredStages(cfg config.Config, prof profile.Profile) pipeline.Config {
+	run := pipeline.Config{Enabled: make(map[string]bool), ErrorBudget: cfg.ErrorBudget, PlanBudget: cfg.PlanBudget}
+	for _, name := range pipelineStages(cfg) {
+		if !prof.Includes(name) {
+			run.Enabled[name] = false
+		}
+	}
+	for name, src := range cfg.Sources {
+		if !src.IsEnabled() {
+			run.Enabled[name] = false
+		}
+	}
+	return run
+}
+
+func newRunCmd(opts *rootOptions) *cobra.Command {
+	var (
+		sopts       sourceOptions
+		disabled    []string
+		full        bool
+		restart     bool
+		incremental bool
+		dryRun      bool
+		bulk        bool
+	)
+	cmd := &cobra.Command{
+		Use:   "run",
+		Short: "Run every enabled source and then scoring, recording the run",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			build, err := sopts.pipelineBuilder(cmd, opts)
+			if err != nil {
+				return err
+			}
+
+			db, err := opts.openDB()
+			if err != nil {
+				return err
+			}
+			defer func() {
+				_ = db.Close()
+			}()
+
+			p, err := build(db, incremental, full, bulk)
+			if err != nil {
+				return err
+			}
+
+			prof, err := sopts.buildProfile(cmd, opts)
+			if err != nil {
+				return err
+			}
+			cfg := configuredStages(opts.cfg, prof)
+			cfg.Restart = restart
+			for _, name := range disabled {
+				cfg.Enabled[name] = false
+			}
+			if dryRun {
+				plan, err := p.Plan(cmd.Context(), cfg)
+				if err != nil {
+					return err
+				}
+				writeRunPlan(cmd.OutOrStdout(), plan)
+				return cfg.PlanBudget.Check(plan)
+			}
+			report, runErr := p.Run(cmd.Context(), cfg)
+			if report != nil {
+				writeRunReport(cmd.OutOrStdout(), report)
+			}
+			opts.notifyRun(cmd.Context(), "", report, runErr)
+			return runErr
+		},
+	}
+	sopts.register(cmd)
+	cmd.Flags().StringSliceVar(&disabled, "disable", nil, "stages to skip, e.g. --disable clinvar to only rescore")
+	cmd.Flags().BoolVar(&full, "full", false, "rescore every SNP instead of only unscored and changed ones")
+	cmd.Flags().BoolVar(&restart, "restart", false, "ignore checkpoints of an interrupted run and start over")
+	cmd.Flags().BoolVar(&incremental, "incremental", false, "only download what changed since each source last completed")
+	cmd.Flags().BoolVar(&bulk, "bulk", false, "load with secondary indexes dropped and fsyncs off, rebuilding the indexes afterwards; for initial builds")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "report the requests and time each source would take and check them against the plan budget, writing nothing")
+	return cmd
+}
+
+func writeRunReport(w io.Writer, report *pipeline.Report) {
+	for _, stage := range report.Stages {
+		fmt.Fprintf(w, "%-10s %-9s %s", stage.Name, stage.Status, stage.Duration.Round(time.Millisecond))
+		if stage.Error != "" {
+			fmt.Fprintf(w, "  %s", stage.Error)
+		}
+		fmt.Fprintln(w)
+	}
+	fmt.Fprintf(w, "Run %s %s: %d downloaded, %d updated", report.Metadata.RunID, report.Metadata.Status,
+		report.Metadata.SNPsDownloaded, report.Metadata.SNPsUpdated)
+	if report.Metadata.SNPsSkipped > 0 {
+		fmt.Fprintf(w, ", %d quarantined", report.Metadata.SNPsSkipped)
+	}
+	if report.Metadata.ErrorsCount > 0 {
+		fmt.Fprintf(w, ", %d errors", report.Metadata.ErrorsCount)
+	}
+	fmt.Fprintln(w)
+	if report.Spectrum != nil {
+		all := report.Spectrum.All
+		fmt.Fprintf(w, "Frequencies: %d rare, %d low-frequency, %d common, %d unmeasured\n",
+			all.Rare, all.LowFrequency, all.Common, all.Unmeasured)
+	}
+	if report.Release != nil {
+		fmt.Fprintf(w, "Release %s recorded\n", report.Release.Version)
+	}
+}
+
+// writeRunPlan prints each source's estimate. Durations are what the rate
+// limits alone would take, a lower bound for the real run.
+func writeRunPlan(w io.Writer, plan *pipeline.Plan) {
+	for _, src := range plan.Sources {
+		fmt.Fprintf(w, "%-10s %8d variants  %6d requests  at least %s\n",
+			src.Source, src.Variants, src.Requests, src.Duration.Round(time.Second))
+	}
+	fmt.Fprintf(w, "Plan: %d requests, at least %s\n", plan.Requests, plan.Duration.Round(time.Second))
+}
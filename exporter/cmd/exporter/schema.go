@@ -0,0 +1,154 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/mkoziy/genome/exporter/internal/attribution"
+	"github.com/mkoziy/genome/exporter/internal/bundle"
+	"github.com/mkoziy/genome/exporter/internal/diff"
+	"github.com/mkoziy/genome/exporter/internal/jsonschema"
+	"github.com/mkoziy/genome/exporter/internal/models"
+	"github.com/mkoziy/genome/exporter/internal/prs"
+	"github.com/mkoziy/genome/exporter/internal/search"
+)
+
+// schemaPayload is a JSON payload the exporter writes.
+type schemaPayload struct {
+	name        string
+	description string
+	value       any
+}
+
+// schemaPayloads lists the payloads the schema command describes.
+var schemaPayloads = []schemaPayload{
+	{"snp", "A SNP with all it is annotated with: a line of export jsonl, and an object written by query.", models.SNP{}},
+	{"annotation", "A line written by annotate --format json.", rsIDAnnotation{}},
+	{"fetched-record", "A line written by fetch-one.", fetchedRecord{}},
+	{"status", "The report written by status --json.", statusReport{}},
+	{"diff", "The report written by diff --format json.", diff.Report{}},
+	{"attribution", "The attribution manifest shipped with exports and written by attribution --format json.", attribution.Manifest{}},
+	{"prs-score", "A line written by prs score.", prs.Score{}},
+	{"change", "A line written by changes.", models.Change{}},
+	{"snp-change", "A line written by changes --snps.", snpChange{}},
+	{"bundle-manifest", "The checksum manifest of a bundle, written by export-mobile and bundle manifest.", bundle.Manifest{}},
+	{"search-document", "A document indexed by export-search.", search.Document{}},
+}
+
+// schemaGenerator derives the payloads' schemas, enumerating the values of
+// the models' enum types.
+func schemaGenerator() *jsonschema.Generator {
+	g := &jsonschema.Generator{}
+	g.Enum(models.ClinicalSignificances)
+	g.Enum(models.ReviewStatuses)
+	g.Enum(models.DataSources)
+	g.Enum(models.VariantTypes)
+	g.Enum(models.FunctionalClasses)
+	g.Enum(models.TranslationStatuses)
+	return g
+}
+
+// payloadSchema returns the schema document of p. With baseURL set its $id
+// is the URL the document is published at.
+func payloadSchema(g *jsonschema.Generator, p schemaPayload, baseURL string) *jsonschema.Schema {
+	doc := g.Reflect(p.value)
+	doc.Title = p.name
+	doc.Description = p.description
+	if baseURL != "" {
+		doc.ID = strings.TrimSuffix(baseURL, "/") + "/" + p.name + ".schema.json"
+	}
+	return doc
+}
+
+func newSchemaCmd(opts *rootOptions) *cobra.Command {
+	var (
+		out     string
+		outDir  string
+		baseURL string
+	)
+	cmd := &cobra.Command{
+		Use:   "schema [payload]",
+		Short: "Write JSON Schema definitions of the JSON the exporter writes",
+		Long: `Write the JSON Schema (draft 2020-12) of a JSON payload the exporter writes,
+derived from the Go types it encodes, so consumers in other languages can
+validate it and generate code from it. Without arguments the payloads are
+listed; with --out-dir every payload, or the one named, is written to
+DIR/<payload>.schema.json.`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			payloads := schemaPayloads
+			if len(args) == 1 {
+				p, ok := findPayload(args[0])
+				if !ok {
+					return fmt.Errorf("unknown payload %q; run schema without arguments to list them", args[0])
+				}
+				payloads = []schemaPayload{p}
+			}
+			g := schemaGenerator()
+
+			switch {
+			case outDir != "":
+				if err := os.MkdirAll(outDir, 0o755); err != nil {
+					return err
+				}
+				for _, p := range payloads {
+					path := filepath.Join(outDir, p.name+".schema.json")
+					if err := writeSchemaFile(path, payloadSchema(g, p, baseURL)); err != nil {
+						return err
+					}
+					fmt.Fprintf(cmd.OutOrStdout(), "Wrote %s\n", path)
+				}
+				return nil
+			case len(args) == 1:
+				if out != "" {
+					return writeSchemaFile(out, payloadSchema(g, payloads[0], baseURL))
+				}
+				return writeSchema(cmd.OutOrStdout(), payloadSchema(g, payloads[0], baseURL))
+			}
+
+			tw := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+			for _, p := range payloads {
+				fmt.Fprintf(tw, "%s\t%s\n", p.name, p.description)
+			}
+			return tw.Flush()
+		},
+	}
+	cmd.Flags().StringVarP(&out, "out", "o", "", "file to write the named payload's schema to (defaults to stdout)")
+	cmd.Flags().StringVar(&outDir, "out-dir", "", "directory to write each schema to as <payload>.schema.json")
+	cmd.Flags().StringVar(&baseURL, "base-url", "", "URL the schemas are published under, used for their $id")
+	return cmd
+}
+
+func findPayload(name string) (schemaPayload, bool) {
+	for _, p := range schemaPayloads {
+		if p.name == name {
+			return p, true
+		}
+	}
+	return schemaPayload{}, false
+}
+
+func writeSchema(w io.Writer, doc *jsonschema.Schema) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(doc)
+}
+
+func writeSchemaFile(path string, doc *jsonschema.Schema) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("create output: %w", err)
+	}
+	if err := writeSchema(f, doc); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("write %s: %w", path, err)
+	}
+	return f.Close()
+}
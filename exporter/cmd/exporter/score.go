@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/mkoziy/genome/exporter/internal/pipeline"
+	"github.com/mkoziy/genome/exporter/internal/scoring"
+)
+
+func newScoreCmd(opts *rootOptions) *cobra.Command {
+	var (
+		full      bool
+		batchSize int
+	)
+	cmd := &cobra.Command{
+		Use:   "score",
+		Short: "Recalculate significance for unscored and changed SNPs",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			db, err := opts.openDB()
+			if err != nil {
+				return err
+			}
+			defer func() {
+				_ = db.Close()
+			}()
+
+			result, err := pipeline.Score(cmd.Context(), db, scoring.New(opts.cfg.Scoring), pipeline.ScoreOptions{Full: full, BatchSize: batchSize})
+			if err != nil {
+				return fmt.Errorf("score: %w", err)
+			}
+
+			out := cmd.OutOrStdout()
+			if result.Outdated > 0 {
+				fmt.Fprintf(out, "Rescored %d SNPs scored by an older algorithm\n", result.Outdated)
+			}
+			fmt.Fprintf(out, "Scored %d SNPs\n", result.Scored)
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&full, "full", false, "rescore every SNP instead of only unscored and changed ones")
+	cmd.Flags().IntVar(&batchSize, "batch-size", 500, "SNPs loaded per batch")
+	return cmd
+}
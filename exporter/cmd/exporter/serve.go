@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/mkoziy/genome/exporter/internal/config"
+	"github.com/mkoziy/genome/exporter/internal/pipeline"
+	"github.com/mkoziy/genome/exporter/internal/profile"
+	"github.com/mkoziy/genome/exporter/internal/schedule"
+)
+
+func newServeCmd(opts *rootOptions) *cobra.Command {
+	var (
+		sopts        sourceOptions
+		scheduleFile string
+	)
+	cmd := &cobra.Command{
+		Use:   "serve",
+		Short: "Stay resident and run the pipeline on cron schedules",
+		Long: `Stay resident and run pipeline jobs on the cron schedules in the --schedule
+file. Each run is recorded and notified like one started with the run
+command. A job that fires while its previous run is still going is skipped,
+and jobs never run at the same time.
+
+With translation_sync configured, a job listing the translation-sync stage
+pulls the translations completed on Weblate or Crowdin and pushes the
+strings they lack, such as:
+
+  - name: translations
+    cron: "0 * * * *"
+    stages: [translation-sync]`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if scheduleFile == "" {
+				return errors.New("--schedule is required")
+			}
+			data, err := os.ReadFile(scheduleFile)
+			if err != nil {
+				return fmt.Errorf("read schedule: %w", err)
+			}
+			cfg, err := schedule.LoadConfig(data)
+			if err != nil {
+				return fmt.Errorf("parse schedule: %w", err)
+			}
+			build, err := sopts.pipelineBuilder(cmd, opts)
+			if err != nil {
+				return err
+			}
+			prof, err := sopts.buildProfile(cmd, opts)
+			if err != nil {
+				return err
+			}
+
+			db, err := opts.openDB()
+			if err != nil {
+				return err
+			}
+			defer func() {
+				_ = db.Close()
+			}()
+
+			jobs := make([]schedule.Job, 0, len(cfg.Jobs))
+			for _, jc := range cfg.Jobs {
+				runCfg, err := jobPipelineConfig(opts.cfg, prof, jc)
+				if err != nil {
+					return err
+				}
+				cron, err := schedule.Parse(jc.Cron)
+				if err != nil {
+					return err
+				}
+				out := cmd.OutOrStdout()
+				jobs = append(jobs, schedule.Job{
+					Name: jc.Name,
+					Cron: cron,
+					Run: func(ctx context.Context) error {
+						p, err := build(db, jc.Incremental, jc.Full, false)
+						if err != nil {
+							opts.notifyRun(ctx, jc.Name, nil, err)
+							return err
+						}
+						report, err := p.Run(ctx, runCfg)
+						if report != nil {
+							writeJobReport(out, jc.Name, report)
+						}
+						opts.notifyRun(ctx, jc.Name, report, err)
+						return err
+					},
+				})
+			}
+
+			scheduler, err := schedule.New(jobs...)
+			if err != nil {
+				return err
+			}
+			if err := scheduler.Run(cmd.Context()); !errors.Is(err, context.Canceled) {
+				return err
+			}
+			return nil
+		},
+	}
+	sopts.register(cmd)
+	cmd.Flags().StringVar(&scheduleFile, "schedule", "", "YAML file listing jobs with their cron expressions and stages")
+	return cmd
+}
+
+// jobPipelineConfig enables only the job's stages, or the stages of prof the
+// config enables if the job lists none.
+func jobPipelineConfig(appCfg config.Config, prof profile.Profile, jc schedule.JobConfig) (pipeline.Config, error) {
+	if len(jc.Stages) == 0 {
+		return configuredStages(appCfg, prof), nil
+	}
+	stages := pipelineStages(appCfg)
+	cfg := pipeline.Config{
+		Enabled:     make(map[string]bool, len(stages)),
+		ErrorBudget: appCfg.ErrorBudget,
+		PlanBudget:  appCfg.PlanBudget,
+	}
+	for _, name := range stages {
+		cfg.Enabled[name] = false
+	}
+	for _, name := range jc.Stages {
+		if _, ok := cfg.Enabled[name]; !ok {
+			return pipeline.Config{}, fmt.Errorf("job %s: unknown stage %q", jc.Name, name)
+		}
+		cfg.Enabled[name] = true
+	}
+	return cfg, nil
+}
+
+func writeJobReport(w io.Writer, job string, report *pipeline.Report) {
+	fmt.Fprintf(w, "Job %s:\n", job)
+	writeRunReport(w, report)
+}
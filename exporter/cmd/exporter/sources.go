@@ -0,0 +1,9 @@
+package main
+
+// Sources beyond ClinVar are compiled in by blank-importing their packages
+// here, each of which registers itself with the sources package:
+//
+//	import _ "github.com/mkoziy/genome/exporter/internal/sources/mylab"
+//
+// A registered source runs once the config has a section for it under
+// sources.
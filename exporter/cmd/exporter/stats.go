@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/mkoziy/genome/exporter/internal/stats"
+	"github.com/mkoziy/genome/exporter/internal/summary"
+)
+
+func newStatsCmd(opts *rootOptions) *cobra.Command {
+	var asJSON bool
+	cmd := &cobra.Command{
+		Use:   "stats",
+		Short: "Show the allele frequency spectrum, per chromosome and clinical significance",
+		Long: `Show the site frequency spectrum of the SNPs, folded on their global minor
+allele frequency, and how many are rare (MAF below 1%), of low frequency
+(below 5%), common or unmeasured: of all SNPs, of those on each chromosome
+and of those with each clinical significance. Comparing them with the last
+release's catches sources that silently lost their frequencies. The summary
+tables are refreshed with them, as completed runs do.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			db, err := opts.openDB()
+			if err != nil {
+				return err
+			}
+			defer func() {
+				_ = db.Close()
+			}()
+
+			if err := summary.Refresh(cmd.Context(), db); err != nil {
+				return fmt.Errorf("refresh summaries: %w", err)
+			}
+			spectrum, err := stats.GetFrequencySpectrum(cmd.Context(), db)
+			if err != nil {
+				return err
+			}
+			if asJSON {
+				enc := json.NewEncoder(cmd.OutOrStdout())
+				enc.SetIndent("", "  ")
+				return enc.Encode(spectrum)
+			}
+			return writeSpectrum(cmd.OutOrStdout(), spectrum)
+		},
+	}
+	cmd.Flags().BoolVar(&asJSON, "json", false, "write the spectrum as JSON")
+	return cmd
+}
+
+func writeSpectrum(w io.Writer, spectrum *stats.FrequencySpectrum) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "MAF\tSNPS")
+	for _, b := range spectrum.All.Bins {
+		fmt.Fprintf(tw, "%g-%g\t%d\n", b.Start, b.End, b.Count)
+	}
+	fmt.Fprintf(tw, "unmeasured\t%d\n", spectrum.All.Unmeasured)
+	fmt.Fprintln(tw)
+
+	fmt.Fprintln(tw, "GROUP\tSNPS\tRARE\tLOW\tCOMMON\tUNMEASURED")
+	row := func(name string, g *stats.FrequencyGroup) {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%d\t%d\n", name, g.SNPs, g.Rare, g.LowFrequency, g.Common, g.Unmeasured)
+	}
+	row("all", spectrum.All)
+	for _, groups := range []struct {
+		prefix string
+		groups map[string]*stats.FrequencyGroup
+	}{
+		{"chr", spectrum.Chromosomes},
+		{"", spectrum.ClinicalSignificance},
+	} {
+		names := make([]string, 0, len(groups.groups))
+		for name := range groups.groups {
+			names = append(names, name)
+		}
+		// Chromosomes in numeric order, then the others by name.
+		sort.Slice(names, func(i, j int) bool {
+			a, aErr := strconv.Atoi(names[i])
+			b, bErr := strconv.Atoi(names[j])
+			if aErr == nil && bErr == nil {
+				return a < b
+			}
+			if (aErr == nil) != (bErr == nil) {
+				return aErr == nil
+			}
+			return names[i] < names[j]
+		})
+		for _, name := range names {
+			row(groups.prefix+name, groups.groups[name])
+		}
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/spf13/cobra"
+
+	"github.com/mkoziy/genome/exporter/internal/models"
+	"github.com/mkoziy/genome/exporter/internal/repositories"
+	"github.com/mkoziy/genome/exporter/internal/stats"
+)
+
+// statusReport is what the status command prints.
+type statusReport struct {
+	Release  *models.DatasetRelease `json:"release,omitempty"`
+	Counts   *stats.Counts          `json:"counts"`
+	Coverage *stats.Coverage        `json:"coverage"`
+}
+
+func newStatusCmd(opts *rootOptions) *cobra.Command {
+	var asJSON bool
+	cmd := &cobra.Command{
+		Use:   "status",
+		Short: "Show the current release, row counts and data coverage",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			db, err := opts.openDB()
+			if err != nil {
+				return err
+			}
+			defer func() {
+				_ = db.Close()
+			}()
+
+			ctx := cmd.Context()
+			report := &statusReport{}
+			report.Release, err = repositories.GetCurrentRelease(ctx, db)
+			if errors.Is(err, sql.ErrNoRows) {
+				report.Release = nil
+			} else if err != nil {
+				return fmt.Errorf("current release: %w", err)
+			}
+			if report.Counts, err = stats.GetCounts(ctx, db); err != nil {
+				return fmt.Errorf("counts: %w", err)
+			}
+			if report.Coverage, err = stats.GetCoverage(ctx, db); err != nil {
+				return fmt.Errorf("coverage: %w", err)
+			}
+
+			if asJSON {
+				enc := json.NewEncoder(cmd.OutOrStdout())
+				enc.SetIndent("", "  ")
+				return enc.Encode(report)
+			}
+			writeStatus(cmd.OutOrStdout(), report)
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&asJSON, "json", false, "write the status as JSON")
+	return cmd
+}
+
+func writeStatus(w io.Writer, report *statusReport) {
+	if report.Release != nil {
+		fmt.Fprintf(w, "Release:   %s (built %s)\n", report.Release.Version, report.Release.BuildDate.Format("2006-01-02"))
+	} else {
+		fmt.Fprintln(w, "Release:   none")
+	}
+
+	cov := report.Coverage
+	fmt.Fprintf(w, "SNPs:      %d\n", cov.TotalSNPs)
+	fmt.Fprintln(w, "Coverage:")
+	for _, row := range []struct {
+		name string
+		n    int
+	}{
+		{"scored", cov.WithScore},
+		{"clinical", cov.WithClinical},
+		{"population", cov.WithPopulationData},
+		{"references", cov.WithReferences},
+		{"translations", cov.WithTranslations},
+	} {
+		fmt.Fprintf(w, "  %-12s %8d  %5.1f%%\n", row.name, row.n, 100*cov.Fraction(row.n))
+	}
+
+	fmt.Fprintln(w, "Tables:")
+	tables := make([]string, 0, len(report.Counts.Tables))
+	for table := range report.Counts.Tables {
+		tables = append(tables, table)
+	}
+	sort.Strings(tables)
+	for _, table := range tables {
+		fmt.Fprintf(w, "  %-22s %8d\n", table, report.Counts.Tables[table])
+	}
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/mkoziy/genome/exporter/internal/config"
+	"github.com/mkoziy/genome/exporter/internal/ratelimit"
+	"github.com/mkoziy/genome/exporter/internal/translate"
+)
+
+func newTranslateCmd(opts *rootOptions) *cobra.Command {
+	var (
+		langs     []string
+		fields    []string
+		provider  string
+		yes       bool
+		dryRun    bool
+		batchSize int
+	)
+	cmd := &cobra.Command{
+		Use:   "translate",
+		Short: "Machine-translate the untranslated text into languages",
+		Long: `Count the strings of --fields not yet translated into each --lang, estimate
+the characters and cost the provider will bill, and after confirmation
+translate them in batches, at the provider's rate limit. Identical strings
+are translated once, and glossary terms are kept out of the provider's hands
+and given their fixed translations.
+
+Translations are recorded as machine drafts by machine:<provider>, for
+review with "translations pending" and "translations review" or in a CAT
+tool with "translations export". Each batch is written as it arrives, so an
+interrupted run resumes where it stopped.
+
+Configure the provider (deepl or google) under translation, with its key in
+EXPORTER_TRANSLATION_API_KEY.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(langs) == 0 {
+				return errors.New("--lang is required")
+			}
+			cfg := opts.cfg.Translation
+			if provider != "" {
+				cfg.Provider = provider
+			}
+			if err := cfg.Validate(); err != nil {
+				return fmt.Errorf("translation: %w", err)
+			}
+			if !cmd.Flags().Changed("batch-size") {
+				batchSize = opts.cfg.Export.BatchSize
+			}
+			db, err := opts.openDB()
+			if err != nil {
+				return err
+			}
+			defer func() {
+				_ = db.Close()
+			}()
+
+			var jobs []*translate.Job
+			for _, lang := range langs {
+				job, err := translate.Plan(cmd.Context(), db, lang, fields, batchSize)
+				if err != nil {
+					return fmt.Errorf("plan %s: %w", lang, err)
+				}
+				jobs = append(jobs, job)
+			}
+			w := cmd.OutOrStdout()
+			requests := writeTranslateEstimate(w, cfg, jobs)
+			if dryRun || requests == 0 {
+				return nil
+			}
+
+			p, err := translate.NewProvider(cfg, ratelimit.NewLimiter(cfg.RateLimit))
+			if err != nil {
+				return err
+			}
+			if !yes {
+				fmt.Fprint(w, "Translate? [y/N] ")
+				answer, _ := bufio.NewReader(cmd.InOrStdin()).ReadString('\n')
+				if a := strings.ToLower(strings.TrimSpace(answer)); a != "y" && a != "yes" {
+					return errors.New("aborted")
+				}
+			}
+			for _, job := range jobs {
+				if len(job.Texts) == 0 {
+					continue
+				}
+				stats, err := job.Run(cmd.Context(), db, p, cfg)
+				if stats != nil {
+					for _, key := range stats.Failed {
+						fmt.Fprintf(w, "failed: %s: the translation dropped a glossary term\n", key)
+					}
+					for _, key := range stats.Malformed {
+						fmt.Fprintf(w, "failed: %s: the translation dropped a placeholder\n", key)
+					}
+					fmt.Fprintf(w, "%s: translated %d texts, wrote %d translations; %d failed, %d stale\n",
+						job.Language, stats.Translated, stats.Imported, len(stats.Failed)+len(stats.Malformed), len(stats.Stale))
+				}
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringSliceVar(&langs, "lang", nil, "languages to translate into, comma-separated")
+	cmd.Flags().StringSliceVar(&fields, "fields", nil, "kinds of text to translate: "+strings.Join(translate.Fields, ", ")+" (defaults to all)")
+	cmd.Flags().StringVar(&provider, "provider", "", "machine translation provider, overriding translation.provider")
+	cmd.Flags().BoolVarP(&yes, "yes", "y", false, "translate without asking for confirmation")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "report the estimate only, translating nothing")
+	cmd.Flags().IntVar(&batchSize, "batch-size", config.DefaultConfig().Export.BatchSize, "SNPs read per query while counting")
+	return cmd
+}
+
+// writeTranslateEstimate writes the estimate of each job and their total,
+// and returns the number of requests they need.
+func writeTranslateEstimate(w io.Writer, cfg translate.MachineConfig, jobs []*translate.Job) int {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "LANGUAGE\tSTRINGS\tTEXTS\tCHARACTERS\tREQUESTS\tCOST")
+	var total translate.Estimate
+	for _, job := range jobs {
+		e := job.Estimate(cfg)
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%d\t$%.2f\n", e.Language, e.Strings, e.Texts, e.Characters, e.Requests, e.Cost)
+		total.Strings += e.Strings
+		total.Texts += e.Texts
+		total.Characters += e.Characters
+		total.Requests += e.Requests
+		total.Cost += e.Cost
+	}
+	fmt.Fprintf(tw, "total\t%d\t%d\t%d\t%d\t$%.2f\n", total.Strings, total.Texts, total.Characters, total.Requests, total.Cost)
+	_ = tw.Flush()
+	if cfg.Provider != "" {
+		fmt.Fprintf(w, "Provider %s, at least %s\n", cfg.Provider, cfg.RateLimit.EstimateDuration(total.Requests).Round(time.Second))
+	}
+	return total.Requests
+}
@@ -0,0 +1,543 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/mkoziy/genome/exporter/internal/config"
+	"github.com/mkoziy/genome/exporter/internal/models"
+	"github.com/mkoziy/genome/exporter/internal/repositories"
+	"github.com/mkoziy/genome/exporter/internal/translate"
+)
+
+func newTranslationsCmd(opts *rootOptions) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "translations",
+		Short: "Review machine translations",
+		Long: `Translations start as machine drafts; a reviewer marks them reviewed, and
+a second look marks them verified. A translation found wrong at any point
+goes back to machine_draft. Exports prefer verified translations to reviewed
+ones, and reviewed ones to drafts.`,
+	}
+	cmd.AddCommand(
+		newTranslationsPendingCmd(opts),
+		newTranslationsReviewCmd(opts),
+		newTranslationsProgressCmd(opts),
+		newTranslationsExportCmd(opts),
+		newTranslationsImportCmd(opts),
+		newTranslationsSyncCmd(opts),
+		newTranslationsCheckCmd(opts),
+		newTranslationsResolveCmd(opts),
+		newGlossaryCmd(opts),
+	)
+	return cmd
+}
+
+// pendingTranslation is a line of translations pending: a translation with
+// what it translates, for the reviewer to compare against.
+type pendingTranslation struct {
+	RsID        string `json:"rsid,omitempty"`
+	Phenotype   string `json:"phenotype,omitempty"`
+	Translation any    `json:"translation"`
+}
+
+func newTranslationsPendingCmd(opts *rootOptions) *cobra.Command {
+	var (
+		lang       string
+		status     string
+		limit      int
+		phenotypes bool
+		out        string
+	)
+	cmd := &cobra.Command{
+		Use:   "pending",
+		Short: "List the translations of a language awaiting review as JSON lines",
+		Long: `Write the --lang translations not yet verified, oldest first, as JSON
+lines with the rsID of their SNP, or with --phenotypes the translations of
+phenotype names with the name they translate. Pass the id of each to
+"translations review".`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if lang == "" {
+				return errors.New("--lang is required")
+			}
+			next := models.TranslationStatus(status)
+			if status != "" && !next.IsValid() {
+				return fmt.Errorf("invalid --status %q", status)
+			}
+			db, err := opts.openDB()
+			if err != nil {
+				return err
+			}
+			defer func() {
+				_ = db.Close()
+			}()
+
+			dst := cmd.OutOrStdout()
+			if out != "" {
+				f, err := os.Create(out)
+				if err != nil {
+					return fmt.Errorf("create output: %w", err)
+				}
+				defer func() {
+					_ = f.Close()
+				}()
+				dst = f
+			}
+			enc := json.NewEncoder(dst)
+
+			var rows []pendingTranslation
+			if phenotypes {
+				names, err := repositories.ListPendingPhenotypeTranslations(cmd.Context(), db, lang, next, limit)
+				if err != nil {
+					return fmt.Errorf("pending translations: %w", err)
+				}
+				for _, n := range names {
+					rows = append(rows, pendingTranslation{Phenotype: n.Phenotype.PhenotypeName, Translation: n})
+				}
+			} else {
+				fields, err := repositories.ListPendingTranslations(cmd.Context(), db, lang, next, limit)
+				if err != nil {
+					return fmt.Errorf("pending translations: %w", err)
+				}
+				for _, f := range fields {
+					rows = append(rows, pendingTranslation{RsID: f.SNP.RsID, Translation: f})
+				}
+			}
+			for _, row := range rows {
+				if err := enc.Encode(row); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&lang, "lang", "", "language code of the translations")
+	cmd.Flags().StringVar(&status, "status", "", "only list translations in this status (machine_draft or reviewed)")
+	cmd.Flags().IntVar(&limit, "limit", 100, "maximum number of translations to list (0 for all)")
+	cmd.Flags().BoolVar(&phenotypes, "phenotypes", false, "list translations of phenotype names instead of SNP fields")
+	cmd.Flags().StringVarP(&out, "out", "o", "", "file to write (defaults to stdout)")
+	return cmd
+}
+
+func newTranslationsReviewCmd(opts *rootOptions) *cobra.Command {
+	var (
+		reviewer  string
+		status    string
+		phenotype bool
+	)
+	cmd := &cobra.Command{
+		Use:   "review ID",
+		Short: "Move a translation to the next review status",
+		Long: `Mark the translation ID as --status, recording --reviewer and the time.
+A machine draft must be reviewed before it is verified; any translation can
+be sent back to machine_draft, which clears its review.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			id, err := strconv.ParseInt(args[0], 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid translation id %q", args[0])
+			}
+			if reviewer == "" {
+				return errors.New("--reviewer is required")
+			}
+			next := models.TranslationStatus(status)
+			if !next.IsValid() {
+				return fmt.Errorf("invalid --status %q", status)
+			}
+			db, err := opts.openDB()
+			if err != nil {
+				return err
+			}
+			defer func() {
+				_ = db.Close()
+			}()
+
+			if phenotype {
+				_, err = repositories.ReviewPhenotypeTranslation(cmd.Context(), db, id, next, reviewer)
+			} else {
+				_, err = repositories.ReviewTranslation(cmd.Context(), db, id, next, reviewer)
+			}
+			if errors.Is(err, sql.ErrNoRows) {
+				return fmt.Errorf("translation %d not found", id)
+			} else if err != nil {
+				return fmt.Errorf("review translation %d: %w", id, err)
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Translation %d is now %s\n", id, next)
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&reviewer, "reviewer", "", "who reviewed the translation")
+	cmd.Flags().StringVar(&status, "status", string(models.TranslationReviewed), "status to move the translation to")
+	cmd.Flags().BoolVar(&phenotype, "phenotype", false, "ID is the translation of a phenotype name")
+	return cmd
+}
+
+func newTranslationsProgressCmd(opts *rootOptions) *cobra.Command {
+	var asJSON bool
+	cmd := &cobra.Command{
+		Use:   "progress",
+		Short: "Count the translations of every language by review status",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			db, err := opts.openDB()
+			if err != nil {
+				return err
+			}
+			defer func() {
+				_ = db.Close()
+			}()
+			progress, err := repositories.GetTranslationProgress(cmd.Context(), db)
+			if err != nil {
+				return fmt.Errorf("translation progress: %w", err)
+			}
+
+			if asJSON {
+				enc := json.NewEncoder(cmd.OutOrStdout())
+				enc.SetIndent("", "  ")
+				return enc.Encode(progress)
+			}
+			w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 4, 2, ' ', tabwriter.AlignRight)
+			fmt.Fprint(w, "language\t")
+			for _, s := range models.TranslationStatuses {
+				fmt.Fprintf(w, "%s\t", s)
+			}
+			fmt.Fprintln(w, "pending\t")
+			for _, p := range progress {
+				fmt.Fprintf(w, "%s\t", p.Language)
+				for _, s := range models.TranslationStatuses {
+					fmt.Fprintf(w, "%d\t", p.Statuses[string(s)])
+				}
+				fmt.Fprintf(w, "%d\t\n", p.Pending())
+			}
+			return w.Flush()
+		},
+	}
+	cmd.Flags().BoolVar(&asJSON, "json", false, "write the counts as JSON")
+	return cmd
+}
+
+// translationFormat returns the file format, xliff or po, named by format
+// or else by the extension of path.
+func translationFormat(format, path string) (string, error) {
+	if format == "" {
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".xlf", ".xliff":
+			format = "xliff"
+		case ".po", ".pot":
+			format = "po"
+		default:
+			return "", fmt.Errorf("cannot tell the format of %s; set --format", path)
+		}
+	}
+	if format != "xliff" && format != "po" {
+		return "", fmt.Errorf("invalid --format %q (want xliff or po)", format)
+	}
+	return format, nil
+}
+
+func newTranslationsExportCmd(opts *rootOptions) *cobra.Command {
+	var (
+		lang             string
+		out              string
+		format           string
+		untranslatedOnly bool
+		batchSize        int
+	)
+	cmd := &cobra.Command{
+		Use:   "export",
+		Short: "Write the strings awaiting translation as XLIFF or PO",
+		Long: `Write the summaries, condition names and phenotype names of the SNPs, and
+the significance levels and score reason templates, not yet translated into
+--lang, and those whose translation is not yet verified, as an XLIFF 1.2 or
+gettext PO file for translators to work on in their own tools. Machine
+drafts are included as translations to review, marked
+needs-review-translation in XLIFF and fuzzy in PO. A template's
+placeholders, such as {1}, must be kept, in any order.
+
+Bring the completed file back with "translations import".`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if lang == "" {
+				return errors.New("--lang is required")
+			}
+			if out == "" {
+				return errors.New("--out is required")
+			}
+			format, err := translationFormat(format, out)
+			if err != nil {
+				return err
+			}
+			if !cmd.Flags().Changed("batch-size") {
+				batchSize = opts.cfg.Export.BatchSize
+			}
+			db, err := opts.openDB()
+			if err != nil {
+				return err
+			}
+			defer func() {
+				_ = db.Close()
+			}()
+
+			units, err := translate.Collect(cmd.Context(), db, lang, untranslatedOnly, batchSize)
+			if err != nil {
+				return fmt.Errorf("export translations: %w", err)
+			}
+			f, err := os.Create(out)
+			if err != nil {
+				return fmt.Errorf("create output: %w", err)
+			}
+			if format == "xliff" {
+				err = translate.WriteXLIFF(f, lang, units)
+			} else {
+				err = translate.WritePO(f, lang, units)
+			}
+			if err != nil {
+				_ = f.Close()
+				return fmt.Errorf("write %s: %w", out, err)
+			}
+			if err := f.Close(); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Exported %d strings to translate into %s to %s\n", len(units), lang, out)
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&lang, "lang", "", "language code to translate into")
+	cmd.Flags().StringVarP(&out, "out", "o", "", "file to write (.xlf, .xliff or .po)")
+	cmd.Flags().StringVar(&format, "format", "", "xliff or po (defaults to the extension of --out)")
+	cmd.Flags().BoolVar(&untranslatedOnly, "untranslated-only", false, "leave out strings already translated but not yet verified")
+	cmd.Flags().IntVar(&batchSize, "batch-size", config.DefaultConfig().Export.BatchSize, "SNPs loaded per batch")
+	return cmd
+}
+
+func newTranslationsImportCmd(opts *rootOptions) *cobra.Command {
+	var (
+		lang       string
+		format     string
+		translator string
+		asJSON     bool
+	)
+	cmd := &cobra.Command{
+		Use:   "import FILE",
+		Short: "Read completed translations back from an XLIFF or PO file",
+		Long: `Write the translations of FILE, an XLIFF 1.2 or gettext PO file written by
+"translations export", into the language it translates into. A translation
+the translator confirmed (XLIFF state translated, final or signed-off, or a
+PO entry not marked fuzzy) is recorded as reviewed by --translator, unless
+it lacks the fixed translation of a glossary term; others as machine drafts. Confirming an unchanged machine draft marks it reviewed.
+Verification stays with "translations review".
+
+Strings whose English text changed since the file was written are skipped
+and listed, as are those of SNPs or phenotypes since removed; export them
+again to translate the current text.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if translator == "" {
+				return errors.New("--translator is required")
+			}
+			format, err := translationFormat(format, args[0])
+			if err != nil {
+				return err
+			}
+			f, err := os.Open(args[0])
+			if err != nil {
+				return err
+			}
+			var (
+				fileLang string
+				units    []translate.Unit
+			)
+			if format == "xliff" {
+				fileLang, units, err = translate.ReadXLIFF(f)
+			} else {
+				fileLang, units, err = translate.ReadPO(f)
+			}
+			_ = f.Close()
+			if err != nil {
+				return fmt.Errorf("read %s: %w", args[0], err)
+			}
+			if lang != "" && lang != fileLang {
+				return fmt.Errorf("%s translates into %s, not %s", args[0], fileLang, lang)
+			}
+
+			db, err := opts.openDB()
+			if err != nil {
+				return err
+			}
+			defer func() {
+				_ = db.Close()
+			}()
+			stats, err := translate.Import(cmd.Context(), db, fileLang, translator, units)
+			if err != nil {
+				return fmt.Errorf("import translations: %w", err)
+			}
+
+			w := cmd.OutOrStdout()
+			if asJSON {
+				enc := json.NewEncoder(w)
+				enc.SetIndent("", "  ")
+				return enc.Encode(stats)
+			}
+			for _, key := range stats.Stale {
+				fmt.Fprintf(w, "stale: %s\n", key)
+			}
+			for _, v := range stats.Violations {
+				fmt.Fprintf(w, "glossary: %s: %q must be translated as %q\n", v.Key, v.Term, v.Translation)
+			}
+			for _, key := range stats.Malformed {
+				fmt.Fprintf(w, "malformed: %s: the translation lacks a placeholder such as {1}\n", key)
+			}
+			fmt.Fprintf(w, "Imported %d %s translations, confirmed %d; %d unchanged, %d untranslated, %d stale\n",
+				stats.Imported, fileLang, stats.Confirmed, stats.Unchanged, stats.Untranslated, len(stats.Stale))
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&lang, "lang", "", "language the file must translate into")
+	cmd.Flags().StringVar(&format, "format", "", "xliff or po (defaults to the extension of FILE)")
+	cmd.Flags().StringVar(&translator, "translator", "", "who translated the file")
+	cmd.Flags().BoolVar(&asJSON, "json", false, "write the counts as JSON")
+	return cmd
+}
+
+func newTranslationsSyncCmd(opts *rootOptions) *cobra.Command {
+	var (
+		langs     []string
+		pushOnly  bool
+		pullOnly  bool
+		asJSON    bool
+		batchSize int
+	)
+	cmd := &cobra.Command{
+		Use:   "sync",
+		Short: "Exchange strings with the Weblate or Crowdin project",
+		Long: `Pull the translations completed on the project configured under
+translation_sync into the database, recorded as by the platform, then push
+the project the strings it lacks. Confirmed Weblate strings and approved
+Crowdin ones come back reviewed, others as machine drafts.
+
+Strings whose English text changed since they were pushed are skipped as
+stale and pushed anew. The translation-sync stage of run and serve does the
+same on every run; set the platform's token in
+EXPORTER_TRANSLATION_SYNC_TOKEN.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if pushOnly && pullOnly {
+				return errors.New("--push-only and --pull-only are mutually exclusive")
+			}
+			cfg := opts.cfg.TranslationSync
+			if len(langs) > 0 {
+				cfg.Languages = langs
+			}
+			p, err := translate.NewPlatform(cfg)
+			if err != nil {
+				return fmt.Errorf("translation_sync: %w", err)
+			}
+			if !cmd.Flags().Changed("batch-size") {
+				batchSize = opts.cfg.Export.BatchSize
+			}
+			db, err := opts.openDB()
+			if err != nil {
+				return err
+			}
+			defer func() {
+				_ = db.Close()
+			}()
+
+			stats, err := translate.Sync(cmd.Context(), db, p, translate.SyncOptions{
+				Languages: cfg.Languages,
+				Push:      !pullOnly,
+				Pull:      !pushOnly,
+				BatchSize: batchSize,
+			})
+			if err != nil {
+				return err
+			}
+			w := cmd.OutOrStdout()
+			if asJSON {
+				enc := json.NewEncoder(w)
+				enc.SetIndent("", "  ")
+				return enc.Encode(stats)
+			}
+			for _, lang := range cfg.Languages {
+				pulled, ok := stats.Pulled[lang]
+				if !ok {
+					continue
+				}
+				for _, key := range pulled.Stale {
+					fmt.Fprintf(w, "stale: %s: %s\n", lang, key)
+				}
+				for _, v := range pulled.Violations {
+					fmt.Fprintf(w, "glossary: %s: %s: %q must be translated as %q\n", lang, v.Key, v.Term, v.Translation)
+				}
+				for _, key := range pulled.Malformed {
+					fmt.Fprintf(w, "malformed: %s: %s: the translation lacks a placeholder such as {1}\n", lang, key)
+				}
+				fmt.Fprintf(w, "Pulled %d %s translations, confirmed %d; %d unchanged, %d untranslated, %d stale\n",
+					pulled.Imported, lang, pulled.Confirmed, pulled.Unchanged, pulled.Untranslated, len(pulled.Stale))
+			}
+			if !pullOnly {
+				fmt.Fprintf(w, "Pushed %d strings to %s\n", stats.Pushed, stats.Platform)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringSliceVar(&langs, "lang", nil, "languages to sync, comma-separated, overriding translation_sync.languages")
+	cmd.Flags().BoolVar(&pushOnly, "push-only", false, "push strings without pulling translations")
+	cmd.Flags().BoolVar(&pullOnly, "pull-only", false, "pull translations without pushing strings")
+	cmd.Flags().BoolVar(&asJSON, "json", false, "write the counts as JSON")
+	cmd.Flags().IntVar(&batchSize, "batch-size", config.DefaultConfig().Export.BatchSize, "SNPs read per query while pushing")
+	return cmd
+}
+
+func newTranslationsResolveCmd(opts *rootOptions) *cobra.Command {
+	var lang string
+	cmd := &cobra.Command{
+		Use:   "resolve RSID",
+		Short: "Show the texts of a SNP in a language after falling back",
+		Long: `Write as JSON the fields of the SNP and the names of its phenotypes as a
+report in --lang shows them, each with the language of its fallback chain
+it was found in. Configure the chains under localization.fallbacks; a
+language without one falls back to its base language, then to English.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if lang == "" {
+				return errors.New("--lang is required")
+			}
+			db, err := opts.openDB()
+			if err != nil {
+				return err
+			}
+			defer func() {
+				_ = db.Close()
+			}()
+			ctx := cmd.Context()
+
+			snp, err := repositories.GetSNPByRsID(ctx, db, args[0])
+			if errors.Is(err, sql.ErrNoRows) {
+				return fmt.Errorf("%s not found", args[0])
+			} else if err != nil {
+				return err
+			}
+			resolver := repositories.NewTranslationResolver(repositories.NewBunRepositories(db).Translations, opts.cfg.Localization)
+			resolved, err := resolver.ResolveTranslations(ctx, snp.ID, lang)
+			if err != nil {
+				return fmt.Errorf("resolve translations: %w", err)
+			}
+			enc := json.NewEncoder(cmd.OutOrStdout())
+			enc.SetIndent("", "  ")
+			return enc.Encode(resolved)
+		},
+	}
+	cmd.Flags().StringVar(&lang, "lang", "", "language code to resolve into")
+	return cmd
+}
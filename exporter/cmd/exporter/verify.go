@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/mkoziy/genome/exporter/internal/verify"
+)
+
+func newVerifyCmd(opts *rootOptions) *cobra.Command {
+	var asJSON bool
+	cmd := &cobra.Command{
+		Use:   "verify",
+		Short: "Check database integrity and report malformed rows",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			db, err := opts.openDB()
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				return exitCode(verify.ExitError)
+			}
+			defer func() {
+				_ = db.Close()
+			}()
+
+			report, err := verify.Run(cmd.Context(), db)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "verify: %v\n", err)
+				return exitCode(verify.ExitError)
+			}
+
+			if asJSON {
+				err = report.WriteJSON(cmd.OutOrStdout())
+			} else {
+				err = report.WriteText(cmd.OutOrStdout())
+			}
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "write report: %v\n", err)
+				return exitCode(verify.ExitError)
+			}
+
+			if code := report.ExitCode(); code != 0 {
+				return exitCode(code)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&asJSON, "json", false, "write the report as JSON")
+	return cmd
+}
@@ -0,0 +1,202 @@
+// Package attribution builds the attribution manifest shipped with exports:
+// for every source the database holds data from, its version, terms of use,
+// when it was last fetched and how many records it contributed. Sources
+// differ in what they allow, from ClinVar's public domain data to SNPedia's
+// non-commercial share-alike licence, so whoever redistributes an export
+// needs to know which of them it contains.
+package attribution
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/uptrace/bun"
+
+	"github.com/mkoziy/genome/exporter/internal/models"
+	"github.com/mkoziy/genome/exporter/internal/repositories"
+)
+
+// Source is what the manifest says of one source.
+type Source struct {
+	Name  string `json:"name"`
+	Title string `json:"title"`
+	URL   string `json:"url"`
+	// Version is the source's release as last fetched, if known.
+	Version    string     `json:"version,omitempty"`
+	License    string     `json:"license,omitempty"`
+	TermsOfUse string     `json:"terms_of_use,omitempty"`
+	TermsURL   string     `json:"terms_url,omitempty"`
+	Conditions []string   `json:"conditions"`
+	Citation   string     `json:"citation,omitempty"`
+	AccessedAt *time.Time `json:"accessed_at,omitempty"`
+	// SNPs counts the distinct SNPs the source annotates; RecordCounts its
+	// rows per table.
+	SNPs         int             `json:"snps"`
+	RecordCounts models.CountMap `json:"record_counts"`
+}
+
+// Manifest lists the sources of a database by name.
+type Manifest struct {
+	GeneratedAt time.Time `json:"generated_at"`
+	// Release is the version of the database's latest recorded release.
+	Release string   `json:"release,omitempty"`
+	Sources []Source `json:"sources"`
+}
+
+// Build returns the manifest of db. It lists every source with rows in the
+// database and every active source recorded in data_sources. Terms stored
+// in data_sources take precedence over Known.
+func Build(ctx context.Context, db *bun.DB) (*Manifest, error) {
+	stored, err := repositories.ListSources(ctx, db)
+	if err != nil {
+		return nil, fmt.Errorf("list sources: %w", err)
+	}
+	counts, err := repositories.CountRecordsBySource(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	m := &Manifest{GeneratedAt: time.Now().UTC(), Sources: make([]Source, 0, len(counts))}
+	var versions models.StringMap
+	release, err := repositories.GetCurrentRelease(ctx, db)
+	switch {
+	case err == nil:
+		m.Release = release.Version
+		versions = release.SourceVersions
+	case !errors.Is(err, sql.ErrNoRows):
+		return nil, fmt.Errorf("release: %w", err)
+	}
+
+	byName := make(map[string]*models.SourceMetadata, len(stored))
+	for _, s := range stored {
+		byName[s.SourceName] = s
+	}
+	names := make([]string, 0, len(counts)+len(stored))
+	for source := range counts {
+		names = append(names, string(source))
+	}
+	for _, s := range stored {
+		if _, ok := counts[models.DataSource(s.SourceName)]; !ok && s.IsActive {
+			names = append(names, s.SourceName)
+		}
+	}
+	slices.Sort(names)
+
+	for _, name := range names {
+		terms := Known[models.DataSource(name)]
+		src := Source{
+			Name:         name,
+			Title:        cmp.Or(terms.Title, name),
+			URL:          terms.URL,
+			Version:      versions[name],
+			License:      terms.License,
+			TermsOfUse:   terms.TermsOfUse,
+			TermsURL:     terms.TermsURL,
+			Conditions:   slices.Clone(terms.Conditions),
+			Citation:     terms.Citation,
+			RecordCounts: make(models.CountMap),
+		}
+		if src.Conditions == nil {
+			src.Conditions = []string{}
+		}
+		if s, ok := byName[name]; ok {
+			src.URL = cmp.Or(s.SourceURL, src.URL)
+			if s.APIVersion != nil && *s.APIVersion != "" {
+				src.Version = *s.APIVersion
+			}
+			if s.TermsOfUse != nil && *s.TermsOfUse != "" {
+				src.TermsOfUse = *s.TermsOfUse
+			}
+			src.AccessedAt = s.LastAccessed
+		}
+		if c, ok := counts[models.DataSource(name)]; ok {
+			src.SNPs = c.SNPs
+			src.RecordCounts = c.Tables
+		}
+		m.Sources = append(m.Sources, src)
+	}
+	return m, nil
+}
+
+// RecordAccess records that source was fetched at the given time, storing
+// it in data_sources with its known URL and terms on first access.
+func RecordAccess(ctx context.Context, db bun.IDB, source models.DataSource, at time.Time) error {
+	terms := Known[source]
+	meta := &models.SourceMetadata{
+		SourceName:   string(source),
+		SourceURL:    terms.URL,
+		LastAccessed: &at,
+	}
+	if terms.Title != "" {
+		meta.Description = &terms.Title
+	}
+	if terms.TermsOfUse != "" {
+		meta.TermsOfUse = &terms.TermsOfUse
+	}
+	return repositories.RecordSourceAccess(ctx, db, meta)
+}
+
+// Restricted returns the sources whose terms put conditions beyond
+// attribution on redistribution, such as non-commercial use.
+func (m *Manifest) Restricted() []Source {
+	var list []Source
+	for _, s := range m.Sources {
+		if slices.ContainsFunc(s.Conditions, func(c string) bool { return c != ConditionAttribution }) {
+			list = append(list, s)
+		}
+	}
+	return list
+}
+
+// WriteJSON writes the manifest as JSON.
+func (m *Manifest) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(m)
+}
+
+// WriteText writes the manifest as a NOTICE file: one paragraph per source.
+func (m *Manifest) WriteText(w io.Writer) error {
+	var b strings.Builder
+	release := cmp.Or(m.Release, "unreleased")
+	fmt.Fprintf(&b, "This database (%s) contains data from the following sources.\n", release)
+	for _, s := range m.Sources {
+		fmt.Fprintf(&b, "\n%s", s.Title)
+		if s.Version != "" {
+			fmt.Fprintf(&b, " %s", s.Version)
+		}
+		if s.URL != "" {
+			fmt.Fprintf(&b, " <%s>", s.URL)
+		}
+		b.WriteString("\n")
+		fmt.Fprintf(&b, "  %d SNPs", s.SNPs)
+		if s.AccessedAt != nil {
+			fmt.Fprintf(&b, ", accessed %s", s.AccessedAt.UTC().Format(time.DateOnly))
+		}
+		b.WriteString("\n")
+		if s.License != "" {
+			fmt.Fprintf(&b, "  Licence: %s\n", s.License)
+		}
+		if s.TermsOfUse != "" {
+			fmt.Fprintf(&b, "  Terms: %s\n", s.TermsOfUse)
+		}
+		if s.TermsURL != "" {
+			fmt.Fprintf(&b, "  See %s\n", s.TermsURL)
+		}
+		if len(s.Conditions) > 0 {
+			fmt.Fprintf(&b, "  Conditions: %s\n", strings.ReplaceAll(strings.Join(s.Conditions, ", "), "_", "-"))
+		}
+		if s.Citation != "" {
+			fmt.Fprintf(&b, "  Cite: %s\n", s.Citation)
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
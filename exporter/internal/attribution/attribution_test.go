@@ -0,0 +1,132 @@
+package attribution
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/uptrace/bun"
+
+	"github.com/mkoziy/genome/exporter/internal/database"
+	"github.com/mkoziy/genome/exporter/internal/migrations"
+	"github.com/mkoziy/genome/exporter/internal/models"
+	"github.com/mkoziy/genome/exporter/internal/repositories"
+)
+
+func newTestDB(t *testing.T) *bun.DB {
+	t.Helper()
+	db, err := database.NewDB("file:"+t.Name()+"?mode=memory&cache=shared", false)
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	if err := migrations.RunMigrations(context.Background(), db); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	return db
+}
+
+func TestBuild(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+
+	snps := []*models.SNP{
+		{RsID: "rs1", Chromosome: "1", Position: 1, ReferenceAllele: "C", AlternateAlleles: models.StringArray{"T"}, VariantType: models.VariantSNV},
+		{RsID: "rs2", Chromosome: "1", Position: 2, ReferenceAllele: "C", AlternateAlleles: models.StringArray{"T"}, VariantType: models.VariantSNV},
+	}
+	if _, err := db.NewInsert().Model(&snps).Exec(ctx); err != nil {
+		t.Fatalf("insert snps: %v", err)
+	}
+	clinical := []*models.ClinicalData{
+		{SNPID: snps[0].ID, ClinicalSignificance: models.ClinicalPathogenic, ReviewStatus: models.ReviewCriteriaProvided, ConditionName: "A", Source: models.SourceClinVar},
+		{SNPID: snps[0].ID, ClinicalSignificance: models.ClinicalPathogenic, ReviewStatus: models.ReviewCriteriaProvided, ConditionName: "B", Source: models.SourceClinVar},
+		{SNPID: snps[1].ID, ClinicalSignificance: models.ClinicalPathogenic, ReviewStatus: models.ReviewCriteriaProvided, ConditionName: "A", Source: models.SourceClinVar},
+	}
+	if _, err := db.NewInsert().Model(&clinical).Exec(ctx); err != nil {
+		t.Fatalf("insert clinical: %v", err)
+	}
+	risk := &models.RiskAllele{SNPID: snps[0].ID, Allele: "T", Effect: "pathogenic", ConditionName: "A", Source: models.SourceClinVar}
+	if _, err := db.NewInsert().Model(risk).Exec(ctx); err != nil {
+		t.Fatalf("insert risk allele: %v", err)
+	}
+	phenotype := &models.Phenotype{SNPID: snps[1].ID, PhenotypeName: "Eye colour", AssociationType: "trait", Source: models.SourceSNPedia}
+	if _, err := db.NewInsert().Model(phenotype).Exec(ctx); err != nil {
+		t.Fatalf("insert phenotype: %v", err)
+	}
+
+	accessed := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	if err := RecordAccess(ctx, db, models.SourceClinVar, accessed.Add(-24*time.Hour)); err != nil {
+		t.Fatalf("record access: %v", err)
+	}
+	if err := RecordAccess(ctx, db, models.SourceClinVar, accessed); err != nil {
+		t.Fatalf("record access again: %v", err)
+	}
+	terms := "Licensed for non-commercial research."
+	cosmic := &models.SourceMetadata{SourceName: "cosmic", SourceURL: "https://cancer.sanger.ac.uk/cosmic", TermsOfUse: &terms, IsActive: true}
+	if _, err := db.NewInsert().Model(cosmic).Exec(ctx); err != nil {
+		t.Fatalf("insert source: %v", err)
+	}
+	if _, err := repositories.CreateRelease(ctx, db, "1.3.0", map[string]string{"clinvar": "2026-02"}); err != nil {
+		t.Fatalf("create release: %v", err)
+	}
+
+	m, err := Build(ctx, db)
+	if err != nil {
+		t.Fatalf("build: %v", err)
+	}
+	if m.Release != "1.3.0" || len(m.Sources) != 3 {
+		t.Fatalf("expected release 1.3.0 with three sources, got %+v", m)
+	}
+	clinvar, other, snpedia := m.Sources[0], m.Sources[1], m.Sources[2]
+	if clinvar.Name != "clinvar" || clinvar.Title != "ClinVar" || clinvar.Version != "2026-02" {
+		t.Fatalf("unexpected ClinVar entry: %+v", clinvar)
+	}
+	if clinvar.SNPs != 2 || clinvar.RecordCounts["snp_clinical"] != 3 || clinvar.RecordCounts["risk_alleles"] != 1 {
+		t.Fatalf("expected 2 SNPs, 3 clinical rows and 1 risk allele from ClinVar, got %d %v", clinvar.SNPs, clinvar.RecordCounts)
+	}
+	if clinvar.AccessedAt == nil || !clinvar.AccessedAt.Equal(accessed) {
+		t.Fatalf("expected ClinVar accessed at %v, got %v", accessed, clinvar.AccessedAt)
+	}
+	if other.Name != "cosmic" || other.TermsOfUse != terms || other.URL != cosmic.SourceURL || other.SNPs != 0 {
+		t.Fatalf("expected the stored terms of a source without rows, got %+v", other)
+	}
+	if snpedia.License != "CC-BY-NC-SA-3.0-US" || snpedia.SNPs != 1 || snpedia.AccessedAt != nil {
+		t.Fatalf("unexpected SNPedia entry: %+v", snpedia)
+	}
+
+	restricted := m.Restricted()
+	if len(restricted) != 1 || restricted[0].Name != "snpedia" {
+		t.Fatalf("expected only SNPedia to restrict redistribution, got %+v", restricted)
+	}
+	var text bytes.Buffer
+	if err := m.WriteText(&text); err != nil {
+		t.Fatalf("write text: %v", err)
+	}
+	if !strings.Contains(text.String(), "ClinVar 2026-02 <https://www.ncbi.nlm.nih.gov/clinvar/>\n  2 SNPs, accessed 2026-03-01\n") {
+		t.Fatalf("unexpected text:\n%s", text.String())
+	}
+}
+
+func TestRecordAccessKeepsCuratedTerms(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+
+	terms := "Curated terms."
+	version := "v3"
+	src := &models.SourceMetadata{SourceName: "gnomad", SourceURL: "https://example.org/gnomad", APIVersion: &version, TermsOfUse: &terms, IsActive: true}
+	if _, err := db.NewInsert().Model(src).Exec(ctx); err != nil {
+		t.Fatalf("insert source: %v", err)
+	}
+	if err := RecordAccess(ctx, db, models.SourceGnomAD, time.Now()); err != nil {
+		t.Fatalf("record access: %v", err)
+	}
+	stored, err := repositories.ListSources(ctx, db)
+	if err != nil {
+		t.Fatalf("list sources: %v", err)
+	}
+	if len(stored) != 1 || stored[0].LastAccessed == nil || *stored[0].TermsOfUse != terms || stored[0].SourceURL != src.SourceURL {
+		t.Fatalf("expected only last_accessed updated, got %+v", stored)
+	}
+}
@@ -0,0 +1,109 @@
+package attribution
+
+import "github.com/mkoziy/genome/exporter/internal/models"
+
+// Conditions a source's terms put on redistributing its data.
+const (
+	// ConditionAttribution requires crediting the source.
+	ConditionAttribution = "attribution"
+	// ConditionShareAlike requires licensing derived data under the same
+	// terms.
+	ConditionShareAlike = "share_alike"
+	// ConditionNonCommercial forbids commercial use without a separate
+	// licence from the source.
+	ConditionNonCommercial = "non_commercial"
+)
+
+// Terms are what the manifest says of a source the database does not
+// describe itself.
+type Terms struct {
+	Title string
+	URL   string
+	// License is an SPDX identifier where the source uses a standard
+	// licence.
+	License    string
+	TermsOfUse string
+	TermsURL   string
+	Conditions []string
+	Citation   string
+}
+
+// Known lists the terms of the sources the exporter imports. They summarize
+// the published terms as of writing; the terms URL is authoritative.
+var Known = map[models.DataSource]Terms{
+	models.SourceClinVar: {
+		Title:      "ClinVar",
+		URL:        "https://www.ncbi.nlm.nih.gov/clinvar/",
+		TermsOfUse: "NCBI places no restrictions on the use or distribution of ClinVar data. Submitters may claim rights to their submissions. Do not imply endorsement by NCBI or NLM.",
+		TermsURL:   "https://www.ncbi.nlm.nih.gov/home/about/policies/",
+		Conditions: []string{ConditionAttribution},
+		Citation:   "Landrum MJ et al. ClinVar: improvements to accessing data. Nucleic Acids Res. 2020;48(D1):D835-D844.",
+	},
+	models.SourceDbSNP: {
+		Title:      "dbSNP",
+		URL:        "https://www.ncbi.nlm.nih.gov/snp/",
+		TermsOfUse: "NCBI places no restrictions on the use or distribution of dbSNP data. Do not imply endorsement by NCBI or NLM.",
+		TermsURL:   "https://www.ncbi.nlm.nih.gov/home/about/policies/",
+		Conditions: []string{ConditionAttribution},
+		Citation:   "Sherry ST et al. dbSNP: the NCBI database of genetic variation. Nucleic Acids Res. 2001;29(1):308-311.",
+	},
+	models.SourceOpenSNP: {
+		Title:      "openSNP",
+		URL:        "https://opensnp.org/",
+		License:    "CC0-1.0",
+		TermsOfUse: "Genotypes and phenotypes are published by their owners into the public domain.",
+		TermsURL:   "https://opensnp.org/disclaimer",
+		Conditions: []string{},
+	},
+	models.SourcePharmGKB: {
+		Title:      "PharmGKB",
+		URL:        "https://www.pharmgkb.org/",
+		License:    "CC-BY-SA-4.0",
+		TermsOfUse: "Data may be redistributed with attribution under the same licence.",
+		TermsURL:   "https://www.pharmgkb.org/page/dataUsagePolicy",
+		Conditions: []string{ConditionAttribution, ConditionShareAlike},
+		Citation:   "Whirl-Carrillo M et al. An evidence-based framework for evaluating pharmacogenomics knowledge for personalized medicine. Clin Pharmacol Ther. 2021;110(3):563-572.",
+	},
+	models.SourceSNPedia: {
+		Title:      "SNPedia",
+		URL:        "https://www.snpedia.com/",
+		License:    "CC-BY-NC-SA-3.0-US",
+		TermsOfUse: "Content may be redistributed with attribution under the same licence, for non-commercial use only; commercial use requires a licence from SNPedia.",
+		TermsURL:   "https://www.snpedia.com/index.php/SNPedia:Copyrights",
+		Conditions: []string{ConditionAttribution, ConditionShareAlike, ConditionNonCommercial},
+		Citation:   "Cariaso M, Lennon G. SNPedia: a wiki supporting personal genome annotation, interpretation and analysis. Nucleic Acids Res. 2012;40(D1):D1308-D1312.",
+	},
+	models.SourceGnomAD: {
+		Title:      "gnomAD",
+		URL:        "https://gnomad.broadinstitute.org/",
+		License:    "CC0-1.0",
+		TermsOfUse: "Data are released without restrictions; citing gnomAD is requested.",
+		TermsURL:   "https://gnomad.broadinstitute.org/terms",
+		Conditions: []string{},
+		Citation:   "Chen S et al. A genomic mutational constraint map using variation in 76,156 human genomes. Nature. 2024;625:92-100.",
+	},
+	models.SourceGWAS: {
+		Title:      "NHGRI-EBI GWAS Catalog",
+		URL:        "https://www.ebi.ac.uk/gwas/",
+		TermsOfUse: "Data are freely available under the EMBL-EBI terms of use; cite the GWAS Catalog.",
+		TermsURL:   "https://www.ebi.ac.uk/about/terms-of-use/",
+		Conditions: []string{ConditionAttribution},
+		Citation:   "Sollis E et al. The NHGRI-EBI GWAS Catalog: knowledgebase and deposition resource. Nucleic Acids Res. 2023;51(D1):D977-D985.",
+	},
+	models.SourceThousandGenomes: {
+		Title:      "1000 Genomes Project",
+		URL:        "https://www.internationalgenome.org/",
+		TermsOfUse: "Data are released without restrictions on use or redistribution; citing the project is requested.",
+		TermsURL:   "https://www.internationalgenome.org/IGSR_disclaimer",
+		Conditions: []string{},
+		Citation:   "The 1000 Genomes Project Consortium. A global reference for human genetic variation. Nature. 2015;526:68-74.",
+	},
+	models.SourceALFA: {
+		Title:      "ALFA: Allele Frequency Aggregator",
+		URL:        "https://www.ncbi.nlm.nih.gov/snp/docs/gsr/alfa/",
+		TermsOfUse: "NCBI places no restrictions on the use or distribution of ALFA data. Do not imply endorsement by NCBI or NLM.",
+		TermsURL:   "https://www.ncbi.nlm.nih.gov/home/about/policies/",
+		Conditions: []string{ConditionAttribution},
+		Citation:   "Phan L et al. ALFA: Allele Frequency Aggregator. National Center for Biotechnology Information, U.S. National Library of Medicine, 2020.",
+	},
+}
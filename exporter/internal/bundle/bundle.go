@@ -0,0 +1,211 @@
+// Package bundle writes and verifies the manifest shipped with distribution
+// artifacts: the SHA-256 checksum of every file, optionally signed with an
+// Ed25519 key, so apps embedding the database can check at startup that it
+// is intact and was published by whoever holds the key.
+//
+// The signature is over the exact bytes of the manifest file and is stored
+// base64-encoded next to it, in <manifest>.sig, so verifying it needs
+// nothing but an Ed25519 implementation; the checksums are then read from
+// the verified bytes.
+package bundle
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// FormatVersion is the version of the manifest format.
+const FormatVersion = 1
+
+// SignatureSuffix is appended to the manifest's path to name its signature.
+const SignatureSuffix = ".sig"
+
+// File is a file of a bundle. Path is relative to the manifest's directory
+// and uses forward slashes.
+type File struct {
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// Manifest lists the files of a bundle with their checksums.
+type Manifest struct {
+	FormatVersion int       `json:"format_version"`
+	CreatedAt     time.Time `json:"created_at"`
+	// KeyID identifies the key the manifest is signed with, when it is.
+	KeyID string `json:"key_id,omitempty"`
+	Files []File `json:"files"`
+}
+
+// Build checksums the files at paths, which are relative to base or under
+// it, except those excluded, e.g. the manifest itself. Directories are
+// walked.
+func Build(base string, paths []string, exclude ...string) (*Manifest, error) {
+	m := &Manifest{FormatVersion: FormatVersion, CreatedAt: time.Now().UTC().Truncate(time.Second), Files: []File{}}
+	base, err := filepath.Abs(base)
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	for _, p := range exclude {
+		if !filepath.IsAbs(p) {
+			p = filepath.Join(base, p)
+		}
+		if rel, err := filepath.Rel(base, p); err == nil {
+			seen[filepath.ToSlash(rel)] = true
+		}
+	}
+	for _, p := range paths {
+		if !filepath.IsAbs(p) {
+			p = filepath.Join(base, p)
+		}
+		err := filepath.WalkDir(p, func(path string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return err
+			}
+			rel, err := filepath.Rel(base, path)
+			if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+				return fmt.Errorf("%s is not under %s", path, base)
+			}
+			rel = filepath.ToSlash(rel)
+			if seen[rel] {
+				return nil
+			}
+			seen[rel] = true
+			f, err := checksum(path)
+			if err != nil {
+				return err
+			}
+			f.Path = rel
+			m.Files = append(m.Files, f)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	slices.SortFunc(m.Files, func(a, b File) int { return strings.Compare(a.Path, b.Path) })
+	return m, nil
+}
+
+func checksum(path string) (File, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return File{}, err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	h := sha256.New()
+	n, err := io.Copy(h, f)
+	if err != nil {
+		return File{}, fmt.Errorf("read %s: %w", path, err)
+	}
+	return File{Size: n, SHA256: hex.EncodeToString(h.Sum(nil))}, nil
+}
+
+// Write writes m to path and, with key set, its signature to
+// path+SignatureSuffix. A stale signature of an unsigned manifest is
+// removed.
+func (m *Manifest) Write(path string, key ed25519.PrivateKey) error {
+	m.KeyID = ""
+	if key != nil {
+		m.KeyID = KeyID(key.Public().(ed25519.PublicKey))
+	}
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return err
+	}
+	sigPath := path + SignatureSuffix
+	if key == nil {
+		if err := os.Remove(sigPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+	sig := base64.StdEncoding.EncodeToString(ed25519.Sign(key, data))
+	return os.WriteFile(sigPath, []byte(sig+"\n"), 0o644)
+}
+
+// Result is the outcome of verifying a bundle.
+type Result struct {
+	Manifest *Manifest `json:"manifest"`
+	// Signed reports whether the signature was checked and is valid.
+	Signed bool `json:"signed"`
+	// Problems lists the files missing or differing from the manifest.
+	Problems []string `json:"problems,omitempty"`
+}
+
+// OK reports whether every file matches the manifest.
+func (r *Result) OK() bool {
+	return len(r.Problems) == 0
+}
+
+// ErrSignature is returned by Verify when the signature is missing or does
+// not match the manifest.
+var ErrSignature = errors.New("invalid signature")
+
+// Verify reads the manifest at path and checks the files it lists, relative
+// to its directory. With key set the signature must be valid, or
+// ErrSignature is returned without checking any file. Files that are
+// missing or differ are reported as the Result's problems.
+func Verify(path string, key ed25519.PublicKey) (*Result, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	res := &Result{}
+	if key != nil {
+		encoded, err := os.ReadFile(path + SignatureSuffix)
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("%w: %s%s not found", ErrSignature, path, SignatureSuffix)
+		} else if err != nil {
+			return nil, err
+		}
+		sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(encoded)))
+		if err != nil || !ed25519.Verify(key, data, sig) {
+			return nil, fmt.Errorf("%w: %s is not signed by key %s", ErrSignature, path, KeyID(key))
+		}
+		res.Signed = true
+	}
+
+	if err := json.Unmarshal(data, &res.Manifest); err != nil {
+		return nil, fmt.Errorf("parse manifest: %w", err)
+	}
+	if res.Manifest.FormatVersion > FormatVersion {
+		return nil, fmt.Errorf("manifest format version %d is newer than supported (%d)", res.Manifest.FormatVersion, FormatVersion)
+	}
+	dir := filepath.Dir(path)
+	for _, want := range res.Manifest.Files {
+		if !filepath.IsLocal(filepath.FromSlash(want.Path)) {
+			res.Problems = append(res.Problems, fmt.Sprintf("%s: path escapes the bundle", want.Path))
+			continue
+		}
+		got, err := checksum(filepath.Join(dir, filepath.FromSlash(want.Path)))
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+			res.Problems = append(res.Problems, fmt.Sprintf("%s: missing", want.Path))
+		case err != nil:
+			return nil, err
+		case got.Size != want.Size || got.SHA256 != want.SHA256:
+			res.Problems = append(res.Problems, fmt.Sprintf("%s: checksum mismatch", want.Path))
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,106 @@
+package bundle
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeFile(t *testing.T, path, data string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatalf("write %s: %v", path, err)
+	}
+}
+
+func TestSignedManifestRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "genome-mobile.db"), "sqlite")
+	writeFile(t, filepath.Join(dir, "extra", "attribution.json"), "{}")
+	manifest := filepath.Join(dir, "manifest.json")
+	writeFile(t, manifest, "stale")
+
+	keys := t.TempDir()
+	pub, err := GenerateKey(filepath.Join(keys, "release.key"), filepath.Join(keys, "release.pub"))
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	if _, err := GenerateKey(filepath.Join(keys, "release.key"), filepath.Join(keys, "other.pub")); err == nil {
+		t.Error("expected an existing key not to be overwritten")
+	}
+	priv, err := ReadPrivateKey(filepath.Join(keys, "release.key"))
+	if err != nil {
+		t.Fatalf("read private key: %v", err)
+	}
+	if read, err := ReadPublicKey(filepath.Join(keys, "release.pub")); err != nil || !read.Equal(pub) {
+		t.Fatalf("expected the public key read back, got %v", err)
+	}
+
+	m, err := Build(dir, []string{"."}, manifest)
+	if err != nil {
+		t.Fatalf("build: %v", err)
+	}
+	if len(m.Files) != 2 || m.Files[0].Path != "extra/attribution.json" || m.Files[1].Size != 6 {
+		t.Fatalf("unexpected files: %+v", m.Files)
+	}
+	if err := m.Write(manifest, priv); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	res, err := Verify(manifest, pub)
+	if err != nil || !res.Signed || !res.OK() || res.Manifest.KeyID != KeyID(pub) {
+		t.Fatalf("expected a valid signed bundle, got %+v (%v)", res, err)
+	}
+
+	writeFile(t, filepath.Join(dir, "genome-mobile.db"), "tampered")
+	if err := os.Remove(filepath.Join(dir, "extra", "attribution.json")); err != nil {
+		t.Fatal(err)
+	}
+	res, err = Verify(manifest, pub)
+	if err != nil || len(res.Problems) != 2 {
+		t.Fatalf("expected a changed and a missing file, got %+v (%v)", res, err)
+	}
+
+	other, err := GenerateKey(filepath.Join(keys, "other.key"), filepath.Join(keys, "other.pub"))
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	if _, err := Verify(manifest, other); !errors.Is(err, ErrSignature) {
+		t.Errorf("expected a signature by another key rejected, got %v", err)
+	}
+	writeFile(t, manifest, `{"format_version":1,"files":[]}`)
+	if _, err := Verify(manifest, pub); !errors.Is(err, ErrSignature) {
+		t.Errorf("expected an edited manifest rejected, got %v", err)
+	}
+}
+
+func TestUnsignedManifest(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "genome.db"), "sqlite")
+	manifest := filepath.Join(dir, "genome.db.manifest.json")
+	writeFile(t, manifest+SignatureSuffix, "stale")
+
+	m, err := Build(dir, []string{filepath.Join(dir, "genome.db")})
+	if err != nil {
+		t.Fatalf("build: %v", err)
+	}
+	if err := m.Write(manifest, nil); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if _, err := os.Stat(manifest + SignatureSuffix); !errors.Is(err, os.ErrNotExist) {
+		t.Error("expected the stale signature removed")
+	}
+	res, err := Verify(manifest, nil)
+	if err != nil || res.Signed || !res.OK() {
+		t.Fatalf("expected a valid unsigned bundle, got %+v (%v)", res, err)
+	}
+	outside := filepath.Join(t.TempDir(), "other.db")
+	writeFile(t, outside, "sqlite")
+	if _, err := Build(dir, []string{outside}); err == nil {
+		t.Error("expected files outside the base rejected")
+	}
+}
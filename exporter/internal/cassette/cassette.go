@@ -0,0 +1,227 @@
+package cassette
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+// RecordEnv is the environment variable that makes Open record cassettes
+// against the real APIs instead of replaying them, e.g.
+//
+//	RECORD_CASSETTES=1 go test ./internal/sources/clinvar
+const RecordEnv = "RECORD_CASSETTES"
+
+// Mode selects whether a Recorder talks to the network.
+type Mode int
+
+const (
+	// ModeReplay answers requests from the cassette and fails on any request it
+	// does not contain.
+	ModeReplay Mode = iota
+	// ModeRecord passes requests to the real transport and records them.
+	ModeRecord
+)
+
+// secretParams are removed from recorded URLs and ignored when matching, so
+// cassettes never contain credentials and replay whichever ones are set.
+var secretParams = []string{"api_key", "email"}
+
+// recordedHeaders are the response headers kept in a cassette; the rest vary
+// per request and would make recordings noisy.
+var recordedHeaders = []string{"Content-Type", "ETag", "Last-Modified", "Retry-After"}
+
+// Interaction is one recorded request and its response.
+type Interaction struct {
+	Method string            `yaml:"method"`
+	URL    string            `yaml:"url"`
+	Status int               `yaml:"status"`
+	Header map[string]string `yaml:"header,omitempty"`
+	Body   string            `yaml:"body"`
+}
+
+// Cassette is the file format: interactions in the order they were recorded.
+type Cassette struct {
+	Interactions []*Interaction `yaml:"interactions"`
+}
+
+// Recorder is an http.RoundTripper that records or replays a cassette. Replay
+// matches requests by method and URL, ignoring parameter order, and hands out
+// identical requests' responses in recorded order, so tests stay deterministic
+// when requests are made concurrently.
+type Recorder struct {
+	// Real makes requests in ModeRecord; nil means http.DefaultTransport.
+	Real http.RoundTripper
+
+	path string
+	mode Mode
+
+	mu       sync.Mutex
+	cassette Cassette
+	used     []bool
+}
+
+// New returns a Recorder for the cassette at path. In ModeReplay the cassette
+// must exist.
+func New(path string, mode Mode) (*Recorder, error) {
+	r := &Recorder{path: path, mode: mode}
+	if mode == ModeRecord {
+		return r, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read cassette: %w", err)
+	}
+	if err := yaml.Unmarshal(data, &r.cassette); err != nil {
+		return nil, fmt.Errorf("parse cassette %s: %w", path, err)
+	}
+	r.used = make([]bool, len(r.cassette.Interactions))
+	return r, nil
+}
+
+// Open returns a Recorder for a test, recording when RecordEnv is set and
+// replaying otherwise. Recordings are saved when the test finishes.
+func Open(t testing.TB, path string) *Recorder {
+	t.Helper()
+	mode := ModeReplay
+	if os.Getenv(RecordEnv) != "" {
+		mode = ModeRecord
+	}
+	r, err := New(path, mode)
+	if err != nil {
+		t.Fatalf("open cassette (set %s=1 to record it): %v", RecordEnv, err)
+	}
+	t.Cleanup(func() {
+		if err := r.Save(); err != nil {
+			t.Errorf("save cassette: %v", err)
+		}
+	})
+	return r
+}
+
+// RoundTrip implements http.RoundTripper.
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	if r.mode == ModeRecord {
+		return r.record(req)
+	}
+	return r.replay(req)
+}
+
+func (r *Recorder) replay(req *http.Request) (*http.Response, error) {
+	u := redact(req.URL)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, in := range r.cassette.Interactions {
+		if r.used[i] || in.Method != req.Method || !sameURL(in.URL, u) {
+			continue
+		}
+		r.used[i] = true
+		return in.response(req), nil
+	}
+	return nil, fmt.Errorf("cassette %s has no unused interaction for %s %s", r.path, req.Method, u)
+}
+
+func (r *Recorder) record(req *http.Request) (*http.Response, error) {
+	base := r.Real
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	resp, err := base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	body, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	in := &Interaction{Method: req.Method, URL: redact(req.URL), Status: resp.StatusCode, Body: string(body)}
+	for _, name := range recordedHeaders {
+		if value := resp.Header.Get(name); value != "" {
+			if in.Header == nil {
+				in.Header = make(map[string]string)
+			}
+			in.Header[name] = value
+		}
+	}
+
+	r.mu.Lock()
+	r.cassette.Interactions = append(r.cassette.Interactions, in)
+	r.mu.Unlock()
+
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	return resp, nil
+}
+
+// Save writes the recorded interactions to the cassette file. It does nothing
+// in ModeReplay.
+func (r *Recorder) Save() error {
+	if r.mode != ModeRecord {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.cassette.Interactions) == 0 {
+		return errors.New("nothing was recorded")
+	}
+
+	data, err := yaml.Marshal(&r.cassette)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(r.path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(r.path, data, 0o644)
+}
+
+func (in *Interaction) response(req *http.Request) *http.Response {
+	header := make(http.Header, len(in.Header))
+	for name, value := range in.Header {
+		header.Set(name, value)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", in.Status, http.StatusText(in.Status)),
+		StatusCode:    in.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader([]byte(in.Body))),
+		ContentLength: int64(len(in.Body)),
+		Request:       req,
+	}
+}
+
+// redact returns u without secret parameters, with the rest sorted.
+func redact(u *url.URL) string {
+	query := u.Query()
+	for _, param := range secretParams {
+		query.Del(param)
+	}
+	redacted := *u
+	redacted.RawQuery = query.Encode()
+	return redacted.String()
+}
+
+// sameURL compares a recorded URL with a redacted one. Recorded URLs are
+// normalized again so hand-edited cassettes may list parameters in any order.
+func sameURL(recorded, redacted string) bool {
+	u, err := url.Parse(recorded)
+	if err != nil {
+		return false
+	}
+	return redact(u) == redacted
+}
@@ -0,0 +1,78 @@
+package cassette
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func get(t *testing.T, client *http.Client, url string) string {
+	t.Helper()
+	resp, err := client.Get(url)
+	if err != nil {
+		t.Fatalf("get %s: %v", url, err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	return string(body)
+}
+
+func TestRecordThenReplay(t *testing.T) {
+	calls := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Content-Type", "text/plain")
+		w.Header().Set("X-Request-Id", "varies")
+		_, _ = io.WriteString(w, r.URL.Query().Get("id")+"#"+string(rune('0'+calls)))
+	}))
+	path := filepath.Join(t.TempDir(), "session.yaml")
+
+	rec, err := New(path, ModeRecord)
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	client := &http.Client{Transport: rec}
+	get(t, client, ts.URL+"/efetch?id=1&api_key=secret")
+	get(t, client, ts.URL+"/efetch?id=1&api_key=secret")
+	get(t, client, ts.URL+"/efetch?id=2")
+	if err := rec.Save(); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	ts.Close()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read cassette: %v", err)
+	}
+	if strings.Contains(string(data), "secret") || strings.Contains(string(data), "X-Request-Id") {
+		t.Fatalf("cassette kept a secret or a volatile header:\n%s", data)
+	}
+
+	rec, err = New(path, ModeReplay)
+	if err != nil {
+		t.Fatalf("replay: %v", err)
+	}
+	client = &http.Client{Transport: rec}
+	// Replay ignores parameter order and credentials, and returns repeated
+	// requests' responses in recorded order.
+	got := []string{
+		get(t, client, ts.URL+"/efetch?id=2"),
+		get(t, client, ts.URL+"/efetch?api_key=other&id=1"),
+		get(t, client, ts.URL+"/efetch?id=1"),
+	}
+	if strings.Join(got, ",") != "2#3,1#1,1#2" {
+		t.Fatalf("unexpected replay: %v", got)
+	}
+	if _, err := client.Get(ts.URL + "/efetch?id=1"); err == nil {
+		t.Fatalf("expected an error once the recorded interactions are used up")
+	}
+}
@@ -0,0 +1,452 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/mkoziy/genome/exporter/internal/httpcache"
+	"github.com/mkoziy/genome/exporter/internal/liftover"
+	"github.com/mkoziy/genome/exporter/internal/logging"
+	"github.com/mkoziy/genome/exporter/internal/notify"
+	"github.com/mkoziy/genome/exporter/internal/pipeline"
+	"github.com/mkoziy/genome/exporter/internal/profile"
+	"github.com/mkoziy/genome/exporter/internal/ratelimit"
+	"github.com/mkoziy/genome/exporter/internal/repositories"
+	"github.com/mkoziy/genome/exporter/internal/scoring"
+	"github.com/mkoziy/genome/exporter/internal/search"
+	"github.com/mkoziy/genome/exporter/internal/sources"
+	"github.com/mkoziy/genome/exporter/internal/sources/clinvar"
+	"github.com/mkoziy/genome/exporter/internal/tracing"
+	"github.com/mkoziy/genome/exporter/internal/translate"
+)
+
+// Sources are the built-in sources, configured by default. Sources
+// registered with the sources package may be configured as well and run only
+// when they are.
+var Sources = []string{"clinvar"}
+
+// knownSources lists the built-in and then the registered sources.
+func knownSources() []string {
+	return append(slices.Clone(Sources), sources.Names()...)
+}
+
+// ncbiSources use NCBI E-utilities and so also read NCBI_API_KEY and NCBI_EMAIL.
+var ncbiSources = map[string]bool{"clinvar": true}
+
+// ExportFormats are the formats export.format accepts.
+var ExportFormats = []string{"jsonl", "csv", "sqlite"}
+
+// Config is the whole application configuration, loaded from one YAML file:
+//
+//	database:
+//	  dsn: genome.db
+//	profile: clinical
+//	sources:
+//	  clinvar:
+//	    queries: ['BRCA1[gene] AND "pathogenic"[CLNSIG]']
+//	    rate_limit: {strategy: token_bucket, requests_per_second: 10}
+//	    workers: 4
+//	writer: {chunk_size: 1000}
+//	error_budget: {max_failure_rate: 0.05, max_consecutive_request_errors: 100}
+//	plan_budget: {max_requests: 50000, max_duration: 6h}
+//	scoring: {recency_half_life_years: 8}
+//	export: {format: csv}
+//	search: {url: 'https://search.example.org:9200', index: snps, username: exporter}
+//	translation: {provider: deepl, batch_size: 50, rate_limit: {requests_per_second: 2}}
+//	translation_sync: {platform: weblate, url: 'https://hosted.weblate.org', project: genome, component: snps, languages: [de, uk]}
+//	liftover: {chain_file: /data/hg19ToHg38.over.chain.gz}
+//	log: {format: json, level: debug}
+//	tracing: {exporter: otlp, endpoint: 'tempo:4318', insecure: true}
+//	notifications:
+//	  on: failure
+//	  slack: ['https://hooks.slack.com/services/...']
+//	  email: {smtp_addr: 'smtp.example.org:587', from: exporter@example.org, to: [ops@example.org]}
+//
+// Omitted settings take the defaults of DefaultConfig.
+type Config struct {
+	Database DatabaseConfig `yaml:"database" json:"database"`
+	// Profile names the dataset build profile selecting the queries of
+	// sources without their own and the stages runs include.
+	Profile string                         `yaml:"profile" json:"profile"`
+	Sources map[string]SourceConfig        `yaml:"sources" json:"sources"`
+	Writer  repositories.BatchWriterConfig `yaml:"writer" json:"writer"`
+	// ErrorBudget aborts a run whose sources fail too often. Negative values
+	// disable a check.
+	ErrorBudget pipeline.ErrorBudget `yaml:"error_budget" json:"error_budget"`
+	// PlanBudget fails a run before it starts if a source is estimated to
+	// need more requests or time than allowed. Unset, runs are not planned.
+	PlanBudget pipeline.PlanBudget `yaml:"plan_budget" json:"plan_budget"`
+	HTTPCache  HTTPCacheConfig     `yaml:"http_cache" json:"http_cache"`
+	Scoring    scoring.Config      `yaml:"scoring" json:"scoring"`
+	Export     ExportConfig        `yaml:"export" json:"export"`
+	// Search is the Elasticsearch or OpenSearch index export-search writes.
+	Search search.Config `yaml:"search" json:"search"`
+	// Localization sets the languages text not translated into the
+	// language of a report falls back to.
+	Localization repositories.LocalizationConfig `yaml:"localization" json:"localization"`
+	// Translation is the machine translation provider the translate command
+	// drafts translations with.
+	Translation translate.MachineConfig `yaml:"translation" json:"translation"`
+	// TranslationSync is the Weblate or Crowdin project the
+	// translation-sync stage and translations sync exchange strings with.
+	TranslationSync translate.SyncConfig `yaml:"translation_sync" json:"translation_sync"`
+	// Liftover is the chain file the liftover stage fills in GRCh37
+	// coordinates with, and GRCh37 input is converted to GRCh38 with.
+	Liftover liftover.Config `yaml:"liftover" json:"liftover"`
+	// Notifications report how each run and scheduled job ended.
+	Notifications notify.Config `yaml:"notifications" json:"notifications"`
+	// Log selects the format and level of the log written to stderr.
+	Log logging.Config `yaml:"log" json:"log"`
+	// Tracing exports spans of runs to an OpenTelemetry collector.
+	Tracing tracing.Config `yaml:"tracing" json:"tracing"`
+}
+
+// DatabaseConfig selects the SQLite database.
+type DatabaseConfig struct {
+	DSN   string `yaml:"dsn" json:"dsn"`
+	Debug bool   `yaml:"debug" json:"debug"`
+}
+
+// SourceConfig configures one data source.
+type SourceConfig struct {
+	// Enabled defaults to true; a disabled source's pipeline stage is skipped.
+	Enabled *bool `yaml:"enabled" json:"enabled,omitempty"`
+	// Queries replace the source's built-in search queries when set.
+	Queries   []string         `yaml:"queries" json:"queries,omitempty"`
+	RateLimit ratelimit.Config `yaml:"rate_limit" json:"rate_limit"`
+	APIKey    string           `yaml:"api_key" json:"-"`
+	Email     string           `yaml:"email" json:"email,omitempty"`
+	Workers   int              `yaml:"workers" json:"workers"`
+	// Options are settings specific to a registered source.
+	Options map[string]any `yaml:"options" json:"options,omitempty"`
+}
+
+// IsEnabled reports whether the source runs.
+func (s SourceConfig) IsEnabled() bool {
+	return s.Enabled == nil || *s.Enabled
+}
+
+// HTTPCacheConfig configures the on-disk response cache shared by sources.
+// An empty Dir disables it.
+type HTTPCacheConfig struct {
+	Dir    string        `yaml:"dir" json:"dir"`
+	MaxAge time.Duration `yaml:"max_age" json:"max_age"`
+}
+
+// ExportConfig holds the defaults of the export command.
+type ExportConfig struct {
+	Format    string `yaml:"format" json:"format"`
+	BatchSize int    `yaml:"batch_size" json:"batch_size"`
+}
+
+// DefaultConfig returns the configuration used when none is supplied.
+func DefaultConfig() Config {
+	sources := make(map[string]SourceConfig, len(Sources))
+	for _, name := range Sources {
+		sources[name] = SourceConfig{RateLimit: ratelimit.DefaultConfig(), Workers: clinvar.DefaultWorkers}
+	}
+	return Config{
+		Database:    DatabaseConfig{DSN: "genome.db"},
+		Profile:     profile.Full,
+		Sources:     sources,
+		Writer:      repositories.DefaultBatchWriterConfig(),
+		ErrorBudget: pipeline.DefaultErrorBudget(),
+		HTTPCache:   HTTPCacheConfig{MaxAge: httpcache.DefaultMaxAge},
+		Scoring:     scoring.DefaultConfig(),
+		Export:      ExportConfig{Format: "jsonl", BatchSize: 500},
+		Search:      search.Config{Index: search.DefaultIndex, Timeout: search.DefaultTimeout},
+		Translation: translate.MachineConfig{
+			BatchSize: translate.DefaultMachineBatchSize,
+			RateLimit: ratelimit.DefaultConfig(),
+			Timeout:   translate.DefaultMachineTimeout,
+		},
+		TranslationSync: translate.SyncConfig{Timeout: translate.DefaultSyncTimeout},
+		Notifications:   notify.Config{On: notify.OnAlways, Timeout: notify.DefaultTimeout},
+		Log:             logging.DefaultConfig(),
+		Tracing:         tracing.DefaultConfig(),
+	}
+}
+
+// LoadConfig loads YAML bytes into a Config with defaults applied. Unknown
+// keys are rejected so a misspelt setting does not silently fall back.
+func LoadConfig(data []byte) (Config, error) {
+	var cfg Config
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&cfg); err != nil && !errors.Is(err, io.EOF) {
+		return Config{}, err
+	}
+	return applyDefaults(cfg), nil
+}
+
+// Load reads the config file at path, or starts from DefaultConfig when path
+// is empty, then applies environment overrides and validates the result.
+//
+// Environment variables take precedence over the file:
+//
+//	EXPORTER_DB                     database.dsn
+//	EXPORTER_HTTP_CACHE             http_cache.dir
+//	EXPORTER_SMTP_PASSWORD          notifications.email.password
+//	EXPORTER_SEARCH_URL             search.url
+//	EXPORTER_SEARCH_PASSWORD        search.password
+//	EXPORTER_SEARCH_API_KEY         search.api_key
+//	EXPORTER_TRANSLATION_API_KEY    translation.api_key
+//	EXPORTER_TRANSLATION_SYNC_TOKEN translation_sync.token
+//	EXPORTER_LOG_FORMAT             log.format
+//	EXPORTER_LOG_LEVEL              log.level
+//	EXPORTER_<SOURCE>_API_KEY       sources.<source>.api_key
+//	EXPORTER_<SOURCE>_EMAIL         sources.<source>.email
+//	NCBI_API_KEY, NCBI_EMAIL        the same for NCBI sources, unless set by the above or the file
+func Load(path string, getenv func(string) string) (Config, error) {
+	cfg := DefaultConfig()
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return Config{}, fmt.Errorf("read config: %w", err)
+		}
+		if cfg, err = LoadConfig(data); err != nil {
+			return Config{}, fmt.Errorf("parse config %s: %w", path, err)
+		}
+	}
+	cfg = applyEnv(cfg, getenv)
+	if err := cfg.Validate(); err != nil {
+		return Config{}, fmt.Errorf("config: %w", err)
+	}
+	return cfg, nil
+}
+
+func applyDefaults(cfg Config) Config {
+	def := DefaultConfig()
+	if cfg.Database.DSN == "" {
+		cfg.Database.DSN = def.Database.DSN
+	}
+	if cfg.Profile == "" {
+		cfg.Profile = def.Profile
+	}
+	if cfg.Sources == nil {
+		cfg.Sources = make(map[string]SourceConfig, len(def.Sources))
+	}
+	for name := range def.Sources {
+		if _, ok := cfg.Sources[name]; !ok {
+			cfg.Sources[name] = SourceConfig{}
+		}
+	}
+	for name, src := range cfg.Sources {
+		src.RateLimit = src.RateLimit.WithDefaults()
+		if src.Workers <= 0 {
+			src.Workers = clinvar.DefaultWorkers
+		}
+		cfg.Sources[name] = src
+	}
+	if cfg.Writer.ChunkSize <= 0 {
+		cfg.Writer.ChunkSize = def.Writer.ChunkSize
+	}
+	if cfg.Writer.BulkChunkSize <= 0 {
+		cfg.Writer.BulkChunkSize = def.Writer.BulkChunkSize
+	}
+	if cfg.Writer.MaxRetries <= 0 {
+		cfg.Writer.MaxRetries = def.Writer.MaxRetries
+	}
+	if cfg.Writer.InitialBackoff <= 0 {
+		cfg.Writer.InitialBackoff = def.Writer.InitialBackoff
+	}
+	if cfg.ErrorBudget.MaxFailureRate == 0 {
+		cfg.ErrorBudget.MaxFailureRate = def.ErrorBudget.MaxFailureRate
+	}
+	if cfg.ErrorBudget.MinRecords == 0 {
+		cfg.ErrorBudget.MinRecords = def.ErrorBudget.MinRecords
+	}
+	if cfg.ErrorBudget.MaxConsecutiveRequestErrors == 0 {
+		cfg.ErrorBudget.MaxConsecutiveRequestErrors = def.ErrorBudget.MaxConsecutiveRequestErrors
+	}
+	if cfg.HTTPCache.MaxAge <= 0 {
+		cfg.HTTPCache.MaxAge = def.HTTPCache.MaxAge
+	}
+	if cfg.Scoring.HighImpactJournals == nil {
+		cfg.Scoring.HighImpactJournals = def.Scoring.HighImpactJournals
+	}
+	if cfg.Scoring.RecencyHalfLifeYears == 0 {
+		cfg.Scoring.RecencyHalfLifeYears = def.Scoring.RecencyHalfLifeYears
+	}
+	if cfg.Export.Format == "" {
+		cfg.Export.Format = def.Export.Format
+	}
+	if cfg.Export.BatchSize <= 0 {
+		cfg.Export.BatchSize = def.Export.BatchSize
+	}
+	if cfg.Search.Index == "" {
+		cfg.Search.Index = def.Search.Index
+	}
+	if cfg.Search.Timeout == 0 {
+		cfg.Search.Timeout = def.Search.Timeout
+	}
+	if cfg.Translation.BatchSize <= 0 {
+		cfg.Translation.BatchSize = def.Translation.BatchSize
+	}
+	cfg.Translation.RateLimit = cfg.Translation.RateLimit.WithDefaults()
+	if cfg.Translation.Timeout == 0 {
+		cfg.Translation.Timeout = def.Translation.Timeout
+	}
+	if cfg.TranslationSync.Timeout == 0 {
+		cfg.TranslationSync.Timeout = def.TranslationSync.Timeout
+	}
+	if cfg.Notifications.On == "" {
+		cfg.Notifications.On = def.Notifications.On
+	}
+	if cfg.Notifications.Timeout == 0 {
+		cfg.Notifications.Timeout = def.Notifications.Timeout
+	}
+	if cfg.Log.Format == "" {
+		cfg.Log.Format = def.Log.Format
+	}
+	if cfg.Log.Level == "" {
+		cfg.Log.Level = def.Log.Level
+	}
+	if cfg.Tracing.ServiceName == "" {
+		cfg.Tracing.ServiceName = def.Tracing.ServiceName
+	}
+	if cfg.Tracing.SampleRatio == 0 {
+		cfg.Tracing.SampleRatio = def.Tracing.SampleRatio
+	}
+	return cfg
+}
+
+func applyEnv(cfg Config, getenv func(string) string) Config {
+	if v := getenv("EXPORTER_DB"); v != "" {
+		cfg.Database.DSN = v
+	}
+	if v := getenv("EXPORTER_HTTP_CACHE"); v != "" {
+		cfg.HTTPCache.Dir = v
+	}
+	if v := getenv("EXPORTER_SMTP_PASSWORD"); v != "" {
+		cfg.Notifications.Email.Password = v
+	}
+	if v := getenv("EXPORTER_SEARCH_URL"); v != "" {
+		cfg.Search.URL = v
+	}
+	if v := getenv("EXPORTER_SEARCH_PASSWORD"); v != "" {
+		cfg.Search.Password = v
+	}
+	if v := getenv("EXPORTER_SEARCH_API_KEY"); v != "" {
+		cfg.Search.APIKey = v
+	}
+	if v := getenv("EXPORTER_TRANSLATION_API_KEY"); v != "" {
+		cfg.Translation.APIKey = v
+	}
+	if v := getenv("EXPORTER_TRANSLATION_SYNC_TOKEN"); v != "" {
+		cfg.TranslationSync.Token = v
+	}
+	if v := getenv("EXPORTER_LOG_FORMAT"); v != "" {
+		cfg.Log.Format = v
+	}
+	if v := getenv("EXPORTER_LOG_LEVEL"); v != "" {
+		cfg.Log.Level = v
+	}
+	for name, src := range cfg.Sources {
+		prefix := "EXPORTER_" + strings.ToUpper(name) + "_"
+		if v := getenv(prefix + "API_KEY"); v != "" {
+			src.APIKey = v
+		} else if v := getenv("NCBI_API_KEY"); v != "" && ncbiSources[name] && src.APIKey == "" {
+			src.APIKey = v
+		}
+		if v := getenv(prefix + "EMAIL"); v != "" {
+			src.Email = v
+		} else if v := getenv("NCBI_EMAIL"); v != "" && ncbiSources[name] && src.Email == "" {
+			src.Email = v
+		}
+		cfg.Sources[name] = src
+	}
+	return cfg
+}
+
+// Validate reports every invalid setting at once.
+func (c Config) Validate() error {
+	var errs []error
+	known := make(map[string]bool)
+	for _, name := range knownSources() {
+		known[name] = true
+	}
+
+	names := make([]string, 0, len(c.Sources))
+	for name := range c.Sources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		src := c.Sources[name]
+		if !known[name] {
+			errs = append(errs, fmt.Errorf("sources.%s: unknown source (want one of %s)", name, strings.Join(knownSources(), ", ")))
+			continue
+		}
+		switch src.RateLimit.Strategy {
+		case "", ratelimit.StrategyTokenBucket, ratelimit.StrategyFixedWindow, ratelimit.StrategyFixedDelay:
+		default:
+			errs = append(errs, fmt.Errorf("sources.%s.rate_limit.strategy: unknown strategy %q", name, src.RateLimit.Strategy))
+		}
+		if src.RateLimit.RequestsPerSec < 0 {
+			errs = append(errs, fmt.Errorf("sources.%s.rate_limit.requests_per_second: must not be negative", name))
+		}
+		for i, query := range src.Queries {
+			if strings.TrimSpace(query) == "" {
+				errs = append(errs, fmt.Errorf("sources.%s.queries[%d]: empty query", name, i))
+			}
+		}
+	}
+
+	if c.ErrorBudget.MaxFailureRate > 1 {
+		errs = append(errs, errors.New("error_budget.max_failure_rate: must be a fraction no greater than 1"))
+	}
+	if c.PlanBudget.MaxRequests < 0 {
+		errs = append(errs, errors.New("plan_budget.max_requests: must not be negative"))
+	}
+	if c.PlanBudget.MaxDuration < 0 {
+		errs = append(errs, errors.New("plan_budget.max_duration: must not be negative"))
+	}
+	if c.Scoring.RecencyHalfLifeYears < 0 {
+		errs = append(errs, errors.New("scoring.recency_half_life_years: must not be negative"))
+	}
+	if _, err := profile.Get(c.Profile); c.Profile != "" && err != nil {
+		errs = append(errs, fmt.Errorf("profile: %w", err))
+	}
+	validFormat := false
+	for _, format := range ExportFormats {
+		validFormat = validFormat || c.Export.Format == format
+	}
+	if !validFormat {
+		errs = append(errs, fmt.Errorf("export.format: unknown format %q (want one of %s)", c.Export.Format, strings.Join(ExportFormats, ", ")))
+	}
+	errs = append(errs, sectionErrors("search", c.Search.Validate())...)
+	errs = append(errs, sectionErrors("localization", c.Localization.Validate())...)
+	errs = append(errs, sectionErrors("translation", c.Translation.Validate())...)
+	errs = append(errs, sectionErrors("translation_sync", c.TranslationSync.Validate())...)
+	errs = append(errs, sectionErrors("notifications", c.Notifications.Validate())...)
+	errs = append(errs, sectionErrors("log", c.Log.Validate())...)
+	errs = append(errs, sectionErrors("tracing", c.Tracing.Validate())...)
+	return errors.Join(errs...)
+}
+
+// sectionErrors prefixes each error joined in err, which names fields
+// relative to section, with the section.
+func sectionErrors(section string, err error) []error {
+	if err == nil {
+		return nil
+	}
+	nested := []error{err}
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		nested = joined.Unwrap()
+	}
+	prefixed := make([]error, len(nested))
+	for i, e := range nested {
+		prefixed[i] = fmt.Errorf("%s.%w", section, e)
+	}
+	return prefixed
+}
@@ -0,0 +1,229 @@
+package config
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mkoziy/genome/exporter/internal/logging"
+	"github.com/mkoziy/genome/exporter/internal/notify"
+	"github.com/mkoziy/genome/exporter/internal/profile"
+	"github.com/mkoziy/genome/exporter/internal/ratelimit"
+	"github.com/mkoziy/genome/exporter/internal/search"
+	"github.com/mkoziy/genome/exporter/internal/sources"
+	"github.com/mkoziy/genome/exporter/internal/translate"
+)
+
+func env(vars map[string]string) func(string) string {
+	return func(key string) string { return vars[key] }
+}
+
+func TestLoadAppliesDefaultsAndOverrides(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "exporter.yaml")
+	data := []byte(`
+database:
+  dsn: /data/genome.db
+profile: minimal
+sources:
+  clinvar:
+    queries: ['BRCA1[gene]']
+    rate_limit:
+      requests_per_second: 10
+    api_key: from-file
+writer:
+  chunk_size: 200
+error_budget:
+  max_failure_rate: 0.1
+  max_consecutive_request_errors: -1
+plan_budget:
+  max_duration: 6h
+scoring:
+  recency_half_life_years: 5
+export:
+  format: csv
+search:
+  url: https://search.example.org:9200
+  username: exporter
+localization:
+  fallbacks:
+    pt-BR: [pt-PT, pt]
+translation:
+  provider: deepl
+  rate_limit: {requests_per_second: 2}
+translation_sync:
+  platform: weblate
+  url: https://hosted.weblate.org
+  project: genome
+  component: snps
+  languages: [de, uk]
+liftover:
+  chain_file: /data/hg19ToHg38.over.chain.gz
+notifications:
+  on: failure
+  email: {smtp_addr: 'smtp.example.org:587', from: exporter@example.org, to: [ops@example.org], username: exporter}
+`)
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	cfg, err := Load(path, env(map[string]string{
+		"EXPORTER_DB":                     "override.db",
+		"NCBI_API_KEY":                    "ignored",
+		"NCBI_EMAIL":                      "dev@example.org",
+		"EXPORTER_SMTP_PASSWORD":          "hunter2",
+		"EXPORTER_LOG_FORMAT":             "json",
+		"EXPORTER_SEARCH_PASSWORD":        "s3cret",
+		"EXPORTER_TRANSLATION_API_KEY":    "deepl-key",
+		"EXPORTER_TRANSLATION_SYNC_TOKEN": "wlu_token",
+	}))
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+
+	clinvar := cfg.Sources["clinvar"]
+	if cfg.Profile != profile.Minimal {
+		t.Errorf("unexpected profile: %q", cfg.Profile)
+	}
+	if cfg.Database.DSN != "override.db" {
+		t.Errorf("env did not override dsn: %q", cfg.Database.DSN)
+	}
+	if clinvar.APIKey != "from-file" || clinvar.Email != "dev@example.org" {
+		t.Errorf("NCBI_* should only fill unset credentials, got key %q email %q", clinvar.APIKey, clinvar.Email)
+	}
+	if len(clinvar.Queries) != 1 || !clinvar.IsEnabled() {
+		t.Errorf("unexpected clinvar source: %+v", clinvar)
+	}
+	// Unset fields of a partly configured section keep their defaults.
+	if clinvar.RateLimit.RequestsPerSec != 10 || clinvar.RateLimit.Strategy != ratelimit.StrategyTokenBucket || clinvar.RateLimit.Burst != 5 {
+		t.Errorf("unexpected rate limit: %+v", clinvar.RateLimit)
+	}
+	if cfg.Writer.ChunkSize != 200 || cfg.Writer.MaxRetries != 3 {
+		t.Errorf("unexpected writer: %+v", cfg.Writer)
+	}
+	if b := cfg.ErrorBudget; b.MaxFailureRate != 0.1 || b.MinRecords != 100 || b.MaxConsecutiveRequestErrors != -1 {
+		t.Errorf("unexpected error budget: %+v", b)
+	}
+	if b := cfg.PlanBudget; b.MaxDuration != 6*time.Hour || b.MaxRequests != 0 {
+		t.Errorf("unexpected plan budget: %+v", b)
+	}
+	if cfg.Scoring.RecencyHalfLifeYears != 5 || len(cfg.Scoring.HighImpactJournals) == 0 {
+		t.Errorf("unexpected scoring: %+v", cfg.Scoring)
+	}
+	if cfg.Export.Format != "csv" || cfg.Export.BatchSize != 500 || cfg.HTTPCache.MaxAge != 24*time.Hour {
+		t.Errorf("unexpected export or cache: %+v %+v", cfg.Export, cfg.HTTPCache)
+	}
+	if n := cfg.Notifications; n.On != notify.OnFailure || n.Email.Password != "hunter2" || n.Timeout != notify.DefaultTimeout {
+		t.Errorf("unexpected notifications: %+v", n)
+	}
+	if s := cfg.Search; s.Index != search.DefaultIndex || s.Password != "s3cret" || s.Timeout != search.DefaultTimeout {
+		t.Errorf("unexpected search: %+v", s)
+	}
+	if chain := cfg.Localization.Chain("pt-BR"); len(chain) != 4 || chain[1] != "pt-PT" {
+		t.Errorf("unexpected localization chain: %v", chain)
+	}
+	if tr := cfg.Translation; tr.APIKey != "deepl-key" || tr.BatchSize != translate.DefaultMachineBatchSize || tr.RateLimit.RequestsPerSec != 2 || tr.RateLimit.MaxRetries != 5 {
+		t.Errorf("unexpected translation: %+v", tr)
+	}
+	if ts := cfg.TranslationSync; ts.Token != "wlu_token" || ts.Timeout != translate.DefaultSyncTimeout || len(ts.Languages) != 2 {
+		t.Errorf("unexpected translation sync: %+v", ts)
+	}
+	if cfg.Liftover.ChainFile != "/data/hg19ToHg38.over.chain.gz" {
+		t.Errorf("unexpected liftover: %+v", cfg.Liftover)
+	}
+	if cfg.Log.Format != logging.FormatJSON || cfg.Log.Level != "info" {
+		t.Errorf("unexpected log: %+v", cfg.Log)
+	}
+}
+
+func TestLoadWithoutFileUsesDefaults(t *testing.T) {
+	cfg, err := Load("", env(map[string]string{"EXPORTER_CLINVAR_API_KEY": "k"}))
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if cfg.Database.DSN != "genome.db" || cfg.Profile != profile.Full || cfg.Sources["clinvar"].APIKey != "k" {
+		t.Fatalf("unexpected config: %+v", cfg)
+	}
+}
+
+func TestLoadExampleConfig(t *testing.T) {
+	cfg, err := Load("../../config/exporter.yaml", env(nil))
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	clinvar := cfg.Sources["clinvar"]
+	if clinvar.RateLimit.Strategy != ratelimit.StrategyTokenBucket || clinvar.RateLimit.RequestsPerSec != 3 || clinvar.RateLimit.MaxBackoff != time.Minute {
+		t.Fatalf("unexpected clinvar rate limit: %+v", clinvar.RateLimit)
+	}
+	if cfg.HTTPCache.Dir != ".cache/http" || cfg.Export.Format != "jsonl" {
+		t.Fatalf("unexpected config: %+v", cfg)
+	}
+}
+
+type stubSource struct{}
+
+func (stubSource) Name() string                              { return "mylab" }
+func (stubSource) Configure(sources.Config) error            { return nil }
+func (stubSource) Fetch(context.Context, sources.Sink) error { return nil }
+
+func TestLoadAcceptsRegisteredSources(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "exporter.yaml")
+	data := "sources:\n  mylab:\n    options: {panel: cardio}\n"
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if _, err := Load(path, env(nil)); err == nil || !strings.Contains(err.Error(), "sources.mylab: unknown source") {
+		t.Fatalf("expected an unregistered source rejected, got %v", err)
+	}
+
+	sources.Register("mylab", func() sources.Source { return stubSource{} })
+	cfg, err := Load(path, env(nil))
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	src := cfg.Sources["mylab"]
+	if src.Options["panel"] != "cardio" || src.RateLimit.RequestsPerSec == 0 || src.Workers == 0 {
+		t.Fatalf("expected options kept and defaults applied, got %+v", src)
+	}
+}
+
+func TestLoadRejectsInvalidConfig(t *testing.T) {
+	cases := map[string]string{
+		"unknown key":      "databse:\n  dsn: x\n",
+		"unknown source":   "sources:\n  gnomad: {}\n",
+		"unknown strategy": "sources:\n  clinvar:\n    rate_limit: {strategy: leaky}\n",
+		"empty query":      "sources:\n  clinvar:\n    queries: ['']\n",
+		"export format":    "export: {format: xml}\n",
+		"profile":          "profile: huge\n",
+		"failure rate":     "error_budget: {max_failure_rate: 5}\n",
+		"request quota":    "plan_budget: {max_requests: -1}\n",
+		"notify when":      "notifications: {on: sometimes}\n",
+		"webhook url":      "notifications: {webhooks: ['hooks.example.org']}\n",
+		"email recipients": "notifications: {email: {smtp_addr: 'smtp.example.org:25', from: a@example.org}}\n",
+		"search url":       "search: {url: 'localhost:9200'}\n",
+		"search index":     "search: {index: SNPs}\n",
+		"fallback":         "localization: {fallbacks: {pt-BR: ['']}}\n",
+		"translation":      "translation: {provider: babelfish}\n",
+		"translation_sync": "translation_sync: {platform: transifex, project: genome, languages: [de]}\n",
+		"log format":       "log: {format: xml}\n",
+		"trace exporter":   "tracing: {exporter: zipkin}\n",
+		"sample ratio":     "tracing: {exporter: otlp, sample_ratio: 2}\n",
+	}
+	for name, data := range cases {
+		path := filepath.Join(t.TempDir(), "exporter.yaml")
+		if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+		if _, err := Load(path, env(nil)); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+
+	// Every problem is reported, not only the first.
+	err := Config{Sources: map[string]SourceConfig{"a": {}, "b": {}}, Export: ExportConfig{Format: "xml"}}.Validate()
+	if err == nil || strings.Count(err.Error(), "\n") != 2 {
+		t.Fatalf("expected three errors, got %v", err)
+	}
+}
@@ -0,0 +1,38 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/uptrace/bun"
+)
+
+// Backup writes a consistent snapshot of db to path using VACUUM INTO.
+// It can run while other connections are writing; the snapshot reflects the
+// state at the start of the statement. The target path must not exist yet.
+func Backup(ctx context.Context, db *bun.DB, path string) error {
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("backup target %s already exists", path)
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("stat backup target: %w", err)
+	}
+
+	// Write to a temporary file first so a failed or cancelled backup never
+	// leaves a truncated database at the final path.
+	tmp := path + ".tmp"
+	_ = os.Remove(tmp)
+
+	if _, err := db.ExecContext(ctx, "VACUUM INTO ?", tmp); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("vacuum into: %w", err)
+	}
+
+	if err := os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("rename backup: %w", err)
+	}
+
+	return nil
+}
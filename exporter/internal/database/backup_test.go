@@ -0,0 +1,48 @@
+package database
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+)
+
+func TestBackup(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	db, err := NewDB(filepath.Join(dir, "source.db"), false)
+	if err != nil {
+		t.Fatalf("open source: %v", err)
+	}
+	defer func() { _ = db.Close() }()
+
+	if _, err := db.ExecContext(ctx, "CREATE TABLE items (id INTEGER PRIMARY KEY, name TEXT)"); err != nil {
+		t.Fatalf("create table: %v", err)
+	}
+	if _, err := db.ExecContext(ctx, "INSERT INTO items (name) VALUES ('a'), ('b')"); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+
+	target := filepath.Join(dir, "backup.db")
+	if err := Backup(ctx, db, target); err != nil {
+		t.Fatalf("backup: %v", err)
+	}
+
+	snapshot, err := NewDB(target, false)
+	if err != nil {
+		t.Fatalf("open backup: %v", err)
+	}
+	defer func() { _ = snapshot.Close() }()
+
+	count, err := snapshot.NewSelect().Table("items").Count(ctx)
+	if err != nil {
+		t.Fatalf("count: %v", err)
+	}
+	if count != 2 {
+		t.Fatalf("expected 2 rows in backup, got %d", count)
+	}
+
+	if err := Backup(ctx, db, target); err == nil {
+		t.Fatalf("expected error when backup target exists")
+	}
+}
@@ -0,0 +1,129 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// DroppedIndexesTable records the secondary indexes a bulk load dropped, so
+// they can be rebuilt even if the process dies before the load finishes.
+const DroppedIndexesTable = "dropped_indexes"
+
+type droppedIndex struct {
+	bun.BaseModel `bun:"table:dropped_indexes"`
+
+	Name string `bun:"name,pk"`
+	SQL  string `bun:"sql,notnull"`
+}
+
+// BulkLoad is an initial load in progress. Building the secondary indexes
+// once at the end is much faster than maintaining them row by row, and
+// without fsyncs each commit only reaches the OS. The load survives the
+// process crashing but not the OS crashing or the power failing, which may
+// corrupt the database; it is meant for builds that can start over.
+type BulkLoad struct {
+	db   *bun.DB
+	conn bun.Conn
+}
+
+// BeginBulkLoad drops the secondary indexes of db, recording their
+// definitions in DroppedIndexesTable, and pins a connection with
+// synchronous=OFF for the load's transactions. Unique indexes are kept,
+// since upserts resolve conflicts through them. Finish must be called once
+// the rows are written.
+func BeginBulkLoad(ctx context.Context, db *bun.DB) (*BulkLoad, error) {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("pin connection: %w", err)
+	}
+	if _, err := conn.ExecContext(ctx, "PRAGMA synchronous = OFF"); err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("disable sync: %w", err)
+	}
+
+	var indexes []droppedIndex
+	err = conn.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		err := tx.NewRaw(`SELECT name, sql FROM sqlite_master
+			WHERE type = 'index' AND sql IS NOT NULL AND sql NOT LIKE 'CREATE UNIQUE %' AND tbl_name <> ?`,
+			DroppedIndexesTable).Scan(ctx, &indexes)
+		if err != nil {
+			return err
+		}
+		for _, idx := range indexes {
+			if _, err := tx.NewInsert().Model(&idx).On("CONFLICT (name) DO NOTHING").Exec(ctx); err != nil {
+				return fmt.Errorf("record index %s: %w", idx.Name, err)
+			}
+			if _, err := tx.ExecContext(ctx, "DROP INDEX ?", bun.Ident(idx.Name)); err != nil {
+				return fmt.Errorf("drop index %s: %w", idx.Name, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		_, _ = conn.ExecContext(ctx, "PRAGMA synchronous = NORMAL")
+		_ = conn.Close()
+		return nil, fmt.Errorf("drop indexes: %w", err)
+	}
+	slog.InfoContext(ctx, "Bulk load started", "dropped_indexes", len(indexes))
+	return &BulkLoad{db: db, conn: conn}, nil
+}
+
+// Conn is the connection the load's transactions should run on.
+func (b *BulkLoad) Conn() bun.Conn {
+	return b.conn
+}
+
+// Finish restores synchronous writes, rebuilds the dropped indexes and
+// refreshes the query planner's statistics with ANALYZE. It does all three
+// even if ctx is cancelled, since the database is slow to query until then.
+func (b *BulkLoad) Finish(ctx context.Context) error {
+	ctx = context.WithoutCancel(ctx)
+	var errs []error
+	if _, err := b.conn.ExecContext(ctx, "PRAGMA synchronous = NORMAL"); err != nil {
+		errs = append(errs, fmt.Errorf("restore sync: %w", err))
+	}
+	if err := b.conn.Close(); err != nil {
+		errs = append(errs, fmt.Errorf("release connection: %w", err))
+	}
+
+	start := time.Now()
+	n, err := RestoreIndexes(ctx, b.db)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	if _, err := b.db.ExecContext(ctx, "ANALYZE"); err != nil {
+		errs = append(errs, fmt.Errorf("analyze: %w", err))
+	}
+	slog.InfoContext(ctx, "Bulk load finished", "rebuilt_indexes", n, "duration", time.Since(start))
+	return errors.Join(errs...)
+}
+
+// RestoreIndexes rebuilds every index recorded in DroppedIndexesTable,
+// returning how many it rebuilt. It completes a bulk load that was
+// interrupted before Finish and does nothing otherwise.
+func RestoreIndexes(ctx context.Context, db *bun.DB) (int, error) {
+	var indexes []droppedIndex
+	if err := db.NewSelect().Model(&indexes).Scan(ctx); err != nil {
+		return 0, fmt.Errorf("list dropped indexes: %w", err)
+	}
+	for i, idx := range indexes {
+		// Creating the index and forgetting it in one transaction means a
+		// crash in between never loses or duplicates it.
+		err := db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+			if _, err := tx.ExecContext(ctx, idx.SQL); err != nil {
+				return err
+			}
+			_, err := tx.NewDelete().Model(&idx).WherePK().Exec(ctx)
+			return err
+		})
+		if err != nil {
+			return i, fmt.Errorf("rebuild index %s: %w", idx.Name, err)
+		}
+	}
+	return len(indexes), nil
+}
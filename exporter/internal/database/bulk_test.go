@@ -0,0 +1,83 @@
+package database
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/uptrace/bun"
+)
+
+func indexNames(t *testing.T, db *bun.DB) string {
+	t.Helper()
+	var names []string
+	err := db.NewRaw("SELECT name FROM sqlite_master WHERE type = 'index' AND sql IS NOT NULL ORDER BY name").
+		Scan(context.Background(), &names)
+	if err != nil {
+		t.Fatalf("list indexes: %v", err)
+	}
+	return strings.Join(names, ",")
+}
+
+func TestBulkLoadDropsAndRebuildsIndexes(t *testing.T) {
+	ctx := context.Background()
+	db, err := NewDB(filepath.Join(t.TempDir(), "bulk.db"), false)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer func() { _ = db.Close() }()
+
+	for _, stmt := range []string{
+		"CREATE TABLE dropped_indexes (name TEXT PRIMARY KEY, sql TEXT NOT NULL)",
+		"CREATE TABLE items (id INTEGER PRIMARY KEY, rsid TEXT, gene TEXT)",
+		"CREATE UNIQUE INDEX idx_items_rsid ON items(rsid)",
+		"CREATE INDEX idx_items_gene ON items(gene)",
+	} {
+		if _, err := db.ExecContext(ctx, stmt); err != nil {
+			t.Fatalf("%s: %v", stmt, err)
+		}
+	}
+
+	bulk, err := BeginBulkLoad(ctx, db)
+	if err != nil {
+		t.Fatalf("begin: %v", err)
+	}
+	// The unique index stays for upserts.
+	if got := indexNames(t, db); got != "idx_items_rsid" {
+		t.Fatalf("expected only the unique index during the load, got %s", got)
+	}
+	var sync int
+	if err := bulk.Conn().NewRaw("PRAGMA synchronous").Scan(ctx, &sync); err != nil || sync != 0 {
+		t.Fatalf("expected synchronous off on the load's connection, got %d (%v)", sync, err)
+	}
+	if _, err := bulk.Conn().ExecContext(ctx, "INSERT INTO items (rsid, gene) VALUES ('rs1', 'BRCA1')"); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+
+	if err := bulk.Finish(ctx); err != nil {
+		t.Fatalf("finish: %v", err)
+	}
+	if got := indexNames(t, db); got != "idx_items_gene,idx_items_rsid" {
+		t.Fatalf("expected indexes rebuilt, got %s", got)
+	}
+	if n, err := db.NewSelect().Table("sqlite_stat1").Where("idx = 'idx_items_gene'").Count(ctx); err != nil || n != 1 {
+		t.Fatalf("expected ANALYZE statistics for the rebuilt index, got %d (%v)", n, err)
+	}
+	if n, err := RestoreIndexes(ctx, db); err != nil || n != 0 {
+		t.Fatalf("expected nothing left to restore, got %d (%v)", n, err)
+	}
+
+	// A load that dies before Finish leaves its dropped indexes recorded.
+	bulk, err = BeginBulkLoad(ctx, db)
+	if err != nil {
+		t.Fatalf("begin again: %v", err)
+	}
+	_ = bulk.Conn().Close()
+	if n, err := RestoreIndexes(ctx, db); err != nil || n != 1 {
+		t.Fatalf("expected the interrupted load's index restored, got %d (%v)", n, err)
+	}
+	if got := indexNames(t, db); got != "idx_items_gene,idx_items_rsid" {
+		t.Fatalf("expected indexes restored, got %s", got)
+	}
+}
@@ -0,0 +1,364 @@
+// Package diff compares two generated databases, typically consecutive
+// releases: the SNPs added and removed, those whose coordinates, gene or
+// significance level changed, and the clinical assertions reclassified, such
+// as a variant of uncertain significance becoming pathogenic.
+package diff
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/uptrace/bun"
+
+	"github.com/mkoziy/genome/exporter/internal/models"
+	"github.com/mkoziy/genome/exporter/internal/repositories"
+)
+
+// defaultBatchSize is how many SNPs are read from each database at once
+// unless Options.BatchSize says otherwise.
+const defaultBatchSize = 5000
+
+// Options tune Compare.
+type Options struct {
+	// MaxItems bounds each list of the report; counts are always complete.
+	// 0 lists everything.
+	MaxItems  int
+	BatchSize int
+}
+
+// Release identifies a database by its most recent recorded release.
+type Release struct {
+	Version  string `json:"version,omitempty"`
+	Checksum string `json:"checksum,omitempty"`
+	SNPs     int    `json:"snps"`
+}
+
+// SNP names a variant added or removed.
+type SNP struct {
+	RsID string `json:"rsid"`
+	Gene string `json:"gene,omitempty"`
+}
+
+// FieldChange is one field of a SNP that changed.
+type FieldChange struct {
+	Field string `json:"field"`
+	Old   string `json:"old"`
+	New   string `json:"new"`
+}
+
+// Change lists the fields of a SNP present in both databases that changed.
+type Change struct {
+	RsID    string        `json:"rsid"`
+	Gene    string        `json:"gene,omitempty"`
+	Changes []FieldChange `json:"changes"`
+}
+
+// Reclassification is a condition whose clinical significance, as one
+// source asserts it for a SNP, changed. Where submitters disagree the
+// significance lists every one asserted, most severe first, joined by /.
+type Reclassification struct {
+	RsID      string            `json:"rsid"`
+	Gene      string            `json:"gene,omitempty"`
+	Condition string            `json:"condition"`
+	Source    models.DataSource `json:"source"`
+	Old       string            `json:"old"`
+	New       string            `json:"new"`
+}
+
+// Counts are the sizes of the report's lists before MaxItems.
+type Counts struct {
+	Added        int `json:"added"`
+	Removed      int `json:"removed"`
+	Changed      int `json:"changed"`
+	Reclassified int `json:"reclassified"`
+}
+
+// Report is the difference between two databases.
+type Report struct {
+	Old          Release            `json:"old"`
+	New          Release            `json:"new"`
+	Counts       Counts             `json:"counts"`
+	Added        []SNP              `json:"added"`
+	Removed      []SNP              `json:"removed"`
+	Changed      []Change           `json:"changed"`
+	Reclassified []Reclassification `json:"reclassified"`
+}
+
+// Empty reports whether the databases hold the same SNPs and assertions, as
+// far as the report compares them.
+func (r *Report) Empty() bool {
+	return r.Counts == Counts{}
+}
+
+// Compare reads the SNPs of both databases in rsID order, a batch at a
+// time, and reports how newDB differs from oldDB.
+func Compare(ctx context.Context, oldDB, newDB *bun.DB, opts Options) (*Report, error) {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = defaultBatchSize
+	}
+	r := &Report{
+		Added:        make([]SNP, 0),
+		Removed:      make([]SNP, 0),
+		Changed:      make([]Change, 0),
+		Reclassified: make([]Reclassification, 0),
+	}
+	var err error
+	if r.Old, err = release(ctx, oldDB); err != nil {
+		return nil, fmt.Errorf("old: %w", err)
+	}
+	if r.New, err = release(ctx, newDB); err != nil {
+		return nil, fmt.Errorf("new: %w", err)
+	}
+
+	oldIt := &iterator{db: oldDB, batchSize: opts.BatchSize}
+	newIt := &iterator{db: newDB, batchSize: opts.BatchSize}
+	listed := func(n int) bool { return opts.MaxItems <= 0 || n <= opts.MaxItems }
+	for {
+		o, err := oldIt.peek(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("old: %w", err)
+		}
+		n, err := newIt.peek(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("new: %w", err)
+		}
+		switch {
+		case o == nil && n == nil:
+			return r, nil
+		case n == nil || (o != nil && o.RsID < n.RsID):
+			if r.Counts.Removed++; listed(r.Counts.Removed) {
+				r.Removed = append(r.Removed, SNP{RsID: o.RsID, Gene: gene(o)})
+			}
+			oldIt.next()
+		case o == nil || n.RsID < o.RsID:
+			if r.Counts.Added++; listed(r.Counts.Added) {
+				r.Added = append(r.Added, SNP{RsID: n.RsID, Gene: gene(n)})
+			}
+			newIt.next()
+		default:
+			if changes := compareFields(o, n); len(changes) > 0 {
+				if r.Counts.Changed++; listed(r.Counts.Changed) {
+					r.Changed = append(r.Changed, Change{RsID: n.RsID, Gene: gene(n), Changes: changes})
+				}
+			}
+			for _, rc := range reclassifications(o, n) {
+				if r.Counts.Reclassified++; listed(r.Counts.Reclassified) {
+					r.Reclassified = append(r.Reclassified, rc)
+				}
+			}
+			oldIt.next()
+			newIt.next()
+		}
+	}
+}
+
+// release returns the latest recorded release of db, if any, and its SNP
+// count.
+func release(ctx context.Context, db *bun.DB) (Release, error) {
+	var rel Release
+	n, err := db.NewSelect().Model((*models.SNP)(nil)).Count(ctx)
+	if err != nil {
+		return rel, err
+	}
+	rel.SNPs = n
+	latest, err := repositories.GetCurrentRelease(ctx, db)
+	if errors.Is(err, sql.ErrNoRows) {
+		return rel, nil
+	}
+	if err != nil {
+		return rel, fmt.Errorf("release: %w", err)
+	}
+	rel.Version = latest.Version
+	rel.Checksum = latest.Checksum
+	return rel, nil
+}
+
+// iterator reads the SNPs of a database by rsID, with their scores and
+// clinical assertions, keyset-paginated.
+type iterator struct {
+	db        *bun.DB
+	batchSize int
+	batch     []*models.SNP
+	after     string
+	done      bool
+}
+
+// peek returns the current SNP, loading the next batch when needed, or nil
+// once every SNP was read.
+func (it *iterator) peek(ctx context.Context) (*models.SNP, error) {
+	if len(it.batch) == 0 && !it.done {
+		err := it.db.NewSelect().
+			Model(&it.batch).
+			Relation("Significance").
+			Relation("ClinicalData").
+			Where("s.rsid > ?", it.after).
+			OrderExpr("s.rsid ASC").
+			Limit(it.batchSize).
+			Scan(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if len(it.batch) < it.batchSize {
+			it.done = true
+		}
+		if len(it.batch) > 0 {
+			it.after = it.batch[len(it.batch)-1].RsID
+		}
+	}
+	if len(it.batch) == 0 {
+		return nil, nil
+	}
+	return it.batch[0], nil
+}
+
+func (it *iterator) next() {
+	it.batch = it.batch[1:]
+}
+
+// compareFields returns the fields of the SNP that differ between o and n.
+// Scores are compared by significance level, so rescoring that moves a
+// variant within its band is not a change.
+func compareFields(o, n *models.SNP) []FieldChange {
+	var changes []FieldChange
+	add := func(field, old, new string) {
+		if old != new {
+			changes = append(changes, FieldChange{Field: field, Old: old, New: new})
+		}
+	}
+	add("chromosome", o.Chromosome, n.Chromosome)
+	add("position", strconv.FormatInt(o.Position, 10), strconv.FormatInt(n.Position, 10))
+	add("reference_allele", o.ReferenceAllele, n.ReferenceAllele)
+	add("alternate_alleles", strings.Join(o.AlternateAlleles, ","), strings.Join(n.AlternateAlleles, ","))
+	add("gene_symbol", gene(o), gene(n))
+	add("variant_type", string(o.VariantType), string(n.VariantType))
+	add("end_position", optionalInt(o.EndPosition), optionalInt(n.EndPosition))
+	add("sv_type", svType(o), svType(n))
+	add("functional_class", functionalClass(o), functionalClass(n))
+	add("significance_level", level(o), level(n))
+	return changes
+}
+
+// reclassifications compares the significances each source asserts for
+// each condition of a SNP. Conditions asserted in only one of the databases
+// are not reclassifications.
+func reclassifications(o, n *models.SNP) []Reclassification {
+	before, after := assertions(o), assertions(n)
+	var list []Reclassification
+	for key, a := range after {
+		b, ok := before[key]
+		if !ok {
+			continue
+		}
+		if old, new := b.significance(), a.significance(); old != new {
+			list = append(list, Reclassification{
+				RsID:      n.RsID,
+				Gene:      gene(n),
+				Condition: a.condition,
+				Source:    a.source,
+				Old:       old,
+				New:       new,
+			})
+		}
+	}
+	slices.SortFunc(list, func(x, y Reclassification) int {
+		return cmp.Or(cmp.Compare(x.Condition, y.Condition), cmp.Compare(x.Source, y.Source))
+	})
+	return list
+}
+
+// assertion is what one source asserts about one condition of a SNP.
+type assertion struct {
+	condition     string
+	source        models.DataSource
+	significances []models.ClinicalSignificance
+}
+
+// significance returns the significances asserted in the order of
+// models.ClinicalSignificances, most severe first, joined by /.
+func (a *assertion) significance() string {
+	sorted := slices.Clone(a.significances)
+	slices.SortFunc(sorted, func(x, y models.ClinicalSignificance) int {
+		return cmp.Compare(severity(x), severity(y))
+	})
+	parts := make([]string, len(sorted))
+	for i, s := range sorted {
+		parts[i] = string(s)
+	}
+	return strings.Join(parts, "/")
+}
+
+// assertions groups the clinical rows of snp by source and condition, the
+// condition identified by its ID where it has one and by its lower-cased
+// name otherwise.
+func assertions(snp *models.SNP) map[string]*assertion {
+	result := make(map[string]*assertion)
+	for _, c := range snp.ClinicalData {
+		condition := strings.ToLower(c.ConditionName)
+		if c.ConditionID != nil && *c.ConditionID != "" {
+			condition = *c.ConditionID
+		}
+		key := string(c.Source) + "\x00" + condition
+		a, ok := result[key]
+		if !ok {
+			a = &assertion{condition: c.ConditionName, source: c.Source}
+			result[key] = a
+		}
+		// Spellings of a condition differing in case are one condition,
+		// named by the first in byte order so reports are stable.
+		a.condition = min(a.condition, c.ConditionName)
+		if !slices.Contains(a.significances, c.ClinicalSignificance) {
+			a.significances = append(a.significances, c.ClinicalSignificance)
+		}
+	}
+	return result
+}
+
+// severity orders significances as models.ClinicalSignificances lists them,
+// unknown ones last.
+func severity(s models.ClinicalSignificance) int {
+	if i := slices.Index(models.ClinicalSignificances, s); i >= 0 {
+		return i
+	}
+	return len(models.ClinicalSignificances)
+}
+
+func gene(snp *models.SNP) string {
+	if snp.GeneSymbol == nil {
+		return ""
+	}
+	return *snp.GeneSymbol
+}
+
+func functionalClass(snp *models.SNP) string {
+	if snp.FunctionalClass == nil {
+		return ""
+	}
+	return string(*snp.FunctionalClass)
+}
+
+func svType(snp *models.SNP) string {
+	if snp.SVType == nil {
+		return ""
+	}
+	return string(*snp.SVType)
+}
+
+func optionalInt(n *int64) string {
+	if n == nil {
+		return ""
+	}
+	return strconv.FormatInt(*n, 10)
+}
+
+func level(snp *models.SNP) string {
+	if snp.Significance == nil {
+		return "Unscored"
+	}
+	return snp.Significance.SignificanceLevel()
+}
@@ -0,0 +1,123 @@
+package diff
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/uptrace/bun"
+
+	"github.com/mkoziy/genome/exporter/internal/database"
+	"github.com/mkoziy/genome/exporter/internal/migrations"
+	"github.com/mkoziy/genome/exporter/internal/models"
+	"github.com/mkoziy/genome/exporter/internal/repositories"
+)
+
+func newTestDB(t *testing.T, name string) *bun.DB {
+	t.Helper()
+	db, err := database.NewDB("file:"+t.Name()+name+"?mode=memory&cache=shared", false)
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	if err := migrations.RunMigrations(context.Background(), db); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	return db
+}
+
+func strPtr(s string) *string { return &s }
+
+// seed stores SNPs of the given rsIDs at position 100 and the clinical rows,
+// whose SNPID is the index of their SNP's rsID.
+func seed(t *testing.T, db *bun.DB, rsIDs []string, clinical []*models.ClinicalData, gene map[string]string) {
+	t.Helper()
+	ctx := context.Background()
+	snps := make([]*models.SNP, len(rsIDs))
+	for i, rsID := range rsIDs {
+		snps[i] = &models.SNP{RsID: rsID, Chromosome: "1", Position: 100 + int64(i), ReferenceAllele: "C", AlternateAlleles: models.StringArray{"T"}, VariantType: models.VariantSNV}
+		if g, ok := gene[rsID]; ok {
+			snps[i].GeneSymbol = strPtr(g)
+		}
+	}
+	if err := repositories.UpsertSNPs(ctx, db, snps); err != nil {
+		t.Fatalf("upsert: %v", err)
+	}
+	for _, c := range clinical {
+		c.SNPID = snps[c.SNPID].ID
+		c.ReviewStatus = models.ReviewCriteriaProvided
+		c.Source = models.SourceClinVar
+	}
+	if len(clinical) > 0 {
+		if _, err := db.NewInsert().Model(&clinical).Exec(ctx); err != nil {
+			t.Fatalf("insert clinical: %v", err)
+		}
+	}
+}
+
+func TestCompare(t *testing.T) {
+	ctx := context.Background()
+	oldDB, newDB := newTestDB(t, "old"), newTestDB(t, "new")
+
+	seed(t, oldDB, []string{"rs1", "rs2", "rs3"}, []*models.ClinicalData{
+		{SNPID: 1, ClinicalSignificance: models.ClinicalUncertainSignif, ConditionName: "Cardiomyopathy"},
+		{SNPID: 1, ClinicalSignificance: models.ClinicalBenign, ConditionName: "not provided"},
+	}, nil)
+	seed(t, newDB, []string{"rs2", "rs3", "rs4"}, []*models.ClinicalData{
+		{SNPID: 0, ClinicalSignificance: models.ClinicalPathogenic, ConditionName: "Cardiomyopathy"},
+		{SNPID: 0, ClinicalSignificance: models.ClinicalLikelyPathogenic, ConditionName: "cardiomyopathy"},
+		{SNPID: 0, ClinicalSignificance: models.ClinicalBenign, ConditionName: "not provided"},
+		{SNPID: 0, ClinicalSignificance: models.ClinicalPathogenic, ConditionName: "Arrhythmia"},
+	}, map[string]string{"rs3": "TTN"})
+	if _, err := repositories.CreateRelease(ctx, newDB, "2.0.0", map[string]string{"clinvar": "2026-10"}); err != nil {
+		t.Fatalf("release: %v", err)
+	}
+
+	r, err := Compare(ctx, oldDB, newDB, Options{BatchSize: 2})
+	if err != nil {
+		t.Fatalf("compare: %v", err)
+	}
+	if r.Counts != (Counts{Added: 1, Removed: 1, Changed: 2, Reclassified: 1}) {
+		t.Fatalf("unexpected counts: %+v", r.Counts)
+	}
+	if r.Added[0].RsID != "rs4" || r.Removed[0].RsID != "rs1" || r.New.Version != "2.0.0" || r.Old.Version != "" {
+		t.Fatalf("unexpected report: %+v", r)
+	}
+	// rs2 moved from position 101 to 100; rs3 from 102 to 101 and gained a gene.
+	if c := r.Changed[1]; c.RsID != "rs3" || len(c.Changes) != 2 || c.Changes[1] != (FieldChange{Field: "gene_symbol", Old: "", New: "TTN"}) {
+		t.Fatalf("unexpected changes: %+v", r.Changed)
+	}
+	rc := r.Reclassified[0]
+	if rc.RsID != "rs2" || !strings.EqualFold(rc.Condition, "cardiomyopathy") || rc.Old != "uncertain_significance" || rc.New != "pathogenic/likely_pathogenic" {
+		t.Fatalf("unexpected reclassification: %+v", r.Reclassified[0])
+	}
+
+	limited, err := Compare(ctx, oldDB, newDB, Options{MaxItems: 1})
+	if err != nil {
+		t.Fatalf("compare: %v", err)
+	}
+	if limited.Counts.Changed != 2 || len(limited.Changed) != 1 {
+		t.Fatalf("expected complete counts of truncated lists, got %+v", limited)
+	}
+
+	var md bytes.Buffer
+	if err := limited.WriteMarkdown(&md); err != nil {
+		t.Fatalf("markdown: %v", err)
+	}
+	for _, s := range []string{
+		"# Changes from unreleased to 2.0.0",
+		"| clinvar | uncertain significance | pathogenic/likely pathogenic |",
+		"- rs4",
+		"…and 1 more.",
+	} {
+		if !strings.Contains(md.String(), s) {
+			t.Errorf("markdown lacks %q:\n%s", s, md.String())
+		}
+	}
+
+	same, err := Compare(ctx, newDB, newDB, Options{})
+	if err != nil || !same.Empty() {
+		t.Fatalf("expected a database to equal itself, got %+v (%v)", same, err)
+	}
+}
@@ -0,0 +1,97 @@
+package diff
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/template"
+)
+
+// WriteJSON writes the report as JSON.
+func (r *Report) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// WriteText writes the counts and one line per listed entry.
+func (r *Report) WriteText(w io.Writer) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s -> %s: %d -> %d SNPs\n", version(r.Old), version(r.New), r.Old.SNPs, r.New.SNPs)
+	fmt.Fprintf(&b, "added %d, removed %d, changed %d, reclassified %d\n",
+		r.Counts.Added, r.Counts.Removed, r.Counts.Changed, r.Counts.Reclassified)
+	for _, s := range r.Added {
+		fmt.Fprintf(&b, "+ %s\n", s.RsID)
+	}
+	for _, s := range r.Removed {
+		fmt.Fprintf(&b, "- %s\n", s.RsID)
+	}
+	for _, c := range r.Changed {
+		for _, f := range c.Changes {
+			fmt.Fprintf(&b, "~ %s %s: %q -> %q\n", c.RsID, f.Field, f.Old, f.New)
+		}
+	}
+	for _, rc := range r.Reclassified {
+		fmt.Fprintf(&b, "! %s %s (%s): %s -> %s\n", rc.RsID, rc.Condition, rc.Source, rc.Old, rc.New)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// markdownEscaper backslash-escapes the characters Markdown would read as
+// formatting in names taken from the databases.
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`, "`", "\\`", `*`, `\*`, `_`, `\_`, `[`, `\[`, `]`, `\]`, `<`, `\<`, `>`, `\>`, `#`, `\#`, `|`, `\|`,
+)
+
+var markdownTemplate = template.Must(template.New("markdown").Funcs(template.FuncMap{
+	"md":      markdownEscaper.Replace,
+	"version": version,
+	"words":   func(s string) string { return strings.ReplaceAll(s, "_", " ") },
+	"more":    func(total, listed int) int { return total - listed },
+}).Parse(`# Changes from {{version .Old}} to {{version .New}}
+
+{{.Old.SNPs}} → {{.New.SNPs}} SNPs: {{.Counts.Added}} added, {{.Counts.Removed}} removed, {{.Counts.Changed}} changed, {{.Counts.Reclassified}} clinical reclassifications.
+{{if .Reclassified}}
+## Reclassified
+
+| SNP | Gene | Condition | Source | Before | After |
+|---|---|---|---|---|---|
+{{range .Reclassified}}| {{.RsID}} | {{md .Gene}} | {{md .Condition}} | {{.Source}} | {{words .Old}} | {{words .New}} |
+{{end}}{{with more .Counts.Reclassified (len .Reclassified)}}
+…and {{.}} more.
+{{end}}{{end}}{{if .Added}}
+## Added
+
+{{range .Added}}- {{.RsID}}{{with .Gene}} ({{md .}}){{end}}
+{{end}}{{with more .Counts.Added (len .Added)}}
+…and {{.}} more.
+{{end}}{{end}}{{if .Removed}}
+## Removed
+
+{{range .Removed}}- {{.RsID}}{{with .Gene}} ({{md .}}){{end}}
+{{end}}{{with more .Counts.Removed (len .Removed)}}
+…and {{.}} more.
+{{end}}{{end}}{{if .Changed}}
+## Changed
+
+{{range .Changed}}- {{.RsID}}{{with .Gene}} ({{md .}}){{end}}: {{range $i, $c := .Changes}}{{if $i}}; {{end}}{{words $c.Field}} {{md (or $c.Old "none")}} → {{md (or $c.New "none")}}{{end}}
+{{end}}{{with more .Counts.Changed (len .Changed)}}
+…and {{.}} more.
+{{end}}{{end}}`))
+
+// WriteMarkdown writes the report as release notes: the counts, then the
+// reclassifications as a table and the other lists as bullets.
+func (r *Report) WriteMarkdown(w io.Writer) error {
+	return markdownTemplate.Execute(w, r)
+}
+
+// version names a release by its version, or by "unreleased" for a database
+// without one.
+func version(rel Release) string {
+	if rel.Version == "" {
+		return "unreleased"
+	}
+	return rel.Version
+}
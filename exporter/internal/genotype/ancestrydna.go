@@ -0,0 +1,27 @@
+package genotype
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ParseAncestryDNA reads an AncestryDNA raw data export: tab-separated rsid,
+// chromosome, position, allele1 and allele2 columns after # comments and a
+// header line. Chromosomes are numbered, 23 to 26 standing for X, Y, the
+// pseudoautosomal regions and MT; uncalled alleles are 0.
+func ParseAncestryDNA(r io.Reader) ([]Call, error) {
+	return parseLines(r, parseAncestryDNALine)
+}
+
+func parseAncestryDNALine(text string) (Call, bool, error) {
+	if strings.HasPrefix(text, "#") || strings.HasPrefix(strings.ToLower(text), "rsid\t") {
+		return Call{}, false, nil
+	}
+	fields := strings.Split(text, "\t")
+	if len(fields) != 5 {
+		return Call{}, false, fmt.Errorf("%w: want 5 tab-separated columns, got %d", ErrFormat, len(fields))
+	}
+	call, err := newCall(fields[0], fields[1], fields[2], strings.TrimSpace(fields[3])+strings.TrimSpace(fields[4]))
+	return call, err == nil, err
+}
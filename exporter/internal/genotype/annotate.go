@@ -0,0 +1,140 @@
+package genotype
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/mkoziy/genome/exporter/internal/models"
+	"github.com/mkoziy/genome/exporter/internal/repositories"
+)
+
+// lookupBatchSize bounds how many rsIDs are looked up at once, so a full
+// raw data file of several hundred thousand calls is not held in memory
+// joined to its database rows all at once.
+const lookupBatchSize = 10000
+
+// Annotation joins a call to the database's record of its variant, with its
+// score and clinical assertions.
+type Annotation struct {
+	Call Call        `json:"call"`
+	SNP  *models.SNP `json:"snp"`
+	// AltCopies is how many of the called alleles are alternate alleles of
+	// the SNP, counted on the opposite strand if the chip reported that one.
+	// It is -1 when unknown: for no-calls, indel calls and calls with an
+	// allele that is neither the reference nor an alternate one on either
+	// strand.
+	AltCopies int `json:"alt_copies"`
+	// Findings are the call's status for each risk allele of the SNP.
+	Findings []Finding `json:"findings,omitempty"`
+	// Effects are the SNP's effects of the called genotype.
+	Effects []*models.GenotypeEffect `json:"genotype_effects,omitempty"`
+}
+
+// Result is a raw data file annotated against the database.
+type Result struct {
+	// Annotations are the calls of variants in the database, in file order.
+	Annotations []Annotation `json:"annotations"`
+	Calls       int          `json:"calls"`
+	NoCalls     int          `json:"no_calls"`
+	// Sex is the sex the calls on X and Y were read for.
+	Sex Sex `json:"sex,omitempty"`
+}
+
+// Annotate looks up the variant of every call in snps by rsID, in batches.
+// Calls of variants the database does not have are counted but not
+// returned. rsIDs merged into another are not followed. Calls on X and Y
+// are read as a person of sex carries them, calls on the mitochondrion as
+// one copy; see Ploidy.
+func Annotate(ctx context.Context, snps repositories.SNPRepository, calls []Call, sex Sex) (*Result, error) {
+	result := &Result{Calls: len(calls), Sex: sex}
+	for start := 0; start < len(calls); start += lookupBatchSize {
+		batch := calls[start:min(start+lookupBatchSize, len(calls))]
+		rsIDs := make([]string, 0, len(batch))
+		for _, call := range batch {
+			if call.NoCall() {
+				result.NoCalls++
+			}
+			if models.IsRsID(call.RsID) {
+				rsIDs = append(rsIDs, call.RsID)
+			}
+		}
+		found, err := snps.GetByRsIDs(ctx, rsIDs)
+		if err != nil {
+			return nil, fmt.Errorf("look up calls %d-%d: %w", start+1, start+len(batch), err)
+		}
+		for _, call := range batch {
+			snp, ok := found[call.RsID]
+			if !ok {
+				continue
+			}
+			a := Annotation{Call: call, SNP: snp, AltCopies: -1}
+			alleles, _ := orient(call, snp)
+			alleles, a.Findings, a.Effects = interpretAt(alleles, snp, sex, "", nil)
+			if alleles != nil {
+				a.AltCopies, _ = countAlt(alleles, snp)
+			}
+			result.Annotations = append(result.Annotations, a)
+		}
+	}
+	return result, nil
+}
+
+// orient returns the alleles of call on the strand of snp. Alleles that match
+// neither the reference nor an alternate allele are retried complemented, as
+// if the chip reported the opposite strand, unless the SNP reads the same on
+// both strands. It returns false if an allele still cannot be placed.
+func orient(call Call, snp *models.SNP) ([]string, bool) {
+	if call.NoCall() {
+		return nil, false
+	}
+	alleles := call.Alleles()
+	if _, ok := countAlt(alleles, snp); ok {
+		return alleles, true
+	}
+	if palindromic(snp) {
+		return nil, false
+	}
+	for i, allele := range alleles {
+		alleles[i] = complement(allele)
+	}
+	if _, ok := countAlt(alleles, snp); ok {
+		return alleles, true
+	}
+	return nil, false
+}
+
+func countAlt(alleles []string, snp *models.SNP) (int, bool) {
+	n := 0
+	for _, allele := range alleles {
+		switch {
+		case slices.Contains(snp.AlternateAlleles, allele):
+			n++
+		case allele != snp.ReferenceAllele:
+			return 0, false
+		}
+	}
+	return n, true
+}
+
+// palindromic reports whether an alternate allele of snp is the complement
+// of its reference, an A/T or C/G SNP whose strand a genotype cannot reveal.
+func palindromic(snp *models.SNP) bool {
+	return slices.Contains(snp.AlternateAlleles, complement(snp.ReferenceAllele))
+}
+
+// complement returns the base pairing with a single-base allele, or the
+// allele itself for anything else, such as D and I.
+func complement(allele string) string {
+	switch allele {
+	case "A":
+		return "T"
+	case "T":
+		return "A"
+	case "C":
+		return "G"
+	case "G":
+		return "C"
+	}
+	return allele
+}
@@ -0,0 +1,90 @@
+package genotype
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mkoziy/genome/exporter/internal/models"
+	"github.com/mkoziy/genome/exporter/internal/repositories/memory"
+)
+
+func TestAnnotateJoinsCallsToStoredVariants(t *testing.T) {
+	ctx := context.Background()
+	repos := memory.NewRepositories()
+	snps := []*models.SNP{
+		{RsID: "rs429358", Chromosome: "19", Position: 44908684, ReferenceAllele: "T", AlternateAlleles: models.StringArray{"C"}, VariantType: models.VariantSNV},
+		{RsID: "rs7412", Chromosome: "19", Position: 44908822, ReferenceAllele: "C", AlternateAlleles: models.StringArray{"T"}, VariantType: models.VariantSNV},
+		{RsID: "rs1801133", Chromosome: "1", Position: 11796321, ReferenceAllele: "G", AlternateAlleles: models.StringArray{"A"}, VariantType: models.VariantSNV},
+		{RsID: "rs9", Chromosome: "1", Position: 9, ReferenceAllele: "A", AlternateAlleles: models.StringArray{"G"}, VariantType: models.VariantSNV},
+	}
+	if err := repos.SNPs.Upsert(ctx, snps); err != nil {
+		t.Fatalf("upsert: %v", err)
+	}
+	clinical := []*models.ClinicalData{{SNPID: snps[0].ID, Source: models.SourceClinVar, ClinicalSignificance: models.ClinicalRiskFactor, ConditionName: "Alzheimer disease"}}
+	if err := repos.Clinical.Upsert(ctx, clinical); err != nil {
+		t.Fatalf("upsert clinical: %v", err)
+	}
+
+	calls := []Call{
+		{RsID: "rs429358", Genotype: "TC"},
+		{RsID: "rs7412", Genotype: "CC"},
+		{RsID: "i3000001", Genotype: "T"},
+		{RsID: "rs1801133"},
+		// Reported on the opposite strand.
+		{RsID: "rs9", Genotype: "TC"},
+		{RsID: "rs404", Genotype: "AA"},
+	}
+	result, err := Annotate(ctx, repos.SNPs, calls, SexUnknown)
+	if err != nil {
+		t.Fatalf("annotate: %v", err)
+	}
+	if result.Calls != 6 || result.NoCalls != 1 || len(result.Annotations) != 4 {
+		t.Fatalf("unexpected result: %d calls, %d no-calls, %d annotations", result.Calls, result.NoCalls, len(result.Annotations))
+	}
+	want := map[string]int{"rs429358": 1, "rs7412": 0, "rs1801133": -1, "rs9": 1}
+	for _, a := range result.Annotations {
+		if a.AltCopies != want[a.Call.RsID] {
+			t.Errorf("%s: expected %d alt copies, got %d", a.Call.RsID, want[a.Call.RsID], a.AltCopies)
+		}
+	}
+	if e4 := result.Annotations[0]; e4.SNP.RsID != "rs429358" || len(e4.SNP.ClinicalData) != 1 {
+		t.Fatalf("expected rs429358 first with its clinical assertion, got %+v", e4.SNP)
+	}
+}
+
+func TestAnnotateReadsXForSex(t *testing.T) {
+	ctx := context.Background()
+	repos := memory.NewRepositories()
+	snps := []*models.SNP{
+		{RsID: "rs5030868", Chromosome: "X", Position: 154532439, ReferenceAllele: "G", AlternateAlleles: models.StringArray{"A"}, VariantType: models.VariantSNV,
+			RiskAlleles: []*models.RiskAllele{{Allele: "A", Effect: "pathogenic", ConditionName: "G6PD deficiency"}}},
+		{RsID: "rs1", Chromosome: "X", Position: 50000000, ReferenceAllele: "C", AlternateAlleles: models.StringArray{"T"}, VariantType: models.VariantSNV},
+	}
+	if err := repos.SNPs.Upsert(ctx, snps); err != nil {
+		t.Fatalf("upsert: %v", err)
+	}
+	calls := []Call{
+		{RsID: "rs5030868", Chromosome: "X", Genotype: "AA"},
+		// Heterozygous on a single X: an error.
+		{RsID: "rs1", Chromosome: "X", Genotype: "CT"},
+	}
+
+	result, err := Annotate(ctx, repos.SNPs, calls, SexMale)
+	if err != nil {
+		t.Fatalf("annotate: %v", err)
+	}
+	if a := result.Annotations[0]; a.AltCopies != 1 || len(a.Findings) != 1 || a.Findings[0].Status != StatusHemizygous {
+		t.Errorf("expected rs5030868 hemizygous with one copy, got %d copies, %+v", a.AltCopies, a.Findings)
+	}
+	if a := result.Annotations[1]; a.AltCopies != -1 {
+		t.Errorf("expected a heterozygous call on a male X unknown, got %d copies", a.AltCopies)
+	}
+
+	result, err = Annotate(ctx, repos.SNPs, calls, SexFemale)
+	if err != nil {
+		t.Fatalf("annotate: %v", err)
+	}
+	if a := result.Annotations[0]; a.AltCopies != 2 || a.Findings[0].Status != StatusHomozygous {
+		t.Errorf("expected rs5030868 homozygous in a female, got %d copies, %+v", a.AltCopies, a.Findings)
+	}
+}
@@ -0,0 +1,174 @@
+package genotype
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/mkoziy/genome/exporter/internal/models"
+)
+
+// ErrFormat is returned for lines a parser cannot read.
+var ErrFormat = errors.New("malformed genotype file")
+
+// ErrUnknownFormat is returned by Parse for files it cannot identify.
+var ErrUnknownFormat = errors.New("unknown genotype file format")
+
+// Format is a raw data file layout.
+type Format string
+
+// Supported formats. MyHeritage and FamilyTreeDNA share a layout but are told
+// apart so results can name the service.
+const (
+	Format23andMe     Format = "23andme"
+	FormatAncestryDNA Format = "ancestrydna"
+	FormatMyHeritage  Format = "myheritage"
+	FormatFTDNA       Format = "ftdna"
+)
+
+// Formats lists the supported formats.
+func Formats() []Format {
+	return []Format{Format23andMe, FormatAncestryDNA, FormatMyHeritage, FormatFTDNA}
+}
+
+// maxLineLength bounds the lines a parser accepts, far above any real line,
+// so a file that is not genotype data fails instead of being buffered whole.
+const maxLineLength = 64 * 1024
+
+// sniffLength is how much of a file Detect needs: enough for the comment
+// block the services put before the data.
+const sniffLength = 8 * 1024
+
+// Detect identifies the format of a file from its first bytes, by the
+// service named in its comments or else by its column header.
+func Detect(head []byte) (Format, error) {
+	switch {
+	case bytes.Contains(head, []byte("23andMe")):
+		return Format23andMe, nil
+	case bytes.Contains(head, []byte("AncestryDNA")):
+		return FormatAncestryDNA, nil
+	case bytes.Contains(head, []byte("MyHeritage")):
+		return FormatMyHeritage, nil
+	}
+
+	sc := bufio.NewScanner(bytes.NewReader(head))
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		header := strings.ToLower(strings.ReplaceAll(line, `"`, ""))
+		switch {
+		case header == "rsid,chromosome,position,result":
+			return FormatFTDNA, nil
+		case strings.HasPrefix(header, "rsid\tchromosome\tposition\tallele1\tallele2"):
+			return FormatAncestryDNA, nil
+		case len(strings.Split(line, "\t")) == 4:
+			return Format23andMe, nil
+		}
+		break
+	}
+	return "", ErrUnknownFormat
+}
+
+// Parse detects the format of r and reads its calls.
+func Parse(r io.Reader) (Format, []Call, error) {
+	br := bufio.NewReaderSize(r, sniffLength)
+	head, err := br.Peek(sniffLength)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, bufio.ErrBufferFull) {
+		return "", nil, fmt.Errorf("read: %w", err)
+	}
+	format, err := Detect(head)
+	if err != nil {
+		return "", nil, err
+	}
+	calls, err := ParseFormat(br, format)
+	return format, calls, err
+}
+
+// ParseFormat reads the calls of r, a file in format.
+func ParseFormat(r io.Reader, format Format) ([]Call, error) {
+	switch format {
+	case Format23andMe:
+		return Parse23andMe(r)
+	case FormatAncestryDNA:
+		return ParseAncestryDNA(r)
+	case FormatMyHeritage, FormatFTDNA:
+		return ParseMyHeritage(r)
+	}
+	return nil, fmt.Errorf("%w %q", ErrUnknownFormat, format)
+}
+
+// lineParser parses one line, returning false for lines without a call such
+// as comments and headers.
+type lineParser func(text string) (Call, bool, error)
+
+// parseLines parses every non-blank line of r with parse.
+func parseLines(r io.Reader, parse lineParser) ([]Call, error) {
+	var calls []Call
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 4096), maxLineLength)
+	for line := 1; sc.Scan(); line++ {
+		text := strings.TrimRight(sc.Text(), "\r")
+		if line == 1 {
+			text = strings.TrimPrefix(text, "\ufeff")
+		}
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+		call, ok, err := parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		if ok {
+			calls = append(calls, call)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("read: %w", err)
+	}
+	return calls, nil
+}
+
+// newCall builds a call from the columns every format has, normalizing the
+// chromosome and genotype.
+func newCall(rsID, chrom, pos, genotype string) (Call, error) {
+	position, err := strconv.ParseInt(strings.TrimSpace(pos), 10, 64)
+	if err != nil || position < 0 {
+		return Call{}, fmt.Errorf("%w: bad position %q", ErrFormat, pos)
+	}
+	call := Call{
+		RsID:       strings.TrimSpace(rsID),
+		Chromosome: normalizeChromosome(chrom),
+		Position:   position,
+		Genotype:   normalizeGenotype(genotype),
+	}
+	if call.RsID == "" {
+		return Call{}, fmt.Errorf("%w: missing rsid", ErrFormat)
+	}
+	// Chip-specific IDs such as i3000001 are kept as they are.
+	if rsID, err := models.NormalizeRsID(call.RsID); err == nil {
+		call.RsID = rsID
+	}
+	if err := checkGenotype(call.Genotype); err != nil {
+		return Call{}, err
+	}
+	return call, nil
+}
+
+// checkGenotype rejects genotypes that are not one or two alleles.
+func checkGenotype(g string) error {
+	if len(g) > 2 {
+		return fmt.Errorf("%w: bad genotype %q", ErrFormat, g)
+	}
+	for _, c := range g {
+		if !strings.ContainsRune("ACGTDI", c) {
+			return fmt.Errorf("%w: bad genotype %q", ErrFormat, g)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package genotype
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestParseDetectsFormats(t *testing.T) {
+	cases := []struct {
+		file   string
+		format Format
+		want   []Call
+	}{
+		{"testdata/23andme.txt", Format23andMe, nil},
+		{"testdata/ancestrydna.txt", FormatAncestryDNA, []Call{
+			{RsID: "rs429358", Chromosome: "19", Position: 45411941, Genotype: "TC"},
+			{RsID: "rs7412", Chromosome: "X", Position: 45412079, Genotype: "CC"},
+			{RsID: "rs3", Chromosome: "X", Position: 100},
+			{RsID: "rs4", Chromosome: "MT", Position: 150, Genotype: "ID"},
+		}},
+		{"testdata/myheritage.csv", FormatMyHeritage, []Call{
+			{RsID: "rs429358", Chromosome: "19", Position: 45411941, Genotype: "TC"},
+			{RsID: "rs7412", Chromosome: "19", Position: 45412079},
+		}},
+		{"testdata/ftdna.csv", FormatFTDNA, []Call{
+			{RsID: "rs429358", Chromosome: "19", Position: 45411941, Genotype: "TC"},
+			{RsID: "rs7412", Chromosome: "X", Position: 45412079, Genotype: "C"},
+		}},
+	}
+	for _, tc := range cases {
+		f, err := os.Open(tc.file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		format, calls, err := Parse(f)
+		_ = f.Close()
+		if err != nil {
+			t.Fatalf("%s: %v", tc.file, err)
+		}
+		if format != tc.format {
+			t.Errorf("%s: expected format %s, got %s", tc.file, tc.format, format)
+		}
+		if tc.want == nil {
+			continue
+		}
+		if len(calls) != len(tc.want) {
+			t.Fatalf("%s: expected %d calls, got %+v", tc.file, len(tc.want), calls)
+		}
+		for i := range tc.want {
+			if calls[i] != tc.want[i] {
+				t.Errorf("%s call %d: expected %+v, got %+v", tc.file, i, tc.want[i], calls[i])
+			}
+		}
+	}
+}
+
+func TestDetectByHeaderWithoutComments(t *testing.T) {
+	cases := map[string]Format{
+		"rs1\t1\t100\tAA\n": Format23andMe,
+		"rsid\tchromosome\tposition\tallele1\tallele2\n":    FormatAncestryDNA,
+		"\"RSID\",\"CHROMOSOME\",\"POSITION\",\"RESULT\"\n": FormatFTDNA,
+	}
+	for head, want := range cases {
+		if got, err := Detect([]byte(head)); err != nil || got != want {
+			t.Errorf("%q: expected %s, got %s (%v)", head, want, got, err)
+		}
+	}
+	if _, _, err := Parse(strings.NewReader("##fileformat=VCFv4.2\nnot,a,genotype\n")); !errors.Is(err, ErrUnknownFormat) {
+		t.Fatalf("expected ErrUnknownFormat, got %v", err)
+	}
+}
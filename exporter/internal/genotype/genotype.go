@@ -0,0 +1,63 @@
+// Package genotype reads the raw data files consumer genotyping services let
+// their customers download and annotates each call with what the database
+// knows about the variant.
+package genotype
+
+import (
+	"strings"
+
+	"github.com/mkoziy/genome/exporter/internal/variant"
+)
+
+// Call is one genotyped marker of a raw data file. Position is on the
+// assembly the file was produced against, which for consumer chips is
+// usually GRCh37 rather than the GRCh38 the database uses, so calls are
+// matched to the database by rsID only.
+type Call struct {
+	RsID       string `json:"rsid"`
+	Chromosome string `json:"chromosome"`
+	Position   int64  `json:"position"`
+	// Genotype holds one letter per called allele: two for diploid calls and
+	// one for haploid ones such as Y or MT, with D and I standing for a
+	// deletion and an insertion. It is empty when the marker was not called.
+	Genotype string `json:"genotype,omitempty"`
+}
+
+// NoCall reports whether the marker was not called.
+func (c Call) NoCall() bool {
+	return c.Genotype == ""
+}
+
+// Alleles returns the called alleles, none for a no-call.
+func (c Call) Alleles() []string {
+	alleles := make([]string, len(c.Genotype))
+	for i := range c.Genotype {
+		alleles[i] = c.Genotype[i : i+1]
+	}
+	return alleles
+}
+
+// normalizeGenotype upper-cases g and maps no-calls to "". Services mark an
+// allele the chip failed to call with - or, AncestryDNA, 0; a call with
+// either allele missing is treated as not called at all.
+func normalizeGenotype(g string) string {
+	g = strings.ToUpper(strings.TrimSpace(g))
+	if strings.ContainsAny(g, "-0") {
+		return ""
+	}
+	return g
+}
+
+// normalizeChromosome maps chromosome names to the database's, including the
+// AncestryDNA codes 25 for the pseudoautosomal regions, which are part of
+// X, and 26 for the mitochondrion.
+func normalizeChromosome(chrom string) string {
+	switch c := variant.NormalizeChromosome(chrom); c {
+	case "25", "XY":
+		return "X"
+	case "26":
+		return "MT"
+	default:
+		return c
+	}
+}
@@ -0,0 +1,119 @@
+package genotype
+
+import (
+	"slices"
+
+	"github.com/mkoziy/genome/exporter/internal/models"
+)
+
+// Status is what a genotype means for one risk allele.
+type Status string
+
+const (
+	// StatusNotCarried means no copy of the allele was called.
+	StatusNotCarried Status = "not_carried"
+	// StatusCarrier means one copy of the allele of a recessive condition,
+	// which is not enough to be affected.
+	StatusCarrier Status = "carrier"
+	// StatusHeterozygous means one copy of the allele and one other.
+	StatusHeterozygous Status = "heterozygous"
+	// StatusHomozygous means two copies of the allele.
+	StatusHomozygous Status = "homozygous"
+	// StatusHemizygous means the only copy of a haploid region, such as X in
+	// males, Y or MT, is the allele.
+	StatusHemizygous Status = "hemizygous"
+	// StatusHeteroplasmic means some copies of the mitochondrial genome carry
+	// the allele and others do not.
+	StatusHeteroplasmic Status = "heteroplasmic"
+	// StatusUnknown means the genotype is missing or could not be matched to
+	// the SNP's alleles.
+	StatusUnknown Status = "unknown"
+)
+
+// Finding is a genotype's status for one risk allele of its SNP.
+type Finding struct {
+	RiskAllele *models.RiskAllele `json:"risk_allele"`
+	// Copies of the allele called, or -1 when unknown.
+	Copies int    `json:"copies"`
+	Status Status `json:"status"`
+	// Heteroplasmy is the share of the mitochondrial genome's copies
+	// carrying the allele, for a heteroplasmic finding whose caller
+	// measured it.
+	Heteroplasmy *float64 `json:"heteroplasmy,omitempty"`
+}
+
+// Interpret reports the status of a genotype for each risk allele. alleles
+// are the called alleles written as the SNP writes its own, on its strand,
+// with "" for one that is neither; nil if the genotype is unknown.
+func Interpret(alleles []string, risks []*models.RiskAllele) []Finding {
+	findings := make([]Finding, 0, len(risks))
+	for _, risk := range risks {
+		f := Finding{RiskAllele: risk, Copies: -1, Status: StatusUnknown}
+		if len(alleles) > 0 {
+			f.Copies = 0
+			for _, a := range alleles {
+				if a == risk.Allele {
+					f.Copies++
+				}
+			}
+			f.Status = status(f.Copies, len(alleles), risk)
+		}
+		findings = append(findings, f)
+	}
+	return findings
+}
+
+// interpretHeteroplasmy reports the status of a heteroplasmic mitochondrial
+// genotype for each risk allele: heteroplasmic for those among alleles,
+// with level as the heteroplasmy of alt if the caller measured it.
+func interpretHeteroplasmy(alleles []string, risks []*models.RiskAllele, alt string, level *float64) []Finding {
+	findings := make([]Finding, 0, len(risks))
+	for _, risk := range risks {
+		f := Finding{RiskAllele: risk, Copies: 0, Status: StatusNotCarried}
+		if slices.Contains(alleles, risk.Allele) {
+			f.Copies, f.Status = 1, StatusHeteroplasmic
+			if risk.Allele == alt {
+				f.Heteroplasmy = level
+			}
+		}
+		findings = append(findings, f)
+	}
+	return findings
+}
+
+// MatchGenotype returns the effects of the genotype alleles make up, written
+// as the SNP writes its own, among effects; none if the genotype is unknown
+// or has an allele that is neither the SNP's reference nor an alternate. A
+// haploid genotype has the effects of the homozygous one: its only copy is
+// the allele.
+func MatchGenotype(alleles []string, effects []*models.GenotypeEffect) []*models.GenotypeEffect {
+	if len(alleles) == 0 || slices.Contains(alleles, "") {
+		return nil
+	}
+	if len(alleles) == 1 {
+		alleles = []string{alleles[0], alleles[0]}
+	}
+	genotype := models.GenotypeOf(alleles...)
+	var matched []*models.GenotypeEffect
+	for _, e := range effects {
+		if e.Genotype == genotype {
+			matched = append(matched, e)
+		}
+	}
+	return matched
+}
+
+func status(copies, ploidy int, risk *models.RiskAllele) Status {
+	switch {
+	case copies == 0:
+		return StatusNotCarried
+	case ploidy == 1:
+		return StatusHemizygous
+	case copies == ploidy:
+		return StatusHomozygous
+	case risk.IsRecessive():
+		return StatusCarrier
+	default:
+		return StatusHeterozygous
+	}
+}
@@ -0,0 +1,48 @@
+package genotype
+
+import (
+	"testing"
+
+	"github.com/mkoziy/genome/exporter/internal/models"
+)
+
+func TestInterpret(t *testing.T) {
+	recessive := "Autosomal recessive inheritance"
+	dominant := &models.RiskAllele{Allele: "C", Effect: "pathogenic", ConditionName: "A"}
+	carrier := &models.RiskAllele{Allele: "C", Effect: "pathogenic", ConditionName: "B", Inheritance: &recessive}
+	cases := []struct {
+		alleles []string
+		risk    *models.RiskAllele
+		copies  int
+		want    Status
+	}{
+		{[]string{"T", "T"}, dominant, 0, StatusNotCarried},
+		{[]string{"T", "C"}, dominant, 1, StatusHeterozygous},
+		{[]string{"T", "C"}, carrier, 1, StatusCarrier},
+		{[]string{"C", "C"}, carrier, 2, StatusHomozygous},
+		{[]string{"C"}, carrier, 1, StatusHemizygous},
+		{[]string{"C", ""}, dominant, 1, StatusHeterozygous},
+		{nil, dominant, -1, StatusUnknown},
+	}
+	for _, c := range cases {
+		got := Interpret(c.alleles, []*models.RiskAllele{c.risk})
+		if len(got) != 1 || got[0].Copies != c.copies || got[0].Status != c.want {
+			t.Errorf("%v for %s: expected %d copies, %s; got %+v", c.alleles, c.risk.ConditionName, c.copies, c.want, got)
+		}
+	}
+}
+
+func TestMatchGenotype(t *testing.T) {
+	effects := []*models.GenotypeEffect{
+		{Genotype: "C/T", Effect: "carrier"},
+		{Genotype: "C/C", Effect: "pathogenic"},
+	}
+	if got := MatchGenotype([]string{"T", "C"}, effects); len(got) != 1 || got[0].Effect != "carrier" {
+		t.Errorf("expected the heterozygous effect, got %+v", got)
+	}
+	for _, alleles := range [][]string{{"T", "T"}, {"C", ""}, nil} {
+		if got := MatchGenotype(alleles, effects); len(got) != 0 {
+			t.Errorf("%v: expected no effects, got %+v", alleles, got)
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package genotype
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ParseMyHeritage reads a MyHeritage or FamilyTreeDNA raw data export:
+// comma-separated, usually quoted RSID, CHROMOSOME, POSITION and RESULT
+// columns after optional # comments and a header line. No-calls are --.
+func ParseMyHeritage(r io.Reader) ([]Call, error) {
+	return parseLines(r, parseMyHeritageLine)
+}
+
+func parseMyHeritageLine(text string) (Call, bool, error) {
+	if strings.HasPrefix(text, "#") {
+		return Call{}, false, nil
+	}
+	fields := strings.Split(text, ",")
+	if len(fields) != 4 {
+		return Call{}, false, fmt.Errorf("%w: want 4 comma-separated columns, got %d", ErrFormat, len(fields))
+	}
+	for i, f := range fields {
+		fields[i] = strings.Trim(strings.TrimSpace(f), `"`)
+	}
+	if strings.EqualFold(fields[0], "rsid") {
+		return Call{}, false, nil
+	}
+	call, err := newCall(fields[0], fields[1], fields[2], fields[3])
+	return call, err == nil, err
+}
@@ -0,0 +1,162 @@
+package genotype
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/mkoziy/genome/exporter/internal/models"
+	"github.com/mkoziy/genome/exporter/internal/variant"
+)
+
+// Sex is the chromosomal sex of the person a genotype is of, which decides
+// how many copies of X and Y they carry.
+type Sex string
+
+// Sexes. SexUnknown leaves calls on X and Y as the file writes them.
+const (
+	SexUnknown Sex = ""
+	SexMale    Sex = "male"
+	SexFemale  Sex = "female"
+)
+
+// ParseSex parses a sex as given on the command line: male or female, in any
+// case, or auto or nothing for SexUnknown.
+func ParseSex(s string) (Sex, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "auto":
+		return SexUnknown, nil
+	case "male", "m", "xy":
+		return SexMale, nil
+	case "female", "f", "xx":
+		return SexFemale, nil
+	}
+	return SexUnknown, fmt.Errorf("unknown sex %q: want male, female or auto", s)
+}
+
+// pseudoautosomal are the pseudoautosomal regions of X and Y on GRCh38,
+// which pair in males as autosomes do.
+var pseudoautosomal = map[string][][2]int64{
+	"X": {{10001, 2781479}, {155701383, 156030895}},
+	"Y": {{10001, 2781479}, {56887903, 57217415}},
+}
+
+// pseudoautosomalGRCh37 are the pseudoautosomal regions of X on GRCh37,
+// the assembly of most raw data files.
+var pseudoautosomalGRCh37 = [][2]int64{{60001, 2699520}, {154931044, 155260560}}
+
+// Pseudoautosomal reports whether GRCh38 position pos on chrom lies in a
+// pseudoautosomal region of X or Y.
+func Pseudoautosomal(chrom string, pos int64) bool {
+	for _, r := range pseudoautosomal[variant.NormalizeChromosome(chrom)] {
+		if pos >= r[0] && pos <= r[1] {
+			return true
+		}
+	}
+	return false
+}
+
+// Ploidy returns how many copies of GRCh38 position pos on chrom a person of
+// sex carries: one of the mitochondrion, whose many copies are read as one,
+// and outside the pseudoautosomal regions one of X and Y in males and none
+// of Y in females. It returns false on X and Y outside the pseudoautosomal
+// regions when sex is unknown.
+func Ploidy(chrom string, pos int64, sex Sex) (int, bool) {
+	switch chrom = variant.NormalizeChromosome(chrom); chrom {
+	case "MT":
+		return 1, true
+	case "X", "Y":
+		if Pseudoautosomal(chrom, pos) {
+			return 2, true
+		}
+		switch {
+		case sex == SexMale:
+			return 1, true
+		case sex == SexFemale && chrom == "X":
+			return 2, true
+		case sex == SexFemale:
+			return 0, true
+		}
+		return 0, false
+	}
+	return 2, true
+}
+
+// fitPloidy returns alleles called at a position of ploidy copies, the way
+// the genotype is meant: a haploid position called homozygous, as chips and
+// diploid callers report them, is one allele. It returns nil for a call
+// where there is no copy, and false for a heterozygous call at a haploid
+// position, which on the mitochondrion is heteroplasmy and elsewhere an
+// error.
+func fitPloidy(alleles []string, ploidy int) ([]string, bool) {
+	switch {
+	case ploidy == 0:
+		return nil, true
+	case ploidy != 1 || len(alleles) <= 1:
+		return alleles, true
+	}
+	for _, a := range alleles[1:] {
+		if a != alleles[0] {
+			return alleles, false
+		}
+	}
+	return alleles[:1], true
+}
+
+// minSexCalls is how many calls on X outside the pseudoautosomal regions
+// InferSex needs.
+const minSexCalls = 20
+
+// maxMaleHeterozygosity is the largest share of those calls that may be
+// heterozygous in a male: beyond genotyping errors, a single X shows none.
+// Females' are heterozygous at a far larger share of the markers of a chip.
+const maxMaleHeterozygosity = 0.02
+
+// InferSex infers the chromosomal sex of the person calls are of from the
+// heterozygosity of X outside the pseudoautosomal regions. As positions may
+// be on GRCh37 or GRCh38, calls in the regions of either are left out. It
+// returns SexUnknown if too few markers on X were called.
+func InferSex(calls []Call) Sex {
+	var called, heterozygous int
+	for _, call := range calls {
+		if call.Chromosome != "X" || call.NoCall() || Pseudoautosomal("X", call.Position) {
+			continue
+		}
+		if slices.ContainsFunc(pseudoautosomalGRCh37, func(r [2]int64) bool { return call.Position >= r[0] && call.Position <= r[1] }) {
+			continue
+		}
+		called++
+		if alleles := call.Alleles(); len(alleles) == 2 && alleles[0] != alleles[1] {
+			heterozygous++
+		}
+	}
+	if called < minSexCalls {
+		return SexUnknown
+	}
+	if float64(heterozygous)/float64(called) <= maxMaleHeterozygosity {
+		return SexMale
+	}
+	return SexFemale
+}
+
+// interpretAt fits alleles, called at snp, to the copies a person of sex
+// carries there and returns them with their findings and effects. A
+// heterozygous call at a haploid position is heteroplasmy on the
+// mitochondrion, with level the heteroplasmy of alt if measured, and an
+// unknown genotype elsewhere.
+func interpretAt(alleles []string, snp *models.SNP, sex Sex, alt string, level *float64) ([]string, []Finding, []*models.GenotypeEffect) {
+	if alleles != nil {
+		if ploidy, ok := Ploidy(snp.Chromosome, snp.Position, sex); ok {
+			fitted, fits := fitPloidy(alleles, ploidy)
+			switch {
+			case !fits && variant.NormalizeChromosome(snp.Chromosome) == "MT":
+				return alleles, interpretHeteroplasmy(alleles, snp.RiskAlleles, alt, level), nil
+			case !fits:
+				alleles = nil
+			default:
+				alleles = fitted
+			}
+		}
+	}
+	return alleles, Interpret(alleles, snp.RiskAlleles), MatchGenotype(alleles, snp.GenotypeEffects)
+}
@@ -0,0 +1,81 @@
+package genotype
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+)
+
+func TestPloidy(t *testing.T) {
+	tests := []struct {
+		chrom string
+		pos   int64
+		sex   Sex
+		want  int
+		known bool
+	}{
+		{"1", 100, SexUnknown, 2, true},
+		{"chrM", 3243, SexFemale, 1, true},
+		{"X", 50000000, SexMale, 1, true},
+		{"X", 50000000, SexFemale, 2, true},
+		{"X", 50000000, SexUnknown, 0, false},
+		// PAR1 pairs in males.
+		{"X", 100000, SexMale, 2, true},
+		{"Y", 100000, SexMale, 2, true},
+		{"Y", 10000000, SexMale, 1, true},
+		{"Y", 10000000, SexFemale, 0, true},
+	}
+	for _, tt := range tests {
+		got, known := Ploidy(tt.chrom, tt.pos, tt.sex)
+		if got != tt.want || known != tt.known {
+			t.Errorf("Ploidy(%s, %d, %q) = %d, %v; want %d, %v", tt.chrom, tt.pos, tt.sex, got, known, tt.want, tt.known)
+		}
+	}
+}
+
+func TestFitPloidy(t *testing.T) {
+	if got, ok := fitPloidy([]string{"A", "A"}, 1); !ok || !slices.Equal(got, []string{"A"}) {
+		t.Errorf("expected a homozygous haploid call collapsed, got %v, %v", got, ok)
+	}
+	if _, ok := fitPloidy([]string{"A", "G"}, 1); ok {
+		t.Errorf("expected a heterozygous haploid call not to fit")
+	}
+	if got, ok := fitPloidy([]string{"A", "G"}, 2); !ok || len(got) != 2 {
+		t.Errorf("expected a diploid call kept, got %v, %v", got, ok)
+	}
+	if got, ok := fitPloidy([]string{"A"}, 0); !ok || got != nil {
+		t.Errorf("expected no alleles where there is no copy, got %v", got)
+	}
+}
+
+func TestInferSex(t *testing.T) {
+	calls := func(heterozygous int) []Call {
+		var calls []Call
+		for i := range 40 {
+			g := "AA"
+			if i < heterozygous {
+				g = "AG"
+			}
+			calls = append(calls, Call{RsID: fmt.Sprintf("rs%d", i+1), Chromosome: "X", Position: int64(10000000 + i), Genotype: g})
+		}
+		// Heterozygous in the pseudoautosomal region, whatever the sex.
+		return append(calls, Call{RsID: "rs99", Chromosome: "X", Position: 100000, Genotype: "AG"})
+	}
+	if got := InferSex(calls(0)); got != SexMale {
+		t.Errorf("expected male, got %q", got)
+	}
+	if got := InferSex(calls(10)); got != SexFemale {
+		t.Errorf("expected female, got %q", got)
+	}
+	if got := InferSex(calls(0)[:10]); got != SexUnknown {
+		t.Errorf("expected too few calls to tell, got %q", got)
+	}
+	for _, s := range []string{"auto", "M", "xx"} {
+		if _, err := ParseSex(s); err != nil {
+			t.Errorf("ParseSex(%q): %v", s, err)
+		}
+	}
+	if _, err := ParseSex("unknown"); err == nil {
+		t.Errorf("expected an unknown sex rejected")
+	}
+}
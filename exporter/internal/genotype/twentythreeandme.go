@@ -0,0 +1,27 @@
+package genotype
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Parse23andMe reads a 23andMe raw data export: tab-separated rsid,
+// chromosome, position and genotype columns after a block of # comments.
+// Markers 23andMe has no rsID for carry internal IDs such as i3000001 and
+// are kept, though they never match the database.
+func Parse23andMe(r io.Reader) ([]Call, error) {
+	return parseLines(r, parse23andMeLine)
+}
+
+func parse23andMeLine(text string) (Call, bool, error) {
+	if strings.HasPrefix(text, "#") {
+		return Call{}, false, nil
+	}
+	fields := strings.Split(text, "\t")
+	if len(fields) != 4 {
+		return Call{}, false, fmt.Errorf("%w: want 4 tab-separated columns, got %d", ErrFormat, len(fields))
+	}
+	call, err := newCall(fields[0], fields[1], fields[2], fields[3])
+	return call, err == nil, err
+}
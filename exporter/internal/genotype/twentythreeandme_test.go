@@ -0,0 +1,53 @@
+package genotype
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestParse23andMe(t *testing.T) {
+	f, err := os.Open("testdata/23andme.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = f.Close() }()
+
+	calls, err := Parse23andMe(f)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	want := []Call{
+		{RsID: "rs429358", Chromosome: "19", Position: 45411941, Genotype: "TC"},
+		{RsID: "rs7412", Chromosome: "19", Position: 45412079, Genotype: "CC"},
+		{RsID: "i3000001", Chromosome: "MT", Position: 150, Genotype: "T"},
+		{RsID: "rs1801133", Chromosome: "1", Position: 11856378},
+	}
+	if len(calls) != len(want) {
+		t.Fatalf("expected %d calls, got %+v", len(want), calls)
+	}
+	for i := range want {
+		if calls[i] != want[i] {
+			t.Errorf("call %d: expected %+v, got %+v", i, want[i], calls[i])
+		}
+	}
+	if !calls[3].NoCall() || len(calls[3].Alleles()) != 0 || strings.Join(calls[0].Alleles(), "/") != "T/C" {
+		t.Fatalf("unexpected alleles: %v %v", calls[0].Alleles(), calls[3].Alleles())
+	}
+}
+
+func TestParse23andMeRejectsMalformedLines(t *testing.T) {
+	for _, line := range []string{
+		"rs1\t1\t100",
+		"rs1\t1\tabc\tAA",
+		"rs1\t1\t100\tAAA",
+		"rs1\t1\t100\tAN",
+		"\t1\t100\tAA",
+	} {
+		_, err := Parse23andMe(strings.NewReader("# header\n" + line + "\n"))
+		if !errors.Is(err, ErrFormat) || !strings.HasPrefix(err.Error(), "line 2:") {
+			t.Errorf("%q: expected a format error on line 2, got %v", line, err)
+		}
+	}
+}
@@ -0,0 +1,441 @@
+package genotype
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/mkoziy/genome/exporter/internal/liftover"
+	"github.com/mkoziy/genome/exporter/internal/models"
+	"github.com/mkoziy/genome/exporter/internal/repositories"
+	"github.com/mkoziy/genome/exporter/internal/variant"
+)
+
+// vcfFixedColumns are the columns before the first sample: CHROM to FORMAT.
+const vcfFixedColumns = 9
+
+// Site is a sample's genotype at one alternate allele of a VCF record. A
+// multi-allelic record is split into a site per alternate allele, each
+// matched to the database on its own.
+type Site struct {
+	// RsID is the record's ID when it is an rsID.
+	RsID       string `json:"rsid,omitempty"`
+	Chromosome string `json:"chromosome"`
+	Position   int64  `json:"position"`
+	Ref        string `json:"ref"`
+	Alt        string `json:"alt"`
+	// Genotype is the sample's GT as written, e.g. 0/1 or 1|2.
+	Genotype string `json:"genotype"`
+	// AltCopies is how many of the sample's alleles are Alt, or -1 if the
+	// genotype is missing.
+	AltCopies int `json:"alt_copies"`
+	// RefCopies is how many are Ref; alleles that are neither are another
+	// alternate allele of the record.
+	RefCopies int `json:"ref_copies"`
+	// Ploidy is how many alleles the genotype has.
+	Ploidy int `json:"ploidy"`
+	// Heteroplasmy is the share of the mitochondrial genome's copies
+	// carrying Alt, for sites on the mitochondrion whose caller measured it
+	// as FORMAT HF or AF.
+	Heteroplasmy *float64 `json:"heteroplasmy,omitempty"`
+}
+
+// homoplasmyLevel is the heteroplasmy from which Alt counts as in every
+// copy of the mitochondrial genome, allowing for sequencing errors.
+const homoplasmyLevel = 0.95
+
+// heteroplasmic reports whether the site is on the mitochondrion and Alt is
+// in some but not all of its copies, by the measured heteroplasmy if any and
+// by the genotype otherwise.
+func (s Site) heteroplasmic() bool {
+	if s.Chromosome != "MT" || s.AltCopies <= 0 {
+		return false
+	}
+	if s.Heteroplasmy != nil {
+		return *s.Heteroplasmy < homoplasmyLevel
+	}
+	return s.AltCopies < s.Ploidy
+}
+
+// NoCall reports whether the sample has no genotype at the site.
+func (s Site) NoCall() bool {
+	return s.AltCopies < 0
+}
+
+// keys returns the variant keys the site may be stored under: its own and,
+// for an anchored indel, the unanchored form ClinVar uses.
+func (s Site) keys() []string {
+	var keys []string
+	if key, ok := variant.Key(s.Chromosome, s.Position, s.Ref, []string{s.Alt}); ok {
+		keys = append(keys, key)
+	}
+	if key, ok := variant.UnanchoredKey(s.Chromosome, s.Position, s.Ref, s.Alt); ok {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// VCFReader reads the genotypes of one sample from a VCF file, record by
+// record, so whole-genome files need not fit in memory.
+type VCFReader struct {
+	sc     *bufio.Scanner
+	line   int
+	column int
+	chain  *liftover.Chain
+	// Sample is the name of the sample read.
+	Sample string
+	// Assembly is the assembly the header's ##reference or ##contig lines
+	// name, GRCh37 or GRCh38, or "" if they name neither.
+	Assembly string
+	// Unlifted counts the sites skipped because they do not map onto
+	// GRCh38, when reading through a chain.
+	Unlifted int
+	// Sex is the sample's sex, if known, which sets how its sites on X and
+	// Y are read; see Ploidy.
+	Sex Sex
+}
+
+// NewVCFReader reads the header of r and returns a reader of the named
+// sample's genotypes, or of the first sample's if sample is empty.
+// Coordinates are read as they are; the database's are GRCh38, so a GRCh37
+// file must be read through LiftOver.
+func NewVCFReader(r io.Reader, sample string) (*VCFReader, error) {
+	v := &VCFReader{sc: bufio.NewScanner(r)}
+	// INFO columns of annotated files can be long.
+	v.sc.Buffer(make([]byte, 0, 64*1024), 16*maxLineLength)
+	for v.sc.Scan() {
+		v.line++
+		text := v.sc.Text()
+		if strings.HasPrefix(text, "##") {
+			if v.Assembly == "" {
+				v.Assembly = headerAssembly(text)
+			}
+			continue
+		}
+		if !strings.HasPrefix(text, "#CHROM") {
+			return nil, fmt.Errorf("line %d: %w: expected the #CHROM header", v.line, ErrFormat)
+		}
+		samples := strings.Split(strings.TrimRight(text, "\r"), "\t")
+		if len(samples) <= vcfFixedColumns {
+			return nil, fmt.Errorf("%w: the VCF has no samples", ErrFormat)
+		}
+		samples = samples[vcfFixedColumns:]
+		i := 0
+		if sample != "" {
+			if i = slices.Index(samples, sample); i < 0 {
+				return nil, fmt.Errorf("sample %q not in the VCF (it has %s)", sample, strings.Join(samples, ", "))
+			}
+		}
+		v.column, v.Sample = vcfFixedColumns+i, samples[i]
+		return v, nil
+	}
+	if err := v.sc.Err(); err != nil {
+		return nil, fmt.Errorf("read: %w", err)
+	}
+	return nil, fmt.Errorf("%w: no #CHROM header", ErrFormat)
+}
+
+// headerAssembly returns the assembly a ##reference or ##contig header line
+// names, such as GRCh37 for "##reference=file:///ref/human_g1k_v37.fasta" or
+// "##contig=<ID=1,length=249250621,assembly=b37>", or "".
+func headerAssembly(line string) string {
+	var value string
+	switch {
+	case strings.HasPrefix(line, "##reference="):
+		value = strings.TrimPrefix(line, "##reference=")
+	case strings.HasPrefix(line, "##contig="):
+		_, value, _ = strings.Cut(line, "assembly=")
+	default:
+		return ""
+	}
+	tokens := strings.FieldsFunc(value, func(r rune) bool {
+		return !('a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || '0' <= r && r <= '9')
+	})
+	for _, token := range tokens {
+		if a := liftover.NormalizeAssembly(token); a != "" {
+			return a
+		}
+		// Reference file names such as human_g1k_v37 or
+		// Homo_sapiens_assembly38 end in the number.
+		if a := liftover.NormalizeAssembly(strings.TrimLeft(token, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ")); a != "" {
+			return a
+		}
+	}
+	return ""
+}
+
+// LiftOver makes v convert every site's coordinates and alleles through
+// chain, from the file's assembly onto GRCh38, skipping and counting in
+// Unlifted the sites that do not map.
+func (v *VCFReader) LiftOver(chain *liftover.Chain) {
+	v.chain = chain
+}
+
+// Read returns the sites of the next record, skipping symbolic alleles such
+// as <DEL> and *, which name no sequence to look up. It returns io.EOF after
+// the last record.
+func (v *VCFReader) Read() ([]Site, error) {
+	for v.sc.Scan() {
+		v.line++
+		text := strings.TrimRight(v.sc.Text(), "\r")
+		if text == "" {
+			continue
+		}
+		sites, err := v.parseRecord(text)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", v.line, err)
+		}
+		if len(sites) > 0 {
+			return sites, nil
+		}
+	}
+	if err := v.sc.Err(); err != nil {
+		return nil, fmt.Errorf("read: %w", err)
+	}
+	return nil, io.EOF
+}
+
+func (v *VCFReader) parseRecord(text string) ([]Site, error) {
+	fields := strings.Split(text, "\t")
+	if len(fields) <= v.column {
+		return nil, fmt.Errorf("%w: want %d tab-separated columns, got %d", ErrFormat, v.column+1, len(fields))
+	}
+	pos, err := strconv.ParseInt(fields[1], 10, 64)
+	if err != nil || pos <= 0 {
+		return nil, fmt.Errorf("%w: bad position %q", ErrFormat, fields[1])
+	}
+	format := strings.Split(fields[8], ":")
+	gtIndex := slices.Index(format, "GT")
+	if gtIndex < 0 {
+		return nil, fmt.Errorf("%w: no GT in FORMAT %q", ErrFormat, fields[8])
+	}
+	values := strings.Split(fields[v.column], ":")
+	gt := ""
+	if gtIndex < len(values) {
+		gt = values[gtIndex]
+	}
+	chrom := normalizeChromosome(fields[0])
+	var levels []string
+	if chrom == "MT" {
+		levels = heteroplasmyLevels(format, values)
+	}
+	alleles, err := parseGT(gt)
+	if err != nil {
+		return nil, err
+	}
+
+	var rsID string
+	for _, id := range strings.Split(fields[2], ";") {
+		if isRsID(id) {
+			rsID, _ = models.NormalizeRsID(id)
+			break
+		}
+	}
+	var sites []Site
+	for i, alt := range strings.Split(fields[4], ",") {
+		if alt == "*" || alt == "." || strings.ContainsAny(alt, "<>[]") {
+			continue
+		}
+		copies, refCopies := -1, 0
+		if alleles != nil {
+			copies = 0
+			for _, a := range alleles {
+				switch a {
+				case i + 1:
+					copies++
+				case 0:
+					refCopies++
+				}
+			}
+		}
+		site := Site{
+			RsID:       rsID,
+			Chromosome: chrom,
+			Position:   pos,
+			Ref:        strings.ToUpper(fields[3]),
+			Alt:        strings.ToUpper(alt),
+			Genotype:   gt,
+			AltCopies:  copies,
+			RefCopies:  refCopies,
+			Ploidy:     len(alleles),
+		}
+		if i < len(levels) {
+			if level, err := strconv.ParseFloat(levels[i], 64); err == nil && level >= 0 && level <= 1 {
+				site.Heteroplasmy = &level
+			}
+		}
+		sites = append(sites, site)
+	}
+	if v.chain != nil {
+		sites = v.lift(sites)
+	}
+	return sites, nil
+}
+
+// heteroplasmyLevels returns the heteroplasmy of each alternate allele of a
+// record on the mitochondrion, from FORMAT HF, as mtDNA-Server writes it,
+// or else AF, as Mutect2 does.
+func heteroplasmyLevels(format, values []string) []string {
+	for _, key := range []string{"HF", "AF"} {
+		if i := slices.Index(format, key); i >= 0 && i < len(values) && values[i] != "." {
+			return strings.Split(values[i], ",")
+		}
+	}
+	return nil
+}
+
+// lift maps sites through v's chain, dropping those that do not map.
+func (v *VCFReader) lift(sites []Site) []Site {
+	lifted := sites[:0]
+	for _, s := range sites {
+		locus, ref, alts, ok := v.chain.MapVariant(s.Chromosome, s.Position, s.Ref, []string{s.Alt})
+		if !ok {
+			v.Unlifted++
+			continue
+		}
+		s.Chromosome, s.Position, s.Ref, s.Alt = locus.Chromosome, locus.Position, ref, alts[0]
+		lifted = append(lifted, s)
+	}
+	return lifted
+}
+
+// Alleles returns the sample's alleles written as snp, which the site
+// matched, writes its own, or nil if the genotype is missing.
+func (s Site) Alleles(snp *models.SNP) []string {
+	if s.NoCall() || len(snp.AlternateAlleles) != 1 {
+		return nil
+	}
+	alleles := make([]string, 0, s.Ploidy)
+	for range s.AltCopies {
+		alleles = append(alleles, snp.AlternateAlleles[0])
+	}
+	for range s.RefCopies {
+		alleles = append(alleles, snp.ReferenceAllele)
+	}
+	for len(alleles) < s.Ploidy {
+		alleles = append(alleles, "")
+	}
+	return alleles
+}
+
+// parseGT returns the allele indexes of a GT value, phased or not, or nil if
+// any allele is missing.
+func parseGT(gt string) ([]int, error) {
+	if gt == "" || gt == "." {
+		return nil, nil
+	}
+	parts := strings.FieldsFunc(gt, func(r rune) bool { return r == '/' || r == '|' })
+	alleles := make([]int, len(parts))
+	for i, p := range parts {
+		if p == "." {
+			return nil, nil
+		}
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("%w: bad genotype %q", ErrFormat, gt)
+		}
+		alleles[i] = n
+	}
+	return alleles, nil
+}
+
+// isRsID reports whether a VCF ID is an rsID, in any case. A bare number is
+// not: IDs are free text.
+func isRsID(id string) bool {
+	_, ok := models.RsIDNumber(id)
+	return ok && len(id) > 2 && strings.EqualFold(id[:2], "rs")
+}
+
+// SiteAnnotation joins a site to the database's record of its variant.
+type SiteAnnotation struct {
+	Site Site        `json:"site"`
+	SNP  *models.SNP `json:"snp"`
+	// Findings are the sample's status for each risk allele of the SNP.
+	Findings []Finding `json:"findings,omitempty"`
+	// Effects are the SNP's effects of the sample's genotype.
+	Effects []*models.GenotypeEffect `json:"genotype_effects,omitempty"`
+}
+
+// VCFResult is a sample annotated against the database.
+type VCFResult struct {
+	Sample string `json:"sample"`
+	// Annotations are the sites of variants in the database, in file order.
+	Annotations []SiteAnnotation `json:"annotations"`
+	Sites       int              `json:"sites"`
+	NoCalls     int              `json:"no_calls"`
+	// Unlifted counts the sites of a GRCh37 file that do not map onto
+	// GRCh38, left out of Sites.
+	Unlifted int `json:"unlifted,omitempty"`
+	// Sex is the sex the sites on X and Y were read for.
+	Sex Sex `json:"sex,omitempty"`
+}
+
+// AnnotateVCF matches every site of vr to the database by position and
+// alleles, in batches, so records the database files under another rsID or
+// none still match. Anchored indels also match their unanchored form.
+func AnnotateVCF(ctx context.Context, snps repositories.SNPRepository, vr *VCFReader) (*VCFResult, error) {
+	result := &VCFResult{Sample: vr.Sample, Sex: vr.Sex}
+	batch := make([]Site, 0, lookupBatchSize)
+	for {
+		sites, err := vr.Read()
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, err
+		}
+		batch = append(batch, sites...)
+		if len(batch) >= lookupBatchSize || (errors.Is(err, io.EOF) && len(batch) > 0) {
+			if err := annotateSites(ctx, snps, batch, vr.Sex, result); err != nil {
+				return nil, err
+			}
+			batch = batch[:0]
+		}
+		if errors.Is(err, io.EOF) {
+			result.Unlifted = vr.Unlifted
+			return result, nil
+		}
+	}
+}
+
+func annotateSites(ctx context.Context, snps repositories.SNPRepository, sites []Site, sex Sex, result *VCFResult) error {
+	var keys []string
+	for _, site := range sites {
+		keys = append(keys, site.keys()...)
+	}
+	found, err := snps.GetByVariantKeys(ctx, keys)
+	if err != nil {
+		return fmt.Errorf("look up sites: %w", err)
+	}
+	byKey := make(map[string][]*models.SNP, len(found))
+	for _, snp := range found {
+		if snp.VariantKey != nil {
+			byKey[*snp.VariantKey] = append(byKey[*snp.VariantKey], snp)
+		}
+	}
+
+	for _, site := range sites {
+		result.Sites++
+		if site.NoCall() {
+			result.NoCalls++
+		}
+		seen := make(map[int64]bool)
+		for _, key := range site.keys() {
+			for _, snp := range byKey[key] {
+				if !seen[snp.ID] {
+					seen[snp.ID] = true
+					alleles := site.Alleles(snp)
+					if site.heteroplasmic() && alleles != nil {
+						alleles = []string{snp.ReferenceAllele, snp.AlternateAlleles[0]}
+					}
+					a := SiteAnnotation{Site: site, SNP: snp}
+					_, a.Findings, a.Effects = interpretAt(alleles, snp, sex, snp.AlternateAlleles[0], site.Heteroplasmy)
+					result.Annotations = append(result.Annotations, a)
+				}
+			}
+		}
+	}
+	return nil
+}
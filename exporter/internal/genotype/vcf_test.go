@@ -0,0 +1,187 @@
+package genotype
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/mkoziy/genome/exporter/internal/liftover"
+	"github.com/mkoziy/genome/exporter/internal/models"
+	"github.com/mkoziy/genome/exporter/internal/repositories/memory"
+)
+
+func openVCF(t *testing.T, sample string) *VCFReader {
+	t.Helper()
+	f, err := os.Open("testdata/sample.vcf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = f.Close() })
+	vr, err := NewVCFReader(f, sample)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	return vr
+}
+
+func TestVCFReaderSplitsMultiAllelicRecords(t *testing.T) {
+	vr := openVCF(t, "S2")
+	var sites []Site
+	for {
+		s, err := vr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatalf("read: %v", err)
+		}
+		sites = append(sites, s...)
+	}
+	want := []Site{
+		{RsID: "rs429358", Chromosome: "19", Position: 44908684, Ref: "T", Alt: "C", Genotype: "0|1", AltCopies: 1, RefCopies: 1, Ploidy: 2},
+		{Chromosome: "1", Position: 100, Ref: "GA", Alt: "G", Genotype: "1/2", AltCopies: 1, Ploidy: 2},
+		{Chromosome: "1", Position: 100, Ref: "GA", Alt: "GAA", Genotype: "1/2", AltCopies: 1, Ploidy: 2},
+		{Chromosome: "3", Position: 10, Ref: "C", Alt: "T", Genotype: "./.", AltCopies: -1},
+	}
+	if len(sites) != len(want) {
+		t.Fatalf("expected %d sites, got %+v", len(want), sites)
+	}
+	for i := range want {
+		if sites[i] != want[i] {
+			t.Errorf("site %d: expected %+v, got %+v", i, want[i], sites[i])
+		}
+	}
+
+	if vr := openVCF(t, ""); vr.Sample != "S1" {
+		t.Fatalf("expected the first sample by default, got %s", vr.Sample)
+	}
+	if _, err := NewVCFReader(strings.NewReader("#CHROM\tPOS\tID\tREF\tALT\tQUAL\tFILTER\tINFO\tFORMAT\tS1\n"), "S9"); err == nil {
+		t.Fatalf("expected an unknown sample rejected")
+	}
+}
+
+func TestVCFReaderLiftsGRCh37Sites(t *testing.T) {
+	const vcf = "##fileformat=VCFv4.2\n" +
+		"##reference=file:///ref/human_g1k_v37.fasta\n" +
+		"#CHROM\tPOS\tID\tREF\tALT\tQUAL\tFILTER\tINFO\tFORMAT\tS1\n" +
+		"19\t100\t.\tT\tC\t.\tPASS\t.\tGT\t0/1\n" +
+		"19\t5000\t.\tG\tA\t.\tPASS\t.\tGT\t1/1\n" +
+		"2\t10\t.\tA\tG\t.\tPASS\t.\tGT\t0/1\n"
+	// chr19 moves 500 bases on; chr2 maps onto its reverse strand.
+	chain, err := liftover.Read(strings.NewReader(
+		"chain 100 chr19 1000 + 0 1000 chr19 2000 + 500 1500 1\n1000\n\n"+
+			"chain 100 chr2 100 + 0 100 chr2 100 - 0 100 2\n100\n"), liftover.GRCh37, liftover.GRCh38)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vr, err := NewVCFReader(strings.NewReader(vcf), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vr.Assembly != liftover.GRCh37 {
+		t.Fatalf("expected GRCh37 detected, got %q", vr.Assembly)
+	}
+	vr.LiftOver(chain)
+	var sites []Site
+	for {
+		s, err := vr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatalf("read: %v", err)
+		}
+		sites = append(sites, s...)
+	}
+	if len(sites) != 2 || vr.Unlifted != 1 {
+		t.Fatalf("expected 2 sites and 1 unlifted, got %+v and %d", sites, vr.Unlifted)
+	}
+	if s := sites[0]; s.Chromosome != "19" || s.Position != 600 || s.Ref != "T" || s.Alt != "C" {
+		t.Errorf("forward site: got %+v", s)
+	}
+	if s := sites[1]; s.Chromosome != "2" || s.Position != 91 || s.Ref != "T" || s.Alt != "C" {
+		t.Errorf("reverse site: got %+v", s)
+	}
+
+	for line, want := range map[string]string{
+		"##reference=GRCh38":                            liftover.GRCh38,
+		"##reference=hg19.fa":                           liftover.GRCh37,
+		"##reference=Homo_sapiens_assembly38.fasta":     liftover.GRCh38,
+		"##contig=<ID=1,length=249250621,assembly=b37>": liftover.GRCh37,
+		"##contig=<ID=1,length=248956422>":              "",
+		"##source=GRCh37":                               "",
+	} {
+		if got := headerAssembly(line); got != want {
+			t.Errorf("headerAssembly(%q) = %q, want %q", line, got, want)
+		}
+	}
+}
+
+func TestAnnotateVCFMatchesByPositionAndAlleles(t *testing.T) {
+	ctx := context.Background()
+	repos := memory.NewRepositories()
+	snps := []*models.SNP{
+		// Filed under another rsID than the VCF's ID.
+		{RsID: "rs111", Chromosome: "19", Position: 44908684, ReferenceAllele: "T", AlternateAlleles: models.StringArray{"C"}, VariantType: models.VariantSNV},
+		// The deletion as ClinVar writes it, without the anchor base.
+		{RsID: "rs222", Chromosome: "1", Position: 101, ReferenceAllele: "A", AlternateAlleles: models.StringArray{"-"}, VariantType: models.VariantDeletion},
+		{RsID: "rs333", Chromosome: "3", Position: 10, ReferenceAllele: "C", AlternateAlleles: models.StringArray{"G"}, VariantType: models.VariantSNV},
+	}
+	if err := repos.SNPs.Upsert(ctx, snps); err != nil {
+		t.Fatalf("upsert: %v", err)
+	}
+
+	result, err := AnnotateVCF(ctx, repos.SNPs, openVCF(t, "S2"))
+	if err != nil {
+		t.Fatalf("annotate: %v", err)
+	}
+	if result.Sample != "S2" || result.Sites != 4 || result.NoCalls != 1 || len(result.Annotations) != 2 {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	if a := result.Annotations[0]; a.SNP.RsID != "rs111" || a.Site.AltCopies != 1 {
+		t.Errorf("expected rs111 heterozygous, got %s with %d copies", a.SNP.RsID, a.Site.AltCopies)
+	}
+	if a := result.Annotations[1]; a.SNP.RsID != "rs222" || a.Site.Alt != "G" {
+		t.Errorf("expected the deletion matched to rs222, got %s for %s", a.SNP.RsID, a.Site.Alt)
+	}
+}
+
+func TestAnnotateVCFReadsMitochondrialHeteroplasmy(t *testing.T) {
+	ctx := context.Background()
+	repos := memory.NewRepositories()
+	snps := []*models.SNP{
+		{RsID: "rs199474657", Chromosome: "MT", Position: 3243, ReferenceAllele: "A", AlternateAlleles: models.StringArray{"G"}, VariantType: models.VariantSNV,
+			RiskAlleles: []*models.RiskAllele{{Allele: "G", Effect: "pathogenic", ConditionName: "MELAS syndrome"}}},
+		{RsID: "rs2853499", Chromosome: "MT", Position: 11467, ReferenceAllele: "A", AlternateAlleles: models.StringArray{"G"}, VariantType: models.VariantSNV,
+			RiskAlleles: []*models.RiskAllele{{Allele: "G", Effect: "benign", ConditionName: "not provided"}}},
+	}
+	if err := repos.SNPs.Upsert(ctx, snps); err != nil {
+		t.Fatalf("upsert: %v", err)
+	}
+	vcf := "##fileformat=VCFv4.2\n" +
+		"#CHROM\tPOS\tID\tREF\tALT\tQUAL\tFILTER\tINFO\tFORMAT\tS1\n" +
+		"chrM\t3243\t.\tA\tG\t.\tPASS\t.\tGT:HF\t0/1:0.31\n" +
+		"chrM\t11467\t.\tA\tG\t.\tPASS\t.\tGT:HF\t1:0.998\n"
+	vr, err := NewVCFReader(strings.NewReader(vcf), "")
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+
+	result, err := AnnotateVCF(ctx, repos.SNPs, vr)
+	if err != nil {
+		t.Fatalf("annotate: %v", err)
+	}
+	if len(result.Annotations) != 2 {
+		t.Fatalf("expected both sites annotated, got %+v", result)
+	}
+	f := result.Annotations[0].Findings
+	if len(f) != 1 || f[0].Status != StatusHeteroplasmic || f[0].Heteroplasmy == nil || *f[0].Heteroplasmy != 0.31 {
+		t.Errorf("expected m.3243A>G heteroplasmic at 0.31, got %+v", f)
+	}
+	if f := result.Annotations[1].Findings; len(f) != 1 || f[0].Status != StatusHemizygous {
+		t.Errorf("expected m.11467A>G homoplasmic, got %+v", f)
+	}
+}
@@ -0,0 +1,96 @@
+// Package haplotype defines the haplotypes the exporter knows out of the box:
+// alleles of genes that single SNPs cannot represent, such as the APOE ε
+// alleles, HLA alleles typed by tag SNPs and the pharmacogene star alleles of
+// the pgx package.
+package haplotype
+
+import (
+	"github.com/mkoziy/genome/exporter/internal/models"
+	"github.com/mkoziy/genome/exporter/internal/pgx"
+)
+
+// definition is a haplotype and the allele of each member SNP it requires,
+// on the forward strand of GRCh38.
+type definition struct {
+	gene, name  string
+	kind        models.HaplotypeKind
+	function    string
+	description string
+	alleles     [][2]string
+}
+
+// definitions are the haplotypes defined here rather than by pgx.
+var definitions = []definition{
+	{
+		gene: "APOE", name: "ε2", kind: models.HaplotypeNamedAllele,
+		function:    "decreased Alzheimer's disease risk",
+		description: "Encodes the E2 isoform; two copies predispose to type III hyperlipoproteinemia.",
+		alleles:     [][2]string{{"rs429358", "T"}, {"rs7412", "T"}},
+	},
+	{
+		gene: "APOE", name: "ε3", kind: models.HaplotypeNamedAllele,
+		function:    "reference",
+		description: "Encodes the E3 isoform, the most common.",
+		alleles:     [][2]string{{"rs429358", "T"}, {"rs7412", "C"}},
+	},
+	{
+		gene: "APOE", name: "ε4", kind: models.HaplotypeNamedAllele,
+		function:    "increased Alzheimer's disease risk",
+		description: "Encodes the E4 isoform; each copy raises the risk of late-onset Alzheimer's disease.",
+		alleles:     [][2]string{{"rs429358", "C"}, {"rs7412", "C"}},
+	},
+	{
+		gene: "HLA-B", name: "*57:01", kind: models.HaplotypeHLATag,
+		function:    "abacavir hypersensitivity",
+		description: "Tagged by the G allele of rs2395029 in HCP5, in near-complete linkage disequilibrium with it in Europeans.",
+		alleles:     [][2]string{{"rs2395029", "G"}},
+	},
+	{
+		gene: "HLA-DQA1", name: "*05:01", kind: models.HaplotypeHLATag,
+		function:    "increased celiac disease risk",
+		description: "Tagged by the T allele of rs2187668 as part of the DQ2.5 haplotype, DQA1*05:01-DQB1*02:01.",
+		alleles:     [][2]string{{"rs2187668", "T"}},
+	},
+}
+
+// Builtin returns fresh copies of the haplotypes known out of the box: those
+// defined here followed by the star alleles of pgx.Genes that have defining
+// variants.
+func Builtin() []*models.Haplotype {
+	var haplotypes []*models.Haplotype
+	for _, d := range definitions {
+		h := &models.Haplotype{Gene: d.gene, Name: d.name, Kind: d.kind, Function: optional(d.function), Description: optional(d.description)}
+		for _, a := range d.alleles {
+			h.SNPs = append(h.SNPs, &models.HaplotypeSNP{RsID: a[0], Allele: a[1]})
+		}
+		haplotypes = append(haplotypes, h)
+	}
+	return append(haplotypes, FromPGx(pgx.Genes)...)
+}
+
+// FromPGx returns the star alleles of genes as haplotypes requiring the
+// alternate allele of each of their defining variants. Reference alleles,
+// which have none, are left out.
+func FromPGx(genes []*pgx.Gene) []*models.Haplotype {
+	var haplotypes []*models.Haplotype
+	for _, gene := range genes {
+		for _, a := range gene.Alleles {
+			if len(a.Variants) == 0 {
+				continue
+			}
+			h := &models.Haplotype{Gene: gene.Symbol, Name: a.Name, Kind: models.HaplotypeStarAllele, Function: optional(a.Function)}
+			for _, v := range a.Variants {
+				h.SNPs = append(h.SNPs, &models.HaplotypeSNP{RsID: v.RsID, Allele: v.Alt})
+			}
+			haplotypes = append(haplotypes, h)
+		}
+	}
+	return haplotypes
+}
+
+func optional(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
@@ -0,0 +1,28 @@
+package haplotype
+
+import (
+	"testing"
+)
+
+func TestBuiltin(t *testing.T) {
+	seen := make(map[string]bool)
+	var stars int
+	for _, h := range Builtin() {
+		if err := h.Validate(); err != nil {
+			t.Error(err)
+		}
+		if seen[h.FullName()] {
+			t.Errorf("%s defined twice", h.FullName())
+		}
+		seen[h.FullName()] = true
+		if h.Name[0] == '*' && h.Gene[:3] == "CYP" {
+			stars++
+		}
+	}
+	if !seen["APOE ε4"] || !seen["CYP2C19*2"] || seen["CYP2C19*1"] {
+		t.Errorf("unexpected haplotypes: %v", seen)
+	}
+	if stars == 0 {
+		t.Error("expected the pgx star alleles")
+	}
+}
@@ -0,0 +1,278 @@
+package httpcache
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// DefaultMaxAge is how long a response is served without asking the server.
+const DefaultMaxAge = 24 * time.Hour
+
+// ignoredParams do not change what a server returns, so they are left out of
+// the cache key; a run with a different API key still hits the cache.
+var ignoredParams = []string{"api_key", "email", "tool"}
+
+// Transport is an http.RoundTripper that keeps successful GET responses on
+// disk, keyed by URL and query parameters. Entries younger than MaxAge are
+// served without a request; older ones are revalidated with If-None-Match or
+// If-Modified-Since when the server sent an ETag or Last-Modified, and
+// refetched otherwise. It is safe for concurrent use, so one Transport can be
+// shared by every source client.
+type Transport struct {
+	// Base makes the requests the cache cannot answer; nil means
+	// http.DefaultTransport.
+	Base   http.RoundTripper
+	MaxAge time.Duration
+
+	dir string
+	now func() time.Time
+}
+
+// New returns a Transport storing responses in dir, creating it if needed.
+func New(dir string, maxAge time.Duration) (*Transport, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &Transport{MaxAge: maxAge, dir: dir, now: time.Now}, nil
+}
+
+// entry is the metadata stored next to a cached body.
+type entry struct {
+	URL      string      `json:"url"`
+	Status   int         `json:"status"`
+	Header   http.Header `json:"header"`
+	StoredAt time.Time   `json:"stored_at"`
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet || req.Header.Get("Range") != "" {
+		return t.base().RoundTrip(req)
+	}
+
+	key := cacheKey(req.URL)
+	cached, err := t.load(key)
+	if err != nil {
+		return nil, err
+	}
+	if cached != nil && t.now().Sub(cached.StoredAt) < t.MaxAge {
+		return t.response(req, key, cached)
+	}
+
+	if cached != nil {
+		etag, modified := cached.Header.Get("ETag"), cached.Header.Get("Last-Modified")
+		if etag != "" || modified != "" {
+			req = req.Clone(req.Context())
+			if etag != "" {
+				req.Header.Set("If-None-Match", etag)
+			}
+			if modified != "" {
+				req.Header.Set("If-Modified-Since", modified)
+			}
+		}
+	}
+
+	resp, err := t.base().RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusNotModified && cached != nil {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		_ = resp.Body.Close()
+		for name, values := range resp.Header {
+			cached.Header[name] = values
+		}
+		cached.StoredAt = t.now()
+		if err := t.writeMeta(key, cached); err != nil {
+			return nil, err
+		}
+		return t.response(req, key, cached)
+	}
+
+	if resp.StatusCode != http.StatusOK || strings.Contains(resp.Header.Get("Cache-Control"), "no-store") {
+		return resp, nil
+	}
+	return t.store(key, req, resp)
+}
+
+func (t *Transport) base() http.RoundTripper {
+	if t.Base != nil {
+		return t.Base
+	}
+	return http.DefaultTransport
+}
+
+// cacheKey hashes the URL with its query parameters sorted and the ignored
+// ones removed.
+func cacheKey(u *url.URL) string {
+	query := u.Query()
+	for _, param := range ignoredParams {
+		query.Del(param)
+	}
+	normalized := *u
+	normalized.RawQuery = query.Encode()
+	normalized.Fragment = ""
+	sum := sha256.Sum256([]byte(normalized.String()))
+	return hex.EncodeToString(sum[:])
+}
+
+func (t *Transport) path(key, ext string) string {
+	return filepath.Join(t.dir, key[:2], key+ext)
+}
+
+// load returns the entry for key, or nil when there is none or it is unreadable.
+func (t *Transport) load(key string) (*entry, error) {
+	data, err := os.ReadFile(t.path(key, ".json"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var e entry
+	if err := json.Unmarshal(data, &e); err != nil {
+		return nil, nil
+	}
+	if _, err := os.Stat(t.path(key, ".body")); err != nil {
+		return nil, nil
+	}
+	return &e, nil
+}
+
+// response builds a response for req from a stored entry.
+func (t *Transport) response(req *http.Request, key string, e *entry) (*http.Response, error) {
+	body, err := os.Open(t.path(key, ".body"))
+	if err != nil {
+		return nil, err
+	}
+	info, err := body.Stat()
+	if err != nil {
+		_ = body.Close()
+		return nil, err
+	}
+	header := e.Header.Clone()
+	header.Set("X-From-Cache", "1")
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", e.Status, http.StatusText(e.Status)),
+		StatusCode:    e.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          body,
+		ContentLength: info.Size(),
+		Request:       req,
+	}, nil
+}
+
+func (t *Transport) writeMeta(key string, e *entry) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	return writeFile(t.path(key, ".json"), bytes.NewReader(data))
+}
+
+// store streams resp's body to the caller while copying it to disk. The entry
+// is committed only once the body has been read to the end, so a download cut
+// short is never served from the cache.
+func (t *Transport) store(key string, req *http.Request, resp *http.Response) (*http.Response, error) {
+	path := t.path(key, ".body")
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), key+".*.tmp")
+	if err != nil {
+		return nil, err
+	}
+
+	e := &entry{URL: req.URL.String(), Status: resp.StatusCode, Header: resp.Header.Clone(), StoredAt: t.now()}
+	resp.Body = &teeBody{
+		body: resp.Body,
+		tmp:  tmp,
+		buf:  bufio.NewWriter(tmp),
+		commit: func() error {
+			if err := os.Rename(tmp.Name(), path); err != nil {
+				return err
+			}
+			return t.writeMeta(key, e)
+		},
+	}
+	return resp, nil
+}
+
+// teeBody copies what is read from body into tmp.
+type teeBody struct {
+	body   io.ReadCloser
+	tmp    *os.File
+	buf    *bufio.Writer
+	commit func() error
+	failed bool
+	eof    bool
+}
+
+func (b *teeBody) Read(p []byte) (int, error) {
+	n, err := b.body.Read(p)
+	if n > 0 && !b.failed {
+		if _, werr := b.buf.Write(p[:n]); werr != nil {
+			b.failed = true
+		}
+	}
+	if errors.Is(err, io.EOF) {
+		b.eof = true
+	}
+	return n, err
+}
+
+// Close reads what the caller left unread, such as whitespace after an XML
+// document, then commits the copy if the body was read completely and discards
+// it otherwise. Failing to write the cache never fails the request.
+func (b *teeBody) Close() error {
+	if !b.eof && !b.failed {
+		_, _ = io.Copy(io.Discard, b)
+	}
+	err := b.body.Close()
+	if b.eof && !b.failed && b.buf.Flush() == nil && b.tmp.Close() == nil {
+		if b.commit() == nil {
+			return err
+		}
+	} else {
+		_ = b.tmp.Close()
+	}
+	_ = os.Remove(b.tmp.Name())
+	return err
+}
+
+// writeFile replaces path with r's content atomically.
+func writeFile(path string, r io.Reader) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(tmp, r); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,116 @@
+package httpcache
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func get(t *testing.T, client *http.Client, url string) (string, bool) {
+	t.Helper()
+	resp, err := client.Get(url)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	return string(body), resp.Header.Get("X-From-Cache") != ""
+}
+
+func TestTransportServesFreshEntriesFromDisk(t *testing.T) {
+	var requests atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		_, _ = io.WriteString(w, "ids="+r.URL.Query().Get("id"))
+	}))
+	defer ts.Close()
+
+	cache, err := New(t.TempDir(), time.Hour)
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	client := &http.Client{Transport: cache}
+
+	if body, hit := get(t, client, ts.URL+"/efetch?id=1&api_key=a"); body != "ids=1" || hit {
+		t.Fatalf("first request: %q hit=%v", body, hit)
+	}
+	// Parameter order and the API key do not change the key.
+	if body, hit := get(t, client, ts.URL+"/efetch?api_key=b&id=1"); body != "ids=1" || !hit {
+		t.Fatalf("repeat request: %q hit=%v", body, hit)
+	}
+	if body, _ := get(t, client, ts.URL+"/efetch?id=2"); body != "ids=2" {
+		t.Fatalf("other request: %q", body)
+	}
+	if n := requests.Load(); n != 2 {
+		t.Fatalf("expected 2 requests to reach the server, got %d", n)
+	}
+}
+
+func TestTransportRevalidatesStaleEntries(t *testing.T) {
+	var requests, notModified atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			notModified.Add(1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		_, _ = io.WriteString(w, "payload")
+	}))
+	defer ts.Close()
+
+	cache, err := New(t.TempDir(), time.Minute)
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	cache.now = func() time.Time { return now }
+	client := &http.Client{Transport: cache}
+
+	get(t, client, ts.URL)
+	now = now.Add(2 * time.Minute)
+	if body, hit := get(t, client, ts.URL); body != "payload" || !hit {
+		t.Fatalf("revalidated request: %q hit=%v", body, hit)
+	}
+	// Revalidation refreshed the entry, so it is fresh again.
+	get(t, client, ts.URL)
+	if requests.Load() != 2 || notModified.Load() != 1 {
+		t.Fatalf("expected 1 full and 1 conditional request, got %d requests, %d not modified",
+			requests.Load(), notModified.Load())
+	}
+}
+
+func TestTransportDoesNotCacheIncompleteOrFailedResponses(t *testing.T) {
+	var requests atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 1 {
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		_, _ = io.WriteString(w, "ok")
+	}))
+	defer ts.Close()
+
+	cache, err := New(t.TempDir(), time.Hour)
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	client := &http.Client{Transport: cache}
+
+	get(t, client, ts.URL)
+	if body, hit := get(t, client, ts.URL); body != "ok" || hit {
+		t.Fatalf("a failed response was cached: %q hit=%v", body, hit)
+	}
+	if body, hit := get(t, client, ts.URL); body != "ok" || !hit {
+		t.Fatalf("expected a cache hit: %q hit=%v", body, hit)
+	}
+}
@@ -0,0 +1,366 @@
+// Package jsonschema derives JSON Schema (draft 2020-12) documents from Go
+// types, following encoding/json's rules for field names, omitempty, the
+// string option and embedded structs, so the schema describes exactly what
+// the exporter writes. Named struct types become definitions referenced by
+// $ref; enum types registered with a Generator list their values.
+//
+// Pointer fields are the only values a schema allows to be null: the
+// exporter writes empty slices and maps where it means none, and no nil
+// elements in them.
+package jsonschema
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Draft is the JSON Schema dialect of the documents.
+const Draft = "https://json-schema.org/draft/2020-12/schema"
+
+// Schema is a JSON Schema, or the subset of it the generator produces.
+type Schema struct {
+	Schema      string             `json:"$schema,omitempty"`
+	ID          string             `json:"$id,omitempty"`
+	Ref         string             `json:"$ref,omitempty"`
+	Title       string             `json:"title,omitempty"`
+	Description string             `json:"description,omitempty"`
+	Type        any                `json:"type,omitempty"`
+	Format      string             `json:"format,omitempty"`
+	Enum        []any              `json:"enum,omitempty"`
+	Items       *Schema            `json:"items,omitempty"`
+	Properties  map[string]*Schema `json:"properties,omitempty"`
+	Required    []string           `json:"required,omitempty"`
+	// AdditionalProperties is false for structs, whose fields are known,
+	// and the schema of the values for maps.
+	AdditionalProperties any                `json:"additionalProperties,omitempty"`
+	AnyOf                []*Schema          `json:"anyOf,omitempty"`
+	Defs                 map[string]*Schema `json:"$defs,omitempty"`
+}
+
+// defsRef prefixes the references to definitions.
+const defsRef = "#/$defs/"
+
+var (
+	timeType          = reflect.TypeFor[time.Time]()
+	durationType      = reflect.TypeFor[time.Duration]()
+	rawMessageType    = reflect.TypeFor[json.RawMessage]()
+	jsonMarshalerType = reflect.TypeFor[json.Marshaler]()
+	textMarshalerType = reflect.TypeFor[encoding.TextMarshaler]()
+)
+
+// Generator derives schemas. The zero value is ready to use.
+type Generator struct {
+	enums map[reflect.Type][]any
+}
+
+// Enum registers the values of an enum type, given as a slice of them such
+// as models.DataSources.
+func (g *Generator) Enum(values any) {
+	v := reflect.ValueOf(values)
+	if v.Kind() != reflect.Slice {
+		panic("jsonschema: Enum takes a slice")
+	}
+	list := make([]any, v.Len())
+	for i := range list {
+		list[i] = v.Index(i).Interface()
+	}
+	if g.enums == nil {
+		g.enums = make(map[reflect.Type][]any)
+	}
+	g.enums[v.Type().Elem()] = list
+}
+
+// Reflect returns the schema document of the JSON encoding of v's type, a
+// pointer standing for the type it points to. The root definition is
+// inlined into the document unless other definitions refer back to it;
+// the definitions it references are under $defs.
+func (g *Generator) Reflect(v any) *Schema {
+	r := &reflector{g: g, defs: make(map[string]*Schema), names: make(map[reflect.Type]string)}
+	doc := r.schema(indirect(reflect.TypeOf(v)))
+	if name, ok := strings.CutPrefix(doc.Ref, defsRef); ok && !r.refersTo(doc.Ref) {
+		doc = r.defs[name]
+		delete(r.defs, name)
+	}
+	doc.Schema = Draft
+	if len(r.defs) > 0 {
+		doc.Defs = r.defs
+	}
+	return doc
+}
+
+type reflector struct {
+	g     *Generator
+	defs  map[string]*Schema
+	names map[reflect.Type]string
+}
+
+func (r *reflector) schema(t reflect.Type) *Schema {
+	if t.Kind() == reflect.Pointer {
+		return nullable(r.schema(t.Elem()))
+	}
+	if values, ok := r.g.enums[t]; ok {
+		return &Schema{Type: kindType(t.Kind()), Enum: values}
+	}
+	switch t {
+	case timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case durationType:
+		return &Schema{Type: "integer", Description: "nanoseconds"}
+	case rawMessageType:
+		return &Schema{}
+	}
+	if t.Implements(jsonMarshalerType) || reflect.PointerTo(t).Implements(jsonMarshalerType) {
+		// Encoded by its own method; its shape is not known.
+		return &Schema{}
+	}
+	if t.Kind() != reflect.String && (t.Implements(textMarshalerType) || reflect.PointerTo(t).Implements(textMarshalerType)) {
+		return &Schema{Type: "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool, reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return &Schema{Type: kindType(t.Kind())}
+	case reflect.Slice, reflect.Array:
+		if t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: r.schema(indirect(t.Elem()))}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: r.schema(indirect(t.Elem()))}
+	case reflect.Struct:
+		return r.definition(t)
+	case reflect.Interface:
+		return &Schema{}
+	}
+	panic(fmt.Sprintf("jsonschema: unsupported type %s", t))
+}
+
+// definition returns a reference to the definition of struct type t,
+// deriving it the first time. Anonymous structs are inlined.
+func (r *reflector) definition(t reflect.Type) *Schema {
+	if t.Name() == "" {
+		return r.object(t)
+	}
+	if name, ok := r.names[t]; ok {
+		return &Schema{Ref: defsRef + name}
+	}
+	name := t.Name()
+	if _, taken := r.defs[name]; taken {
+		name = t.PkgPath()[strings.LastIndex(t.PkgPath(), "/")+1:] + "." + name
+	}
+	r.names[t] = name
+	// Reserve the name before deriving the fields, which may refer back.
+	r.defs[name] = &Schema{}
+	r.defs[name] = r.object(t)
+	return &Schema{Ref: defsRef + name}
+}
+
+// object derives the schema of a struct's fields. Fields encoded whatever
+// their value, without omitempty, are required.
+func (r *reflector) object(t reflect.Type) *Schema {
+	s := &Schema{Type: "object", Properties: make(map[string]*Schema), AdditionalProperties: false}
+	for _, f := range fields(t) {
+		fs := r.schema(f.typ)
+		if f.quoted {
+			fs = quoted(fs)
+		}
+		s.Properties[f.name] = fs
+		if !f.omitEmpty {
+			s.Required = append(s.Required, f.name)
+		}
+	}
+	return s
+}
+
+// refersTo reports whether any definition contains ref.
+func (r *reflector) refersTo(ref string) bool {
+	var walk func(s *Schema) bool
+	walk = func(s *Schema) bool {
+		if s == nil {
+			return false
+		}
+		if s.Ref == ref {
+			return true
+		}
+		if walk(s.Items) {
+			return true
+		}
+		if ap, ok := s.AdditionalProperties.(*Schema); ok && walk(ap) {
+			return true
+		}
+		for _, p := range s.Properties {
+			if walk(p) {
+				return true
+			}
+		}
+		for _, a := range s.AnyOf {
+			if walk(a) {
+				return true
+			}
+		}
+		return false
+	}
+	for _, def := range r.defs {
+		if walk(def) {
+			return true
+		}
+	}
+	return false
+}
+
+// field is a struct field as encoding/json encodes it.
+type field struct {
+	name      string
+	typ       reflect.Type
+	omitEmpty bool
+	quoted    bool
+}
+
+// fields lists the encoded fields of struct type t in order, promoting the
+// fields of embedded structs without a JSON name. Of fields promoted under
+// the same name, the least nested wins, as in encoding/json; ties at the
+// same depth drop the name.
+func fields(t reflect.Type) []field {
+	type candidate struct {
+		field
+		depth  int
+		tagged bool
+	}
+	var list []candidate
+	var walk func(t reflect.Type, depth int, seen map[reflect.Type]bool)
+	walk = func(t reflect.Type, depth int, seen map[reflect.Type]bool) {
+		if seen[t] {
+			return
+		}
+		seen[t] = true
+		for i := range t.NumField() {
+			sf := t.Field(i)
+			tag := sf.Tag.Get("json")
+			if tag == "-" {
+				continue
+			}
+			name, opts, _ := strings.Cut(tag, ",")
+			ft := sf.Type
+			if sf.Anonymous {
+				et := indirect(ft)
+				if name == "" && et.Kind() == reflect.Struct {
+					walk(et, depth+1, seen)
+					continue
+				}
+				if !sf.IsExported() && et.Kind() != reflect.Struct {
+					continue
+				}
+			} else if !sf.IsExported() {
+				continue
+			}
+			tagged := name != ""
+			if name == "" {
+				name = sf.Name
+			}
+			list = append(list, candidate{
+				field: field{
+					name:      name,
+					typ:       ft,
+					omitEmpty: hasOption(opts, "omitempty") || hasOption(opts, "omitzero"),
+					quoted:    hasOption(opts, "string") && quotable(ft),
+				},
+				depth:  depth,
+				tagged: tagged,
+			})
+		}
+	}
+	walk(t, 0, make(map[reflect.Type]bool))
+
+	var result []field
+	for i, c := range list {
+		dominant := true
+		for j, o := range list {
+			if i == j || o.name != c.name {
+				continue
+			}
+			if o.depth < c.depth || (o.depth == c.depth && (o.tagged && !c.tagged || o.tagged == c.tagged)) {
+				dominant = false
+			}
+		}
+		if dominant {
+			result = append(result, c.field)
+		}
+	}
+	return result
+}
+
+func hasOption(opts, name string) bool {
+	for opts != "" {
+		var opt string
+		opt, opts, _ = strings.Cut(opts, ",")
+		if opt == name {
+			return true
+		}
+	}
+	return false
+}
+
+// quotable reports whether the string option applies to t, which it does
+// for booleans, numbers and strings.
+func quotable(t reflect.Type) bool {
+	switch indirect(t).Kind() {
+	case reflect.Bool, reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// quoted is the schema of a value the string option encodes as a string.
+func quoted(s *Schema) *Schema {
+	if types, ok := s.Type.([]string); ok && len(types) == 2 {
+		return &Schema{Type: []string{"string", "null"}}
+	}
+	return &Schema{Type: "string"}
+}
+
+// nullable allows s to be null as well.
+func nullable(s *Schema) *Schema {
+	switch {
+	case s.Ref != "":
+		return &Schema{AnyOf: []*Schema{s, {Type: "null"}}}
+	case s.Type == nil:
+		// Anything, null included.
+		return s
+	}
+	if typ, ok := s.Type.(string); ok {
+		s.Type = []string{typ, "null"}
+		if s.Enum != nil {
+			s.Enum = append(s.Enum, nil)
+		}
+	}
+	return s
+}
+
+func kindType(k reflect.Kind) string {
+	switch k {
+	case reflect.Bool:
+		return "boolean"
+	case reflect.String:
+		return "string"
+	case reflect.Float32, reflect.Float64:
+		return "number"
+	}
+	return "integer"
+}
+
+func indirect(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t
+}
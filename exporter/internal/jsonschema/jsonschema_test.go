@@ -0,0 +1,132 @@
+package jsonschema
+
+import (
+	"encoding/json"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/mkoziy/genome/exporter/internal/models"
+)
+
+type color string
+
+type base struct {
+	ID      int64  `json:"id"`
+	Comment string `json:"comment,omitempty"`
+}
+
+type node struct {
+	base
+	Name     string              `json:"name"`
+	Color    color               `json:"color"`
+	Shade    *color              `json:"shade,omitempty"`
+	Count    int64               `json:"count,string"`
+	Tags     []string            `json:"tags"`
+	Labels   map[string]int      `json:"labels,omitempty"`
+	Seen     time.Time           `json:"seen"`
+	Parent   *node               `json:"parent,omitempty"`
+	Children []node              `json:"children,omitempty"`
+	Extra    json.RawMessage     `json:"extra,omitempty"`
+	Secret   string              `json:"-"`
+	Plain    bool                // untagged, so named Plain
+	Anon     struct{ X int }     `json:"anon"`
+	private  string              // unexported, so not encoded
+	Sources  []models.DataSource `json:"sources"`
+}
+
+func TestReflect(t *testing.T) {
+	var g Generator
+	g.Enum([]color{"red", "green"})
+	g.Enum(models.DataSources)
+	doc := g.Reflect(&node{})
+
+	if doc.Schema != Draft || doc.Ref != "#/$defs/node" {
+		t.Fatalf("expected a self-referencing root to stay a reference, got %+v", doc)
+	}
+	def := doc.Defs["node"]
+	if def == nil || def.AdditionalProperties != false {
+		t.Fatalf("expected a closed node definition, got %+v", def)
+	}
+	wantRequired := []string{"id", "name", "color", "count", "tags", "seen", "Plain", "anon", "sources"}
+	if !slices.Equal(def.Required, wantRequired) {
+		t.Fatalf("required = %v, want %v", def.Required, wantRequired)
+	}
+	for _, name := range []string{"Secret", "private", "base"} {
+		if _, ok := def.Properties[name]; ok {
+			t.Fatalf("expected %s not to be a property", name)
+		}
+	}
+
+	props := def.Properties
+	checks := map[string]*Schema{
+		"id":       {Type: "integer"},
+		"comment":  {Type: "string"},
+		"color":    {Type: "string", Enum: []any{color("red"), color("green")}},
+		"shade":    {Type: []string{"string", "null"}, Enum: []any{color("red"), color("green"), nil}},
+		"count":    {Type: "string"},
+		"tags":     {Type: "array", Items: &Schema{Type: "string"}},
+		"labels":   {Type: "object", AdditionalProperties: &Schema{Type: "integer"}},
+		"seen":     {Type: "string", Format: "date-time"},
+		"parent":   {AnyOf: []*Schema{{Ref: "#/$defs/node"}, {Type: "null"}}},
+		"children": {Type: "array", Items: &Schema{Ref: "#/$defs/node"}},
+		"extra":    {},
+		"Plain":    {Type: "boolean"},
+		"anon": {Type: "object", Properties: map[string]*Schema{"X": {Type: "integer"}},
+			Required: []string{"X"}, AdditionalProperties: false},
+	}
+	for name, want := range checks {
+		if !reflect.DeepEqual(props[name], want) {
+			got, _ := json.Marshal(props[name])
+			exp, _ := json.Marshal(want)
+			t.Errorf("%s: got %s, want %s", name, got, exp)
+		}
+	}
+	if enum := props["sources"].Items.Enum; len(enum) != len(models.DataSources) {
+		t.Errorf("expected the data sources enumerated, got %v", enum)
+	}
+}
+
+func TestReflectInlinesRoot(t *testing.T) {
+	var g Generator
+	doc := g.Reflect(models.SNP{})
+	if doc.Ref != "" || doc.Type != "object" {
+		t.Fatalf("expected the SNP inlined as the root, got %+v", doc)
+	}
+	if _, ok := doc.Defs["SNP"]; ok {
+		t.Fatalf("expected no SNP definition")
+	}
+	if !slices.Contains(doc.Required, "rsid") || slices.Contains(doc.Required, "gene_symbol") {
+		t.Fatalf("unexpected required fields %v", doc.Required)
+	}
+	if _, ok := doc.Defs["Significance"]; !ok {
+		t.Fatalf("expected a Significance definition, got %v", doc.Defs)
+	}
+	if _, ok := doc.Defs["Significance"].Properties["snp"]; ok {
+		t.Fatalf("expected the json:\"-\" back reference left out")
+	}
+	if _, err := json.Marshal(doc); err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+
+	// An encoded SNP has exactly the required keys and no unknown ones.
+	data, err := json.Marshal(models.SNP{RsID: "rs1", AlternateAlleles: models.StringArray{"T"}})
+	if err != nil {
+		t.Fatalf("marshal snp: %v", err)
+	}
+	var encoded map[string]any
+	if err := json.Unmarshal(data, &encoded); err != nil {
+		t.Fatalf("unmarshal snp: %v", err)
+	}
+	for key := range encoded {
+		if _, ok := doc.Properties[key]; !ok {
+			t.Errorf("encoded key %s is not a property", key)
+		}
+	}
+	for _, key := range doc.Required {
+		if _, ok := encoded[key]; !ok {
+			t.Errorf("required key %s is not encoded", key)
+		}
+	}
+}
@@ -0,0 +1,293 @@
+// Package liftover converts coordinates between the GRCh37 and GRCh38
+// assemblies with a UCSC chain file, such as hg19ToHg38.over.chain.gz from
+// https://hgdownload.soe.ucsc.edu/goldenPath/hg19/liftOver/.
+package liftover
+
+import (
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mkoziy/genome/exporter/internal/variant"
+)
+
+// Assemblies coordinates are converted between. The database's own is
+// GRCh38.
+const (
+	GRCh37 = "GRCh37"
+	GRCh38 = "GRCh38"
+)
+
+// NormalizeAssembly returns the assembly name is an alias of, such as
+// GRCh37 for hg19 or b37, and "" if it names neither assembly.
+func NormalizeAssembly(name string) string {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "grch37", "hg19", "b37", "hs37d5", "37":
+		return GRCh37
+	case "grch38", "hg38", "38":
+		return GRCh38
+	}
+	return ""
+}
+
+// Config locates the chain file.
+type Config struct {
+	// ChainFile is a UCSC chain file from GRCh37 to GRCh38, gzipped or
+	// not. Coordinates are converted both ways with it; unset, they are not
+	// converted.
+	ChainFile string `yaml:"chain_file" json:"chain_file,omitempty"`
+}
+
+// Locus is a position on an assembly.
+type Locus struct {
+	Chromosome string `json:"chromosome"`
+	Position   int64  `json:"position"`
+	// Reverse reports whether the sequence at the position reads on the
+	// opposite strand, so alleles must be reverse-complemented.
+	Reverse bool `json:"reverse,omitempty"`
+}
+
+// segment is an ungapped block of a chain: size bases at from on the source
+// chromosome aligned to size bases at to on the target one, both 0-based on
+// the forward strand.
+type segment struct {
+	from, to, size int64
+	target         string
+	reverse        bool
+	score          float64
+}
+
+// Chain maps positions of one assembly onto another.
+type Chain struct {
+	// From and To are the assemblies mapped between.
+	From, To string
+	// segments are each source chromosome's segments by start, with the
+	// furthest end of any segment up to each.
+	segments map[string][]segment
+	reach    map[string][]int64
+}
+
+// Open reads the chain file at path, mapping from onto to.
+func Open(path, from, to string) (*Chain, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	c, err := Read(f, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return c, nil
+}
+
+// Read reads a chain file, gzipped or not, mapping from onto to.
+func Read(r io.Reader, from, to string) (*Chain, error) {
+	br := bufio.NewReader(r)
+	if magic, _ := br.Peek(2); len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+		defer func() {
+			_ = gz.Close()
+		}()
+		br = bufio.NewReader(gz)
+	}
+
+	segments := make(map[string][]segment)
+	sc := bufio.NewScanner(br)
+	var (
+		source            string
+		seg               segment
+		qSize, tPos, qPos int64
+		inChain           bool
+		line              int
+	)
+	malformed := func(msg string) error {
+		return fmt.Errorf("line %d: %s", line, msg)
+	}
+	for sc.Scan() {
+		line++
+		fields := strings.Fields(sc.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		if fields[0] == "chain" {
+			// chain score tName tSize tStrand tStart tEnd qName qSize qStrand qStart qEnd id
+			if len(fields) < 12 {
+				return nil, malformed("want 12 fields in a chain header")
+			}
+			score, err1 := strconv.ParseFloat(fields[1], 64)
+			start, err2 := parseInt64(fields[5])
+			size, err3 := parseInt64(fields[8])
+			qStart, err4 := parseInt64(fields[10])
+			if err1 != nil || err2 != nil || err3 != nil || err4 != nil || fields[4] != "+" {
+				return nil, malformed("bad chain header")
+			}
+			source = variant.NormalizeChromosome(fields[2])
+			seg = segment{target: variant.NormalizeChromosome(fields[7]), reverse: fields[9] == "-", score: score}
+			qSize, tPos, qPos, inChain = size, start, qStart, true
+			continue
+		}
+		if !inChain || (len(fields) != 3 && len(fields) != 1) {
+			return nil, malformed("want a block of size, or of size and the gaps after it, in a chain")
+		}
+		size, err := parseInt64(fields[0])
+		if err != nil || size <= 0 {
+			return nil, malformed("bad block size")
+		}
+		seg.from, seg.size = tPos, size
+		seg.to = qPos
+		if seg.reverse {
+			// Reverse chains count query positions from the end.
+			seg.to = qSize - qPos - size
+		}
+		segments[source] = append(segments[source], seg)
+		if len(fields) == 1 {
+			inChain = false
+			continue
+		}
+		dt, err1 := parseInt64(fields[1])
+		dq, err2 := parseInt64(fields[2])
+		if err1 != nil || err2 != nil || dt < 0 || dq < 0 {
+			return nil, malformed("bad gap")
+		}
+		tPos += size + dt
+		qPos += size + dq
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if len(segments) == 0 {
+		return nil, errors.New("no chains")
+	}
+	return newChain(from, to, segments), nil
+}
+
+func newChain(from, to string, segments map[string][]segment) *Chain {
+	c := &Chain{From: from, To: to, segments: segments, reach: make(map[string][]int64, len(segments))}
+	for chrom, segs := range segments {
+		sort.Slice(segs, func(i, j int) bool { return segs[i].from < segs[j].from })
+		reach := make([]int64, len(segs))
+		var furthest int64
+		for i, s := range segs {
+			furthest = max(furthest, s.from+s.size)
+			reach[i] = furthest
+		}
+		c.reach[chrom] = reach
+	}
+	return c
+}
+
+// Invert returns the chain mapping c's target assembly back onto its
+// source, so one chain file converts both ways. Positions the target
+// assembly duplicates map to the copy of the best-scoring chain.
+func (c *Chain) Invert() *Chain {
+	segments := make(map[string][]segment)
+	for chrom, segs := range c.segments {
+		for _, s := range segs {
+			segments[s.target] = append(segments[s.target], segment{
+				from: s.to, to: s.from, size: s.size, target: chrom, reverse: s.reverse, score: s.score,
+			})
+		}
+	}
+	return newChain(c.To, c.From, segments)
+}
+
+// Map returns the position pos, 1-based, on chrom maps to, and false if it
+// falls outside every chain, in sequence the other assembly lacks.
+// Chromosomes are named as the database names them, with or without a
+// "chr" prefix.
+func (c *Chain) Map(chrom string, pos int64) (Locus, bool) {
+	chrom = variant.NormalizeChromosome(chrom)
+	segs, reach := c.segments[chrom], c.reach[chrom]
+	p := pos - 1
+	var best *segment
+	// Segments starting after p cannot hold it; earlier ones might as long
+	// as some segment up to them reaches past p.
+	for i := sort.Search(len(segs), func(i int) bool { return segs[i].from > p }) - 1; i >= 0 && reach[i] > p; i-- {
+		s := &segs[i]
+		if p < s.from+s.size && (best == nil || s.score > best.score) {
+			best = s
+		}
+	}
+	if best == nil {
+		return Locus{}, false
+	}
+	offset := p - best.from
+	if best.reverse {
+		offset = best.size - 1 - offset
+	}
+	return Locus{Chromosome: best.target, Position: best.to + offset + 1, Reverse: best.reverse}, true
+}
+
+// MapVariant returns where a variant with reference allele ref at pos on
+// chrom lies on the other assembly, with its alleles as written there:
+// reverse-complemented, and starting from what was its last base, if it maps
+// onto the opposite strand. It returns false if either end of the reference
+// allele does not map, or they map apart.
+func (c *Chain) MapVariant(chrom string, pos int64, ref string, alts []string) (Locus, string, []string, bool) {
+	end := pos + max(int64(len(ref)), 1) - 1
+	first, ok1 := c.Map(chrom, pos)
+	last, ok2 := c.Map(chrom, end)
+	if !ok1 || !ok2 || first.Chromosome != last.Chromosome || abs(last.Position-first.Position) != end-pos {
+		return Locus{}, "", nil, false
+	}
+	if !first.Reverse {
+		return first, ref, alts, true
+	}
+	lifted := make([]string, len(alts))
+	for i, alt := range alts {
+		lifted[i] = ReverseComplement(alt)
+	}
+	return last, ReverseComplement(ref), lifted, true
+}
+
+// ReverseComplement returns the allele read on the opposite strand. Anything
+// but bases, such as - for a missing allele, is kept as it is.
+func ReverseComplement(allele string) string {
+	out := make([]byte, len(allele))
+	for i := range len(allele) {
+		b := allele[len(allele)-1-i]
+		switch b {
+		case 'A':
+			b = 'T'
+		case 'T':
+			b = 'A'
+		case 'C':
+			b = 'G'
+		case 'G':
+			b = 'C'
+		case 'a':
+			b = 't'
+		case 't':
+			b = 'a'
+		case 'c':
+			b = 'g'
+		case 'g':
+			b = 'c'
+		}
+		out[i] = b
+	}
+	return string(out)
+}
+
+func parseInt64(s string) (int64, error) {
+	return strconv.ParseInt(s, 10, 64)
+}
+
+func abs(n int64) int64 {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
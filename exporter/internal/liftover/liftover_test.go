@@ -0,0 +1,112 @@
+package liftover
+
+import (
+	"bytes"
+	"compress/gzip"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// testChains map chr1 onto itself with a gap, and chr2 onto the reverse
+// strand of chr3.
+const testChains = `chain 1000 chr1 1000 + 100 300 chr1 1000 + 200 410 1
+50 10 20
+140
+
+chain 500 chr2 500 + 0 100 chr3 400 - 50 150 2
+100
+`
+
+func TestMap(t *testing.T) {
+	c, err := Read(strings.NewReader(testChains), GRCh37, GRCh38)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		chrom string
+		pos   int64
+		want  Locus
+		ok    bool
+	}{
+		{"chr1", 101, Locus{Chromosome: "1", Position: 201}, true},
+		{"1", 150, Locus{Chromosome: "1", Position: 250}, true},
+		{"1", 155, Locus{}, false},
+		{"1", 161, Locus{Chromosome: "1", Position: 271}, true},
+		{"1", 100, Locus{}, false},
+		{"2", 1, Locus{Chromosome: "3", Position: 350, Reverse: true}, true},
+		{"2", 100, Locus{Chromosome: "3", Position: 251, Reverse: true}, true},
+		{"X", 1, Locus{}, false},
+	}
+	for _, tc := range cases {
+		got, ok := c.Map(tc.chrom, tc.pos)
+		if got != tc.want || ok != tc.ok {
+			t.Errorf("Map(%s, %d) = %+v, %v; want %+v, %v", tc.chrom, tc.pos, got, ok, tc.want, tc.ok)
+		}
+	}
+
+	inv := c.Invert()
+	if inv.From != GRCh38 || inv.To != GRCh37 {
+		t.Errorf("inverted chain maps %s onto %s", inv.From, inv.To)
+	}
+	for _, tc := range cases {
+		if !tc.ok {
+			continue
+		}
+		back, ok := inv.Map(tc.want.Chromosome, tc.want.Position)
+		if !ok || back.Position != tc.pos || back.Reverse != tc.want.Reverse {
+			t.Errorf("inverse of %s:%d = %+v, %v", tc.chrom, tc.pos, back, ok)
+		}
+	}
+}
+
+func TestMapVariant(t *testing.T) {
+	c, err := Read(strings.NewReader(testChains), GRCh37, GRCh38)
+	if err != nil {
+		t.Fatal(err)
+	}
+	locus, ref, alts, ok := c.MapVariant("1", 101, "AC", []string{"A"})
+	if !ok || locus.Position != 201 || ref != "AC" || !slices.Equal(alts, []string{"A"}) {
+		t.Errorf("forward: got %+v %s %v, %v", locus, ref, alts, ok)
+	}
+	locus, ref, alts, ok = c.MapVariant("2", 1, "AC", []string{"G", "-"})
+	if !ok || locus.Position != 349 || ref != "GT" || !slices.Equal(alts, []string{"C", "-"}) {
+		t.Errorf("reverse: got %+v %s %v, %v", locus, ref, alts, ok)
+	}
+	if _, _, _, ok := c.MapVariant("1", 148, "ACGTAC", []string{"A"}); ok {
+		t.Error("expected a variant spanning a gap not to map")
+	}
+}
+
+func TestReadGzip(t *testing.T) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	_, _ = gz.Write([]byte(testChains))
+	_ = gz.Close()
+	c, err := Read(&buf, GRCh37, GRCh38)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := c.Map("1", 101); !ok || got.Position != 201 {
+		t.Errorf("got %+v, %v", got, ok)
+	}
+
+	for name, in := range map[string]string{
+		"empty":         "",
+		"short header":  "chain 1 chr1 10 + 0 10\n10\n",
+		"block outside": "5 0 0\n",
+		"bad size":      "chain 1 chr1 10 + 0 10 chr1 10 + 0 10 1\nx\n",
+	} {
+		if _, err := Read(strings.NewReader(in), GRCh37, GRCh38); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestNormalizeAssembly(t *testing.T) {
+	for in, want := range map[string]string{"hg19": GRCh37, "GRCh37": GRCh37, "b37": GRCh37, "HG38": GRCh38, "grch38": GRCh38, "mm10": ""} {
+		if got := NormalizeAssembly(in); got != want {
+			t.Errorf("NormalizeAssembly(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+// Package logging sets up the process-wide slog logger and carries
+// correlation fields such as run_id, source and query on contexts, so every
+// record logged with one of slog's Context functions includes the fields of
+// the run, stage and query it belongs to.
+package logging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+// Formats the handler can write records in.
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// Correlation keys attached to records by the pipeline and sources.
+const (
+	KeyRunID  = "run_id"
+	KeySource = "source"
+	KeyQuery  = "query"
+)
+
+// Config selects the log format and the least severe level written.
+type Config struct {
+	Format string `yaml:"format" json:"format"`
+	// Level is debug, info, warn or error.
+	Level string `yaml:"level" json:"level"`
+}
+
+// DefaultConfig logs info and above as text.
+func DefaultConfig() Config {
+	return Config{Format: FormatText, Level: "info"}
+}
+
+// Validate reports every invalid setting at once, naming fields relative to c.
+func (c Config) Validate() error {
+	var errs []error
+	switch c.Format {
+	case "", FormatText, FormatJSON:
+	default:
+		errs = append(errs, fmt.Errorf("format: unknown format %q (want %s or %s)", c.Format, FormatText, FormatJSON))
+	}
+	if _, err := c.level(); err != nil {
+		errs = append(errs, fmt.Errorf("level: %w", err))
+	}
+	return errors.Join(errs...)
+}
+
+func (c Config) level() (slog.Level, error) {
+	var level slog.Level
+	if c.Level == "" {
+		return slog.LevelInfo, nil
+	}
+	if err := level.UnmarshalText([]byte(c.Level)); err != nil {
+		return level, fmt.Errorf("unknown level %q (want debug, info, warn or error)", c.Level)
+	}
+	return level, nil
+}
+
+// New returns a logger writing records to w as cfg selects, adding the
+// correlation fields of each record's context.
+func New(w io.Writer, cfg Config) (*slog.Logger, error) {
+	level, err := cfg.level()
+	if err != nil {
+		return nil, err
+	}
+	opts := &slog.HandlerOptions{Level: level}
+	var h slog.Handler
+	switch strings.ToLower(cfg.Format) {
+	case "", FormatText:
+		h = slog.NewTextHandler(w, opts)
+	case FormatJSON:
+		h = slog.NewJSONHandler(w, opts)
+	default:
+		return nil, fmt.Errorf("unknown log format %q", cfg.Format)
+	}
+	return slog.New(contextHandler{h}), nil
+}
+
+// Setup makes the logger New returns slog's default, which the standard log
+// package then writes through as well.
+func Setup(w io.Writer, cfg Config) error {
+	logger, err := New(w, cfg)
+	if err != nil {
+		return err
+	}
+	slog.SetDefault(logger)
+	return nil
+}
+
+type ctxKey struct{}
+
+// With returns a copy of ctx whose records carry args, alternating keys and
+// values as for slog.Logger.With, on top of the fields ctx already carries. A
+// key that is already set is replaced.
+func With(ctx context.Context, args ...any) context.Context {
+	var r slog.Record
+	r.Add(args...)
+	attrs := append([]slog.Attr(nil), Attrs(ctx)...)
+	r.Attrs(func(a slog.Attr) bool {
+		for i := range attrs {
+			if attrs[i].Key == a.Key {
+				attrs[i] = a
+				return true
+			}
+		}
+		attrs = append(attrs, a)
+		return true
+	})
+	return context.WithValue(ctx, ctxKey{}, attrs)
+}
+
+// Attrs returns the fields ctx carries.
+func Attrs(ctx context.Context) []slog.Attr {
+	attrs, _ := ctx.Value(ctxKey{}).([]slog.Attr)
+	return attrs
+}
+
+// contextHandler adds the fields of a record's context to the record.
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if attrs := Attrs(ctx); len(attrs) > 0 {
+		r = r.Clone()
+		r.AddAttrs(attrs...)
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
@@ -0,0 +1,66 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestLoggerAddsContextFields(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, Config{Format: FormatJSON, Level: "debug"})
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+
+	ctx := With(context.Background(), KeyRunID, "run-1", KeySource, "clinvar")
+	ctx = With(ctx, KeyQuery, "BRCA1[gene]", KeySource, "dbsnp")
+	logger.DebugContext(ctx, "Batch fetched", "start", 500)
+
+	var rec map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &rec); err != nil {
+		t.Fatalf("decode %q: %v", buf.String(), err)
+	}
+	want := map[string]any{"msg": "Batch fetched", "run_id": "run-1", "source": "dbsnp", "query": "BRCA1[gene]", "start": 500.0}
+	for k, v := range want {
+		if rec[k] != v {
+			t.Errorf("%s = %v, want %v", k, rec[k], v)
+		}
+	}
+
+	// The parent context keeps its own fields.
+	if attrs := Attrs(With(ctx, KeySource, "gnomad")); len(attrs) != 3 {
+		t.Errorf("expected 3 fields, got %v", attrs)
+	}
+	if attrs := Attrs(ctx); attrs[1].Value.String() != "dbsnp" {
+		t.Errorf("parent context changed: %v", attrs)
+	}
+}
+
+func TestLoggerTextFormatAndLevel(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, Config{Format: FormatText, Level: "warn"})
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	ctx := With(context.Background(), KeyRunID, "run-1")
+	logger.InfoContext(ctx, "dropped")
+	logger.WarnContext(ctx, "kept")
+
+	out := buf.String()
+	if strings.Contains(out, "dropped") || !strings.Contains(out, "msg=kept run_id=run-1") {
+		t.Fatalf("unexpected output: %q", out)
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	err := Config{Format: "xml", Level: "loud"}.Validate()
+	if err == nil || !strings.Contains(err.Error(), "format:") || !strings.Contains(err.Error(), "level:") {
+		t.Fatalf("expected format and level errors, got %v", err)
+	}
+	if err := (Config{}).Validate(); err != nil {
+		t.Fatalf("expected the zero config to be valid, got %v", err)
+	}
+}
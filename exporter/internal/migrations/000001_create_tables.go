@@ -0,0 +1,56 @@
+package migrations
+
+import (
+	"context"
+
+	"github.com/uptrace/bun"
+
+	"github.com/mkoziy/genome/exporter/internal/models"
+)
+
+func init() {
+	// Migration 1: create tables
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		modelsList := []interface{}{
+			(*models.SNP)(nil),
+			(*models.Significance)(nil),
+			(*models.ClinicalData)(nil),
+			(*models.Phenotype)(nil),
+			(*models.Reference)(nil),
+			(*models.PopulationFreq)(nil),
+			(*models.Translation)(nil),
+			(*models.PhenotypeTranslation)(nil),
+			(*models.SourceMetadata)(nil),
+			(*models.DownloadMetadata)(nil),
+		}
+
+		for _, model := range modelsList {
+			if _, err := db.NewCreateTable().Model(model).IfNotExists().Exec(ctx); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}, func(ctx context.Context, db *bun.DB) error {
+		modelsList := []interface{}{
+			(*models.DownloadMetadata)(nil),
+			(*models.SourceMetadata)(nil),
+			(*models.PhenotypeTranslation)(nil),
+			(*models.Translation)(nil),
+			(*models.PopulationFreq)(nil),
+			(*models.Reference)(nil),
+			(*models.Phenotype)(nil),
+			(*models.ClinicalData)(nil),
+			(*models.Significance)(nil),
+			(*models.SNP)(nil),
+		}
+
+		for _, model := range modelsList {
+			if _, err := db.NewDropTable().Model(model).IfExists().Exec(ctx); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,52 @@
+package migrations
+
+import (
+	"context"
+
+	"github.com/uptrace/bun"
+)
+
+func init() {
+	// Migration 2: indexes
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		indexes := []string{
+			"CREATE INDEX IF NOT EXISTS idx_snps_chromosome_position ON snps(chromosome, position)",
+			"CREATE INDEX IF NOT EXISTS idx_snps_gene_symbol ON snps(gene_symbol)",
+			"CREATE INDEX IF NOT EXISTS idx_significance_score ON snp_significance(total_score DESC)",
+			"CREATE INDEX IF NOT EXISTS idx_clinical_significance ON snp_clinical(clinical_significance)",
+			"CREATE INDEX IF NOT EXISTS idx_clinical_condition ON snp_clinical(condition_name)",
+			"CREATE INDEX IF NOT EXISTS idx_phenotypes_name ON snp_phenotypes(phenotype_name)",
+			"CREATE INDEX IF NOT EXISTS idx_references_pubmed ON snp_references(pubmed_id)",
+			"CREATE INDEX IF NOT EXISTS idx_populations_code ON snp_populations(population_code)",
+			"CREATE INDEX IF NOT EXISTS idx_translations_snp_lang ON snp_translations(snp_id, language_code)",
+		}
+
+		for _, idx := range indexes {
+			if _, err := db.ExecContext(ctx, idx); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}, func(ctx context.Context, db *bun.DB) error {
+		indexes := []string{
+			"DROP INDEX IF EXISTS idx_snps_chromosome_position",
+			"DROP INDEX IF EXISTS idx_snps_gene_symbol",
+			"DROP INDEX IF EXISTS idx_significance_score",
+			"DROP INDEX IF EXISTS idx_clinical_significance",
+			"DROP INDEX IF EXISTS idx_clinical_condition",
+			"DROP INDEX IF EXISTS idx_phenotypes_name",
+			"DROP INDEX IF EXISTS idx_references_pubmed",
+			"DROP INDEX IF EXISTS idx_populations_code",
+			"DROP INDEX IF EXISTS idx_translations_snp_lang",
+		}
+
+		for _, idx := range indexes {
+			if _, err := db.ExecContext(ctx, idx); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
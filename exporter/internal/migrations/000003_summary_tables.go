@@ -0,0 +1,44 @@
+package migrations
+
+import (
+	"context"
+
+	"github.com/uptrace/bun"
+
+	"github.com/mkoziy/genome/exporter/internal/models"
+)
+
+func init() {
+	// Migration 3: summary tables refreshed by the summary package
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		modelsList := []interface{}{
+			(*models.ChromosomeCount)(nil),
+			(*models.ClinicalSignificanceCount)(nil),
+			(*models.GeneCount)(nil),
+			(*models.ScoreHistogramBin)(nil),
+		}
+
+		for _, model := range modelsList {
+			if _, err := db.NewCreateTable().Model(model).IfNotExists().Exec(ctx); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}, func(ctx context.Context, db *bun.DB) error {
+		modelsList := []interface{}{
+			(*models.ScoreHistogramBin)(nil),
+			(*models.GeneCount)(nil),
+			(*models.ClinicalSignificanceCount)(nil),
+			(*models.ChromosomeCount)(nil),
+		}
+
+		for _, model := range modelsList {
+			if _, err := db.NewDropTable().Model(model).IfExists().Exec(ctx); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,20 @@
+package migrations
+
+import (
+	"context"
+
+	"github.com/uptrace/bun"
+
+	"github.com/mkoziy/genome/exporter/internal/models"
+)
+
+func init() {
+	// Migration 4: dataset release history
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		_, err := db.NewCreateTable().Model((*models.DatasetRelease)(nil)).IfNotExists().Exec(ctx)
+		return err
+	}, func(ctx context.Context, db *bun.DB) error {
+		_, err := db.NewDropTable().Model((*models.DatasetRelease)(nil)).IfExists().Exec(ctx)
+		return err
+	})
+}
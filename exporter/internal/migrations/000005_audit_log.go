@@ -0,0 +1,92 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/uptrace/bun"
+
+	"github.com/mkoziy/genome/exporter/internal/models"
+)
+
+// auditedTables maps each audited table to the columns whose changes are recorded.
+var auditedTables = map[string][]string{
+	"snps": {
+		"rsid", "chromosome", "position", "reference_allele", "alternate_alleles",
+		"gene_symbol", "gene_id", "variant_type", "functional_class",
+	},
+	"snp_clinical": {
+		"snp_id", "clinical_significance", "review_status", "condition_name", "condition_id",
+		"inheritance_pattern", "penetrance", "allele_origin", "source", "source_id", "last_evaluated",
+	},
+}
+
+// auditJSON builds a json_object over the given row. JSON-typed columns may be
+// stored as BLOBs, which json_object rejects, so they are cast to text first.
+func auditJSON(prefix string, columns []string) string {
+	pairs := make([]string, len(columns))
+	for i, col := range columns {
+		ref := prefix + "." + col
+		pairs[i] = fmt.Sprintf("'%s', CASE WHEN typeof(%s) = 'blob' THEN CAST(%s AS TEXT) ELSE %s END", col, ref, ref, ref)
+	}
+	return "json_object(" + strings.Join(pairs, ", ") + ")"
+}
+
+func auditTriggers(table string, columns []string) []string {
+	changed := make([]string, len(columns))
+	for i, col := range columns {
+		changed[i] = fmt.Sprintf("OLD.%s IS NOT NEW.%s", col, col)
+	}
+
+	update := fmt.Sprintf(`CREATE TRIGGER IF NOT EXISTS audit_%[1]s_update AFTER UPDATE ON %[1]s
+		WHEN %[2]s
+		BEGIN
+			INSERT INTO audit_log (table_name, row_id, operation, old_values, new_values, changed_at)
+			VALUES ('%[1]s', NEW.id, '%[3]s', %[4]s, %[5]s, CURRENT_TIMESTAMP);
+		END`, table, strings.Join(changed, " OR "), models.AuditUpdate, auditJSON("OLD", columns), auditJSON("NEW", columns))
+
+	del := fmt.Sprintf(`CREATE TRIGGER IF NOT EXISTS audit_%[1]s_delete AFTER DELETE ON %[1]s
+		BEGIN
+			INSERT INTO audit_log (table_name, row_id, operation, old_values, new_values, changed_at)
+			VALUES ('%[1]s', OLD.id, '%[2]s', %[3]s, NULL, CURRENT_TIMESTAMP);
+		END`, table, models.AuditDelete, auditJSON("OLD", columns))
+
+	return []string{update, del}
+}
+
+func init() {
+	// Migration 5: audit log populated by update/delete triggers
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		if _, err := db.NewCreateTable().Model((*models.AuditEntry)(nil)).IfNotExists().Exec(ctx); err != nil {
+			return err
+		}
+		if _, err := db.ExecContext(ctx, "CREATE INDEX IF NOT EXISTS idx_audit_log_row ON audit_log(table_name, row_id)"); err != nil {
+			return err
+		}
+		if _, err := db.ExecContext(ctx, "CREATE INDEX IF NOT EXISTS idx_audit_log_changed_at ON audit_log(changed_at)"); err != nil {
+			return err
+		}
+
+		for table, columns := range auditedTables {
+			for _, trigger := range auditTriggers(table, columns) {
+				if _, err := db.ExecContext(ctx, trigger); err != nil {
+					return err
+				}
+			}
+		}
+
+		return nil
+	}, func(ctx context.Context, db *bun.DB) error {
+		for table := range auditedTables {
+			for _, op := range []string{models.AuditUpdate, models.AuditDelete} {
+				if _, err := db.ExecContext(ctx, fmt.Sprintf("DROP TRIGGER IF EXISTS audit_%s_%s", table, op)); err != nil {
+					return err
+				}
+			}
+		}
+
+		_, err := db.NewDropTable().Model((*models.AuditEntry)(nil)).IfExists().Exec(ctx)
+		return err
+	})
+}
@@ -0,0 +1,57 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/uptrace/bun"
+)
+
+// naturalKeys maps child tables to the unique index backing their upserts.
+// nullable names a key column whose NULLs the index lets repeat, so rows
+// without it have no natural key and are never duplicates.
+var naturalKeys = []struct {
+	table    string
+	index    string
+	columns  []string
+	nullable string
+}{
+	{"snp_clinical", "uq_clinical_natural_key", []string{"snp_id", "source", "condition_name"}, ""},
+	{"snp_phenotypes", "uq_phenotypes_natural_key", []string{"snp_id", "source", "phenotype_name"}, ""},
+	{"snp_references", "uq_references_natural_key", []string{"snp_id", "pubmed_id"}, "pubmed_id"},
+	{"snp_populations", "uq_populations_natural_key", []string{"snp_id", "source", "population_code", "allele"}, ""},
+}
+
+func init() {
+	// Migration 6: natural-key unique indexes for child-table upserts
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		for _, key := range naturalKeys {
+			cols := strings.Join(key.columns, ", ")
+
+			// Collapse duplicates left behind by earlier blind inserts, keeping the newest row.
+			dedupe := fmt.Sprintf("DELETE FROM %s WHERE id NOT IN (SELECT MAX(id) FROM %s GROUP BY %s)", key.table, key.table, cols)
+			if key.nullable != "" {
+				dedupe += fmt.Sprintf(" AND %s IS NOT NULL", key.nullable)
+			}
+			if _, err := db.ExecContext(ctx, dedupe); err != nil {
+				return err
+			}
+
+			create := fmt.Sprintf("CREATE UNIQUE INDEX IF NOT EXISTS %s ON %s(%s)", key.index, key.table, cols)
+			if _, err := db.ExecContext(ctx, create); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}, func(ctx context.Context, db *bun.DB) error {
+		for _, key := range naturalKeys {
+			if _, err := db.ExecContext(ctx, "DROP INDEX IF EXISTS "+key.index); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,24 @@
+package migrations
+
+import (
+	"context"
+
+	"github.com/uptrace/bun"
+)
+
+func init() {
+	// Migration 7: provenance for references so they can be pruned per source
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		if err := addColumn(ctx, db, "snp_references", "source", "VARCHAR"); err != nil {
+			return err
+		}
+		_, err := db.ExecContext(ctx, "CREATE INDEX IF NOT EXISTS idx_references_source ON snp_references(source)")
+		return err
+	}, func(ctx context.Context, db *bun.DB) error {
+		if _, err := db.ExecContext(ctx, "DROP INDEX IF EXISTS idx_references_source"); err != nil {
+			return err
+		}
+		_, err := db.ExecContext(ctx, "ALTER TABLE snp_references DROP COLUMN source")
+		return err
+	})
+}
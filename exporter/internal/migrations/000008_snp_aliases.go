@@ -0,0 +1,23 @@
+package migrations
+
+import (
+	"context"
+
+	"github.com/uptrace/bun"
+
+	"github.com/mkoziy/genome/exporter/internal/models"
+)
+
+func init() {
+	// Migration 8: aliases for rsIDs merged into another SNP
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		if _, err := db.NewCreateTable().Model((*models.SNPAlias)(nil)).IfNotExists().Exec(ctx); err != nil {
+			return err
+		}
+		_, err := db.ExecContext(ctx, "CREATE INDEX IF NOT EXISTS idx_aliases_snp ON snp_aliases(snp_id)")
+		return err
+	}, func(ctx context.Context, db *bun.DB) error {
+		_, err := db.NewDropTable().Model((*models.SNPAlias)(nil)).IfExists().Exec(ctx)
+		return err
+	})
+}
@@ -0,0 +1,30 @@
+package migrations
+
+import (
+	"context"
+
+	"github.com/uptrace/bun"
+)
+
+func init() {
+	// Migration 9: precomputed score rank and percentile
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		if err := addColumn(ctx, db, "snp_significance", "score_rank", "INTEGER"); err != nil {
+			return err
+		}
+		if err := addColumn(ctx, db, "snp_significance", "percentile", "REAL"); err != nil {
+			return err
+		}
+		_, err := db.ExecContext(ctx, "CREATE INDEX IF NOT EXISTS idx_significance_rank ON snp_significance(score_rank)")
+		return err
+	}, func(ctx context.Context, db *bun.DB) error {
+		if _, err := db.ExecContext(ctx, "DROP INDEX IF EXISTS idx_significance_rank"); err != nil {
+			return err
+		}
+		if _, err := db.ExecContext(ctx, "ALTER TABLE snp_significance DROP COLUMN percentile"); err != nil {
+			return err
+		}
+		_, err := db.ExecContext(ctx, "ALTER TABLE snp_significance DROP COLUMN score_rank")
+		return err
+	})
+}
@@ -0,0 +1,83 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/uptrace/bun"
+)
+
+// scoredTables maps each table that feeds the significance score to the columns
+// whose changes invalidate it.
+var scoredTables = map[string][]string{
+	"snp_clinical":    {"snp_id", "clinical_significance", "review_status", "condition_name"},
+	"snp_references":  {"snp_id", "pubmed_id", "journal", "publication_year", "citation_count"},
+	"snp_populations": {"snp_id", "population_code", "allele", "frequency"},
+}
+
+var staleTriggerOps = []string{"insert", "update", "delete"}
+
+// staleTriggers marks the score of every SNP a row change touches as stale.
+// Updates only fire when a scored column changes, so re-importing identical rows
+// does not queue their SNPs for rescoring.
+func staleTriggers(table string, columns []string) []string {
+	changed := make([]string, len(columns))
+	for i, col := range columns {
+		changed[i] = fmt.Sprintf("OLD.%s IS NOT NEW.%s", col, col)
+	}
+
+	insert := fmt.Sprintf(`CREATE TRIGGER IF NOT EXISTS stale_%[1]s_insert AFTER INSERT ON %[1]s
+		BEGIN
+			UPDATE snp_significance SET stale = 1 WHERE snp_id = NEW.snp_id;
+		END`, table)
+
+	update := fmt.Sprintf(`CREATE TRIGGER IF NOT EXISTS stale_%[1]s_update AFTER UPDATE ON %[1]s
+		WHEN %[2]s
+		BEGIN
+			UPDATE snp_significance SET stale = 1 WHERE snp_id IN (OLD.snp_id, NEW.snp_id);
+		END`, table, strings.Join(changed, " OR "))
+
+	del := fmt.Sprintf(`CREATE TRIGGER IF NOT EXISTS stale_%[1]s_delete AFTER DELETE ON %[1]s
+		BEGIN
+			UPDATE snp_significance SET stale = 1 WHERE snp_id = OLD.snp_id;
+		END`, table)
+
+	return []string{insert, update, del}
+}
+
+func init() {
+	// Migration 10: stale flag on scores maintained by child-row triggers
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		if err := addColumn(ctx, db, "snp_significance", "stale", "BOOLEAN NOT NULL DEFAULT 0"); err != nil {
+			return err
+		}
+		if _, err := db.ExecContext(ctx, "CREATE INDEX IF NOT EXISTS idx_significance_stale ON snp_significance(stale)"); err != nil {
+			return err
+		}
+
+		for table, columns := range scoredTables {
+			for _, trigger := range staleTriggers(table, columns) {
+				if _, err := db.ExecContext(ctx, trigger); err != nil {
+					return err
+				}
+			}
+		}
+
+		return nil
+	}, func(ctx context.Context, db *bun.DB) error {
+		for table := range scoredTables {
+			for _, op := range staleTriggerOps {
+				if _, err := db.ExecContext(ctx, fmt.Sprintf("DROP TRIGGER IF EXISTS stale_%s_%s", table, op)); err != nil {
+					return err
+				}
+			}
+		}
+
+		if _, err := db.ExecContext(ctx, "DROP INDEX IF EXISTS idx_significance_stale"); err != nil {
+			return err
+		}
+		_, err := db.ExecContext(ctx, "ALTER TABLE snp_significance DROP COLUMN stale")
+		return err
+	})
+}
@@ -0,0 +1,54 @@
+package migrations
+
+import (
+	"context"
+
+	"github.com/uptrace/bun"
+
+	"github.com/mkoziy/genome/exporter/internal/models"
+)
+
+// archiveScoreTrigger copies a score into snp_significance_history when it is
+// overwritten by a score from another algorithm version.
+const archiveScoreTrigger = `CREATE TRIGGER IF NOT EXISTS archive_snp_significance_version
+	AFTER UPDATE ON snp_significance
+	WHEN OLD.algorithm_version IS NOT NEW.algorithm_version
+	BEGIN
+		INSERT INTO snp_significance_history (snp_id, algorithm_version, total_score, clinical_score,
+			research_score, population_score, functional_score, score_details, calculated_at, archived_at)
+		VALUES (OLD.snp_id, OLD.algorithm_version, OLD.total_score, OLD.clinical_score,
+			OLD.research_score, OLD.population_score, OLD.functional_score, OLD.score_details,
+			OLD.calculated_at, CURRENT_TIMESTAMP);
+	END`
+
+func init() {
+	// Migration 11: scoring algorithm versions and history of superseded scores
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		if err := addColumn(ctx, db, "snp_significance", "algorithm_version", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+			return err
+		}
+		if err := addColumn(ctx, db, "dataset_releases", "score_algorithm_version", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+			return err
+		}
+		if _, err := db.NewCreateTable().Model((*models.SignificanceHistory)(nil)).IfNotExists().Exec(ctx); err != nil {
+			return err
+		}
+		if _, err := db.ExecContext(ctx, "CREATE INDEX IF NOT EXISTS idx_significance_history_snp ON snp_significance_history(snp_id)"); err != nil {
+			return err
+		}
+		_, err := db.ExecContext(ctx, archiveScoreTrigger)
+		return err
+	}, func(ctx context.Context, db *bun.DB) error {
+		if _, err := db.ExecContext(ctx, "DROP TRIGGER IF EXISTS archive_snp_significance_version"); err != nil {
+			return err
+		}
+		if _, err := db.NewDropTable().Model((*models.SignificanceHistory)(nil)).IfExists().Exec(ctx); err != nil {
+			return err
+		}
+		if _, err := db.ExecContext(ctx, "ALTER TABLE dataset_releases DROP COLUMN score_algorithm_version"); err != nil {
+			return err
+		}
+		_, err := db.ExecContext(ctx, "ALTER TABLE snp_significance DROP COLUMN algorithm_version")
+		return err
+	})
+}
@@ -0,0 +1,17 @@
+package migrations
+
+import (
+	"context"
+
+	"github.com/uptrace/bun"
+)
+
+func init() {
+	// Migration 12: total score normalized against clinically annotated SNPs
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		return addColumn(ctx, db, "snp_significance", "normalized_score", "REAL")
+	}, func(ctx context.Context, db *bun.DB) error {
+		_, err := db.ExecContext(ctx, "ALTER TABLE snp_significance DROP COLUMN normalized_score")
+		return err
+	})
+}
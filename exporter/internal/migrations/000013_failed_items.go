@@ -0,0 +1,23 @@
+package migrations
+
+import (
+	"context"
+
+	"github.com/uptrace/bun"
+
+	"github.com/mkoziy/genome/exporter/internal/models"
+)
+
+func init() {
+	// Migration 13: queue of records lost by failed downloads
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		if _, err := db.NewCreateTable().Model((*models.FailedItem)(nil)).IfNotExists().Exec(ctx); err != nil {
+			return err
+		}
+		_, err := db.ExecContext(ctx, "CREATE UNIQUE INDEX IF NOT EXISTS idx_failed_items_item ON failed_items(source, kind, item_key, start)")
+		return err
+	}, func(ctx context.Context, db *bun.DB) error {
+		_, err := db.NewDropTable().Model((*models.FailedItem)(nil)).IfExists().Exec(ctx)
+		return err
+	})
+}
@@ -0,0 +1,64 @@
+package migrations
+
+import (
+	"context"
+
+	"github.com/uptrace/bun"
+
+	"github.com/mkoziy/genome/exporter/internal/models"
+)
+
+func init() {
+	// Migration 14: normalized chrom:pos:ref:alt key for cross-source deduplication
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		if err := addColumn(ctx, db, "snps", "variant_key", "VARCHAR"); err != nil {
+			return err
+		}
+		if _, err := db.ExecContext(ctx, "CREATE INDEX IF NOT EXISTS idx_snps_variant_key ON snps(variant_key)"); err != nil {
+			return err
+		}
+		return backfillVariantKeys(ctx, db)
+	}, func(ctx context.Context, db *bun.DB) error {
+		if _, err := db.ExecContext(ctx, "DROP INDEX IF EXISTS idx_snps_variant_key"); err != nil {
+			return err
+		}
+		_, err := db.ExecContext(ctx, "ALTER TABLE snps DROP COLUMN variant_key")
+		return err
+	})
+}
+
+// backfillVariantKeys derives the key of SNPs written before the column
+// existed, in batches by ID.
+func backfillVariantKeys(ctx context.Context, db *bun.DB) error {
+	const batch = 1000
+	var lastID int64
+	for {
+		var snps []*models.SNP
+		err := db.NewSelect().
+			Model(&snps).
+			Column("id", "chromosome", "position", "reference_allele", "alternate_alleles").
+			Where("id > ?", lastID).
+			OrderExpr("id ASC").
+			Limit(batch).
+			Scan(ctx)
+		if err != nil || len(snps) == 0 {
+			return err
+		}
+		err = db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+			for _, snp := range snps {
+				if _, err := tx.NewUpdate().
+					Model((*models.SNP)(nil)).
+					Set("variant_key = ?", snp.CanonicalKey()).
+					Where("id = ?", snp.ID).
+					Exec(ctx); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		lastID = snps[len(snps)-1].ID
+	}
+}
@@ -0,0 +1,23 @@
+package migrations
+
+import (
+	"context"
+
+	"github.com/uptrace/bun"
+
+	"github.com/mkoziy/genome/exporter/internal/models"
+)
+
+func init() {
+	// Migration 15: records that failed validation during ingestion
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		if _, err := db.NewCreateTable().Model((*models.QuarantinedRecord)(nil)).IfNotExists().Exec(ctx); err != nil {
+			return err
+		}
+		_, err := db.ExecContext(ctx, "CREATE INDEX IF NOT EXISTS idx_quarantined_records_source ON quarantined_records(source, record_id)")
+		return err
+	}, func(ctx context.Context, db *bun.DB) error {
+		_, err := db.NewDropTable().Model((*models.QuarantinedRecord)(nil)).IfExists().Exec(ctx)
+		return err
+	})
+}
@@ -0,0 +1,24 @@
+package migrations
+
+import (
+	"context"
+
+	"github.com/uptrace/bun"
+
+	"github.com/mkoziy/genome/exporter/internal/database"
+)
+
+func init() {
+	// Migration 16: secondary indexes dropped by a bulk load until it rebuilds them
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		_, err := db.ExecContext(ctx, "CREATE TABLE IF NOT EXISTS ? (name TEXT PRIMARY KEY, sql TEXT NOT NULL)",
+			bun.Ident(database.DroppedIndexesTable))
+		return err
+	}, func(ctx context.Context, db *bun.DB) error {
+		if _, err := database.RestoreIndexes(ctx, db); err != nil {
+			return err
+		}
+		_, err := db.ExecContext(ctx, "DROP TABLE IF EXISTS ?", bun.Ident(database.DroppedIndexesTable))
+		return err
+	})
+}
@@ -0,0 +1,23 @@
+package migrations
+
+import (
+	"context"
+
+	"github.com/uptrace/bun"
+
+	"github.com/mkoziy/genome/exporter/internal/models"
+)
+
+func init() {
+	// Migration 17: alleles with genotype-specific effects
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		if _, err := db.NewCreateTable().Model((*models.RiskAllele)(nil)).IfNotExists().Exec(ctx); err != nil {
+			return err
+		}
+		_, err := db.ExecContext(ctx, "CREATE UNIQUE INDEX IF NOT EXISTS uq_risk_alleles_natural_key ON risk_alleles(snp_id, source, allele, condition_name)")
+		return err
+	}, func(ctx context.Context, db *bun.DB) error {
+		_, err := db.NewDropTable().Model((*models.RiskAllele)(nil)).IfExists().Exec(ctx)
+		return err
+	})
+}
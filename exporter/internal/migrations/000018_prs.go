@@ -0,0 +1,44 @@
+package migrations
+
+import (
+	"context"
+
+	"github.com/uptrace/bun"
+
+	"github.com/mkoziy/genome/exporter/internal/models"
+)
+
+func init() {
+	// Migration 18: polygenic risk score models
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		for _, model := range []interface{}{
+			(*models.PRSModel)(nil),
+			(*models.PRSWeight)(nil),
+			(*models.PRSDistribution)(nil),
+		} {
+			if _, err := db.NewCreateTable().Model(model).IfNotExists().Exec(ctx); err != nil {
+				return err
+			}
+		}
+		for _, stmt := range []string{
+			"CREATE UNIQUE INDEX IF NOT EXISTS uq_prs_weights_natural_key ON prs_weights(model_id, rsid, effect_allele)",
+			"CREATE UNIQUE INDEX IF NOT EXISTS uq_prs_distributions_natural_key ON prs_distributions(model_id, population)",
+		} {
+			if _, err := db.ExecContext(ctx, stmt); err != nil {
+				return err
+			}
+		}
+		return nil
+	}, func(ctx context.Context, db *bun.DB) error {
+		for _, model := range []interface{}{
+			(*models.PRSDistribution)(nil),
+			(*models.PRSWeight)(nil),
+			(*models.PRSModel)(nil),
+		} {
+			if _, err := db.NewDropTable().Model(model).IfExists().Exec(ctx); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,110 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/uptrace/bun"
+
+	"github.com/mkoziy/genome/exporter/internal/models"
+)
+
+// changeFeedTables maps each table whose changes are fed to the column
+// naming the SNP a row belongs to.
+var changeFeedTables = map[string]string{
+	"snps":             "id",
+	"snp_significance": "snp_id",
+	"snp_clinical":     "snp_id",
+	"snp_phenotypes":   "snp_id",
+	"snp_references":   "snp_id",
+	"snp_populations":  "snp_id",
+	"risk_alleles":     "snp_id",
+	"snp_translations": "snp_id",
+}
+
+// changeFeedIgnored are columns an update may change without being fed:
+// keys and the timestamps rewritten on every upsert.
+var changeFeedIgnored = map[string]bool{
+	"id":            true,
+	"created_at":    true,
+	"updated_at":    true,
+	"calculated_at": true,
+	"translated_at": true,
+}
+
+func changeFeedTriggers(table, snpColumn string, columns []string) []string {
+	var changed []string
+	for _, col := range columns {
+		if !changeFeedIgnored[col] {
+			changed = append(changed, fmt.Sprintf("OLD.%s IS NOT NEW.%s", col, col))
+		}
+	}
+	insert := func(row, op string) string {
+		rsid := fmt.Sprintf("(SELECT rsid FROM snps WHERE id = %s.%s)", row, snpColumn)
+		if table == "snps" {
+			rsid = row + ".rsid"
+		}
+		return fmt.Sprintf(`INSERT INTO change_feed (record_type, record_id, snp_id, rsid, operation, run_id, changed_at)
+			VALUES ('%s', %s.id, %s.%s, %s, '%s', (SELECT run_id FROM %s), CURRENT_TIMESTAMP);`,
+			table, row, row, snpColumn, rsid, op, models.ChangeRunTable)
+	}
+	return []string{
+		fmt.Sprintf(`CREATE TRIGGER IF NOT EXISTS change_feed_%[1]s_insert AFTER INSERT ON %[1]s
+			BEGIN
+				%[2]s
+			END`, table, insert("NEW", models.ChangeInsert)),
+		fmt.Sprintf(`CREATE TRIGGER IF NOT EXISTS change_feed_%[1]s_update AFTER UPDATE ON %[1]s
+			WHEN %[2]s
+			BEGIN
+				%[3]s
+			END`, table, strings.Join(changed, " OR "), insert("NEW", models.ChangeUpdate)),
+		fmt.Sprintf(`CREATE TRIGGER IF NOT EXISTS change_feed_%[1]s_delete AFTER DELETE ON %[1]s
+			BEGIN
+				%[2]s
+			END`, table, insert("OLD", models.ChangeDelete)),
+	}
+}
+
+func init() {
+	// Migration 19: change feed populated by insert/update/delete triggers
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		if _, err := db.NewCreateTable().Model((*models.Change)(nil)).IfNotExists().Exec(ctx); err != nil {
+			return err
+		}
+		if _, err := db.ExecContext(ctx, "CREATE INDEX IF NOT EXISTS idx_change_feed_changed_at ON change_feed(changed_at)"); err != nil {
+			return err
+		}
+		// A single row, present only while a run writes.
+		if _, err := db.ExecContext(ctx, "CREATE TABLE IF NOT EXISTS ? (id INTEGER PRIMARY KEY CHECK (id = 1), run_id TEXT NOT NULL)",
+			bun.Ident(models.ChangeRunTable)); err != nil {
+			return err
+		}
+
+		for table, snpColumn := range changeFeedTables {
+			var columns []string
+			if err := db.NewRaw("SELECT name FROM pragma_table_info(?) ORDER BY cid", table).Scan(ctx, &columns); err != nil {
+				return err
+			}
+			for _, trigger := range changeFeedTriggers(table, snpColumn, columns) {
+				if _, err := db.ExecContext(ctx, trigger); err != nil {
+					return fmt.Errorf("%s: %w", table, err)
+				}
+			}
+		}
+		return nil
+	}, func(ctx context.Context, db *bun.DB) error {
+		for table := range changeFeedTables {
+			for _, op := range []string{models.ChangeInsert, models.ChangeUpdate, models.ChangeDelete} {
+				if _, err := db.ExecContext(ctx, fmt.Sprintf("DROP TRIGGER IF EXISTS change_feed_%s_%s", table, op)); err != nil {
+					return err
+				}
+			}
+		}
+		if _, err := db.ExecContext(ctx, "DROP TABLE IF EXISTS ?", bun.Ident(models.ChangeRunTable)); err != nil {
+			return err
+		}
+		_, err := db.NewDropTable().Model((*models.Change)(nil)).IfExists().Exec(ctx)
+		return err
+	})
+}
@@ -0,0 +1,71 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/uptrace/bun"
+)
+
+// translationTables are the tables of translations that go through review.
+var translationTables = []string{"snp_translations", "phenotype_translations"}
+
+// translationReviewColumns are the columns recording a translation's review.
+var translationReviewColumns = []struct{ name, definition string }{
+	{"status", "VARCHAR NOT NULL DEFAULT 'machine_draft'"},
+	{"reviewer", "VARCHAR"},
+	{"reviewed_at", "TIMESTAMP"},
+	{"verified_at", "TIMESTAMP"},
+}
+
+// refeedUpdates recreates the change feed's update trigger on table so it
+// compares the table's current columns.
+func refeedUpdates(ctx context.Context, db *bun.DB, table string) error {
+	if _, err := db.ExecContext(ctx, fmt.Sprintf("DROP TRIGGER IF EXISTS change_feed_%s_update", table)); err != nil {
+		return err
+	}
+	var columns []string
+	if err := db.NewRaw("SELECT name FROM pragma_table_info(?) ORDER BY cid", table).Scan(ctx, &columns); err != nil {
+		return err
+	}
+	_, err := db.ExecContext(ctx, changeFeedTriggers(table, changeFeedTables[table], columns)[1])
+	return err
+}
+
+func init() {
+	// Migration 20: review status, reviewer and review times of translations
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		for _, table := range translationTables {
+			for _, col := range translationReviewColumns {
+				if err := addColumn(ctx, db, table, col.name, col.definition); err != nil {
+					return err
+				}
+			}
+			if _, err := db.ExecContext(ctx, fmt.Sprintf("UPDATE %s SET status = 'verified' WHERE verified AND status = 'machine_draft'", table)); err != nil {
+				return err
+			}
+			if _, err := db.ExecContext(ctx, fmt.Sprintf("CREATE INDEX IF NOT EXISTS idx_%[1]s_lang_status ON %[1]s(language_code, status)", table)); err != nil {
+				return err
+			}
+		}
+		return refeedUpdates(ctx, db, "snp_translations")
+	}, func(ctx context.Context, db *bun.DB) error {
+		for _, table := range translationTables {
+			if _, err := db.ExecContext(ctx, fmt.Sprintf("DROP INDEX IF EXISTS idx_%s_lang_status", table)); err != nil {
+				return err
+			}
+			// The change feed trigger names every column; drop it first.
+			if table == "snp_translations" {
+				if _, err := db.ExecContext(ctx, "DROP TRIGGER IF EXISTS change_feed_snp_translations_update"); err != nil {
+					return err
+				}
+			}
+			for _, col := range translationReviewColumns {
+				if _, err := db.ExecContext(ctx, fmt.Sprintf("ALTER TABLE %s DROP COLUMN %s", table, col.name)); err != nil {
+					return err
+				}
+			}
+		}
+		return refeedUpdates(ctx, db, "snp_translations")
+	})
+}
@@ -0,0 +1,23 @@
+package migrations
+
+import (
+	"context"
+
+	"github.com/uptrace/bun"
+
+	"github.com/mkoziy/genome/exporter/internal/models"
+)
+
+func init() {
+	// Migration 21: glossary of terms with fixed translations
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		if _, err := db.NewCreateTable().Model((*models.GlossaryTerm)(nil)).IfNotExists().Exec(ctx); err != nil {
+			return err
+		}
+		_, err := db.ExecContext(ctx, "CREATE UNIQUE INDEX IF NOT EXISTS uq_glossary_terms_natural_key ON glossary_terms(term, language_code)")
+		return err
+	}, func(ctx context.Context, db *bun.DB) error {
+		_, err := db.NewDropTable().Model((*models.GlossaryTerm)(nil)).IfExists().Exec(ctx)
+		return err
+	})
+}
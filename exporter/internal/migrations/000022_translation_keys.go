@@ -0,0 +1,57 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/uptrace/bun"
+)
+
+// translationKeys maps the translation tables to the unique index backing
+// their upserts.
+var translationKeys = []struct {
+	table   string
+	index   string
+	columns []string
+}{
+	{"snp_translations", "uq_translations_natural_key", []string{"snp_id", "language_code", "field_name"}},
+	{"phenotype_translations", "uq_phenotype_translations_natural_key", []string{"phenotype_id", "language_code"}},
+}
+
+func init() {
+	// Migration 22: natural-key unique indexes for translation upserts
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		for _, key := range translationKeys {
+			cols := strings.Join(key.columns, ", ")
+
+			// Collapse duplicates left behind by re-run translation jobs,
+			// keeping the row furthest through review, then the newest,
+			// which readers showed until now.
+			dedupe := fmt.Sprintf(`DELETE FROM %[1]s WHERE id NOT IN (
+				SELECT id FROM (
+					SELECT id, ROW_NUMBER() OVER (
+						PARTITION BY %[2]s
+						ORDER BY CASE status WHEN 'verified' THEN 2 WHEN 'reviewed' THEN 1 ELSE 0 END DESC, id DESC
+					) AS n FROM %[1]s
+				) WHERE n = 1
+			)`, key.table, cols)
+			if _, err := db.ExecContext(ctx, dedupe); err != nil {
+				return err
+			}
+
+			create := fmt.Sprintf("CREATE UNIQUE INDEX IF NOT EXISTS %s ON %s(%s)", key.index, key.table, cols)
+			if _, err := db.ExecContext(ctx, create); err != nil {
+				return err
+			}
+		}
+		return nil
+	}, func(ctx context.Context, db *bun.DB) error {
+		for _, key := range translationKeys {
+			if _, err := db.ExecContext(ctx, "DROP INDEX IF EXISTS "+key.index); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
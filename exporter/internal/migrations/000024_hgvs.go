@@ -0,0 +1,87 @@
+package migrations
+
+import (
+	"context"
+
+	"github.com/uptrace/bun"
+
+	"github.com/mkoziy/genome/exporter/internal/models"
+)
+
+// hgvsColumns are the SNPs' HGVS descriptions, each indexed for lookups.
+var hgvsColumns = []string{"hgvs_genomic", "hgvs_coding", "hgvs_protein"}
+
+func init() {
+	// Migration 24: HGVS descriptions of SNPs for lookups by transcript and protein change
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		for _, column := range hgvsColumns {
+			if err := addColumn(ctx, db, "snps", column, "VARCHAR"); err != nil {
+				return err
+			}
+			if _, err := db.ExecContext(ctx, "CREATE INDEX IF NOT EXISTS idx_snps_"+column+" ON snps("+column+")"); err != nil {
+				return err
+			}
+		}
+		if err := backfillGenomicHGVS(ctx, db); err != nil {
+			return err
+		}
+		// Changes to the descriptions are fed from here on; the backfill
+		// above is not.
+		return refeedUpdates(ctx, db, "snps")
+	}, func(ctx context.Context, db *bun.DB) error {
+		// The change feed's trigger reads the columns; drop it first.
+		if _, err := db.ExecContext(ctx, "DROP TRIGGER IF EXISTS change_feed_snps_update"); err != nil {
+			return err
+		}
+		for _, column := range hgvsColumns {
+			if _, err := db.ExecContext(ctx, "DROP INDEX IF EXISTS idx_snps_"+column); err != nil {
+				return err
+			}
+			if _, err := db.ExecContext(ctx, "ALTER TABLE snps DROP COLUMN "+column); err != nil {
+				return err
+			}
+		}
+		return refeedUpdates(ctx, db, "snps")
+	})
+}
+
+// backfillGenomicHGVS derives the genomic description of SNPs written before
+// the column existed, in batches by ID. Coding and protein descriptions come
+// with the next ClinVar fetch.
+func backfillGenomicHGVS(ctx context.Context, db *bun.DB) error {
+	const batch = 1000
+	var lastID int64
+	for {
+		var snps []*models.SNP
+		err := db.NewSelect().
+			Model(&snps).
+			Column("id", "chromosome", "position", "reference_allele", "alternate_alleles").
+			Where("id > ?", lastID).
+			OrderExpr("id ASC").
+			Limit(batch).
+			Scan(ctx)
+		if err != nil || len(snps) == 0 {
+			return err
+		}
+		err = db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+			for _, snp := range snps {
+				hgvs := snp.GenomicHGVS()
+				if hgvs == nil {
+					continue
+				}
+				if _, err := tx.NewUpdate().
+					Model((*models.SNP)(nil)).
+					Set("hgvs_genomic = ?", *hgvs).
+					Where("id = ?", snp.ID).
+					Exec(ctx); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		lastID = snps[len(snps)-1].ID
+	}
+}
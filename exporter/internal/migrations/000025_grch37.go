@@ -0,0 +1,38 @@
+package migrations
+
+import (
+	"context"
+
+	"github.com/uptrace/bun"
+)
+
+func init() {
+	// Migration 25: GRCh37 coordinates of SNPs alongside the GRCh38 ones
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		if err := addColumn(ctx, db, "snps", "grch37_chromosome", "VARCHAR"); err != nil {
+			return err
+		}
+		if err := addColumn(ctx, db, "snps", "grch37_position", "BIGINT"); err != nil {
+			return err
+		}
+		if _, err := db.ExecContext(ctx, "CREATE INDEX IF NOT EXISTS idx_snps_grch37_chromosome_position ON snps(grch37_chromosome, grch37_position)"); err != nil {
+			return err
+		}
+		// Liftovers filling the coordinates are fed as updates.
+		return refeedUpdates(ctx, db, "snps")
+	}, func(ctx context.Context, db *bun.DB) error {
+		if _, err := db.ExecContext(ctx, "DROP INDEX IF EXISTS idx_snps_grch37_chromosome_position"); err != nil {
+			return err
+		}
+		// The change feed's trigger reads the columns; drop it first.
+		if _, err := db.ExecContext(ctx, "DROP TRIGGER IF EXISTS change_feed_snps_update"); err != nil {
+			return err
+		}
+		for _, column := range []string{"grch37_chromosome", "grch37_position"} {
+			if _, err := db.ExecContext(ctx, "ALTER TABLE snps DROP COLUMN "+column); err != nil {
+				return err
+			}
+		}
+		return refeedUpdates(ctx, db, "snps")
+	})
+}
@@ -0,0 +1,108 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/uptrace/bun"
+
+	"github.com/mkoziy/genome/exporter/internal/models"
+)
+
+func init() {
+	// Migration 26: effect directions of risk alleles and effects of whole genotypes
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		if err := addColumn(ctx, db, "risk_alleles", "direction", "VARCHAR"); err != nil {
+			return err
+		}
+		if _, err := db.ExecContext(ctx, `UPDATE risk_alleles SET direction = CASE
+				WHEN effect IN ('pathogenic', 'likely_pathogenic', 'risk_factor') THEN 'increased'
+				WHEN effect = 'protective' THEN 'decreased'
+				WHEN odds_ratio > 1 THEN 'increased'
+				WHEN odds_ratio < 1 THEN 'decreased'
+			END
+			WHERE direction IS NULL`); err != nil {
+			return err
+		}
+		if err := refeedUpdates(ctx, db, "risk_alleles"); err != nil {
+			return err
+		}
+
+		if _, err := db.NewCreateTable().Model((*models.GenotypeEffect)(nil)).IfNotExists().Exec(ctx); err != nil {
+			return err
+		}
+		if _, err := db.ExecContext(ctx, "CREATE UNIQUE INDEX IF NOT EXISTS uq_genotype_effects_natural_key ON genotype_effects(snp_id, source, genotype, condition_name)"); err != nil {
+			return err
+		}
+		if err := backfillGenotypeEffects(ctx, db); err != nil {
+			return err
+		}
+		// Fed from here on: the backfill derives what consumers already have
+		// as risk alleles.
+		var columns []string
+		if err := db.NewRaw("SELECT name FROM pragma_table_info('genotype_effects') ORDER BY cid").Scan(ctx, &columns); err != nil {
+			return err
+		}
+		for _, trigger := range changeFeedTriggers("genotype_effects", "snp_id", columns) {
+			if _, err := db.ExecContext(ctx, trigger); err != nil {
+				return fmt.Errorf("genotype_effects: %w", err)
+			}
+		}
+		return nil
+	}, func(ctx context.Context, db *bun.DB) error {
+		for _, op := range []string{models.ChangeInsert, models.ChangeUpdate, models.ChangeDelete} {
+			if _, err := db.ExecContext(ctx, fmt.Sprintf("DROP TRIGGER IF EXISTS change_feed_genotype_effects_%s", op)); err != nil {
+				return err
+			}
+		}
+		if _, err := db.NewDropTable().Model((*models.GenotypeEffect)(nil)).IfExists().Exec(ctx); err != nil {
+			return err
+		}
+		// The change feed trigger names every column; drop it first.
+		if _, err := db.ExecContext(ctx, "DROP TRIGGER IF EXISTS change_feed_risk_alleles_update"); err != nil {
+			return err
+		}
+		if _, err := db.ExecContext(ctx, "ALTER TABLE risk_alleles DROP COLUMN direction"); err != nil {
+			return err
+		}
+		return refeedUpdates(ctx, db, "risk_alleles")
+	})
+}
+
+// backfillGenotypeEffects derives the genotype effects of SNPs with risk
+// alleles stored before the table existed, in batches by ID.
+func backfillGenotypeEffects(ctx context.Context, db *bun.DB) error {
+	const batch = 1000
+	var lastID int64
+	for {
+		var snps []*models.SNP
+		err := db.NewSelect().
+			Model(&snps).
+			Column("id", "reference_allele").
+			Relation("RiskAlleles").
+			Where("s.id > ?", lastID).
+			Where("EXISTS (SELECT 1 FROM risk_alleles AS r WHERE r.snp_id = s.id)").
+			OrderExpr("s.id ASC").
+			Limit(batch).
+			Scan(ctx)
+		if err != nil || len(snps) == 0 {
+			return err
+		}
+		var effects []*models.GenotypeEffect
+		for _, snp := range snps {
+			risks := make([]models.RiskAllele, len(snp.RiskAlleles))
+			for i, r := range snp.RiskAlleles {
+				risks[i] = *r
+			}
+			for _, e := range models.DeriveGenotypeEffects(snp, risks) {
+				effects = append(effects, &e)
+			}
+		}
+		if len(effects) > 0 {
+			if _, err := db.NewInsert().Model(&effects).On("CONFLICT DO NOTHING").Exec(ctx); err != nil {
+				return err
+			}
+		}
+		lastID = snps[len(snps)-1].ID
+	}
+}
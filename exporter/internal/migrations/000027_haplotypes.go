@@ -0,0 +1,37 @@
+package migrations
+
+import (
+	"context"
+
+	"github.com/uptrace/bun"
+
+	"github.com/mkoziy/genome/exporter/internal/models"
+)
+
+func init() {
+	// Migration 27: haplotypes and the alleles of their member SNPs
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		for _, model := range []interface{}{(*models.Haplotype)(nil), (*models.HaplotypeSNP)(nil)} {
+			if _, err := db.NewCreateTable().Model(model).IfNotExists().Exec(ctx); err != nil {
+				return err
+			}
+		}
+		for _, stmt := range []string{
+			"CREATE UNIQUE INDEX IF NOT EXISTS uq_haplotypes_natural_key ON haplotypes(gene, name)",
+			"CREATE UNIQUE INDEX IF NOT EXISTS uq_haplotype_snps_natural_key ON haplotype_snps(haplotype_id, rsid)",
+			"CREATE INDEX IF NOT EXISTS idx_haplotype_snps_rsid ON haplotype_snps(rsid)",
+		} {
+			if _, err := db.ExecContext(ctx, stmt); err != nil {
+				return err
+			}
+		}
+		return nil
+	}, func(ctx context.Context, db *bun.DB) error {
+		for _, model := range []interface{}{(*models.HaplotypeSNP)(nil), (*models.Haplotype)(nil)} {
+			if _, err := db.NewDropTable().Model(model).IfExists().Exec(ctx); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,54 @@
+package migrations
+
+import (
+	"context"
+
+	"github.com/uptrace/bun"
+)
+
+// structuralColumns are the SNPs' extents and structural variant fields.
+var structuralColumns = []struct{ name, definition string }{
+	{"end_position", "BIGINT"},
+	{"sv_type", "VARCHAR"},
+	{"sv_length", "BIGINT"},
+}
+
+func init() {
+	// Migration 28: end positions and structural variant types and lengths of SNPs
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		for _, col := range structuralColumns {
+			if err := addColumn(ctx, db, "snps", col.name, col.definition); err != nil {
+				return err
+			}
+		}
+		// ClinVar's variant types were stored as it writes them; the ones
+		// with a counterpart take it. Structural fields come with the next
+		// ClinVar fetch.
+		if _, err := db.ExecContext(ctx, `UPDATE snps SET variant_type = CASE lower(variant_type)
+				WHEN 'single nucleotide variant' THEN 'SNV'
+				WHEN 'snv' THEN 'SNV'
+				WHEN 'insertion' THEN 'insertion'
+				WHEN 'deletion' THEN 'deletion'
+				WHEN 'indel' THEN 'indel'
+				WHEN 'duplication' THEN 'duplication'
+				WHEN 'tandem duplication' THEN 'duplication'
+				WHEN 'copy number gain' THEN 'copy_number_variant'
+				WHEN 'copy number loss' THEN 'copy_number_variant'
+				ELSE variant_type
+			END`); err != nil {
+			return err
+		}
+		// Changes to the new columns are fed too.
+		return refeedUpdates(ctx, db, "snps")
+	}, func(ctx context.Context, db *bun.DB) error {
+		if _, err := db.ExecContext(ctx, "DROP TRIGGER IF EXISTS change_feed_snps_update"); err != nil {
+			return err
+		}
+		for _, col := range structuralColumns {
+			if _, err := db.ExecContext(ctx, "ALTER TABLE snps DROP COLUMN "+col.name); err != nil {
+				return err
+			}
+		}
+		return refeedUpdates(ctx, db, "snps")
+	})
+}
@@ -0,0 +1,65 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/uptrace/bun"
+
+	"github.com/mkoziy/genome/exporter/internal/variant"
+)
+
+// heteroplasmyColumns are the population frequencies' counts of samples
+// heteroplasmic for mitochondrial variants and their largest heteroplasmy.
+var heteroplasmyColumns = []struct{ name, definition string }{
+	{"heteroplasmic_count", "INTEGER"},
+	{"max_heteroplasmy", "REAL"},
+}
+
+// normalizeChromosomes rewrites the spellings of column's chromosomes on
+// snps, such as "chrM" or "23", as variant.NormalizeChromosome does.
+func normalizeChromosomes(ctx context.Context, db *bun.DB, column string) error {
+	var chroms []string
+	if err := db.NewRaw("SELECT DISTINCT ? FROM snps WHERE ? IS NOT NULL", bun.Ident(column), bun.Ident(column)).Scan(ctx, &chroms); err != nil {
+		return err
+	}
+	for _, chrom := range chroms {
+		if normalized := variant.NormalizeChromosome(chrom); normalized != chrom {
+			if _, err := db.NewUpdate().Table("snps").
+				Set("? = ?", bun.Ident(column), normalized).
+				Where("? = ?", bun.Ident(column), chrom).
+				Exec(ctx); err != nil {
+				return fmt.Errorf("normalize %s %q: %w", column, chrom, err)
+			}
+		}
+	}
+	return nil
+}
+
+func init() {
+	// Migration 29: heteroplasmy of mitochondrial population frequencies and
+	// normalized chromosome names
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		for _, col := range heteroplasmyColumns {
+			if err := addColumn(ctx, db, "snp_populations", col.name, col.definition); err != nil {
+				return err
+			}
+		}
+		for _, column := range []string{"chromosome", "grch37_chromosome"} {
+			if err := normalizeChromosomes(ctx, db, column); err != nil {
+				return err
+			}
+		}
+		return refeedUpdates(ctx, db, "snp_populations")
+	}, func(ctx context.Context, db *bun.DB) error {
+		if _, err := db.ExecContext(ctx, "DROP TRIGGER IF EXISTS change_feed_snp_populations_update"); err != nil {
+			return err
+		}
+		for _, col := range heteroplasmyColumns {
+			if _, err := db.ExecContext(ctx, "ALTER TABLE snp_populations DROP COLUMN "+col.name); err != nil {
+				return err
+			}
+		}
+		return refeedUpdates(ctx, db, "snp_populations")
+	})
+}
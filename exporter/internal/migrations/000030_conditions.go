@@ -0,0 +1,42 @@
+package migrations
+
+import (
+	"context"
+
+	"github.com/uptrace/bun"
+
+	"github.com/mkoziy/genome/exporter/internal/models"
+)
+
+func init() {
+	// Migration 30: conditions and their ontology hierarchy
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		for _, model := range []interface{}{(*models.Condition)(nil), (*models.ConditionRelation)(nil)} {
+			if _, err := db.NewCreateTable().Model(model).IfNotExists().Exec(ctx); err != nil {
+				return err
+			}
+		}
+		for _, stmt := range []string{
+			"CREATE UNIQUE INDEX IF NOT EXISTS uq_condition_relations_natural_key ON condition_relations(parent_id, child_id)",
+			"CREATE INDEX IF NOT EXISTS idx_condition_relations_child ON condition_relations(child_id)",
+			"CREATE INDEX IF NOT EXISTS idx_conditions_medgen_id ON conditions(medgen_id)",
+			// Rollups select the clinical rows of every subtype by ID.
+			"CREATE INDEX IF NOT EXISTS idx_clinical_condition_id ON snp_clinical(condition_id)",
+		} {
+			if _, err := db.ExecContext(ctx, stmt); err != nil {
+				return err
+			}
+		}
+		return nil
+	}, func(ctx context.Context, db *bun.DB) error {
+		if _, err := db.ExecContext(ctx, "DROP INDEX IF EXISTS idx_clinical_condition_id"); err != nil {
+			return err
+		}
+		for _, model := range []interface{}{(*models.ConditionRelation)(nil), (*models.Condition)(nil)} {
+			if _, err := db.NewDropTable().Model(model).IfExists().Exec(ctx); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
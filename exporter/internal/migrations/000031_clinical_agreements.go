@@ -0,0 +1,52 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/uptrace/bun"
+
+	"github.com/mkoziy/genome/exporter/internal/models"
+)
+
+// agreementScoredColumns are the columns of clinical_agreements whose
+// changes invalidate the score of their SNP.
+var agreementScoredColumns = []string{"snp_id", "condition_name", "majority_call", "conflicting"}
+
+func init() {
+	// Migration 31: agreement between the submitters of clinical assertions
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		if _, err := db.NewCreateTable().Model((*models.ClinicalAgreement)(nil)).IfNotExists().Exec(ctx); err != nil {
+			return err
+		}
+		if _, err := db.ExecContext(ctx, "CREATE UNIQUE INDEX IF NOT EXISTS uq_clinical_agreements_natural_key ON clinical_agreements(snp_id, source, condition_name)"); err != nil {
+			return err
+		}
+		// Nothing to backfill: submissions were never stored, so the table
+		// fills as ClinVar is fetched again.
+		var columns []string
+		if err := db.NewRaw("SELECT name FROM pragma_table_info('clinical_agreements') ORDER BY cid").Scan(ctx, &columns); err != nil {
+			return err
+		}
+		triggers := append(changeFeedTriggers("clinical_agreements", "snp_id", columns), staleTriggers("clinical_agreements", agreementScoredColumns)...)
+		for _, trigger := range triggers {
+			if _, err := db.ExecContext(ctx, trigger); err != nil {
+				return fmt.Errorf("clinical_agreements: %w", err)
+			}
+		}
+		return nil
+	}, func(ctx context.Context, db *bun.DB) error {
+		for _, op := range []string{models.ChangeInsert, models.ChangeUpdate, models.ChangeDelete} {
+			if _, err := db.ExecContext(ctx, fmt.Sprintf("DROP TRIGGER IF EXISTS change_feed_clinical_agreements_%s", op)); err != nil {
+				return err
+			}
+		}
+		for _, op := range staleTriggerOps {
+			if _, err := db.ExecContext(ctx, fmt.Sprintf("DROP TRIGGER IF EXISTS stale_clinical_agreements_%s", op)); err != nil {
+				return err
+			}
+		}
+		_, err := db.NewDropTable().Model((*models.ClinicalAgreement)(nil)).IfExists().Exec(ctx)
+		return err
+	})
+}
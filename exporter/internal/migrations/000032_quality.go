@@ -0,0 +1,27 @@
+package migrations
+
+import (
+	"context"
+
+	"github.com/uptrace/bun"
+
+	"github.com/mkoziy/genome/exporter/internal/models"
+)
+
+func init() {
+	// Migration 32: per-SNP data quality scores
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		if _, err := db.NewCreateTable().Model((*models.Quality)(nil)).IfNotExists().Exec(ctx); err != nil {
+			return err
+		}
+		// Filters select SNPs scoring at least a quality.
+		_, err := db.ExecContext(ctx, "CREATE INDEX IF NOT EXISTS idx_quality_score ON snp_quality(score)")
+		return err
+	}, func(ctx context.Context, db *bun.DB) error {
+		if _, err := db.ExecContext(ctx, "DROP INDEX IF EXISTS idx_quality_score"); err != nil {
+			return err
+		}
+		_, err := db.NewDropTable().Model((*models.Quality)(nil)).IfExists().Exec(ctx)
+		return err
+	})
+}
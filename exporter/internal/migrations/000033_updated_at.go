@@ -0,0 +1,57 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/uptrace/bun"
+)
+
+// updatedAtTables maps each table given an updated_at column to the column
+// its existing rows take it from.
+var updatedAtTables = map[string]string{
+	"snp_clinical":     "created_at",
+	"snp_phenotypes":   "created_at",
+	"snp_references":   "created_at",
+	"snp_populations":  "created_at",
+	"risk_alleles":     "created_at",
+	"genotype_effects": "created_at",
+	"snp_translations": "translated_at",
+}
+
+func init() {
+	// Migration 33: updated_at on the SNPs' child tables
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		for table, from := range updatedAtTables {
+			exists, err := columnExists(ctx, db, table, "updated_at")
+			if err != nil {
+				return err
+			}
+			// Tables created since have the column already.
+			if !exists {
+				// SQLite adds columns only with constant defaults; bun writes
+				// CURRENT_TIMESTAMP itself, and existing rows are backfilled.
+				if err := addColumn(ctx, db, table, "updated_at", "TIMESTAMP NOT NULL DEFAULT '1970-01-01 00:00:00'"); err != nil {
+					return err
+				}
+				if _, err := db.ExecContext(ctx, fmt.Sprintf("UPDATE %s SET updated_at = %s", table, from)); err != nil {
+					return err
+				}
+			}
+			if _, err := db.ExecContext(ctx, fmt.Sprintf("CREATE INDEX IF NOT EXISTS idx_%[1]s_updated_at ON %[1]s(updated_at)", table)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}, func(ctx context.Context, db *bun.DB) error {
+		for table := range updatedAtTables {
+			if _, err := db.ExecContext(ctx, fmt.Sprintf("DROP INDEX IF EXISTS idx_%s_updated_at", table)); err != nil {
+				return err
+			}
+			if _, err := db.ExecContext(ctx, fmt.Sprintf("ALTER TABLE %s DROP COLUMN updated_at", table)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,57 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/uptrace/bun"
+
+	"github.com/mkoziy/genome/exporter/internal/models"
+)
+
+// rewriteUnknown replaces each distinct value of column on snps outside
+// known with what rewrite returns for it, NULL when that is empty. The
+// models refuse to read unknown values.
+func rewriteUnknown[T ~string](ctx context.Context, db *bun.DB, column string, known []T, rewrite func(string) T) error {
+	var values []string
+	if err := db.NewRaw("SELECT DISTINCT ? FROM snps WHERE ? IS NOT NULL AND ? NOT IN (?)",
+		bun.Ident(column), bun.Ident(column), bun.Ident(column), bun.In(known)).Scan(ctx, &values); err != nil {
+		return err
+	}
+	for _, value := range values {
+		var to any
+		if rewritten := rewrite(value); rewritten != "" {
+			to = string(rewritten)
+		}
+		if _, err := db.NewUpdate().Table("snps").
+			Set("? = ?", bun.Ident(column), to).
+			Where("? = ?", bun.Ident(column), value).
+			Exec(ctx); err != nil {
+			return fmt.Errorf("rewrite %s %q: %w", column, value, err)
+		}
+	}
+	return nil
+}
+
+func init() {
+	// Migration 34: known values in the enum columns of snps, which ClinVar
+	// variant types and molecular consequences were stored unmapped into
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		if err := rewriteUnknown(ctx, db, "variant_type", models.VariantTypes, func(value string) models.VariantType {
+			if vt := models.VariantType(strings.ToLower(value)); vt.IsValid() {
+				return vt
+			}
+			return models.VariantOther
+		}); err != nil {
+			return err
+		}
+		return rewriteUnknown(ctx, db, "functional_class", models.FunctionalClasses, func(value string) models.FunctionalClass {
+			class, _ := models.ParseFunctionalClass(value)
+			return class
+		})
+	}, func(ctx context.Context, db *bun.DB) error {
+		// The values replaced are not kept.
+		return nil
+	})
+}
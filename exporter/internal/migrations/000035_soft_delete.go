@@ -0,0 +1,95 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/uptrace/bun"
+
+	"github.com/mkoziy/genome/exporter/internal/models"
+)
+
+// softDeleteTables are the tables whose rows retractions soft delete.
+var softDeleteTables = []string{
+	"snps",
+	"snp_clinical",
+	"snp_phenotypes",
+	"snp_references",
+	"snp_populations",
+	"risk_alleles",
+	"genotype_effects",
+}
+
+// restale recreates the stale trigger on updates of table, which fires when
+// one of columns changes.
+func restale(ctx context.Context, db *bun.DB, table string, columns []string) error {
+	if _, err := db.ExecContext(ctx, fmt.Sprintf("DROP TRIGGER IF EXISTS stale_%s_update", table)); err != nil {
+		return err
+	}
+	_, err := db.ExecContext(ctx, staleTriggers(table, columns)[1])
+	return err
+}
+
+func init() {
+	// Migration 35: soft deletes of SNPs and their annotations, and the
+	// retractions behind them
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		for _, table := range softDeleteTables {
+			if err := addColumn(ctx, db, table, "deleted_at", "TIMESTAMP"); err != nil {
+				return err
+			}
+			// Retracting and restoring rows are fed as updates.
+			if _, ok := changeFeedTables[table]; ok {
+				if err := refeedUpdates(ctx, db, table); err != nil {
+					return err
+				}
+			}
+		}
+		if _, err := db.ExecContext(ctx, "CREATE INDEX IF NOT EXISTS idx_snps_deleted_at ON snps(deleted_at)"); err != nil {
+			return err
+		}
+		// Retracting a scored row changes the score as deleting it would.
+		for table, columns := range scoredTables {
+			if err := restale(ctx, db, table, append(columns[:len(columns):len(columns)], "deleted_at")); err != nil {
+				return err
+			}
+		}
+
+		if _, err := db.NewCreateTable().Model((*models.Retraction)(nil)).IfNotExists().Exec(ctx); err != nil {
+			return err
+		}
+		_, err := db.ExecContext(ctx, "CREATE INDEX IF NOT EXISTS idx_retractions_snp ON retractions(snp_id)")
+		return err
+	}, func(ctx context.Context, db *bun.DB) error {
+		if _, err := db.ExecContext(ctx, "DROP INDEX IF EXISTS idx_retractions_snp"); err != nil {
+			return err
+		}
+		if _, err := db.NewDropTable().Model((*models.Retraction)(nil)).IfExists().Exec(ctx); err != nil {
+			return err
+		}
+		for table, columns := range scoredTables {
+			if err := restale(ctx, db, table, columns); err != nil {
+				return err
+			}
+		}
+		if _, err := db.ExecContext(ctx, "DROP INDEX IF EXISTS idx_snps_deleted_at"); err != nil {
+			return err
+		}
+		for _, table := range softDeleteTables {
+			// The update trigger reads the column, so SQLite refuses to drop
+			// it until the trigger is gone.
+			if _, err := db.ExecContext(ctx, fmt.Sprintf("DROP TRIGGER IF EXISTS change_feed_%s_update", table)); err != nil {
+				return err
+			}
+			if _, err := db.ExecContext(ctx, fmt.Sprintf("ALTER TABLE %s DROP COLUMN deleted_at", table)); err != nil {
+				return err
+			}
+			if _, ok := changeFeedTables[table]; ok {
+				if err := refeedUpdates(ctx, db, table); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
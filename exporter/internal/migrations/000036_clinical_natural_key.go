@@ -0,0 +1,47 @@
+package migrations
+
+import (
+	"context"
+
+	"github.com/uptrace/bun"
+
+	"github.com/mkoziy/genome/exporter/internal/models"
+)
+
+// collapseClinical deletes all but the most recently updated of the
+// snp_clinical rows sharing key.
+func collapseClinical(ctx context.Context, db *bun.DB, key string) error {
+	_, err := db.ExecContext(ctx, `DELETE FROM snp_clinical WHERE id NOT IN (
+		SELECT id FROM (
+			SELECT id, ROW_NUMBER() OVER (PARTITION BY `+key+` ORDER BY updated_at DESC, id DESC) AS n
+			FROM snp_clinical
+		) WHERE n = 1
+	)`)
+	return err
+}
+
+func init() {
+	// Migration 36: the natural key of clinical assertions takes in the
+	// source's record and the condition's ID
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		if _, err := db.ExecContext(ctx, "DROP INDEX IF EXISTS uq_clinical_natural_key"); err != nil {
+			return err
+		}
+		// Conditions renamed at the source left their assertion under the
+		// old name beside the new one.
+		if err := collapseClinical(ctx, db, models.ClinicalNaturalKey); err != nil {
+			return err
+		}
+		_, err := db.ExecContext(ctx, "CREATE UNIQUE INDEX IF NOT EXISTS uq_clinical_natural_key ON snp_clinical("+models.ClinicalNaturalKey+")")
+		return err
+	}, func(ctx context.Context, db *bun.DB) error {
+		if _, err := db.ExecContext(ctx, "DROP INDEX IF EXISTS uq_clinical_natural_key"); err != nil {
+			return err
+		}
+		if err := collapseClinical(ctx, db, "snp_id, source, condition_name"); err != nil {
+			return err
+		}
+		_, err := db.ExecContext(ctx, "CREATE UNIQUE INDEX IF NOT EXISTS uq_clinical_natural_key ON snp_clinical(snp_id, source, condition_name)")
+		return err
+	})
+}
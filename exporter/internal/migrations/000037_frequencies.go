@@ -0,0 +1,28 @@
+package migrations
+
+import (
+	"context"
+
+	"github.com/uptrace/bun"
+
+	"github.com/mkoziy/genome/exporter/internal/models"
+)
+
+func init() {
+	// Migration 37: per-SNP minor allele frequencies aggregated across
+	// frequency sources
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		if _, err := db.NewCreateTable().Model((*models.Frequency)(nil)).IfNotExists().Exec(ctx); err != nil {
+			return err
+		}
+		// Consumers select variants rarer or commoner than a frequency.
+		_, err := db.ExecContext(ctx, "CREATE INDEX IF NOT EXISTS idx_frequencies_global_maf ON snp_frequencies(global_maf)")
+		return err
+	}, func(ctx context.Context, db *bun.DB) error {
+		if _, err := db.ExecContext(ctx, "DROP INDEX IF EXISTS idx_frequencies_global_maf"); err != nil {
+			return err
+		}
+		_, err := db.NewDropTable().Model((*models.Frequency)(nil)).IfExists().Exec(ctx)
+		return err
+	})
+}
@@ -0,0 +1,27 @@
+package migrations
+
+import (
+	"context"
+
+	"github.com/uptrace/bun"
+
+	"github.com/mkoziy/genome/exporter/internal/models"
+)
+
+func init() {
+	// Migration 38: Hardy–Weinberg tests of the genotypes counted in each
+	// population
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		if _, err := db.NewCreateTable().Model((*models.HardyWeinberg)(nil)).IfNotExists().Exec(ctx); err != nil {
+			return err
+		}
+		_, err := db.ExecContext(ctx, "CREATE UNIQUE INDEX IF NOT EXISTS uq_hardy_weinberg ON snp_hardy_weinberg(snp_id, source, population_code, allele)")
+		return err
+	}, func(ctx context.Context, db *bun.DB) error {
+		if _, err := db.ExecContext(ctx, "DROP INDEX IF EXISTS uq_hardy_weinberg"); err != nil {
+			return err
+		}
+		_, err := db.NewDropTable().Model((*models.HardyWeinberg)(nil)).IfExists().Exec(ctx)
+		return err
+	})
+}
@@ -0,0 +1,28 @@
+package migrations
+
+import (
+	"context"
+
+	"github.com/uptrace/bun"
+
+	"github.com/mkoziy/genome/exporter/internal/models"
+)
+
+func init() {
+	// Migration 39: summary tables of the allele frequency spectrum
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		for _, model := range []interface{}{(*models.FrequencySpectrumBin)(nil), (*models.FrequencyClassCount)(nil)} {
+			if _, err := db.NewCreateTable().Model(model).IfNotExists().Exec(ctx); err != nil {
+				return err
+			}
+		}
+		return nil
+	}, func(ctx context.Context, db *bun.DB) error {
+		for _, model := range []interface{}{(*models.FrequencyClassCount)(nil), (*models.FrequencySpectrumBin)(nil)} {
+			if _, err := db.NewDropTable().Model(model).IfExists().Exec(ctx); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -3,105 +3,16 @@ package migrations
 import (
 	"context"
 	"fmt"
+	"log/slog"
 
 	"github.com/uptrace/bun"
 	"github.com/uptrace/bun/migrate"
-
-	"github.com/mkoziy/genome/exporter/internal/models"
 )
 
+// Migrations holds all registered migrations. Bun derives each migration name
+// from its source file, so every migration lives in a NNNNNN_name.go file.
 var Migrations = migrate.NewMigrations()
 
-func init() {
-	// Migration 1: create tables
-	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
-		modelsList := []interface{}{
-			(*models.SNP)(nil),
-			(*models.Significance)(nil),
-			(*models.ClinicalData)(nil),
-			(*models.Phenotype)(nil),
-			(*models.Reference)(nil),
-			(*models.PopulationFreq)(nil),
-			(*models.Translation)(nil),
-			(*models.PhenotypeTranslation)(nil),
-			(*models.SourceMetadata)(nil),
-			(*models.DownloadMetadata)(nil),
-		}
-
-		for _, model := range modelsList {
-			if _, err := db.NewCreateTable().Model(model).IfNotExists().Exec(ctx); err != nil {
-				return err
-			}
-		}
-
-		return nil
-	}, func(ctx context.Context, db *bun.DB) error {
-		modelsList := []interface{}{
-			(*models.DownloadMetadata)(nil),
-			(*models.SourceMetadata)(nil),
-			(*models.PhenotypeTranslation)(nil),
-			(*models.Translation)(nil),
-			(*models.PopulationFreq)(nil),
-			(*models.Reference)(nil),
-			(*models.Phenotype)(nil),
-			(*models.ClinicalData)(nil),
-			(*models.Significance)(nil),
-			(*models.SNP)(nil),
-		}
-
-		for _, model := range modelsList {
-			if _, err := db.NewDropTable().Model(model).IfExists().Exec(ctx); err != nil {
-				return err
-			}
-		}
-
-		return nil
-	})
-
-	// Migration 2: indexes
-	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
-		indexes := []string{
-			"CREATE INDEX IF NOT EXISTS idx_snps_chromosome_position ON snps(chromosome, position)",
-			"CREATE INDEX IF NOT EXISTS idx_snps_gene_symbol ON snps(gene_symbol)",
-			"CREATE INDEX IF NOT EXISTS idx_significance_score ON snp_significance(total_score DESC)",
-			"CREATE INDEX IF NOT EXISTS idx_clinical_significance ON snp_clinical(clinical_significance)",
-			"CREATE INDEX IF NOT EXISTS idx_clinical_condition ON snp_clinical(condition_name)",
-			"CREATE INDEX IF NOT EXISTS idx_phenotypes_name ON snp_phenotypes(phenotype_name)",
-			"CREATE INDEX IF NOT EXISTS idx_references_pubmed ON snp_references(pubmed_id)",
-			"CREATE INDEX IF NOT EXISTS idx_populations_code ON snp_populations(population_code)",
-			"CREATE INDEX IF NOT EXISTS idx_translations_snp_lang ON snp_translations(snp_id, language_code)",
-		}
-
-		for _, idx := range indexes {
-			if _, err := db.ExecContext(ctx, idx); err != nil {
-				return err
-			}
-		}
-
-		return nil
-	}, func(ctx context.Context, db *bun.DB) error {
-		indexes := []string{
-			"DROP INDEX IF EXISTS idx_snps_chromosome_position",
-			"DROP INDEX IF EXISTS idx_snps_gene_symbol",
-			"DROP INDEX IF EXISTS idx_significance_score",
-			"DROP INDEX IF EXISTS idx_clinical_significance",
-			"DROP INDEX IF EXISTS idx_clinical_condition",
-			"DROP INDEX IF EXISTS idx_phenotypes_name",
-			"DROP INDEX IF EXISTS idx_references_pubmed",
-			"DROP INDEX IF EXISTS idx_populations_code",
-			"DROP INDEX IF EXISTS idx_translations_snp_lang",
-		}
-
-		for _, idx := range indexes {
-			if _, err := db.ExecContext(ctx, idx); err != nil {
-				return err
-			}
-		}
-
-		return nil
-	})
-}
-
 // RunMigrations runs all pending migrations.
 func RunMigrations(ctx context.Context, db *bun.DB) error {
 	migrator := migrate.NewMigrator(db, Migrations)
@@ -116,10 +27,28 @@ func RunMigrations(ctx context.Context, db *bun.DB) error {
 	}
 
 	if group.IsZero() {
-		fmt.Println("No new migrations to run")
+		slog.InfoContext(ctx, "No new migrations to run")
 		return nil
 	}
 
-	fmt.Printf("Migrated to %s\n", group)
+	slog.InfoContext(ctx, "Migrated", "group", group.String())
 	return nil
 }
+
+// addColumn adds a column unless it already exists. Fresh databases get new
+// columns from the model definitions in migration 1, so later migrations that
+// add the same columns to older databases must be no-ops there.
+func addColumn(ctx context.Context, db *bun.DB, table, column, definition string) error {
+	exists, err := columnExists(ctx, db, table, column)
+	if err != nil || exists {
+		return err
+	}
+	_, err = db.ExecContext(ctx, fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition))
+	return err
+}
+
+func columnExists(ctx context.Context, db *bun.DB, table, column string) (bool, error) {
+	var count int
+	err := db.NewRaw("SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?", table, column).Scan(ctx, &count)
+	return count > 0, err
+}
@@ -0,0 +1,168 @@
+package migrations
+
+import (
+	"context"
+	"testing"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/migrate"
+
+	"github.com/mkoziy/genome/exporter/internal/database"
+	"github.com/mkoziy/genome/exporter/internal/models"
+)
+
+func newTestDB(t *testing.T) *bun.DB {
+	t.Helper()
+	db, err := database.NewDB("file:"+t.Name()+"?mode=memory&cache=shared", false)
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	return db
+}
+
+// migrateThrough applies the migrations up to and including the one named
+// last, as a database last upgraded then would have them.
+func migrateThrough(t *testing.T, db *bun.DB, last string) {
+	t.Helper()
+	ctx := context.Background()
+	upTo := migrate.NewMigrations()
+	for _, m := range Migrations.Sorted() {
+		upTo.Add(m)
+		if m.Name == last {
+			break
+		}
+	}
+	migrator := migrate.NewMigrator(db, upTo)
+	if err := migrator.Init(ctx); err != nil {
+		t.Fatalf("init: %v", err)
+	}
+	if _, err := migrator.Migrate(ctx); err != nil {
+		t.Fatalf("migrate through %s: %v", last, err)
+	}
+}
+
+func TestNaturalKeysKeepReferencesWithoutPubMedID(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+	migrateThrough(t, db, "000005")
+
+	snp := &models.SNP{RsID: "rs1", Chromosome: "1", Position: 100, ReferenceAllele: "C", VariantType: models.VariantSNV}
+	if _, err := db.NewInsert().Model(snp).Exec(ctx); err != nil {
+		t.Fatalf("insert snp: %v", err)
+	}
+	pmid, doi, url := "123", "10.1000/1", "https://example.org/1"
+	refs := []*models.Reference{
+		{SNPID: snp.ID, PubmedID: &pmid},
+		{SNPID: snp.ID, PubmedID: &pmid},
+		{SNPID: snp.ID, DOI: &doi},
+		{SNPID: snp.ID, URL: &url},
+	}
+	if _, err := db.NewInsert().Model(&refs).Exec(ctx); err != nil {
+		t.Fatalf("insert references: %v", err)
+	}
+
+	if err := RunMigrations(ctx, db); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	var got []*models.Reference
+	if err := db.NewSelect().Model(&got).Order("id").Scan(ctx); err != nil {
+		t.Fatalf("select references: %v", err)
+	}
+	if len(got) != 3 {
+		t.Fatalf("references = %d, want the newer PubMed duplicate and both without a PubMed ID", len(got))
+	}
+	if got[0].ID != refs[1].ID || got[1].DOI == nil || got[2].URL == nil {
+		t.Errorf("kept references %d, %d, %d; want %d, %d, %d", got[0].ID, got[1].ID, got[2].ID, refs[1].ID, refs[2].ID, refs[3].ID)
+	}
+}
+
+func TestMigrateRollbackMigrate(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+	migrator := migrate.NewMigrator(db, Migrations)
+	if err := migrator.Init(ctx); err != nil {
+		t.Fatalf("init: %v", err)
+	}
+
+	if _, err := migrator.Migrate(ctx); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	if _, err := migrator.Rollback(ctx); err != nil {
+		t.Fatalf("rollback: %v", err)
+	}
+	if _, err := migrator.Migrate(ctx); err != nil {
+		t.Fatalf("migrate again: %v", err)
+	}
+}
+
+// dropSNPColumns drops columns from snps, as a database created before a
+// migration added them lacks them, with the change feed's trigger on snps
+// rebuilt over the columns left.
+func dropSNPColumns(t *testing.T, db *bun.DB, columns ...string) {
+	t.Helper()
+	ctx := context.Background()
+	if _, err := db.ExecContext(ctx, "DROP TRIGGER IF EXISTS change_feed_snps_update"); err != nil {
+		t.Fatalf("drop trigger: %v", err)
+	}
+	for _, column := range columns {
+		if _, err := db.ExecContext(ctx, "ALTER TABLE snps DROP COLUMN "+column); err != nil {
+			t.Fatalf("drop %s: %v", column, err)
+		}
+	}
+	if err := refeedUpdates(ctx, db, "snps"); err != nil {
+		t.Fatalf("refeed: %v", err)
+	}
+}
+
+// countFeedUpdates counts the updates of snps the change feed recorded.
+func countFeedUpdates(t *testing.T, db *bun.DB) int {
+	t.Helper()
+	var n int
+	if err := db.NewRaw("SELECT COUNT(*) FROM change_feed WHERE record_type = 'snps' AND operation = ?", models.ChangeUpdate).
+		Scan(context.Background(), &n); err != nil {
+		t.Fatalf("count change feed: %v", err)
+	}
+	return n
+}
+
+func TestHGVSChangesAreFed(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+	migrateThrough(t, db, "000023")
+	dropSNPColumns(t, db, hgvsColumns...)
+	if _, err := db.ExecContext(ctx, `INSERT INTO snps (rsid, chromosome, position, reference_allele, alternate_alleles, variant_type)
+		VALUES ('rs1', '1', 100, 'C', '["T"]', 'SNV')`); err != nil {
+		t.Fatalf("insert snp: %v", err)
+	}
+	migrateThrough(t, db, "000024")
+
+	before := countFeedUpdates(t, db)
+	if _, err := db.ExecContext(ctx, "UPDATE snps SET hgvs_coding = 'NM_000001.1:c.1C>T' WHERE rsid = 'rs1'"); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if got := countFeedUpdates(t, db); got != before+1 {
+		t.Fatalf("expected the HGVS change fed, updates went from %d to %d", before, got)
+	}
+}
+
+func TestGRCh37ChangesAreFed(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+	migrateThrough(t, db, "000024")
+	dropSNPColumns(t, db, "grch37_chromosome", "grch37_position")
+	if _, err := db.ExecContext(ctx, `INSERT INTO snps (rsid, chromosome, position, reference_allele, alternate_alleles, variant_type)
+		VALUES ('rs1', '1', 100, 'C', '["T"]', 'SNV')`); err != nil {
+		t.Fatalf("insert snp: %v", err)
+	}
+	migrateThrough(t, db, "000025")
+
+	before := countFeedUpdates(t, db)
+	if _, err := db.ExecContext(ctx, "UPDATE snps SET grch37_chromosome = '1', grch37_position = 90 WHERE rsid = 'rs1'"); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if got := countFeedUpdates(t, db); got != before+1 {
+		t.Fatalf("expected the liftover fed, updates went from %d to %d", before, got)
+	}
+}
@@ -0,0 +1,254 @@
+// Package mobile writes the compact database a mobile app embeds: a trimmed
+// SQLite file keyed by numeric rsID holding only what the app shows for a
+// genotype, the score, the risk alleles with their effects and the
+// translated texts, without the evidence, references and provenance of the
+// full database.
+package mobile
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/uptrace/bun"
+
+	"github.com/mkoziy/genome/exporter/internal/attribution"
+	"github.com/mkoziy/genome/exporter/internal/database"
+	"github.com/mkoziy/genome/exporter/internal/models"
+	"github.com/mkoziy/genome/exporter/internal/repositories"
+)
+
+// FormatVersion is stored in the artifact's meta table and bumped whenever
+// its schema changes, so apps can refuse files they cannot read.
+const FormatVersion = 1
+
+// schema is the artifact's layout. rsIDs are stored as integers without the
+// rs prefix so the snps table is keyed by its rowid; the child tables are
+// WITHOUT ROWID tables clustered by rsID, so a lookup reads one page run.
+var schema = []string{
+	`CREATE TABLE meta (key TEXT PRIMARY KEY, value TEXT NOT NULL) WITHOUT ROWID`,
+	`CREATE TABLE snps (
+		rsid INTEGER PRIMARY KEY,
+		gene TEXT,
+		score REAL
+	)`,
+	`CREATE TABLE effects (
+		rsid INTEGER NOT NULL,
+		allele TEXT NOT NULL,
+		effect TEXT NOT NULL,
+		condition TEXT NOT NULL,
+		recessive INTEGER NOT NULL,
+		PRIMARY KEY (rsid, allele, effect, condition)
+	) WITHOUT ROWID`,
+	`CREATE TABLE texts (
+		rsid INTEGER NOT NULL,
+		lang TEXT NOT NULL,
+		field TEXT NOT NULL,
+		text TEXT NOT NULL,
+		PRIMARY KEY (rsid, lang, field)
+	) WITHOUT ROWID`,
+}
+
+// Options select what goes into the artifact.
+type Options struct {
+	// MinScore leaves out SNPs scoring below it, and unscored SNPs when
+	// positive.
+	MinScore float64
+	// MinQuality leaves out SNPs whose data quality is below it, and
+	// unassessed SNPs when positive.
+	MinQuality float64
+	// Languages restricts the texts to these language codes; all when empty.
+	Languages []string
+	// BatchSize is how many SNPs are read and written at a time.
+	BatchSize int
+}
+
+// Stats counts the rows written.
+type Stats struct {
+	SNPs    int   `json:"snps"`
+	Effects int   `json:"effects"`
+	Texts   int   `json:"texts"`
+	Bytes   int64 `json:"bytes"`
+}
+
+// Export writes the artifact for the SNPs of db to path, which must not
+// exist yet. It is built in a temporary file and renamed into place, so a
+// failed export leaves nothing behind.
+func Export(ctx context.Context, db *bun.DB, path string, opts Options) (*Stats, error) {
+	if _, err := os.Stat(path); err == nil {
+		return nil, fmt.Errorf("export target %s already exists", path)
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("stat export target: %w", err)
+	}
+	tmp := path + ".tmp"
+	_ = os.Remove(tmp)
+
+	stats, err := write(ctx, db, tmp, opts)
+	if err != nil {
+		_ = os.Remove(tmp)
+		return nil, err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+		return nil, fmt.Errorf("rename export: %w", err)
+	}
+	return stats, nil
+}
+
+func write(ctx context.Context, db *bun.DB, path string, opts Options) (*Stats, error) {
+	out, err := database.NewDB(path, false)
+	if err != nil {
+		return nil, fmt.Errorf("create %s: %w", path, err)
+	}
+	defer func() {
+		_ = out.Close()
+	}()
+	for _, stmt := range schema {
+		if _, err := out.ExecContext(ctx, stmt); err != nil {
+			return nil, fmt.Errorf("create schema: %w", err)
+		}
+	}
+
+	stats := &Stats{}
+	err = repositories.ForEachSNP(ctx, db, opts.BatchSize, func(batch []*models.SNP) error {
+		var included []*models.SNP
+		for _, snp := range batch {
+			if opts.includes(snp) {
+				included = append(included, snp)
+			}
+		}
+		if len(included) == 0 {
+			return nil
+		}
+		texts, err := loadTexts(ctx, db, included, opts.Languages)
+		if err != nil {
+			return fmt.Errorf("load texts: %w", err)
+		}
+		return out.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+			return writeBatch(ctx, tx, included, texts)
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = out.QueryRowContext(ctx, "SELECT (SELECT COUNT(*) FROM snps), (SELECT COUNT(*) FROM effects), (SELECT COUNT(*) FROM texts)").
+		Scan(&stats.SNPs, &stats.Effects, &stats.Texts)
+	if err != nil {
+		return nil, fmt.Errorf("count rows: %w", err)
+	}
+
+	// The counts are of the full database the artifact was trimmed from.
+	manifest, err := attribution.Build(ctx, db)
+	if err != nil {
+		return nil, fmt.Errorf("attribution: %w", err)
+	}
+	var credits strings.Builder
+	if err := manifest.WriteJSON(&credits); err != nil {
+		return nil, fmt.Errorf("attribution: %w", err)
+	}
+
+	meta := map[string]string{
+		"attribution":    credits.String(),
+		"format_version": strconv.Itoa(FormatVersion),
+		"generated_at":   time.Now().UTC().Format(time.RFC3339),
+		"min_score":      strconv.FormatFloat(opts.MinScore, 'f', -1, 64),
+		"min_quality":    strconv.FormatFloat(opts.MinQuality, 'f', -1, 64),
+		"languages":      strings.Join(opts.Languages, ","),
+		"snp_count":      strconv.Itoa(stats.SNPs),
+	}
+	for key, value := range meta {
+		if _, err := out.ExecContext(ctx, "INSERT INTO meta (key, value) VALUES (?, ?)", key, value); err != nil {
+			return nil, fmt.Errorf("write meta: %w", err)
+		}
+	}
+	// Leave a single self-contained file: no WAL, no free pages.
+	if _, err := out.ExecContext(ctx, "PRAGMA journal_mode = DELETE"); err != nil {
+		return nil, fmt.Errorf("leave wal mode: %w", err)
+	}
+	if _, err := out.ExecContext(ctx, "VACUUM"); err != nil {
+		return nil, fmt.Errorf("vacuum: %w", err)
+	}
+	if err := out.Close(); err != nil {
+		return nil, fmt.Errorf("close %s: %w", path, err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	stats.Bytes = info.Size()
+	return stats, nil
+}
+
+// includes reports whether snp has a numeric rsID, scores at least MinScore
+// and has a quality of at least MinQuality.
+func (o Options) includes(snp *models.SNP) bool {
+	if _, ok := models.RsIDNumber(snp.RsID); !ok {
+		return false
+	}
+	if o.MinScore > 0 && (snp.Significance == nil || snp.Significance.TotalScore < o.MinScore) {
+		return false
+	}
+	if o.MinQuality > 0 && (snp.Quality == nil || snp.Quality.Score < o.MinQuality) {
+		return false
+	}
+	return true
+}
+
+func writeBatch(ctx context.Context, tx bun.Tx, batch []*models.SNP, texts map[int64][]*models.Translation) error {
+	for _, snp := range batch {
+		rsid, _ := models.RsIDNumber(snp.RsID)
+		var score *float64
+		if snp.Significance != nil {
+			score = &snp.Significance.TotalScore
+		}
+		if _, err := tx.ExecContext(ctx, "INSERT INTO snps (rsid, gene, score) VALUES (?, ?, ?)", rsid, snp.GeneSymbol, score); err != nil {
+			return fmt.Errorf("write %s: %w", snp.RsID, err)
+		}
+
+		for _, risk := range snp.RiskAlleles {
+			// Sources may assert the same effect; the app needs it once,
+			// recessive if any source knows the condition to be.
+			if _, err := tx.ExecContext(ctx, `INSERT INTO effects (rsid, allele, effect, condition, recessive) VALUES (?, ?, ?, ?, ?)
+				ON CONFLICT DO UPDATE SET recessive = max(recessive, excluded.recessive)`,
+				rsid, risk.Allele, risk.Effect, risk.ConditionName, risk.IsRecessive()); err != nil {
+				return fmt.Errorf("write effects of %s: %w", snp.RsID, err)
+			}
+		}
+		for _, t := range texts[snp.ID] {
+			if _, err := tx.ExecContext(ctx, `INSERT OR REPLACE INTO texts (rsid, lang, field, text) VALUES (?, ?, ?, ?)`,
+				rsid, t.LanguageCode, t.FieldName, t.TranslatedText); err != nil {
+				return fmt.Errorf("write texts of %s: %w", snp.RsID, err)
+			}
+		}
+	}
+	return nil
+}
+
+// loadTexts returns the translations of the batch's SNPs by SNP ID, verified
+// ones last so they replace unverified ones of the same field on insert.
+func loadTexts(ctx context.Context, db *bun.DB, batch []*models.SNP, languages []string) (map[int64][]*models.Translation, error) {
+	ids := make([]int64, len(batch))
+	for i, snp := range batch {
+		ids[i] = snp.ID
+	}
+	var rows []*models.Translation
+	q := db.NewSelect().
+		Model(&rows).
+		Where("t.snp_id IN (?)", bun.In(ids)).
+		OrderExpr("t.verified ASC, t.id ASC")
+	if len(languages) > 0 {
+		q = q.Where("t.language_code IN (?)", bun.In(languages))
+	}
+	if err := q.Scan(ctx); err != nil {
+		return nil, err
+	}
+	texts := make(map[int64][]*models.Translation, len(batch))
+	for _, row := range rows {
+		texts[row.SNPID] = append(texts[row.SNPID], row)
+	}
+	return texts, nil
+}
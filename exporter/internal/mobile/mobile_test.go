@@ -0,0 +1,125 @@
+package mobile
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	"github.com/uptrace/bun"
+
+	"github.com/mkoziy/genome/exporter/internal/attribution"
+	"github.com/mkoziy/genome/exporter/internal/database"
+	"github.com/mkoziy/genome/exporter/internal/migrations"
+	"github.com/mkoziy/genome/exporter/internal/models"
+	"github.com/mkoziy/genome/exporter/internal/repositories"
+)
+
+func newSourceDB(t *testing.T) *bun.DB {
+	t.Helper()
+	ctx := context.Background()
+	db, err := database.NewDB("file:"+t.Name()+"?mode=memory&cache=shared", false)
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	if err := migrations.RunMigrations(ctx, db); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	gene := "CFTR"
+	snps := []*models.SNP{
+		{RsID: "rs113993960", Chromosome: "7", Position: 1, ReferenceAllele: "C", AlternateAlleles: models.StringArray{"T"}, VariantType: models.VariantSNV, GeneSymbol: &gene},
+		{RsID: "rs2", Chromosome: "1", Position: 2, ReferenceAllele: "A", AlternateAlleles: models.StringArray{"G"}, VariantType: models.VariantSNV},
+		{RsID: "rs3", Chromosome: "1", Position: 3, ReferenceAllele: "A", AlternateAlleles: models.StringArray{"G"}, VariantType: models.VariantSNV},
+	}
+	if _, err := db.NewInsert().Model(&snps).Exec(ctx); err != nil {
+		t.Fatalf("insert snps: %v", err)
+	}
+	for i, score := range []float64{90, 10} {
+		if _, err := db.NewInsert().Model(&models.Significance{SNPID: snps[i].ID, TotalScore: score}).Exec(ctx); err != nil {
+			t.Fatalf("insert significance: %v", err)
+		}
+	}
+	recessive := "Autosomal recessive inheritance"
+	risks := []*models.RiskAllele{
+		{SNPID: snps[0].ID, Allele: "T", Effect: "pathogenic", ConditionName: "Cystic fibrosis", Inheritance: &recessive, Source: models.SourceClinVar},
+		{SNPID: snps[0].ID, Allele: "T", Effect: "pathogenic", ConditionName: "Cystic fibrosis", Source: models.SourceGWAS},
+	}
+	if _, err := db.NewInsert().Model(&risks).Exec(ctx); err != nil {
+		t.Fatalf("insert risk alleles: %v", err)
+	}
+	translations := []*models.Translation{
+		{SNPID: snps[0].ID, LanguageCode: "de", FieldName: models.TranslationSummary, TranslatedText: "geprüft", Verified: true},
+		{SNPID: snps[0].ID, LanguageCode: "de", FieldName: models.TranslationSummary, TranslatedText: "maschinell"},
+		{SNPID: snps[0].ID, LanguageCode: "fr", FieldName: models.TranslationSummary, TranslatedText: "résumé"},
+	}
+	for _, tr := range translations {
+		if err := repositories.UpsertTranslations(ctx, db, []*models.Translation{tr}); err != nil {
+			t.Fatalf("upsert translation: %v", err)
+		}
+	}
+	return db
+}
+
+func TestExport(t *testing.T) {
+	ctx := context.Background()
+	db := newSourceDB(t)
+	path := filepath.Join(t.TempDir(), "mobile.db")
+
+	stats, err := Export(ctx, db, path, Options{MinScore: 50, Languages: []string{"de"}, BatchSize: 1})
+	if err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	if stats.SNPs != 1 || stats.Effects != 1 || stats.Texts != 1 || stats.Bytes == 0 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+
+	mob, err := database.NewDB(path, false)
+	if err != nil {
+		t.Fatalf("open export: %v", err)
+	}
+	defer func() { _ = mob.Close() }()
+
+	var gene string
+	var score float64
+	if err := mob.QueryRowContext(ctx, "SELECT gene, score FROM snps WHERE rsid = 113993960").Scan(&gene, &score); err != nil {
+		t.Fatalf("query snp: %v", err)
+	}
+	if gene != "CFTR" || score != 90 {
+		t.Fatalf("unexpected snp row: %s %v", gene, score)
+	}
+	var recessive bool
+	if err := mob.QueryRowContext(ctx, "SELECT recessive FROM effects WHERE rsid = 113993960 AND allele = 'T'").Scan(&recessive); err != nil {
+		t.Fatalf("query effect: %v", err)
+	}
+	if !recessive {
+		t.Fatalf("expected the ClinVar inheritance to mark the effect recessive")
+	}
+	var text string
+	if err := mob.QueryRowContext(ctx, "SELECT text FROM texts WHERE rsid = 113993960 AND lang = 'de' AND field = 'summary'").Scan(&text); err != nil {
+		t.Fatalf("query text: %v", err)
+	}
+	if text != "geprüft" {
+		t.Fatalf("expected the verified translation, got %q", text)
+	}
+	var version string
+	if err := mob.QueryRowContext(ctx, "SELECT value FROM meta WHERE key = 'format_version'").Scan(&version); err != nil || version != "1" {
+		t.Fatalf("expected format version 1, got %q (%v)", version, err)
+	}
+	var credits string
+	if err := mob.QueryRowContext(ctx, "SELECT value FROM meta WHERE key = 'attribution'").Scan(&credits); err != nil {
+		t.Fatalf("query attribution: %v", err)
+	}
+	var manifest attribution.Manifest
+	if err := json.Unmarshal([]byte(credits), &manifest); err != nil {
+		t.Fatalf("decode attribution: %v", err)
+	}
+	if len(manifest.Sources) != 2 || manifest.Sources[0].Name != "clinvar" || manifest.Sources[1].Name != "gwas_catalog" {
+		t.Fatalf("expected ClinVar and the GWAS Catalog credited, got %+v", manifest.Sources)
+	}
+
+	if _, err := Export(ctx, db, path, Options{}); err == nil {
+		t.Fatalf("expected an existing target to be refused")
+	}
+}
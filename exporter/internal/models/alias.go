@@ -0,0 +1,28 @@
+package models
+
+import (
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// SNPAlias maps a retired rsID (merged or withdrawn in a dbSNP build) to the SNP
+// it was merged into.
+type SNPAlias struct {
+	bun.BaseModel `bun:"table:snp_aliases,alias:sa"`
+
+	ID        int64     `bun:"id,pk,autoincrement" json:"id"`
+	RsID      string    `bun:"rsid,unique,notnull" json:"rsid"`
+	SNPID     int64     `bun:"snp_id,notnull" json:"snp_id"`
+	Reason    string    `bun:"reason,notnull" json:"reason"`
+	CreatedAt time.Time `bun:"created_at,nullzero,notnull,default:current_timestamp" json:"created_at"`
+
+	SNP *SNP `bun:"rel:belongs-to,join:snp_id=id" json:"-"`
+}
+
+// AliasReasonDuplicate marks aliases created by merging duplicate coordinates.
+const AliasReasonDuplicate = "duplicate_coordinates"
+
+// AliasReasonVariantKey marks aliases created when an rsID arrived for a
+// variant already stored under another rsID.
+const AliasReasonVariantKey = "variant_key"
@@ -0,0 +1,27 @@
+package models
+
+import (
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// Audit operations recorded by the audit triggers.
+const (
+	AuditUpdate = "update"
+	AuditDelete = "delete"
+)
+
+// AuditEntry records the before/after values of a changed SNP or clinical row.
+// Entries are written by database triggers, never by application code.
+type AuditEntry struct {
+	bun.BaseModel `bun:"table:audit_log,alias:al"`
+
+	ID        int64                  `bun:"id,pk,autoincrement" json:"id"`
+	TableName string                 `bun:"table_name,notnull" json:"table_name"`
+	RowID     int64                  `bun:"row_id,notnull" json:"row_id"`
+	Operation string                 `bun:"operation,notnull" json:"operation"`
+	OldValues map[string]interface{} `bun:"old_values,type:json" json:"old_values,omitempty"`
+	NewValues map[string]interface{} `bun:"new_values,type:json" json:"new_values,omitempty"`
+	ChangedAt time.Time              `bun:"changed_at,nullzero,notnull,default:current_timestamp" json:"changed_at"`
+}
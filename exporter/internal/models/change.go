@@ -0,0 +1,39 @@
+package models
+
+import (
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// Change feed operations recorded by the change feed triggers.
+const (
+	ChangeInsert = "insert"
+	ChangeUpdate = "update"
+	ChangeDelete = "delete"
+)
+
+// ChangeRunTable holds the ID of the pipeline run writing to the database,
+// if any, which the change feed triggers copy into each change.
+const ChangeRunTable = "change_feed_run"
+
+// Change is one row of a SNP table inserted, updated or deleted. Changes are
+// written by database triggers, never by application code; their IDs
+// increase, so a consumer resumes after the last ID it read.
+//
+// SNPID is the SNP the row belongs to, the row itself for the snps table, so
+// a consumer indexing whole SNPs knows which to refresh. RsID is the SNP's
+// rsID when the change was recorded, unknown for a row deleted along with
+// its SNP.
+type Change struct {
+	bun.BaseModel `bun:"table:change_feed,alias:cf"`
+
+	ID         int64     `bun:"id,pk,autoincrement" json:"id"`
+	RecordType string    `bun:"record_type,notnull" json:"record_type"`
+	RecordID   int64     `bun:"record_id,notnull" json:"record_id"`
+	SNPID      int64     `bun:"snp_id,notnull" json:"snp_id"`
+	RsID       *string   `bun:"rsid" json:"rsid,omitempty"`
+	Operation  string    `bun:"operation,notnull" json:"operation"`
+	RunID      *string   `bun:"run_id" json:"run_id,omitempty"`
+	ChangedAt  time.Time `bun:"changed_at,nullzero,notnull,default:current_timestamp" json:"changed_at"`
+}
@@ -1,6 +1,9 @@
 package models
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"time"
 
 	"github.com/uptrace/bun"
@@ -23,10 +26,68 @@ type ClinicalData struct {
 	SourceID             *string              `bun:"source_id" json:"source_id,omitempty"`
 	LastEvaluated        *time.Time           `bun:"last_evaluated" json:"last_evaluated,omitempty"`
 	CreatedAt            time.Time            `bun:"created_at,nullzero,notnull,default:current_timestamp" json:"created_at"`
+	UpdatedAt            time.Time            `bun:"updated_at,nullzero,notnull,default:current_timestamp" json:"updated_at"`
+	DeletedAt            *time.Time           `bun:"deleted_at,soft_delete" json:"deleted_at,omitempty"`
 
 	SNP *SNP `bun:"rel:belongs-to,join:snp_id=id" json:"-"`
 }
 
+// ClinicalNaturalKey lists the columns and expressions of the unique index
+// on snp_clinical that upserts conflict on; see ClinicalData.NaturalKey.
+const ClinicalNaturalKey = "snp_id, source, COALESCE(source_id, ''), COALESCE(NULLIF(condition_id, ''), condition_name)"
+
+var _ bun.BeforeAppendModelHook = (*ClinicalData)(nil)
+
+// BeforeAppendModel updates the timestamp on modifications and refuses
+// unknown enum values.
+func (c *ClinicalData) BeforeAppendModel(ctx context.Context, query bun.Query) error {
+	touch(query, &c.UpdatedAt)
+	return checkValues(query, c.ClinicalSignificance, c.ReviewStatus, c.Source)
+}
+
+// Validate checks that the annotation names a condition and uses known
+// significance, review status and source values.
+func (c *ClinicalData) Validate() error {
+	if c.ConditionName == "" {
+		return errors.New("condition name is required")
+	}
+	if !c.ClinicalSignificance.IsValid() {
+		return fmt.Errorf("unknown clinical significance %q", c.ClinicalSignificance)
+	}
+	if !c.ReviewStatus.IsValid() {
+		return fmt.Errorf("unknown review status %q", c.ReviewStatus)
+	}
+	if !c.Source.IsValid() {
+		return fmt.Errorf("unknown source %q", c.Source)
+	}
+	return nil
+}
+
+// NaturalKey identifies the assertion among those stored: its SNP, source,
+// record at the source and condition. The condition is its ID where it has
+// one, so an assertion whose condition the source renames stays the same
+// assertion.
+func (c *ClinicalData) NaturalKey() string {
+	var sourceID string
+	if c.SourceID != nil {
+		sourceID = *c.SourceID
+	}
+	return fmt.Sprintf("%d\x00%s\x00%s\x00%s", c.SNPID, c.Source, sourceID, c.ConditionKey())
+}
+
+// ConditionKey identifies the assertion's condition: its ID where it has
+// one, else its name.
+func (c *ClinicalData) ConditionKey() string {
+	return conditionKey(c.ConditionID, c.ConditionName)
+}
+
+func conditionKey(id *string, name string) string {
+	if id != nil && *id != "" {
+		return *id
+	}
+	return name
+}
+
 // IsPathogenic returns true if variant is pathogenic or likely pathogenic.
 func (c *ClinicalData) IsPathogenic() bool {
 	return c.ClinicalSignificance == ClinicalPathogenic || c.ClinicalSignificance == ClinicalLikelyPathogenic
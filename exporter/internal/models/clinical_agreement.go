@@ -0,0 +1,149 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// ClinicalAgreement is how far the submitters of clinical assertions on a
+// SNP agree about one condition: how many classify it pathogenic, benign or
+// of uncertain significance, the classification most of them make and
+// whether they conflict. A source's aggregate ClinicalData row for the
+// condition gives one classification; this says how settled it is.
+type ClinicalAgreement struct {
+	bun.BaseModel `bun:"table:clinical_agreements,alias:ca"`
+
+	ID            int64   `bun:"id,pk,autoincrement" json:"id"`
+	SNPID         int64   `bun:"snp_id,notnull" json:"snp_id"`
+	ConditionName string  `bun:"condition_name,notnull" json:"condition_name"`
+	ConditionID   *string `bun:"condition_id" json:"condition_id,omitempty"`
+	// PathogenicCount, BenignCount and UncertainCount count the submissions
+	// classifying the SNP pathogenic or likely pathogenic, benign or likely
+	// benign, and of uncertain significance. OtherCount counts the rest,
+	// such as risk factors and drug responses, which take no side.
+	PathogenicCount int `bun:"pathogenic_count,notnull" json:"pathogenic_count"`
+	BenignCount     int `bun:"benign_count,notnull" json:"benign_count"`
+	UncertainCount  int `bun:"uncertain_count,notnull" json:"uncertain_count"`
+	OtherCount      int `bun:"other_count,notnull" json:"other_count"`
+	// MajorityCall is ClinicalPathogenic, ClinicalBenign or
+	// ClinicalUncertainSignif, whichever most submissions make, and empty
+	// if none do or two tie.
+	MajorityCall ClinicalSignificance `bun:"majority_call,nullzero" json:"majority_call,omitempty"`
+	// Agreement is the share of the submissions taking a side that make
+	// MajorityCall, from 0 to 1.
+	Agreement float64 `bun:"agreement,notnull" json:"agreement"`
+	// Conflicting is set when submissions take more than one side, as
+	// ClinVar reports conflicting classifications of pathogenicity.
+	Conflicting bool       `bun:"conflicting,notnull" json:"conflicting"`
+	Source      DataSource `bun:"source,notnull" json:"source"`
+	UpdatedAt   time.Time  `bun:"updated_at,nullzero,notnull,default:current_timestamp" json:"updated_at"`
+
+	SNP *SNP `bun:"rel:belongs-to,join:snp_id=id" json:"-"`
+}
+
+var _ bun.BeforeAppendModelHook = (*ClinicalAgreement)(nil)
+
+// BeforeAppendModel updates the timestamp on modifications and refuses
+// unknown enum values.
+func (a *ClinicalAgreement) BeforeAppendModel(ctx context.Context, query bun.Query) error {
+	touch(query, &a.UpdatedAt)
+	return checkValues(query, a.MajorityCall, a.Source)
+}
+
+// Validate checks that the agreement names a condition, counts no fewer than
+// zero submissions, makes a majority call of a known side, if any, and uses
+// a known source.
+func (a *ClinicalAgreement) Validate() error {
+	if a.ConditionName == "" {
+		return errors.New("condition name is required")
+	}
+	if a.PathogenicCount < 0 || a.BenignCount < 0 || a.UncertainCount < 0 || a.OtherCount < 0 {
+		return errors.New("submission counts must not be negative")
+	}
+	switch a.MajorityCall {
+	case "", ClinicalPathogenic, ClinicalBenign, ClinicalUncertainSignif:
+	default:
+		return fmt.Errorf("majority call %q is not pathogenic, benign or uncertain_significance", a.MajorityCall)
+	}
+	if !a.Source.IsValid() {
+		return fmt.Errorf("unknown source %q", a.Source)
+	}
+	return nil
+}
+
+// ConditionKey identifies the condition as ClinicalData.ConditionKey does,
+// so an agreement matches the assertions on its condition.
+func (a *ClinicalAgreement) ConditionKey() string {
+	return conditionKey(a.ConditionID, a.ConditionName)
+}
+
+// Submissions returns how many submissions the agreement counts.
+func (a *ClinicalAgreement) Submissions() int {
+	return a.PathogenicCount + a.BenignCount + a.UncertainCount + a.OtherCount
+}
+
+// Against returns how many submissions take a side other than that of a
+// classification: pathogenic, benign or uncertain significance. None are
+// against a classification taking no side, such as a risk factor.
+func (a *ClinicalAgreement) Against(significance ClinicalSignificance) int {
+	sided := a.PathogenicCount + a.BenignCount + a.UncertainCount
+	switch significance {
+	case ClinicalPathogenic, ClinicalLikelyPathogenic:
+		return sided - a.PathogenicCount
+	case ClinicalBenign, ClinicalLikelyBenign:
+		return sided - a.BenignCount
+	case ClinicalUncertainSignif:
+		return sided - a.UncertainCount
+	}
+	return 0
+}
+
+// Summarize sets the counts, majority call, agreement and conflict of a from
+// the classifications of the submissions on its condition.
+func (a *ClinicalAgreement) Summarize(calls []ClinicalSignificance) {
+	a.PathogenicCount, a.BenignCount, a.UncertainCount, a.OtherCount = 0, 0, 0, 0
+	for _, c := range calls {
+		switch c {
+		case ClinicalPathogenic, ClinicalLikelyPathogenic:
+			a.PathogenicCount++
+		case ClinicalBenign, ClinicalLikelyBenign:
+			a.BenignCount++
+		case ClinicalUncertainSignif:
+			a.UncertainCount++
+		default:
+			a.OtherCount++
+		}
+	}
+
+	a.MajorityCall, a.Agreement, a.Conflicting = "", 0, false
+	sides := []struct {
+		call  ClinicalSignificance
+		count int
+	}{
+		{ClinicalPathogenic, a.PathogenicCount},
+		{ClinicalBenign, a.BenignCount},
+		{ClinicalUncertainSignif, a.UncertainCount},
+	}
+	var taking, taken, best int
+	for _, side := range sides {
+		if side.count == 0 {
+			continue
+		}
+		taking += side.count
+		taken++
+		switch {
+		case side.count > best:
+			a.MajorityCall, best = side.call, side.count
+		case side.count == best:
+			a.MajorityCall = ""
+		}
+	}
+	if taking > 0 {
+		a.Agreement = float64(best) / float64(taking)
+	}
+	a.Conflicting = taken > 1
+}
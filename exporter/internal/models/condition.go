@@ -0,0 +1,78 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// ConditionOntology is the ontology a condition's ID is from.
+type ConditionOntology string
+
+// Condition ontologies.
+const (
+	// OntologyMONDO IDs look like "MONDO:0004994".
+	OntologyMONDO ConditionOntology = "MONDO"
+	// OntologyMedGen IDs are UMLS concept IDs such as "C0878544", as ClinVar
+	// names conditions by.
+	OntologyMedGen ConditionOntology = "MedGen"
+)
+
+// ConditionOntologies lists the known condition ontologies.
+var ConditionOntologies = []ConditionOntology{OntologyMONDO, OntologyMedGen}
+
+// IsValid reports whether o is a known condition ontology.
+func (o ConditionOntology) IsValid() bool {
+	for _, known := range ConditionOntologies {
+		if o == known {
+			return true
+		}
+	}
+	return false
+}
+
+// Condition is a term of a disease ontology, placed in its hierarchy by
+// ConditionRelation. Clinical assertions name conditions by MedGen ID, so
+// terms of other ontologies carry the MedGen ID they map to, if any, to
+// reach them.
+type Condition struct {
+	bun.BaseModel `bun:"table:conditions,alias:cond"`
+
+	ID       string            `bun:"id,pk" json:"id"`
+	Name     string            `bun:"name,notnull" json:"name"`
+	Ontology ConditionOntology `bun:"ontology,notnull" json:"ontology"`
+	// MedGenID is the MedGen ID of a term of another ontology.
+	MedGenID  *string   `bun:"medgen_id" json:"medgen_id,omitempty"`
+	UpdatedAt time.Time `bun:"updated_at,nullzero,notnull,default:current_timestamp" json:"updated_at"`
+}
+
+// Validate checks that the condition has an ID, a name and a known
+// ontology.
+func (c *Condition) Validate() error {
+	if strings.TrimSpace(c.ID) == "" {
+		return errors.New("condition ID is required")
+	}
+	if strings.TrimSpace(c.Name) == "" {
+		return fmt.Errorf("condition %s has no name", c.ID)
+	}
+	if !c.Ontology.IsValid() {
+		return fmt.Errorf("condition %s: unknown ontology %q", c.ID, c.Ontology)
+	}
+	return nil
+}
+
+// ConditionRelation makes one condition a subtype of another, as its
+// ontology's is-a hierarchy does. A condition may have several parents.
+type ConditionRelation struct {
+	bun.BaseModel `bun:"table:condition_relations,alias:cr"`
+
+	ID       int64  `bun:"id,pk,autoincrement" json:"id"`
+	ParentID string `bun:"parent_id,notnull" json:"parent_id"`
+	ChildID  string `bun:"child_id,notnull" json:"child_id"`
+
+	Parent *Condition `bun:"rel:belongs-to,join:parent_id=id" json:"-"`
+	Child  *Condition `bun:"rel:belongs-to,join:child_id=id" json:"-"`
+}
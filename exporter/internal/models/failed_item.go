@@ -0,0 +1,42 @@
+package models
+
+import (
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// Failed item kinds, which say what ItemKey identifies and so how the item is
+// retried.
+const (
+	// FailedItemUID is a source record ID whose fetch failed.
+	FailedItemUID = "uid"
+	// FailedItemAccession is a fetched record that could not be mapped.
+	FailedItemAccession = "accession"
+	// FailedItemBatch is the batch at Start of the search ItemKey, whose
+	// search failed before its record IDs were known.
+	FailedItemBatch = "batch"
+)
+
+// FailedItem is a record a download lost, queued so the retry-failed command
+// can process it again. Failing again increments Attempts; succeeding deletes
+// the row.
+//
+// Start, Query and Error have no column default: bun leaves a column with a
+// default out of a multi-row insert when the first row's value is zero,
+// losing it for every row.
+type FailedItem struct {
+	bun.BaseModel `bun:"table:failed_items,alias:fi"`
+
+	ID            int64     `bun:"id,pk,autoincrement" json:"id"`
+	Source        string    `bun:"source,notnull" json:"source"`
+	Kind          string    `bun:"kind,notnull" json:"kind"`
+	ItemKey       string    `bun:"item_key,notnull" json:"item_key"`
+	Start         int       `bun:"start,notnull" json:"start"`
+	Query         string    `bun:"query,notnull" json:"query"`
+	Error         string    `bun:"error,notnull" json:"error"`
+	Attempts      int       `bun:"attempts,notnull,default:1" json:"attempts"`
+	RunID         *string   `bun:"run_id" json:"run_id,omitempty"`
+	FirstFailedAt time.Time `bun:"first_failed_at,nullzero,notnull,default:current_timestamp" json:"first_failed_at"`
+	LastFailedAt  time.Time `bun:"last_failed_at,nullzero,notnull,default:current_timestamp" json:"last_failed_at"`
+}
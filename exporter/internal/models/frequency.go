@@ -0,0 +1,125 @@
+package models
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// Superpopulations are the continental groups of the 1000 Genomes Project,
+// which the populations of every source are grouped into.
+const (
+	SuperpopulationAFR = "AFR"
+	SuperpopulationAMR = "AMR"
+	SuperpopulationEAS = "EAS"
+	SuperpopulationEUR = "EUR"
+	SuperpopulationSAS = "SAS"
+)
+
+// Variants with a MAF below RareMAF are rare, and from CommonMAF common;
+// those between are of low frequency.
+const (
+	RareMAF   = 0.01
+	CommonMAF = 0.05
+)
+
+// FrequencySources ranks the sources of population frequencies, the first
+// preferred: gnomAD, then the 1000 Genomes Project, then ALFA. Sources not
+// listed come after them all.
+var FrequencySources = []DataSource{SourceGnomAD, SourceThousandGenomes, SourceALFA}
+
+// FrequencyRank returns the position of source in FrequencySources, or
+// len(FrequencySources) for sources not listed.
+func FrequencyRank(source DataSource) int {
+	for i, s := range FrequencySources {
+		if s == source {
+			return i
+		}
+	}
+	return len(FrequencySources)
+}
+
+// globalPopulations are the codes sources give the frequency over all their
+// samples, in lower case.
+var globalPopulations = map[string]bool{
+	"":       true,
+	"all":    true,
+	"global": true,
+	"total":  true,
+}
+
+// superpopulationCodes lists, for each superpopulation, the population codes
+// of 1000 Genomes, gnomAD and ALFA that stand for it, in lower case and in
+// order of preference: a source reporting several of them is represented by
+// the first, so gnomAD's EUR is its non-Finnish Europeans.
+var superpopulationCodes = map[string][]string{
+	SuperpopulationAFR: {"afr", "african", "african_american", "african_others"},
+	SuperpopulationAMR: {"amr", "latin_american_2", "latin_american_1"},
+	SuperpopulationEAS: {"eas", "east_asian"},
+	SuperpopulationEUR: {"eur", "nfe", "european", "fin", "asj"},
+	SuperpopulationSAS: {"sas", "south_asian"},
+}
+
+// normalizePopulation lower-cases code and joins its words with
+// underscores, as ALFA's population names are matched.
+func normalizePopulation(code string) string {
+	return strings.Join(strings.Fields(strings.ToLower(strings.ReplaceAll(code, "_", " "))), "_")
+}
+
+// IsGlobalPopulation reports whether code is a source's code for all of its
+// samples together.
+func IsGlobalPopulation(code string) bool {
+	return globalPopulations[normalizePopulation(code)]
+}
+
+// Superpopulation returns the superpopulation code stands for and its rank
+// among the codes standing for it, 0 the preferred. Codes of no
+// superpopulation return themselves, ranked 0, and so form groups of their
+// own.
+func Superpopulation(code string) (string, int) {
+	normalized := normalizePopulation(code)
+	for superpopulation, codes := range superpopulationCodes {
+		for i, c := range codes {
+			if c == normalized {
+				return superpopulation, i
+			}
+		}
+	}
+	return code, 0
+}
+
+// Frequency is the canonical frequency of a SNP's minor allele, aggregated
+// from all of its PopulationFreq rows: the global MAF, the MAF in each
+// superpopulation and the highest and lowest of those. Each is taken from
+// the first of FrequencySources to report it, so consumers read one
+// aggregation rather than each weighing the sources their own way.
+type Frequency struct {
+	bun.BaseModel `bun:"table:snp_frequencies,alias:fq"`
+
+	ID    int64 `bun:"id,pk,autoincrement" json:"id"`
+	SNPID int64 `bun:"snp_id,notnull,unique" json:"snp_id"`
+	// GlobalMAF is nil when no source reports a frequency over all of its
+	// samples.
+	GlobalMAF    *float64   `bun:"global_maf" json:"global_maf,omitempty"`
+	GlobalSource DataSource `bun:"global_source,nullzero" json:"global_source,omitempty"`
+	// SuperpopulationMAFs maps each superpopulation measured to its MAF.
+	SuperpopulationMAFs FrequencyMap `bun:"superpopulation_mafs,type:json" json:"superpopulation_mafs,omitempty"`
+	// MaxMAF and MinMAF are the highest and lowest of SuperpopulationMAFs,
+	// nil when it is empty.
+	MaxMAF             *float64  `bun:"max_maf" json:"max_maf,omitempty"`
+	MaxSuperpopulation string    `bun:"max_superpopulation,nullzero" json:"max_superpopulation,omitempty"`
+	MinMAF             *float64  `bun:"min_maf" json:"min_maf,omitempty"`
+	MinSuperpopulation string    `bun:"min_superpopulation,nullzero" json:"min_superpopulation,omitempty"`
+	CalculatedAt       time.Time `bun:"calculated_at,nullzero,notnull,default:current_timestamp" json:"calculated_at"`
+
+	SNP *SNP `bun:"rel:belongs-to,join:snp_id=id" json:"-"`
+}
+
+var _ bun.BeforeAppendModelHook = (*Frequency)(nil)
+
+// BeforeAppendModel refuses unknown sources.
+func (f *Frequency) BeforeAppendModel(ctx context.Context, query bun.Query) error {
+	return checkValues(query, f.GlobalSource)
+}
@@ -0,0 +1,118 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// GenotypeEffect is the effect of a whole genotype of a SNP on a condition
+// or trait, which says more than its risk alleles alone: one copy of an
+// allele of a recessive condition is a carrier state, two are affected.
+type GenotypeEffect struct {
+	bun.BaseModel `bun:"table:genotype_effects,alias:ge"`
+
+	ID    int64 `bun:"id,pk,autoincrement" json:"id"`
+	SNPID int64 `bun:"snp_id,notnull" json:"snp_id"`
+	// Genotype is the alleles as the SNP writes them, sorted and joined by
+	// "/", such as "C/T"; see GenotypeOf.
+	Genotype string `bun:"genotype,notnull" json:"genotype"`
+	// Effect is what having the genotype means, e.g. pathogenic, carrier or
+	// the association type of a GWAS hit.
+	Effect        string          `bun:"effect,notnull" json:"effect"`
+	Direction     EffectDirection `bun:"direction,nullzero" json:"direction,omitempty"`
+	ConditionName string          `bun:"condition_name,notnull" json:"condition_name"`
+	// Magnitude is the size of the effect, where the source measures one:
+	// an odds ratio against the homozygous reference genotype.
+	Magnitude *NullableFloat64 `bun:"magnitude" json:"magnitude,omitempty"`
+	Source    DataSource       `bun:"source,notnull" json:"source"`
+	CreatedAt time.Time        `bun:"created_at,nullzero,notnull,default:current_timestamp" json:"created_at"`
+	UpdatedAt time.Time        `bun:"updated_at,nullzero,notnull,default:current_timestamp" json:"updated_at"`
+	DeletedAt *time.Time       `bun:"deleted_at,soft_delete" json:"deleted_at,omitempty"`
+
+	SNP *SNP `bun:"rel:belongs-to,join:snp_id=id" json:"-"`
+}
+
+var _ bun.BeforeAppendModelHook = (*GenotypeEffect)(nil)
+
+// BeforeAppendModel updates the timestamp on modifications and refuses
+// unknown enum values.
+func (g *GenotypeEffect) BeforeAppendModel(ctx context.Context, query bun.Query) error {
+	touch(query, &g.UpdatedAt)
+	return checkValues(query, g.Source)
+}
+
+// Validate checks that the genotype is named, has an effect on a condition
+// in a known direction, if any, and uses a known source.
+func (g *GenotypeEffect) Validate() error {
+	if g.Genotype == "" {
+		return errors.New("genotype is required")
+	}
+	if g.Effect == "" || g.ConditionName == "" {
+		return errors.New("effect and condition name are required")
+	}
+	if g.Direction != "" && !g.Direction.IsValid() {
+		return fmt.Errorf("unknown effect direction %q", g.Direction)
+	}
+	if g.Magnitude != nil && g.Magnitude.Valid && g.Magnitude.Float64 <= 0 {
+		return fmt.Errorf("magnitude %g must be positive", g.Magnitude.Float64)
+	}
+	if !g.Source.IsValid() {
+		return fmt.Errorf("unknown source %q", g.Source)
+	}
+	return nil
+}
+
+// GenotypeOf returns the genotype of alleles as genotype effects name it:
+// sorted and joined by "/", so "T/C" and "C/T" are the same genotype.
+func GenotypeOf(alleles ...string) string {
+	sorted := slices.Clone(alleles)
+	slices.Sort(sorted)
+	return strings.Join(sorted, "/")
+}
+
+// DeriveGenotypeEffects returns the effects of the heterozygous and
+// homozygous genotypes of each risk allele of snp, other than its reference
+// allele. One copy of an allele of a recessive condition is a carrier state
+// with no effect; otherwise both genotypes take the allele's direction. An
+// allele's odds ratio is taken to multiply per copy, so the homozygote's
+// magnitude is its square.
+func DeriveGenotypeEffects(snp *SNP, risks []RiskAllele) []GenotypeEffect {
+	result := make([]GenotypeEffect, 0, 2*len(risks))
+	for _, r := range risks {
+		if r.Allele == "" || r.Allele == snp.ReferenceAllele {
+			continue
+		}
+		direction := r.Direction
+		var het, hom *NullableFloat64
+		if or := r.OddsRatio; or != nil && or.Valid && or.Float64 > 0 {
+			het = &NullableFloat64{Float64: or.Float64, Valid: true}
+			hom = &NullableFloat64{Float64: or.Float64 * or.Float64, Valid: true}
+			if direction == "" {
+				direction = OddsRatioDirection(or.Float64)
+			}
+		}
+		effect := func(genotype, name string, direction EffectDirection, magnitude *NullableFloat64) GenotypeEffect {
+			return GenotypeEffect{
+				SNPID:         r.SNPID,
+				Genotype:      genotype,
+				Effect:        name,
+				Direction:     direction,
+				ConditionName: r.ConditionName,
+				Magnitude:     magnitude,
+				Source:        r.Source,
+			}
+		}
+		heterozygous := effect(GenotypeOf(snp.ReferenceAllele, r.Allele), r.Effect, direction, het)
+		if r.IsRecessive() {
+			heterozygous = effect(heterozygous.Genotype, "carrier", EffectNone, nil)
+		}
+		result = append(result, heterozygous, effect(GenotypeOf(r.Allele, r.Allele), r.Effect, direction, hom))
+	}
+	return result
+}
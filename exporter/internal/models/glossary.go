@@ -0,0 +1,41 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// GlossaryTerm fixes the translation of a term into a language: a clinical
+// term such as "pathogenic" with its one approved translation, or a gene
+// symbol kept as it is. Machine translation protects the term and reviews
+// flag translations of text containing it that lack Translation.
+type GlossaryTerm struct {
+	bun.BaseModel `bun:"table:glossary_terms,alias:g"`
+
+	ID           int64  `bun:"id,pk,autoincrement" json:"id"`
+	Term         string `bun:"term,notnull" json:"term"`
+	LanguageCode string `bun:"language_code,notnull" json:"language_code"`
+	Translation  string `bun:"translation,notnull" json:"translation"`
+	// CaseSensitive matches Term and Translation only as written, as gene
+	// symbols must be; other terms match in any case.
+	CaseSensitive bool      `bun:"case_sensitive,notnull" json:"case_sensitive"`
+	Note          *string   `bun:"note" json:"note,omitempty"`
+	UpdatedAt     time.Time `bun:"updated_at,nullzero,notnull,default:current_timestamp" json:"updated_at"`
+}
+
+// Validate checks that the term, its language and its translation are set.
+func (g *GlossaryTerm) Validate() error {
+	if strings.TrimSpace(g.Term) == "" {
+		return errors.New("glossary term is empty")
+	}
+	if g.LanguageCode == "" {
+		return errors.New("glossary term has no language")
+	}
+	if strings.TrimSpace(g.Translation) == "" {
+		return errors.New("glossary term has no translation")
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// HaplotypeKind is what kind of named combination of alleles a haplotype is.
+type HaplotypeKind string
+
+// Haplotype kinds.
+const (
+	// HaplotypeStarAllele is a pharmacogene star allele, such as CYP2C19*2.
+	HaplotypeStarAllele HaplotypeKind = "star_allele"
+	// HaplotypeHLATag is an HLA allele or haplotype typed by tag SNPs, such
+	// as HLA-B*57:01, rather than by sequencing the gene.
+	HaplotypeHLATag HaplotypeKind = "hla_tag"
+	// HaplotypeNamedAllele is any other named allele of a gene, such as
+	// APOE ε4.
+	HaplotypeNamedAllele HaplotypeKind = "named_allele"
+)
+
+// HaplotypeKinds lists every valid kind.
+var HaplotypeKinds = []HaplotypeKind{HaplotypeStarAllele, HaplotypeHLATag, HaplotypeNamedAllele}
+
+// IsValid reports whether k is a known kind.
+func (k HaplotypeKind) IsValid() bool {
+	for _, v := range HaplotypeKinds {
+		if k == v {
+			return true
+		}
+	}
+	return false
+}
+
+// Haplotype is a named combination of alleles of several SNPs inherited
+// together, which means something none of them does alone: APOE ε4 is the C
+// allele of both rs429358 and rs7412, and a star allele is all its defining
+// variants.
+type Haplotype struct {
+	bun.BaseModel `bun:"table:haplotypes,alias:h"`
+
+	ID   int64  `bun:"id,pk,autoincrement" json:"id"`
+	Gene string `bun:"gene,notnull" json:"gene"`
+	// Name is the haplotype's name within the gene, such as "*2" or "ε4";
+	// see FullName.
+	Name string        `bun:"name,notnull" json:"name"`
+	Kind HaplotypeKind `bun:"kind,notnull" json:"kind"`
+	// Function is the haplotype's function or what it is associated with,
+	// such as "no function" for a star allele.
+	Function    *string   `bun:"function" json:"function,omitempty"`
+	Description *string   `bun:"description" json:"description,omitempty"`
+	UpdatedAt   time.Time `bun:"updated_at,nullzero,notnull,default:current_timestamp" json:"updated_at"`
+
+	SNPs []*HaplotypeSNP `bun:"rel:has-many,join:id=haplotype_id" json:"snps,omitempty"`
+}
+
+// HaplotypeSNP is a member SNP of a haplotype and the allele of it the
+// haplotype requires, on the forward strand of GRCh38. Members name their SNP
+// by rsID so definitions stand before the SNPs are loaded.
+type HaplotypeSNP struct {
+	bun.BaseModel `bun:"table:haplotype_snps,alias:hs"`
+
+	ID          int64  `bun:"id,pk,autoincrement" json:"-"`
+	HaplotypeID int64  `bun:"haplotype_id,notnull" json:"-"`
+	RsID        string `bun:"rsid,notnull" json:"rsid"`
+	Allele      string `bun:"allele,notnull" json:"allele"`
+
+	Haplotype *Haplotype `bun:"rel:belongs-to,join:haplotype_id=id" json:"haplotype,omitempty"`
+	SNP       *SNP       `bun:"rel:belongs-to,join:rsid=rsid" json:"-"`
+}
+
+// FullName returns the haplotype's name with its gene, as it is usually
+// written: "CYP2C19*2" for a star allele, "APOE ε4" otherwise.
+func (h *Haplotype) FullName() string {
+	if strings.HasPrefix(h.Name, "*") {
+		return h.Gene + h.Name
+	}
+	return h.Gene + " " + h.Name
+}
+
+// Validate checks that the haplotype is named within a gene, of a known kind,
+// and requires one allele each of at least one SNP.
+func (h *Haplotype) Validate() error {
+	if strings.TrimSpace(h.Gene) == "" || strings.TrimSpace(h.Name) == "" {
+		return errors.New("gene and haplotype name are required")
+	}
+	if !h.Kind.IsValid() {
+		return fmt.Errorf("%s: unknown haplotype kind %q", h.FullName(), h.Kind)
+	}
+	if len(h.SNPs) == 0 {
+		return fmt.Errorf("%s: no member SNPs", h.FullName())
+	}
+	seen := make(map[string]bool, len(h.SNPs))
+	for _, m := range h.SNPs {
+		if !IsRsID(m.RsID) {
+			return fmt.Errorf("%s: %w: %q", h.FullName(), ErrInvalidRsID, m.RsID)
+		}
+		if m.Allele == "" {
+			return fmt.Errorf("%s: no allele of %s", h.FullName(), m.RsID)
+		}
+		if seen[m.RsID] {
+			return fmt.Errorf("%s: %s is a member twice", h.FullName(), m.RsID)
+		}
+		seen[m.RsID] = true
+	}
+	return nil
+}
+
+// Copies returns how many copies of the haplotype a genotype carries, given
+// the called alleles of each SNP by rsID on the forward strand of GRCh38, and
+// false if a member SNP was not called. Unphased genotypes cannot tell which
+// copy each required allele is on, so it is the fewest copies of any member's
+// allele: exact when at most one member is heterozygous, an upper bound
+// otherwise.
+func (h *Haplotype) Copies(genotypes map[string][]string) (int, bool) {
+	copies := -1
+	for _, m := range h.SNPs {
+		alleles, ok := genotypes[m.RsID]
+		if !ok || len(alleles) == 0 {
+			return 0, false
+		}
+		n := 0
+		for _, a := range alleles {
+			if a == m.Allele {
+				n++
+			}
+		}
+		if copies < 0 || n < copies {
+			copies = n
+		}
+	}
+	return max(copies, 0), copies >= 0
+}
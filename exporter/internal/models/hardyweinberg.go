@@ -0,0 +1,66 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// HWEDeviationPValue is the p-value below which a population's genotype
+// counts deviate from Hardy–Weinberg equilibrium. It is as strict as the
+// filters of genome-wide association studies, so that deviations flag
+// genotyping errors rather than the selection and structure of real
+// populations.
+const HWEDeviationPValue = 1e-6
+
+// HardyWeinberg compares the genotypes one source counted for an allele in
+// one population with those Hardy–Weinberg equilibrium expects from the
+// allele's frequency. It is derived from the AlleleCount, AlleleNumber and
+// HomozygoteCount of a PopulationFreq of an autosomal SNP, where all three
+// are reported.
+type HardyWeinberg struct {
+	bun.BaseModel `bun:"table:snp_hardy_weinberg,alias:hw"`
+
+	ID             int64      `bun:"id,pk,autoincrement" json:"id"`
+	SNPID          int64      `bun:"snp_id,notnull" json:"snp_id"`
+	Source         DataSource `bun:"source,notnull" json:"source"`
+	PopulationCode string     `bun:"population_code,notnull" json:"population_code"`
+	Allele         string     `bun:"allele,notnull" json:"allele"`
+	// AlleleFrequency is the allele's frequency among the Samples counted.
+	AlleleFrequency float64 `bun:"allele_frequency,notnull" json:"allele_frequency"`
+	Samples         int     `bun:"samples,notnull" json:"samples"`
+	// The observed genotypes: homozygous for the allele, heterozygous and
+	// carrying none of it.
+	Homozygotes   int `bun:"homozygotes,notnull" json:"homozygotes"`
+	Heterozygotes int `bun:"heterozygotes,notnull" json:"heterozygotes"`
+	NonCarriers   int `bun:"non_carriers,notnull" json:"non_carriers"`
+	// The same genotypes as equilibrium expects them.
+	ExpectedHomozygotes   float64 `bun:"expected_homozygotes,notnull" json:"expected_homozygotes"`
+	ExpectedHeterozygotes float64 `bun:"expected_heterozygotes,notnull" json:"expected_heterozygotes"`
+	ExpectedNonCarriers   float64 `bun:"expected_non_carriers,notnull" json:"expected_non_carriers"`
+	// Inbreeding is the inbreeding coefficient F, one less the observed
+	// over the expected heterozygosity: positive for too few heterozygotes,
+	// negative for too many. It is nil for monomorphic sites.
+	Inbreeding *float64 `bun:"inbreeding" json:"inbreeding,omitempty"`
+	// ChiSquare and PValue are Pearson's goodness-of-fit test of the
+	// observed genotypes against the expected, with one degree of freedom.
+	ChiSquare    float64   `bun:"chi_square,notnull" json:"chi_square"`
+	PValue       float64   `bun:"p_value,notnull" json:"p_value"`
+	CalculatedAt time.Time `bun:"calculated_at,nullzero,notnull,default:current_timestamp" json:"calculated_at"`
+
+	SNP *SNP `bun:"rel:belongs-to,join:snp_id=id" json:"-"`
+}
+
+var _ bun.BeforeAppendModelHook = (*HardyWeinberg)(nil)
+
+// BeforeAppendModel refuses unknown sources.
+func (h *HardyWeinberg) BeforeAppendModel(ctx context.Context, query bun.Query) error {
+	return checkValues(query, h.Source)
+}
+
+// Deviates reports whether the genotypes deviate from equilibrium, their
+// p-value below HWEDeviationPValue.
+func (h *HardyWeinberg) Deviates() bool {
+	return h.PValue < HWEDeviationPValue
+}
@@ -0,0 +1,35 @@
+package models
+
+import (
+	"database/sql/driver"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// touch sets updatedAt to the current time when query updates the row.
+// Models with an updated_at column call it from their BeforeAppendModel
+// hooks, which bun runs on every row written, so updates through bun move
+// the timestamp; inserts leave it to the column default, and upserts set
+// it themselves.
+func touch(query bun.Query, updatedAt *time.Time) {
+	if _, ok := query.(*bun.UpdateQuery); ok {
+		*updatedAt = time.Now()
+	}
+}
+
+// checkValues returns the first error of the enum values' Value methods when
+// query writes the row. Bun would otherwise only report the failure as
+// malformed SQL; models call it from their BeforeAppendModel hooks so writing
+// an unknown value fails with ErrUnknownValue instead.
+func checkValues(query bun.Query, values ...driver.Valuer) error {
+	switch query.(type) {
+	case *bun.InsertQuery, *bun.UpdateQuery:
+		for _, v := range values {
+			if _, err := v.Value(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,210 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// Message is a text the exporter itself writes into scores and reports,
+// such as a significance level or the template of a score explanation,
+// translated like the SNPs' fields. A template marks its arguments {1},
+// {2} and so on, which a translation may reorder.
+type Message struct {
+	// Key identifies the message, such as "level/very_high"; a
+	// translation of the message is stored under it.
+	Key  string
+	Text string
+	// Note tells the translator where the text is shown.
+	Note string
+}
+
+// FormatMessage returns template, the text of a message or a translation of
+// it, with its placeholders replaced by args. Placeholders without an
+// argument are left as they are.
+func FormatMessage(template string, args ...string) string {
+	if len(args) == 0 {
+		return template
+	}
+	pairs := make([]string, 0, 2*len(args))
+	for i, arg := range args {
+		pairs = append(pairs, "{"+strconv.Itoa(i+1)+"}", arg)
+	}
+	return strings.NewReplacer(pairs...).Replace(template)
+}
+
+// Significance levels, from the highest.
+var (
+	LevelVeryHigh = Message{Key: "level/very_high", Text: "Very High", Note: "Significance level of a score of 80 or more"}
+	LevelHigh     = Message{Key: "level/high", Text: "High", Note: "Significance level of a score from 60 to 80"}
+	LevelModerate = Message{Key: "level/moderate", Text: "Moderate", Note: "Significance level of a score from 40 to 60"}
+	LevelLow      = Message{Key: "level/low", Text: "Low", Note: "Significance level of a score from 20 to 40"}
+	LevelMinimal  = Message{Key: "level/minimal", Text: "Minimal", Note: "Significance level of a score below 20"}
+)
+
+// Templates of the reasons explaining a score.
+var (
+	ReasonClinicalAssertion = Message{Key: "reason/clinical_assertion", Text: "{1} {2} assertion",
+		Note: "Score reason: the best ClinVar assertion, {1} its review status and {2} its significance"}
+	ReasonDissentingSubmission = Message{Key: "reason/dissenting_submission", Text: "{1} conflicting submission",
+		Note: "Score reason, taking points off: a single submitter classifies the variant otherwise than the best assertion"}
+	ReasonDissentingSubmissions = Message{Key: "reason/dissenting_submissions", Text: "{1} conflicting submissions",
+		Note: "Score reason, taking points off: {1} submitters classify the variant otherwise than the best assertion"}
+	ReasonFunctionalVariant = Message{Key: "reason/functional_variant", Text: "{1} variant",
+		Note: "Score reason: {1} is the functional class, such as missense"}
+	ReasonPubMedReference = Message{Key: "reason/pubmed_reference", Text: "{1} PubMed reference, {2} recency-weighted",
+		Note: "Score reason: a single PubMed reference, {2} its weight after discounting its age"}
+	ReasonPubMedReferences = Message{Key: "reason/pubmed_references", Text: "{1} PubMed references, {2} recency-weighted",
+		Note: "Score reason: {1} PubMed references, {2} their count after discounting their age"}
+	ReasonCitation         = Message{Key: "reason/citation", Text: "{1} citation", Note: "Score reason: a single citation"}
+	ReasonCitations        = Message{Key: "reason/citations", Text: "{1} citations", Note: "Score reason: {1} citations of the references"}
+	ReasonHighImpact       = Message{Key: "reason/high_impact_study", Text: "{1} high-impact journal study", Note: "Score reason: a single study in a high-impact journal"}
+	ReasonHighImpacts      = Message{Key: "reason/high_impact_studies", Text: "{1} high-impact journal studies", Note: "Score reason: {1} studies in high-impact journals"}
+	ReasonMAF              = Message{Key: "reason/maf", Text: "MAF {1} in {2}", Note: "Score reason: the highest minor allele frequency {1}, in the population {2}"}
+	ReasonRareIn           = Message{Key: "reason/rare_in", Text: "rare in {1}", Note: "Score reason: rare in the only population measured, {1}"}
+	ReasonRareInAll        = Message{Key: "reason/rare_in_all", Text: "rare in all {1} populations", Note: "Score reason: rare in each of the {1} populations measured"}
+	ReasonAncestrySpecific = Message{Key: "reason/ancestry_specific", Text: "common in {1} but {2} in {3}",
+		Note: "Score reason: common in the population {1} but at the frequency {2} in the population {3}"}
+)
+
+// LabelMessage returns the message of the display text of an enum value
+// named in score explanations, such as "expert-panel" for
+// reviewed_by_expert_panel.
+func LabelMessage(value string) Message {
+	text := strings.ReplaceAll(value, "_", " ")
+	switch ReviewStatus(value) {
+	case ReviewExpertPanel:
+		text = "expert-panel"
+	case ReviewMultipleSubmitter:
+		text = "multiple-submitter"
+	case ReviewSingleSubmitter:
+		text = "single-submitter"
+	case ReviewNoAssertion:
+		text = "no-assertion-criteria"
+	}
+	return Message{Key: "label/" + value, Text: text, Note: "Named in score reasons: " + value}
+}
+
+// Messages returns every message, in the order translators are given them.
+func Messages() []Message {
+	messages := []Message{
+		LevelVeryHigh, LevelHigh, LevelModerate, LevelLow, LevelMinimal,
+		ReasonClinicalAssertion, ReasonDissentingSubmission, ReasonDissentingSubmissions, ReasonFunctionalVariant,
+		ReasonPubMedReference, ReasonPubMedReferences, ReasonCitation, ReasonCitations, ReasonHighImpact, ReasonHighImpacts,
+		ReasonMAF, ReasonRareIn, ReasonRareInAll, ReasonAncestrySpecific,
+	}
+	for _, s := range ReviewStatuses {
+		messages = append(messages, LabelMessage(string(s)))
+	}
+	for _, s := range ClinicalSignificances {
+		messages = append(messages, LabelMessage(string(s)))
+	}
+	for _, f := range FunctionalClasses {
+		messages = append(messages, LabelMessage(string(f)))
+	}
+	return messages
+}
+
+// LookupMessage returns the message key identifies.
+func LookupMessage(key string) (Message, bool) {
+	for _, m := range Messages() {
+		if m.Key == key {
+			return m, true
+		}
+	}
+	return Message{}, false
+}
+
+// Explanation is a reason of a score in a form it can be rendered in any
+// language: the key and English text of its template message, with its
+// arguments.
+type Explanation struct {
+	Message string           `json:"message"`
+	Text    string           `json:"text"`
+	Args    []ExplanationArg `json:"args,omitempty"`
+	Points  float64          `json:"points"`
+}
+
+// ExplanationArg is an argument of an explanation: literal text, such as a
+// number or population code, or the text of a message.
+type ExplanationArg struct {
+	Text    string `json:"text"`
+	Message string `json:"message,omitempty"`
+}
+
+// Render returns the explanation as a reason, such as
+// "expert-panel pathogenic assertion (+40)" or "1 conflicting submission
+// (-13.3)", with each message looked up
+// through translate, which returns the text to show for a message's key and
+// English text.
+func (e Explanation) Render(translate func(key, text string) string) string {
+	template := translate(e.Message, e.Text)
+	args := make([]string, len(e.Args))
+	for i, arg := range e.Args {
+		args[i] = arg.Text
+		if arg.Message != "" {
+			args[i] = translate(arg.Message, arg.Text)
+		}
+	}
+	sign := "+"
+	if e.Points < 0 {
+		sign = ""
+	}
+	return FormatMessage(template, args...) + " (" + sign + strconv.FormatFloat(e.Points, 'f', -1, 64) + ")"
+}
+
+// explanationArgs returns args as explanation arguments, messages by key.
+func explanationArgs(args []any) []ExplanationArg {
+	out := make([]ExplanationArg, len(args))
+	for i, arg := range args {
+		if m, ok := arg.(Message); ok {
+			out[i] = ExplanationArg{Text: m.Text, Message: m.Key}
+		} else {
+			out[i] = ExplanationArg{Text: fmt.Sprint(arg)}
+		}
+	}
+	return out
+}
+
+// MessageTranslation represents a translated message.
+type MessageTranslation struct {
+	bun.BaseModel `bun:"table:message_translations,alias:mt"`
+
+	ID             int64             `bun:"id,pk,autoincrement" json:"id"`
+	MessageKey     string            `bun:"message_key,notnull" json:"message_key"`
+	LanguageCode   string            `bun:"language_code,notnull" json:"language_code"`
+	TranslatedText string            `bun:"translated_text,notnull" json:"translated_text"`
+	Translator     *string           `bun:"translator" json:"translator,omitempty"`
+	TranslatedAt   time.Time         `bun:"translated_at,nullzero,notnull,default:current_timestamp" json:"translated_at"`
+	Verified       bool              `bun:"verified,default:false" json:"verified"`
+	Status         TranslationStatus `bun:"status,notnull,default:'machine_draft'" json:"status"`
+	Reviewer       *string           `bun:"reviewer" json:"reviewer,omitempty"`
+	ReviewedAt     *time.Time        `bun:"reviewed_at" json:"reviewed_at,omitempty"`
+	VerifiedAt     *time.Time        `bun:"verified_at" json:"verified_at,omitempty"`
+}
+
+var _ bun.BeforeAppendModelHook = (*MessageTranslation)(nil)
+
+// BeforeAppendModel fills the review status before the translation is
+// written.
+func (t *MessageTranslation) BeforeAppendModel(ctx context.Context, query bun.Query) error {
+	switch query.(type) {
+	case *bun.InsertQuery, *bun.UpdateQuery:
+		t.review().normalize()
+	}
+	return nil
+}
+
+// Advance moves the translation to status next on behalf of reviewer.
+// Moving back to a draft clears the review.
+func (t *MessageTranslation) Advance(next TranslationStatus, reviewer string, at time.Time) error {
+	return t.review().advance(next, reviewer, at)
+}
+
+func (t *MessageTranslation) review() review {
+	return review{&t.Status, &t.Verified, &t.Reviewer, &t.ReviewedAt, &t.VerifiedAt}
+}
@@ -1,6 +1,9 @@
 package models
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"time"
 
 	"github.com/uptrace/bun"
@@ -21,10 +24,42 @@ type Phenotype struct {
 	StudyType          *string          `bun:"study_type" json:"study_type,omitempty"`
 	Source             DataSource       `bun:"source,notnull" json:"source"`
 	CreatedAt          time.Time        `bun:"created_at,nullzero,notnull,default:current_timestamp" json:"created_at"`
+	UpdatedAt          time.Time        `bun:"updated_at,nullzero,notnull,default:current_timestamp" json:"updated_at"`
+	DeletedAt          *time.Time       `bun:"deleted_at,soft_delete" json:"deleted_at,omitempty"`
 
 	SNP *SNP `bun:"rel:belongs-to,join:snp_id=id" json:"-"`
 }
 
+var _ bun.BeforeAppendModelHook = (*Phenotype)(nil)
+
+// BeforeAppendModel updates the timestamp on modifications and refuses
+// unknown enum values.
+func (p *Phenotype) BeforeAppendModel(ctx context.Context, query bun.Query) error {
+	touch(query, &p.UpdatedAt)
+	return checkValues(query, p.Source)
+}
+
+// Validate checks that the association names a phenotype and its type, uses a
+// known source and has a p-value within [0, 1] and a positive odds ratio.
+func (p *Phenotype) Validate() error {
+	if p.PhenotypeName == "" {
+		return errors.New("phenotype name is required")
+	}
+	if p.AssociationType == "" {
+		return errors.New("association type is required")
+	}
+	if !p.Source.IsValid() {
+		return fmt.Errorf("unknown source %q", p.Source)
+	}
+	if p.PValue != nil && p.PValue.Valid && (p.PValue.Float64 < 0 || p.PValue.Float64 > 1) {
+		return fmt.Errorf("p-value %g outside [0, 1]", p.PValue.Float64)
+	}
+	if p.OddsRatio != nil && p.OddsRatio.Valid && p.OddsRatio.Float64 <= 0 {
+		return fmt.Errorf("odds ratio %g must be positive", p.OddsRatio.Float64)
+	}
+	return nil
+}
+
 // IsStatisticallySignificant checks if p-value < 0.05.
 func (p *Phenotype) IsStatisticallySignificant() bool {
 	if p.PValue == nil || !p.PValue.Valid {
@@ -1,6 +1,7 @@
 package models
 
 import (
+	"context"
 	"time"
 
 	"github.com/uptrace/bun"
@@ -10,21 +11,39 @@ import (
 type PopulationFreq struct {
 	bun.BaseModel `bun:"table:snp_populations,alias:pop"`
 
-	ID              int64      `bun:"id,pk,autoincrement" json:"id"`
-	SNPID           int64      `bun:"snp_id,notnull" json:"snp_id"`
-	PopulationCode  string     `bun:"population_code,notnull" json:"population_code"`
-	PopulationName  *string    `bun:"population_name" json:"population_name,omitempty"`
-	Allele          string     `bun:"allele,notnull" json:"allele"`
-	Frequency       float64    `bun:"frequency,notnull" json:"frequency"`
-	AlleleCount     *int       `bun:"allele_count" json:"allele_count,omitempty"`
-	AlleleNumber    *int       `bun:"allele_number" json:"allele_number,omitempty"`
-	HomozygoteCount *int       `bun:"homozygote_count" json:"homozygote_count,omitempty"`
-	Source          DataSource `bun:"source,notnull" json:"source"`
-	CreatedAt       time.Time  `bun:"created_at,nullzero,notnull,default:current_timestamp" json:"created_at"`
+	ID             int64   `bun:"id,pk,autoincrement" json:"id"`
+	SNPID          int64   `bun:"snp_id,notnull" json:"snp_id"`
+	PopulationCode string  `bun:"population_code,notnull" json:"population_code"`
+	PopulationName *string `bun:"population_name" json:"population_name,omitempty"`
+	Allele         string  `bun:"allele,notnull" json:"allele"`
+	Frequency      float64 `bun:"frequency,notnull" json:"frequency"`
+	AlleleCount    *int    `bun:"allele_count" json:"allele_count,omitempty"`
+	AlleleNumber   *int    `bun:"allele_number" json:"allele_number,omitempty"`
+	// HomozygoteCount counts the samples homozygous for Allele, or on the
+	// mitochondrion homoplasmic, carrying it in every copy.
+	HomozygoteCount *int `bun:"homozygote_count" json:"homozygote_count,omitempty"`
+	// HeteroplasmicCount and MaxHeteroplasmy are, for mitochondrial variants,
+	// how many samples carry Allele in only some copies of the mitochondrial
+	// genome and the largest share of copies it was found in among them.
+	HeteroplasmicCount *int             `bun:"heteroplasmic_count" json:"heteroplasmic_count,omitempty"`
+	MaxHeteroplasmy    *NullableFloat64 `bun:"max_heteroplasmy" json:"max_heteroplasmy,omitempty"`
+	Source             DataSource       `bun:"source,notnull" json:"source"`
+	CreatedAt          time.Time        `bun:"created_at,nullzero,notnull,default:current_timestamp" json:"created_at"`
+	UpdatedAt          time.Time        `bun:"updated_at,nullzero,notnull,default:current_timestamp" json:"updated_at"`
+	DeletedAt          *time.Time       `bun:"deleted_at,soft_delete" json:"deleted_at,omitempty"`
 
 	SNP *SNP `bun:"rel:belongs-to,join:snp_id=id" json:"-"`
 }
 
+var _ bun.BeforeAppendModelHook = (*PopulationFreq)(nil)
+
+// BeforeAppendModel updates the timestamp on modifications and refuses
+// unknown enum values.
+func (p *PopulationFreq) BeforeAppendModel(ctx context.Context, query bun.Query) error {
+	touch(query, &p.UpdatedAt)
+	return checkValues(query, p.Source)
+}
+
 // IsCommon returns true if frequency > 5%.
 func (p *PopulationFreq) IsCommon() bool {
 	return p.Frequency > 0.05
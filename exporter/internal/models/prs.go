@@ -0,0 +1,83 @@
+package models
+
+import (
+	"errors"
+	"math"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// PRSModel is a published polygenic risk score definition, such as a PGS
+// Catalog scoring file: a weight per effect allele, summed over a genotype.
+type PRSModel struct {
+	bun.BaseModel `bun:"table:prs_models,alias:pm"`
+
+	ID int64 `bun:"id,pk,autoincrement" json:"id"`
+	// PGSID is the PGS Catalog accession, e.g. PGS000001, or another
+	// identifier unique among imported models.
+	PGSID         string    `bun:"pgs_id,unique,notnull" json:"pgs_id"`
+	Name          *string   `bun:"name" json:"name,omitempty"`
+	TraitReported string    `bun:"trait_reported,notnull" json:"trait_reported"`
+	TraitMapped   *string   `bun:"trait_mapped" json:"trait_mapped,omitempty"`
+	GenomeBuild   *string   `bun:"genome_build" json:"genome_build,omitempty"`
+	WeightType    *string   `bun:"weight_type" json:"weight_type,omitempty"`
+	Citation      *string   `bun:"citation" json:"citation,omitempty"`
+	VariantCount  int       `bun:"variant_count,notnull" json:"variant_count"`
+	CreatedAt     time.Time `bun:"created_at,nullzero,notnull,default:current_timestamp" json:"created_at"`
+
+	Weights       []*PRSWeight       `bun:"rel:has-many,join:id=model_id" json:"weights,omitempty"`
+	Distributions []*PRSDistribution `bun:"rel:has-many,join:id=model_id" json:"distributions,omitempty"`
+}
+
+// PRSWeight is the weight of one effect allele of a model. Variants are
+// matched to genotypes by rsID, so they need not be in the snps table.
+type PRSWeight struct {
+	bun.BaseModel `bun:"table:prs_weights,alias:pw"`
+
+	ID           int64   `bun:"id,pk,autoincrement" json:"id"`
+	ModelID      int64   `bun:"model_id,notnull" json:"model_id"`
+	RsID         string  `bun:"rsid,notnull" json:"rsid"`
+	EffectAllele string  `bun:"effect_allele,notnull" json:"effect_allele"`
+	OtherAllele  *string `bun:"other_allele" json:"other_allele,omitempty"`
+	Weight       float64 `bun:"weight,notnull" json:"weight"`
+}
+
+// PRSDistribution is the distribution of a model's score in a reference
+// population, taken as normal, against which a score is placed.
+type PRSDistribution struct {
+	bun.BaseModel `bun:"table:prs_distributions,alias:pd"`
+
+	ID         int64   `bun:"id,pk,autoincrement" json:"id"`
+	ModelID    int64   `bun:"model_id,notnull" json:"model_id"`
+	Population string  `bun:"population,notnull" json:"population"`
+	Mean       float64 `bun:"mean,notnull" json:"mean"`
+	SD         float64 `bun:"sd,notnull" json:"sd"`
+	// Method says where the distribution comes from, e.g. "published" or
+	// "allele frequencies" for one derived from population_data.
+	Method     string    `bun:"method,notnull" json:"method"`
+	SampleSize *int      `bun:"sample_size" json:"sample_size,omitempty"`
+	CreatedAt  time.Time `bun:"created_at,nullzero,notnull,default:current_timestamp" json:"created_at"`
+}
+
+// Validate checks that the distribution has a spread to place scores in.
+func (d *PRSDistribution) Validate() error {
+	if d.Population == "" {
+		return errors.New("population is required")
+	}
+	if !(d.SD > 0) || math.IsInf(d.SD, 0) || math.IsNaN(d.Mean) || math.IsInf(d.Mean, 0) {
+		return errors.New("a finite mean and positive standard deviation are required")
+	}
+	return nil
+}
+
+// ZScore returns how many standard deviations score lies from the mean.
+func (d *PRSDistribution) ZScore(score float64) float64 {
+	return (score - d.Mean) / d.SD
+}
+
+// Percentile returns the share of the population, 0 to 100, scoring below
+// score.
+func (d *PRSDistribution) Percentile(score float64) float64 {
+	return 50 * (1 + math.Erf(d.ZScore(score)/math.Sqrt2))
+}
@@ -0,0 +1,43 @@
+package models
+
+import (
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// WellCharacterizedQuality is the quality score from which a SNP counts as
+// well characterized.
+const WellCharacterizedQuality = 60.0
+
+// Quality is how completely a SNP is characterized, from 0 to 100: how many
+// of its descriptive fields are known, how many sources contribute to it,
+// how well its best clinical assertion was reviewed and in how many
+// populations its frequency was measured, each up to 25 points. Unlike
+// Significance it says nothing of what the SNP does, only how much is known
+// about it.
+type Quality struct {
+	bun.BaseModel `bun:"table:snp_quality,alias:q"`
+
+	ID             int64   `bun:"id,pk,autoincrement" json:"id"`
+	SNPID          int64   `bun:"snp_id,notnull,unique" json:"snp_id"`
+	Score          float64 `bun:"score,notnull" json:"score"`
+	FieldScore     float64 `bun:"field_score,notnull" json:"field_score"`
+	SourceScore    float64 `bun:"source_score,notnull" json:"source_score"`
+	ReviewScore    float64 `bun:"review_score,notnull" json:"review_score"`
+	FrequencyScore float64 `bun:"frequency_score,notnull" json:"frequency_score"`
+	// MissingFields names the descriptive fields the SNP lacks, such as
+	// gene_symbol.
+	MissingFields   StringArray `bun:"missing_fields,type:json" json:"missing_fields,omitempty"`
+	SourceCount     int         `bun:"source_count,notnull" json:"source_count"`
+	PopulationCount int         `bun:"population_count,notnull" json:"population_count"`
+	CalculatedAt    time.Time   `bun:"calculated_at,nullzero,notnull,default:current_timestamp" json:"calculated_at"`
+
+	SNP *SNP `bun:"rel:belongs-to,join:snp_id=id" json:"-"`
+}
+
+// IsWellCharacterized reports whether the score reaches
+// WellCharacterizedQuality.
+func (q *Quality) IsWellCharacterized() bool {
+	return q.Score >= WellCharacterizedQuality
+}
@@ -0,0 +1,28 @@
+package models
+
+import (
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// QuarantinedRecord is a record that failed validation during ingestion. It is
+// kept with the payload it arrived as and the validation error instead of
+// being written, so bad data neither aborts a run nor reaches the SNP tables.
+//
+// RecordID has no column default: bun leaves a column with a default out of a
+// multi-row insert when the first row's value is zero, losing it for every
+// row.
+type QuarantinedRecord struct {
+	bun.BaseModel `bun:"table:quarantined_records,alias:qr"`
+
+	ID       int64   `bun:"id,pk,autoincrement" json:"id"`
+	Source   string  `bun:"source,notnull" json:"source"`
+	RecordID string  `bun:"record_id,notnull" json:"record_id"`
+	RunID    *string `bun:"run_id" json:"run_id,omitempty"`
+	Error    string  `bun:"error,notnull" json:"error"`
+	// Payload is the source record as JSON, or the mapped bundle if the source
+	// kept no raw record.
+	Payload   string    `bun:"payload,notnull" json:"payload"`
+	CreatedAt time.Time `bun:"created_at,nullzero,notnull,default:current_timestamp" json:"created_at"`
+}
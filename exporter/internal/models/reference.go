@@ -1,6 +1,7 @@
 package models
 
 import (
+	"context"
 	"time"
 
 	"github.com/uptrace/bun"
@@ -10,22 +11,34 @@ import (
 type Reference struct {
 	bun.BaseModel `bun:"table:snp_references,alias:r"`
 
-	ID              int64     `bun:"id,pk,autoincrement" json:"id"`
-	SNPID           int64     `bun:"snp_id,notnull" json:"snp_id"`
-	PubmedID        *string   `bun:"pubmed_id" json:"pubmed_id,omitempty"`
-	Title           *string   `bun:"title" json:"title,omitempty"`
-	Authors         *string   `bun:"authors" json:"authors,omitempty"`
-	Journal         *string   `bun:"journal" json:"journal,omitempty"`
-	PublicationYear *int      `bun:"publication_year" json:"publication_year,omitempty"`
-	DOI             *string   `bun:"doi" json:"doi,omitempty"`
-	URL             *string   `bun:"url" json:"url,omitempty"`
-	CitationCount   int       `bun:"citation_count,default:0" json:"citation_count"`
-	Abstract        *string   `bun:"abstract" json:"abstract,omitempty"`
-	CreatedAt       time.Time `bun:"created_at,nullzero,notnull,default:current_timestamp" json:"created_at"`
+	ID              int64      `bun:"id,pk,autoincrement" json:"id"`
+	SNPID           int64      `bun:"snp_id,notnull" json:"snp_id"`
+	PubmedID        *string    `bun:"pubmed_id" json:"pubmed_id,omitempty"`
+	Title           *string    `bun:"title" json:"title,omitempty"`
+	Authors         *string    `bun:"authors" json:"authors,omitempty"`
+	Journal         *string    `bun:"journal" json:"journal,omitempty"`
+	PublicationYear *int       `bun:"publication_year" json:"publication_year,omitempty"`
+	DOI             *string    `bun:"doi" json:"doi,omitempty"`
+	URL             *string    `bun:"url" json:"url,omitempty"`
+	CitationCount   int        `bun:"citation_count,default:0" json:"citation_count"`
+	Abstract        *string    `bun:"abstract" json:"abstract,omitempty"`
+	Source          DataSource `bun:"source,nullzero" json:"source,omitempty"`
+	CreatedAt       time.Time  `bun:"created_at,nullzero,notnull,default:current_timestamp" json:"created_at"`
+	UpdatedAt       time.Time  `bun:"updated_at,nullzero,notnull,default:current_timestamp" json:"updated_at"`
+	DeletedAt       *time.Time `bun:"deleted_at,soft_delete" json:"deleted_at,omitempty"`
 
 	SNP *SNP `bun:"rel:belongs-to,join:snp_id=id" json:"-"`
 }
 
+var _ bun.BeforeAppendModelHook = (*Reference)(nil)
+
+// BeforeAppendModel updates the timestamp on modifications and refuses
+// unknown enum values.
+func (r *Reference) BeforeAppendModel(ctx context.Context, query bun.Query) error {
+	touch(query, &r.UpdatedAt)
+	return checkValues(query, r.Source)
+}
+
 // GetPubmedURL returns the full PubMed URL.
 func (r *Reference) GetPubmedURL() string {
 	if r.PubmedID == nil {
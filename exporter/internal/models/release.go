@@ -0,0 +1,39 @@
+package models
+
+import (
+	"errors"
+	"regexp"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+var semverPattern = regexp.MustCompile(`^\d+\.\d+\.\d+(-[0-9A-Za-z.-]+)?(\+[0-9A-Za-z.-]+)?$`)
+
+// DatasetRelease records a published build of the database. A different
+// ScoreAlgorithmVersion between two releases means scores were recalculated
+// under a new formula.
+type DatasetRelease struct {
+	bun.BaseModel `bun:"table:dataset_releases,alias:dr"`
+
+	ID                    int64     `bun:"id,pk,autoincrement" json:"id"`
+	Version               string    `bun:"version,unique,notnull" json:"version"`
+	BuildDate             time.Time `bun:"build_date,notnull" json:"build_date"`
+	SourceVersions        StringMap `bun:"source_versions,type:json,notnull" json:"source_versions"`
+	RecordCounts          CountMap  `bun:"record_counts,type:json,notnull" json:"record_counts"`
+	Checksum              string    `bun:"checksum,notnull" json:"checksum"`
+	ScoreAlgorithmVersion int       `bun:"score_algorithm_version,notnull,default:0" json:"score_algorithm_version"`
+	Notes                 *string   `bun:"notes" json:"notes,omitempty"`
+	CreatedAt             time.Time `bun:"created_at,nullzero,notnull,default:current_timestamp" json:"created_at"`
+}
+
+// Validate checks that the release carries a semantic version and checksum.
+func (r *DatasetRelease) Validate() error {
+	if !semverPattern.MatchString(r.Version) {
+		return errors.New("version must be a semantic version such as 1.2.0")
+	}
+	if r.Checksum == "" {
+		return errors.New("checksum is required")
+	}
+	return nil
+}
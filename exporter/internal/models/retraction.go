@@ -0,0 +1,41 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// Retraction records a curator withdrawing a SNP, or what one source says
+// about it, such as a ClinVar record withdrawn by its submitter. The rows
+// withdrawn are soft deleted rather than removed, so the retraction can be
+// undone and the history of what was published is kept.
+type Retraction struct {
+	bun.BaseModel `bun:"table:retractions,alias:rt"`
+
+	ID    int64  `bun:"id,pk,autoincrement" json:"id"`
+	SNPID int64  `bun:"snp_id,notnull" json:"snp_id"`
+	RsID  string `bun:"rsid,notnull" json:"rsid"`
+	// Source is the source whose annotations of the SNP were retracted,
+	// empty when the SNP itself was.
+	Source      DataSource `bun:"source,nullzero" json:"source,omitempty"`
+	Reason      string     `bun:"reason,notnull" json:"reason"`
+	Curator     *string    `bun:"curator" json:"curator,omitempty"`
+	RetractedAt time.Time  `bun:"retracted_at,nullzero,notnull,default:current_timestamp" json:"retracted_at"`
+	// RestoredAt is when the retraction was undone, nil while it stands.
+	RestoredAt *time.Time `bun:"restored_at" json:"restored_at,omitempty"`
+}
+
+// Validate checks that the retraction gives a reason and names a known
+// source, if any.
+func (r *Retraction) Validate() error {
+	if r.Reason == "" {
+		return errors.New("reason is required")
+	}
+	if r.Source != "" && !r.Source.IsValid() {
+		return fmt.Errorf("unknown source %q", r.Source)
+	}
+	return nil
+}
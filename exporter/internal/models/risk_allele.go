@@ -0,0 +1,74 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// RiskAllele is an allele of a SNP with an effect of its own, so a genotype
+// can be interpreted by how many copies of it it carries rather than by the
+// SNP's significance alone. ClinVar contributes the alternate allele of each
+// assertion that is not benign or uncertain; sources reporting GWAS
+// associations contribute their effect alleles, with the odds ratio.
+type RiskAllele struct {
+	bun.BaseModel `bun:"table:risk_alleles,alias:ra"`
+
+	ID     int64  `bun:"id,pk,autoincrement" json:"id"`
+	SNPID  int64  `bun:"snp_id,notnull" json:"snp_id"`
+	Allele string `bun:"allele,notnull" json:"allele"`
+	// Effect is what carrying the allele means, e.g. pathogenic or
+	// risk_factor for ClinVar, or the association type of a GWAS hit.
+	Effect string `bun:"effect,notnull" json:"effect"`
+	// Direction is which way the allele moves the risk of the condition,
+	// empty if the source does not say.
+	Direction     EffectDirection `bun:"direction,nullzero" json:"direction,omitempty"`
+	ConditionName string          `bun:"condition_name,notnull" json:"condition_name"`
+	// Inheritance is the mode of inheritance of the condition, when known,
+	// which decides whether one copy is enough to be affected.
+	Inheritance *string          `bun:"inheritance" json:"inheritance,omitempty"`
+	OddsRatio   *NullableFloat64 `bun:"odds_ratio" json:"odds_ratio,omitempty"`
+	Source      DataSource       `bun:"source,notnull" json:"source"`
+	CreatedAt   time.Time        `bun:"created_at,nullzero,notnull,default:current_timestamp" json:"created_at"`
+	UpdatedAt   time.Time        `bun:"updated_at,nullzero,notnull,default:current_timestamp" json:"updated_at"`
+	DeletedAt   *time.Time       `bun:"deleted_at,soft_delete" json:"deleted_at,omitempty"`
+
+	SNP *SNP `bun:"rel:belongs-to,join:snp_id=id" json:"-"`
+}
+
+var _ bun.BeforeAppendModelHook = (*RiskAllele)(nil)
+
+// BeforeAppendModel updates the timestamp on modifications and refuses
+// unknown enum values.
+func (r *RiskAllele) BeforeAppendModel(ctx context.Context, query bun.Query) error {
+	touch(query, &r.UpdatedAt)
+	return checkValues(query, r.Source)
+}
+
+// Validate checks that the allele is named, has an effect on a condition
+// in a known direction, if any, and uses a known source.
+func (r *RiskAllele) Validate() error {
+	if r.Allele == "" {
+		return errors.New("allele is required")
+	}
+	if r.Effect == "" || r.ConditionName == "" {
+		return errors.New("effect and condition name are required")
+	}
+	if r.Direction != "" && !r.Direction.IsValid() {
+		return fmt.Errorf("unknown effect direction %q", r.Direction)
+	}
+	if !r.Source.IsValid() {
+		return fmt.Errorf("unknown source %q", r.Source)
+	}
+	return nil
+}
+
+// IsRecessive reports whether the condition is known to need two copies,
+// making one copy a carrier state.
+func (r *RiskAllele) IsRecessive() bool {
+	return r.Inheritance != nil && strings.Contains(strings.ToLower(*r.Inheritance), "recessive")
+}
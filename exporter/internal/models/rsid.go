@@ -0,0 +1,81 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalidRsID is returned for text that is not a dbSNP reference SNP ID.
+var ErrInvalidRsID = errors.New("invalid rsID")
+
+// NormalizeRsID returns the canonical form of a dbSNP reference SNP ID, as
+// SNPs are stored: "rs" and the number without leading zeros. "RS429358",
+// " rs0429358" and the bare number "429358" all give "rs429358", so the same
+// variant written differently by two sources or users cannot land on two
+// rows. Anything else is an ErrInvalidRsID.
+func NormalizeRsID(s string) (string, error) {
+	n, ok := parseRsID(s)
+	if !ok {
+		return "", fmt.Errorf("%w: %q", ErrInvalidRsID, s)
+	}
+	return "rs" + strconv.FormatInt(n, 10), nil
+}
+
+// IsRsID reports whether s is an rsID in canonical form, such as rs429358.
+func IsRsID(s string) bool {
+	n, ok := parseRsID(s)
+	return ok && s == "rs"+strconv.FormatInt(n, 10)
+}
+
+// RsIDNumber returns the number of an rsID in any form NormalizeRsID takes,
+// such as 429358 for rs429358, and false if s is not one.
+func RsIDNumber(s string) (int64, bool) {
+	return parseRsID(s)
+}
+
+func parseRsID(s string) (int64, bool) {
+	s = strings.TrimSpace(s)
+	if len(s) >= 2 && strings.EqualFold(s[:2], "rs") {
+		s = s[2:]
+	}
+	if s == "" || strings.Trim(s, "0123456789") != "" {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	return n, err == nil && n > 0
+}
+
+// RsIDResolver maps the rsIDs among rsIDs that dbSNP merged into others to
+// the rsIDs they were merged into, leaving the rest out.
+// repositories.RsIDResolver binds one to a database.
+type RsIDResolver func(ctx context.Context, rsIDs []string) (map[string]string, error)
+
+// CanonicalRsIDs normalizes rsIDs and, when resolve is not nil, follows
+// merges: it returns the rsID each of rsIDs is stored under, in order. The
+// first one that is not an rsID fails them all.
+func CanonicalRsIDs(ctx context.Context, rsIDs []string, resolve RsIDResolver) ([]string, error) {
+	canonical := make([]string, len(rsIDs))
+	for i, s := range rsIDs {
+		rsID, err := NormalizeRsID(s)
+		if err != nil {
+			return nil, err
+		}
+		canonical[i] = rsID
+	}
+	if resolve == nil || len(canonical) == 0 {
+		return canonical, nil
+	}
+	merged, err := resolve(ctx, canonical)
+	if err != nil {
+		return nil, fmt.Errorf("resolve merged rsIDs: %w", err)
+	}
+	for i, rsID := range canonical {
+		if current, ok := merged[rsID]; ok {
+			canonical[i] = current
+		}
+	}
+	return canonical, nil
+}
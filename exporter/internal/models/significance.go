@@ -2,26 +2,33 @@ package models
 
 import (
 	"database/sql/driver"
-	"encoding/json"
-	"errors"
 	"time"
 
 	"github.com/uptrace/bun"
 )
 
 // Significance represents the calculated significance score for a SNP.
+// AlgorithmVersion identifies the scoring formula that produced it.
 type Significance struct {
 	bun.BaseModel `bun:"table:snp_significance,alias:sig"`
 
-	ID              int64          `bun:"id,pk,autoincrement" json:"id"`
-	SNPID           int64          `bun:"snp_id,notnull,unique" json:"snp_id"`
-	TotalScore      float64        `bun:"total_score,notnull" json:"total_score"`
-	ClinicalScore   float64        `bun:"clinical_score,notnull" json:"clinical_score"`
-	ResearchScore   float64        `bun:"research_score,notnull" json:"research_score"`
-	PopulationScore float64        `bun:"population_score,notnull" json:"population_score"`
-	FunctionalScore float64        `bun:"functional_score,notnull" json:"functional_score"`
-	ScoreDetails    ScoreBreakdown `bun:"score_details,type:json" json:"score_details"`
-	CalculatedAt    time.Time      `bun:"calculated_at,nullzero,notnull,default:current_timestamp" json:"calculated_at"`
+	ID               int64          `bun:"id,pk,autoincrement" json:"id"`
+	SNPID            int64          `bun:"snp_id,notnull,unique" json:"snp_id"`
+	TotalScore       float64        `bun:"total_score,notnull" json:"total_score"`
+	ClinicalScore    float64        `bun:"clinical_score,notnull" json:"clinical_score"`
+	ResearchScore    float64        `bun:"research_score,notnull" json:"research_score"`
+	PopulationScore  float64        `bun:"population_score,notnull" json:"population_score"`
+	FunctionalScore  float64        `bun:"functional_score,notnull" json:"functional_score"`
+	ScoreDetails     ScoreBreakdown `bun:"score_details,type:json" json:"score_details"`
+	AlgorithmVersion int            `bun:"algorithm_version,notnull,default:0" json:"algorithm_version"`
+	ScoreRank        *int           `bun:"score_rank" json:"score_rank,omitempty"`
+	Percentile       *float64       `bun:"percentile" json:"percentile,omitempty"`
+	NormalizedScore  *float64       `bun:"normalized_score" json:"normalized_score,omitempty"`
+	Stale            bool           `bun:"stale,notnull,default:false" json:"stale"`
+	CalculatedAt     time.Time      `bun:"calculated_at,nullzero,notnull,default:current_timestamp" json:"calculated_at"`
+	// Level is SignificanceLevel in the reader's language, set on the SNPs
+	// of a localizing repository.
+	Level string `bun:"-" json:"-"`
 
 	SNP *SNP `bun:"rel:belongs-to,join:snp_id=id" json:"-"`
 }
@@ -32,38 +39,69 @@ type ScoreBreakdown struct {
 	ResearchDetails   ResearchScoring   `json:"research"`
 	PopulationDetails PopulationScoring `json:"population"`
 	FunctionalDetails FunctionalScoring `json:"functional"`
+	// Reasons explain each contribution to the total, e.g.
+	// "expert-panel pathogenic assertion (+40)".
+	Reasons []string `json:"reasons,omitempty"`
+	// Explanations are Reasons as templates and arguments, for rendering
+	// in other languages. Scores calculated before they were recorded have
+	// none.
+	Explanations []Explanation `json:"explanations,omitempty"`
 }
 
-func (s ScoreBreakdown) Value() (driver.Value, error) {
-	return json.Marshal(s)
+// AddReason records that the evidence, m formatted with args, contributed
+// points to the score. Arguments that are messages are rendered in the
+// reader's language too. Evidence worth nothing is not recorded.
+func (s *ScoreBreakdown) AddReason(points float64, m Message, args ...any) {
+	if points == 0 {
+		return
+	}
+	e := Explanation{Message: m.Key, Text: m.Text, Args: explanationArgs(args), Points: points}
+	s.Explanations = append(s.Explanations, e)
+	s.Reasons = append(s.Reasons, e.Render(func(_, text string) string { return text }))
 }
 
-func (s *ScoreBreakdown) Scan(value interface{}) error {
-	if value == nil {
-		return nil
+// LocalizedReasons returns Reasons with each explanation's messages looked
+// up through translate, as Explanation.Render does; Reasons as they are if
+// the score has no explanations.
+func (s ScoreBreakdown) LocalizedReasons(translate func(key, text string) string) []string {
+	if len(s.Explanations) == 0 {
+		return s.Reasons
 	}
-	bytes, ok := value.([]byte)
-	if !ok {
-		return errors.New("failed to scan ScoreBreakdown")
+	reasons := make([]string, len(s.Explanations))
+	for i, e := range s.Explanations {
+		reasons[i] = e.Render(translate)
 	}
-	return json.Unmarshal(bytes, s)
+	return reasons
+}
+
+func (s ScoreBreakdown) Value() (driver.Value, error) {
+	return JSONColumn[ScoreBreakdown]{Data: s}.Value()
+}
+
+func (s *ScoreBreakdown) Scan(value interface{}) error {
+	return scanJSON(value, s, ScoreBreakdown{})
 }
 
 type ClinicalScoring struct {
 	HasPathogenic     bool    `json:"has_pathogenic"`
 	ReviewStatusScore float64 `json:"review_status_score"`
 	ConditionCount    int     `json:"condition_count"`
+	// Conflicting is set when submitters disagree on the best assertion.
+	Conflicting bool `json:"conflicting,omitempty"`
 }
 
 type ResearchScoring struct {
-	PubmedCount       int `json:"pubmed_count"`
-	CitationTotal     int `json:"citation_total"`
-	HighImpactStudies int `json:"high_impact_studies"`
+	PubmedCount       int     `json:"pubmed_count"`
+	WeightedStudies   float64 `json:"weighted_studies"`
+	CitationTotal     int     `json:"citation_total"`
+	HighImpactStudies int     `json:"high_impact_studies"`
 }
 
 type PopulationScoring struct {
-	MaxMAF          float64 `json:"max_maf"`
-	PopulationCount int     `json:"population_count"`
+	MaxMAF           float64 `json:"max_maf"`
+	MinMAF           float64 `json:"min_maf"`
+	PopulationCount  int     `json:"population_count"`
+	AncestrySpecific bool    `json:"ancestry_specific"`
 }
 
 type FunctionalScoring struct {
@@ -71,28 +109,46 @@ type FunctionalScoring struct {
 	IsRegulatory      bool `json:"is_regulatory"`
 }
 
+// LevelScore returns the score compared against the significance thresholds:
+// NormalizedScore once normalization has run, TotalScore before that.
+func (s *Significance) LevelScore() float64 {
+	if s.NormalizedScore != nil {
+		return *s.NormalizedScore
+	}
+	return s.TotalScore
+}
+
 // IsHighlySignificant returns true if score >= 70.
 func (s *Significance) IsHighlySignificant() bool {
-	return s.TotalScore >= 70.0
+	return s.LevelScore() >= 70.0
 }
 
 // IsModeratelySignificant returns true if score >= 40.
 func (s *Significance) IsModeratelySignificant() bool {
-	return s.TotalScore >= 40.0
+	return s.LevelScore() >= 40.0
 }
 
-// SignificanceLevel returns a human-readable level.
+// SignificanceLevel returns a human-readable level: Level if it is set, or
+// else the English text of LevelMessage.
 func (s *Significance) SignificanceLevel() string {
-	switch {
-	case s.TotalScore >= 80:
-		return "Very High"
-	case s.TotalScore >= 60:
-		return "High"
-	case s.TotalScore >= 40:
-		return "Moderate"
-	case s.TotalScore >= 20:
-		return "Low"
+	if s.Level != "" {
+		return s.Level
+	}
+	return s.LevelMessage().Text
+}
+
+// LevelMessage returns the message of the significance level of the score.
+func (s *Significance) LevelMessage() Message {
+	switch score := s.LevelScore(); {
+	case score >= 80:
+		return LevelVeryHigh
+	case score >= 60:
+		return LevelHigh
+	case score >= 40:
+		return LevelModerate
+	case score >= 20:
+		return LevelLow
 	default:
-		return "Minimal"
+		return LevelMinimal
 	}
 }
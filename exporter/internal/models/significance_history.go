@@ -0,0 +1,28 @@
+package models
+
+import (
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// SignificanceHistory is a score retained when the SNP was rescored under a
+// different scoring algorithm version. Rows are written by a database trigger,
+// never by application code.
+type SignificanceHistory struct {
+	bun.BaseModel `bun:"table:snp_significance_history,alias:sh"`
+
+	ID               int64          `bun:"id,pk,autoincrement" json:"id"`
+	SNPID            int64          `bun:"snp_id,notnull" json:"snp_id"`
+	AlgorithmVersion int            `bun:"algorithm_version,notnull" json:"algorithm_version"`
+	TotalScore       float64        `bun:"total_score,notnull" json:"total_score"`
+	ClinicalScore    float64        `bun:"clinical_score,notnull" json:"clinical_score"`
+	ResearchScore    float64        `bun:"research_score,notnull" json:"research_score"`
+	PopulationScore  float64        `bun:"population_score,notnull" json:"population_score"`
+	FunctionalScore  float64        `bun:"functional_score,notnull" json:"functional_score"`
+	ScoreDetails     ScoreBreakdown `bun:"score_details,type:json" json:"score_details"`
+	CalculatedAt     time.Time      `bun:"calculated_at,notnull" json:"calculated_at"`
+	ArchivedAt       time.Time      `bun:"archived_at,nullzero,notnull,default:current_timestamp" json:"archived_at"`
+
+	SNP *SNP `bun:"rel:belongs-to,join:snp_id=id" json:"-"`
+}
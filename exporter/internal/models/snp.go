@@ -2,10 +2,15 @@ package models
 
 import (
 	"context"
+	"database/sql/driver"
 	"errors"
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/uptrace/bun"
+
+	"github.com/mkoziy/genome/exporter/internal/variant"
 )
 
 // SNP represents a Single Nucleotide Polymorphism.
@@ -22,27 +27,166 @@ type SNP struct {
 	GeneID           *string          `bun:"gene_id" json:"gene_id,omitempty"`
 	VariantType      VariantType      `bun:"variant_type,notnull" json:"variant_type"`
 	FunctionalClass  *FunctionalClass `bun:"functional_class" json:"functional_class,omitempty"`
+	VariantKey       *string          `bun:"variant_key" json:"variant_key,omitempty"`
 	CreatedAt        time.Time        `bun:"created_at,nullzero,notnull,default:current_timestamp" json:"created_at"`
 	UpdatedAt        time.Time        `bun:"updated_at,nullzero,notnull,default:current_timestamp" json:"updated_at"`
+	// DeletedAt is when the SNP was retracted, nil unless it was. Queries
+	// through bun leave retracted SNPs out unless they ask for them with
+	// WhereDeleted or WhereAllWithDeleted; see Retraction.
+	DeletedAt *time.Time `bun:"deleted_at,soft_delete" json:"deleted_at,omitempty"`
+	// HGVS descriptions of the variant, such as "NM_000546.6:c.215C>G":
+	// genomic on GRCh38, derived from the coordinates, and coding and
+	// protein on the transcript a source prefers.
+	HGVSGenomic *string `bun:"hgvs_genomic" json:"hgvs_genomic,omitempty"`
+	HGVSCoding  *string `bun:"hgvs_coding" json:"hgvs_coding,omitempty"`
+	HGVSProtein *string `bun:"hgvs_protein" json:"hgvs_protein,omitempty"`
+	// GRCh37Chromosome and GRCh37Position are the variant's coordinates on
+	// GRCh37, as a source gives them or lifted over from GRCh38; nil where
+	// neither is known.
+	GRCh37Chromosome *string `bun:"grch37_chromosome" json:"grch37_chromosome,omitempty"`
+	GRCh37Position   *int64  `bun:"grch37_position" json:"grch37_position,omitempty"`
+	// EndPosition is the last position on GRCh38 the variant spans, where it
+	// spans more than its reference allele shows, as structural variants do.
+	EndPosition *int64 `bun:"end_position" json:"end_position,omitempty"`
+	// SVType and SVLength describe a structural variant: its kind and how
+	// many bases it deletes, duplicates, inserts or inverts. Both are nil for
+	// small variants.
+	SVType   *StructuralVariantType `bun:"sv_type" json:"sv_type,omitempty"`
+	SVLength *int64                 `bun:"sv_length" json:"sv_length,omitempty"`
+	// Summary is the plain-language summary in the language the SNP was read
+	// in; only localized reads fill it.
+	Summary string `bun:"-" json:"summary,omitempty"`
 
-	Significance   *Significance     `bun:"rel:has-one,join:id=snp_id" json:"significance,omitempty"`
-	ClinicalData   []*ClinicalData   `bun:"rel:has-many,join:id=snp_id" json:"clinical_data,omitempty"`
-	Phenotypes     []*Phenotype      `bun:"rel:has-many,join:id=snp_id" json:"phenotypes,omitempty"`
-	References     []*Reference      `bun:"rel:has-many,join:id=snp_id" json:"references,omitempty"`
-	PopulationData []*PopulationFreq `bun:"rel:has-many,join:id=snp_id" json:"population_data,omitempty"`
+	Significance *Significance `bun:"rel:has-one,join:id=snp_id" json:"significance,omitempty"`
+	Quality      *Quality      `bun:"rel:has-one,join:id=snp_id" json:"quality,omitempty"`
+	Frequency    *Frequency    `bun:"rel:has-one,join:id=snp_id" json:"frequency,omitempty"`
+	// HardyWeinberg are the tests of the genotypes counted in each
+	// population against Hardy–Weinberg equilibrium.
+	HardyWeinberg   []*HardyWeinberg  `bun:"rel:has-many,join:id=snp_id" json:"hardy_weinberg,omitempty"`
+	ClinicalData    []*ClinicalData   `bun:"rel:has-many,join:id=snp_id" json:"clinical_data,omitempty"`
+	Phenotypes      []*Phenotype      `bun:"rel:has-many,join:id=snp_id" json:"phenotypes,omitempty"`
+	References      []*Reference      `bun:"rel:has-many,join:id=snp_id" json:"references,omitempty"`
+	PopulationData  []*PopulationFreq `bun:"rel:has-many,join:id=snp_id" json:"population_data,omitempty"`
+	RiskAlleles     []*RiskAllele     `bun:"rel:has-many,join:id=snp_id" json:"risk_alleles,omitempty"`
+	GenotypeEffects []*GenotypeEffect `bun:"rel:has-many,join:id=snp_id" json:"genotype_effects,omitempty"`
+	// ClinicalAgreements say how far the submitters behind ClinicalData
+	// agree, one per source and condition.
+	ClinicalAgreements []*ClinicalAgreement `bun:"rel:has-many,join:id=snp_id" json:"clinical_agreements,omitempty"`
+	// Haplotypes are the SNP's memberships of haplotypes, which share its
+	// rsID rather than its ID.
+	Haplotypes []*HaplotypeSNP `bun:"rel:has-many,join:rsid=rsid" json:"haplotypes,omitempty"`
 }
 
-// BeforeUpdate updates the timestamp on modifications.
-func (s *SNP) BeforeUpdate(ctx context.Context, query *bun.UpdateQuery) error {
-	s.UpdatedAt = time.Now()
+var _ bun.BeforeAppendModelHook = (*SNP)(nil)
+
+// BeforeAppendModel normalizes the chromosomes, so that "chrM", "M" and
+// "MT" are stored alike, and derives VariantKey and HGVSGenomic from the
+// coordinates before the SNP is inserted or updated, updating the timestamp
+// on modifications and refusing unknown enum values.
+func (s *SNP) BeforeAppendModel(ctx context.Context, query bun.Query) error {
+	touch(query, &s.UpdatedAt)
+	values := []driver.Valuer{s.VariantType}
+	if s.FunctionalClass != nil {
+		values = append(values, *s.FunctionalClass)
+	}
+	if err := checkValues(query, values...); err != nil {
+		return err
+	}
+	switch query.(type) {
+	case *bun.InsertQuery, *bun.UpdateQuery:
+		s.Chromosome = variant.NormalizeChromosome(s.Chromosome)
+		if s.GRCh37Chromosome != nil {
+			chrom := variant.NormalizeChromosome(*s.GRCh37Chromosome)
+			s.GRCh37Chromosome = &chrom
+		}
+		s.VariantKey = s.CanonicalKey()
+		s.HGVSGenomic = s.GenomicHGVS()
+	}
 	return nil
 }
 
-// Validate checks that required SNP fields are present.
+// CanonicalKey returns the normalized chrom:pos:ref:alt key of the SNP's
+// coordinates, shared by every rsID a source may file the variant under, or
+// nil if they are incomplete. Structural variants with symbolic alleles have
+// none: their extent, not their alleles, tells them apart.
+func (s *SNP) CanonicalKey() *string {
+	if s.IsSymbolic() {
+		return nil
+	}
+	key, ok := variant.Key(s.Chromosome, s.Position, s.ReferenceAllele, s.AlternateAlleles)
+	if !ok {
+		return nil
+	}
+	return &key
+}
+
+// GenomicHGVS returns the HGVS description of the SNP's coordinates on
+// GRCh38, such as "NC_000019.10:g.44908684T>C", or nil if it has several
+// alternate alleles or they cannot be described. Structural variants with
+// symbolic alleles are described by their span.
+func (s *SNP) GenomicHGVS() *string {
+	if s.IsSymbolic() {
+		return s.structuralHGVS()
+	}
+	if len(s.AlternateAlleles) != 1 {
+		return nil
+	}
+	hgvs, ok := variant.GenomicHGVS(s.Chromosome, s.Position, s.ReferenceAllele, s.AlternateAlleles[0])
+	if !ok {
+		return nil
+	}
+	return &hgvs
+}
+
+// structuralHGVS describes deletions, duplications and inversions by their
+// span; copy-number changes and insertions of unknown sequence have no
+// description of the kind.
+func (s *SNP) structuralHGVS() *string {
+	if s.SVType == nil || s.EndPosition == nil {
+		return nil
+	}
+	change := map[StructuralVariantType]string{SVDeletion: "del", SVDuplication: "dup", SVInversion: "inv"}[*s.SVType]
+	hgvs, ok := variant.StructuralHGVS(s.Chromosome, s.Position, *s.EndPosition, change)
+	if !ok {
+		return nil
+	}
+	return &hgvs
+}
+
+// IsStructural reports whether the SNP is a structural variant.
+func (s *SNP) IsStructural() bool {
+	return s.SVType != nil
+}
+
+// IsSymbolic reports whether the SNP is a structural variant whose alternate
+// alleles are symbolic, such as "<DUP>", rather than sequence.
+func (s *SNP) IsSymbolic() bool {
+	for _, alt := range s.AlternateAlleles {
+		if strings.HasPrefix(alt, "<") && strings.HasSuffix(alt, ">") {
+			return true
+		}
+	}
+	return false
+}
+
+// End returns the last position the variant spans on GRCh38: EndPosition if
+// known, otherwise the last base of its reference allele.
+func (s *SNP) End() int64 {
+	if s.EndPosition != nil {
+		return *s.EndPosition
+	}
+	return s.Position + max(int64(len(s.ReferenceAllele)), 1) - 1
+}
+
+// Validate checks that required SNP fields are present and the rsID is in
+// canonical form.
 func (s *SNP) Validate() error {
 	if s.RsID == "" {
 		return errors.New("rsID is required")
 	}
+	if !IsRsID(s.RsID) {
+		return fmt.Errorf("rsID %q is not in canonical form (see NormalizeRsID)", s.RsID)
+	}
 	if s.Chromosome == "" {
 		return errors.New("chromosome is required")
 	}
@@ -55,6 +199,26 @@ func (s *SNP) Validate() error {
 	if len(s.AlternateAlleles) == 0 {
 		return errors.New("at least one alternate allele is required")
 	}
+	if !s.VariantType.IsValid() {
+		return fmt.Errorf("unknown variant type %q", s.VariantType)
+	}
+	if s.FunctionalClass != nil && !s.FunctionalClass.IsValid() {
+		return fmt.Errorf("unknown functional class %q", *s.FunctionalClass)
+	}
+	if s.EndPosition != nil && *s.EndPosition < s.Position {
+		return fmt.Errorf("end position %d before position %d", *s.EndPosition, s.Position)
+	}
+	if s.SVType != nil {
+		if !s.SVType.IsValid() {
+			return fmt.Errorf("unknown structural variant type %q", *s.SVType)
+		}
+		if s.EndPosition == nil {
+			return errors.New("structural variants require an end position")
+		}
+	}
+	if s.SVLength != nil && *s.SVLength <= 0 {
+		return fmt.Errorf("structural variant length %d must be positive", *s.SVLength)
+	}
 	return nil
 }
 
@@ -76,3 +240,61 @@ func (s *SNP) IsProteinCoding() bool {
 	}
 	return false
 }
+
+// SNPData bundles a SNP with the related rows fetched for it by a source.
+// Child rows have no SNPID yet; it is assigned when the bundle is written.
+// Raw is the source record the bundle was mapped from, kept so a bundle that
+// fails validation can be quarantined as received. Genotype effects are
+// usually derived from the risk alleles with DeriveGenotypeEffects, unless
+// the source reports its own.
+type SNPData struct {
+	SNP             *SNP
+	Clinical        []ClinicalData
+	References      []Reference
+	Phenotypes      []Phenotype
+	PopulationData  []PopulationFreq
+	RiskAlleles     []RiskAllele
+	GenotypeEffects []GenotypeEffect
+	Agreements      []ClinicalAgreement
+
+	Source DataSource
+	Raw    any
+}
+
+// Validate checks the SNP and every clinical, phenotype, risk allele,
+// genotype effect and agreement row, reporting all problems found.
+func (d *SNPData) Validate() error {
+	var errs []error
+	if d.SNP == nil {
+		return errors.New("snp is missing")
+	}
+	if err := d.SNP.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+	for i := range d.Clinical {
+		if err := d.Clinical[i].Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("clinical %d: %w", i, err))
+		}
+	}
+	for i := range d.Phenotypes {
+		if err := d.Phenotypes[i].Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("phenotype %d: %w", i, err))
+		}
+	}
+	for i := range d.RiskAlleles {
+		if err := d.RiskAlleles[i].Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("risk allele %d: %w", i, err))
+		}
+	}
+	for i := range d.GenotypeEffects {
+		if err := d.GenotypeEffects[i].Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("genotype effect %d: %w", i, err))
+		}
+	}
+	for i := range d.Agreements {
+		if err := d.Agreements[i].Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("agreement %d: %w", i, err))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,84 @@
+package models
+
+import (
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// ChromosomeCount is a precomputed per-chromosome SNP count.
+type ChromosomeCount struct {
+	bun.BaseModel `bun:"table:summary_chromosomes,alias:sch"`
+
+	Chromosome      string    `bun:"chromosome,pk" json:"chromosome"`
+	SNPCount        int       `bun:"snp_count,notnull" json:"snp_count"`
+	PathogenicCount int       `bun:"pathogenic_count,notnull" json:"pathogenic_count"`
+	RefreshedAt     time.Time `bun:"refreshed_at,nullzero,notnull,default:current_timestamp" json:"refreshed_at"`
+}
+
+// ClinicalSignificanceCount is a precomputed count of clinical annotations per significance.
+type ClinicalSignificanceCount struct {
+	bun.BaseModel `bun:"table:summary_clinical_significance,alias:scs"`
+
+	ClinicalSignificance ClinicalSignificance `bun:"clinical_significance,pk" json:"clinical_significance"`
+	RecordCount          int                  `bun:"record_count,notnull" json:"record_count"`
+	SNPCount             int                  `bun:"snp_count,notnull" json:"snp_count"`
+	RefreshedAt          time.Time            `bun:"refreshed_at,nullzero,notnull,default:current_timestamp" json:"refreshed_at"`
+}
+
+// GeneCount is a precomputed per-gene SNP count with the best score seen.
+type GeneCount struct {
+	bun.BaseModel `bun:"table:summary_genes,alias:sg"`
+
+	GeneSymbol      string    `bun:"gene_symbol,pk" json:"gene_symbol"`
+	SNPCount        int       `bun:"snp_count,notnull" json:"snp_count"`
+	PathogenicCount int       `bun:"pathogenic_count,notnull" json:"pathogenic_count"`
+	MaxScore        *float64  `bun:"max_score" json:"max_score,omitempty"`
+	RefreshedAt     time.Time `bun:"refreshed_at,nullzero,notnull,default:current_timestamp" json:"refreshed_at"`
+}
+
+// ScoreHistogramBin is a precomputed bucket of the total score distribution.
+type ScoreHistogramBin struct {
+	bun.BaseModel `bun:"table:summary_score_histogram,alias:ssh"`
+
+	BucketStart int       `bun:"bucket_start,pk" json:"bucket_start"`
+	BucketEnd   int       `bun:"bucket_end,notnull" json:"bucket_end"`
+	SNPCount    int       `bun:"snp_count,notnull" json:"snp_count"`
+	RefreshedAt time.Time `bun:"refreshed_at,nullzero,notnull,default:current_timestamp" json:"refreshed_at"`
+}
+
+// Groupings of the frequency summaries: all SNPs together, per chromosome and
+// per clinical significance, named by GroupName.
+const (
+	FrequencyGroupingAll                  = "all"
+	FrequencyGroupingChromosome           = "chromosome"
+	FrequencyGroupingClinicalSignificance = "clinical_significance"
+)
+
+// FrequencySpectrumBin is a precomputed bucket of the site frequency
+// spectrum, folded on the global MAF, of a group of SNPs.
+type FrequencySpectrumBin struct {
+	bun.BaseModel `bun:"table:summary_frequency_spectrum,alias:sfs"`
+
+	Grouping    string    `bun:"grouping,pk" json:"grouping"`
+	GroupName   string    `bun:"group_name,pk" json:"group_name"`
+	BinStart    float64   `bun:"bin_start,pk" json:"bin_start"`
+	BinEnd      float64   `bun:"bin_end,notnull" json:"bin_end"`
+	SNPCount    int       `bun:"snp_count,notnull" json:"snp_count"`
+	RefreshedAt time.Time `bun:"refreshed_at,nullzero,notnull,default:current_timestamp" json:"refreshed_at"`
+}
+
+// FrequencyClassCount is a precomputed count of a group of SNPs by how
+// common they are: rare below RareMAF, low-frequency below CommonMAF, common
+// from it and unmeasured without a global MAF.
+type FrequencyClassCount struct {
+	bun.BaseModel `bun:"table:summary_frequency_classes,alias:sfc"`
+
+	Grouping          string    `bun:"grouping,pk" json:"grouping"`
+	GroupName         string    `bun:"group_name,pk" json:"group_name"`
+	RareCount         int       `bun:"rare_count,notnull" json:"rare_count"`
+	LowFrequencyCount int       `bun:"low_frequency_count,notnull" json:"low_frequency_count"`
+	CommonCount       int       `bun:"common_count,notnull" json:"common_count"`
+	UnmeasuredCount   int       `bun:"unmeasured_count,notnull" json:"unmeasured_count"`
+	RefreshedAt       time.Time `bun:"refreshed_at,nullzero,notnull,default:current_timestamp" json:"refreshed_at"`
+}
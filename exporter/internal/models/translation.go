@@ -1,11 +1,97 @@
 package models
 
 import (
+	"context"
+	"fmt"
 	"time"
 
 	"github.com/uptrace/bun"
 )
 
+// TranslationStatus is where a translation is in review: machine output
+// is reviewed by a person, then verified, e.g. by a medical translator.
+type TranslationStatus string
+
+const (
+	TranslationMachineDraft TranslationStatus = "machine_draft"
+	TranslationReviewed     TranslationStatus = "reviewed"
+	TranslationVerified     TranslationStatus = "verified"
+)
+
+// TranslationStatuses lists every TranslationStatus value, in the order a
+// translation passes through them.
+var TranslationStatuses = []TranslationStatus{TranslationMachineDraft, TranslationReviewed, TranslationVerified}
+
+// IsValid reports whether s is one of the known translation statuses.
+func (s TranslationStatus) IsValid() bool {
+	return contains(TranslationStatuses, s)
+}
+
+// Rank orders statuses by how far through review they are; a field
+// translated more than once is shown in the translation ranked highest.
+func (s TranslationStatus) Rank() int {
+	for i, status := range TranslationStatuses {
+		if status == s {
+			return i
+		}
+	}
+	return -1
+}
+
+// CanBecome reports whether a translation in status s may move to next: on
+// to the following status, or back to a draft when a reviewer rejects it.
+func (s TranslationStatus) CanBecome(next TranslationStatus) bool {
+	switch next {
+	case TranslationMachineDraft:
+		return s == TranslationReviewed || s == TranslationVerified
+	default:
+		return next.IsValid() && next.Rank() == s.Rank()+1
+	}
+}
+
+// review points at the review fields of a translation.
+type review struct {
+	status     *TranslationStatus
+	verified   *bool
+	reviewer   **string
+	reviewedAt **time.Time
+	verifiedAt **time.Time
+}
+
+// advance moves the review to status next on behalf of reviewer. Moving
+// back to a draft clears the review.
+func (r review) advance(next TranslationStatus, reviewer string, at time.Time) error {
+	r.normalize()
+	if !r.status.CanBecome(next) {
+		return fmt.Errorf("translation cannot go from %s to %s", *r.status, next)
+	}
+	at = at.UTC()
+	*r.status = next
+	switch next {
+	case TranslationMachineDraft:
+		*r.reviewer, *r.reviewedAt, *r.verifiedAt = nil, nil, nil
+	case TranslationReviewed:
+		*r.reviewer, *r.reviewedAt = &reviewer, &at
+	case TranslationVerified:
+		*r.reviewer, *r.verifiedAt = &reviewer, &at
+	}
+	*r.verified = next == TranslationVerified
+	return nil
+}
+
+// normalize derives an unset status from the verified flag, so rows written
+// without one, and before statuses existed, keep their meaning; and keeps
+// the flag in step with the status.
+func (r review) normalize() {
+	if *r.status == "" {
+		*r.status = TranslationMachineDraft
+		if *r.verified {
+			*r.status = TranslationVerified
+		}
+	}
+	*r.verified = *r.status == TranslationVerified
+}
+
 // Translation represents translated content for SNP fields.
 type Translation struct {
 	bun.BaseModel `bun:"table:snp_translations,alias:t"`
@@ -17,11 +103,49 @@ type Translation struct {
 	TranslatedText string    `bun:"translated_text,notnull" json:"translated_text"`
 	Translator     *string   `bun:"translator" json:"translator,omitempty"`
 	TranslatedAt   time.Time `bun:"translated_at,nullzero,notnull,default:current_timestamp" json:"translated_at"`
-	Verified       bool      `bun:"verified,default:false" json:"verified"`
+	// Verified is set exactly when Status is verified, for readers of the
+	// column from before statuses, such as exported artifacts.
+	Verified   bool              `bun:"verified,default:false" json:"verified"`
+	Status     TranslationStatus `bun:"status,notnull,default:'machine_draft'" json:"status"`
+	Reviewer   *string           `bun:"reviewer" json:"reviewer,omitempty"`
+	ReviewedAt *time.Time        `bun:"reviewed_at" json:"reviewed_at,omitempty"`
+	VerifiedAt *time.Time        `bun:"verified_at" json:"verified_at,omitempty"`
+	// UpdatedAt moves with reviews as well as with new texts, unlike
+	// TranslatedAt.
+	UpdatedAt time.Time `bun:"updated_at,nullzero,notnull,default:current_timestamp" json:"updated_at"`
 
 	SNP *SNP `bun:"rel:belongs-to,join:snp_id=id" json:"-"`
 }
 
+var _ bun.BeforeAppendModelHook = (*Translation)(nil)
+
+// BeforeAppendModel fills the review status before the translation is
+// written, updating the timestamp on modifications.
+func (t *Translation) BeforeAppendModel(ctx context.Context, query bun.Query) error {
+	touch(query, &t.UpdatedAt)
+	switch query.(type) {
+	case *bun.InsertQuery, *bun.UpdateQuery:
+		t.Normalize()
+	}
+	return nil
+}
+
+// Normalize fills an unset Status from Verified, and sets Verified from
+// Status, as writing the translation does.
+func (t *Translation) Normalize() {
+	t.review().normalize()
+}
+
+// Advance moves the translation to status next on behalf of reviewer.
+// Moving back to a draft clears the review.
+func (t *Translation) Advance(next TranslationStatus, reviewer string, at time.Time) error {
+	return t.review().advance(next, reviewer, at)
+}
+
+func (t *Translation) review() review {
+	return review{&t.Status, &t.Verified, &t.Reviewer, &t.ReviewedAt, &t.VerifiedAt}
+}
+
 // PhenotypeTranslation represents translated phenotype names.
 type PhenotypeTranslation struct {
 	bun.BaseModel `bun:"table:phenotype_translations,alias:pt"`
@@ -32,7 +156,52 @@ type PhenotypeTranslation struct {
 	TranslatedName string    `bun:"translated_name,notnull" json:"translated_name"`
 	Translator     *string   `bun:"translator" json:"translator,omitempty"`
 	TranslatedAt   time.Time `bun:"translated_at,nullzero,notnull,default:current_timestamp" json:"translated_at"`
-	Verified       bool      `bun:"verified,default:false" json:"verified"`
+	// Verified is set exactly when Status is verified, for readers of the
+	// column from before statuses, such as exported artifacts.
+	Verified   bool              `bun:"verified,default:false" json:"verified"`
+	Status     TranslationStatus `bun:"status,notnull,default:'machine_draft'" json:"status"`
+	Reviewer   *string           `bun:"reviewer" json:"reviewer,omitempty"`
+	ReviewedAt *time.Time        `bun:"reviewed_at" json:"reviewed_at,omitempty"`
+	VerifiedAt *time.Time        `bun:"verified_at" json:"verified_at,omitempty"`
 
 	Phenotype *Phenotype `bun:"rel:belongs-to,join:phenotype_id=id" json:"-"`
 }
+
+var _ bun.BeforeAppendModelHook = (*PhenotypeTranslation)(nil)
+
+// BeforeAppendModel fills the review status before the translation is
+// written.
+func (t *PhenotypeTranslation) BeforeAppendModel(ctx context.Context, query bun.Query) error {
+	switch query.(type) {
+	case *bun.InsertQuery, *bun.UpdateQuery:
+		t.Normalize()
+	}
+	return nil
+}
+
+// Normalize fills an unset Status from Verified, and sets Verified from
+// Status, as writing the translation does.
+func (t *PhenotypeTranslation) Normalize() {
+	t.review().normalize()
+}
+
+// Advance moves the translation to status next on behalf of reviewer.
+// Moving back to a draft clears the review.
+func (t *PhenotypeTranslation) Advance(next TranslationStatus, reviewer string, at time.Time) error {
+	return t.review().advance(next, reviewer, at)
+}
+
+func (t *PhenotypeTranslation) review() review {
+	return review{&t.Status, &t.Verified, &t.Reviewer, &t.ReviewedAt, &t.VerifiedAt}
+}
+
+// TranslationSummary is the field name of a SNP's plain-language summary,
+// which reports show under the variant in the reader's language.
+const TranslationSummary = "summary"
+
+// TranslationConditionField returns the field name of the translation of a
+// condition named in the SNP's clinical assertions and risk alleles, keyed by
+// its English name so every row naming it shares one translation.
+func TranslationConditionField(name string) string {
+	return "condition:" + name
+}
@@ -0,0 +1,38 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTranslationAdvance(t *testing.T) {
+	at := time.Date(2026, 3, 1, 12, 0, 0, 0, time.FixedZone("CET", 3600))
+	tr := &Translation{TranslatedText: "maschinell"}
+	if err := tr.Advance(TranslationVerified, "anna", at); err == nil {
+		t.Fatal("expected a draft not to skip review")
+	}
+	if err := tr.Advance(TranslationReviewed, "anna", at); err != nil {
+		t.Fatalf("review: %v", err)
+	}
+	if tr.Status != TranslationReviewed || tr.Verified || *tr.Reviewer != "anna" || tr.ReviewedAt.Location() != time.UTC {
+		t.Fatalf("unexpected review: %+v", tr)
+	}
+	if err := tr.Advance(TranslationVerified, "ben", at.Add(time.Hour)); err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	if tr.Status != TranslationVerified || !tr.Verified || *tr.Reviewer != "ben" || tr.VerifiedAt == nil {
+		t.Fatalf("unexpected verification: %+v", tr)
+	}
+	if err := tr.Advance(TranslationMachineDraft, "ben", at); err != nil {
+		t.Fatalf("reject: %v", err)
+	}
+	if tr.Verified || tr.Reviewer != nil || tr.ReviewedAt != nil || tr.VerifiedAt != nil {
+		t.Fatalf("expected a rejection to clear the review, got %+v", tr)
+	}
+
+	legacy := &PhenotypeTranslation{Verified: true}
+	legacy.Normalize()
+	if legacy.Status != TranslationVerified {
+		t.Fatalf("expected a verified row without status to be verified, got %q", legacy.Status)
+	}
+}
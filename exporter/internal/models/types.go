@@ -4,8 +4,15 @@ import (
 	"database/sql/driver"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"strconv"
+	"strings"
 )
 
+// ErrUnknownValue is returned, wrapped, when an enum column is written or
+// read with a value outside its type's known values.
+var ErrUnknownValue = errors.New("unknown value")
+
 // ClinicalSignificance represents the clinical impact.
 type ClinicalSignificance string
 
@@ -22,6 +29,27 @@ const (
 	ClinicalOther            ClinicalSignificance = "other"
 )
 
+// ClinicalSignificances lists every known ClinicalSignificance value.
+var ClinicalSignificances = []ClinicalSignificance{
+	ClinicalPathogenic, ClinicalLikelyPathogenic, ClinicalUncertainSignif, ClinicalLikelyBenign, ClinicalBenign,
+	ClinicalRiskFactor, ClinicalProtective, ClinicalDrugResponse, ClinicalAssociation, ClinicalOther,
+}
+
+// IsValid reports whether c is one of the known clinical significance values.
+func (c ClinicalSignificance) IsValid() bool {
+	return contains(ClinicalSignificances, c)
+}
+
+// Value rejects unknown clinical significance values, so they cannot be stored.
+func (c ClinicalSignificance) Value() (driver.Value, error) {
+	return enumValue("clinical significance", ClinicalSignificances, c)
+}
+
+// Scan reads a stored clinical significance, failing on unknown values.
+func (c *ClinicalSignificance) Scan(value interface{}) error {
+	return scanEnum("clinical significance", ClinicalSignificances, c, value)
+}
+
 // Review status per ClinVar.
 type ReviewStatus string
 
@@ -34,6 +62,27 @@ const (
 	ReviewNoAssertion       ReviewStatus = "no_assertion"
 )
 
+// ReviewStatuses lists every known ReviewStatus value.
+var ReviewStatuses = []ReviewStatus{
+	ReviewPracticeGuideline, ReviewExpertPanel, ReviewCriteriaProvided,
+	ReviewMultipleSubmitter, ReviewSingleSubmitter, ReviewNoAssertion,
+}
+
+// IsValid reports whether r is one of the known review status values.
+func (r ReviewStatus) IsValid() bool {
+	return contains(ReviewStatuses, r)
+}
+
+// Value rejects unknown review status values, so they cannot be stored.
+func (r ReviewStatus) Value() (driver.Value, error) {
+	return enumValue("review status", ReviewStatuses, r)
+}
+
+// Scan reads a stored review status, failing on unknown values.
+func (r *ReviewStatus) Scan(value interface{}) error {
+	return scanEnum("review status", ReviewStatuses, r, value)
+}
+
 // Data source tagging to track provenance.
 type DataSource string
 
@@ -44,8 +93,32 @@ const (
 	SourcePharmGKB DataSource = "pharmgkb"
 	SourceSNPedia  DataSource = "snpedia"
 	SourceGnomAD   DataSource = "gnomad"
+	SourceGWAS     DataSource = "gwas_catalog"
+	// SourceThousandGenomes and SourceALFA are the frequencies of the 1000
+	// Genomes Project and of NCBI's Allele Frequency Aggregator, which dbSNP
+	// reports beside its own.
+	SourceThousandGenomes DataSource = "1000genomes"
+	SourceALFA            DataSource = "alfa"
 )
 
+// DataSources lists every known DataSource value.
+var DataSources = []DataSource{SourceClinVar, SourceDbSNP, SourceOpenSNP, SourcePharmGKB, SourceSNPedia, SourceGnomAD, SourceGWAS, SourceThousandGenomes, SourceALFA}
+
+// IsValid reports whether d is one of the known data sources.
+func (d DataSource) IsValid() bool {
+	return contains(DataSources, d)
+}
+
+// Value rejects unknown data source values, so they cannot be stored.
+func (d DataSource) Value() (driver.Value, error) {
+	return enumValue("data source", DataSources, d)
+}
+
+// Scan reads a stored data source, failing on unknown values.
+func (d *DataSource) Scan(value interface{}) error {
+	return scanEnum("data source", DataSources, d, value)
+}
+
 // Variant type for SNP characterization.
 type VariantType string
 
@@ -56,8 +129,63 @@ const (
 	VariantIndel       VariantType = "indel"
 	VariantDuplication VariantType = "duplication"
 	VariantCNV         VariantType = "copy_number_variant"
+	VariantInversion   VariantType = "inversion"
+	// VariantOther is any other kind of variant, such as a microsatellite.
+	VariantOther VariantType = "other"
 )
 
+// VariantTypes lists every known VariantType value.
+var VariantTypes = []VariantType{
+	VariantSNV, VariantInsertion, VariantDeletion, VariantIndel, VariantDuplication, VariantCNV,
+	VariantInversion, VariantOther,
+}
+
+// IsValid reports whether v is one of the known variant types.
+func (v VariantType) IsValid() bool {
+	return contains(VariantTypes, v)
+}
+
+// Value rejects unknown variant type values, so they cannot be stored.
+func (v VariantType) Value() (driver.Value, error) {
+	return enumValue("variant type", VariantTypes, v)
+}
+
+// Scan reads a stored variant type, failing on unknown values.
+func (v *VariantType) Scan(value interface{}) error {
+	return scanEnum("variant type", VariantTypes, v, value)
+}
+
+// StructuralVariantType is the kind of a structural variant, as the SVTYPE
+// of VCF names it.
+type StructuralVariantType string
+
+const (
+	SVDeletion    StructuralVariantType = "DEL"
+	SVDuplication StructuralVariantType = "DUP"
+	SVInsertion   StructuralVariantType = "INS"
+	SVInversion   StructuralVariantType = "INV"
+	SVCopyNumber  StructuralVariantType = "CNV"
+)
+
+// StructuralVariantTypes lists every known StructuralVariantType value.
+var StructuralVariantTypes = []StructuralVariantType{SVDeletion, SVDuplication, SVInsertion, SVInversion, SVCopyNumber}
+
+// IsValid reports whether t is one of the known structural variant types.
+func (t StructuralVariantType) IsValid() bool {
+	return contains(StructuralVariantTypes, t)
+}
+
+// SymbolicAllele returns the VCF symbolic allele standing for a variant of
+// type t, such as "<DUP>", for structural variants whose sequence is not
+// spelled out.
+func (t StructuralVariantType) SymbolicAllele() string {
+	return "<" + string(t) + ">"
+}
+
+// StructuralVariantMinLength is the length from which insertions and
+// deletions count as structural variants, by the usual convention.
+const StructuralVariantMinLength = 50
+
 // Functional class approximations.
 type FunctionalClass string
 
@@ -74,28 +202,250 @@ const (
 	FuncIntergenic FunctionalClass = "intergenic"
 )
 
-// StringArray stores a slice of strings in SQLite as JSON.
+// FunctionalClasses lists every known FunctionalClass value.
+var FunctionalClasses = []FunctionalClass{
+	FuncMissense, FuncNonsense, FuncSynonymous, FuncFrameShift, FuncSplice,
+	FuncUTR5, FuncUTR3, FuncIntron, FuncRegulatory, FuncIntergenic,
+}
+
+// IsValid reports whether f is one of the known functional classes.
+func (f FunctionalClass) IsValid() bool {
+	return contains(FunctionalClasses, f)
+}
+
+// ParseFunctionalClass returns the functional class of a molecular
+// consequence named as a Sequence Ontology term, as ClinVar names them, such
+// as "missense variant" or "splice_donor_variant".
+func ParseFunctionalClass(consequence string) (FunctionalClass, bool) {
+	term := strings.ToLower(strings.TrimSpace(strings.ReplaceAll(consequence, "_", " ")))
+	term = strings.TrimSuffix(term, " variant")
+	switch term {
+	case "missense":
+		return FuncMissense, true
+	case "nonsense", "stop gained":
+		return FuncNonsense, true
+	case "synonymous":
+		return FuncSynonymous, true
+	case "frameshift":
+		return FuncFrameShift, true
+	case "splice donor", "splice acceptor", "splice region", "splice":
+		return FuncSplice, true
+	case "5 prime utr":
+		return FuncUTR5, true
+	case "3 prime utr":
+		return FuncUTR3, true
+	case "intron":
+		return FuncIntron, true
+	case "regulatory region", "regulatory", "tf binding site":
+		return FuncRegulatory, true
+	case "intergenic":
+		return FuncIntergenic, true
+	}
+	return "", false
+}
+
+// Value rejects unknown functional class values, so they cannot be stored.
+func (f FunctionalClass) Value() (driver.Value, error) {
+	return enumValue("functional class", FunctionalClasses, f)
+}
+
+// Scan reads a stored functional class, failing on unknown values.
+func (f *FunctionalClass) Scan(value interface{}) error {
+	return scanEnum("functional class", FunctionalClasses, f, value)
+}
+
+// EffectDirection is which way an allele or genotype moves what it is
+// associated with: the risk of a condition, or a trait's value.
+type EffectDirection string
+
+const (
+	EffectIncreased EffectDirection = "increased"
+	EffectDecreased EffectDirection = "decreased"
+	// EffectNone is no effect on the carrier, as one copy of an allele of a
+	// recessive condition has.
+	EffectNone EffectDirection = "none"
+)
+
+// EffectDirections lists every known EffectDirection value.
+var EffectDirections = []EffectDirection{EffectIncreased, EffectDecreased, EffectNone}
+
+// IsValid reports whether e is one of the known effect directions.
+func (e EffectDirection) IsValid() bool {
+	return contains(EffectDirections, e)
+}
+
+// Direction returns the effect direction c attributes to the alleles it is
+// asserted for, and "" if it names none, as drug response and plain
+// associations do not.
+func (c ClinicalSignificance) Direction() EffectDirection {
+	switch c {
+	case ClinicalPathogenic, ClinicalLikelyPathogenic, ClinicalRiskFactor:
+		return EffectIncreased
+	case ClinicalProtective:
+		return EffectDecreased
+	}
+	return ""
+}
+
+// OddsRatioDirection returns the effect direction of an odds ratio.
+func OddsRatioDirection(or float64) EffectDirection {
+	switch {
+	case or > 1:
+		return EffectIncreased
+	case or < 1:
+		return EffectDecreased
+	}
+	return EffectNone
+}
+
+func contains[T comparable](values []T, v T) bool {
+	for _, candidate := range values {
+		if candidate == v {
+			return true
+		}
+	}
+	return false
+}
+
+// enumValue returns v as stored, failing with ErrUnknownValue if it is not one
+// of values. The empty value is stored as NULL.
+func enumValue[T ~string](name string, values []T, v T) (driver.Value, error) {
+	if v == "" {
+		return nil, nil
+	}
+	if !contains(values, v) {
+		return nil, fmt.Errorf("%w: %s %q", ErrUnknownValue, name, v)
+	}
+	return string(v), nil
+}
+
+// scanEnum sets *v to the stored value, failing with ErrUnknownValue if it is
+// not one of values. NULL reads as the empty value.
+func scanEnum[T ~string](name string, values []T, v *T, value interface{}) error {
+	var s string
+	switch value := value.(type) {
+	case nil:
+		*v = ""
+		return nil
+	case string:
+		s = value
+	case []byte:
+		s = string(value)
+	default:
+		return fmt.Errorf("failed to scan %s from %T", name, value)
+	}
+	if !contains(values, T(s)) {
+		return fmt.Errorf("%w: %s %q", ErrUnknownValue, name, s)
+	}
+	*v = T(s)
+	return nil
+}
+
+// JSONColumn stores a value of type T as JSON text, which SQLite's JSON
+// columns and PostgreSQL's json and jsonb columns accept alike. It reads the
+// text back from []byte or string driver values, and NULL or empty text as
+// the zero value.
+type JSONColumn[T any] struct {
+	Data T
+}
+
+func (c JSONColumn[T]) Value() (driver.Value, error) {
+	data, err := json.Marshal(c.Data)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+func (c *JSONColumn[T]) Scan(value interface{}) error {
+	var zero T
+	c.Data = zero
+
+	var data []byte
+	switch value := value.(type) {
+	case nil:
+		return nil
+	case []byte:
+		data = value
+	case string:
+		data = []byte(value)
+	default:
+		return fmt.Errorf("failed to scan JSONColumn[%T] from %T", zero, value)
+	}
+	if len(data) == 0 {
+		return nil
+	}
+	return json.Unmarshal(data, &c.Data)
+}
+
+// scanJSON scans value into *dest through JSONColumn, setting it to empty
+// rather than nil when the column is NULL.
+func scanJSON[T any](value interface{}, dest *T, empty T) error {
+	c := JSONColumn[T]{}
+	if err := c.Scan(value); err != nil {
+		return err
+	}
+	*dest = c.Data
+	if value == nil {
+		*dest = empty
+	}
+	return nil
+}
+
+// StringArray stores a slice of strings as JSON.
 type StringArray []string
 
 func (s StringArray) Value() (driver.Value, error) {
-	if len(s) == 0 {
-		return "[]", nil
+	if s == nil {
+		s = StringArray{}
 	}
-	return json.Marshal(s)
+	return JSONColumn[StringArray]{Data: s}.Value()
 }
 
 func (s *StringArray) Scan(value interface{}) error {
-	if value == nil {
-		*s = []string{}
-		return nil
+	return scanJSON(value, s, StringArray{})
+}
+
+// StringMap stores a string-keyed map of strings as JSON.
+type StringMap map[string]string
+
+func (m StringMap) Value() (driver.Value, error) {
+	if m == nil {
+		m = StringMap{}
 	}
+	return JSONColumn[StringMap]{Data: m}.Value()
+}
+
+func (m *StringMap) Scan(value interface{}) error {
+	return scanJSON(value, m, StringMap{})
+}
+
+// FrequencyMap stores a string-keyed map of frequencies as JSON.
+type FrequencyMap map[string]float64
 
-	bytes, ok := value.([]byte)
-	if !ok {
-		return errors.New("failed to scan StringArray")
+func (m FrequencyMap) Value() (driver.Value, error) {
+	if m == nil {
+		m = FrequencyMap{}
 	}
+	return JSONColumn[FrequencyMap]{Data: m}.Value()
+}
 
-	return json.Unmarshal(bytes, s)
+func (m *FrequencyMap) Scan(value interface{}) error {
+	return scanJSON(value, m, FrequencyMap{})
+}
+
+// CountMap stores a string-keyed map of counts as JSON.
+type CountMap map[string]int
+
+func (m CountMap) Value() (driver.Value, error) {
+	if m == nil {
+		m = CountMap{}
+	}
+	return JSONColumn[CountMap]{Data: m}.Value()
+}
+
+func (m *CountMap) Scan(value interface{}) error {
+	return scanJSON(value, m, CountMap{})
 }
 
 // NullableFloat64 handles nullable float columns.
@@ -125,6 +475,13 @@ func (n *NullableFloat64) Scan(value interface{}) error {
 		if err := json.Unmarshal(v, &n.Float64); err != nil {
 			return err
 		}
+	case string:
+		// The columns are VARCHAR, so SQLite hands the value back as text.
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return err
+		}
+		n.Float64 = f
 	default:
 		return errors.New("failed to scan NullableFloat64")
 	}
@@ -1,6 +1,12 @@
 package models
 
-import "testing"
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
 
 func TestSNPValidate(t *testing.T) {
 	valid := &SNP{
@@ -21,6 +27,144 @@ func TestSNPValidate(t *testing.T) {
 	if err := invalid.Validate(); err == nil {
 		t.Fatalf("expected error for invalid SNP")
 	}
+
+	for _, rsID := range []string{"RS429358", "429358", "rs0429358"} {
+		snp := *valid
+		snp.RsID = rsID
+		if err := snp.Validate(); err == nil {
+			t.Errorf("expected %q rejected as not canonical", rsID)
+		}
+	}
+
+	snp := *valid
+	snp.VariantType = "single nucleotide variant"
+	if err := snp.Validate(); err == nil {
+		t.Error("expected an unknown variant type rejected")
+	}
+	class := FunctionalClass("missense variant")
+	snp = *valid
+	snp.FunctionalClass = &class
+	if err := snp.Validate(); err == nil {
+		t.Error("expected an unknown functional class rejected")
+	}
+}
+
+func TestEnumColumns(t *testing.T) {
+	if v, err := ClinicalPathogenic.Value(); err != nil || v != "pathogenic" {
+		t.Fatalf("Value = %v, %v", v, err)
+	}
+	if v, err := DataSource("").Value(); err != nil || v != nil {
+		t.Fatalf("expected the empty source stored as NULL, got %v, %v", v, err)
+	}
+	if _, err := ReviewStatus("reviewed").Value(); !errors.Is(err, ErrUnknownValue) {
+		t.Fatalf("expected an unknown review status refused, got %v", err)
+	}
+
+	var vt VariantType
+	if err := vt.Scan([]byte("deletion")); err != nil || vt != VariantDeletion {
+		t.Fatalf("Scan = %q, %v", vt, err)
+	}
+	if err := vt.Scan(nil); err != nil || vt != "" {
+		t.Fatalf("expected NULL read as empty, got %q, %v", vt, err)
+	}
+	var fc FunctionalClass
+	if err := fc.Scan("missense variant"); !errors.Is(err, ErrUnknownValue) || !strings.Contains(err.Error(), `"missense variant"`) {
+		t.Fatalf("expected the stored value named in the error, got %v", err)
+	}
+}
+
+func TestJSONColumn(t *testing.T) {
+	// Drivers hand JSON back as bytes or as text.
+	for _, value := range []interface{}{[]byte(`["A","G"]`), `["A","G"]`} {
+		var s StringArray
+		if err := s.Scan(value); err != nil || !slices.Equal(s, StringArray{"A", "G"}) {
+			t.Errorf("Scan(%T) = %v, %v", value, s, err)
+		}
+	}
+	var s StringArray
+	if err := s.Scan(nil); err != nil || s == nil || len(s) != 0 {
+		t.Errorf("expected NULL read as an empty array, got %#v, %v", s, err)
+	}
+	if err := s.Scan(42); err == nil {
+		t.Error("expected an integer refused")
+	}
+	if v, err := (StringArray)(nil).Value(); err != nil || v != "[]" {
+		t.Errorf("expected an empty array stored as [], got %v, %v", v, err)
+	}
+
+	var details ScoreBreakdown
+	if err := details.Scan(`{"reasons":["x"]}`); err != nil || len(details.Reasons) != 1 {
+		t.Errorf("unexpected score details %+v, %v", details, err)
+	}
+	c := JSONColumn[map[string]int]{Data: map[string]int{"a": 1}}
+	v, err := c.Value()
+	if err != nil || v != `{"a":1}` {
+		t.Fatalf("Value = %v, %v", v, err)
+	}
+	var back JSONColumn[map[string]int]
+	if err := back.Scan(v); err != nil || back.Data["a"] != 1 {
+		t.Fatalf("round trip = %v, %v", back.Data, err)
+	}
+	if err := back.Scan(""); err != nil || back.Data != nil {
+		t.Fatalf("expected empty text read as the zero value, got %v, %v", back.Data, err)
+	}
+}
+
+func TestParseFunctionalClass(t *testing.T) {
+	for consequence, want := range map[string]FunctionalClass{
+		"missense variant":     FuncMissense,
+		"missense":             FuncMissense,
+		"stop_gained":          FuncNonsense,
+		"splice donor variant": FuncSplice,
+		"5 prime UTR variant":  FuncUTR5,
+		"intron_variant":       FuncIntron,
+	} {
+		if got, ok := ParseFunctionalClass(consequence); !ok || got != want {
+			t.Errorf("ParseFunctionalClass(%q) = %q, %v; want %q", consequence, got, ok, want)
+		}
+	}
+	if got, ok := ParseFunctionalClass("no sequence alteration"); ok {
+		t.Errorf("expected no class for an unknown consequence, got %q", got)
+	}
+}
+
+func TestNormalizeRsID(t *testing.T) {
+	for in, want := range map[string]string{
+		"rs429358": "rs429358", "RS429358": "rs429358", " Rs429358\t": "rs429358",
+		"429358": "rs429358", "rs0429358": "rs429358",
+	} {
+		got, err := NormalizeRsID(in)
+		if err != nil || got != want {
+			t.Errorf("NormalizeRsID(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+	for _, in := range []string{"", "rs", "rs0", "i700123", "rs12a", "rs-1", "rs 1", "rs99999999999999999999"} {
+		if got, err := NormalizeRsID(in); !errors.Is(err, ErrInvalidRsID) {
+			t.Errorf("NormalizeRsID(%q) = %q, %v; want ErrInvalidRsID", in, got, err)
+		}
+	}
+	if !IsRsID("rs7412") || IsRsID("RS7412") || IsRsID("7412") {
+		t.Error("IsRsID accepts only the canonical form")
+	}
+	if n, ok := RsIDNumber("RS7412"); !ok || n != 7412 {
+		t.Errorf("RsIDNumber = %d, %v", n, ok)
+	}
+}
+
+func TestCanonicalRsIDs(t *testing.T) {
+	resolve := func(_ context.Context, rsIDs []string) (map[string]string, error) {
+		if !slices.Equal(rsIDs, []string{"rs1", "rs2"}) {
+			t.Errorf("resolver got %v", rsIDs)
+		}
+		return map[string]string{"rs2": "rs3"}, nil
+	}
+	got, err := CanonicalRsIDs(context.Background(), []string{"RS1", "2"}, resolve)
+	if err != nil || !slices.Equal(got, []string{"rs1", "rs3"}) {
+		t.Errorf("got %v, %v", got, err)
+	}
+	if _, err := CanonicalRsIDs(context.Background(), []string{"rs1", "chr1:100"}, nil); !errors.Is(err, ErrInvalidRsID) {
+		t.Errorf("expected ErrInvalidRsID, got %v", err)
+	}
 }
 
 func TestPhenotypeChecks(t *testing.T) {
@@ -70,6 +214,12 @@ func TestSignificanceHelpers(t *testing.T) {
 	if lvl := s.SignificanceLevel(); lvl != "Minimal" {
 		t.Fatalf("expected Minimal, got %s", lvl)
 	}
+
+	normalized := 75.0
+	s.NormalizedScore = &normalized
+	if !s.IsHighlySignificant() || s.SignificanceLevel() != "High" {
+		t.Fatalf("expected the normalized score to drive the level, got %s", s.SignificanceLevel())
+	}
 }
 
 func TestReferenceHelpers(t *testing.T) {
@@ -94,3 +244,176 @@ func TestPopulationHelpers(t *testing.T) {
 		t.Fatalf("expected rare")
 	}
 }
+
+func TestSNPDataValidate(t *testing.T) {
+	snp := &SNP{
+		RsID:             "rs429358",
+		Chromosome:       "19",
+		Position:         44908684,
+		ReferenceAllele:  "C",
+		AlternateAlleles: StringArray{"T"},
+		VariantType:      VariantSNV,
+	}
+	clinical := ClinicalData{
+		ConditionName:        "Alzheimer disease",
+		ClinicalSignificance: ClinicalRiskFactor,
+		ReviewStatus:         ReviewCriteriaProvided,
+		Source:               SourceClinVar,
+	}
+	phenotype := Phenotype{
+		PhenotypeName:   "Alzheimer disease",
+		AssociationType: "risk",
+		Source:          SourceOpenSNP,
+		PValue:          &NullableFloat64{Float64: 0.001, Valid: true},
+		OddsRatio:       &NullableFloat64{Float64: 3.2, Valid: true},
+	}
+	data := SNPData{SNP: snp, Clinical: []ClinicalData{clinical}, Phenotypes: []Phenotype{phenotype}}
+	if err := data.Validate(); err != nil {
+		t.Fatalf("expected valid bundle, got error: %v", err)
+	}
+
+	badClinical := clinical
+	badClinical.ConditionName = ""
+	badPhenotype := phenotype
+	badPhenotype.PValue = &NullableFloat64{Float64: 1.5, Valid: true}
+	data = SNPData{SNP: snp, Clinical: []ClinicalData{clinical, badClinical}, Phenotypes: []Phenotype{badPhenotype}}
+	err := data.Validate()
+	if err == nil {
+		t.Fatalf("expected error for invalid rows")
+	}
+	for _, want := range []string{"clinical 1:", "phenotype 0:"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Fatalf("expected %q in %v", want, err)
+		}
+	}
+
+	if err := (&SNPData{}).Validate(); err == nil {
+		t.Fatalf("expected error for missing SNP")
+	}
+}
+
+func TestDeriveGenotypeEffects(t *testing.T) {
+	recessive := "Autosomal recessive inheritance"
+	snp := &SNP{ReferenceAllele: "T", AlternateAlleles: StringArray{"C", "G"}}
+	effects := DeriveGenotypeEffects(snp, []RiskAllele{
+		{Allele: "C", Effect: "pathogenic", Direction: EffectIncreased, ConditionName: "A", Inheritance: &recessive, Source: SourceClinVar},
+		{Allele: "G", Effect: "association", ConditionName: "B", OddsRatio: &NullableFloat64{Float64: 0.5, Valid: true}, Source: SourceGWAS},
+		{Allele: "T", Effect: "association", ConditionName: "C", Source: SourceGWAS},
+	})
+	type want struct {
+		genotype, effect string
+		direction        EffectDirection
+		magnitude        float64
+	}
+	wants := []want{
+		{"C/T", "carrier", EffectNone, 0},
+		{"C/C", "pathogenic", EffectIncreased, 0},
+		{"G/T", "association", EffectDecreased, 0.5},
+		{"G/G", "association", EffectDecreased, 0.25},
+	}
+	if len(effects) != len(wants) {
+		t.Fatalf("expected %d effects, got %+v", len(wants), effects)
+	}
+	for i, w := range wants {
+		e := effects[i]
+		var magnitude float64
+		if e.Magnitude != nil {
+			magnitude = e.Magnitude.Float64
+		}
+		if e.Genotype != w.genotype || e.Effect != w.effect || e.Direction != w.direction || magnitude != w.magnitude {
+			t.Errorf("effect %d: expected %+v, got %+v", i, w, e)
+		}
+		if err := e.Validate(); err != nil {
+			t.Errorf("effect %d: %v", i, err)
+		}
+	}
+
+	if GenotypeOf("T", "C") != GenotypeOf("C", "T") {
+		t.Error("expected genotypes independent of allele order")
+	}
+	bad := GenotypeEffect{Genotype: "C/T", Effect: "x", ConditionName: "A", Direction: "up", Source: SourceClinVar}
+	if err := bad.Validate(); err == nil {
+		t.Error("expected an unknown direction rejected")
+	}
+}
+
+func TestClinicalAgreementSummarize(t *testing.T) {
+	cases := []struct {
+		calls       []ClinicalSignificance
+		majority    ClinicalSignificance
+		agreement   float64
+		conflicting bool
+	}{
+		{[]ClinicalSignificance{ClinicalPathogenic, ClinicalLikelyPathogenic}, ClinicalPathogenic, 1, false},
+		{[]ClinicalSignificance{ClinicalPathogenic, ClinicalLikelyPathogenic, ClinicalUncertainSignif, ClinicalRiskFactor}, ClinicalPathogenic, 2.0 / 3, true},
+		{[]ClinicalSignificance{ClinicalLikelyBenign, ClinicalPathogenic}, "", 0.5, true},
+		{[]ClinicalSignificance{ClinicalDrugResponse}, "", 0, false},
+		{nil, "", 0, false},
+	}
+	for _, c := range cases {
+		a := ClinicalAgreement{ConditionName: "A", Source: SourceClinVar}
+		a.Summarize(c.calls)
+		if a.MajorityCall != c.majority || a.Agreement != c.agreement || a.Conflicting != c.conflicting {
+			t.Errorf("%v: expected %q at %g, conflicting %v; got %+v", c.calls, c.majority, c.agreement, c.conflicting, a)
+		}
+		if a.Submissions() != len(c.calls) {
+			t.Errorf("%v: counted %d submissions", c.calls, a.Submissions())
+		}
+		if err := a.Validate(); err != nil {
+			t.Errorf("%v: %v", c.calls, err)
+		}
+	}
+
+	a := ClinicalAgreement{PathogenicCount: 1, BenignCount: 3, UncertainCount: 1, OtherCount: 2}
+	if a.Against(ClinicalLikelyPathogenic) != 4 || a.Against(ClinicalBenign) != 2 || a.Against(ClinicalRiskFactor) != 0 {
+		t.Errorf("unexpected submissions against: %d, %d, %d", a.Against(ClinicalLikelyPathogenic), a.Against(ClinicalBenign), a.Against(ClinicalRiskFactor))
+	}
+
+	bad := ClinicalAgreement{ConditionName: "A", MajorityCall: ClinicalRiskFactor, Source: SourceClinVar}
+	if err := bad.Validate(); err == nil {
+		t.Error("expected a majority call taking no side rejected")
+	}
+}
+
+func TestHaplotype(t *testing.T) {
+	e4 := &Haplotype{Gene: "APOE", Name: "ε4", Kind: HaplotypeNamedAllele, SNPs: []*HaplotypeSNP{
+		{RsID: "rs429358", Allele: "C"},
+		{RsID: "rs7412", Allele: "C"},
+	}}
+	if err := e4.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if got := e4.FullName(); got != "APOE ε4" {
+		t.Errorf("FullName = %q", got)
+	}
+	if got := (&Haplotype{Gene: "CYP2C19", Name: "*2"}).FullName(); got != "CYP2C19*2" {
+		t.Errorf("FullName = %q", got)
+	}
+
+	cases := []struct {
+		genotypes map[string][]string
+		copies    int
+		ok        bool
+	}{
+		{map[string][]string{"rs429358": {"T", "T"}, "rs7412": {"C", "C"}}, 0, true},
+		{map[string][]string{"rs429358": {"T", "C"}, "rs7412": {"C", "C"}}, 1, true},
+		{map[string][]string{"rs429358": {"C", "C"}, "rs7412": {"C", "C"}}, 2, true},
+		{map[string][]string{"rs429358": {"C", "C"}}, 0, false},
+	}
+	for i, tc := range cases {
+		if copies, ok := e4.Copies(tc.genotypes); copies != tc.copies || ok != tc.ok {
+			t.Errorf("case %d: got %d, %v; want %d, %v", i, copies, ok, tc.copies, tc.ok)
+		}
+	}
+
+	for name, h := range map[string]*Haplotype{
+		"no members": {Gene: "APOE", Name: "ε4", Kind: HaplotypeNamedAllele},
+		"bad kind":   {Gene: "APOE", Name: "ε4", Kind: "isoform", SNPs: e4.SNPs},
+		"bad rsID":   {Gene: "APOE", Name: "ε4", Kind: HaplotypeNamedAllele, SNPs: []*HaplotypeSNP{{RsID: "RS429358", Allele: "C"}}},
+		"twice":      {Gene: "APOE", Name: "ε4", Kind: HaplotypeNamedAllele, SNPs: []*HaplotypeSNP{e4.SNPs[0], e4.SNPs[0]}},
+	} {
+		if err := h.Validate(); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
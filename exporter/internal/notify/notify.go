@@ -0,0 +1,299 @@
+// Package notify tells people how a pipeline run ended, through generic JSON
+// webhooks, Slack incoming webhooks and email, so unattended scheduled runs
+// surface failures without anyone tailing logs.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/smtp"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/mkoziy/genome/exporter/internal/pipeline"
+)
+
+// When a run is notified about.
+const (
+	OnAlways  = "always"
+	OnFailure = "failure"
+)
+
+// DefaultTimeout bounds the delivery of one run's notifications.
+const DefaultTimeout = 30 * time.Second
+
+// Config lists where run notifications go. Targets may be combined; with none
+// configured nothing is sent.
+type Config struct {
+	// On is OnAlways to notify about every run or OnFailure to notify only
+	// about runs that did not complete.
+	On string `yaml:"on" json:"on"`
+	// Webhooks receive the Summary as a JSON POST.
+	Webhooks []string `yaml:"webhooks" json:"-"`
+	// Slack lists Slack incoming webhook URLs.
+	Slack   []string      `yaml:"slack" json:"-"`
+	Email   EmailConfig   `yaml:"email" json:"email"`
+	Timeout time.Duration `yaml:"timeout" json:"timeout"`
+}
+
+// EmailConfig sends notifications through an SMTP server. Username and
+// Password are only needed if the server requires authentication.
+type EmailConfig struct {
+	// SMTPAddr is the server's host:port.
+	SMTPAddr string   `yaml:"smtp_addr" json:"smtp_addr,omitempty"`
+	From     string   `yaml:"from" json:"from,omitempty"`
+	To       []string `yaml:"to" json:"to,omitempty"`
+	Username string   `yaml:"username" json:"username,omitempty"`
+	Password string   `yaml:"password" json:"-"`
+}
+
+// Enabled reports whether email is configured.
+func (c EmailConfig) Enabled() bool {
+	return c.SMTPAddr != "" || len(c.To) > 0
+}
+
+// Validate reports every invalid setting at once, naming fields relative to c.
+func (c Config) Validate() error {
+	var errs []error
+	switch c.On {
+	case "", OnAlways, OnFailure:
+	default:
+		errs = append(errs, fmt.Errorf("on: unknown value %q (want %s or %s)", c.On, OnAlways, OnFailure))
+	}
+	for i, u := range c.Webhooks {
+		if err := validateURL(u); err != nil {
+			errs = append(errs, fmt.Errorf("webhooks[%d]: %w", i, err))
+		}
+	}
+	for i, u := range c.Slack {
+		if err := validateURL(u); err != nil {
+			errs = append(errs, fmt.Errorf("slack[%d]: %w", i, err))
+		}
+	}
+	if c.Email.Enabled() {
+		if _, _, err := net.SplitHostPort(c.Email.SMTPAddr); err != nil {
+			errs = append(errs, fmt.Errorf("email.smtp_addr: want host:port, got %q", c.Email.SMTPAddr))
+		}
+		if c.Email.From == "" {
+			errs = append(errs, errors.New("email.from: required"))
+		}
+		if len(c.Email.To) == 0 {
+			errs = append(errs, errors.New("email.to: required"))
+		}
+	}
+	if c.Timeout < 0 {
+		errs = append(errs, errors.New("timeout: must not be negative"))
+	}
+	return errors.Join(errs...)
+}
+
+func validateURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return errors.New("invalid URL")
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("want an http or https URL, got %q", redact(raw))
+	}
+	return nil
+}
+
+// redact drops everything after the host of a URL, since webhook paths and
+// queries usually embed a secret token.
+func redact(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return "<invalid URL>"
+	}
+	return u.Scheme + "://" + u.Host
+}
+
+// Summary is what a notification reports about a run; webhooks receive it as
+// JSON.
+type Summary struct {
+	// Job names the scheduled job that started the run, if any.
+	Job         string                  `json:"job,omitempty"`
+	RunID       string                  `json:"run_id,omitempty"`
+	Status      string                  `json:"status"`
+	StartedAt   *time.Time              `json:"started_at,omitempty"`
+	FinishedAt  *time.Time              `json:"finished_at,omitempty"`
+	Downloaded  int                     `json:"downloaded"`
+	Updated     int                     `json:"updated"`
+	Quarantined int                     `json:"quarantined"`
+	Errors      int                     `json:"errors"`
+	Error       string                  `json:"error,omitempty"`
+	Stages      []*pipeline.StageReport `json:"stages,omitempty"`
+}
+
+// NewSummary summarizes a run from its report and the error the run returned.
+// report may be nil when the run failed before it was recorded.
+func NewSummary(job string, report *pipeline.Report, runErr error) Summary {
+	s := Summary{Job: job, Status: pipeline.StatusFailed}
+	if runErr != nil {
+		s.Error = runErr.Error()
+	}
+	if report == nil || report.Metadata == nil {
+		return s
+	}
+	meta := report.Metadata
+	start := meta.StartTime
+	s.RunID, s.Status = meta.RunID, meta.Status
+	s.StartedAt, s.FinishedAt = &start, meta.EndTime
+	s.Downloaded, s.Updated = meta.SNPsDownloaded, meta.SNPsUpdated
+	s.Quarantined, s.Errors = meta.SNPsSkipped, meta.ErrorsCount
+	s.Stages = report.Stages
+	return s
+}
+
+// Failed reports whether the run did not complete.
+func (s Summary) Failed() bool {
+	return s.Status != pipeline.StatusCompleted
+}
+
+// Title is a one-line description of the run, used as the email subject.
+func (s Summary) Title() string {
+	name := "Run"
+	if s.RunID != "" {
+		name += " " + s.RunID
+	}
+	if s.Job != "" {
+		name = "Job " + s.Job + ": " + name
+	}
+	return name + " " + s.Status
+}
+
+// Text describes the run and its stages in plain text.
+func (s Summary) Text() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s: %d downloaded, %d updated", s.Title(), s.Downloaded, s.Updated)
+	if s.Quarantined > 0 {
+		fmt.Fprintf(&b, ", %d quarantined", s.Quarantined)
+	}
+	if s.Errors > 0 {
+		fmt.Fprintf(&b, ", %d errors", s.Errors)
+	}
+	if s.StartedAt != nil && s.FinishedAt != nil {
+		fmt.Fprintf(&b, " in %s", s.FinishedAt.Sub(*s.StartedAt).Round(time.Second))
+	}
+	b.WriteByte('\n')
+	for _, stage := range s.Stages {
+		fmt.Fprintf(&b, "%s: %s", stage.Name, stage.Status)
+		if stage.Error != "" {
+			fmt.Fprintf(&b, " (%s)", stage.Error)
+		}
+		b.WriteByte('\n')
+	}
+	if s.Error != "" && len(s.Stages) == 0 {
+		fmt.Fprintf(&b, "error: %s\n", s.Error)
+	}
+	return b.String()
+}
+
+// Notifier delivers run summaries to the configured targets.
+type Notifier struct {
+	cfg    Config
+	client *http.Client
+	// sendMail is smtp.SendMail, replaced in tests.
+	sendMail func(addr string, a smtp.Auth, from string, to []string, msg []byte) error
+}
+
+// New creates a notifier for cfg.
+func New(cfg Config) *Notifier {
+	if cfg.On == "" {
+		cfg.On = OnAlways
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = DefaultTimeout
+	}
+	return &Notifier{cfg: cfg, client: http.DefaultClient, sendMail: smtp.SendMail}
+}
+
+// WithHTTPClient replaces the client webhooks are posted with.
+func (n *Notifier) WithHTTPClient(client *http.Client) *Notifier {
+	n.client = client
+	return n
+}
+
+// Enabled reports whether any target is configured.
+func (n *Notifier) Enabled() bool {
+	return len(n.cfg.Webhooks) > 0 || len(n.cfg.Slack) > 0 || n.cfg.Email.Enabled()
+}
+
+// Notify sends s to every target, unless the notifier only reports failures
+// and the run completed. Every target is tried; the errors of those that
+// failed are returned together.
+func (n *Notifier) Notify(ctx context.Context, s Summary) error {
+	if n.cfg.On == OnFailure && !s.Failed() {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, n.cfg.Timeout)
+	defer cancel()
+
+	var errs []error
+	for _, u := range n.cfg.Webhooks {
+		if err := n.post(ctx, u, s); err != nil {
+			errs = append(errs, fmt.Errorf("webhook %s: %w", redact(u), err))
+		}
+	}
+	for _, u := range n.cfg.Slack {
+		if err := n.post(ctx, u, map[string]string{"text": s.Text()}); err != nil {
+			errs = append(errs, fmt.Errorf("slack %s: %w", redact(u), err))
+		}
+	}
+	if n.cfg.Email.Enabled() {
+		if err := n.email(s); err != nil {
+			errs = append(errs, fmt.Errorf("email: %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (n *Notifier) post(ctx context.Context, u string, payload any) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := n.client.Do(req)
+	if err != nil {
+		// The error repeats the URL, which may hold a token.
+		var uerr *url.Error
+		if errors.As(err, &uerr) {
+			err = uerr.Err
+		}
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+func (n *Notifier) email(s Summary) error {
+	e := n.cfg.Email
+	var auth smtp.Auth
+	if e.Username != "" {
+		host, _, _ := net.SplitHostPort(e.SMTPAddr)
+		auth = smtp.PlainAuth("", e.Username, e.Password, host)
+	}
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", e.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(e.To, ", "))
+	fmt.Fprintf(&msg, "Subject: [exporter] %s\r\n", s.Title())
+	msg.WriteString("MIME-Version: 1.0\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n")
+	msg.WriteString(strings.ReplaceAll(s.Text(), "\n", "\r\n"))
+	return n.sendMail(e.SMTPAddr, auth, e.From, e.To, msg.Bytes())
+}
@@ -0,0 +1,131 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/smtp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mkoziy/genome/exporter/internal/models"
+	"github.com/mkoziy/genome/exporter/internal/pipeline"
+)
+
+func testReport(status string) *pipeline.Report {
+	end := time.Date(2024, 5, 1, 3, 10, 0, 0, time.UTC)
+	return &pipeline.Report{
+		Metadata: &models.DownloadMetadata{
+			RunID: "abc123", Status: status,
+			StartTime: end.Add(-10 * time.Minute), EndTime: &end,
+			SNPsDownloaded: 120, SNPsUpdated: 40, SNPsSkipped: 2, ErrorsCount: 3,
+		},
+		Stages: []*pipeline.StageReport{
+			{Name: pipeline.StageClinVar, Status: status, Error: "error budget exceeded"},
+			{Name: pipeline.StageScoring, Status: pipeline.StatusSkipped},
+		},
+	}
+}
+
+func TestNotifySendsToEveryTarget(t *testing.T) {
+	var webhook Summary
+	var slack map[string]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var err error
+		switch r.URL.Path {
+		case "/hook":
+			err = json.NewDecoder(r.Body).Decode(&webhook)
+		case "/slack":
+			err = json.NewDecoder(r.Body).Decode(&slack)
+		default:
+			http.Error(w, "gone", http.StatusGone)
+		}
+		if err != nil {
+			t.Errorf("decode %s: %v", r.URL.Path, err)
+		}
+	}))
+	defer srv.Close()
+
+	var mail struct {
+		addr string
+		to   []string
+		msg  string
+	}
+	n := New(Config{
+		Webhooks: []string{srv.URL + "/hook", srv.URL + "/secret-token"},
+		Slack:    []string{srv.URL + "/slack"},
+		Email:    EmailConfig{SMTPAddr: "smtp.example.org:587", From: "exporter@example.org", To: []string{"ops@example.org"}},
+	})
+	n.sendMail = func(addr string, a smtp.Auth, from string, to []string, msg []byte) error {
+		mail.addr, mail.to, mail.msg = addr, to, string(msg)
+		return nil
+	}
+
+	err := n.Notify(context.Background(), NewSummary("nightly", testReport(pipeline.StatusFailed), errors.New("clinvar: error budget exceeded")))
+	if err == nil || !strings.Contains(err.Error(), "410") {
+		t.Fatalf("expected the failing webhook reported, got %v", err)
+	}
+	if strings.Contains(err.Error(), "secret-token") {
+		t.Fatalf("error leaks the webhook path: %v", err)
+	}
+
+	if webhook.RunID != "abc123" || webhook.Job != "nightly" || webhook.Status != pipeline.StatusFailed ||
+		webhook.Downloaded != 120 || webhook.Quarantined != 2 || len(webhook.Stages) != 2 {
+		t.Fatalf("unexpected webhook payload: %+v", webhook)
+	}
+	if !strings.Contains(slack["text"], "Job nightly: Run abc123 failed: 120 downloaded, 40 updated, 2 quarantined, 3 errors in 10m0s") ||
+		!strings.Contains(slack["text"], "clinvar: failed (error budget exceeded)") {
+		t.Fatalf("unexpected slack text: %q", slack["text"])
+	}
+	if mail.addr != "smtp.example.org:587" || len(mail.to) != 1 ||
+		!strings.Contains(mail.msg, "Subject: [exporter] Job nightly: Run abc123 failed\r\n") {
+		t.Fatalf("unexpected mail: %+v", mail)
+	}
+}
+
+func TestNotifyOnFailureSkipsCompletedRuns(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+	}))
+	defer srv.Close()
+
+	n := New(Config{On: OnFailure, Webhooks: []string{srv.URL}})
+	if err := n.Notify(context.Background(), NewSummary("", testReport(pipeline.StatusCompleted), nil)); err != nil {
+		t.Fatalf("notify: %v", err)
+	}
+	if calls != 0 {
+		t.Fatalf("expected no notification for a completed run, got %d", calls)
+	}
+
+	// A run that failed before it was recorded has no report.
+	if err := n.Notify(context.Background(), NewSummary("", nil, errors.New("open database"))); err != nil {
+		t.Fatalf("notify: %v", err)
+	}
+	if calls != 1 {
+		t.Fatalf("expected one notification for the failed run, got %d", calls)
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	cfg := Config{
+		On:       "sometimes",
+		Webhooks: []string{"ftp://example.org/hook"},
+		Email:    EmailConfig{SMTPAddr: "smtp.example.org"},
+	}
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatalf("expected errors")
+	}
+	for _, want := range []string{"on:", "webhooks[0]", "email.smtp_addr", "email.from", "email.to"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Fatalf("expected %q in %v", want, err)
+		}
+	}
+	if err := (Config{}).Validate(); err != nil {
+		t.Fatalf("expected the zero config to be valid, got %v", err)
+	}
+}
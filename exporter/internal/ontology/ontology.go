@@ -0,0 +1,214 @@
+// Package ontology reads the hierarchies of disease ontologies, MONDO and
+// MedGen, into conditions and the relations that make one a subtype of
+// another, so that queries about a condition can take in its subtypes.
+package ontology
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/mkoziy/genome/exporter/internal/models"
+)
+
+// ErrFormat is returned for ontology files that cannot be read.
+var ErrFormat = errors.New("malformed ontology file")
+
+// Hierarchy is the conditions of an ontology and their is-a relations.
+type Hierarchy struct {
+	Conditions []*models.Condition
+	Relations  []*models.ConditionRelation
+}
+
+// add records that child is a parent's subtype, once.
+func (h *Hierarchy) add(seen map[[2]string]bool, parent, child string) {
+	if parent == child || seen[[2]string{parent, child}] {
+		return
+	}
+	seen[[2]string{parent, child}] = true
+	h.Relations = append(h.Relations, &models.ConditionRelation{ParentID: parent, ChildID: child})
+}
+
+// prune drops the relations to or from a condition not in the hierarchy,
+// such as obsolete terms.
+func (h *Hierarchy) prune() {
+	known := make(map[string]bool, len(h.Conditions))
+	for _, c := range h.Conditions {
+		known[c.ID] = true
+	}
+	h.Relations = slices.DeleteFunc(h.Relations, func(r *models.ConditionRelation) bool {
+		return !known[r.ParentID] || !known[r.ChildID]
+	})
+}
+
+// ParseMONDO reads the MONDO disease ontology in OBO format, gzipped or not,
+// such as mondo.obo. Obsolete terms are left out; a term's MedGen ID is
+// taken from its MEDGEN or UMLS cross-reference naming a concept ID,
+// preferring one MONDO marks as equivalent.
+func ParseMONDO(r io.Reader) (*Hierarchy, error) {
+	br, done, err := open(r)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	h := &Hierarchy{}
+	seen := make(map[[2]string]bool)
+	var (
+		term                 *models.Condition
+		parents              []string
+		obsolete, equivalent bool
+	)
+	flush := func() {
+		if term != nil && !obsolete && strings.HasPrefix(term.ID, "MONDO:") {
+			h.Conditions = append(h.Conditions, term)
+			for _, p := range parents {
+				h.add(seen, p, term.ID)
+			}
+		}
+		term, parents, obsolete, equivalent = nil, nil, false, false
+	}
+	sc := bufio.NewScanner(br)
+	sc.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for line := 1; sc.Scan(); line++ {
+		text := strings.TrimSpace(sc.Text())
+		if strings.HasPrefix(text, "[") {
+			flush()
+			if text == "[Term]" {
+				term = &models.Condition{Ontology: models.OntologyMONDO}
+			}
+			continue
+		}
+		key, value, ok := strings.Cut(text, ":")
+		if term == nil || !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		switch key {
+		case "id":
+			if value == "" {
+				return nil, fmt.Errorf("line %d: %w: empty id", line, ErrFormat)
+			}
+			term.ID = value
+		case "name":
+			term.Name = value
+		case "is_obsolete":
+			obsolete = value == "true"
+		case "is_a":
+			parent, _, _ := strings.Cut(value, " ")
+			parents = append(parents, parent)
+		case "xref":
+			id, qualifiers, _ := strings.Cut(value, " ")
+			db, cui, _ := strings.Cut(id, ":")
+			if (db != "MEDGEN" && db != "UMLS") || !isConceptID(cui) {
+				continue
+			}
+			if eq := strings.Contains(qualifiers, "equivalentTo"); term.MedGenID == nil || (eq && !equivalent) {
+				term.MedGenID, equivalent = &cui, eq
+			}
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	flush()
+	h.prune()
+	return h, nil
+}
+
+// ParseMedGen reads MedGen's hierarchy from its NAMES.RRF file of concept
+// names and MGREL.RRF file of relations between concepts, gzipped or not.
+// Relations other than parent and child, and suppressed rows, are left out.
+func ParseMedGen(names, relations io.Reader) (*Hierarchy, error) {
+	h := &Hierarchy{}
+	err := readRRF(names, 4, func(fields []string) {
+		if fields[3] != "Y" {
+			h.Conditions = append(h.Conditions, &models.Condition{ID: fields[0], Name: fields[1], Ontology: models.OntologyMedGen})
+		}
+	})
+	if err != nil {
+		return nil, fmt.Errorf("names: %w", err)
+	}
+	seen := make(map[[2]string]bool)
+	// REL is the relation of CUI2 to CUI1.
+	err = readRRF(relations, 12, func(fields []string) {
+		if fields[11] == "Y" {
+			return
+		}
+		switch fields[3] {
+		case "PAR":
+			h.add(seen, fields[4], fields[0])
+		case "CHD":
+			h.add(seen, fields[0], fields[4])
+		}
+	})
+	if err != nil {
+		return nil, fmt.Errorf("relations: %w", err)
+	}
+	h.prune()
+	return h, nil
+}
+
+// readRRF passes each row of a pipe-delimited MedGen file with at least
+// columns fields to fn, skipping the "#" header.
+func readRRF(r io.Reader, columns int, fn func(fields []string)) error {
+	br, done, err := open(r)
+	if err != nil {
+		return err
+	}
+	defer done()
+	sc := bufio.NewScanner(br)
+	sc.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for line := 1; sc.Scan(); line++ {
+		text := strings.TrimRight(sc.Text(), "\r")
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.Split(text, "|")
+		if len(fields) < columns {
+			return fmt.Errorf("line %d: %w: %d fields, want %d", line, ErrFormat, len(fields), columns)
+		}
+		fn(fields)
+	}
+	return sc.Err()
+}
+
+// open returns a reader of r's contents, gunzipped if they are gzipped, and
+// a function releasing it.
+func open(r io.Reader) (*bufio.Reader, func(), error) {
+	br := bufio.NewReader(r)
+	if magic, _ := br.Peek(2); bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, nil, fmt.Errorf("gunzip: %w", err)
+		}
+		return bufio.NewReader(gz), func() { _ = gz.Close() }, nil
+	}
+	return br, func() {}, nil
+}
+
+// isConceptID reports whether id is a concept ID as ClinVar names conditions
+// by: a UMLS one, "C" and seven digits, or one MedGen minted, "CN" and
+// digits, as opposed to MedGen's numeric UIDs.
+func isConceptID(id string) bool {
+	digits, ok := strings.CutPrefix(id, "CN")
+	if !ok {
+		if digits, ok = strings.CutPrefix(id, "C"); !ok || len(digits) != 7 {
+			return false
+		}
+	}
+	if digits == "" {
+		return false
+	}
+	for _, c := range digits {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,94 @@
+package ontology
+
+import (
+	"bytes"
+	"compress/gzip"
+	"strings"
+	"testing"
+)
+
+const mondoOBO = `format-version: 1.2
+ontology: mondo
+
+[Term]
+id: MONDO:0004994
+name: cardiomyopathy
+xref: MEDGEN:1234 {source="MONDO:equivalentTo"}
+xref: UMLS:C0878544 {source="MONDO:equivalentTo"}
+is_a: MONDO:0005267 {source="NCIT:C34830"} ! heart disorder
+
+[Term]
+id: MONDO:0005045
+name: hypertrophic cardiomyopathy
+xref: UMLS:C0949658 {source="MONDO:relatedTo"}
+xref: MEDGEN:C0007194 {source="MONDO:equivalentTo"}
+is_a: MONDO:0004994 ! cardiomyopathy
+
+[Term]
+id: MONDO:0005267
+name: heart disorder
+
+[Term]
+id: MONDO:0000001
+name: obsolete cardiomyopathy
+is_obsolete: true
+is_a: MONDO:0004994
+
+[Typedef]
+id: part_of
+name: part of
+`
+
+func TestParseMONDO(t *testing.T) {
+	var gz bytes.Buffer
+	w := gzip.NewWriter(&gz)
+	_, _ = w.Write([]byte(mondoOBO))
+	_ = w.Close()
+
+	h, err := ParseMONDO(&gz)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if len(h.Conditions) != 3 {
+		t.Fatalf("expected 3 conditions without the obsolete one, got %d", len(h.Conditions))
+	}
+	cardio, hcm := h.Conditions[0], h.Conditions[1]
+	if cardio.Name != "cardiomyopathy" || cardio.MedGenID == nil || *cardio.MedGenID != "C0878544" {
+		t.Errorf("expected cardiomyopathy mapped to C0878544, got %+v", cardio)
+	}
+	if hcm.MedGenID == nil || *hcm.MedGenID != "C0007194" {
+		t.Errorf("expected the equivalent MedGen ID preferred, got %v", hcm.MedGenID)
+	}
+	if len(h.Relations) != 2 {
+		t.Fatalf("expected 2 relations, got %d", len(h.Relations))
+	}
+	if r := h.Relations[1]; r.ParentID != "MONDO:0004994" || r.ChildID != "MONDO:0005045" {
+		t.Errorf("expected hypertrophic cardiomyopathy under cardiomyopathy, got %+v", r)
+	}
+}
+
+func TestParseMedGen(t *testing.T) {
+	names := "#CUI|name|source|SUPPRESS|\n" +
+		"C0878544|Cardiomyopathy|MONDO|N|\n" +
+		"C0007194|Hypertrophic cardiomyopathy|MONDO|N|\n" +
+		"C9999999|Suppressed|MONDO|Y|\n"
+	relations := "#CUI1|AUI1|STYPE1|REL|CUI2|AUI2|STYPE2|RELA|RUI|SAB|SL|SUPPRESS|\n" +
+		"C0878544|A1|SCUI|CHD|C0007194|A2|SCUI|isa|R1|MONDO|MONDO|N|\n" +
+		"C0007194|A2|SCUI|PAR|C0878544|A1|SCUI|inverse_isa|R2|MONDO|MONDO|N|\n" +
+		"C0878544|A1|SCUI|RO|C0007194|A2|SCUI|related_to|R3|MONDO|MONDO|N|\n" +
+		"C0878544|A1|SCUI|CHD|C9999999|A3|SCUI|isa|R4|MONDO|MONDO|N|\n"
+	h, err := ParseMedGen(strings.NewReader(names), strings.NewReader(relations))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if len(h.Conditions) != 2 {
+		t.Fatalf("expected 2 conditions, got %d", len(h.Conditions))
+	}
+	if len(h.Relations) != 1 || h.Relations[0].ParentID != "C0878544" || h.Relations[0].ChildID != "C0007194" {
+		t.Fatalf("expected one parent-child relation, got %+v", h.Relations)
+	}
+
+	if _, err := ParseMedGen(strings.NewReader("C1|x\n"), strings.NewReader("")); err == nil {
+		t.Fatalf("expected a short row rejected")
+	}
+}
@@ -0,0 +1,288 @@
+// Package pgx calls pharmacogene diplotypes from a genotype: it matches the
+// called alleles against star-allele tables, after CPIC's allele definition
+// and functionality tables, derives the metabolizer phenotype from the
+// diplotype's activity score and picks the CPIC recommendations for it.
+//
+// Only alleles defined by single-nucleotide variants can be called from
+// consumer chips and VCFs: gene deletions, duplications and hybrids, such as
+// CYP2D6*5 and *1xN, are not, so a diplotype is always reported as two
+// copies.
+package pgx
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/mkoziy/genome/exporter/internal/genotype"
+)
+
+// Phenotype is the metabolizer status of a diplotype.
+type Phenotype string
+
+// Metabolizer phenotypes, from CPIC's standardized terms.
+const (
+	PhenotypeUltrarapid    Phenotype = "ultrarapid_metabolizer"
+	PhenotypeRapid         Phenotype = "rapid_metabolizer"
+	PhenotypeNormal        Phenotype = "normal_metabolizer"
+	PhenotypeIntermediate  Phenotype = "intermediate_metabolizer"
+	PhenotypePoor          Phenotype = "poor_metabolizer"
+	PhenotypeIndeterminate Phenotype = "indeterminate"
+)
+
+// Title returns the phenotype as written in reports, e.g. "Poor metabolizer".
+func (p Phenotype) Title() string {
+	s := strings.ReplaceAll(string(p), "_", " ")
+	if s == "" {
+		return s
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}
+
+// Variant is a defining variant of an allele: the alternate allele of an SNP
+// on the forward strand of GRCh38.
+type Variant struct {
+	RsID string
+	Ref  string
+	Alt  string
+}
+
+// Allele is a star allele and the variants defining it, all of which it
+// carries. The reference allele, usually *1, has none.
+type Allele struct {
+	Name     string
+	Function string
+	// Activity is the allele's activity value; the diplotype's activity
+	// score is the sum of its two alleles'.
+	Activity float64
+	Variants []Variant
+}
+
+// Range maps activity scores from Min to Max, inclusive, to a phenotype.
+type Range struct {
+	Min, Max  float64
+	Phenotype Phenotype
+}
+
+// Recommendation is a CPIC dosing recommendation for one drug and the
+// phenotypes it applies to.
+type Recommendation struct {
+	Drug       string      `json:"drug"`
+	Phenotypes []Phenotype `json:"-"`
+	Text       string      `json:"text"`
+	// Strength is CPIC's classification of the recommendation: strong,
+	// moderate, optional or no recommendation.
+	Strength string `json:"strength"`
+	URL      string `json:"url"`
+}
+
+// Gene is the star-allele table of one pharmacogene.
+type Gene struct {
+	Symbol string
+	// Alleles lists the reference allele first.
+	Alleles         []Allele
+	Phenotypes      []Range
+	Recommendations []Recommendation
+}
+
+// Genotypes are the called alleles of a genotype by rsID, each on either
+// strand. An allele that is "" is known to be neither of the SNP's.
+type Genotypes map[string][]string
+
+// FromCalls returns the genotypes of the called markers of a raw data file.
+func FromCalls(calls []genotype.Call) Genotypes {
+	g := make(Genotypes, len(calls))
+	for _, call := range calls {
+		if !call.NoCall() && strings.HasPrefix(call.RsID, "rs") {
+			g[call.RsID] = call.Alleles()
+		}
+	}
+	return g
+}
+
+// FromVCF returns the genotypes of the sites of an annotated VCF sample, by
+// the rsID of the variant each matched, so records without an rsID count.
+// Of the sites a multi-allelic record is split into, the one whose
+// alternate allele the sample carries wins.
+func FromVCF(r *genotype.VCFResult) Genotypes {
+	g := make(Genotypes)
+	for _, a := range r.Annotations {
+		alleles := a.Site.Alleles(a.SNP)
+		if alleles == nil {
+			continue
+		}
+		if prev, ok := g[a.SNP.RsID]; !ok || slices.Contains(prev, "") {
+			g[a.SNP.RsID] = alleles
+		}
+	}
+	return g
+}
+
+// Result is the diplotype called for one gene.
+type Result struct {
+	Gene string `json:"gene"`
+	// Diplotype is e.g. *1/*2, or empty if no pair of the alleles the
+	// genotype could test for explains it.
+	Diplotype string `json:"diplotype,omitempty"`
+	// Alternatives are other diplotypes explaining the genotype as well,
+	// which unphased genotypes cannot tell apart.
+	Alternatives []string `json:"alternatives,omitempty"`
+	// Tested reports whether the genotype called any defining variant of
+	// the gene.
+	Tested        bool      `json:"tested"`
+	ActivityScore *float64  `json:"activity_score,omitempty"`
+	Phenotype     Phenotype `json:"phenotype"`
+	// Untested are the alleles a defining variant of which was not called;
+	// the diplotype assumes the genotype carries none of them.
+	Untested        []string         `json:"untested,omitempty"`
+	Recommendations []Recommendation `json:"recommendations,omitempty"`
+}
+
+// CallAll calls the diplotype of every gene, in order.
+func CallAll(genes []*Gene, g Genotypes) []Result {
+	results := make([]Result, 0, len(genes))
+	for _, gene := range genes {
+		results = append(results, gene.Call(g))
+	}
+	return results
+}
+
+// Call calls the gene's diplotype from g: the pairs of alleles, among those
+// whose defining variants were all called, that carry exactly the copies of
+// every called variant the genotype has, in table order. More than one pair
+// means the phase is unknown; if the pairs disagree on the phenotype, it is
+// indeterminate.
+func (gene *Gene) Call(g Genotypes) Result {
+	result := Result{Gene: gene.Symbol, Phenotype: PhenotypeIndeterminate}
+
+	copies := make(map[string]int)
+	for _, a := range gene.Alleles {
+		for _, v := range a.Variants {
+			if n, ok := v.copies(g[v.RsID]); ok {
+				copies[v.RsID+v.Alt] = n
+			}
+		}
+	}
+	var testable []Allele
+	for _, a := range gene.Alleles {
+		ok := true
+		for _, v := range a.Variants {
+			if _, called := copies[v.RsID+v.Alt]; !called {
+				ok = false
+			}
+		}
+		if ok {
+			testable = append(testable, a)
+		} else {
+			result.Untested = append(result.Untested, a.Name)
+		}
+	}
+	if len(copies) == 0 {
+		return result
+	}
+	result.Tested = true
+
+	type pair struct{ a, b Allele }
+	var pairs []pair
+	for i, a := range testable {
+		for _, b := range testable[i:] {
+			if explains(a, b, copies) {
+				pairs = append(pairs, pair{a, b})
+			}
+		}
+	}
+	if len(pairs) == 0 {
+		return result
+	}
+
+	first := pairs[0]
+	result.Diplotype = first.a.Name + "/" + first.b.Name
+	score := first.a.Activity + first.b.Activity
+	phenotype := gene.phenotype(score)
+	for _, p := range pairs[1:] {
+		result.Alternatives = append(result.Alternatives, p.a.Name+"/"+p.b.Name)
+		if gene.phenotype(p.a.Activity+p.b.Activity) != phenotype {
+			phenotype = PhenotypeIndeterminate
+		}
+	}
+	if phenotype == PhenotypeIndeterminate {
+		return result
+	}
+	result.ActivityScore = &score
+	result.Phenotype = phenotype
+	for _, rec := range gene.Recommendations {
+		if slices.Contains(rec.Phenotypes, phenotype) {
+			result.Recommendations = append(result.Recommendations, rec)
+		}
+	}
+	return result
+}
+
+// explains reports whether alleles a and b together carry the called copies
+// of every variant.
+func explains(a, b Allele, copies map[string]int) bool {
+	want := make(map[string]int, len(copies))
+	for _, allele := range []Allele{a, b} {
+		for _, v := range allele.Variants {
+			want[v.RsID+v.Alt]++
+		}
+	}
+	for key, n := range copies {
+		if want[key] != n {
+			return false
+		}
+	}
+	return true
+}
+
+func (gene *Gene) phenotype(score float64) Phenotype {
+	for _, r := range gene.Phenotypes {
+		if score >= r.Min && score <= r.Max {
+			return r.Phenotype
+		}
+	}
+	return PhenotypeIndeterminate
+}
+
+// copies counts v's alternate allele among alleles, complemented if they
+// were called on the opposite strand. It returns false for a missing or
+// unmatched genotype.
+func (v Variant) copies(alleles []string) (int, bool) {
+	count := func(alleles []string) (int, bool) {
+		n := 0
+		for _, a := range alleles {
+			switch a {
+			case v.Alt:
+				n++
+			case v.Ref:
+			default:
+				return 0, false
+			}
+		}
+		return n, len(alleles) > 0
+	}
+	if n, ok := count(alleles); ok {
+		return n, true
+	}
+	if complement(v.Ref) == v.Alt {
+		return 0, false
+	}
+	flipped := make([]string, len(alleles))
+	for i, a := range alleles {
+		flipped[i] = complement(a)
+	}
+	return count(flipped)
+}
+
+func complement(allele string) string {
+	switch allele {
+	case "A":
+		return "T"
+	case "T":
+		return "A"
+	case "C":
+		return "G"
+	case "G":
+		return "C"
+	}
+	return allele
+}
@@ -0,0 +1,114 @@
+package pgx
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/mkoziy/genome/exporter/internal/genotype"
+)
+
+func TestCall(t *testing.T) {
+	tests := []struct {
+		name         string
+		gene         *Gene
+		genotypes    Genotypes
+		diplotype    string
+		alternatives []string
+		phenotype    Phenotype
+		drugs        int
+	}{
+		{
+			name:      "reference",
+			gene:      cyp2c19,
+			genotypes: Genotypes{"rs4244285": {"G", "G"}, "rs4986893": {"G", "G"}, "rs12248560": {"C", "C"}},
+			diplotype: "*1/*1", phenotype: PhenotypeNormal, drugs: 1,
+		},
+		{
+			name:      "no function and increased function",
+			gene:      cyp2c19,
+			genotypes: Genotypes{"rs4244285": {"A", "G"}, "rs4986893": {"G", "G"}, "rs12248560": {"C", "T"}},
+			diplotype: "*2/*17", phenotype: PhenotypeIntermediate, drugs: 1,
+		},
+		{
+			name:      "opposite strand",
+			gene:      cyp2c19,
+			genotypes: Genotypes{"rs4244285": {"T", "T"}, "rs4986893": {"C", "C"}, "rs12248560": {"G", "G"}},
+			diplotype: "*2/*2", phenotype: PhenotypePoor, drugs: 2,
+		},
+		{
+			name:      "allele sharing a variant",
+			gene:      cyp2d6,
+			genotypes: Genotypes{"rs1065852": {"A", "A"}, "rs3892097": {"C", "T"}, "rs16947": {"G", "G"}, "rs28371706": {"G", "G"}, "rs28371725": {"C", "C"}},
+			diplotype: "*4/*10", phenotype: PhenotypeIntermediate, drugs: 2,
+		},
+		{
+			name:      "allele of two variants",
+			gene:      cyp2d6,
+			genotypes: Genotypes{"rs1065852": {"G", "G"}, "rs3892097": {"C", "C"}, "rs16947": {"A", "G"}, "rs28371706": {"G", "G"}, "rs28371725": {"C", "T"}},
+			diplotype: "*1/*41", phenotype: PhenotypeNormal, drugs: 1,
+		},
+		{
+			name:      "untested alleles assumed absent",
+			gene:      cyp2c9,
+			genotypes: Genotypes{"rs1799853": {"C", "T"}},
+			diplotype: "*1/*2", phenotype: PhenotypeIntermediate, drugs: 1,
+		},
+		{
+			name:      "nothing explains the genotype",
+			gene:      cyp2c9,
+			genotypes: Genotypes{"rs1799853": {"T", "T"}, "rs1057910": {"C", "C"}},
+			phenotype: PhenotypeIndeterminate,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := tt.gene.Call(tt.genotypes)
+			if !r.Tested || r.Diplotype != tt.diplotype || r.Phenotype != tt.phenotype ||
+				!slices.Equal(r.Alternatives, tt.alternatives) || len(r.Recommendations) != tt.drugs {
+				t.Fatalf("unexpected result: %+v", r)
+			}
+		})
+	}
+}
+
+func TestCallAmbiguousPhase(t *testing.T) {
+	gene := &Gene{
+		Symbol: "TEST",
+		Alleles: []Allele{
+			{Name: "*1", Activity: 1},
+			{Name: "*2", Activity: 0, Variants: []Variant{{RsID: "rs1", Ref: "A", Alt: "G"}}},
+			{Name: "*3", Activity: 1, Variants: []Variant{{RsID: "rs2", Ref: "C", Alt: "T"}}},
+			{Name: "*4", Activity: 0, Variants: []Variant{{RsID: "rs1", Ref: "A", Alt: "G"}, {RsID: "rs2", Ref: "C", Alt: "T"}}},
+		},
+		Phenotypes: []Range{{Min: 0, Max: 0.5, Phenotype: PhenotypePoor}, {Min: 1, Max: 2, Phenotype: PhenotypeNormal}},
+	}
+	r := gene.Call(Genotypes{"rs1": {"A", "G"}, "rs2": {"C", "T"}})
+	if r.Diplotype != "*1/*4" || !slices.Equal(r.Alternatives, []string{"*2/*3"}) {
+		t.Fatalf("expected both phases, got %+v", r)
+	}
+	if r.Phenotype != PhenotypeNormal || r.ActivityScore == nil || *r.ActivityScore != 1 {
+		t.Fatalf("expected the phases to agree on the phenotype, got %+v", r)
+	}
+
+	gene.Alleles[2].Activity = 0
+	if r := gene.Call(Genotypes{"rs1": {"A", "G"}, "rs2": {"C", "T"}}); r.Phenotype != PhenotypeIndeterminate || r.ActivityScore != nil {
+		t.Fatalf("expected disagreeing phases to be indeterminate, got %+v", r)
+	}
+}
+
+func TestCallAllFromCalls(t *testing.T) {
+	calls := []genotype.Call{
+		{RsID: "rs4244285", Genotype: "AG"},
+		{RsID: "rs1799853"},
+	}
+	results := CallAll(Genes, FromCalls(calls))
+	if len(results) != 3 {
+		t.Fatalf("expected a result per gene, got %+v", results)
+	}
+	if r := results[0]; r.Diplotype != "*1/*2" || !slices.Equal(r.Untested, []string{"*3", "*17"}) {
+		t.Fatalf("unexpected CYP2C19 result: %+v", r)
+	}
+	if results[1].Tested || results[2].Tested {
+		t.Fatalf("expected no-calls and absent markers to leave genes untested, got %+v", results[1:])
+	}
+}
@@ -0,0 +1,143 @@
+package pgx
+
+// Genes are the star-allele tables of the pharmacogenes called by default,
+// cut down to the alleles that single-nucleotide variants on common chips
+// define. Variants are on the forward strand of GRCh38, which for CYP2D6 is
+// the opposite of the gene's.
+var Genes = []*Gene{cyp2c19, cyp2c9, cyp2d6}
+
+// cyp2c19 has no activity values in CPIC's tables; the ones below encode its
+// table of function combinations, in which *1/*17 is rapid, *17/*17
+// ultrarapid and *2/*17 intermediate.
+var cyp2c19 = &Gene{
+	Symbol: "CYP2C19",
+	Alleles: []Allele{
+		{Name: "*1", Function: "normal function", Activity: 1},
+		{Name: "*2", Function: "no function", Activity: 0, Variants: []Variant{{RsID: "rs4244285", Ref: "G", Alt: "A"}}},
+		{Name: "*3", Function: "no function", Activity: 0, Variants: []Variant{{RsID: "rs4986893", Ref: "G", Alt: "A"}}},
+		{Name: "*17", Function: "increased function", Activity: 1.5, Variants: []Variant{{RsID: "rs12248560", Ref: "C", Alt: "T"}}},
+	},
+	Phenotypes: []Range{
+		{Min: 0, Max: 0, Phenotype: PhenotypePoor},
+		{Min: 0.5, Max: 1.5, Phenotype: PhenotypeIntermediate},
+		{Min: 2, Max: 2, Phenotype: PhenotypeNormal},
+		{Min: 2.5, Max: 2.5, Phenotype: PhenotypeRapid},
+		{Min: 3, Max: 3, Phenotype: PhenotypeUltrarapid},
+	},
+	Recommendations: []Recommendation{
+		{
+			Drug:       "clopidogrel",
+			Phenotypes: []Phenotype{PhenotypePoor, PhenotypeIntermediate},
+			Text:       "Avoid standard-dose clopidogrel if possible; use prasugrel or ticagrelor at standard dose if not contraindicated.",
+			Strength:   "strong",
+			URL:        "https://cpicpgx.org/guidelines/guideline-for-clopidogrel-and-cyp2c19/",
+		},
+		{
+			Drug:       "clopidogrel",
+			Phenotypes: []Phenotype{PhenotypeNormal, PhenotypeRapid, PhenotypeUltrarapid},
+			Text:       "Use the standard dose.",
+			Strength:   "strong",
+			URL:        "https://cpicpgx.org/guidelines/guideline-for-clopidogrel-and-cyp2c19/",
+		},
+		{
+			Drug:       "citalopram, escitalopram",
+			Phenotypes: []Phenotype{PhenotypeUltrarapid},
+			Text:       "Consider a clinically appropriate alternative not predominantly metabolized by CYP2C19.",
+			Strength:   "moderate",
+			URL:        "https://cpicpgx.org/guidelines/cpic-guideline-for-ssri-and-snri-antidepressants/",
+		},
+		{
+			Drug:       "citalopram, escitalopram",
+			Phenotypes: []Phenotype{PhenotypePoor},
+			Text:       "Consider a lower starting dose and slower titration than normal, or an alternative not predominantly metabolized by CYP2C19.",
+			Strength:   "moderate",
+			URL:        "https://cpicpgx.org/guidelines/cpic-guideline-for-ssri-and-snri-antidepressants/",
+		},
+	},
+}
+
+var cyp2c9 = &Gene{
+	Symbol: "CYP2C9",
+	Alleles: []Allele{
+		{Name: "*1", Function: "normal function", Activity: 1},
+		{Name: "*2", Function: "decreased function", Activity: 0.5, Variants: []Variant{{RsID: "rs1799853", Ref: "C", Alt: "T"}}},
+		{Name: "*3", Function: "no function", Activity: 0, Variants: []Variant{{RsID: "rs1057910", Ref: "A", Alt: "C"}}},
+	},
+	Phenotypes: []Range{
+		{Min: 0, Max: 0.5, Phenotype: PhenotypePoor},
+		{Min: 1, Max: 1.5, Phenotype: PhenotypeIntermediate},
+		{Min: 2, Max: 2, Phenotype: PhenotypeNormal},
+	},
+	Recommendations: []Recommendation{
+		{
+			Drug:       "celecoxib, flurbiprofen, ibuprofen, lornoxicam",
+			Phenotypes: []Phenotype{PhenotypeIntermediate},
+			Text:       "With an activity score of 1, initiate therapy with the lowest recommended starting dose; with 1.5, use the normal starting dose.",
+			Strength:   "moderate",
+			URL:        "https://cpicpgx.org/guidelines/cpic-guideline-for-nsaids-based-on-cyp2c9-genotype/",
+		},
+		{
+			Drug:       "celecoxib, flurbiprofen, ibuprofen, lornoxicam",
+			Phenotypes: []Phenotype{PhenotypePoor},
+			Text:       "Initiate therapy with 25-50% of the lowest recommended starting dose and titrate cautiously, or use an alternative not metabolized by CYP2C9.",
+			Strength:   "moderate",
+			URL:        "https://cpicpgx.org/guidelines/cpic-guideline-for-nsaids-based-on-cyp2c9-genotype/",
+		},
+	},
+}
+
+// cyp2d6 leaves out *3, *5, *6 and gene duplications, which are deletions
+// or copy-number changes. Without duplications no diplotype reaches the
+// ultrarapid range.
+var cyp2d6 = &Gene{
+	Symbol: "CYP2D6",
+	Alleles: []Allele{
+		{Name: "*1", Function: "normal function", Activity: 1},
+		{Name: "*2", Function: "normal function", Activity: 1, Variants: []Variant{{RsID: "rs16947", Ref: "G", Alt: "A"}}},
+		{Name: "*4", Function: "no function", Activity: 0, Variants: []Variant{
+			{RsID: "rs1065852", Ref: "G", Alt: "A"},
+			{RsID: "rs3892097", Ref: "C", Alt: "T"},
+		}},
+		{Name: "*10", Function: "decreased function", Activity: 0.25, Variants: []Variant{{RsID: "rs1065852", Ref: "G", Alt: "A"}}},
+		{Name: "*17", Function: "decreased function", Activity: 0.5, Variants: []Variant{{RsID: "rs28371706", Ref: "G", Alt: "A"}}},
+		{Name: "*41", Function: "decreased function", Activity: 0.5, Variants: []Variant{
+			{RsID: "rs16947", Ref: "G", Alt: "A"},
+			{RsID: "rs28371725", Ref: "C", Alt: "T"},
+		}},
+	},
+	Phenotypes: []Range{
+		{Min: 0, Max: 0, Phenotype: PhenotypePoor},
+		{Min: 0.25, Max: 1, Phenotype: PhenotypeIntermediate},
+		{Min: 1.25, Max: 2.25, Phenotype: PhenotypeNormal},
+	},
+	Recommendations: []Recommendation{
+		{
+			Drug:       "codeine",
+			Phenotypes: []Phenotype{PhenotypePoor},
+			Text:       "Avoid codeine because of possible lack of effect; if opioid use is warranted, consider a non-tramadol opioid.",
+			Strength:   "strong",
+			URL:        "https://cpicpgx.org/guidelines/guideline-for-codeine-and-cyp2d6/",
+		},
+		{
+			Drug:       "codeine",
+			Phenotypes: []Phenotype{PhenotypeIntermediate, PhenotypeNormal},
+			Text:       "Use the label-recommended age- or weight-specific dosing. In intermediate metabolizers, if no response, consider a non-tramadol opioid.",
+			Strength:   "strong",
+			URL:        "https://cpicpgx.org/guidelines/guideline-for-codeine-and-cyp2d6/",
+		},
+		{
+			Drug:       "tamoxifen",
+			Phenotypes: []Phenotype{PhenotypePoor},
+			Text:       "Consider hormonal therapy such as an aromatase inhibitor, or a higher tamoxifen dose of 40 mg/day if aromatase inhibitors are contraindicated.",
+			Strength:   "moderate",
+			URL:        "https://cpicpgx.org/guidelines/cpic-guideline-for-tamoxifen-based-on-cyp2d6-genotype/",
+		},
+		{
+			Drug:       "tamoxifen",
+			Phenotypes: []Phenotype{PhenotypeIntermediate},
+			Text:       "Consider hormonal therapy such as an aromatase inhibitor, or a higher tamoxifen dose of 40 mg/day if aromatase inhibitors are contraindicated.",
+			Strength:   "optional",
+			URL:        "https://cpicpgx.org/guidelines/cpic-guideline-for-tamoxifen-based-on-cyp2d6-genotype/",
+		},
+	},
+}
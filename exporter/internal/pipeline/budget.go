@@ -0,0 +1,131 @@
+package pipeline
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/mkoziy/genome/exporter/internal/sources/clinvar"
+)
+
+// maxErrorLog is how many failures a stage records in the run's error log;
+// the rest are only counted.
+const maxErrorLog = 100
+
+// ErrBudgetExceeded is returned by a stage that failed more often than the
+// run's ErrorBudget allows.
+var ErrBudgetExceeded = errors.New("error budget exceeded")
+
+// ErrorBudget bounds how many failed requests and records a stage tolerates
+// before the run is aborted rather than silently completing with gaps. Zero
+// or negative fields are not enforced.
+type ErrorBudget struct {
+	// MaxFailureRate is the largest fraction of records that may fail to
+	// download or map, e.g. 0.05.
+	MaxFailureRate float64 `yaml:"max_failure_rate" json:"max_failure_rate"`
+	// MinRecords is how many records must have been seen before
+	// MaxFailureRate applies, so one early failure does not abort a run.
+	MinRecords int `yaml:"min_records" json:"min_records"`
+	// MaxConsecutiveRequestErrors is how many API requests may fail in a row.
+	MaxConsecutiveRequestErrors int `yaml:"max_consecutive_request_errors" json:"max_consecutive_request_errors"`
+}
+
+// DefaultErrorBudget aborts a stage once more than 5% of at least 100 records
+// have failed or 100 requests have failed in a row.
+func DefaultErrorBudget() ErrorBudget {
+	return ErrorBudget{MaxFailureRate: 0.05, MinRecords: 100, MaxConsecutiveRequestErrors: 100}
+}
+
+// ErrorTracker counts a stage's failures against the run's ErrorBudget. It is
+// a clinvar.FailureHandler and safe for concurrent use. Once the budget is
+// exceeded every further report returns the same error.
+type ErrorTracker struct {
+	budget ErrorBudget
+
+	mu          sync.Mutex
+	succeeded   int
+	failed      int
+	errors      int
+	consecutive int
+	log         []string
+	exceeded    error
+}
+
+var _ clinvar.FailureHandler = (*ErrorTracker)(nil)
+
+// NewErrorTracker returns a tracker enforcing budget.
+func NewErrorTracker(budget ErrorBudget) *ErrorTracker {
+	return &ErrorTracker{budget: budget}
+}
+
+// Succeeded counts records that were downloaded and mapped.
+func (t *ErrorTracker) Succeeded(records int) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.succeeded += records
+	t.consecutive = 0
+	return t.exceeded
+}
+
+// Failed counts f's records as failed and records f in the error log.
+func (t *ErrorTracker) Failed(f clinvar.Failure) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.failed += f.Records
+	t.errors++
+	if f.Request {
+		t.consecutive++
+	}
+	if len(t.log) < maxErrorLog {
+		t.log = append(t.log, describeFailure(f))
+	}
+	if t.exceeded == nil {
+		t.exceeded = t.check()
+	}
+	return t.exceeded
+}
+
+// check returns ErrBudgetExceeded if the counts are over budget; callers hold mu.
+func (t *ErrorTracker) check() error {
+	b := t.budget
+	if b.MaxConsecutiveRequestErrors > 0 && t.consecutive >= b.MaxConsecutiveRequestErrors {
+		return fmt.Errorf("%w: %d consecutive requests failed", ErrBudgetExceeded, t.consecutive)
+	}
+	seen := t.succeeded + t.failed
+	if b.MaxFailureRate > 0 && seen >= b.MinRecords && seen > 0 {
+		if rate := float64(t.failed) / float64(seen); rate > b.MaxFailureRate {
+			return fmt.Errorf("%w: %d of %d records failed (%.1f%%, max %.1f%%)",
+				ErrBudgetExceeded, t.failed, seen, 100*rate, 100*b.MaxFailureRate)
+		}
+	}
+	return nil
+}
+
+// Errors returns how many failures were reported.
+func (t *ErrorTracker) Errors() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.errors
+}
+
+// Log returns the first failures reported, followed by a count of the rest.
+func (t *ErrorTracker) Log() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	log := append([]string(nil), t.log...)
+	if more := t.errors - len(t.log); more > 0 {
+		log = append(log, fmt.Sprintf("... and %d more", more))
+	}
+	return log
+}
+
+func describeFailure(f clinvar.Failure) string {
+	switch {
+	case f.Accession != "":
+		return fmt.Sprintf("map %s: %v", f.Accession, f.Err)
+	case f.Request:
+		return fmt.Sprintf("batch at %d of %q (%d records): %v", f.Start, f.Query, f.Records, f.Err)
+	default:
+		return fmt.Sprintf("%d records of %q: %v", f.Records, f.Query, f.Err)
+	}
+}
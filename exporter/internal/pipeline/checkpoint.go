@@ -0,0 +1,182 @@
+package pipeline
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/uptrace/bun"
+
+	"github.com/mkoziy/genome/exporter/internal/models"
+)
+
+// snapshot is the JSON stored in DownloadMetadata.ConfigSnapshot. Checkpoints
+// are kept only for stages that did not complete, so the next run resumes them.
+type snapshot struct {
+	Stages      []*StageReport             `json:"stages,omitempty"`
+	Checkpoints map[string]json.RawMessage `json:"checkpoints,omitempty"`
+}
+
+// RunContext is what a stage sees of the run executing it.
+type RunContext struct {
+	DB    *bun.DB
+	RunID string
+
+	stage string
+	state *runState
+}
+
+// runState is shared by the stages of one run.
+type runState struct {
+	mu          sync.Mutex
+	db          *bun.DB
+	meta        *models.DownloadMetadata
+	budget      ErrorBudget
+	resume      map[string]json.RawMessage
+	checkpoints map[string]json.RawMessage
+	trackers    map[string]*ErrorTracker
+}
+
+// tracker returns the stage's ErrorTracker, creating it if create is set.
+func (s *runState) tracker(stage string, create bool) *ErrorTracker {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t := s.trackers[stage]
+	if t == nil && create {
+		t = NewErrorTracker(s.budget)
+		s.trackers[stage] = t
+	}
+	return t
+}
+
+// Resume decodes into v the checkpoint the stage saved in the most recent run
+// that included it, if that run did not complete the stage. It reports whether
+// there was one.
+func (r *RunContext) Resume(v any) (bool, error) {
+	data, ok := r.state.resume[r.stage]
+	if !ok {
+		return false, nil
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return false, fmt.Errorf("decode %s checkpoint: %w", r.stage, err)
+	}
+	return true, nil
+}
+
+// Checkpoint records v as the stage's resume point and persists it with the
+// run, so it survives the process being killed. It is written even if ctx is
+// cancelled, since a shutting-down stage saves its last resume point then.
+func (r *RunContext) Checkpoint(ctx context.Context, v any) error {
+	ctx = context.WithoutCancel(ctx)
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("encode %s checkpoint: %w", r.stage, err)
+	}
+
+	s := r.state
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.checkpoints[r.stage] = data
+	return s.save(ctx, nil)
+}
+
+// Errors returns the tracker counting the stage's failures against the run's
+// ErrorBudget. Its count and log are added to the stage's report.
+func (r *RunContext) Errors() *ErrorTracker {
+	return r.state.tracker(r.stage, true)
+}
+
+// LastSuccess returns the start time of the latest earlier run in which the
+// stage completed. Anything modified after it may not have been downloaded, so
+// it is the lower bound for an incremental fetch.
+func (r *RunContext) LastSuccess(ctx context.Context) (time.Time, bool, error) {
+	return lastSuccess(ctx, r.DB, r.stage, r.state.meta.ID)
+}
+
+// save writes the snapshot to the run's row; callers hold mu.
+func (s *runState) save(ctx context.Context, stages []*StageReport) error {
+	data, err := json.Marshal(snapshot{Stages: stages, Checkpoints: s.checkpoints})
+	if err != nil {
+		return err
+	}
+	encoded := string(data)
+	s.meta.ConfigSnapshot = &encoded
+	_, err = s.db.NewUpdate().Model(s.meta).Column("config_snapshot").WherePK().Exec(ctx)
+	return err
+}
+
+// loadCheckpoints returns, per stage, the checkpoint left by the latest run
+// that included the stage.
+func loadCheckpoints(ctx context.Context, db *bun.DB, stages []string) (map[string]json.RawMessage, error) {
+	resume := make(map[string]json.RawMessage)
+	for _, stage := range stages {
+		var last models.DownloadMetadata
+		err := db.NewSelect().
+			Model(&last).
+			Where("',' || dm.source || ',' LIKE ?", "%,"+stage+",%").
+			OrderExpr("dm.id DESC").
+			Limit(1).
+			Scan(ctx)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("load %s checkpoint: %w", stage, err)
+		}
+		if last.ConfigSnapshot == nil {
+			continue
+		}
+
+		var snap snapshot
+		if err := json.Unmarshal([]byte(*last.ConfigSnapshot), &snap); err != nil {
+			continue
+		}
+		if data, ok := snap.Checkpoints[stage]; ok {
+			resume[stage] = data
+		}
+	}
+	return resume, nil
+}
+
+// LastSuccess returns the start time of the latest run in which stage
+// completed, for planning an incremental fetch outside a run.
+func LastSuccess(ctx context.Context, db *bun.DB, stage string) (time.Time, bool, error) {
+	return lastSuccess(ctx, db, stage, math.MaxInt64)
+}
+
+// lastSuccess scans the runs before runID that included stage, newest first,
+// for one whose snapshot records the stage as completed.
+func lastSuccess(ctx context.Context, db *bun.DB, stage string, runID int64) (time.Time, bool, error) {
+	var runs []*models.DownloadMetadata
+	err := db.NewSelect().
+		Model(&runs).
+		Column("dm.id", "dm.start_time", "dm.config_snapshot").
+		Where("',' || dm.source || ',' LIKE ?", "%,"+stage+",%").
+		Where("dm.id < ?", runID).
+		OrderExpr("dm.id DESC").
+		Scan(ctx)
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("load %s history: %w", stage, err)
+	}
+
+	for _, run := range runs {
+		if run.ConfigSnapshot == nil {
+			continue
+		}
+		var snap snapshot
+		if err := json.Unmarshal([]byte(*run.ConfigSnapshot), &snap); err != nil {
+			continue
+		}
+		for _, r := range snap.Stages {
+			if r.Name == stage && r.Status == StatusCompleted {
+				return run.StartTime, true, nil
+			}
+		}
+	}
+	return time.Time{}, false, nil
+}
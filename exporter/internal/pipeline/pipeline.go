@@ -0,0 +1,472 @@
+package pipeline
+
+import (
+	"cmp"
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"strings"
+	"time"
+
+	"github.com/uptrace/bun"
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/mkoziy/genome/exporter/internal/attribution"
+	"github.com/mkoziy/genome/exporter/internal/database"
+	"github.com/mkoziy/genome/exporter/internal/logging"
+	"github.com/mkoziy/genome/exporter/internal/models"
+	"github.com/mkoziy/genome/exporter/internal/repositories"
+	"github.com/mkoziy/genome/exporter/internal/stats"
+	"github.com/mkoziy/genome/exporter/internal/summary"
+	"github.com/mkoziy/genome/exporter/internal/tracing"
+)
+
+// Run and stage statuses recorded in DownloadMetadata and StageReport.
+const (
+	StatusRunning     = "running"
+	StatusCompleted   = "completed"
+	StatusFailed      = "failed"
+	StatusInterrupted = "interrupted"
+	StatusSkipped     = "skipped"
+	StatusDisabled    = "disabled"
+)
+
+// Stage is one source or processing step. Stages whose dependencies have all
+// finished run concurrently with each other.
+type Stage struct {
+	Name      string
+	DependsOn []string
+	Run       func(ctx context.Context, run *RunContext) (StageResult, error)
+	// Plan, if set, estimates the stage before the run starts. It may read
+	// earlier runs from db, e.g. to plan an incremental download.
+	Plan func(ctx context.Context, db *bun.DB) (SourcePlan, error)
+}
+
+// StageResult counts what a stage did; the counts of all stages are summed into
+// the run's DownloadMetadata.
+type StageResult struct {
+	Downloaded int `json:"downloaded"`
+	Updated    int `json:"updated"`
+	Skipped    int `json:"skipped"`
+	Errors     int `json:"errors"`
+}
+
+// StageReport is the outcome of one stage in a run.
+type StageReport struct {
+	Name     string        `json:"name"`
+	Status   string        `json:"status"`
+	Result   StageResult   `json:"result"`
+	Duration time.Duration `json:"duration"`
+	Error    string        `json:"error,omitempty"`
+	// ErrorLog lists the failures the stage tolerated or aborted on.
+	ErrorLog []string `json:"error_log,omitempty"`
+}
+
+// Report is the outcome of a run. Stages are listed in pipeline order. Plan
+// is set when the run was planned against a PlanBudget.
+type Report struct {
+	Metadata *models.DownloadMetadata `json:"metadata"`
+	Stages   []*StageReport           `json:"stages"`
+	Plan     *Plan                    `json:"plan,omitempty"`
+	// Spectrum is the allele frequency spectrum of the database a completed
+	// run leaves.
+	Spectrum *stats.FrequencySpectrum `json:"spectrum,omitempty"`
+	// Release is the dataset release a completed run records.
+	Release *models.DatasetRelease `json:"release,omitempty"`
+}
+
+// Config selects which stages run. Stages missing from Enabled run; a disabled
+// stage counts as satisfied for its dependents, so scoring can be rerun
+// without downloading again. Stages resume from the checkpoint of an
+// interrupted run unless Restart is set. ErrorBudget bounds the failures each
+// stage tolerates; the zero budget tolerates any number. PlanBudget bounds
+// the requests and time each source is estimated to need.
+type Config struct {
+	Enabled     map[string]bool `yaml:"enabled" json:"enabled"`
+	Restart     bool            `yaml:"restart" json:"restart"`
+	ErrorBudget ErrorBudget     `yaml:"error_budget" json:"error_budget"`
+	PlanBudget  PlanBudget      `yaml:"plan_budget" json:"plan_budget"`
+}
+
+func (c Config) enabled(name string) bool {
+	enabled, ok := c.Enabled[name]
+	return !ok || enabled
+}
+
+// Pipeline runs stages as a dependency graph.
+type Pipeline struct {
+	db     *bun.DB
+	stages []Stage
+	now    func() time.Time
+}
+
+// New validates that stage names are unique and that dependencies exist and
+// form no cycle.
+func New(db *bun.DB, stages ...Stage) (*Pipeline, error) {
+	byName := make(map[string]Stage, len(stages))
+	for _, stage := range stages {
+		if stage.Name == "" || stage.Run == nil {
+			return nil, errors.New("stage needs a name and a run func")
+		}
+		if _, ok := byName[stage.Name]; ok {
+			return nil, fmt.Errorf("duplicate stage %q", stage.Name)
+		}
+		byName[stage.Name] = stage
+	}
+	for _, stage := range stages {
+		for _, dep := range stage.DependsOn {
+			if _, ok := byName[dep]; !ok {
+				return nil, fmt.Errorf("stage %q depends on unknown stage %q", stage.Name, dep)
+			}
+		}
+	}
+
+	// Depth-first search; a stage reached again while on the stack closes a cycle.
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	state := make(map[string]int, len(stages))
+	var visit func(name string) error
+	visit = func(name string) error {
+		switch state[name] {
+		case visiting:
+			return fmt.Errorf("dependency cycle through stage %q", name)
+		case visited:
+			return nil
+		}
+		state[name] = visiting
+		for _, dep := range byName[name].DependsOn {
+			if err := visit(dep); err != nil {
+				return err
+			}
+		}
+		state[name] = visited
+		return nil
+	}
+	for _, stage := range stages {
+		if err := visit(stage.Name); err != nil {
+			return nil, err
+		}
+	}
+
+	return &Pipeline{db: db, stages: stages, now: time.Now}, nil
+}
+
+// Run executes every enabled stage once its dependencies have finished and
+// records the run as a single DownloadMetadata row. A failed stage skips its
+// dependents but not unrelated stages. The returned error joins the errors of
+// all failed stages.
+//
+// With a PlanBudget the run is planned first and fails with ErrOverBudget,
+// without being recorded, if a source would exceed it.
+//
+// Cancelling ctx shuts the run down gracefully: running stages are expected to
+// commit what they have and checkpoint before returning, stages not yet
+// started are skipped, and the run is recorded as interrupted so the next run
+// resumes it.
+func (p *Pipeline) Run(ctx context.Context, cfg Config) (_ *Report, err error) {
+	for name := range cfg.Enabled {
+		if !p.has(name) {
+			return nil, fmt.Errorf("unknown stage %q", name)
+		}
+	}
+
+	var plan *Plan
+	if cfg.PlanBudget.enabled() {
+		if plan, err = p.Plan(ctx, cfg); err != nil {
+			return nil, err
+		}
+		if err := cfg.PlanBudget.Check(plan); err != nil {
+			return nil, err
+		}
+	}
+
+	// A bulk load that died before rebuilding its indexes is finished first.
+	if n, err := database.RestoreIndexes(ctx, p.db); err != nil {
+		return nil, err
+	} else if n > 0 {
+		slog.WarnContext(ctx, "Rebuilt indexes dropped by an interrupted bulk load", "indexes", n)
+	}
+
+	var sources []string
+	for _, stage := range p.stages {
+		if cfg.enabled(stage.Name) {
+			sources = append(sources, stage.Name)
+		}
+	}
+	meta := &models.DownloadMetadata{
+		RunID:     newRunID(p.now()),
+		Source:    strings.Join(sources, ","),
+		StartTime: p.now(),
+		Status:    StatusRunning,
+	}
+	ctx = logging.With(ctx, logging.KeyRunID, meta.RunID)
+	ctx, span := tracing.Start(ctx, "pipeline.run", attribute.String(logging.KeyRunID, meta.RunID), attribute.String("stages", meta.Source))
+	defer func() { tracing.End(span, err) }()
+	state := &runState{
+		db:          p.db,
+		meta:        meta,
+		budget:      cfg.ErrorBudget,
+		checkpoints: make(map[string]json.RawMessage),
+		trackers:    make(map[string]*ErrorTracker),
+	}
+	if !cfg.Restart {
+		resume, err := loadCheckpoints(ctx, p.db, sources)
+		if err != nil {
+			return nil, err
+		}
+		state.resume = resume
+		// Carry resume points over until the stage saves a newer one, so a run
+		// interrupted again before its first checkpoint loses nothing.
+		for stage, data := range resume {
+			state.checkpoints[stage] = data
+		}
+	}
+	if _, err := p.db.NewInsert().Model(meta).Exec(ctx); err != nil {
+		return nil, fmt.Errorf("record run: %w", err)
+	}
+	// Tag the change feed with the run while it writes.
+	if err := repositories.BeginChangeRun(ctx, p.db, meta.RunID); err != nil {
+		return nil, fmt.Errorf("record run: %w", err)
+	}
+	slog.InfoContext(ctx, "Run started", "stages", meta.Source)
+
+	reports := p.execute(ctx, cfg, state)
+
+	report := &Report{Metadata: meta, Stages: make([]*StageReport, len(p.stages)), Plan: plan}
+	var errs []error
+	var errLog []string
+	for i, stage := range p.stages {
+		r := reports[stage.Name]
+		report.Stages[i] = r
+		meta.SNPsDownloaded += r.Result.Downloaded
+		meta.SNPsUpdated += r.Result.Updated
+		meta.SNPsSkipped += r.Result.Skipped
+		meta.ErrorsCount += r.Result.Errors
+		for _, line := range r.ErrorLog {
+			errLog = append(errLog, r.Name+": "+line)
+		}
+		if r.Status == StatusFailed {
+			meta.ErrorsCount++
+			errs = append(errs, fmt.Errorf("%s: %s", r.Name, r.Error))
+			errLog = append(errLog, r.Name+": "+r.Error)
+		}
+	}
+
+	end := p.now()
+	meta.EndTime = &end
+	meta.Status = StatusCompleted
+	if len(errs) > 0 {
+		meta.Status = StatusFailed
+	}
+	if len(errLog) > 0 {
+		errorLog := strings.Join(errLog, "\n")
+		meta.ErrorLog = &errorLog
+	}
+	if err := ctx.Err(); err != nil {
+		meta.Status = StatusInterrupted
+		errs = append(errs, fmt.Errorf("run interrupted: %w", err))
+	}
+
+	// Record the outcome even when ctx was cancelled mid-run.
+	ctx = context.WithoutCancel(ctx)
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	for _, r := range report.Stages {
+		if r.Status == StatusCompleted {
+			delete(state.checkpoints, r.Name)
+		}
+	}
+	if err := state.save(ctx, report.Stages); err != nil {
+		errs = append(errs, fmt.Errorf("record run: %w", err))
+	}
+	if err := repositories.EndChangeRun(ctx, p.db); err != nil {
+		errs = append(errs, fmt.Errorf("record run: %w", err))
+	}
+	// Stages named after a data source fetch it; the attribution manifest
+	// reports when each source was last accessed.
+	for _, r := range report.Stages {
+		if r.Status == StatusCompleted && models.DataSource(r.Name).IsValid() {
+			if err := attribution.RecordAccess(ctx, p.db, models.DataSource(r.Name), meta.StartTime); err != nil {
+				errs = append(errs, fmt.Errorf("record %s access: %w", r.Name, err))
+			}
+		}
+	}
+	// Completed runs refresh the summary tables and report the frequency
+	// spectrum they leave, to sanity-check the release against the last.
+	if meta.Status == StatusCompleted {
+		if err := summary.Refresh(ctx, p.db); err != nil {
+			errs = append(errs, fmt.Errorf("refresh summaries: %w", err))
+		} else if report.Spectrum, err = stats.GetFrequencySpectrum(ctx, p.db); err != nil {
+			errs = append(errs, fmt.Errorf("frequency spectrum: %w", err))
+		}
+		if report.Release, err = p.release(ctx, report); err != nil {
+			errs = append(errs, fmt.Errorf("record release: %w", err))
+		}
+	}
+	if _, err := p.db.NewUpdate().Model(meta).
+		Column("end_time", "status", "snps_downloaded", "snps_updated", "snps_skipped", "errors_count", "error_log").
+		WherePK().
+		Exec(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("record run: %w", err))
+	}
+
+	slog.InfoContext(ctx, "Run finished", "status", meta.Status, "duration", end.Sub(meta.StartTime),
+		"downloaded", meta.SNPsDownloaded, "updated", meta.SNPsUpdated, "skipped", meta.SNPsSkipped, "errors", meta.ErrorsCount)
+	span.SetAttributes(attribute.String("status", meta.Status))
+	return report, errors.Join(errs...)
+}
+
+// release records the dataset release a completed run leaves, under the
+// version following the current release's. Each source the run fetched is
+// versioned by the version data_sources records for it, or else the day of
+// the run; the others keep their versions from the current release.
+func (p *Pipeline) release(ctx context.Context, report *Report) (*models.DatasetRelease, error) {
+	versions := make(map[string]string)
+	current, err := repositories.GetCurrentRelease(ctx, p.db)
+	switch {
+	case err == nil:
+		maps.Copy(versions, current.SourceVersions)
+	case !errors.Is(err, sql.ErrNoRows):
+		return nil, err
+	}
+	stored, err := repositories.ListSources(ctx, p.db)
+	if err != nil {
+		return nil, err
+	}
+	apiVersions := make(map[string]string, len(stored))
+	for _, s := range stored {
+		if s.APIVersion != nil {
+			apiVersions[s.SourceName] = *s.APIVersion
+		}
+	}
+	for _, r := range report.Stages {
+		if r.Status == StatusCompleted && models.DataSource(r.Name).IsValid() {
+			versions[r.Name] = cmp.Or(apiVersions[r.Name], report.Metadata.StartTime.UTC().Format(time.DateOnly))
+		}
+	}
+
+	version, err := repositories.NextReleaseVersion(ctx, p.db)
+	if err != nil {
+		return nil, err
+	}
+	return repositories.CreateRelease(ctx, p.db, version, versions)
+}
+
+func (p *Pipeline) has(name string) bool {
+	for _, stage := range p.stages {
+		if stage.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// execute runs the stages, launching each as soon as it is ready.
+func (p *Pipeline) execute(ctx context.Context, cfg Config, state *runState) map[string]*StageReport {
+	reports := make(map[string]*StageReport, len(p.stages))
+	pending := make(map[string]bool, len(p.stages))
+	for _, stage := range p.stages {
+		pending[stage.Name] = true
+	}
+
+	done := make(chan *StageReport)
+	running := 0
+	for len(pending) > 0 || running > 0 {
+		launched := false
+		for _, stage := range p.stages {
+			if !pending[stage.Name] || !ready(stage, reports) {
+				continue
+			}
+			delete(pending, stage.Name)
+			launched = true
+
+			if !cfg.enabled(stage.Name) {
+				reports[stage.Name] = &StageReport{Name: stage.Name, Status: StatusDisabled}
+				continue
+			}
+			if ctx.Err() != nil {
+				reports[stage.Name] = &StageReport{Name: stage.Name, Status: StatusSkipped, Error: "run interrupted"}
+				continue
+			}
+			if dep := failedDependency(stage, reports); dep != "" {
+				reports[stage.Name] = &StageReport{Name: stage.Name, Status: StatusSkipped, Error: "dependency " + dep + " did not complete"}
+				continue
+			}
+
+			running++
+			go func(stage Stage) {
+				ctx := logging.With(ctx, logging.KeySource, stage.Name)
+				ctx, span := tracing.Start(ctx, "pipeline.stage", attribute.String("stage", stage.Name))
+				start := p.now()
+				run := &RunContext{DB: p.db, RunID: state.meta.RunID, stage: stage.Name, state: state}
+				slog.InfoContext(ctx, "Stage started")
+				result, err := stage.Run(ctx, run)
+				r := &StageReport{Name: stage.Name, Status: StatusCompleted, Result: result, Duration: p.now().Sub(start)}
+				if t := state.tracker(stage.Name, false); t != nil {
+					r.Result.Errors += t.Errors()
+					r.ErrorLog = t.Log()
+				}
+				switch {
+				case err != nil && ctx.Err() != nil:
+					r.Status, r.Error = StatusInterrupted, err.Error()
+				case err != nil:
+					r.Status, r.Error = StatusFailed, err.Error()
+				}
+				span.SetAttributes(attribute.String("status", r.Status), attribute.Int("downloaded", r.Result.Downloaded),
+					attribute.Int("updated", r.Result.Updated), attribute.Int("errors", r.Result.Errors))
+				tracing.End(span, err)
+				if r.Error != "" {
+					slog.ErrorContext(ctx, "Stage did not complete", "status", r.Status, "duration", r.Duration, "errors", r.Result.Errors, "error", r.Error)
+				} else {
+					slog.InfoContext(ctx, "Stage completed", "duration", r.Duration, "downloaded", r.Result.Downloaded,
+						"updated", r.Result.Updated, "skipped", r.Result.Skipped, "errors", r.Result.Errors)
+				}
+				done <- r
+			}(stage)
+		}
+		if launched {
+			continue
+		}
+
+		r := <-done
+		running--
+		reports[r.Name] = r
+	}
+	return reports
+}
+
+// ready reports whether every dependency of stage has finished.
+func ready(stage Stage, reports map[string]*StageReport) bool {
+	for _, dep := range stage.DependsOn {
+		if reports[dep] == nil {
+			return false
+		}
+	}
+	return true
+}
+
+// failedDependency returns the first dependency of stage that failed or was
+// skipped, or "".
+func failedDependency(stage Stage, reports map[string]*StageReport) string {
+	for _, dep := range stage.DependsOn {
+		if status := reports[dep].Status; status == StatusFailed || status == StatusInterrupted || status == StatusSkipped {
+			return dep
+		}
+	}
+	return ""
+}
+
+// newRunID returns a sortable, unique identifier such as 20250101T120000Z-1a2b3c4d.
+func newRunID(now time.Time) string {
+	suffix := make([]byte, 4)
+	_, _ = rand.Read(suffix)
+	return now.UTC().Format("20060102T150405Z") + "-" + hex.EncodeToString(suffix)
+}
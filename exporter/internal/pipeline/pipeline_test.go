@@ -0,0 +1,680 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/uptrace/bun"
+
+	"github.com/mkoziy/genome/exporter/internal/database"
+	"github.com/mkoziy/genome/exporter/internal/liftover"
+	"github.com/mkoziy/genome/exporter/internal/migrations"
+	"github.com/mkoziy/genome/exporter/internal/models"
+	"github.com/mkoziy/genome/exporter/internal/ratelimit"
+	"github.com/mkoziy/genome/exporter/internal/repositories"
+	"github.com/mkoziy/genome/exporter/internal/sources"
+	"github.com/mkoziy/genome/exporter/internal/sources/clinvar"
+)
+
+func newTestDB(t *testing.T) *bun.DB {
+	t.Helper()
+	db, err := database.NewDB("file:"+t.Name()+"?mode=memory&cache=shared", false)
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	if err := migrations.RunMigrations(context.Background(), db); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	return db
+}
+
+// recorder records the order in which stages finish.
+type recorder struct {
+	mu    sync.Mutex
+	order []string
+}
+
+func (r *recorder) stage(name string, deps []string, result StageResult, err error) Stage {
+	return Stage{
+		Name:      name,
+		DependsOn: deps,
+		Run: func(ctx context.Context, run *RunContext) (StageResult, error) {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			r.order = append(r.order, name)
+			return result, err
+		},
+	}
+}
+
+func TestNewRejectsInvalidGraphs(t *testing.T) {
+	run := func(context.Context, *RunContext) (StageResult, error) { return StageResult{}, nil }
+	cases := map[string][]Stage{
+		"duplicate": {{Name: "a", Run: run}, {Name: "a", Run: run}},
+		"unknown":   {{Name: "a", DependsOn: []string{"b"}, Run: run}},
+		"cycle": {
+			{Name: "a", DependsOn: []string{"c"}, Run: run},
+			{Name: "b", DependsOn: []string{"a"}, Run: run},
+			{Name: "c", DependsOn: []string{"b"}, Run: run},
+		},
+	}
+	for name, stages := range cases {
+		if _, err := New(nil, stages...); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestRunOrdersStagesAndRecordsMetadata(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	rec := &recorder{}
+
+	p, err := New(db,
+		rec.stage("score", []string{"enrich"}, StageResult{Updated: 3}, nil),
+		rec.stage("enrich", []string{"download"}, StageResult{Updated: 2}, nil),
+		rec.stage("download", nil, StageResult{Downloaded: 5, Errors: 1}, nil),
+	)
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	report, err := p.Run(ctx, Config{})
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+
+	if got := strings.Join(rec.order, ","); got != "download,enrich,score" {
+		t.Fatalf("expected dependency order, got %s", got)
+	}
+	for _, stage := range report.Stages {
+		if stage.Status != StatusCompleted {
+			t.Fatalf("expected %s completed, got %s", stage.Name, stage.Status)
+		}
+	}
+
+	var meta models.DownloadMetadata
+	if err := db.NewSelect().Model(&meta).Where("run_id = ?", report.Metadata.RunID).Scan(ctx); err != nil {
+		t.Fatalf("load metadata: %v", err)
+	}
+	if meta.Status != StatusCompleted || meta.EndTime == nil {
+		t.Fatalf("expected completed run with end time, got %+v", meta)
+	}
+	if meta.Source != "score,enrich,download" {
+		t.Fatalf("expected all stages listed as sources, got %q", meta.Source)
+	}
+	if meta.SNPsDownloaded != 5 || meta.SNPsUpdated != 5 || meta.ErrorsCount != 1 {
+		t.Fatalf("expected summed counts, got %+v", meta)
+	}
+	if meta.ConfigSnapshot == nil || !strings.Contains(*meta.ConfigSnapshot, `"name":"enrich"`) {
+		t.Fatalf("expected stage reports in snapshot, got %v", meta.ConfigSnapshot)
+	}
+}
+
+func TestRunExecutesIndependentStagesConcurrently(t *testing.T) {
+	db := newTestDB(t)
+
+	// Each stage waits for the other to start; run sequentially they would time out.
+	var started sync.WaitGroup
+	started.Add(2)
+	waitForBoth := func(context.Context, *RunContext) (StageResult, error) {
+		started.Done()
+		done := make(chan struct{})
+		go func() {
+			started.Wait()
+			close(done)
+		}()
+		select {
+		case <-done:
+			return StageResult{}, nil
+		case <-time.After(2 * time.Second):
+			return StageResult{}, errors.New("ran alone")
+		}
+	}
+
+	p, err := New(db, Stage{Name: "dbsnp", Run: waitForBoth}, Stage{Name: "gnomad", Run: waitForBoth})
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	if _, err := p.Run(context.Background(), Config{}); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+}
+
+func TestRunSkipsDependentsOfFailedStages(t *testing.T) {
+	db := newTestDB(t)
+	rec := &recorder{}
+
+	p, err := New(db,
+		rec.stage("download", nil, StageResult{}, errors.New("boom")),
+		rec.stage("other", nil, StageResult{Downloaded: 1}, nil),
+		rec.stage("score", []string{"download"}, StageResult{}, nil),
+	)
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	report, err := p.Run(context.Background(), Config{})
+	if err == nil || !strings.Contains(err.Error(), "boom") {
+		t.Fatalf("expected stage error, got %v", err)
+	}
+
+	statuses := make(map[string]string)
+	for _, stage := range report.Stages {
+		statuses[stage.Name] = stage.Status
+	}
+	if statuses["download"] != StatusFailed || statuses["other"] != StatusCompleted || statuses["score"] != StatusSkipped {
+		t.Fatalf("unexpected statuses: %v", statuses)
+	}
+	if report.Metadata.Status != StatusFailed || report.Metadata.ErrorLog == nil {
+		t.Fatalf("expected failed run with error log, got %+v", report.Metadata)
+	}
+	if report.Release != nil {
+		t.Fatalf("expected no release of a failed run, got %+v", report.Release)
+	}
+}
+
+func TestRunRecordsRelease(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	rec := &recorder{}
+
+	p, err := New(db,
+		rec.stage("clinvar", nil, StageResult{Downloaded: 1}, nil),
+		rec.stage("score", []string{"clinvar"}, StageResult{Updated: 1}, nil),
+	)
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	report, err := p.Run(ctx, Config{})
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	day := report.Metadata.StartTime.UTC().Format(time.DateOnly)
+	if r := report.Release; r == nil || r.Version != "1.0.0" || len(r.SourceVersions) != 1 || r.SourceVersions["clinvar"] != day {
+		t.Fatalf("expected release 1.0.0 of the clinvar of %s, got %+v", day, r)
+	}
+
+	// Rescoring alone keeps the version of the source it did not fetch.
+	if _, err := db.NewUpdate().Model((*models.SourceMetadata)(nil)).
+		Set("api_version = ?", "2024-05").
+		Where("source_name = ?", "clinvar").
+		Exec(ctx); err != nil {
+		t.Fatalf("set api version: %v", err)
+	}
+	report, err = p.Run(ctx, Config{Enabled: map[string]bool{"clinvar": false}})
+	if err != nil {
+		t.Fatalf("rescore: %v", err)
+	}
+	if r := report.Release; r == nil || r.Version != "1.0.1" || r.SourceVersions["clinvar"] != day {
+		t.Fatalf("expected release 1.0.1 keeping the clinvar of %s, got %+v", day, r)
+	}
+
+	report, err = p.Run(ctx, Config{})
+	if err != nil {
+		t.Fatalf("rerun: %v", err)
+	}
+	if r := report.Release; r == nil || r.Version != "1.0.2" || r.SourceVersions["clinvar"] != "2024-05" {
+		t.Fatalf("expected release 1.0.2 of clinvar 2024-05, got %+v", r)
+	}
+	current, err := repositories.GetCurrentRelease(ctx, db)
+	if err != nil || current.Version != "1.0.2" {
+		t.Fatalf("expected 1.0.2 current, got %+v, %v", current, err)
+	}
+}
+
+func TestRunDisabledStageDoesNotBlockDependents(t *testing.T) {
+	db := newTestDB(t)
+	rec := &recorder{}
+
+	p, err := New(db,
+		rec.stage("download", nil, StageResult{}, nil),
+		rec.stage("score", []string{"download"}, StageResult{}, nil),
+	)
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	report, err := p.Run(context.Background(), Config{Enabled: map[string]bool{"download": false}})
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if got := strings.Join(rec.order, ","); got != "score" {
+		t.Fatalf("expected only score to run, got %s", got)
+	}
+	if report.Stages[0].Status != StatusDisabled || report.Metadata.Source != "score" {
+		t.Fatalf("expected download disabled, got %+v / %q", report.Stages[0], report.Metadata.Source)
+	}
+
+	if _, err := p.Run(context.Background(), Config{Enabled: map[string]bool{"nope": false}}); err == nil {
+		t.Fatal("expected unknown stage to be rejected")
+	}
+}
+
+func TestRunResumesFromCheckpointOfInterruptedRun(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+
+	type progress struct {
+		Offset int `json:"offset"`
+	}
+	var resumed []int
+	fail := true
+	p, err := New(db, Stage{
+		Name: "download",
+		Run: func(ctx context.Context, run *RunContext) (StageResult, error) {
+			var cp progress
+			if _, err := run.Resume(&cp); err != nil {
+				return StageResult{}, err
+			}
+			resumed = append(resumed, cp.Offset)
+			if err := run.Checkpoint(ctx, progress{Offset: cp.Offset + 500}); err != nil {
+				return StageResult{}, err
+			}
+			if fail {
+				return StageResult{}, errors.New("interrupted")
+			}
+			return StageResult{}, nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+
+	run := func(cfg Config) {
+		t.Helper()
+		if _, err := p.Run(ctx, cfg); (err != nil) != fail {
+			t.Fatalf("run: unexpected error %v", err)
+		}
+	}
+	run(Config{})
+	run(Config{})
+	run(Config{Restart: true})
+	fail = false
+	run(Config{})
+	run(Config{})
+
+	// Failed runs hand their offset on; a restart ignores it; a completed run clears it.
+	if fmt.Sprint(resumed) != "[0 500 0 500 0]" {
+		t.Fatalf("unexpected resume offsets: %v", resumed)
+	}
+}
+
+func TestLastSuccessIgnoresFailedRuns(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+
+	var seen []string
+	fail := false
+	p, err := New(db, Stage{
+		Name: "download",
+		Run: func(ctx context.Context, run *RunContext) (StageResult, error) {
+			since, ok, err := run.LastSuccess(ctx)
+			if err != nil {
+				return StageResult{}, err
+			}
+			seen = append(seen, fmt.Sprint(ok, " ", since.Hour()))
+			if fail {
+				return StageResult{}, errors.New("interrupted")
+			}
+			return StageResult{}, nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	hour := 0
+	p.now = func() time.Time {
+		return time.Date(2025, 1, 1, hour, 0, 0, 0, time.UTC)
+	}
+
+	for _, h := range []int{1, 2, 3} {
+		hour = h
+		fail = h == 2
+		_, _ = p.Run(ctx, Config{})
+	}
+
+	// The failed run at 2:00 does not move the bound past the success at 1:00.
+	if strings.Join(seen, ",") != "false 0,true 1,true 1" {
+		t.Fatalf("unexpected last successes: %v", seen)
+	}
+	since, ok, err := LastSuccess(ctx, db, "download")
+	if err != nil || !ok || since.Hour() != 3 {
+		t.Fatalf("expected last success at 3:00, got %v %v %v", since, ok, err)
+	}
+}
+
+func TestRunRecordsInterruptedRunAndKeepsCheckpoint(t *testing.T) {
+	db := newTestDB(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	rec := &recorder{}
+
+	p, err := New(db,
+		Stage{
+			Name: "download",
+			Run: func(ctx context.Context, run *RunContext) (StageResult, error) {
+				cancel()
+				// The final checkpoint is saved after the cancel, as a stage
+				// flushing its last batch would.
+				if err := run.Checkpoint(ctx, map[string]int{"offset": 700}); err != nil {
+					return StageResult{}, err
+				}
+				return StageResult{Downloaded: 700}, ctx.Err()
+			},
+		},
+		rec.stage("score", []string{"download"}, StageResult{}, nil),
+	)
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+
+	report, err := p.Run(ctx, Config{})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if len(rec.order) != 0 {
+		t.Fatalf("dependent stage ran after the interrupt: %v", rec.order)
+	}
+	if s := report.Stages[0].Status; s != StatusInterrupted {
+		t.Fatalf("expected download interrupted, got %s", s)
+	}
+
+	var meta models.DownloadMetadata
+	if err := db.NewSelect().Model(&meta).Where("run_id = ?", report.Metadata.RunID).Scan(context.Background()); err != nil {
+		t.Fatalf("load metadata: %v", err)
+	}
+	if meta.Status != StatusInterrupted || meta.EndTime == nil || meta.SNPsDownloaded != 700 {
+		t.Fatalf("expected interrupted run with counts, got %+v", meta)
+	}
+	if meta.ConfigSnapshot == nil || !strings.Contains(*meta.ConfigSnapshot, `"offset":700`) {
+		t.Fatalf("expected checkpoint kept in snapshot, got %v", meta.ConfigSnapshot)
+	}
+}
+
+func TestErrorTrackerEnforcesBudget(t *testing.T) {
+	fail := clinvar.Failure{Records: 1, Err: errors.New("unmappable")}
+	request := clinvar.Failure{Records: 10, Request: true, Err: errors.New("bad gateway")}
+
+	rate := NewErrorTracker(ErrorBudget{MaxFailureRate: 0.05, MinRecords: 100})
+	_ = rate.Succeeded(90)
+	for i := 0; i < 9; i++ {
+		if err := rate.Failed(fail); err != nil {
+			t.Fatalf("failure %d: aborted before MinRecords: %v", i, err)
+		}
+	}
+	if err := rate.Failed(fail); !errors.Is(err, ErrBudgetExceeded) {
+		t.Fatalf("expected 10 of 100 records to exceed 5%%, got %v", err)
+	}
+	if err := rate.Succeeded(1000); !errors.Is(err, ErrBudgetExceeded) {
+		t.Fatalf("expected an exceeded budget to stay exceeded, got %v", err)
+	}
+
+	streak := NewErrorTracker(ErrorBudget{MaxConsecutiveRequestErrors: 3})
+	_ = streak.Failed(request)
+	_ = streak.Failed(request)
+	_ = streak.Succeeded(1)
+	_ = streak.Failed(request)
+	if err := streak.Failed(request); err != nil {
+		t.Fatalf("expected a success to reset the streak, got %v", err)
+	}
+	if err := streak.Failed(request); !errors.Is(err, ErrBudgetExceeded) {
+		t.Fatalf("expected 3 consecutive request errors to abort, got %v", err)
+	}
+	if streak.Errors() != 5 {
+		t.Fatalf("expected 5 errors, got %d", streak.Errors())
+	}
+}
+
+func TestRunRecordsToleratedErrorsAndAbortsOverBudget(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+
+	var allowed int
+	stage := Stage{
+		Name: "download",
+		Run: func(ctx context.Context, run *RunContext) (StageResult, error) {
+			errs := run.Errors()
+			for i := 0; i < 3; i++ {
+				if err := errs.Failed(clinvar.Failure{Accession: fmt.Sprintf("VCV%d", i), Records: 1, Err: errors.New("no rsID")}); err != nil {
+					return StageResult{Downloaded: 10}, err
+				}
+				allowed++
+			}
+			return StageResult{Downloaded: 10}, nil
+		},
+	}
+	p, err := New(db, stage)
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+
+	report, err := p.Run(ctx, Config{})
+	if err != nil {
+		t.Fatalf("run without budget: %v", err)
+	}
+	meta := report.Metadata
+	if meta.Status != StatusCompleted || meta.ErrorsCount != 3 {
+		t.Fatalf("expected completed run with 3 errors, got %+v", meta)
+	}
+	if meta.ErrorLog == nil || !strings.Contains(*meta.ErrorLog, "download: map VCV2: no rsID") {
+		t.Fatalf("expected tolerated errors in error log, got %v", meta.ErrorLog)
+	}
+
+	report, err = p.Run(ctx, Config{ErrorBudget: ErrorBudget{MaxFailureRate: 0.05, MinRecords: 2}})
+	if err == nil || !strings.Contains(err.Error(), ErrBudgetExceeded.Error()) {
+		t.Fatalf("expected error budget exceeded, got %v", err)
+	}
+	if report.Metadata.Status != StatusFailed || report.Metadata.ErrorsCount != 3 {
+		t.Fatalf("expected failed run counting 2 failures and the failed stage, got %+v", report.Metadata)
+	}
+	if allowed != 4 {
+		t.Fatalf("expected the second run to abort on its second failure, %d allowed overall", allowed)
+	}
+}
+
+func TestRunFailsEarlyOverPlanBudget(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	rec := &recorder{}
+
+	planned := func(stage Stage, requests int, limits ratelimit.Config) Stage {
+		stage.Plan = func(context.Context, *bun.DB) (SourcePlan, error) {
+			return NewSourcePlan([]clinvar.QueryPlan{{Query: "q", Count: 100, Requests: requests}}, limits), nil
+		}
+		return stage
+	}
+	slow := ratelimit.Config{Strategy: ratelimit.StrategyFixedDelay, FixedDelay: time.Second}
+	p, err := New(db,
+		planned(rec.stage("clinvar", nil, StageResult{}, nil), 601, slow),
+		planned(rec.stage("dbsnp", nil, StageResult{}, nil), 11, ratelimit.DefaultConfig()),
+		rec.stage("score", []string{"clinvar", "dbsnp"}, StageResult{}, nil),
+	)
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+
+	plan, err := p.Plan(ctx, Config{})
+	if err != nil {
+		t.Fatalf("plan: %v", err)
+	}
+	if len(plan.Sources) != 2 || plan.Requests != 612 || plan.Duration != 10*time.Minute {
+		t.Fatalf("expected both sources planned, as slow as clinvar, got %+v", plan)
+	}
+
+	_, err = p.Run(ctx, Config{PlanBudget: PlanBudget{MaxDuration: 5 * time.Minute, MaxRequests: 100}})
+	if !errors.Is(err, ErrOverBudget) || !strings.Contains(err.Error(), "clinvar needs 601 requests") ||
+		!strings.Contains(err.Error(), "clinvar needs at least 10m0s") || strings.Contains(err.Error(), "dbsnp") {
+		t.Fatalf("expected clinvar over both budgets, got %v", err)
+	}
+	if len(rec.order) != 0 {
+		t.Fatalf("expected no stage run, got %v", rec.order)
+	}
+	if n, err := db.NewSelect().Model((*models.DownloadMetadata)(nil)).Count(ctx); err != nil || n != 0 {
+		t.Fatalf("expected no run recorded, got %d (%v)", n, err)
+	}
+
+	// Disabling the expensive source brings the run within budget.
+	report, err := p.Run(ctx, Config{
+		Enabled:    map[string]bool{"clinvar": false},
+		PlanBudget: PlanBudget{MaxDuration: 5 * time.Minute, MaxRequests: 100},
+	})
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if report.Plan == nil || len(report.Plan.Sources) != 1 || report.Plan.Sources[0].Source != "dbsnp" {
+		t.Fatalf("expected the plan reported, got %+v", report.Plan)
+	}
+}
+
+// listSource is a registered-style source serving fixed records.
+type listSource struct {
+	records []models.SNPData
+}
+
+func (s *listSource) Name() string                   { return "list" }
+func (s *listSource) Configure(sources.Config) error { return nil }
+
+func (s *listSource) Fetch(ctx context.Context, sink sources.Sink) error {
+	for _, rec := range s.records {
+		if err := sink.Put(ctx, rec); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestSourceStageWritesAndQuarantines(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+
+	snp := func(rsID string, pos int64) *models.SNP {
+		return &models.SNP{RsID: rsID, Chromosome: "1", Position: pos, ReferenceAllele: "A",
+			AlternateAlleles: models.StringArray{"G"}, VariantType: models.VariantSNV}
+	}
+	src := &listSource{records: []models.SNPData{
+		{SNP: snp("rs1", 100)},
+		{SNP: snp("rs2", 200)},
+		{SNP: snp("rs3", 0), Source: "list"},
+	}}
+	p, err := New(db, SourceStage(src, SourceOptions{}))
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	report, err := p.Run(ctx, Config{})
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	stage := report.Stages[0]
+	if stage.Name != "list" || stage.Result.Downloaded != 2 || stage.Result.Skipped != 1 {
+		t.Fatalf("unexpected stage report: %+v", stage)
+	}
+	if report.Metadata.Source != "list" || report.Metadata.SNPsDownloaded != 2 {
+		t.Fatalf("expected the source recorded in the run, got %+v", report.Metadata)
+	}
+	if n, err := db.NewSelect().Table("quarantined_records").Where("source = 'list'").Count(ctx); err != nil || n != 1 {
+		t.Fatalf("expected the invalid record quarantined, got %d (%v)", n, err)
+	}
+}
+
+func TestLiftoverStageFillsGRCh37Coordinates(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+
+	// GRCh37 chr1:1001-2000 is GRCh38 chr1:11001-12000.
+	chain, err := liftover.Read(strings.NewReader("chain 1 chr1 5000 + 1000 2000 chr1 20000 + 11000 12000 1\n1000\n"), liftover.GRCh37, liftover.GRCh38)
+	if err != nil {
+		t.Fatalf("read chain: %v", err)
+	}
+	given, known := "1", int64(500)
+	snps := []*models.SNP{
+		{RsID: "rs1", Chromosome: "1", Position: 11500, ReferenceAllele: "A", AlternateAlleles: models.StringArray{"G"}, VariantType: models.VariantSNV},
+		{RsID: "rs2", Chromosome: "1", Position: 15000, ReferenceAllele: "A", AlternateAlleles: models.StringArray{"G"}, VariantType: models.VariantSNV},
+		{RsID: "rs3", Chromosome: "1", Position: 11600, ReferenceAllele: "A", AlternateAlleles: models.StringArray{"G"}, VariantType: models.VariantSNV,
+			GRCh37Chromosome: &given, GRCh37Position: &known},
+	}
+	if err := repositories.UpsertSNPs(ctx, db, snps); err != nil {
+		t.Fatalf("upsert: %v", err)
+	}
+
+	p, err := New(db, LiftoverStage(chain.Invert(), 0, nil))
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	report, err := p.Run(ctx, Config{})
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if stage := report.Stages[0]; stage.Result.Updated != 1 || stage.Result.Skipped != 1 {
+		t.Fatalf("expected rs1 lifted and rs2 unmapped, got %+v", stage.Result)
+	}
+	found, err := repositories.GetSNPsInGRCh37Region(ctx, db, "1", 1, 2000, repositories.SignificanceFilter{})
+	if err != nil || len(found) != 2 || found[0].RsID != "rs3" || found[1].RsID != "rs1" || *found[1].GRCh37Position != 1500 {
+		t.Fatalf("expected rs3 as given and rs1 at 1500, got %+v (%v)", found, err)
+	}
+
+	// A source moving the SNP on GRCh38 drops the coordinates lifted from
+	// the old position.
+	moved := &models.SNP{RsID: "rs1", Chromosome: "1", Position: 11501, ReferenceAllele: "A", AlternateAlleles: models.StringArray{"G"}, VariantType: models.VariantSNV}
+	if err := repositories.UpsertSNPs(ctx, db, []*models.SNP{moved}); err != nil {
+		t.Fatalf("upsert: %v", err)
+	}
+	snp, err := repositories.GetSNPByRsID(ctx, db, "rs1")
+	if err != nil || snp.GRCh37Position != nil {
+		t.Fatalf("expected the lifted coordinates cleared, got %v (%v)", snp.GRCh37Position, err)
+	}
+}
+
+// roundTripFunc serves canned ClinVar responses without a network.
+type roundTripFunc func(*http.Request) *http.Response
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) { return f(r), nil }
+
+func TestRetryFailedResolvesRecoveredItems(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+
+	transport := roundTripFunc(func(r *http.Request) *http.Response {
+		resp := &http.Response{StatusCode: http.StatusOK, Header: make(http.Header), Request: r}
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/efetch.fcgi"):
+			resp.Body = io.NopCloser(strings.NewReader(`<ClinVarResult-Set><ClinVarSet><ReferenceClinVarAssertion><ClinVarAccession Acc="VCV000000001" Version="1" Type="Variation" /><ClinicalSignificance><ReviewStatus>reviewed by expert panel</ReviewStatus><Description>Pathogenic</Description></ClinicalSignificance><MeasureSet Type="Variant"><Measure Type="SNV"><SequenceLocation Assembly="GRCh38" Chr="19" start="44908684" stop="44908684" referenceAllele="T" alternateAllele="C" /><XRef Type="rs" DB="dbSNP" ID="rs429358" /></Measure></MeasureSet></ReferenceClinVarAssertion></ClinVarSet></ClinVarResult-Set>`))
+		default:
+			// Searches are still failing.
+			resp.StatusCode = http.StatusBadGateway
+			resp.Body = io.NopCloser(strings.NewReader("bad gateway"))
+		}
+		return resp
+	})
+	client := clinvar.NewClient(ratelimit.NewLimiter(ratelimit.Config{RequestsPerSec: 1000, Burst: 100}), "", "").WithTransport(transport)
+
+	if err := repositories.EnqueueFailedItems(ctx, db, []*models.FailedItem{
+		{Source: StageClinVar, Kind: models.FailedItemUID, ItemKey: "1"},
+		{Source: StageClinVar, Kind: models.FailedItemBatch, ItemKey: "test", Start: 500},
+	}); err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+
+	result, err := RetryFailed(ctx, db, clinvar.NewFetcher(client), RetryOptions{Writer: repositories.DefaultBatchWriterConfig()})
+	if err != nil {
+		t.Fatalf("retry: %v", err)
+	}
+	if result.Retried != 2 || result.Resolved != 1 || result.Failed != 1 || result.SNPs != 1 {
+		t.Fatalf("unexpected result %+v", result)
+	}
+
+	items, err := repositories.ListFailedItems(ctx, db, StageClinVar, 0)
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(items) != 1 || items[0].Kind != models.FailedItemBatch || items[0].Attempts != 2 {
+		t.Fatalf("expected only the batch left with 2 attempts, got %+v", items)
+	}
+	if n, err := db.NewSelect().Model((*models.SNP)(nil)).Where("rsid = ?", "rs429358").Count(ctx); err != nil || n != 1 {
+		t.Fatalf("expected the recovered SNP written, got %d (%v)", n, err)
+	}
+}
@@ -0,0 +1,102 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/mkoziy/genome/exporter/internal/ratelimit"
+	"github.com/mkoziy/genome/exporter/internal/sources/clinvar"
+)
+
+// ErrOverBudget is returned by Run when the plan of a source exceeds the
+// run's PlanBudget.
+var ErrOverBudget = errors.New("plan exceeds budget")
+
+// PlanBudget bounds how many API requests and how much time each source may
+// be planned to take. A run over budget fails before it is recorded. Zero
+// fields are not enforced; with both zero the run is not planned.
+type PlanBudget struct {
+	// MaxRequests is the request quota of each source, e.g. a daily API quota.
+	MaxRequests int `yaml:"max_requests" json:"max_requests"`
+	// MaxDuration is the longest a source may take, e.g. the night a
+	// scheduled run has before the database is needed again.
+	MaxDuration time.Duration `yaml:"max_duration" json:"max_duration"`
+}
+
+func (b PlanBudget) enabled() bool {
+	return b.MaxRequests > 0 || b.MaxDuration > 0
+}
+
+// Check reports every source of plan that exceeds the budget.
+func (b PlanBudget) Check(plan *Plan) error {
+	var errs []error
+	for _, src := range plan.Sources {
+		if b.MaxRequests > 0 && src.Requests > b.MaxRequests {
+			errs = append(errs, fmt.Errorf("%w: %s needs %d requests, more than the %d allowed",
+				ErrOverBudget, src.Source, src.Requests, b.MaxRequests))
+		}
+		if b.MaxDuration > 0 && src.Duration > b.MaxDuration {
+			errs = append(errs, fmt.Errorf("%w: %s needs at least %s, more than the %s allowed",
+				ErrOverBudget, src.Source, src.Duration.Round(time.Second), b.MaxDuration))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// SourcePlan estimates what downloading one source involves. Duration is the
+// time the source's rate limit alone needs for Requests, a lower bound for
+// the real run.
+type SourcePlan struct {
+	Source   string              `json:"source"`
+	Queries  []clinvar.QueryPlan `json:"queries"`
+	Variants int                 `json:"variants"`
+	Requests int                 `json:"requests"`
+	Duration time.Duration       `json:"duration"`
+}
+
+// NewSourcePlan sums the query plans of a source limited by limits.
+func NewSourcePlan(queries []clinvar.QueryPlan, limits ratelimit.Config) SourcePlan {
+	plan := SourcePlan{Queries: queries}
+	for _, q := range queries {
+		plan.Variants += q.Count
+		plan.Requests += q.Requests
+	}
+	plan.Duration = limits.EstimateDuration(plan.Requests)
+	return plan
+}
+
+// Plan is the estimate of a run, source by source. Sources have their own
+// rate limits and run concurrently, so the run takes as long as its slowest
+// source.
+type Plan struct {
+	Sources  []SourcePlan  `json:"sources"`
+	Requests int           `json:"requests"`
+	Duration time.Duration `json:"duration"`
+}
+
+// Plan estimates every enabled stage that can be planned, which makes the
+// initial search requests of each source but downloads nothing. Checkpoints
+// of an interrupted run are not taken into account, so a resumed run is
+// planned as if it started over.
+func (p *Pipeline) Plan(ctx context.Context, cfg Config) (*Plan, error) {
+	plan := &Plan{}
+	for _, stage := range p.stages {
+		if !cfg.enabled(stage.Name) || stage.Plan == nil {
+			continue
+		}
+		src, err := stage.Plan(ctx, p.db)
+		if err != nil {
+			return nil, fmt.Errorf("plan %s: %w", stage.Name, err)
+		}
+		src.Source = stage.Name
+		plan.Sources = append(plan.Sources, src)
+		plan.Requests += src.Requests
+		plan.Duration = max(plan.Duration, src.Duration)
+		slog.InfoContext(ctx, "Source planned", "source", src.Source, "variants", src.Variants,
+			"requests", src.Requests, "duration", src.Duration)
+	}
+	return plan, nil
+}
@@ -0,0 +1,230 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+
+	"github.com/uptrace/bun"
+
+	"github.com/mkoziy/genome/exporter/internal/logging"
+	"github.com/mkoziy/genome/exporter/internal/models"
+	"github.com/mkoziy/genome/exporter/internal/repositories"
+	"github.com/mkoziy/genome/exporter/internal/sources/clinvar"
+)
+
+// failureQueue collects the failures a stage reports so they can be queued
+// for retry-failed once the stage ends, passing each report on to next.
+type failureQueue struct {
+	source string
+	runID  string
+	next   clinvar.FailureHandler
+
+	mu    sync.Mutex
+	items []*models.FailedItem
+}
+
+func newFailureQueue(source, runID string, next clinvar.FailureHandler) *failureQueue {
+	return &failureQueue{source: source, runID: runID, next: next}
+}
+
+func (q *failureQueue) Succeeded(records int) error {
+	return q.next.Succeeded(records)
+}
+
+func (q *failureQueue) Failed(f clinvar.Failure) error {
+	q.mu.Lock()
+	q.items = append(q.items, failedItems(q.source, &q.runID, f)...)
+	q.mu.Unlock()
+	return q.next.Failed(f)
+}
+
+// save queues the collected failures.
+func (q *failureQueue) save(ctx context.Context, db bun.IDB) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if err := repositories.EnqueueFailedItems(ctx, db, q.items); err != nil {
+		return fmt.Errorf("queue failed items: %w", err)
+	}
+	q.items = nil
+	return nil
+}
+
+// failedItems converts a failure into the items to retry: the record that
+// could not be mapped, each record whose fetch failed, or else the batch whose
+// search failed.
+func failedItems(source string, runID *string, f clinvar.Failure) []*models.FailedItem {
+	msg := ""
+	if f.Err != nil {
+		msg = f.Err.Error()
+	}
+	item := func(kind, key string, start int) *models.FailedItem {
+		return &models.FailedItem{Source: source, Kind: kind, ItemKey: key, Start: start, Query: f.Query, Error: msg, RunID: runID}
+	}
+	switch {
+	case f.Accession != "":
+		return []*models.FailedItem{item(models.FailedItemAccession, f.Accession, 0)}
+	case len(f.IDs) > 0:
+		items := make([]*models.FailedItem, len(f.IDs))
+		for i, id := range f.IDs {
+			items[i] = item(models.FailedItemUID, id, 0)
+		}
+		return items
+	default:
+		return []*models.FailedItem{item(models.FailedItemBatch, f.Query, f.Start)}
+	}
+}
+
+// RetryOptions controls a retry-failed pass.
+type RetryOptions struct {
+	Writer repositories.BatchWriterConfig
+	// MaxAttempts leaves out items that already failed this many times; zero
+	// retries every item.
+	MaxAttempts int
+}
+
+// RetryResult reports what a retry-failed pass did.
+type RetryResult struct {
+	Retried     int `json:"retried"`
+	Resolved    int `json:"resolved"`
+	Failed      int `json:"failed"`
+	SNPs        int `json:"snps"`
+	Quarantined int `json:"quarantined"`
+}
+
+// RetryFailed processes the queued ClinVar items again, writing the SNPs they
+// yield. Items that succeed are removed from the queue once their SNPs are
+// committed; items that fail again stay queued with one more attempt.
+// Scores of the written SNPs are marked stale as usual, so the next scoring
+// pass picks them up.
+func RetryFailed(ctx context.Context, db *bun.DB, fetcher *clinvar.Fetcher, opts RetryOptions) (RetryResult, error) {
+	ctx = logging.With(ctx, logging.KeySource, StageClinVar)
+	var result RetryResult
+	items, err := repositories.ListFailedItems(ctx, db, StageClinVar, opts.MaxAttempts)
+	if err != nil {
+		return result, fmt.Errorf("list failed items: %w", err)
+	}
+	result.Retried = len(items)
+	if len(items) == 0 {
+		return result, nil
+	}
+
+	var (
+		resolved []int64
+		again    []*models.FailedItem
+	)
+	fail := func(item *models.FailedItem, err error) {
+		slog.WarnContext(ctx, "Retry failed", "kind", item.Kind, "item", item.ItemKey, "error", err)
+		result.Failed++
+		again = append(again, &models.FailedItem{
+			Source: item.Source, Kind: item.Kind, ItemKey: item.ItemKey, Start: item.Start,
+			Query: item.Query, Error: err.Error(),
+		})
+	}
+
+	// done is closed once the source stops touching resolved and again, which
+	// after a write error may be after load returns.
+	done := make(chan struct{})
+	source := streamFunc(func(ctx context.Context, out chan<- models.SNPData) error {
+		defer close(done)
+		emit := func(data []clinvar.SNPData) error {
+			for _, d := range data {
+				select {
+				case out <- d:
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			}
+			return nil
+		}
+
+		var uids []*models.FailedItem
+		for _, item := range items {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			var (
+				data     []clinvar.SNPData
+				failures []clinvar.Failure
+				err      error
+			)
+			switch item.Kind {
+			case models.FailedItemUID:
+				uids = append(uids, item)
+				continue
+			case models.FailedItemAccession:
+				data, failures, err = fetcher.FetchSearch(ctx, item.ItemKey, 0)
+			case models.FailedItemBatch:
+				data, failures, err = fetcher.FetchSearch(ctx, item.ItemKey, item.Start)
+			default:
+				err = fmt.Errorf("unknown item kind %q", item.Kind)
+			}
+			if err == nil && len(failures) > 0 {
+				err = failures[0].Err
+			}
+			if err != nil {
+				fail(item, err)
+				continue
+			}
+			if err := emit(data); err != nil {
+				return err
+			}
+			resolved = append(resolved, item.ID)
+		}
+
+		// UIDs are fetched together. Mapping failures cannot be traced back to
+		// a UID, so they are queued by accession instead.
+		if len(uids) == 0 {
+			return nil
+		}
+		ids := make([]string, len(uids))
+		for i, item := range uids {
+			ids[i] = item.ItemKey
+		}
+		data, failures, err := fetcher.FetchIDs(ctx, ids)
+		if err != nil {
+			for _, item := range uids {
+				fail(item, err)
+			}
+			return nil
+		}
+		for _, f := range failures {
+			again = append(again, failedItems(StageClinVar, nil, f)...)
+		}
+		if err := emit(data); err != nil {
+			return err
+		}
+		for _, item := range uids {
+			resolved = append(resolved, item.ID)
+		}
+		return nil
+	})
+
+	stats, err := load(ctx, source, repositories.NewBatchWriter(db, opts.Writer))
+	<-done
+	result.SNPs = stats.SNPs
+	result.Quarantined = stats.Quarantined
+
+	// Record failures even when cancelled, but only resolve items whose SNPs
+	// were all written.
+	ctx = context.WithoutCancel(ctx)
+	if qerr := repositories.EnqueueFailedItems(ctx, db, again); qerr != nil && err == nil {
+		err = fmt.Errorf("queue failed items: %w", qerr)
+	}
+	if err != nil {
+		return result, err
+	}
+	if err := repositories.ResolveFailedItems(ctx, db, resolved); err != nil {
+		return result, fmt.Errorf("resolve failed items: %w", err)
+	}
+	result.Resolved = len(resolved)
+	return result, nil
+}
+
+// streamFunc adapts a function to SNPStreamer.
+type streamFunc func(ctx context.Context, out chan<- models.SNPData) error
+
+func (f streamFunc) StreamSignificantSNPs(ctx context.Context, out chan<- models.SNPData) error {
+	return f(ctx, out)
+}
@@ -0,0 +1,406 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+
+	"github.com/uptrace/bun"
+
+	"github.com/mkoziy/genome/exporter/internal/database"
+	"github.com/mkoziy/genome/exporter/internal/liftover"
+	"github.com/mkoziy/genome/exporter/internal/models"
+	"github.com/mkoziy/genome/exporter/internal/ratelimit"
+	"github.com/mkoziy/genome/exporter/internal/repositories"
+	"github.com/mkoziy/genome/exporter/internal/scoring"
+	"github.com/mkoziy/genome/exporter/internal/sources"
+	"github.com/mkoziy/genome/exporter/internal/sources/clinvar"
+	"github.com/mkoziy/genome/exporter/internal/translate"
+)
+
+// Stage names. Frequency (dbSNP, gnomAD) and literature (PubMed) enrichment
+// stages slot in between ClinVar and scoring as their sources are added;
+// sources registered with the sources package run as stages named after them.
+const (
+	StageClinVar         = "clinvar"
+	StageScoring         = "scoring"
+	StageTranslationSync = "translation-sync"
+	StageLiftover        = "liftover"
+	StageFrequencies     = "frequencies"
+	StageQuality         = "quality"
+)
+
+// ClinVarOptions controls the ClinVar stage.
+type ClinVarOptions struct {
+	Writer repositories.BatchWriterConfig
+	// Incremental limits the download to variants modified since the last run
+	// that completed the stage. Without such a run everything is downloaded.
+	Incremental bool
+	// Bulk loads with secondary indexes dropped and fsyncs off, rebuilding
+	// the indexes once the stage ends. It is meant for initial builds; see
+	// database.BulkLoad.
+	Bulk bool
+	// RateLimit is the limit the fetcher's client is built with, used to
+	// estimate how long the stage takes when the run is planned.
+	RateLimit ratelimit.Config
+}
+
+// ClinVarStage downloads significant variants from ClinVar, resuming from the
+// checkpoint of an interrupted run. Failed batches and records are counted
+// against the run's error budget, aborting the stage once it is exceeded;
+// until then they are skipped and queued for RetryFailed. A checkpoint is saved once the SNPs it
+// covers are committed, so resuming never skips unwritten data.
+func ClinVarStage(fetcher *clinvar.Fetcher, opts ClinVarOptions) Stage {
+	return Stage{
+		Name: StageClinVar,
+		Plan: func(ctx context.Context, db *bun.DB) (SourcePlan, error) {
+			if opts.Incremental {
+				since, ok, err := LastSuccess(ctx, db, StageClinVar)
+				if err != nil {
+					return SourcePlan{}, err
+				}
+				if ok {
+					fetcher.WithModifiedSince(since)
+				}
+			}
+			queries, err := fetcher.Plan(ctx)
+			if err != nil {
+				return SourcePlan{}, err
+			}
+			return NewSourcePlan(queries, opts.RateLimit), nil
+		},
+		Run: func(ctx context.Context, run *RunContext) (_ StageResult, err error) {
+			if opts.Incremental {
+				since, ok, err := run.LastSuccess(ctx)
+				if err != nil {
+					return StageResult{}, err
+				}
+				if ok {
+					fetcher.WithModifiedSince(since)
+				}
+			}
+
+			failures := newFailureQueue(StageClinVar, run.RunID, run.Errors())
+			fetcher.WithFailureHandler(failures)
+
+			cp := &clinvar.Checkpoint{}
+			if _, err := run.Resume(cp); err != nil {
+				return StageResult{}, err
+			}
+
+			type pendingCheckpoint struct {
+				cp      clinvar.Checkpoint
+				emitted int
+			}
+			var (
+				mu      sync.Mutex
+				pending []pendingCheckpoint
+			)
+			fetcher.WithCheckpoint(cp, func(c clinvar.Checkpoint, emitted int) {
+				mu.Lock()
+				defer mu.Unlock()
+				pending = append(pending, pendingCheckpoint{cp: c, emitted: emitted})
+			})
+
+			writer := repositories.NewBatchWriter(run.DB, opts.Writer).WithRunID(run.RunID)
+			if opts.Bulk {
+				bulk, err := database.BeginBulkLoad(ctx, run.DB)
+				if err != nil {
+					return StageResult{}, err
+				}
+				defer func() {
+					if ferr := bulk.Finish(ctx); ferr != nil {
+						err = errors.Join(err, fmt.Errorf("finish bulk load: %w", ferr))
+					}
+				}()
+				writer.WithBulkLoad(bulk.Conn())
+			}
+
+			stats, err := load(ctx, fetcher, writer.OnCommit(func(stats repositories.BatchWriteStats) {
+				mu.Lock()
+				var durable *clinvar.Checkpoint
+				for len(pending) > 0 && pending[0].emitted <= stats.SNPs+stats.Quarantined {
+					durable = &pending[0].cp
+					pending = pending[1:]
+				}
+				mu.Unlock()
+				if durable != nil {
+					if err := run.Checkpoint(ctx, durable); err != nil {
+						slog.ErrorContext(ctx, "Saving checkpoint failed", "error", err)
+					}
+				}
+			}))
+			if qerr := failures.save(context.WithoutCancel(ctx), run.DB); qerr != nil {
+				err = errors.Join(err, qerr)
+			}
+			return StageResult{Downloaded: stats.SNPs, Skipped: stats.Quarantined}, err
+		},
+	}
+}
+
+// SourceOptions controls the stage of a registered source.
+type SourceOptions struct {
+	Writer repositories.BatchWriterConfig
+}
+
+// SourceStage downloads from a source registered with the sources package,
+// writing and quarantining what it fetches as the ClinVar stage does. Such
+// sources keep no checkpoint, so an interrupted stage starts over.
+func SourceStage(src sources.Source, opts SourceOptions) Stage {
+	return Stage{
+		Name: src.Name(),
+		Run: func(ctx context.Context, run *RunContext) (StageResult, error) {
+			w := repositories.NewBatchWriter(run.DB, opts.Writer).WithRunID(run.RunID)
+			stats, err := load(ctx, sourceStreamer{src}, w)
+			return StageResult{Downloaded: stats.SNPs, Skipped: stats.Quarantined}, err
+		},
+	}
+}
+
+// sourceStreamer adapts a sources.Source to load.
+type sourceStreamer struct {
+	src sources.Source
+}
+
+func (s sourceStreamer) StreamSignificantSNPs(ctx context.Context, out chan<- models.SNPData) error {
+	return s.src.Fetch(ctx, sources.ChanSink(out))
+}
+
+// ScoringStage rescores SNPs once every download stage has finished. Unless
+// opts.Full is set only SNPs whose evidence changed are rescored, which after an
+// incremental download is just the delta.
+func ScoringStage(scorer *scoring.Scorer, opts ScoreOptions) Stage {
+	return Stage{
+		Name:      StageScoring,
+		DependsOn: []string{StageClinVar},
+		Run: func(ctx context.Context, run *RunContext) (StageResult, error) {
+			result, err := Score(ctx, run.DB, scorer, opts)
+			return StageResult{Updated: result.Scored}, err
+		},
+	}
+}
+
+// TranslationSyncStage exchanges strings with a translation platform once
+// the stages in dependsOn, the sources, have loaded the text to translate.
+// It is meant for scheduled jobs; profiles listing their stages leave it
+// out.
+func TranslationSyncStage(p translate.Platform, opts translate.SyncOptions, dependsOn []string) Stage {
+	return Stage{
+		Name:      StageTranslationSync,
+		DependsOn: dependsOn,
+		Run: func(ctx context.Context, run *RunContext) (StageResult, error) {
+			stats, err := translate.Sync(ctx, run.DB, p, opts)
+			// Translations pulled count as downloaded, strings pushed as
+			// updated.
+			result := StageResult{Updated: stats.Pushed}
+			for _, pulled := range stats.Pulled {
+				result.Downloaded += pulled.Imported + pulled.Confirmed
+				result.Skipped += len(pulled.Stale)
+			}
+			return result, err
+		},
+	}
+}
+
+// LiftoverStage fills in the GRCh37 coordinates of the SNPs the stages in
+// dependsOn, the sources, loaded without them, lifting them over from
+// GRCh38 with chain, which must map GRCh38 onto GRCh37.
+func LiftoverStage(chain *liftover.Chain, batchSize int, dependsOn []string) Stage {
+	return Stage{
+		Name:      StageLiftover,
+		DependsOn: dependsOn,
+		Run: func(ctx context.Context, run *RunContext) (StageResult, error) {
+			result, err := LiftOver(ctx, run.DB, chain, batchSize)
+			// Positions GRCh37 lacks are retried by the next run, in case
+			// the chain file is replaced.
+			return StageResult{Updated: result.Lifted, Skipped: result.Unmapped}, err
+		},
+	}
+}
+
+// LiftResult reports what a liftover pass did.
+type LiftResult struct {
+	Lifted   int `json:"lifted"`
+	Unmapped int `json:"unmapped"`
+}
+
+// LiftOver lifts the SNPs without GRCh37 coordinates over from GRCh38 with
+// chain, in batches. SNPs whose reference allele does not map in one piece
+// are left without them.
+func LiftOver(ctx context.Context, db *bun.DB, chain *liftover.Chain, batchSize int) (LiftResult, error) {
+	var result LiftResult
+	err := repositories.ForEachUnliftedSNP(ctx, db, batchSize, func(batch []*models.SNP) error {
+		lifted := make([]*models.SNP, 0, len(batch))
+		for _, snp := range batch {
+			locus, _, _, ok := chain.MapVariant(snp.Chromosome, snp.Position, snp.ReferenceAllele, snp.AlternateAlleles)
+			if !ok {
+				result.Unmapped++
+				continue
+			}
+			snp.GRCh37Chromosome, snp.GRCh37Position = &locus.Chromosome, &locus.Position
+			lifted = append(lifted, snp)
+		}
+		if err := repositories.SetGRCh37Coordinates(ctx, db, lifted); err != nil {
+			return fmt.Errorf("save GRCh37 coordinates: %w", err)
+		}
+		result.Lifted += len(lifted)
+		return nil
+	})
+	return result, err
+}
+
+// FrequencyStage reaggregates the canonical frequencies of every SNP, and
+// retests its genotype counts against Hardy–Weinberg equilibrium, once the
+// stages in dependsOn, those that load population frequencies, have
+// finished.
+func FrequencyStage(batchSize int, dependsOn []string) Stage {
+	return Stage{
+		Name:      StageFrequencies,
+		DependsOn: dependsOn,
+		Run: func(ctx context.Context, run *RunContext) (StageResult, error) {
+			aggregated, err := AggregateFrequencies(ctx, run.DB, batchSize)
+			return StageResult{Updated: aggregated}, err
+		},
+	}
+}
+
+// AggregateFrequencies recomputes and stores the canonical frequencies and
+// Hardy–Weinberg tests of every SNP, in batches, returning how many were
+// aggregated.
+func AggregateFrequencies(ctx context.Context, db *bun.DB, batchSize int) (int, error) {
+	var aggregated int
+	err := repositories.ForEachSNP(ctx, db, batchSize, func(batch []*models.SNP) error {
+		rows := make([]*models.Frequency, len(batch))
+		ids := make([]int64, len(batch))
+		var tests []*models.HardyWeinberg
+		for i, snp := range batch {
+			rows[i] = scoring.Frequencies(snp.ID, snp.PopulationData)
+			ids[i] = snp.ID
+			tests = append(tests, scoring.HardyWeinberg(snp)...)
+		}
+		if err := repositories.SaveFrequencies(ctx, db, rows); err != nil {
+			return fmt.Errorf("save frequencies: %w", err)
+		}
+		if err := repositories.SaveHardyWeinberg(ctx, db, ids, tests); err != nil {
+			return fmt.Errorf("save hardy-weinberg: %w", err)
+		}
+		aggregated += len(rows)
+		return nil
+	})
+	return aggregated, err
+}
+
+// QualityStage reassesses the quality of every SNP once the stages in
+// dependsOn, those that load or complete SNPs, have finished, so the stored
+// quality reflects the whole run.
+func QualityStage(batchSize int, dependsOn []string) Stage {
+	return Stage{
+		Name:      StageQuality,
+		DependsOn: dependsOn,
+		Run: func(ctx context.Context, run *RunContext) (StageResult, error) {
+			assessed, err := AssessQuality(ctx, run.DB, batchSize)
+			return StageResult{Updated: assessed}, err
+		},
+	}
+}
+
+// AssessQuality recomputes and stores the quality of every SNP, in batches,
+// returning how many were assessed.
+func AssessQuality(ctx context.Context, db *bun.DB, batchSize int) (int, error) {
+	var assessed int
+	err := repositories.ForEachSNP(ctx, db, batchSize, func(batch []*models.SNP) error {
+		rows := make([]*models.Quality, len(batch))
+		for i, snp := range batch {
+			rows[i] = scoring.Quality(snp)
+		}
+		if err := repositories.SaveQuality(ctx, db, rows); err != nil {
+			return fmt.Errorf("save quality: %w", err)
+		}
+		assessed += len(rows)
+		return nil
+	})
+	return assessed, err
+}
+
+// SNPStreamer is a source that sends SNPs to out until it is exhausted.
+type SNPStreamer interface {
+	StreamSignificantSNPs(ctx context.Context, out chan<- models.SNPData) error
+}
+
+// load streams SNPs from source into w, writing while later batches are still
+// downloading.
+func load(ctx context.Context, source SNPStreamer, w *repositories.BatchWriter) (repositories.BatchWriteStats, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	in := make(chan models.SNPData, w.ChunkSize())
+	fetchErr := make(chan error, 1)
+	go func() {
+		defer close(in)
+		fetchErr <- source.StreamSignificantSNPs(ctx, in)
+	}()
+
+	stats, err := w.Run(ctx, in)
+	if err != nil {
+		return stats, fmt.Errorf("write: %w", err)
+	}
+	if err := <-fetchErr; err != nil {
+		return stats, fmt.Errorf("fetch: %w", err)
+	}
+	return stats, nil
+}
+
+// ScoreOptions controls a scoring pass.
+type ScoreOptions struct {
+	// Full rescores every SNP instead of only unscored, changed and outdated ones.
+	Full      bool
+	BatchSize int
+}
+
+// ScoreResult reports what a scoring pass did.
+type ScoreResult struct {
+	Outdated int64 `json:"outdated"`
+	Scored   int   `json:"scored"`
+}
+
+// Score recalculates significance for SNPs that need it, then refreshes ranks
+// and normalized scores if anything changed.
+func Score(ctx context.Context, db *bun.DB, scorer *scoring.Scorer, opts ScoreOptions) (ScoreResult, error) {
+	var result ScoreResult
+	repos := repositories.NewBunRepositories(db)
+	forEach := repos.SNPs.ForEachStale
+	if opts.Full {
+		forEach = repos.SNPs.ForEach
+	} else {
+		outdated, err := repositories.MarkOutdatedScores(ctx, db, scoring.AlgorithmVersion)
+		if err != nil {
+			return result, fmt.Errorf("mark outdated scores: %w", err)
+		}
+		result.Outdated = outdated
+	}
+
+	err := forEach(ctx, opts.BatchSize, func(batch []*models.SNP) error {
+		for _, snp := range batch {
+			if err := repos.Significance.Save(ctx, scorer.Score(snp)); err != nil {
+				return fmt.Errorf("save score for %s: %w", snp.RsID, err)
+			}
+			result.Scored++
+		}
+		return nil
+	})
+	if err != nil {
+		return result, err
+	}
+
+	if result.Scored > 0 {
+		if err := repositories.RefreshScoreRanks(ctx, db); err != nil {
+			return result, fmt.Errorf("refresh ranks: %w", err)
+		}
+		if err := repositories.NormalizeScores(ctx, db); err != nil {
+			return result, fmt.Errorf("normalize scores: %w", err)
+		}
+	}
+	return result, nil
+}
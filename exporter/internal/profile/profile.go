@@ -0,0 +1,79 @@
+// Package profile defines the named dataset build profiles, which trade
+// coverage for size: a minimal database small enough to ship in a mobile app,
+// a clinical one with every clinically significant ClinVar variant, and a full
+// one with everything the enrichment sources add.
+package profile
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/mkoziy/genome/exporter/internal/pipeline"
+	"github.com/mkoziy/genome/exporter/internal/sources/clinvar"
+)
+
+// Profile names.
+const (
+	Minimal  = "minimal"
+	Clinical = "clinical"
+	Full     = "full"
+)
+
+// Profile selects what a build downloads.
+type Profile struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	// Queries are the search queries of each source. A source without
+	// queries here runs its built-in ones.
+	Queries map[string][]string `json:"queries,omitempty"`
+	// References keeps the literature references sources cite.
+	References bool `json:"references"`
+	// Stages are the pipeline stages the build runs, so enrichment stages are
+	// left out. Nil runs every stage.
+	Stages []string `json:"stages,omitempty"`
+}
+
+// Includes reports whether the profile runs stage.
+func (p Profile) Includes(stage string) bool {
+	return p.Stages == nil || slices.Contains(p.Stages, stage)
+}
+
+var profiles = []Profile{
+	{
+		Name:        Minimal,
+		Description: "pathogenic variants reviewed by an expert panel or in a practice guideline",
+		Queries:     map[string][]string{pipeline.StageClinVar: {clinvar.QueryExpertPanelPathogenicVariants()}},
+		Stages:      []string{pipeline.StageClinVar, pipeline.StageScoring, pipeline.StageFrequencies, pipeline.StageQuality},
+	},
+	{
+		Name:        Clinical,
+		Description: "every pathogenic, risk factor and drug response variant in ClinVar",
+		Queries:     map[string][]string{pipeline.StageClinVar: clinvar.DefaultQueries()},
+		Stages:      []string{pipeline.StageClinVar, pipeline.StageScoring, pipeline.StageFrequencies, pipeline.StageQuality},
+	},
+	{
+		Name:        Full,
+		Description: "clinical plus references, frequencies and predictions from every enrichment source",
+		References:  true,
+	},
+}
+
+// Names lists the profiles from smallest to largest.
+func Names() []string {
+	names := make([]string, len(profiles))
+	for i, p := range profiles {
+		names[i] = p.Name
+	}
+	return names
+}
+
+// Get returns the named profile.
+func Get(name string) (Profile, error) {
+	for _, p := range profiles {
+		if p.Name == name {
+			return p, nil
+		}
+	}
+	return Profile{}, fmt.Errorf("unknown profile %q (want one of %s)", name, strings.Join(Names(), ", "))
+}
@@ -0,0 +1,34 @@
+package profile
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mkoziy/genome/exporter/internal/pipeline"
+)
+
+func TestProfiles(t *testing.T) {
+	minimal, err := Get(Minimal)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	queries := minimal.Queries[pipeline.StageClinVar]
+	if len(queries) != 1 || !strings.Contains(queries[0], `"reviewed by expert panel"[RVSTAT]`) || minimal.References {
+		t.Fatalf("unexpected minimal profile: %+v", minimal)
+	}
+	if !minimal.Includes(pipeline.StageScoring) || minimal.Includes("gnomad") {
+		t.Fatalf("expected minimal to leave out enrichment stages only")
+	}
+
+	full, err := Get(Full)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if full.Queries != nil || !full.References || !full.Includes("gnomad") {
+		t.Fatalf("expected full to run every stage with default queries, got %+v", full)
+	}
+
+	if _, err := Get("huge"); err == nil || !strings.Contains(err.Error(), "minimal, clinical, full") {
+		t.Fatalf("expected unknown profile error listing the profiles, got %v", err)
+	}
+}
@@ -0,0 +1,182 @@
+package progress
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// Kind identifies what an Event reports.
+type Kind string
+
+const (
+	KindStart Kind = "start"
+	KindBatch Kind = "batch"
+	KindDone  Kind = "done"
+)
+
+// Event is one progress update. Records and Batches count the current task
+// (e.g. one ClinVar query); SourceRecords counts every task of the source so
+// far in the run.
+type Event struct {
+	Time          time.Time     `json:"time"`
+	Source        string        `json:"source"`
+	Kind          Kind          `json:"kind"`
+	Task          string        `json:"task,omitempty"`
+	Records       int           `json:"records"`
+	TotalRecords  int           `json:"total_records"`
+	Batches       int           `json:"batches"`
+	TotalBatches  int           `json:"total_batches"`
+	SourceRecords int           `json:"source_records"`
+	RecordsPerSec float64       `json:"records_per_sec"`
+	ETA           time.Duration `json:"eta"`
+}
+
+// Reporter receives progress events. Implementations must be safe for
+// concurrent use, since independent sources report from their own goroutines.
+type Reporter interface {
+	Report(Event)
+}
+
+// Discard drops every event.
+var Discard Reporter = discard{}
+
+type discard struct{}
+
+func (discard) Report(Event) {}
+
+// Tracker turns task and batch notifications from one source into Events with
+// throughput and ETA. A Tracker is used by one goroutine at a time.
+type Tracker struct {
+	reporter Reporter
+	source   string
+	now      func() time.Time
+
+	task          string
+	start         time.Time
+	resumed       int
+	records       int
+	totalRecords  int
+	batches       int
+	totalBatches  int
+	sourceRecords int
+}
+
+// NewTracker reports progress of source to r; a nil r discards it.
+func NewTracker(r Reporter, source string) *Tracker {
+	if r == nil {
+		r = Discard
+	}
+	return &Tracker{reporter: r, source: source, now: time.Now}
+}
+
+// Start begins a task of totalRecords records in totalBatches batches, of which
+// doneRecords and doneBatches were completed by an earlier run. Resumed work
+// counts toward progress but not toward the throughput used for the ETA.
+func (t *Tracker) Start(task string, totalRecords, totalBatches, doneRecords, doneBatches int) {
+	t.task = task
+	t.start = t.now()
+	t.resumed = doneRecords
+	t.records = doneRecords
+	t.totalRecords = totalRecords
+	t.batches = doneBatches
+	t.totalBatches = totalBatches
+	t.emit(KindStart)
+}
+
+// Batch records that a batch of n records finished.
+func (t *Tracker) Batch(n int) {
+	t.records += n
+	t.sourceRecords += n
+	t.batches++
+	t.emit(KindBatch)
+}
+
+// Done ends the current task.
+func (t *Tracker) Done() {
+	t.emit(KindDone)
+}
+
+func (t *Tracker) emit(kind Kind) {
+	now := t.now()
+	e := Event{
+		Time:          now,
+		Source:        t.source,
+		Kind:          kind,
+		Task:          t.task,
+		Records:       t.records,
+		TotalRecords:  t.totalRecords,
+		Batches:       t.batches,
+		TotalBatches:  t.totalBatches,
+		SourceRecords: t.sourceRecords,
+	}
+	if elapsed := now.Sub(t.start).Seconds(); elapsed > 0 && t.records > t.resumed {
+		e.RecordsPerSec = float64(t.records-t.resumed) / elapsed
+		if remaining := t.totalRecords - t.records; remaining > 0 {
+			e.ETA = time.Duration(float64(remaining) / e.RecordsPerSec * float64(time.Second))
+		}
+	}
+	t.reporter.Report(e)
+}
+
+// Terminal writes one human-readable line per event.
+type Terminal struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewTerminal returns a Reporter writing text lines to w.
+func NewTerminal(w io.Writer) *Terminal {
+	return &Terminal{w: w}
+}
+
+// Report implements Reporter.
+func (t *Terminal) Report(e Event) {
+	var line string
+	switch e.Kind {
+	case KindStart:
+		line = fmt.Sprintf("[%s] %s: %d records in %d batches", e.Source, e.Task, e.TotalRecords, e.TotalBatches)
+		if e.Records > 0 {
+			line += fmt.Sprintf(", resuming at %d", e.Records)
+		}
+	case KindBatch:
+		line = fmt.Sprintf("[%s] %d/%d records (%.1f%%), batch %d/%d, %.1f records/s",
+			e.Source, e.Records, e.TotalRecords, percent(e.Records, e.TotalRecords), e.Batches, e.TotalBatches, e.RecordsPerSec)
+		if e.ETA > 0 {
+			line += ", ETA " + e.ETA.Round(time.Second).String()
+		}
+	case KindDone:
+		line = fmt.Sprintf("[%s] %s done, %d records from %s so far", e.Source, e.Task, e.SourceRecords, e.Source)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	_, _ = fmt.Fprintln(t.w, line)
+}
+
+func percent(n, total int) float64 {
+	if total == 0 {
+		return 100
+	}
+	return 100 * float64(n) / float64(total)
+}
+
+// JSON writes each event as one JSON object per line, for wrapping tools.
+type JSON struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+// NewJSON returns a Reporter writing JSON lines to w.
+func NewJSON(w io.Writer) *JSON {
+	return &JSON{enc: json.NewEncoder(w)}
+}
+
+// Report implements Reporter.
+func (j *JSON) Report(e Event) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	_ = j.enc.Encode(e)
+}
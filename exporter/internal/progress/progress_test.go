@@ -0,0 +1,71 @@
+package progress
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+// recorder keeps every event it receives.
+type recorder struct {
+	events []Event
+}
+
+func (r *recorder) Report(e Event) { r.events = append(r.events, e) }
+
+func TestTrackerRateAndETA(t *testing.T) {
+	rec := &recorder{}
+	tracker := NewTracker(rec, "clinvar")
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	tracker.now = func() time.Time { return now }
+
+	// 200 of 1000 records were done by an earlier run and must not inflate the rate.
+	tracker.Start("q", 1000, 10, 200, 2)
+	now = now.Add(10 * time.Second)
+	tracker.Batch(100)
+	tracker.Done()
+
+	if len(rec.events) != 3 {
+		t.Fatalf("expected 3 events, got %d", len(rec.events))
+	}
+	batch := rec.events[1]
+	if batch.Kind != KindBatch || batch.Records != 300 || batch.Batches != 3 || batch.SourceRecords != 100 {
+		t.Fatalf("unexpected batch event: %+v", batch)
+	}
+	if batch.RecordsPerSec != 10 {
+		t.Fatalf("expected 10 records/s, got %v", batch.RecordsPerSec)
+	}
+	if batch.ETA != 70*time.Second {
+		t.Fatalf("expected 70s ETA for 700 remaining records, got %v", batch.ETA)
+	}
+	if rec.events[2].Kind != KindDone {
+		t.Fatalf("expected done event last, got %s", rec.events[2].Kind)
+	}
+}
+
+func TestRenderers(t *testing.T) {
+	e := Event{Source: "clinvar", Kind: KindBatch, Records: 250, TotalRecords: 1000, Batches: 1, TotalBatches: 4, RecordsPerSec: 25, ETA: 30 * time.Second}
+
+	var text bytes.Buffer
+	NewTerminal(&text).Report(e)
+	if got := text.String(); !strings.Contains(got, "250/1000 records (25.0%)") || !strings.Contains(got, "ETA 30s") {
+		t.Fatalf("unexpected terminal line: %q", got)
+	}
+
+	var stream bytes.Buffer
+	NewJSON(&stream).Report(e)
+	NewJSON(&stream).Report(e)
+	lines := strings.Split(strings.TrimSpace(stream.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected one JSON object per line, got %q", stream.String())
+	}
+	var decoded Event
+	if err := json.Unmarshal([]byte(lines[0]), &decoded); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if decoded.Records != 250 || decoded.Kind != KindBatch || decoded.ETA != e.ETA {
+		t.Fatalf("unexpected decoded event: %+v", decoded)
+	}
+}
@@ -0,0 +1,168 @@
+// Package prs computes polygenic risk scores: it reads published score
+// definitions in the PGS Catalog scoring file format, sums their weights over
+// a genotype and places the sum in a reference distribution.
+package prs
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/mkoziy/genome/exporter/internal/models"
+)
+
+// ErrFormat is returned for scoring files that cannot be read.
+var ErrFormat = errors.New("malformed PGS scoring file")
+
+// headerKeys maps the metadata keys of both scoring file layouts, the
+// current "#pgs_id=" and the pre-2.0 "# PGS ID = ", to model fields.
+var headerKeys = map[string]string{
+	"pgs_id":                "pgs_id",
+	"pgs_name":              "pgs_name",
+	"trait_reported":        "trait_reported",
+	"reported_trait":        "trait_reported",
+	"trait_mapped":          "trait_mapped",
+	"mapped_trait(s)_(efo)": "trait_mapped",
+	"genome_build":          "genome_build",
+	"original_genome_build": "genome_build",
+	"weight_type":           "weight_type",
+	"citation":              "citation",
+	"pgs_publication_(pgp)": "citation",
+}
+
+// Definition is a parsed scoring file.
+type Definition struct {
+	Model   *models.PRSModel
+	Weights []*models.PRSWeight
+	// Skipped counts rows that cannot be scored by rsID: those without one,
+	// haplotypes, interactions and dominant or recessive effects.
+	Skipped int
+}
+
+// ParsePGSCatalog reads a PGS Catalog scoring file, gzipped or not. Only
+// files listing rsIDs can be used, since genotypes are matched by rsID.
+// Rows repeating an rsID and effect allele are summed.
+func ParsePGSCatalog(r io.Reader) (*Definition, error) {
+	br := bufio.NewReader(r)
+	if magic, _ := br.Peek(2); bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, fmt.Errorf("gunzip: %w", err)
+		}
+		defer func() {
+			_ = gz.Close()
+		}()
+		br = bufio.NewReader(gz)
+	}
+
+	def := &Definition{Model: &models.PRSModel{}}
+	meta := make(map[string]string)
+	var columns map[string]int
+	index := make(map[string]*models.PRSWeight)
+	sc := bufio.NewScanner(br)
+	sc.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for line := 1; sc.Scan(); line++ {
+		text := strings.TrimRight(sc.Text(), "\r")
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+		if strings.HasPrefix(text, "#") {
+			if key, value, ok := strings.Cut(strings.TrimLeft(text, "# "), "="); ok {
+				key = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(key), " ", "_"))
+				if field, ok := headerKeys[key]; ok {
+					meta[field] = strings.TrimSpace(value)
+				}
+			}
+			continue
+		}
+		fields := strings.Split(text, "\t")
+		if columns == nil {
+			columns = make(map[string]int, len(fields))
+			for i, name := range fields {
+				columns[strings.ToLower(strings.TrimSpace(name))] = i
+			}
+			if _, ok := columns["rsid"]; !ok {
+				return nil, fmt.Errorf("%w: no rsID column; only files listing rsIDs are supported", ErrFormat)
+			}
+			for _, required := range []string{"effect_allele", "effect_weight"} {
+				if _, ok := columns[required]; !ok {
+					return nil, fmt.Errorf("%w: no %s column", ErrFormat, required)
+				}
+			}
+			continue
+		}
+
+		get := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(fields) {
+				return strings.TrimSpace(fields[i])
+			}
+			return ""
+		}
+		rsID, err := models.NormalizeRsID(get("rsid"))
+		if err != nil || isTrue(get("is_haplotype")) || isTrue(get("is_diplotype")) ||
+			isTrue(get("is_interaction")) || isTrue(get("is_dominant")) || isTrue(get("is_recessive")) {
+			def.Skipped++
+			continue
+		}
+		weight, err := strconv.ParseFloat(get("effect_weight"), 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w: bad effect_weight %q", line, ErrFormat, get("effect_weight"))
+		}
+		effect := strings.ToUpper(get("effect_allele"))
+		if effect == "" {
+			return nil, fmt.Errorf("line %d: %w: missing effect_allele", line, ErrFormat)
+		}
+		if w, ok := index[rsID+":"+effect]; ok {
+			w.Weight += weight
+			continue
+		}
+		w := &models.PRSWeight{RsID: rsID, EffectAllele: effect, Weight: weight}
+		other := strings.ToUpper(get("other_allele"))
+		if other == "" {
+			other = strings.ToUpper(get("reference_allele"))
+		}
+		if other != "" {
+			w.OtherAllele = &other
+		}
+		index[rsID+":"+effect] = w
+		def.Weights = append(def.Weights, w)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("read: %w", err)
+	}
+	if columns == nil {
+		return nil, fmt.Errorf("%w: no column header", ErrFormat)
+	}
+
+	m := def.Model
+	m.PGSID = meta["pgs_id"]
+	if m.PGSID == "" {
+		return nil, fmt.Errorf("%w: no pgs_id in the header", ErrFormat)
+	}
+	m.TraitReported = meta["trait_reported"]
+	m.Name = optional(meta["pgs_name"])
+	m.TraitMapped = optional(meta["trait_mapped"])
+	m.GenomeBuild = optional(meta["genome_build"])
+	m.WeightType = optional(meta["weight_type"])
+	m.Citation = optional(meta["citation"])
+	m.VariantCount = len(def.Weights)
+	return def, nil
+}
+
+func isTrue(s string) bool {
+	return strings.EqualFold(s, "true")
+}
+
+// optional returns nil for values the PGS Catalog leaves empty or reports
+// as NR, not reported.
+func optional(s string) *string {
+	if s == "" || s == "NR" {
+		return nil
+	}
+	return &s
+}
@@ -0,0 +1,113 @@
+package prs
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"math"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/mkoziy/genome/exporter/internal/genotype"
+	"github.com/mkoziy/genome/exporter/internal/models"
+)
+
+func readTestdata(t *testing.T) []byte {
+	t.Helper()
+	data, err := os.ReadFile("testdata/PGS000001.txt")
+	if err != nil {
+		t.Fatalf("read testdata: %v", err)
+	}
+	return data
+}
+
+func TestParsePGSCatalog(t *testing.T) {
+	data := readTestdata(t)
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	_, _ = zw.Write(data)
+	_ = zw.Close()
+
+	for name, input := range map[string][]byte{"plain": data, "gzip": gz.Bytes()} {
+		t.Run(name, func(t *testing.T) {
+			def, err := ParsePGSCatalog(bytes.NewReader(input))
+			if err != nil {
+				t.Fatalf("parse: %v", err)
+			}
+			m := def.Model
+			if m.PGSID != "PGS000001" || m.TraitReported != "Breast cancer" || m.Name == nil || *m.Name != "PRS77_BC" {
+				t.Fatalf("unexpected model: %+v", m)
+			}
+			if m.GenomeBuild != nil || m.WeightType != nil {
+				t.Fatalf("expected NR to be left empty, got %v %v", m.GenomeBuild, m.WeightType)
+			}
+			if len(def.Weights) != 3 || def.Skipped != 2 || m.VariantCount != 3 {
+				t.Fatalf("expected 3 weights and 2 skipped rows, got %d and %d", len(def.Weights), def.Skipped)
+			}
+			if w := def.Weights[1]; w.RsID != "rs2" || w.EffectAllele != "A" || *w.OtherAllele != "G" || w.Weight != -0.1 {
+				t.Fatalf("unexpected weight: %+v", w)
+			}
+		})
+	}
+}
+
+func TestParsePGSCatalogLegacyHeader(t *testing.T) {
+	input := "### PGS CATALOG SCORING FILE\n# PGS ID = PGS000002\n# Reported Trait = Height\n" +
+		"rsID\teffect_allele\treference_allele\teffect_weight\nrs1\tc\tt\t0.1\nrs1\tC\tT\t0.2\n"
+	def, err := ParsePGSCatalog(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if def.Model.PGSID != "PGS000002" || def.Model.TraitReported != "Height" {
+		t.Fatalf("unexpected model: %+v", def.Model)
+	}
+	if len(def.Weights) != 1 || math.Abs(def.Weights[0].Weight-0.3) > 1e-9 || *def.Weights[0].OtherAllele != "T" {
+		t.Fatalf("expected repeated rows summed, got %+v", def.Weights)
+	}
+
+	_, err = ParsePGSCatalog(strings.NewReader("#pgs_id=PGS3\nchr_name\tchr_position\teffect_allele\teffect_weight\n1\t100\tA\t0.1\n"))
+	if !errors.Is(err, ErrFormat) {
+		t.Fatalf("expected a file without rsIDs to be rejected, got %v", err)
+	}
+}
+
+func TestCompute(t *testing.T) {
+	def, err := ParsePGSCatalog(bytes.NewReader(readTestdata(t)))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	model := def.Model
+	model.Weights = def.Weights
+	model.Distributions = []*models.PRSDistribution{{Population: "nfe", Mean: 0.2, SD: 0.1, Method: "published"}}
+
+	calls := []genotype.Call{
+		{RsID: "rs1", Genotype: "CC"}, // two effect alleles: +0.4
+		{RsID: "rs2", Genotype: "TC"}, // opposite strand of AG: one effect allele, -0.1
+		{RsID: "rs3", Genotype: "GG"}, // neither allele of an A/T SNP: unmatched
+	}
+	s := Compute(model, calls)
+	if s.Matched != 2 || s.Variants != 3 || math.Abs(s.Value-0.3) > 1e-9 {
+		t.Fatalf("unexpected score: %+v", s)
+	}
+	if len(s.Percentiles) != 1 || math.Abs(s.Percentiles[0].ZScore-1) > 1e-9 || math.Abs(s.Percentiles[0].Percentile-84.13) > 0.01 {
+		t.Fatalf("unexpected percentiles: %+v", s.Percentiles)
+	}
+}
+
+func TestFrequencyDistribution(t *testing.T) {
+	weights := []*models.PRSWeight{
+		{RsID: "rs1", EffectAllele: "C", Weight: 1},
+		{RsID: "rs2", EffectAllele: "A", Weight: 2},
+		{RsID: "rs3", EffectAllele: "G", Weight: 5},
+	}
+	freqs := map[string]map[string]float64{
+		"rs1": {"C": 0.5, "T": 0.5},
+		"rs2": {"A": 0.1},
+	}
+	mean, sd, used := FrequencyDistribution(weights, freqs)
+	// mean = 2*0.5*1 + 2*0.1*2; variance = 2*0.5*0.5*1 + 2*0.1*0.9*4
+	if used != 2 || math.Abs(mean-1.4) > 1e-9 || math.Abs(sd-math.Sqrt(1.22)) > 1e-9 {
+		t.Fatalf("unexpected distribution: mean %v sd %v used %d", mean, sd, used)
+	}
+}
@@ -0,0 +1,148 @@
+package prs
+
+import (
+	"math"
+
+	"github.com/mkoziy/genome/exporter/internal/genotype"
+	"github.com/mkoziy/genome/exporter/internal/models"
+)
+
+// Score is a model's score for one genotype.
+type Score struct {
+	PGSID string  `json:"pgs_id"`
+	Trait string  `json:"trait"`
+	Value float64 `json:"value"`
+	// Matched counts the model's variants the genotype has a call for; the
+	// others count as no copies of the effect allele.
+	Matched  int `json:"matched"`
+	Variants int `json:"variants"`
+	// Coverage is Matched over Variants. Percentiles of poorly covered
+	// scores say little, since reference distributions assume every variant.
+	Coverage    float64      `json:"coverage"`
+	Percentiles []Percentile `json:"percentiles,omitempty"`
+}
+
+// Percentile places a score in one reference population.
+type Percentile struct {
+	Population string  `json:"population"`
+	Method     string  `json:"method"`
+	ZScore     float64 `json:"z_score"`
+	Percentile float64 `json:"percentile"`
+}
+
+// Compute sums the weights of model, loaded with its weights and
+// distributions, over the calls: each weight times the copies of its effect
+// allele called. Calls reported on the opposite strand are flipped where the
+// other allele tells them apart. Calls of variants missing from the genotype,
+// no-calls and calls matching neither allele add nothing.
+func Compute(model *models.PRSModel, calls []genotype.Call) *Score {
+	byRsID := make(map[string]genotype.Call, len(calls))
+	for _, call := range calls {
+		byRsID[call.RsID] = call
+	}
+
+	s := &Score{PGSID: model.PGSID, Trait: model.TraitReported, Variants: len(model.Weights)}
+	for _, w := range model.Weights {
+		call, ok := byRsID[w.RsID]
+		if !ok || call.NoCall() {
+			continue
+		}
+		copies, ok := dosage(call.Alleles(), w)
+		if !ok {
+			continue
+		}
+		s.Matched++
+		s.Value += float64(copies) * w.Weight
+	}
+	if s.Variants > 0 {
+		s.Coverage = float64(s.Matched) / float64(s.Variants)
+	}
+	for _, d := range model.Distributions {
+		s.Percentiles = append(s.Percentiles, Percentile{
+			Population: d.Population,
+			Method:     d.Method,
+			ZScore:     d.ZScore(s.Value),
+			Percentile: d.Percentile(s.Value),
+		})
+	}
+	return s
+}
+
+// dosage counts the effect allele among alleles. Without an other allele
+// any allele but the effect one counts as other; with one, alleles matching
+// neither are retried on the opposite strand unless the pair is an A/T or
+// C/G one whose strand a genotype cannot reveal.
+func dosage(alleles []string, w *models.PRSWeight) (int, bool) {
+	if w.OtherAllele == nil {
+		n := 0
+		for _, a := range alleles {
+			if a == w.EffectAllele {
+				n++
+			}
+		}
+		return n, true
+	}
+	other := *w.OtherAllele
+	count := func(alleles []string) (int, bool) {
+		n := 0
+		for _, a := range alleles {
+			switch a {
+			case w.EffectAllele:
+				n++
+			case other:
+			default:
+				return 0, false
+			}
+		}
+		return n, true
+	}
+	if n, ok := count(alleles); ok {
+		return n, true
+	}
+	if complement(w.EffectAllele) == other {
+		return 0, false
+	}
+	flipped := make([]string, len(alleles))
+	for i, a := range alleles {
+		flipped[i] = complement(a)
+	}
+	return count(flipped)
+}
+
+func complement(allele string) string {
+	switch allele {
+	case "A":
+		return "T"
+	case "T":
+		return "A"
+	case "C":
+		return "G"
+	case "G":
+		return "C"
+	}
+	return allele
+}
+
+// MethodAlleleFrequencies marks distributions derived by
+// FrequencyDistribution.
+const MethodAlleleFrequencies = "allele frequencies"
+
+// FrequencyDistribution derives the distribution of the score of weights in
+// a population from the frequencies of their effect alleles there, by rsID
+// and allele, assuming Hardy-Weinberg equilibrium and variants inherited
+// independently: each contributes a mean of 2pw and a variance of
+// 2p(1-p)w². Variants without a frequency for their effect allele are left
+// out; it returns how many were used.
+func FrequencyDistribution(weights []*models.PRSWeight, freqs map[string]map[string]float64) (mean, sd float64, used int) {
+	var variance float64
+	for _, w := range weights {
+		p, ok := freqs[w.RsID][w.EffectAllele]
+		if !ok || p < 0 || p > 1 {
+			continue
+		}
+		mean += 2 * p * w.Weight
+		variance += 2 * p * (1 - p) * w.Weight * w.Weight
+		used++
+	}
+	return mean, math.Sqrt(variance), used
+}
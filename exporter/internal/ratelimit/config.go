@@ -28,6 +28,11 @@ func DefaultConfig() Config {
 	}
 }
 
+// WithDefaults returns cfg with unset fields taken from DefaultConfig.
+func (cfg Config) WithDefaults() Config {
+	return applyDefaults(cfg)
+}
+
 func applyDefaults(cfg Config) Config {
 	def := DefaultConfig()
 	if cfg.Strategy == "" {
@@ -56,3 +61,29 @@ func applyDefaults(cfg Config) Config {
 	}
 	return cfg
 }
+
+// EstimateDuration returns the minimum time a limiter built from cfg needs to
+// allow n requests, ignoring response latency and retries.
+func (cfg Config) EstimateDuration(n int) time.Duration {
+	cfg = applyDefaults(cfg)
+	if n <= 0 {
+		return 0
+	}
+
+	switch cfg.Strategy {
+	case StrategyFixedDelay:
+		return time.Duration(n-1) * cfg.FixedDelay
+	case StrategyFixedWindow:
+		limit := int(cfg.RequestsPerSec)
+		if limit < 1 {
+			limit = 1
+		}
+		return time.Duration((n-1)/limit) * time.Second
+	default:
+		// The bucket starts full, so the first Burst requests are free.
+		if n <= cfg.Burst {
+			return 0
+		}
+		return time.Duration(float64(n-cfg.Burst) / cfg.RequestsPerSec * float64(time.Second))
+	}
+}
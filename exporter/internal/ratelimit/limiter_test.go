@@ -93,29 +93,22 @@ func TestCalculateBackoffBounds(t *testing.T) {
 	}
 }
 
-func TestConfigLoader(t *testing.T) {
-	yamlData := []byte(`rate_limits:
-  clinvar:
-    strategy: token_bucket
-    requests_per_second: 3
-    burst: 5
-    max_retries: 5
-    initial_backoff: 1s
-    max_backoff: 60s
-    backoff_multiplier: 2
-`)
-
-	cfgs, err := LoadSourceConfigs(yamlData)
-	if err != nil {
-		t.Fatalf("unexpected error: %v", err)
-	}
-
-	clinvar, err := cfgs.Get("clinvar")
-	if err != nil {
-		t.Fatalf("unexpected error: %v", err)
-	}
-
-	if clinvar.RequestsPerSec != 3 {
-		t.Fatalf("expected requests_per_second=3, got %v", clinvar.RequestsPerSec)
+func TestEstimateDuration(t *testing.T) {
+	cases := []struct {
+		name string
+		cfg  Config
+		n    int
+		want time.Duration
+	}{
+		{"token bucket within burst", Config{Strategy: StrategyTokenBucket, RequestsPerSec: 10, Burst: 5}, 5, 0},
+		{"token bucket beyond burst", Config{Strategy: StrategyTokenBucket, RequestsPerSec: 10, Burst: 5}, 105, 10 * time.Second},
+		{"fixed window", Config{Strategy: StrategyFixedWindow, RequestsPerSec: 3}, 7, 2 * time.Second},
+		{"fixed delay", Config{Strategy: StrategyFixedDelay, FixedDelay: 5 * time.Second}, 3, 10 * time.Second},
+		{"no requests", DefaultConfig(), 0, 0},
+	}
+	for _, tc := range cases {
+		if got := tc.cfg.EstimateDuration(tc.n); got != tc.want {
+			t.Errorf("%s: expected %v, got %v", tc.name, tc.want, got)
+		}
 	}
 }
@@ -0,0 +1,51 @@
+package report
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+)
+
+// DefaultPDFCommand converts HTML on stdin to PDF on stdout with
+// wkhtmltopdf, which must be installed separately.
+var DefaultPDFCommand = []string{"wkhtmltopdf", "--quiet", "--encoding", "utf-8", "-", "-"}
+
+// PDFConverter renders reports as PDF by piping their HTML through an
+// external converter, so the exporter carries no PDF engine of its own.
+type PDFConverter struct {
+	// Command reads HTML on stdin and writes PDF on stdout;
+	// DefaultPDFCommand if empty.
+	Command []string
+}
+
+// WritePDF renders r as HTML and converts it to PDF on w.
+func (c PDFConverter) WritePDF(ctx context.Context, w io.Writer, r *Report) error {
+	command := c.Command
+	if len(command) == 0 {
+		command = DefaultPDFCommand
+	}
+	var html bytes.Buffer
+	if err := r.WriteHTML(&html); err != nil {
+		return err
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, command[0], command[1:]...)
+	cmd.Stdin = &html
+	cmd.Stdout = w
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			return fmt.Errorf("pdf converter %q not found; install it or choose another command", command[0])
+		}
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("pdf converter: %w: %s", err, msg)
+		}
+		return fmt.Errorf("pdf converter: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,167 @@
+package report
+
+import (
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// Format is an output format of a report.
+type Format string
+
+// Supported formats. PDF is rendered from the HTML by an external converter;
+// see PDFConverter.
+const (
+	FormatMarkdown Format = "markdown"
+	FormatHTML     Format = "html"
+	FormatPDF      Format = "pdf"
+)
+
+// markdownEscaper backslash-escapes the characters Markdown would read as
+// formatting in names and titles taken from the database.
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`, "`", "\\`", `*`, `\*`, `_`, `\_`, `[`, `\[`, `]`, `\]`, `<`, `\<`, `>`, `\>`, `#`, `\#`, `|`, `\|`,
+)
+
+var funcs = map[string]any{
+	"date": func(r *Report) string { return r.GeneratedAt.UTC().Format("2006-01-02 15:04 MST") },
+	"score": func(score *float64) string {
+		if score == nil {
+			return ""
+		}
+		return fmt.Sprintf("%.0f", *score)
+	},
+	// share writes a frequency as a percentage, to one significant digit
+	// below 1%.
+	"share": func(f *float64) string {
+		if f == nil {
+			return ""
+		}
+		pct := *f * 100
+		if pct < 1 {
+			return strconv.FormatFloat(pct, 'g', 1, 64) + "%"
+		}
+		return fmt.Sprintf("%.0f%%", pct)
+	},
+	"disclaimer": func() string { return Disclaimer },
+	"join":       strings.Join,
+}
+
+var markdownTemplate = template.Must(template.New("markdown").
+	Funcs(funcs).
+	Funcs(template.FuncMap{"md": markdownEscaper.Replace}).
+	Parse(`# {{md .Title}}
+
+{{if .Sample}}Sample: {{md .Sample}}
+{{end}}Generated: {{date .}}{{if .Language}}
+Language: {{.Language}}{{end}}
+Calls: {{.Calls}} ({{.NoCalls}} no-calls), {{.Matched}} matched in the database
+{{range .Sections}}
+## {{.Title}}
+{{if not (or .Items .Diplotypes)}}
+No findings.
+{{end}}{{range .Diplotypes}}
+### {{md .Gene}}{{with .Diplotype}} {{md .}}{{end}}
+
+Phenotype: **{{.Phenotype.Title}}**{{with .ActivityScore}} (activity score {{.}}){{end}}
+{{if .Alternatives}}
+Also consistent with: {{md (join .Alternatives ", ")}}
+{{end}}{{if .Untested}}
+Not tested: {{md (join .Untested ", ")}}
+{{end}}{{if .Recommendations}}
+CPIC recommendations:
+
+{{range .Recommendations}}- [{{md .Drug}}]({{.URL}}): {{md .Text}} ({{.Strength}})
+{{end}}{{end}}{{end}}{{range .Items}}
+### {{.RsID}}{{if .Gene}} ({{md .Gene}}){{end}}
+
+Genotype: **{{md .Genotype}}**{{with share .GenotypeFrequency}} (in about {{.}} of people){{end}} · Significance: **{{.Level}}**{{with score .Score}} ({{.}}){{end}}
+{{with .Summary}}
+{{md .}}
+{{end}}{{if .Explanations}}
+{{range .Explanations}}- {{md .}}
+{{end}}{{end}}{{if .Evidence}}
+Evidence:
+
+{{range .Evidence}}- {{md .Name}}: {{md .Detail}} ({{.Source}})
+{{end}}{{end}}{{if .Reasons}}
+Score:
+
+{{range .Reasons}}- {{md .}}
+{{end}}{{end}}{{if .References}}
+References:
+
+{{range .References}}- {{if .URL}}[{{md .Text}}]({{.URL}}){{else}}{{md .Text}}{{end}}
+{{end}}{{end}}{{end}}{{end}}
+---
+
+_{{disclaimer}}_
+`))
+
+var htmlTemplate = htmltemplate.Must(htmltemplate.New("html").Funcs(funcs).Parse(`<!DOCTYPE html>
+<html{{with .Language}} lang="{{.}}"{{end}}>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: sans-serif; max-width: 50em; margin: 2em auto; line-height: 1.4; color: #222; }
+.item { border-top: 1px solid #ccc; padding-top: 0.5em; }
+.level { font-weight: bold; }
+.meta, .disclaimer { color: #555; font-size: 0.9em; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<p class="meta">{{if .Sample}}Sample: {{.Sample}}<br>{{end}}Generated: {{date .}}{{if .Language}}<br>Language: {{.Language}}{{end}}<br>
+Calls: {{.Calls}} ({{.NoCalls}} no-calls), {{.Matched}} matched in the database</p>
+{{range .Sections}}<section id="{{.Category}}">
+<h2>{{.Title}}</h2>
+{{if not (or .Items .Diplotypes)}}<p>No findings.</p>
+{{end}}{{range .Diplotypes}}<div class="item">
+<h3>{{.Gene}}{{with .Diplotype}} {{.}}{{end}}</h3>
+<p>Phenotype: <span class="level">{{.Phenotype.Title}}</span>{{with .ActivityScore}} (activity score {{.}}){{end}}</p>
+{{if .Alternatives}}<p>Also consistent with: {{join .Alternatives ", "}}</p>
+{{end}}{{if .Untested}}<p class="meta">Not tested: {{join .Untested ", "}}</p>
+{{end}}{{if .Recommendations}}<p>CPIC recommendations:</p>
+<ul>
+{{range .Recommendations}}<li><a href="{{.URL}}">{{.Drug}}</a>: {{.Text}} ({{.Strength}})</li>
+{{end}}</ul>
+{{end}}</div>
+{{end}}{{range .Items}}<div class="item">
+<h3>{{.RsID}}{{if .Gene}} ({{.Gene}}){{end}}</h3>
+<p>Genotype: <strong>{{.Genotype}}</strong>{{with share .GenotypeFrequency}} (in about {{.}} of people){{end}} · Significance: <span class="level">{{.Level}}</span>{{with score .Score}} ({{.}}){{end}}</p>
+{{with .Summary}}<p>{{.}}</p>
+{{end}}{{if .Explanations}}<ul>
+{{range .Explanations}}<li>{{.}}</li>
+{{end}}</ul>
+{{end}}{{if .Evidence}}<p>Evidence:</p>
+<ul>
+{{range .Evidence}}<li>{{.Name}}: {{.Detail}} ({{.Source}})</li>
+{{end}}</ul>
+{{end}}{{if .Reasons}}<p>Score:</p>
+<ul>
+{{range .Reasons}}<li>{{.}}</li>
+{{end}}</ul>
+{{end}}{{if .References}}<p>References:</p>
+<ol>
+{{range .References}}<li>{{if .URL}}<a href="{{.URL}}">{{.Text}}</a>{{else}}{{.Text}}{{end}}</li>
+{{end}}</ol>
+{{end}}</div>
+{{end}}</section>
+{{end}}<p class="disclaimer">{{disclaimer}}</p>
+</body>
+</html>
+`))
+
+// WriteMarkdown renders r as Markdown.
+func (r *Report) WriteMarkdown(w io.Writer) error {
+	return markdownTemplate.Execute(w, r)
+}
+
+// WriteHTML renders r as a standalone HTML page.
+func (r *Report) WriteHTML(w io.Writer) error {
+	return htmlTemplate.Execute(w, r)
+}
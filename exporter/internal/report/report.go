@@ -0,0 +1,441 @@
+// Package report renders an annotated genotype as a report for the person
+// it belongs to: the variants the database knows of, grouped into clinical,
+// pharmacogenomic and trait sections, each with its significance level, what
+// the genotype means for it and the literature behind it.
+package report
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/mkoziy/genome/exporter/internal/genotype"
+	"github.com/mkoziy/genome/exporter/internal/models"
+	"github.com/mkoziy/genome/exporter/internal/pgx"
+	"github.com/mkoziy/genome/exporter/internal/scoring"
+)
+
+// Category is a section of the report.
+type Category string
+
+// Report sections, in the order they are rendered.
+const (
+	CategoryClinical        Category = "clinical"
+	CategoryPharmacogenomic Category = "pharmacogenomic"
+	CategoryTraits          Category = "traits"
+)
+
+// Categories lists the sections in the order they are rendered.
+func Categories() []Category {
+	return []Category{CategoryClinical, CategoryPharmacogenomic, CategoryTraits}
+}
+
+// Title returns the section heading of the category.
+func (c Category) Title() string {
+	switch c {
+	case CategoryClinical:
+		return "Clinical findings"
+	case CategoryPharmacogenomic:
+		return "Drug response"
+	case CategoryTraits:
+		return "Traits"
+	}
+	return string(c)
+}
+
+// defaultMaxReferences is how many references an item lists unless
+// Options.MaxReferences says otherwise.
+const defaultMaxReferences = 5
+
+// Disclaimer closes every report.
+const Disclaimer = "This report is generated from public research databases for educational use. " +
+	"It is not a diagnosis; discuss any finding with a physician or genetic counsellor before acting on it."
+
+// Entry is one annotated variant of a genotype.
+type Entry struct {
+	// Genotype is the called genotype as shown, e.g. AG or C/T.
+	Genotype string
+	// Alleles are the called alleles, nil where they are not known.
+	Alleles  []string
+	SNP      *models.SNP
+	Findings []genotype.Finding
+}
+
+// Input is an annotated genotype, from a raw data file or a VCF.
+type Input struct {
+	Sample  string
+	Calls   int
+	NoCalls int
+	Entries []Entry
+	// Diplotypes are the pharmacogene diplotypes called from the genotype,
+	// reported under drug response when tested.
+	Diplotypes []pgx.Result
+}
+
+// FromResult returns the input of a raw data file annotated by
+// genotype.Annotate.
+func FromResult(r *genotype.Result) Input {
+	in := Input{Calls: r.Calls, NoCalls: r.NoCalls}
+	for _, a := range r.Annotations {
+		in.Entries = append(in.Entries, Entry{Genotype: a.Call.Genotype, Alleles: a.Call.Alleles(), SNP: a.SNP, Findings: a.Findings})
+	}
+	return in
+}
+
+// FromVCF returns the input of a VCF sample annotated by genotype.AnnotateVCF.
+// Genotypes are shown as alleles where the site's record has one alternate
+// allele and as the GT value otherwise.
+func FromVCF(r *genotype.VCFResult) Input {
+	in := Input{Sample: r.Sample, Calls: r.Sites, NoCalls: r.NoCalls}
+	for _, a := range r.Annotations {
+		gt := a.Site.Genotype
+		var called []string
+		if alleles := a.Site.Alleles(a.SNP); alleles != nil {
+			if !slices.Contains(alleles, "") {
+				called = slices.Clone(alleles)
+			}
+			for i, allele := range alleles {
+				if allele == "" {
+					alleles[i] = "?"
+				}
+			}
+			gt = strings.Join(alleles, "/")
+		}
+		in.Entries = append(in.Entries, Entry{Genotype: gt, Alleles: called, SNP: a.SNP, Findings: a.Findings})
+	}
+	return in
+}
+
+// Options tune Build.
+type Options struct {
+	// Title heads the report; "Genetic report" if empty.
+	Title string
+	// Language is the language the entries' SNPs were read in, through a
+	// repositories.LocalizedSNPRepository; empty for the database's English.
+	Language string
+	// CarriedOnly leaves out variants for which the genotype carries no risk
+	// allele, including those without risk alleles, and normal metabolizers.
+	CarriedOnly bool
+	// MaxReferences bounds the references listed per item, most cited first;
+	// 0 means 5 and a negative value lists none.
+	MaxReferences int
+	// Now is the generation time; time.Now if zero.
+	Now time.Time
+}
+
+// Report is a rendered-ready report.
+type Report struct {
+	Title       string    `json:"title"`
+	Sample      string    `json:"sample,omitempty"`
+	Language    string    `json:"language,omitempty"`
+	GeneratedAt time.Time `json:"generated_at"`
+	Calls       int       `json:"calls"`
+	NoCalls     int       `json:"no_calls"`
+	// Matched is how many calls matched a variant in the database.
+	Matched  int       `json:"matched"`
+	Sections []Section `json:"sections"`
+}
+
+// Section lists the items of one category, most significant first. Empty
+// sections are kept so readers see the category was looked at.
+type Section struct {
+	Category Category `json:"category"`
+	Title    string   `json:"title"`
+	// Diplotypes are the pharmacogene diplotypes of the drug response
+	// section, listed before its items.
+	Diplotypes []pgx.Result `json:"diplotypes,omitempty"`
+	Items      []Item       `json:"items"`
+}
+
+// Item is a variant as reported in one section. A variant with evidence in
+// several categories appears in each, with the evidence of that category.
+type Item struct {
+	RsID     string `json:"rsid"`
+	Gene     string `json:"gene,omitempty"`
+	Genotype string `json:"genotype"`
+	// GenotypeFrequency is how common the genotype is, as Hardy–Weinberg
+	// equilibrium expects from the variant's global allele frequencies; nil
+	// where they are not known.
+	GenotypeFrequency *float64 `json:"genotype_frequency,omitempty"`
+	// Level is the significance level of the variant's score, or
+	// "Unscored".
+	Level string   `json:"level"`
+	Score *float64 `json:"score,omitempty"`
+	// Summary is the plain-language summary, if any.
+	Summary string `json:"summary,omitempty"`
+	// Explanations say what the genotype means for each risk allele.
+	Explanations []string `json:"explanations,omitempty"`
+	// Evidence lists the assertions and associations behind the item.
+	Evidence []Evidence `json:"evidence"`
+	// Reasons are the contributions to the score.
+	Reasons    []string   `json:"reasons,omitempty"`
+	References []Citation `json:"references,omitempty"`
+	// Carried reports whether the genotype carries a risk allele of the
+	// section.
+	Carried bool `json:"carried"`
+}
+
+// Evidence is one assertion or association of a variant.
+type Evidence struct {
+	// Name is the condition, trait or drug.
+	Name string `json:"name"`
+	// Detail is the significance or association and how well it is
+	// supported, e.g. "pathogenic, reviewed by expert panel".
+	Detail string            `json:"detail"`
+	Source models.DataSource `json:"source"`
+}
+
+// Citation is a reference as listed under an item.
+type Citation struct {
+	Text string `json:"text"`
+	URL  string `json:"url,omitempty"`
+}
+
+// Build groups the entries of in into sections.
+func Build(in Input, opts Options) *Report {
+	r := &Report{
+		Title:       cmp.Or(opts.Title, "Genetic report"),
+		Sample:      in.Sample,
+		Language:    opts.Language,
+		GeneratedAt: opts.Now,
+		Calls:       in.Calls,
+		NoCalls:     in.NoCalls,
+		Matched:     len(in.Entries),
+	}
+	if r.GeneratedAt.IsZero() {
+		r.GeneratedAt = time.Now()
+	}
+
+	items := make(map[Category][]Item)
+	for _, e := range in.Entries {
+		if e.SNP == nil {
+			continue
+		}
+		for _, c := range Categories() {
+			item, ok := buildItem(e, c, opts.MaxReferences)
+			if ok && (item.Carried || !opts.CarriedOnly) {
+				items[c] = append(items[c], item)
+			}
+		}
+	}
+	var diplotypes []pgx.Result
+	for _, d := range in.Diplotypes {
+		if d.Tested && (d.Phenotype != pgx.PhenotypeNormal || !opts.CarriedOnly) {
+			diplotypes = append(diplotypes, d)
+		}
+	}
+	for _, c := range Categories() {
+		list := items[c]
+		slices.SortStableFunc(list, compareItems)
+		section := Section{Category: c, Title: c.Title(), Items: list}
+		if c == CategoryPharmacogenomic {
+			section.Diplotypes = diplotypes
+		}
+		r.Sections = append(r.Sections, section)
+	}
+	return r
+}
+
+// buildItem returns the item of e in section c, or false if e has no
+// evidence in c.
+func buildItem(e Entry, c Category, maxReferences int) (Item, bool) {
+	snp := e.SNP
+	item := Item{RsID: snp.RsID, Genotype: e.Genotype, Level: "Unscored"}
+	if snp.HasGene() {
+		item.Gene = *snp.GeneSymbol
+	}
+
+	for _, cd := range snp.ClinicalData {
+		if clinicalCategory(cd.ClinicalSignificance) != c {
+			continue
+		}
+		item.Evidence = append(item.Evidence, Evidence{
+			Name:   cd.ConditionName,
+			Detail: humanize(string(cd.ClinicalSignificance)) + ", " + humanize(string(cd.ReviewStatus)),
+			Source: cd.Source,
+		})
+	}
+	for _, p := range snp.Phenotypes {
+		if phenotypeCategory(p) != c {
+			continue
+		}
+		item.Evidence = append(item.Evidence, Evidence{
+			Name:   p.PhenotypeName,
+			Detail: phenotypeDetail(p),
+			Source: p.Source,
+		})
+	}
+	for _, f := range e.Findings {
+		if f.RiskAllele == nil || clinicalCategory(models.ClinicalSignificance(f.RiskAllele.Effect)) != c {
+			continue
+		}
+		item.Explanations = append(item.Explanations, explain(f))
+		if f.Copies > 0 {
+			item.Carried = true
+		}
+	}
+	if len(item.Evidence) == 0 && len(item.Explanations) == 0 {
+		return Item{}, false
+	}
+
+	if sig := snp.Significance; sig != nil {
+		score := sig.LevelScore()
+		item.Score = &score
+		item.Level = sig.SignificanceLevel()
+		item.Reasons = sig.ScoreDetails.Reasons
+	}
+	if f, ok := scoring.GenotypeProbability(snp, e.Alleles); ok {
+		item.GenotypeFrequency = &f
+	}
+	item.Summary = snp.Summary
+	item.References = citations(snp.References, maxReferences)
+	return item, true
+}
+
+// clinicalCategory returns the section of a clinical significance, or ""
+// for significances not worth reporting such as benign and uncertain.
+func clinicalCategory(s models.ClinicalSignificance) Category {
+	switch s {
+	case models.ClinicalPathogenic, models.ClinicalLikelyPathogenic, models.ClinicalRiskFactor, models.ClinicalProtective:
+		return CategoryClinical
+	case models.ClinicalDrugResponse:
+		return CategoryPharmacogenomic
+	case models.ClinicalAssociation:
+		return CategoryTraits
+	}
+	// GWAS risk alleles carry their association type as the effect.
+	if s != "" && !s.IsValid() {
+		return CategoryTraits
+	}
+	return ""
+}
+
+// phenotypeCategory files PharmGKB annotations and drug associations under
+// drug response and every other phenotype under traits.
+func phenotypeCategory(p *models.Phenotype) Category {
+	if p.Source == models.SourcePharmGKB || strings.Contains(strings.ToLower(p.AssociationType), "drug") {
+		return CategoryPharmacogenomic
+	}
+	return CategoryTraits
+}
+
+func phenotypeDetail(p *models.Phenotype) string {
+	detail := humanize(p.AssociationType)
+	if p.OddsRatio != nil && p.OddsRatio.Valid {
+		detail += fmt.Sprintf(", odds ratio %.2f", p.OddsRatio.Float64)
+	}
+	if p.PValue != nil && p.PValue.Valid {
+		detail += fmt.Sprintf(", p = %.1e", p.PValue.Float64)
+	}
+	return detail
+}
+
+// explain says in a sentence what a finding means.
+func explain(f genotype.Finding) string {
+	risk := f.RiskAllele
+	about := fmt.Sprintf("the %s allele, %s for %s", risk.Allele, humanize(risk.Effect), risk.ConditionName)
+	switch f.Status {
+	case genotype.StatusNotCarried:
+		return "No copy of " + about + "."
+	case genotype.StatusCarrier:
+		return "One copy of " + about + ". The condition is recessive: this makes a carrier, not affected."
+	case genotype.StatusHeterozygous:
+		return "One copy of " + about + "."
+	case genotype.StatusHomozygous:
+		return "Two copies of " + about + "."
+	case genotype.StatusHemizygous:
+		return "The only copy is " + about + "."
+	case genotype.StatusHeteroplasmic:
+		if f.Heteroplasmy != nil {
+			return fmt.Sprintf("%.0f%% of the mitochondrial genome's copies carry %s.", *f.Heteroplasmy*100, about)
+		}
+		return "Some of the mitochondrial genome's copies carry " + about + "."
+	}
+	return "The genotype could not be matched to " + about + "."
+}
+
+// compareItems orders items carried first, then by score, highest first,
+// unscored last, then by rsID.
+func compareItems(a, b Item) int {
+	if a.Carried != b.Carried {
+		if a.Carried {
+			return -1
+		}
+		return 1
+	}
+	switch {
+	case a.Score != nil && b.Score == nil:
+		return -1
+	case a.Score == nil && b.Score != nil:
+		return 1
+	case a.Score != nil && *a.Score != *b.Score:
+		return cmp.Compare(*b.Score, *a.Score)
+	}
+	return cmp.Compare(a.RsID, b.RsID)
+}
+
+// citations lists the most cited of refs, at most max of them.
+func citations(refs []*models.Reference, max int) []Citation {
+	if max == 0 {
+		max = defaultMaxReferences
+	}
+	if max < 0 || len(refs) == 0 {
+		return nil
+	}
+	sorted := slices.Clone(refs)
+	slices.SortStableFunc(sorted, func(a, b *models.Reference) int {
+		return cmp.Compare(b.CitationCount, a.CitationCount)
+	})
+	var list []Citation
+	for _, ref := range sorted[:min(max, len(sorted))] {
+		list = append(list, cite(ref))
+	}
+	return list
+}
+
+// cite formats ref as "Authors (Year). Title. Journal." with a link to the
+// article, its DOI or its PubMed entry, whichever is known.
+func cite(ref *models.Reference) Citation {
+	var parts []string
+	head := deref(ref.Authors)
+	if ref.PublicationYear != nil {
+		head = strings.TrimSpace(fmt.Sprintf("%s (%d)", head, *ref.PublicationYear))
+	}
+	for _, p := range []string{head, deref(ref.Title), deref(ref.Journal)} {
+		if p = strings.TrimSuffix(strings.TrimSpace(p), "."); p != "" {
+			parts = append(parts, p+".")
+		}
+	}
+	c := Citation{Text: strings.Join(parts, " ")}
+	switch {
+	case ref.URL != nil && *ref.URL != "":
+		c.URL = *ref.URL
+	case ref.DOI != nil && *ref.DOI != "":
+		c.URL = "https://doi.org/" + *ref.DOI
+	case ref.PubmedID != nil && *ref.PubmedID != "":
+		c.URL = "https://pubmed.ncbi.nlm.nih.gov/" + *ref.PubmedID + "/"
+	}
+	if c.Text == "" {
+		switch {
+		case ref.PubmedID != nil && *ref.PubmedID != "":
+			c.Text = "PubMed " + *ref.PubmedID
+		default:
+			c.Text = c.URL
+		}
+	}
+	return c
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+// humanize turns an enum value such as likely_pathogenic into words.
+func humanize(s string) string {
+	return strings.ReplaceAll(s, "_", " ")
+}
@@ -0,0 +1,174 @@
+package report
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mkoziy/genome/exporter/internal/genotype"
+	"github.com/mkoziy/genome/exporter/internal/models"
+	"github.com/mkoziy/genome/exporter/internal/pgx"
+)
+
+func strPtr(s string) *string { return &s }
+
+func testInput() Input {
+	recessive := &models.RiskAllele{Allele: "T", Effect: "pathogenic", ConditionName: "Cystic fibrosis", Inheritance: strPtr("Autosomal recessive inheritance"), Source: models.SourceClinVar}
+	drug := &models.RiskAllele{Allele: "A", Effect: "drug_response", ConditionName: "clopidogrel response", Source: models.SourceClinVar}
+	year := 2011
+	cftr := &models.SNP{
+		ID: 1, RsID: "rs113993960", GeneSymbol: strPtr("CFTR"), ReferenceAllele: "C", AlternateAlleles: models.StringArray{"T"},
+		Significance: &models.Significance{TotalScore: 92, ScoreDetails: models.ScoreBreakdown{Reasons: []string{"expert-panel pathogenic assertion (+40)"}}},
+		ClinicalData: []*models.ClinicalData{
+			{ClinicalSignificance: models.ClinicalPathogenic, ReviewStatus: models.ReviewExpertPanel, ConditionName: "Cystic fibrosis", Source: models.SourceClinVar},
+			{ClinicalSignificance: models.ClinicalBenign, ReviewStatus: models.ReviewSingleSubmitter, ConditionName: "not provided", Source: models.SourceClinVar},
+		},
+		References: []*models.Reference{
+			{PubmedID: strPtr("1"), CitationCount: 1},
+			{PubmedID: strPtr("2"), Authors: strPtr("Smith J"), PublicationYear: &year, Title: strPtr("CFTR_variants."), Journal: strPtr("Nature"), CitationCount: 9},
+		},
+		PopulationData: []*models.PopulationFreq{
+			{PopulationCode: "all", Allele: "T", Frequency: 0.02, Source: models.SourceGnomAD},
+		},
+		RiskAlleles: []*models.RiskAllele{recessive},
+	}
+	cyp := &models.SNP{
+		ID: 2, RsID: "rs4244285", GeneSymbol: strPtr("CYP2C19"), ReferenceAllele: "G", AlternateAlleles: models.StringArray{"A"},
+		Significance: &models.Significance{TotalScore: 55},
+		ClinicalData: []*models.ClinicalData{
+			{ClinicalSignificance: models.ClinicalDrugResponse, ReviewStatus: models.ReviewPracticeGuideline, ConditionName: "clopidogrel response", Source: models.SourceClinVar},
+		},
+		Phenotypes: []*models.Phenotype{
+			{ID: 7, PhenotypeName: "Eye color", AssociationType: "association", OddsRatio: &models.NullableFloat64{Float64: 1.5, Valid: true}, Source: models.SourceGWAS},
+		},
+		RiskAlleles: []*models.RiskAllele{drug},
+	}
+	return Input{
+		Calls: 10, NoCalls: 1,
+		Entries: []Entry{
+			{Genotype: "GG", SNP: cyp, Findings: genotype.Interpret([]string{"G", "G"}, cyp.RiskAlleles)},
+			{Genotype: "CT", Alleles: []string{"C", "T"}, SNP: cftr, Findings: genotype.Interpret([]string{"C", "T"}, cftr.RiskAlleles)},
+		},
+		Diplotypes: pgx.CallAll(pgx.Genes, pgx.Genotypes{
+			"rs4244285": {"A", "G"},
+			"rs1799853": {"C", "C"}, "rs1057910": {"A", "A"},
+		}),
+	}
+}
+
+func TestBuildGroupsByCategory(t *testing.T) {
+	r := Build(testInput(), Options{Now: time.Date(2026, 1, 2, 3, 4, 0, 0, time.UTC)})
+	if len(r.Sections) != 3 || r.Matched != 2 {
+		t.Fatalf("unexpected report: %+v", r)
+	}
+
+	clinical := r.Sections[0].Items
+	if len(clinical) != 1 || clinical[0].RsID != "rs113993960" || !clinical[0].Carried || clinical[0].Level != "Very High" {
+		t.Fatalf("unexpected clinical items: %+v", clinical)
+	}
+	if len(clinical[0].Evidence) != 1 || clinical[0].Evidence[0].Detail != "pathogenic, reviewed by expert panel" {
+		t.Fatalf("expected only the pathogenic assertion as evidence, got %+v", clinical[0].Evidence)
+	}
+	if len(clinical[0].Explanations) != 1 || !strings.Contains(clinical[0].Explanations[0], "carrier") {
+		t.Fatalf("expected a carrier explanation, got %v", clinical[0].Explanations)
+	}
+	if f := clinical[0].GenotypeFrequency; f == nil || *f < 0.0391 || *f > 0.0393 {
+		t.Fatalf("expected CT in 2pq = 3.92%% of people, got %v", f)
+	}
+	if drug := r.Sections[1].Items; len(drug) == 1 && drug[0].GenotypeFrequency != nil {
+		t.Fatalf("expected no genotype frequency without allele frequencies, got %v", *drug[0].GenotypeFrequency)
+	}
+	refs := clinical[0].References
+	if len(refs) != 2 || refs[0].Text != "Smith J (2011). CFTR_variants. Nature." || refs[1].URL != "https://pubmed.ncbi.nlm.nih.gov/1/" {
+		t.Fatalf("unexpected references: %+v", refs)
+	}
+
+	drug := r.Sections[1]
+	if len(drug.Items) != 1 || drug.Items[0].RsID != "rs4244285" || drug.Items[0].Carried {
+		t.Fatalf("unexpected drug response items: %+v", drug.Items)
+	}
+	if len(drug.Diplotypes) != 2 || drug.Diplotypes[0].Diplotype != "*1/*2" || drug.Diplotypes[1].Phenotype != pgx.PhenotypeNormal {
+		t.Fatalf("expected the tested CYP2C19 and CYP2C9 diplotypes, got %+v", drug.Diplotypes)
+	}
+	traits := r.Sections[2].Items
+	if len(traits) != 1 || traits[0].Evidence[0].Detail != "association, odds ratio 1.50" {
+		t.Fatalf("unexpected trait items: %+v", traits)
+	}
+
+	carried := Build(testInput(), Options{CarriedOnly: true})
+	if len(carried.Sections[0].Items) != 1 || len(carried.Sections[1].Items) != 0 || len(carried.Sections[2].Items) != 0 {
+		t.Fatalf("expected only the carried item, got %+v", carried.Sections)
+	}
+	if d := carried.Sections[1].Diplotypes; len(d) != 1 || d[0].Gene != "CYP2C19" {
+		t.Fatalf("expected normal metabolizers left out, got %+v", d)
+	}
+}
+
+func TestBuildKeepsLocalizedText(t *testing.T) {
+	in := testInput()
+	in.Entries[0].SNP.Summary = "Beeinflusst den Clopidogrel-Stoffwechsel."
+	r := Build(in, Options{Language: "de"})
+	if r.Language != "de" {
+		t.Fatalf("expected language de, got %q", r.Language)
+	}
+	if got := r.Sections[1].Items[0].Summary; got != "Beeinflusst den Clopidogrel-Stoffwechsel." {
+		t.Fatalf("expected the SNP's summary, got %q", got)
+	}
+}
+
+func TestRender(t *testing.T) {
+	r := Build(testInput(), Options{Title: "Report <for> *me*", Now: time.Date(2026, 1, 2, 3, 4, 0, 0, time.UTC)})
+
+	var md bytes.Buffer
+	if err := r.WriteMarkdown(&md); err != nil {
+		t.Fatalf("markdown: %v", err)
+	}
+	for _, want := range []string{
+		`# Report \<for\> \*me\*`,
+		"## Clinical findings",
+		"### rs113993960 (CFTR)",
+		"Genotype: **CT** (in about 4% of people) · Significance: **Very High** (92)",
+		`[Smith J (2011). CFTR\_variants. Nature.](https://pubmed.ncbi.nlm.nih.gov/2/)`,
+		`### CYP2C19 \*1/\*2`,
+		"Phenotype: **Intermediate metabolizer** (activity score 1)",
+		"- [clopidogrel](https://cpicpgx.org/guidelines/guideline-for-clopidogrel-and-cyp2c19/): Avoid standard-dose",
+		"Generated: 2026-01-02 03:04 UTC",
+	} {
+		if !strings.Contains(md.String(), want) {
+			t.Errorf("markdown lacks %q:\n%s", want, md.String())
+		}
+	}
+
+	var html bytes.Buffer
+	if err := r.WriteHTML(&html); err != nil {
+		t.Fatalf("html: %v", err)
+	}
+	for _, want := range []string{
+		"<title>Report &lt;for&gt; *me*</title>",
+		`<section id="pharmacogenomic">`,
+		"<h3>CYP2C19 *1/*2</h3>",
+		`<a href="https://pubmed.ncbi.nlm.nih.gov/2/">Smith J (2011). CFTR_variants. Nature.</a>`,
+	} {
+		if !strings.Contains(html.String(), want) {
+			t.Errorf("html lacks %q:\n%s", want, html.String())
+		}
+	}
+}
+
+func TestWritePDFPipesHTML(t *testing.T) {
+	r := Build(testInput(), Options{})
+	var out bytes.Buffer
+	if err := (PDFConverter{Command: []string{"cat"}}).WritePDF(context.Background(), &out, r); err != nil {
+		t.Fatalf("pdf: %v", err)
+	}
+	if !strings.HasPrefix(out.String(), "<!DOCTYPE html>") {
+		t.Fatalf("expected the converter to receive HTML, got %.40q", out.String())
+	}
+
+	err := (PDFConverter{Command: []string{"no-such-converter-xyz"}}).WritePDF(context.Background(), &out, r)
+	if err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Fatalf("expected a missing converter error, got %v", err)
+	}
+}
@@ -0,0 +1,37 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/uptrace/bun"
+
+	"github.com/mkoziy/genome/exporter/internal/models"
+)
+
+// GetRecordHistory returns audit entries for a single row, oldest first.
+func GetRecordHistory(ctx context.Context, db *bun.DB, table string, rowID int64) ([]*models.AuditEntry, error) {
+	var entries []*models.AuditEntry
+	err := db.NewSelect().
+		Model(&entries).
+		Where("table_name = ?", table).
+		Where("row_id = ?", rowID).
+		OrderExpr("al.id ASC").
+		Scan(ctx)
+
+	return entries, err
+}
+
+// GetChangesBetween returns audit entries recorded in [from, to), oldest first.
+// It answers questions like "what changed between the March and April ClinVar runs".
+func GetChangesBetween(ctx context.Context, db *bun.DB, from, to time.Time) ([]*models.AuditEntry, error) {
+	var entries []*models.AuditEntry
+	err := db.NewSelect().
+		Model(&entries).
+		Where("changed_at >= ?", from.UTC()).
+		Where("changed_at < ?", to.UTC()).
+		OrderExpr("al.id ASC").
+		Scan(ctx)
+
+	return entries, err
+}
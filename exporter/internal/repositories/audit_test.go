@@ -0,0 +1,74 @@
+package repositories
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mkoziy/genome/exporter/internal/models"
+)
+
+func TestAuditTriggersRecordChanges(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+
+	snp := testSNP("rs1", "1", 100)
+	if err := UpsertSNPs(ctx, db, []*models.SNP{snp}); err != nil {
+		t.Fatalf("upsert: %v", err)
+	}
+
+	// Re-running with identical values must not produce audit entries.
+	if err := UpsertSNPs(ctx, db, []*models.SNP{testSNP("rs1", "1", 100)}); err != nil {
+		t.Fatalf("upsert: %v", err)
+	}
+
+	before := time.Now().Add(-time.Minute)
+	if err := UpsertSNPs(ctx, db, []*models.SNP{testSNP("rs1", "1", 150)}); err != nil {
+		t.Fatalf("upsert: %v", err)
+	}
+
+	stored, err := GetSNPByRsID(ctx, db, "rs1")
+	if err != nil {
+		t.Fatalf("get snp: %v", err)
+	}
+
+	history, err := GetRecordHistory(ctx, db, "snps", stored.ID)
+	if err != nil {
+		t.Fatalf("history: %v", err)
+	}
+	if len(history) != 1 {
+		t.Fatalf("expected 1 audit entry, got %d", len(history))
+	}
+	entry := history[0]
+	if entry.Operation != models.AuditUpdate {
+		t.Fatalf("unexpected operation: %s", entry.Operation)
+	}
+	if entry.OldValues["position"] != float64(100) || entry.NewValues["position"] != float64(150) {
+		t.Fatalf("unexpected before/after values: %v -> %v", entry.OldValues, entry.NewValues)
+	}
+
+	changes, err := GetChangesBetween(ctx, db, before, time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatalf("changes: %v", err)
+	}
+	if len(changes) != 1 {
+		t.Fatalf("expected 1 change in window, got %d", len(changes))
+	}
+
+	clinical := &models.ClinicalData{SNPID: stored.ID, ClinicalSignificance: models.ClinicalUncertainSignif, ReviewStatus: models.ReviewSingleSubmitter, ConditionName: "X", Source: models.SourceClinVar}
+	if _, err := db.NewInsert().Model(clinical).Exec(ctx); err != nil {
+		t.Fatalf("insert clinical: %v", err)
+	}
+	clinical.ClinicalSignificance = models.ClinicalPathogenic
+	if _, err := db.NewUpdate().Model(clinical).WherePK().Exec(ctx); err != nil {
+		t.Fatalf("update clinical: %v", err)
+	}
+
+	history, err = GetRecordHistory(ctx, db, "snp_clinical", clinical.ID)
+	if err != nil {
+		t.Fatalf("history: %v", err)
+	}
+	if len(history) != 1 || history[0].OldValues["clinical_significance"] != string(models.ClinicalUncertainSignif) {
+		t.Fatalf("unexpected clinical history: %+v", history)
+	}
+}
@@ -0,0 +1,192 @@
+package repositories
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/mkoziy/genome/exporter/internal/models"
+)
+
+// CachedSNPRepository is a read-through LRU cache in front of another
+// SNPRepository, bounded by entry count and TTL. It targets the API server,
+// where a few popular rsIDs (APOE, MTHFR) are looked up constantly.
+//
+// Cached SNPs are shared between callers and must be treated as read-only.
+// Unknown rsIDs are not cached. Upsert through the cache invalidates the
+// written rsIDs; writes that bypass it become visible after the TTL.
+type CachedSNPRepository struct {
+	next SNPRepository
+	size int
+	ttl  time.Duration
+	now  func() time.Time
+
+	mu      sync.Mutex
+	order   *list.List // front is most recently used
+	entries map[string]*list.Element
+}
+
+type cacheEntry struct {
+	rsID    string
+	snp     *models.SNP
+	expires time.Time
+}
+
+// NewCachedSNPRepository wraps next with an LRU of at most size entries that
+// expire after ttl. A non-positive ttl disables expiry.
+func NewCachedSNPRepository(next SNPRepository, size int, ttl time.Duration) *CachedSNPRepository {
+	if size <= 0 {
+		size = 1
+	}
+	return &CachedSNPRepository{
+		next:    next,
+		size:    size,
+		ttl:     ttl,
+		now:     time.Now,
+		order:   list.New(),
+		entries: make(map[string]*list.Element, size),
+	}
+}
+
+// GetByRsID returns the cached SNP or loads it from the wrapped repository.
+func (c *CachedSNPRepository) GetByRsID(ctx context.Context, rsID string) (*models.SNP, error) {
+	if snp, ok := c.get(rsID); ok {
+		return snp, nil
+	}
+
+	snp, err := c.next.GetByRsID(ctx, rsID)
+	if err != nil {
+		return nil, err
+	}
+	c.put(snp)
+	return snp, nil
+}
+
+// GetByRsIDs serves cached rsIDs and loads the rest with a single call to the
+// wrapped repository.
+func (c *CachedSNPRepository) GetByRsIDs(ctx context.Context, rsIDs []string) (map[string]*models.SNP, error) {
+	result := make(map[string]*models.SNP, len(rsIDs))
+	missing := make([]string, 0)
+	for _, rsID := range rsIDs {
+		if snp, ok := c.get(rsID); ok {
+			result[rsID] = snp
+		} else {
+			missing = append(missing, rsID)
+		}
+	}
+	if len(missing) == 0 {
+		return result, nil
+	}
+
+	loaded, err := c.next.GetByRsIDs(ctx, missing)
+	if err != nil {
+		return nil, err
+	}
+	for rsID, snp := range loaded {
+		c.put(snp)
+		result[rsID] = snp
+	}
+	return result, nil
+}
+
+// GetByVariantKeys is not cached, since the cache is keyed by rsID; the
+// SNPs it loads are cached for later rsID lookups.
+func (c *CachedSNPRepository) GetByVariantKeys(ctx context.Context, keys []string) ([]*models.SNP, error) {
+	snps, err := c.next.GetByVariantKeys(ctx, keys)
+	if err != nil {
+		return nil, err
+	}
+	for _, snp := range snps {
+		c.put(snp)
+	}
+	return snps, nil
+}
+
+// Upsert writes through to the wrapped repository and drops the written rsIDs.
+func (c *CachedSNPRepository) Upsert(ctx context.Context, snps []*models.SNP) error {
+	c.mu.Lock()
+	for _, snp := range snps {
+		c.remove(snp.RsID)
+	}
+	c.mu.Unlock()
+
+	return c.next.Upsert(ctx, snps)
+}
+
+// ForEach bypasses the cache; full scans would only evict the hot entries.
+func (c *CachedSNPRepository) ForEach(ctx context.Context, batchSize int, fn func(batch []*models.SNP) error) error {
+	return c.next.ForEach(ctx, batchSize, fn)
+}
+
+// ForEachStale bypasses the cache for the same reason as ForEach.
+func (c *CachedSNPRepository) ForEachStale(ctx context.Context, batchSize int, fn func(batch []*models.SNP) error) error {
+	return c.next.ForEachStale(ctx, batchSize, fn)
+}
+
+// Invalidate drops rsIDs from the cache, e.g. after an import wrote them directly.
+func (c *CachedSNPRepository) Invalidate(rsIDs ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, rsID := range rsIDs {
+		c.remove(rsID)
+	}
+}
+
+// Purge empties the cache.
+func (c *CachedSNPRepository) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.order.Init()
+	c.entries = make(map[string]*list.Element, c.size)
+}
+
+// Len returns the number of cached entries, including expired ones not yet evicted.
+func (c *CachedSNPRepository) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+func (c *CachedSNPRepository) get(rsID string) (*models.SNP, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.entries[rsID]
+	if !ok {
+		return nil, false
+	}
+	entry := el.Value.(*cacheEntry)
+	if c.ttl > 0 && !c.now().Before(entry.expires) {
+		c.remove(rsID)
+		return nil, false
+	}
+	c.order.MoveToFront(el)
+	return entry.snp, true
+}
+
+func (c *CachedSNPRepository) put(snp *models.SNP) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry := &cacheEntry{rsID: snp.RsID, snp: snp, expires: c.now().Add(c.ttl)}
+	if el, ok := c.entries[snp.RsID]; ok {
+		el.Value = entry
+		c.order.MoveToFront(el)
+		return
+	}
+
+	c.entries[snp.RsID] = c.order.PushFront(entry)
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.remove(oldest.Value.(*cacheEntry).rsID)
+	}
+}
+
+// remove drops rsID; callers must hold mu.
+func (c *CachedSNPRepository) remove(rsID string) {
+	if el, ok := c.entries[rsID]; ok {
+		c.order.Remove(el)
+		delete(c.entries, rsID)
+	}
+}
@@ -0,0 +1,89 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mkoziy/genome/exporter/internal/models"
+)
+
+// countingSNPRepository counts lookups that reach the wrapped repository.
+type countingSNPRepository struct {
+	SNPRepository
+	lookups int
+}
+
+func (r *countingSNPRepository) GetByRsID(ctx context.Context, rsID string) (*models.SNP, error) {
+	r.lookups++
+	return r.SNPRepository.GetByRsID(ctx, rsID)
+}
+
+func (r *countingSNPRepository) GetByRsIDs(ctx context.Context, rsIDs []string) (map[string]*models.SNP, error) {
+	r.lookups += len(rsIDs)
+	return r.SNPRepository.GetByRsIDs(ctx, rsIDs)
+}
+
+func TestCachedSNPRepository(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+
+	if err := UpsertSNPs(ctx, db, []*models.SNP{testSNP("rs1", "1", 100), testSNP("rs2", "2", 200), testSNP("rs3", "3", 300)}); err != nil {
+		t.Fatalf("upsert: %v", err)
+	}
+
+	backend := &countingSNPRepository{SNPRepository: NewBunRepositories(db).SNPs}
+	cache := NewCachedSNPRepository(backend, 2, time.Minute)
+	now := time.Now()
+	cache.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		if _, err := cache.GetByRsID(ctx, "rs1"); err != nil {
+			t.Fatalf("get: %v", err)
+		}
+	}
+	if backend.lookups != 1 {
+		t.Fatalf("expected 1 backend lookup, got %d", backend.lookups)
+	}
+
+	if _, err := cache.GetByRsID(ctx, "rs404"); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("expected sql.ErrNoRows, got %v", err)
+	}
+
+	// rs1 is cached; only rs2 and rs3 go to the backend, and rs1 is evicted
+	// as the least recently used entry once the cache holds more than 2.
+	backend.lookups = 0
+	got, err := cache.GetByRsIDs(ctx, []string{"rs1", "rs2", "rs3"})
+	if err != nil {
+		t.Fatalf("get many: %v", err)
+	}
+	if len(got) != 3 || backend.lookups != 2 {
+		t.Fatalf("expected 3 SNPs with 2 backend lookups, got %d and %d", len(got), backend.lookups)
+	}
+	if cache.Len() != 2 {
+		t.Fatalf("expected cache bounded to 2 entries, got %d", cache.Len())
+	}
+
+	backend.lookups = 0
+	now = now.Add(2 * time.Minute)
+	if _, err := cache.GetByRsID(ctx, "rs3"); err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if backend.lookups != 1 {
+		t.Fatalf("expected expired entry to be reloaded")
+	}
+
+	backend.lookups = 0
+	if err := cache.Upsert(ctx, []*models.SNP{testSNP("rs3", "3", 301)}); err != nil {
+		t.Fatalf("upsert: %v", err)
+	}
+	snp, err := cache.GetByRsID(ctx, "rs3")
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if backend.lookups != 1 || snp.Position != 301 {
+		t.Fatalf("expected upsert to invalidate rs3, got position %d after %d lookups", snp.Position, backend.lookups)
+	}
+}
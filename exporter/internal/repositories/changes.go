@@ -0,0 +1,94 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/uptrace/bun"
+
+	"github.com/mkoziy/genome/exporter/internal/models"
+)
+
+// BeginChangeRun tags the changes recorded from now on with runID, until
+// EndChangeRun.
+func BeginChangeRun(ctx context.Context, db bun.IDB, runID string) error {
+	_, err := db.NewRaw("INSERT OR REPLACE INTO ? (id, run_id) VALUES (1, ?)", bun.Ident(models.ChangeRunTable), runID).Exec(ctx)
+	return err
+}
+
+// EndChangeRun stops tagging changes with a run ID.
+func EndChangeRun(ctx context.Context, db bun.IDB) error {
+	_, err := db.NewRaw("DELETE FROM ?", bun.Ident(models.ChangeRunTable)).Exec(ctx)
+	return err
+}
+
+// ListChanges returns up to limit changes recorded after the change with
+// ID afterID, oldest first.
+func ListChanges(ctx context.Context, db bun.IDB, afterID int64, limit int) ([]*models.Change, error) {
+	changes := make([]*models.Change, 0)
+	err := db.NewSelect().
+		Model(&changes).
+		Where("cf.id > ?", afterID).
+		OrderExpr("cf.id ASC").
+		Limit(limit).
+		Scan(ctx)
+	return changes, err
+}
+
+// LastChangeID returns the ID of the latest change, 0 if there is none. A
+// consumer that has just loaded the full dataset resumes after it.
+func LastChangeID(ctx context.Context, db bun.IDB) (int64, error) {
+	var id int64
+	err := db.NewSelect().
+		Model((*models.Change)(nil)).
+		ColumnExpr("COALESCE(MAX(cf.id), 0)").
+		Scan(ctx, &id)
+	return id, err
+}
+
+// PruneChanges deletes the changes recorded before the given time and
+// returns how many it deleted. Consumers further behind must reload the
+// full dataset.
+func PruneChanges(ctx context.Context, db bun.IDB, before time.Time) (int64, error) {
+	res, err := db.NewDelete().
+		Model((*models.Change)(nil)).
+		Where("changed_at < ?", before.UTC()).
+		Exec(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// GetSNPsByIDs fetches SNPs with related data by primary key, keyed by ID.
+// IDs of deleted SNPs are absent. Lookups are chunked like GetSNPsByRsIDs.
+func GetSNPsByIDs(ctx context.Context, db *bun.DB, ids []int64) (map[int64]*models.SNP, error) {
+	result := make(map[int64]*models.SNP, len(ids))
+	for start := 0; start < len(ids); start += rsIDChunkSize {
+		end := min(start+rsIDChunkSize, len(ids))
+		var snps []*models.SNP
+		err := db.NewSelect().
+			Model(&snps).
+			Where("s.id IN (?)", bun.In(ids[start:end])).
+			Relation("Significance").
+			Relation("Quality").
+			Relation("Frequency").
+			Relation("HardyWeinberg").
+			Relation("ClinicalData").
+			Relation("Phenotypes").
+			Relation("References").
+			Relation("PopulationData").
+			Relation("RiskAlleles").
+			Relation("GenotypeEffects").
+			Relation("ClinicalAgreements").
+			Relation("Haplotypes.Haplotype").
+			Scan(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, snp := range snps {
+			result[snp.ID] = snp
+		}
+	}
+	return result, nil
+}
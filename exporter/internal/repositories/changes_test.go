@@ -0,0 +1,86 @@
+package repositories
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mkoziy/genome/exporter/internal/models"
+)
+
+func TestChangeFeed(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+
+	snp := testSNP("rs1", "1", 100)
+	if _, err := db.NewInsert().Model(snp).Exec(ctx); err != nil {
+		t.Fatalf("insert snp: %v", err)
+	}
+	if err := BeginChangeRun(ctx, db, "run-1"); err != nil {
+		t.Fatalf("begin run: %v", err)
+	}
+	clinical := &models.ClinicalData{SNPID: snp.ID, ClinicalSignificance: models.ClinicalUncertainSignif, ReviewStatus: models.ReviewCriteriaProvided,
+		ConditionName: "Cystic fibrosis", Source: models.SourceClinVar}
+	if _, err := db.NewInsert().Model(clinical).Exec(ctx); err != nil {
+		t.Fatalf("insert clinical: %v", err)
+	}
+	// Rewriting the same values is not a change.
+	if _, err := db.NewUpdate().Model(clinical).WherePK().Exec(ctx); err != nil {
+		t.Fatalf("rewrite clinical: %v", err)
+	}
+	clinical.ClinicalSignificance = models.ClinicalPathogenic
+	if _, err := db.NewUpdate().Model(clinical).WherePK().Exec(ctx); err != nil {
+		t.Fatalf("update clinical: %v", err)
+	}
+	if err := EndChangeRun(ctx, db); err != nil {
+		t.Fatalf("end run: %v", err)
+	}
+	if _, err := db.NewDelete().Model(clinical).WherePK().ForceDelete().Exec(ctx); err != nil {
+		t.Fatalf("delete clinical: %v", err)
+	}
+
+	changes, err := ListChanges(ctx, db, 0, 100)
+	if err != nil {
+		t.Fatalf("list changes: %v", err)
+	}
+	want := []struct {
+		table, op, run string
+	}{
+		{"snps", models.ChangeInsert, ""},
+		{"snp_clinical", models.ChangeInsert, "run-1"},
+		{"snp_clinical", models.ChangeUpdate, "run-1"},
+		{"snp_clinical", models.ChangeDelete, ""},
+	}
+	if len(changes) != len(want) {
+		t.Fatalf("expected %d changes, got %d", len(want), len(changes))
+	}
+	for i, w := range want {
+		c := changes[i]
+		var run string
+		if c.RunID != nil {
+			run = *c.RunID
+		}
+		if c.RecordType != w.table || c.Operation != w.op || run != w.run || c.SNPID != snp.ID || c.RsID == nil || *c.RsID != "rs1" {
+			t.Fatalf("change %d: got %s %s run %q snp %d, want %s %s run %q", i, c.RecordType, c.Operation, run, c.SNPID, w.table, w.op, w.run)
+		}
+	}
+
+	after, err := ListChanges(ctx, db, changes[1].ID, 1)
+	if err != nil || len(after) != 1 || after[0].ID != changes[2].ID {
+		t.Fatalf("expected to resume after the second change, got %v (%v)", after, err)
+	}
+	last, err := LastChangeID(ctx, db)
+	if err != nil || last != changes[3].ID {
+		t.Fatalf("expected last change %d, got %d (%v)", changes[3].ID, last, err)
+	}
+
+	snps, err := GetSNPsByIDs(ctx, db, []int64{snp.ID, snp.ID + 1})
+	if err != nil || len(snps) != 1 || snps[snp.ID].RsID != "rs1" {
+		t.Fatalf("expected rs1 by ID, got %v (%v)", snps, err)
+	}
+
+	n, err := PruneChanges(ctx, db, time.Now().Add(time.Hour))
+	if err != nil || n != 4 {
+		t.Fatalf("expected 4 changes pruned, got %d (%v)", n, err)
+	}
+}
@@ -0,0 +1,133 @@
+package repositories
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/uptrace/bun"
+
+	"github.com/mkoziy/genome/exporter/internal/models"
+)
+
+// maxConditionMatches caps how many clinical rows a condition search considers.
+const maxConditionMatches = 5000
+
+// ConditionMatch groups the SNPs annotated with one condition.
+type ConditionMatch struct {
+	ConditionName string        `json:"condition_name"`
+	ConditionID   *string       `json:"condition_id,omitempty"`
+	SNPs          []*models.SNP `json:"snps"`
+}
+
+// SearchByCondition finds SNPs by condition. An exact ConditionID match (e.g. a MedGen CUI)
+// wins; otherwise condition names are matched case-insensitively, ranking exact names
+// before prefix matches before substring matches. The LIKE fallback is the place
+// to swap in ranked full-text results once an FTS index exists.
+func SearchByCondition(ctx context.Context, db *bun.DB, query string) ([]*ConditionMatch, error) {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return nil, nil
+	}
+
+	var clinical []*models.ClinicalData
+	err := db.NewSelect().
+		Model(&clinical).
+		Where("c.condition_id = ?", query).
+		Limit(maxConditionMatches).
+		Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(clinical) == 0 {
+		err = db.NewSelect().
+			Model(&clinical).
+			Where("c.condition_name LIKE ? ESCAPE '\\'", "%"+escapeLike(query)+"%").
+			Limit(maxConditionMatches).
+			Scan(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(clinical) == 0 {
+		return nil, nil
+	}
+
+	groups := make(map[string]*ConditionMatch)
+	snpIDs := make(map[string]map[int64]bool)
+	allIDs := make([]int64, 0, len(clinical))
+	seenID := make(map[int64]bool)
+	for _, c := range clinical {
+		key := conditionKey(c)
+		if _, ok := groups[key]; !ok {
+			groups[key] = &ConditionMatch{ConditionName: c.ConditionName, ConditionID: c.ConditionID}
+			snpIDs[key] = make(map[int64]bool)
+		}
+		snpIDs[key][c.SNPID] = true
+		if !seenID[c.SNPID] {
+			seenID[c.SNPID] = true
+			allIDs = append(allIDs, c.SNPID)
+		}
+	}
+
+	var snps []*models.SNP
+	err = db.NewSelect().
+		Model(&snps).
+		Relation("Significance").
+		Relation("ClinicalData").
+		Where("s.id IN (?)", bun.In(allIDs)).
+		OrderExpr("s.id ASC").
+		Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*ConditionMatch, 0, len(groups))
+	for key, group := range groups {
+		for _, snp := range snps {
+			if snpIDs[key][snp.ID] {
+				group.SNPs = append(group.SNPs, snp)
+			}
+		}
+		result = append(result, group)
+	}
+
+	lower := strings.ToLower(query)
+	sort.SliceStable(result, func(i, j int) bool {
+		ri, rj := nameRank(result[i].ConditionName, lower), nameRank(result[j].ConditionName, lower)
+		if ri != rj {
+			return ri < rj
+		}
+		if len(result[i].SNPs) != len(result[j].SNPs) {
+			return len(result[i].SNPs) > len(result[j].SNPs)
+		}
+		return result[i].ConditionName < result[j].ConditionName
+	})
+
+	return result, nil
+}
+
+func conditionKey(c *models.ClinicalData) string {
+	if c.ConditionID != nil && *c.ConditionID != "" {
+		return "id:" + *c.ConditionID
+	}
+	return "name:" + strings.ToLower(c.ConditionName)
+}
+
+func nameRank(name, lowerQuery string) int {
+	name = strings.ToLower(name)
+	switch {
+	case name == lowerQuery:
+		return 0
+	case strings.HasPrefix(name, lowerQuery):
+		return 1
+	default:
+		return 2
+	}
+}
+
+// escapeLike escapes LIKE wildcards so user input is matched literally.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
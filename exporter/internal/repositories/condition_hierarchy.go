@@ -0,0 +1,147 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+
+	"github.com/uptrace/bun"
+
+	"github.com/mkoziy/genome/exporter/internal/models"
+)
+
+// conditionBatchSize is how many conditions or relations SaveConditions
+// inserts per statement; ontologies have tens of thousands of terms.
+const conditionBatchSize = 500
+
+// subtypesSQL selects the ID of a condition and of every condition under
+// it, however deep, from a recursive walk of condition_relations. UNION
+// rather than UNION ALL keeps the walk finite should the hierarchy loop.
+const subtypesSQL = `WITH RECURSIVE subtypes(id) AS (
+		SELECT ?
+		UNION
+		SELECT r.child_id FROM condition_relations AS r JOIN subtypes ON r.parent_id = subtypes.id
+	)`
+
+// SaveConditions inserts conditions, replacing the name, ontology and
+// MedGen ID of those already known, and replaces the parents of each of them
+// with those relations give, in one transaction.
+func SaveConditions(ctx context.Context, db *bun.DB, conditions []*models.Condition, relations []*models.ConditionRelation) error {
+	for _, c := range conditions {
+		if err := c.Validate(); err != nil {
+			return err
+		}
+	}
+	return db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		for start := 0; start < len(conditions); start += conditionBatchSize {
+			batch := conditions[start:min(start+conditionBatchSize, len(conditions))]
+			_, err := tx.NewInsert().
+				Model(&batch).
+				On("CONFLICT (id) DO UPDATE").
+				Set("name = EXCLUDED.name").
+				Set("ontology = EXCLUDED.ontology").
+				Set("medgen_id = EXCLUDED.medgen_id").
+				Set("updated_at = CURRENT_TIMESTAMP").
+				Exec(ctx)
+			if err != nil {
+				return err
+			}
+			ids := make([]string, len(batch))
+			for i, c := range batch {
+				ids[i] = c.ID
+			}
+			if _, err := tx.NewDelete().Model((*models.ConditionRelation)(nil)).Where("child_id IN (?)", bun.In(ids)).Exec(ctx); err != nil {
+				return err
+			}
+		}
+		for start := 0; start < len(relations); start += conditionBatchSize {
+			batch := relations[start:min(start+conditionBatchSize, len(relations))]
+			if _, err := tx.NewInsert().Model(&batch).On("CONFLICT (parent_id, child_id) DO NOTHING").Exec(ctx); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// FindCondition returns the condition query names: by ID, by MedGen ID or
+// by its name in any case, in that order. It returns sql.ErrNoRows if none
+// matches.
+func FindCondition(ctx context.Context, db bun.IDB, query string) (*models.Condition, error) {
+	query = strings.TrimSpace(query)
+	for _, where := range []string{"cond.id = ?", "cond.medgen_id = ?", "lower(cond.name) = lower(?)"} {
+		c := new(models.Condition)
+		err := db.NewSelect().Model(c).Where(where, query).OrderExpr("cond.id ASC").Limit(1).Scan(ctx)
+		if err == nil {
+			return c, nil
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+// GetConditionParents returns the conditions id is a direct subtype of,
+// ordered by name.
+func GetConditionParents(ctx context.Context, db bun.IDB, id string) ([]*models.Condition, error) {
+	parents := make([]*models.Condition, 0)
+	err := db.NewSelect().
+		Model(&parents).
+		Where("cond.id IN (SELECT parent_id FROM condition_relations WHERE child_id = ?)", id).
+		OrderExpr("cond.name ASC").
+		Scan(ctx)
+	return parents, err
+}
+
+// GetConditionChildren returns the direct subtypes of id, ordered by name.
+func GetConditionChildren(ctx context.Context, db bun.IDB, id string) ([]*models.Condition, error) {
+	children := make([]*models.Condition, 0)
+	err := db.NewSelect().
+		Model(&children).
+		Where("cond.id IN (SELECT child_id FROM condition_relations WHERE parent_id = ?)", id).
+		OrderExpr("cond.name ASC").
+		Scan(ctx)
+	return children, err
+}
+
+// GetConditionSubtypes returns every condition under id, however deep, not
+// including id itself, ordered by name.
+func GetConditionSubtypes(ctx context.Context, db bun.IDB, id string) ([]*models.Condition, error) {
+	subtypes := make([]*models.Condition, 0)
+	err := db.NewSelect().
+		Model(&subtypes).
+		Where("cond.id IN ("+subtypesSQL+" SELECT id FROM subtypes)", id).
+		Where("cond.id != ?", id).
+		OrderExpr("cond.name ASC").
+		Scan(ctx)
+	return subtypes, err
+}
+
+// GetConditionRollup returns the report of a condition and all of its
+// subtypes, however deep: every SNP with a clinical assertion naming any of
+// them, by its ID or MedGen ID, ordered by total score, highest first. The
+// condition is named as FindCondition takes it. It returns sql.ErrNoRows if
+// no condition matches.
+func GetConditionRollup(ctx context.Context, db *bun.DB, query string) (*ConditionReport, error) {
+	condition, err := FindCondition(ctx, db, query)
+	if err != nil {
+		return nil, err
+	}
+	var clinical []*models.ClinicalData
+	err = db.NewSelect().
+		Model(&clinical).
+		Where("c.condition_id IN ("+subtypesSQL+` SELECT id FROM subtypes
+			UNION SELECT medgen_id FROM conditions WHERE id IN (SELECT id FROM subtypes) AND medgen_id IS NOT NULL)`, condition.ID).
+		OrderExpr("c.id ASC").
+		Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	variants, err := conditionVariants(ctx, db, clinical)
+	if err != nil {
+		return nil, err
+	}
+	return &ConditionReport{ConditionID: condition.ID, ConditionName: condition.Name, Variants: variants}, nil
+}
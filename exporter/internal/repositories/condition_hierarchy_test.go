@@ -0,0 +1,89 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/mkoziy/genome/exporter/internal/models"
+)
+
+func TestConditionHierarchy(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+
+	cardio, hcm, dcm := "C0878544", "C0007194", "C0007193"
+	conditions := []*models.Condition{
+		{ID: "MONDO:0004994", Name: "cardiomyopathy", Ontology: models.OntologyMONDO, MedGenID: &cardio},
+		{ID: "MONDO:0005045", Name: "hypertrophic cardiomyopathy", Ontology: models.OntologyMONDO, MedGenID: &hcm},
+		{ID: "MONDO:0005021", Name: "dilated cardiomyopathy", Ontology: models.OntologyMONDO, MedGenID: &dcm},
+		{ID: "MONDO:0024573", Name: "familial hypertrophic cardiomyopathy 1", Ontology: models.OntologyMONDO},
+		{ID: "MONDO:0005267", Name: "heart disorder", Ontology: models.OntologyMONDO},
+	}
+	relations := []*models.ConditionRelation{
+		{ParentID: "MONDO:0005267", ChildID: "MONDO:0004994"},
+		{ParentID: "MONDO:0004994", ChildID: "MONDO:0005045"},
+		{ParentID: "MONDO:0004994", ChildID: "MONDO:0005021"},
+		{ParentID: "MONDO:0005045", ChildID: "MONDO:0024573"},
+	}
+	if err := SaveConditions(ctx, db, conditions, relations); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+
+	snps := []*models.SNP{testSNP("rs1", "1", 1), testSNP("rs2", "1", 2), testSNP("rs3", "1", 3), testSNP("rs4", "1", 4)}
+	if _, err := db.NewInsert().Model(&snps).Exec(ctx); err != nil {
+		t.Fatalf("insert snps: %v", err)
+	}
+	fhc := "MONDO:0024573"
+	clinical := []*models.ClinicalData{
+		{SNPID: snps[0].ID, ConditionName: "Hypertrophic cardiomyopathy", ConditionID: &hcm},
+		{SNPID: snps[1].ID, ConditionName: "Dilated cardiomyopathy", ConditionID: &dcm},
+		{SNPID: snps[2].ID, ConditionName: "Familial hypertrophic cardiomyopathy 1", ConditionID: &fhc},
+		{SNPID: snps[3].ID, ConditionName: "Long QT syndrome"},
+	}
+	for _, c := range clinical {
+		c.ClinicalSignificance = models.ClinicalPathogenic
+		c.ReviewStatus = models.ReviewCriteriaProvided
+		c.Source = models.SourceClinVar
+	}
+	if _, err := db.NewInsert().Model(&clinical).Exec(ctx); err != nil {
+		t.Fatalf("insert clinical: %v", err)
+	}
+
+	report, err := GetConditionRollup(ctx, db, "Cardiomyopathy")
+	if err != nil {
+		t.Fatalf("rollup: %v", err)
+	}
+	if report.ConditionID != "MONDO:0004994" || len(report.Variants) != 3 {
+		t.Fatalf("expected the 3 SNPs under cardiomyopathy, got %s with %d", report.ConditionID, len(report.Variants))
+	}
+	report, err = GetConditionRollup(ctx, db, hcm)
+	if err != nil {
+		t.Fatalf("rollup by MedGen ID: %v", err)
+	}
+	if len(report.Variants) != 2 {
+		t.Fatalf("expected hypertrophic cardiomyopathy and its subtype, got %d SNPs", len(report.Variants))
+	}
+	if _, err := GetConditionRollup(ctx, db, "not a condition"); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("expected sql.ErrNoRows, got %v", err)
+	}
+
+	subtypes, err := GetConditionSubtypes(ctx, db, "MONDO:0004994")
+	if err != nil || len(subtypes) != 3 {
+		t.Fatalf("expected 3 subtypes, got %d, %v", len(subtypes), err)
+	}
+	parents, err := GetConditionParents(ctx, db, "MONDO:0005045")
+	if err != nil || len(parents) != 1 || parents[0].ID != "MONDO:0004994" {
+		t.Fatalf("unexpected parents %+v, %v", parents, err)
+	}
+
+	// Reloading a condition replaces its parents.
+	if err := SaveConditions(ctx, db, conditions[2:3], []*models.ConditionRelation{{ParentID: "MONDO:0005267", ChildID: "MONDO:0005021"}}); err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	children, err := GetConditionChildren(ctx, db, "MONDO:0004994")
+	if err != nil || len(children) != 1 || children[0].ID != "MONDO:0005045" {
+		t.Fatalf("expected dilated cardiomyopathy moved, got %+v, %v", children, err)
+	}
+}
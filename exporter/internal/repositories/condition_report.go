@@ -0,0 +1,104 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+
+	"github.com/uptrace/bun"
+
+	"github.com/mkoziy/genome/exporter/internal/models"
+)
+
+// ConditionReport is the evidence bundle for one condition.
+type ConditionReport struct {
+	ConditionID   string              `json:"condition_id"`
+	ConditionName string              `json:"condition_name"`
+	Variants      []*ConditionVariant `json:"variants"`
+}
+
+// ConditionVariant is a SNP linked to the condition. Assertions holds only the
+// clinical rows for this condition; the SNP carries its significance, population
+// frequencies and references.
+type ConditionVariant struct {
+	SNP        *models.SNP            `json:"snp"`
+	Assertions []*models.ClinicalData `json:"assertions"`
+}
+
+// GetConditionReport returns all SNPs linked to conditionID (e.g. a MedGen CUI),
+// ordered by total score, highest first. It returns sql.ErrNoRows if no clinical
+// annotation references the condition.
+func GetConditionReport(ctx context.Context, db *bun.DB, conditionID string) (*ConditionReport, error) {
+	var clinical []*models.ClinicalData
+	err := db.NewSelect().
+		Model(&clinical).
+		Where("c.condition_id = ?", conditionID).
+		OrderExpr("c.id ASC").
+		Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(clinical) == 0 {
+		return nil, sql.ErrNoRows
+	}
+
+	variants, err := conditionVariants(ctx, db, clinical)
+	if err != nil {
+		return nil, err
+	}
+	return &ConditionReport{
+		ConditionID:   conditionID,
+		ConditionName: clinical[0].ConditionName,
+		Variants:      variants,
+	}, nil
+}
+
+// conditionVariants returns the SNPs clinical annotates, with their
+// significance, references and population frequencies, each holding its
+// rows of clinical, ordered by total score, highest first.
+func conditionVariants(ctx context.Context, db *bun.DB, clinical []*models.ClinicalData) ([]*ConditionVariant, error) {
+	variants := make([]*ConditionVariant, 0)
+	if len(clinical) == 0 {
+		return variants, nil
+	}
+	assertions := make(map[int64][]*models.ClinicalData)
+	snpIDs := make([]int64, 0)
+	for _, c := range clinical {
+		if _, ok := assertions[c.SNPID]; !ok {
+			snpIDs = append(snpIDs, c.SNPID)
+		}
+		assertions[c.SNPID] = append(assertions[c.SNPID], c)
+	}
+
+	var snps []*models.SNP
+	err := db.NewSelect().
+		Model(&snps).
+		Relation("Significance").
+		Relation("References").
+		Relation("PopulationData").
+		Where("s.id IN (?)", bun.In(snpIDs)).
+		Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, snp := range snps {
+		variants = append(variants, &ConditionVariant{SNP: snp, Assertions: assertions[snp.ID]})
+	}
+
+	sort.SliceStable(variants, func(i, j int) bool {
+		si, sj := totalScore(variants[i].SNP), totalScore(variants[j].SNP)
+		if si != sj {
+			return si > sj
+		}
+		return variants[i].SNP.RsID < variants[j].SNP.RsID
+	})
+	return variants, nil
+}
+
+// totalScore returns the SNP's total score, or -1 if it has not been scored.
+func totalScore(snp *models.SNP) float64 {
+	if snp.Significance == nil {
+		return -1
+	}
+	return snp.Significance.TotalScore
+}
@@ -0,0 +1,111 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/mkoziy/genome/exporter/internal/models"
+)
+
+func TestSearchByCondition(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+
+	snps := []*models.SNP{testSNP("rs1", "1", 1), testSNP("rs2", "1", 2), testSNP("rs3", "1", 3)}
+	if _, err := db.NewInsert().Model(&snps).Exec(ctx); err != nil {
+		t.Fatalf("insert snps: %v", err)
+	}
+	cardio := "C0007194"
+	clinical := []*models.ClinicalData{
+		{SNPID: snps[0].ID, ConditionName: "Hypertrophic cardiomyopathy", ConditionID: &cardio},
+		{SNPID: snps[1].ID, ConditionName: "Hypertrophic cardiomyopathy", ConditionID: &cardio},
+		{SNPID: snps[2].ID, ConditionName: "Cardiomyopathy"},
+		{SNPID: snps[2].ID, ConditionName: "Long QT syndrome"},
+	}
+	for _, c := range clinical {
+		c.ClinicalSignificance = models.ClinicalPathogenic
+		c.ReviewStatus = models.ReviewCriteriaProvided
+		c.Source = models.SourceClinVar
+	}
+	if _, err := db.NewInsert().Model(&clinical).Exec(ctx); err != nil {
+		t.Fatalf("insert clinical: %v", err)
+	}
+
+	byID, err := SearchByCondition(ctx, db, cardio)
+	if err != nil {
+		t.Fatalf("search by id: %v", err)
+	}
+	if len(byID) != 1 || len(byID[0].SNPs) != 2 {
+		t.Fatalf("unexpected id search result: %+v", byID)
+	}
+
+	byName, err := SearchByCondition(ctx, db, "cardiomyopathy")
+	if err != nil {
+		t.Fatalf("search by name: %v", err)
+	}
+	if len(byName) != 2 {
+		t.Fatalf("expected 2 condition groups, got %d", len(byName))
+	}
+	if byName[0].ConditionName != "Cardiomyopathy" {
+		t.Fatalf("expected exact name match ranked first, got %s", byName[0].ConditionName)
+	}
+
+	none, err := SearchByCondition(ctx, db, "100%")
+	if err != nil {
+		t.Fatalf("search: %v", err)
+	}
+	if len(none) != 0 {
+		t.Fatalf("expected LIKE wildcards to be escaped, got %d groups", len(none))
+	}
+}
+
+func TestGetConditionReport(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+
+	snps := []*models.SNP{testSNP("rs1", "1", 1), testSNP("rs2", "1", 2), testSNP("rs3", "1", 3)}
+	if _, err := db.NewInsert().Model(&snps).Exec(ctx); err != nil {
+		t.Fatalf("insert snps: %v", err)
+	}
+	cui := "C0011860"
+	clinical := []*models.ClinicalData{
+		{SNPID: snps[0].ID, ConditionName: "Type 2 diabetes", ConditionID: &cui, Source: models.SourceClinVar},
+		{SNPID: snps[1].ID, ConditionName: "Type 2 diabetes", ConditionID: &cui, Source: models.SourceClinVar},
+		{SNPID: snps[1].ID, ConditionName: "Type 2 diabetes", ConditionID: &cui, Source: models.SourceSNPedia},
+		{SNPID: snps[1].ID, ConditionName: "Obesity", Source: models.SourceClinVar},
+		{SNPID: snps[2].ID, ConditionName: "Obesity", Source: models.SourceClinVar},
+	}
+	for _, c := range clinical {
+		c.ClinicalSignificance = models.ClinicalRiskFactor
+		c.ReviewStatus = models.ReviewCriteriaProvided
+	}
+	if _, err := db.NewInsert().Model(&clinical).Exec(ctx); err != nil {
+		t.Fatalf("insert clinical: %v", err)
+	}
+	sig := &models.Significance{SNPID: snps[1].ID, TotalScore: 70}
+	if _, err := db.NewInsert().Model(sig).Exec(ctx); err != nil {
+		t.Fatalf("insert significance: %v", err)
+	}
+	freq := &models.PopulationFreq{SNPID: snps[1].ID, PopulationCode: "EUR", Allele: "T", Frequency: 0.3, Source: models.SourceGnomAD}
+	if _, err := db.NewInsert().Model(freq).Exec(ctx); err != nil {
+		t.Fatalf("insert population: %v", err)
+	}
+
+	report, err := GetConditionReport(ctx, db, cui)
+	if err != nil {
+		t.Fatalf("report: %v", err)
+	}
+	if report.ConditionName != "Type 2 diabetes" || len(report.Variants) != 2 {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+	top := report.Variants[0]
+	if top.SNP.RsID != "rs2" || len(top.Assertions) != 2 || len(top.SNP.PopulationData) != 1 {
+		t.Fatalf("expected scored rs2 with its 2 assertions first, got %s with %d assertions", top.SNP.RsID, len(top.Assertions))
+	}
+
+	if _, err := GetConditionReport(ctx, db, "C404"); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("expected sql.ErrNoRows for unknown condition, got %v", err)
+	}
+}
@@ -0,0 +1,60 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/uptrace/bun"
+
+	"github.com/mkoziy/genome/exporter/internal/models"
+)
+
+// EnqueueFailedItems queues items for retry. An item already queued keeps its
+// first failure time and has its attempts incremented and its error, run and
+// last failure time replaced.
+func EnqueueFailedItems(ctx context.Context, db bun.IDB, items []*models.FailedItem) error {
+	if len(items) == 0 {
+		return nil
+	}
+	for _, item := range items {
+		if item.Attempts == 0 {
+			item.Attempts = 1
+		}
+	}
+	_, err := db.NewInsert().
+		Model(&items).
+		On("CONFLICT (source, kind, item_key, start) DO UPDATE").
+		Set("attempts = attempts + EXCLUDED.attempts").
+		Set("error = EXCLUDED.error").
+		Set("query = EXCLUDED.query").
+		Set("run_id = EXCLUDED.run_id").
+		Set("last_failed_at = CURRENT_TIMESTAMP").
+		Exec(ctx)
+	return err
+}
+
+// ListFailedItems returns the items queued for source, oldest first. Items
+// that already failed maxAttempts times are left out unless maxAttempts <= 0.
+func ListFailedItems(ctx context.Context, db bun.IDB, source string, maxAttempts int) ([]*models.FailedItem, error) {
+	items := make([]*models.FailedItem, 0)
+	q := db.NewSelect().
+		Model(&items).
+		Where("fi.source = ?", source).
+		OrderExpr("fi.id ASC")
+	if maxAttempts > 0 {
+		q = q.Where("fi.attempts < ?", maxAttempts)
+	}
+	err := q.Scan(ctx)
+	return items, err
+}
+
+// ResolveFailedItems removes items that were processed successfully.
+func ResolveFailedItems(ctx context.Context, db bun.IDB, ids []int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	_, err := db.NewDelete().
+		Model((*models.FailedItem)(nil)).
+		Where("id IN (?)", bun.In(ids)).
+		Exec(ctx)
+	return err
+}
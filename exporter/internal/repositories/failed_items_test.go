@@ -0,0 +1,54 @@
+package repositories
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mkoziy/genome/exporter/internal/models"
+)
+
+func TestFailedItemsQueue(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+
+	run := "run-1"
+	enqueue := func(items ...*models.FailedItem) {
+		t.Helper()
+		if err := EnqueueFailedItems(ctx, db, items); err != nil {
+			t.Fatalf("enqueue: %v", err)
+		}
+	}
+	enqueue(
+		&models.FailedItem{Source: "clinvar", Kind: models.FailedItemUID, ItemKey: "101", Error: "timeout", RunID: &run},
+		&models.FailedItem{Source: "clinvar", Kind: models.FailedItemBatch, ItemKey: "q", Start: 500, Error: "502"},
+		&models.FailedItem{Source: "clinvar", Kind: models.FailedItemBatch, ItemKey: "q", Start: 1000, Error: "502"},
+	)
+	// Failing again bumps the attempts of the queued item instead of adding one.
+	enqueue(&models.FailedItem{Source: "clinvar", Kind: models.FailedItemUID, ItemKey: "101", Error: "503"})
+
+	items, err := ListFailedItems(ctx, db, "clinvar", 0)
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(items) != 3 {
+		t.Fatalf("expected 3 queued items, got %d", len(items))
+	}
+	if items[0].ItemKey != "101" || items[0].Attempts != 2 || items[0].Error != "503" || items[0].RunID != nil {
+		t.Fatalf("expected the repeated failure to update the item, got %+v", items[0])
+	}
+
+	retryable, err := ListFailedItems(ctx, db, "clinvar", 2)
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(retryable) != 2 {
+		t.Fatalf("expected items under 2 attempts only, got %d", len(retryable))
+	}
+
+	if err := ResolveFailedItems(ctx, db, []int64{items[1].ID, items[2].ID}); err != nil {
+		t.Fatalf("resolve: %v", err)
+	}
+	if items, err = ListFailedItems(ctx, db, "clinvar", 0); err != nil || len(items) != 1 {
+		t.Fatalf("expected one item left, got %d (%v)", len(items), err)
+	}
+}
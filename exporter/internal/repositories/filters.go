@@ -0,0 +1,152 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/uptrace/bun"
+
+	"github.com/mkoziy/genome/exporter/internal/models"
+)
+
+// SNPFilter is a composable specification of SNP query conditions. Zero values
+// disable the corresponding condition; all set conditions must hold.
+type SNPFilter struct {
+	Chromosomes []string `json:"chromosomes,omitempty"`
+	Genes       []string `json:"genes,omitempty"`
+
+	// MinScore and MaxScore bound snp_significance.total_score. Setting either
+	// excludes unscored SNPs.
+	MinScore *float64 `json:"min_score,omitempty"`
+	MaxScore *float64 `json:"max_score,omitempty"`
+	// MinQuality bounds snp_quality.score from below, excluding SNPs whose
+	// quality was never assessed.
+	MinQuality *float64 `json:"min_quality,omitempty"`
+
+	// ClinicalSignificances and ReviewStatuses must be satisfied by the same
+	// clinical assertion, e.g. "pathogenic reviewed by an expert panel".
+	ClinicalSignificances []models.ClinicalSignificance `json:"clinical_significances,omitempty"`
+	ReviewStatuses        []models.ReviewStatus         `json:"review_statuses,omitempty"`
+
+	// Sources matches SNPs with at least one annotation row from any listed source.
+	Sources []models.DataSource `json:"sources,omitempty"`
+
+	HasPopulationData *bool      `json:"has_population_data,omitempty"`
+	UpdatedSince      *time.Time `json:"updated_since,omitempty"`
+}
+
+// Validate reports the first significance, review status or source the
+// filter names that does not exist, which would match nothing.
+func (f SNPFilter) Validate() error {
+	for _, s := range f.ClinicalSignificances {
+		if !s.IsValid() {
+			return fmt.Errorf("unknown clinical significance %q", s)
+		}
+	}
+	for _, r := range f.ReviewStatuses {
+		if !r.IsValid() {
+			return fmt.Errorf("unknown review status %q", r)
+		}
+	}
+	for _, s := range f.Sources {
+		if !s.IsValid() {
+			return fmt.Errorf("unknown source %q", s)
+		}
+	}
+	return nil
+}
+
+// apply adds the filter conditions to a query over snps aliased as "s".
+func (f SNPFilter) apply(q *bun.SelectQuery) *bun.SelectQuery {
+	if len(f.Chromosomes) > 0 {
+		q = q.Where("s.chromosome IN (?)", bun.In(f.Chromosomes))
+	}
+	if len(f.Genes) > 0 {
+		q = q.Where("s.gene_symbol IN (?)", bun.In(f.Genes))
+	}
+	if f.MinScore != nil || f.MaxScore != nil {
+		q = q.Where("EXISTS (SELECT 1 FROM snp_significance AS sig WHERE sig.snp_id = s.id AND sig.total_score >= ? AND sig.total_score <= ?)",
+			floatOr(f.MinScore, 0), floatOr(f.MaxScore, 100))
+	}
+	if f.MinQuality != nil {
+		q = q.Where("EXISTS (SELECT 1 FROM snp_quality AS q WHERE q.snp_id = s.id AND q.score >= ?)", *f.MinQuality)
+	}
+	if len(f.ClinicalSignificances) > 0 || len(f.ReviewStatuses) > 0 {
+		cond := "EXISTS (SELECT 1 FROM snp_clinical AS c WHERE c.snp_id = s.id AND c.deleted_at IS NULL"
+		args := make([]interface{}, 0, 2)
+		if len(f.ClinicalSignificances) > 0 {
+			cond += " AND c.clinical_significance IN (?)"
+			args = append(args, bun.In(f.ClinicalSignificances))
+		}
+		if len(f.ReviewStatuses) > 0 {
+			cond += " AND c.review_status IN (?)"
+			args = append(args, bun.In(f.ReviewStatuses))
+		}
+		q = q.Where(cond+")", args...)
+	}
+	if len(f.Sources) > 0 {
+		q = q.Where(`EXISTS (
+			SELECT 1 FROM snp_clinical AS c WHERE c.snp_id = s.id AND c.source IN (?0) AND c.deleted_at IS NULL
+			UNION ALL SELECT 1 FROM snp_phenotypes AS p WHERE p.snp_id = s.id AND p.source IN (?0) AND p.deleted_at IS NULL
+			UNION ALL SELECT 1 FROM snp_populations AS pop WHERE pop.snp_id = s.id AND pop.source IN (?0) AND pop.deleted_at IS NULL
+			UNION ALL SELECT 1 FROM snp_references AS r WHERE r.snp_id = s.id AND r.source IN (?0) AND r.deleted_at IS NULL
+		)`, bun.In(f.Sources))
+	}
+	if f.HasPopulationData != nil {
+		if *f.HasPopulationData {
+			q = q.Where("EXISTS (SELECT 1 FROM snp_populations AS pop WHERE pop.snp_id = s.id AND pop.deleted_at IS NULL)")
+		} else {
+			q = q.Where("NOT EXISTS (SELECT 1 FROM snp_populations AS pop WHERE pop.snp_id = s.id AND pop.deleted_at IS NULL)")
+		}
+	}
+	if f.UpdatedSince != nil {
+		q = q.Where("s.updated_at > ?", f.UpdatedSince.UTC())
+	}
+	return q
+}
+
+func floatOr(v *float64, def float64) float64 {
+	if v == nil {
+		return def
+	}
+	return *v
+}
+
+// FindSNPs returns a page of SNPs matching filter, ordered by id, with score and
+// clinical data preloaded.
+func FindSNPs(ctx context.Context, db *bun.DB, filter SNPFilter, page Page) (*SNPPage, error) {
+	page = page.normalize()
+
+	var snps []*models.SNP
+	total, err := db.NewSelect().
+		Model(&snps).
+		Relation("Significance").
+		Relation("ClinicalData").
+		Apply(filter.apply).
+		OrderExpr("s.id ASC").
+		Limit(page.Limit).
+		Offset(page.Offset).
+		ScanAndCount(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SNPPage{Total: total, SNPs: snps}, nil
+}
+
+// SignificanceFilter narrows SNP queries by score and clinical significance.
+// Zero values disable the corresponding condition.
+type SignificanceFilter struct {
+	MinScore              float64
+	ClinicalSignificances []models.ClinicalSignificance
+}
+
+// apply adds the filter conditions to a query over snps aliased as "s".
+func (f SignificanceFilter) apply(q *bun.SelectQuery) *bun.SelectQuery {
+	spec := SNPFilter{ClinicalSignificances: f.ClinicalSignificances}
+	if f.MinScore > 0 {
+		spec.MinScore = &f.MinScore
+	}
+	return spec.apply(q)
+}
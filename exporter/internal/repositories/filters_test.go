@@ -0,0 +1,82 @@
+package repositories
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mkoziy/genome/exporter/internal/models"
+)
+
+func TestFindSNPs(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+
+	apoe, brca := "APOE", "BRCA1"
+	snps := []*models.SNP{testSNP("rs1", "19", 1), testSNP("rs2", "19", 2), testSNP("rs3", "17", 3), testSNP("rs4", "17", 4)}
+	snps[0].GeneSymbol, snps[1].GeneSymbol = &apoe, &apoe
+	snps[2].GeneSymbol, snps[3].GeneSymbol = &brca, &brca
+	if _, err := db.NewInsert().Model(&snps).Exec(ctx); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+	sigs := []*models.Significance{{SNPID: snps[0].ID, TotalScore: 80}, {SNPID: snps[2].ID, TotalScore: 65}, {SNPID: snps[3].ID, TotalScore: 30}}
+	if _, err := db.NewInsert().Model(&sigs).Exec(ctx); err != nil {
+		t.Fatalf("insert significance: %v", err)
+	}
+	clinical := []*models.ClinicalData{
+		{SNPID: snps[0].ID, ClinicalSignificance: models.ClinicalPathogenic, ReviewStatus: models.ReviewSingleSubmitter, ConditionName: "A", Source: models.SourceClinVar},
+		{SNPID: snps[0].ID, ClinicalSignificance: models.ClinicalBenign, ReviewStatus: models.ReviewExpertPanel, ConditionName: "B", Source: models.SourceClinVar},
+		{SNPID: snps[2].ID, ClinicalSignificance: models.ClinicalPathogenic, ReviewStatus: models.ReviewExpertPanel, ConditionName: "C", Source: models.SourceClinVar},
+	}
+	if _, err := db.NewInsert().Model(&clinical).Exec(ctx); err != nil {
+		t.Fatalf("insert clinical: %v", err)
+	}
+	pops := []*models.PopulationFreq{{SNPID: snps[1].ID, PopulationCode: "EUR", Allele: "T", Frequency: 0.3, Source: models.SourceGnomAD}}
+	if _, err := db.NewInsert().Model(&pops).Exec(ctx); err != nil {
+		t.Fatalf("insert populations: %v", err)
+	}
+	quality := []*models.Quality{{SNPID: snps[0].ID, Score: 70}, {SNPID: snps[1].ID, Score: 40}}
+	if err := SaveQuality(ctx, db, quality); err != nil {
+		t.Fatalf("save quality: %v", err)
+	}
+
+	min60 := 60.0
+	yes := true
+	future := time.Now().Add(time.Hour)
+	cases := []struct {
+		name   string
+		filter SNPFilter
+		want   []string
+	}{
+		{"all", SNPFilter{}, []string{"rs1", "rs2", "rs3", "rs4"}},
+		{"chromosome", SNPFilter{Chromosomes: []string{"17"}}, []string{"rs3", "rs4"}},
+		{"gene and score", SNPFilter{Genes: []string{"BRCA1"}, MinScore: &min60}, []string{"rs3"}},
+		{
+			"significance and review on the same assertion",
+			SNPFilter{ClinicalSignificances: []models.ClinicalSignificance{models.ClinicalPathogenic}, ReviewStatuses: []models.ReviewStatus{models.ReviewExpertPanel}},
+			[]string{"rs3"},
+		},
+		{"source", SNPFilter{Sources: []models.DataSource{models.SourceGnomAD}}, []string{"rs2"}},
+		{"population data", SNPFilter{HasPopulationData: &yes}, []string{"rs2"}},
+		{"quality", SNPFilter{MinQuality: &min60}, []string{"rs1"}},
+		{"updated since", SNPFilter{UpdatedSince: &future}, []string{}},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			page, err := FindSNPs(ctx, db, tc.filter, Page{})
+			if err != nil {
+				t.Fatalf("find: %v", err)
+			}
+			got := rsIDs(page.SNPs)
+			if page.Total != len(tc.want) || len(got) != len(tc.want) {
+				t.Fatalf("got %v (total %d), want %v", got, page.Total, tc.want)
+			}
+			for i := range got {
+				if got[i] != tc.want[i] {
+					t.Fatalf("got %v, want %v", got, tc.want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,30 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/uptrace/bun"
+
+	"github.com/mkoziy/genome/exporter/internal/models"
+)
+
+// SaveFrequencies inserts aggregated frequencies, replacing those of SNPs
+// already aggregated.
+func SaveFrequencies(ctx context.Context, db bun.IDB, rows []*models.Frequency) error {
+	if len(rows) == 0 {
+		return nil
+	}
+	_, err := db.NewInsert().
+		Model(&rows).
+		On("CONFLICT (snp_id) DO UPDATE").
+		Set("global_maf = EXCLUDED.global_maf").
+		Set("global_source = EXCLUDED.global_source").
+		Set("superpopulation_mafs = EXCLUDED.superpopulation_mafs").
+		Set("max_maf = EXCLUDED.max_maf").
+		Set("max_superpopulation = EXCLUDED.max_superpopulation").
+		Set("min_maf = EXCLUDED.min_maf").
+		Set("min_superpopulation = EXCLUDED.min_superpopulation").
+		Set("calculated_at = CURRENT_TIMESTAMP").
+		Exec(ctx)
+	return err
+}
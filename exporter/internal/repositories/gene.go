@@ -0,0 +1,145 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/uptrace/bun"
+
+	"github.com/mkoziy/genome/exporter/internal/models"
+)
+
+// GeneQueryOptions controls filtering and pagination for GetSNPsByGene.
+type GeneQueryOptions struct {
+	SignificanceFilter
+	Page
+}
+
+// GetSNPsByGene returns a page of SNPs in a gene ordered by position, with all relations preloaded.
+func GetSNPsByGene(ctx context.Context, db *bun.DB, geneSymbol string, opts GeneQueryOptions) (*SNPPage, error) {
+	page := opts.Page.normalize()
+
+	var snps []*models.SNP
+	total, err := db.NewSelect().
+		Model(&snps).
+		Relation("Significance").
+		Relation("ClinicalData").
+		Relation("Phenotypes").
+		Relation("References").
+		Relation("PopulationData").
+		Where("s.gene_symbol = ?", geneSymbol).
+		Apply(opts.SignificanceFilter.apply).
+		OrderExpr("s.position ASC, s.id ASC").
+		Limit(page.Limit).
+		Offset(page.Offset).
+		ScanAndCount(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SNPPage{Total: total, SNPs: snps}, nil
+}
+
+// keyReferenceLimit caps the references returned in a gene summary.
+const keyReferenceLimit = 10
+
+// GeneSummary is the aggregate "gene page" payload.
+type GeneSummary struct {
+	Gene           string              `json:"gene"`
+	VariantCount   int                 `json:"variant_count"`
+	ScoredCount    int                 `json:"scored_count"`
+	MaxScore       *float64            `json:"max_score,omitempty"`
+	BySignificance map[string]int      `json:"by_significance"`
+	Conditions     []GeneCondition     `json:"conditions"`
+	KeyReferences  []*models.Reference `json:"key_references"`
+}
+
+// GeneCondition is a condition linked to variants in the gene.
+type GeneCondition struct {
+	ConditionName string  `json:"condition_name"`
+	ConditionID   *string `json:"condition_id,omitempty"`
+	VariantCount  int     `json:"variant_count"`
+}
+
+// GetGeneSummary aggregates the variants of a gene: counts of distinct SNPs by
+// clinical significance, the maximum score, conditions by number of variants and
+// the most cited references. It returns sql.ErrNoRows if the gene has no SNPs.
+func GetGeneSummary(ctx context.Context, db *bun.DB, geneSymbol string) (*GeneSummary, error) {
+	summary := &GeneSummary{
+		Gene:           geneSymbol,
+		BySignificance: make(map[string]int),
+		Conditions:     make([]GeneCondition, 0),
+		KeyReferences:  make([]*models.Reference, 0),
+	}
+
+	var totals struct {
+		Variants int      `bun:"variants"`
+		Scored   int      `bun:"scored"`
+		MaxScore *float64 `bun:"max_score"`
+	}
+	err := db.NewSelect().
+		TableExpr("snps AS s").
+		ColumnExpr("COUNT(*) AS variants").
+		ColumnExpr("COUNT(sig.id) AS scored").
+		ColumnExpr("MAX(sig.total_score) AS max_score").
+		Join("LEFT JOIN snp_significance AS sig ON sig.snp_id = s.id").
+		Where("s.gene_symbol = ?", geneSymbol).
+		Where("s.deleted_at IS NULL").
+		Scan(ctx, &totals)
+	if err != nil {
+		return nil, err
+	}
+	if totals.Variants == 0 {
+		return nil, sql.ErrNoRows
+	}
+	summary.VariantCount, summary.ScoredCount, summary.MaxScore = totals.Variants, totals.Scored, totals.MaxScore
+
+	var bySignificance []struct {
+		Significance string `bun:"clinical_significance"`
+		Count        int    `bun:"count"`
+	}
+	err = db.NewSelect().
+		TableExpr("snp_clinical AS c").
+		Column("c.clinical_significance").
+		ColumnExpr("COUNT(DISTINCT c.snp_id) AS count").
+		Join("JOIN snps AS s ON s.id = c.snp_id").
+		Where("s.gene_symbol = ?", geneSymbol).
+		Where("c.deleted_at IS NULL AND s.deleted_at IS NULL").
+		Group("c.clinical_significance").
+		Scan(ctx, &bySignificance)
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range bySignificance {
+		summary.BySignificance[row.Significance] = row.Count
+	}
+
+	err = db.NewSelect().
+		TableExpr("snp_clinical AS c").
+		ColumnExpr("c.condition_name AS condition_name").
+		ColumnExpr("MAX(c.condition_id) AS condition_id").
+		ColumnExpr("COUNT(DISTINCT c.snp_id) AS variant_count").
+		Join("JOIN snps AS s ON s.id = c.snp_id").
+		Where("s.gene_symbol = ?", geneSymbol).
+		Where("c.deleted_at IS NULL AND s.deleted_at IS NULL").
+		Group("c.condition_name").
+		OrderExpr("variant_count DESC, c.condition_name ASC").
+		Scan(ctx, &summary.Conditions)
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.NewSelect().
+		Model(&summary.KeyReferences).
+		Join("JOIN snps AS s ON s.id = r.snp_id").
+		Where("s.gene_symbol = ?", geneSymbol).
+		Where("s.deleted_at IS NULL").
+		OrderExpr("r.citation_count DESC, r.publication_year DESC, r.id ASC").
+		Limit(keyReferenceLimit).
+		Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return summary, nil
+}
@@ -0,0 +1,111 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/mkoziy/genome/exporter/internal/models"
+)
+
+func TestGetSNPsByGene(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+
+	gene, other := "BRCA1", "TP53"
+	snps := make([]*models.SNP, 0)
+	for i, rsID := range []string{"rs1", "rs2", "rs3", "rs4", "rs5"} {
+		snp := testSNP(rsID, "17", int64(100+i))
+		snp.GeneSymbol = &gene
+		snps = append(snps, snp)
+	}
+	otherSNP := testSNP("rs6", "17", 50)
+	otherSNP.GeneSymbol = &other
+	snps = append(snps, otherSNP)
+	if _, err := db.NewInsert().Model(&snps).Exec(ctx); err != nil {
+		t.Fatalf("insert snps: %v", err)
+	}
+	sigs := []*models.Significance{
+		{SNPID: snps[0].ID, TotalScore: 90},
+		{SNPID: snps[3].ID, TotalScore: 65},
+		{SNPID: snps[4].ID, TotalScore: 20},
+	}
+	if _, err := db.NewInsert().Model(&sigs).Exec(ctx); err != nil {
+		t.Fatalf("insert significance: %v", err)
+	}
+
+	page, err := GetSNPsByGene(ctx, db, gene, GeneQueryOptions{Page: Page{Limit: 2, Offset: 2}})
+	if err != nil {
+		t.Fatalf("by gene: %v", err)
+	}
+	if page.Total != 5 || len(page.SNPs) != 2 || page.SNPs[0].RsID != "rs3" {
+		t.Fatalf("unexpected page: total=%d ids=%v", page.Total, rsIDs(page.SNPs))
+	}
+
+	page, err = GetSNPsByGene(ctx, db, gene, GeneQueryOptions{SignificanceFilter: SignificanceFilter{MinScore: 60}})
+	if err != nil {
+		t.Fatalf("by gene: %v", err)
+	}
+	if page.Total != 2 || len(page.SNPs) != 2 || page.SNPs[1].RsID != "rs4" {
+		t.Fatalf("unexpected filtered page: total=%d ids=%v", page.Total, rsIDs(page.SNPs))
+	}
+}
+
+func TestGetGeneSummary(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+
+	gene := "APOE"
+	snps := []*models.SNP{testSNP("rs429358", "19", 1), testSNP("rs7412", "19", 2), testSNP("rs1", "19", 3)}
+	for _, snp := range snps {
+		snp.GeneSymbol = &gene
+	}
+	if _, err := db.NewInsert().Model(&snps).Exec(ctx); err != nil {
+		t.Fatalf("insert snps: %v", err)
+	}
+	if _, err := db.NewInsert().Model(&models.Significance{SNPID: snps[0].ID, TotalScore: 88}).Exec(ctx); err != nil {
+		t.Fatalf("insert significance: %v", err)
+	}
+	clinical := []*models.ClinicalData{
+		{SNPID: snps[0].ID, ClinicalSignificance: models.ClinicalRiskFactor, ConditionName: "Alzheimer disease"},
+		{SNPID: snps[1].ID, ClinicalSignificance: models.ClinicalRiskFactor, ConditionName: "Alzheimer disease"},
+		{SNPID: snps[1].ID, ClinicalSignificance: models.ClinicalProtective, ConditionName: "Hyperlipoproteinemia"},
+	}
+	for _, c := range clinical {
+		c.ReviewStatus = models.ReviewCriteriaProvided
+		c.Source = models.SourceClinVar
+	}
+	if _, err := db.NewInsert().Model(&clinical).Exec(ctx); err != nil {
+		t.Fatalf("insert clinical: %v", err)
+	}
+	pmidA, pmidB := "1", "2"
+	refs := []*models.Reference{
+		{SNPID: snps[0].ID, PubmedID: &pmidA, CitationCount: 10},
+		{SNPID: snps[1].ID, PubmedID: &pmidB, CitationCount: 500},
+	}
+	if _, err := db.NewInsert().Model(&refs).Exec(ctx); err != nil {
+		t.Fatalf("insert references: %v", err)
+	}
+
+	summary, err := GetGeneSummary(ctx, db, gene)
+	if err != nil {
+		t.Fatalf("summary: %v", err)
+	}
+	if summary.VariantCount != 3 || summary.ScoredCount != 1 || summary.MaxScore == nil || *summary.MaxScore != 88 {
+		t.Fatalf("unexpected totals: %+v", summary)
+	}
+	if summary.BySignificance[string(models.ClinicalRiskFactor)] != 2 || summary.BySignificance[string(models.ClinicalProtective)] != 1 {
+		t.Fatalf("unexpected significance counts: %v", summary.BySignificance)
+	}
+	if len(summary.Conditions) != 2 || summary.Conditions[0].ConditionName != "Alzheimer disease" || summary.Conditions[0].VariantCount != 2 {
+		t.Fatalf("unexpected conditions: %+v", summary.Conditions)
+	}
+	if len(summary.KeyReferences) != 2 || *summary.KeyReferences[0].PubmedID != pmidB {
+		t.Fatalf("expected most cited reference first, got %+v", summary.KeyReferences)
+	}
+
+	if _, err := GetGeneSummary(ctx, db, "NOPE"); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("expected sql.ErrNoRows for unknown gene, got %v", err)
+	}
+}
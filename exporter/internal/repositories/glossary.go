@@ -0,0 +1,57 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/uptrace/bun"
+
+	"github.com/mkoziy/genome/exporter/internal/models"
+)
+
+// SaveGlossaryTerms inserts terms, replacing the translation, case
+// sensitivity and note of those already in the glossary for their language.
+func SaveGlossaryTerms(ctx context.Context, db bun.IDB, terms []*models.GlossaryTerm) error {
+	if len(terms) == 0 {
+		return nil
+	}
+	for _, term := range terms {
+		if err := term.Validate(); err != nil {
+			return err
+		}
+	}
+	_, err := db.NewInsert().
+		Model(&terms).
+		On("CONFLICT (term, language_code) DO UPDATE").
+		Set("translation = EXCLUDED.translation").
+		Set("case_sensitive = EXCLUDED.case_sensitive").
+		Set("note = EXCLUDED.note").
+		Set("updated_at = CURRENT_TIMESTAMP").
+		Exec(ctx)
+	return err
+}
+
+// ListGlossaryTerms returns the glossary of lang, or of every language if
+// lang is empty, ordered by language and term.
+func ListGlossaryTerms(ctx context.Context, db bun.IDB, lang string) ([]*models.GlossaryTerm, error) {
+	terms := make([]*models.GlossaryTerm, 0)
+	q := db.NewSelect().Model(&terms).OrderExpr("g.language_code ASC, g.term ASC")
+	if lang != "" {
+		q = q.Where("g.language_code = ?", lang)
+	}
+	return terms, q.Scan(ctx)
+}
+
+// DeleteGlossaryTerm removes term from the glossary of lang and reports
+// whether it was there.
+func DeleteGlossaryTerm(ctx context.Context, db bun.IDB, term, lang string) (bool, error) {
+	res, err := db.NewDelete().
+		Model((*models.GlossaryTerm)(nil)).
+		Where("term = ?", term).
+		Where("language_code = ?", lang).
+		Exec(ctx)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
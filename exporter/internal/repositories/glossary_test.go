@@ -0,0 +1,45 @@
+package repositories
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mkoziy/genome/exporter/internal/models"
+)
+
+func TestGlossaryTerms(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+
+	// The case-sensitive term after one that is not must keep its flag.
+	terms := []*models.GlossaryTerm{
+		{Term: "pathogenic", LanguageCode: "de", Translation: "krankheitsverursachend"},
+		{Term: "BRCA1", LanguageCode: "de", Translation: "BRCA1", CaseSensitive: true},
+		{Term: "pathogenic", LanguageCode: "fr", Translation: "pathogène"},
+	}
+	if err := SaveGlossaryTerms(ctx, db, terms); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	again := []*models.GlossaryTerm{{Term: "pathogenic", LanguageCode: "de", Translation: "pathogen"}}
+	if err := SaveGlossaryTerms(ctx, db, again); err != nil {
+		t.Fatalf("save again: %v", err)
+	}
+	if err := SaveGlossaryTerms(ctx, db, []*models.GlossaryTerm{{Term: "benign", LanguageCode: "de"}}); err == nil {
+		t.Error("expected a term without translation rejected")
+	}
+
+	de, err := ListGlossaryTerms(ctx, db, "de")
+	if err != nil || len(de) != 2 || de[0].Term != "BRCA1" || !de[0].CaseSensitive || de[1].Translation != "pathogen" {
+		t.Fatalf("unexpected German glossary: %v (%v)", de, err)
+	}
+	if all, _ := ListGlossaryTerms(ctx, db, ""); len(all) != 3 {
+		t.Errorf("expected 3 terms in all, got %d", len(all))
+	}
+
+	if ok, err := DeleteGlossaryTerm(ctx, db, "pathogenic", "fr"); !ok || err != nil {
+		t.Fatalf("expected the term deleted, got %v (%v)", ok, err)
+	}
+	if ok, _ := DeleteGlossaryTerm(ctx, db, "pathogenic", "fr"); ok {
+		t.Error("expected a second delete to find nothing")
+	}
+}
@@ -0,0 +1,78 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/uptrace/bun"
+
+	"github.com/mkoziy/genome/exporter/internal/models"
+)
+
+// SaveHaplotypes inserts haplotypes, replacing the kind, function,
+// description and member SNPs of those already defined for their gene, in
+// one transaction.
+func SaveHaplotypes(ctx context.Context, db *bun.DB, haplotypes []*models.Haplotype) error {
+	for _, h := range haplotypes {
+		if err := h.Validate(); err != nil {
+			return err
+		}
+	}
+	return db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		for _, h := range haplotypes {
+			_, err := tx.NewInsert().
+				Model(h).
+				On("CONFLICT (gene, name) DO UPDATE").
+				Set("kind = EXCLUDED.kind").
+				Set("function = EXCLUDED.function").
+				Set("description = EXCLUDED.description").
+				Set("updated_at = CURRENT_TIMESTAMP").
+				Returning("id").
+				Exec(ctx)
+			if err != nil {
+				return fmt.Errorf("%s: %w", h.FullName(), err)
+			}
+			if _, err := tx.NewDelete().Model((*models.HaplotypeSNP)(nil)).Where("haplotype_id = ?", h.ID).Exec(ctx); err != nil {
+				return err
+			}
+			for _, m := range h.SNPs {
+				m.HaplotypeID = h.ID
+			}
+			if _, err := tx.NewInsert().Model(&h.SNPs).Exec(ctx); err != nil {
+				return fmt.Errorf("%s: %w", h.FullName(), err)
+			}
+		}
+		return nil
+	})
+}
+
+// ListHaplotypes returns the haplotypes of gene, or of every gene if gene is
+// empty, with their member SNPs, ordered by gene and name.
+func ListHaplotypes(ctx context.Context, db bun.IDB, gene string) ([]*models.Haplotype, error) {
+	haplotypes := make([]*models.Haplotype, 0)
+	q := db.NewSelect().
+		Model(&haplotypes).
+		Relation("SNPs", func(q *bun.SelectQuery) *bun.SelectQuery {
+			return q.OrderExpr("hs.id ASC")
+		}).
+		OrderExpr("h.gene ASC, h.name ASC")
+	if gene != "" {
+		q = q.Where("h.gene = ?", gene)
+	}
+	return haplotypes, q.Scan(ctx)
+}
+
+// GetHaplotypesByRsID returns the haplotypes rsID is a member of, with all
+// their member SNPs, ordered by gene and name.
+func GetHaplotypesByRsID(ctx context.Context, db bun.IDB, rsID string) ([]*models.Haplotype, error) {
+	haplotypes := make([]*models.Haplotype, 0)
+	err := db.NewSelect().
+		Model(&haplotypes).
+		Relation("SNPs", func(q *bun.SelectQuery) *bun.SelectQuery {
+			return q.OrderExpr("hs.id ASC")
+		}).
+		Where("EXISTS (SELECT 1 FROM haplotype_snps AS m WHERE m.haplotype_id = h.id AND m.rsid = ?)", rsID).
+		OrderExpr("h.gene ASC, h.name ASC").
+		Scan(ctx)
+	return haplotypes, err
+}
@@ -0,0 +1,70 @@
+package repositories
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mkoziy/genome/exporter/internal/models"
+)
+
+func TestHaplotypes(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+
+	function := "no function"
+	haplotypes := []*models.Haplotype{
+		{Gene: "CYP2C19", Name: "*2", Kind: models.HaplotypeStarAllele, SNPs: []*models.HaplotypeSNP{{RsID: "rs4244285", Allele: "A"}}},
+		{Gene: "APOE", Name: "ε4", Kind: models.HaplotypeNamedAllele, SNPs: []*models.HaplotypeSNP{
+			{RsID: "rs429358", Allele: "C"},
+			{RsID: "rs7412", Allele: "C"},
+		}},
+	}
+	if err := SaveHaplotypes(ctx, db, haplotypes); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	// Saving again replaces the function and the members.
+	again := []*models.Haplotype{{Gene: "CYP2C19", Name: "*2", Kind: models.HaplotypeStarAllele, Function: &function, SNPs: []*models.HaplotypeSNP{{RsID: "rs4244285", Allele: "A"}, {RsID: "rs12769205", Allele: "G"}}}}
+	if err := SaveHaplotypes(ctx, db, again); err != nil {
+		t.Fatalf("save again: %v", err)
+	}
+
+	all, err := ListHaplotypes(ctx, db, "")
+	if err != nil || len(all) != 2 || all[0].Gene != "APOE" || len(all[0].SNPs) != 2 {
+		t.Fatalf("unexpected haplotypes: %v (%v)", all, err)
+	}
+	cyp, _ := ListHaplotypes(ctx, db, "CYP2C19")
+	if len(cyp) != 1 || cyp[0].Function == nil || *cyp[0].Function != function || len(cyp[0].SNPs) != 2 {
+		t.Fatalf("unexpected CYP2C19 haplotypes: %v", cyp)
+	}
+
+	byRsID, err := GetHaplotypesByRsID(ctx, db, "rs7412")
+	if err != nil || len(byRsID) != 1 || byRsID[0].FullName() != "APOE ε4" || len(byRsID[0].SNPs) != 2 {
+		t.Fatalf("unexpected haplotypes of rs7412: %v (%v)", byRsID, err)
+	}
+
+	// The SNP finds its memberships through its rsID.
+	if err := UpsertSNPs(ctx, db, []*models.SNP{{RsID: "rs7412", Chromosome: "19", Position: 44908822, ReferenceAllele: "C", AlternateAlleles: models.StringArray{"T"}, VariantType: models.VariantSNV}}); err != nil {
+		t.Fatal(err)
+	}
+	snp, err := GetSNPByRsID(ctx, db, "rs7412")
+	if err != nil || len(snp.Haplotypes) != 1 || snp.Haplotypes[0].Allele != "C" || snp.Haplotypes[0].Haplotype.Name != "ε4" {
+		t.Fatalf("unexpected memberships: %+v (%v)", snp, err)
+	}
+
+	// Bulk loads preload them like the single lookup.
+	byRsIDs, err := GetSNPsByRsIDs(ctx, db, []string{"rs7412"})
+	if err != nil || len(byRsIDs["rs7412"].Haplotypes) != 1 {
+		t.Fatalf("unexpected memberships by rsIDs: %+v (%v)", byRsIDs["rs7412"], err)
+	}
+	byKeys, err := GetSNPsByVariantKeys(ctx, db, []string{*snp.VariantKey})
+	if err != nil || len(byKeys) != 1 || len(byKeys[0].Haplotypes) != 1 {
+		t.Fatalf("unexpected memberships by variant keys: %v (%v)", byKeys, err)
+	}
+	var iterated []*models.SNP
+	if err := ForEachSNP(ctx, db, 0, func(batch []*models.SNP) error {
+		iterated = append(iterated, batch...)
+		return nil
+	}); err != nil || len(iterated) != 1 || len(iterated[0].Haplotypes) != 1 {
+		t.Fatalf("unexpected memberships iterated: %v (%v)", iterated, err)
+	}
+}
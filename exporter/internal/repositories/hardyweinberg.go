@@ -0,0 +1,31 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/uptrace/bun"
+
+	"github.com/mkoziy/genome/exporter/internal/models"
+)
+
+// SaveHardyWeinberg replaces the Hardy–Weinberg tests of the SNPs with
+// snpIDs by rows, in one transaction, so populations no longer counting
+// their genotypes lose their tests.
+func SaveHardyWeinberg(ctx context.Context, db bun.IDB, snpIDs []int64, rows []*models.HardyWeinberg) error {
+	if len(snpIDs) == 0 {
+		return nil
+	}
+	return db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		if _, err := tx.NewDelete().
+			Model((*models.HardyWeinberg)(nil)).
+			Where("snp_id IN (?)", bun.In(snpIDs)).
+			Exec(ctx); err != nil {
+			return err
+		}
+		if len(rows) == 0 {
+			return nil
+		}
+		_, err := tx.NewInsert().Model(&rows).Exec(ctx)
+		return err
+	})
+}
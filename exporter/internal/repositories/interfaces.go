@@ -0,0 +1,191 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/uptrace/bun"
+
+	"github.com/mkoziy/genome/exporter/internal/models"
+)
+
+// SNPRepository reads and writes SNP rows. Lookups of unknown rsIDs return
+// sql.ErrNoRows, matching the bun-backed functions.
+type SNPRepository interface {
+	GetByRsID(ctx context.Context, rsID string) (*models.SNP, error)
+	GetByRsIDs(ctx context.Context, rsIDs []string) (map[string]*models.SNP, error)
+	GetByVariantKeys(ctx context.Context, keys []string) ([]*models.SNP, error)
+	Upsert(ctx context.Context, snps []*models.SNP) error
+	ForEach(ctx context.Context, batchSize int, fn func(batch []*models.SNP) error) error
+	ForEachStale(ctx context.Context, batchSize int, fn func(batch []*models.SNP) error) error
+}
+
+// ClinicalRepository writes and lists clinical annotations.
+type ClinicalRepository interface {
+	Upsert(ctx context.Context, rows []*models.ClinicalData) error
+	ListBySNP(ctx context.Context, snpID int64) ([]*models.ClinicalData, error)
+}
+
+// ReferenceRepository writes and lists literature references.
+type ReferenceRepository interface {
+	Upsert(ctx context.Context, rows []*models.Reference) error
+	ListBySNP(ctx context.Context, snpID int64) ([]*models.Reference, error)
+}
+
+// PopulationRepository writes and lists population frequencies.
+type PopulationRepository interface {
+	Upsert(ctx context.Context, rows []*models.PopulationFreq) error
+	ListBySNP(ctx context.Context, snpID int64) ([]*models.PopulationFreq, error)
+}
+
+// SignificanceRepository stores calculated scores, one per SNP.
+type SignificanceRepository interface {
+	Save(ctx context.Context, sig *models.Significance) error
+	GetBySNP(ctx context.Context, snpID int64) (*models.Significance, error)
+}
+
+// TranslationRepository writes and reads translated texts.
+type TranslationRepository interface {
+	// Upsert writes translations, replacing those of the same text unless
+	// they are further through review.
+	Upsert(ctx context.Context, rows []*models.Translation, phenotypes []*models.PhenotypeTranslation) error
+	// Get returns the lang translations of the SNPs and their phenotypes.
+	Get(ctx context.Context, lang string, snpIDs []int64) (*Translations, error)
+}
+
+// Repositories bundles the repository interfaces consumed by fetchers, the scorer
+// and API handlers.
+type Repositories struct {
+	SNPs         SNPRepository
+	Clinical     ClinicalRepository
+	References   ReferenceRepository
+	Populations  PopulationRepository
+	Significance SignificanceRepository
+	Translations TranslationRepository
+}
+
+// NewBunRepositories returns repositories backed by db.
+func NewBunRepositories(db *bun.DB) *Repositories {
+	return &Repositories{
+		SNPs:         &bunSNPRepository{db: db},
+		Clinical:     &bunClinicalRepository{db: db},
+		References:   &bunReferenceRepository{db: db},
+		Populations:  &bunPopulationRepository{db: db},
+		Significance: &bunSignificanceRepository{db: db},
+		Translations: &bunTranslationRepository{db: db},
+	}
+}
+
+type bunSNPRepository struct {
+	db *bun.DB
+}
+
+func (r *bunSNPRepository) GetByRsID(ctx context.Context, rsID string) (*models.SNP, error) {
+	return GetSNPByRsID(ctx, r.db, rsID)
+}
+
+func (r *bunSNPRepository) GetByRsIDs(ctx context.Context, rsIDs []string) (map[string]*models.SNP, error) {
+	return GetSNPsByRsIDs(ctx, r.db, rsIDs)
+}
+
+func (r *bunSNPRepository) GetByVariantKeys(ctx context.Context, keys []string) ([]*models.SNP, error) {
+	return GetSNPsByVariantKeys(ctx, r.db, keys)
+}
+
+func (r *bunSNPRepository) Upsert(ctx context.Context, snps []*models.SNP) error {
+	if len(snps) == 0 {
+		return nil
+	}
+	return UpsertSNPs(ctx, r.db, snps)
+}
+
+func (r *bunSNPRepository) ForEach(ctx context.Context, batchSize int, fn func(batch []*models.SNP) error) error {
+	return ForEachSNP(ctx, r.db, batchSize, fn)
+}
+
+func (r *bunSNPRepository) ForEachStale(ctx context.Context, batchSize int, fn func(batch []*models.SNP) error) error {
+	return ForEachStaleSNP(ctx, r.db, batchSize, fn)
+}
+
+type bunClinicalRepository struct {
+	db *bun.DB
+}
+
+func (r *bunClinicalRepository) Upsert(ctx context.Context, rows []*models.ClinicalData) error {
+	return UpsertClinicalData(ctx, r.db, rows)
+}
+
+func (r *bunClinicalRepository) ListBySNP(ctx context.Context, snpID int64) ([]*models.ClinicalData, error) {
+	rows := make([]*models.ClinicalData, 0)
+	err := r.db.NewSelect().Model(&rows).Where("snp_id = ?", snpID).OrderExpr("id ASC").Scan(ctx)
+	return rows, err
+}
+
+type bunReferenceRepository struct {
+	db *bun.DB
+}
+
+func (r *bunReferenceRepository) Upsert(ctx context.Context, rows []*models.Reference) error {
+	return UpsertReferences(ctx, r.db, rows)
+}
+
+func (r *bunReferenceRepository) ListBySNP(ctx context.Context, snpID int64) ([]*models.Reference, error) {
+	rows := make([]*models.Reference, 0)
+	err := r.db.NewSelect().Model(&rows).Where("snp_id = ?", snpID).OrderExpr("id ASC").Scan(ctx)
+	return rows, err
+}
+
+type bunPopulationRepository struct {
+	db *bun.DB
+}
+
+func (r *bunPopulationRepository) Upsert(ctx context.Context, rows []*models.PopulationFreq) error {
+	return UpsertPopulationFreqs(ctx, r.db, rows)
+}
+
+func (r *bunPopulationRepository) ListBySNP(ctx context.Context, snpID int64) ([]*models.PopulationFreq, error) {
+	rows := make([]*models.PopulationFreq, 0)
+	err := r.db.NewSelect().Model(&rows).Where("snp_id = ?", snpID).OrderExpr("id ASC").Scan(ctx)
+	return rows, err
+}
+
+type bunSignificanceRepository struct {
+	db *bun.DB
+}
+
+func (r *bunSignificanceRepository) Save(ctx context.Context, sig *models.Significance) error {
+	_, err := r.db.NewInsert().
+		Model(sig).
+		On("CONFLICT (snp_id) DO UPDATE").
+		Set("total_score = EXCLUDED.total_score").
+		Set("clinical_score = EXCLUDED.clinical_score").
+		Set("research_score = EXCLUDED.research_score").
+		Set("population_score = EXCLUDED.population_score").
+		Set("functional_score = EXCLUDED.functional_score").
+		Set("score_details = EXCLUDED.score_details").
+		Set("algorithm_version = EXCLUDED.algorithm_version").
+		Set("stale = 0").
+		Set("calculated_at = CURRENT_TIMESTAMP").
+		Exec(ctx)
+	return err
+}
+
+func (r *bunSignificanceRepository) GetBySNP(ctx context.Context, snpID int64) (*models.Significance, error) {
+	sig := new(models.Significance)
+	err := r.db.NewSelect().Model(sig).Where("snp_id = ?", snpID).Scan(ctx)
+	return sig, err
+}
+
+type bunTranslationRepository struct {
+	db *bun.DB
+}
+
+func (r *bunTranslationRepository) Upsert(ctx context.Context, rows []*models.Translation, phenotypes []*models.PhenotypeTranslation) error {
+	if err := UpsertTranslations(ctx, r.db, rows); err != nil {
+		return err
+	}
+	return UpsertPhenotypeTranslations(ctx, r.db, phenotypes)
+}
+
+func (r *bunTranslationRepository) Get(ctx context.Context, lang string, snpIDs []int64) (*Translations, error) {
+	return GetTranslations(ctx, r.db, lang, snpIDs)
+}
@@ -0,0 +1,82 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/uptrace/bun"
+
+	"github.com/mkoziy/genome/exporter/internal/models"
+)
+
+const defaultScanBatchSize = 500
+
+// ForEachSNP scans the snps table in id order, batchSize rows at a time, and calls fn
+// with each batch and all relations preloaded. Memory stays bounded by one batch.
+// Iteration stops at the first error returned by fn or the query.
+func ForEachSNP(ctx context.Context, db *bun.DB, batchSize int, fn func(batch []*models.SNP) error) error {
+	return forEachSNP(ctx, db, batchSize, nil, fn)
+}
+
+// ForEachMatchingSNP is ForEachSNP restricted to the SNPs filter matches.
+func ForEachMatchingSNP(ctx context.Context, db *bun.DB, batchSize int, filter SNPFilter, fn func(batch []*models.SNP) error) error {
+	return forEachSNP(ctx, db, batchSize, filter.apply, fn)
+}
+
+// ForEachStaleSNP is ForEachSNP restricted to SNPs whose score must be
+// recalculated: those never scored, and those whose stale flag was set by a
+// clinical, reference or population row being inserted, changed or deleted since
+// the score was saved. Saving a score clears the flag, so a scoring pass that
+// saves every SNP it is handed touches only what changed since the last run.
+func ForEachStaleSNP(ctx context.Context, db *bun.DB, batchSize int, fn func(batch []*models.SNP) error) error {
+	return forEachSNP(ctx, db, batchSize, func(q *bun.SelectQuery) *bun.SelectQuery {
+		return q.Where("NOT EXISTS (SELECT 1 FROM snp_significance AS ss WHERE ss.snp_id = s.id AND NOT ss.stale)")
+	}, fn)
+}
+
+func forEachSNP(ctx context.Context, db *bun.DB, batchSize int, where func(*bun.SelectQuery) *bun.SelectQuery, fn func(batch []*models.SNP) error) error {
+	if batchSize <= 0 {
+		batchSize = defaultScanBatchSize
+	}
+
+	var afterID int64
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		var batch []*models.SNP
+		q := db.NewSelect().
+			Model(&batch).
+			Relation("Significance").
+			Relation("Quality").
+			Relation("Frequency").
+			Relation("HardyWeinberg").
+			Relation("ClinicalData").
+			Relation("Phenotypes").
+			Relation("References").
+			Relation("PopulationData").
+			Relation("RiskAlleles").
+			Relation("GenotypeEffects").
+			Relation("ClinicalAgreements").
+			Relation("Haplotypes.Haplotype").
+			Where("s.id > ?", afterID)
+		if where != nil {
+			q = where(q)
+		}
+		if err := q.OrderExpr("s.id ASC").Limit(batchSize).Scan(ctx); err != nil {
+			return err
+		}
+		if len(batch) == 0 {
+			return nil
+		}
+
+		if err := fn(batch); err != nil {
+			return err
+		}
+
+		if len(batch) < batchSize {
+			return nil
+		}
+		afterID = batch[len(batch)-1].ID
+	}
+}
@@ -0,0 +1,118 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/mkoziy/genome/exporter/internal/models"
+)
+
+func TestForEachSNP(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+
+	snps := make([]*models.SNP, 0)
+	for i := 1; i <= 10; i++ {
+		snps = append(snps, testSNP(fmt.Sprintf("rs%d", i), "1", int64(i)))
+	}
+	if _, err := db.NewInsert().Model(&snps).Exec(ctx); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+
+	var batches, total int
+	var lastID int64
+	err := ForEachSNP(ctx, db, 3, func(batch []*models.SNP) error {
+		batches++
+		for _, snp := range batch {
+			if snp.ID <= lastID {
+				t.Fatalf("ids not increasing: %d after %d", snp.ID, lastID)
+			}
+			lastID = snp.ID
+			total++
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("for each: %v", err)
+	}
+	if batches != 4 || total != 10 {
+		t.Fatalf("expected 4 batches / 10 rows, got %d / %d", batches, total)
+	}
+
+	stop := errors.New("stop")
+	err = ForEachSNP(ctx, db, 3, func(batch []*models.SNP) error { return stop })
+	if !errors.Is(err, stop) {
+		t.Fatalf("expected callback error to propagate, got %v", err)
+	}
+}
+
+func TestForEachStaleSNP(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+	repos := NewBunRepositories(db)
+
+	snps := []*models.SNP{testSNP("rs1", "1", 100), testSNP("rs2", "1", 200), testSNP("rs3", "1", 300)}
+	if err := UpsertSNPs(ctx, db, snps); err != nil {
+		t.Fatalf("upsert: %v", err)
+	}
+	clinical := &models.ClinicalData{SNPID: snps[0].ID, ClinicalSignificance: models.ClinicalUncertainSignif, ReviewStatus: models.ReviewSingleSubmitter, ConditionName: "X", Source: models.SourceClinVar}
+	if err := UpsertClinicalData(ctx, db, []*models.ClinicalData{clinical}); err != nil {
+		t.Fatalf("upsert clinical: %v", err)
+	}
+
+	stale := func() []string {
+		t.Helper()
+		var got []string
+		err := ForEachStaleSNP(ctx, db, 2, func(batch []*models.SNP) error {
+			got = append(got, rsIDs(batch)...)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("for each stale: %v", err)
+		}
+		return got
+	}
+
+	if got := stale(); len(got) != 3 {
+		t.Fatalf("expected unscored SNPs to be stale, got %v", got)
+	}
+	for _, snp := range snps {
+		if err := repos.Significance.Save(ctx, &models.Significance{SNPID: snp.ID, TotalScore: 10}); err != nil {
+			t.Fatalf("save score: %v", err)
+		}
+	}
+	if got := stale(); len(got) != 0 {
+		t.Fatalf("expected no stale SNPs after scoring, got %v", got)
+	}
+
+	// Re-importing an identical row leaves the score alone; a changed one does not.
+	if err := UpsertClinicalData(ctx, db, []*models.ClinicalData{clinical}); err != nil {
+		t.Fatalf("re-upsert clinical: %v", err)
+	}
+	if got := stale(); len(got) != 0 {
+		t.Fatalf("expected identical re-import to keep scores fresh, got %v", got)
+	}
+	clinical.ClinicalSignificance = models.ClinicalPathogenic
+	if err := UpsertClinicalData(ctx, db, []*models.ClinicalData{clinical}); err != nil {
+		t.Fatalf("update clinical: %v", err)
+	}
+	freq := &models.PopulationFreq{SNPID: snps[2].ID, PopulationCode: "EUR", Allele: "T", Frequency: 0.1, Source: models.SourceGnomAD}
+	if err := UpsertPopulationFreqs(ctx, db, []*models.PopulationFreq{freq}); err != nil {
+		t.Fatalf("insert frequency: %v", err)
+	}
+	if got := stale(); len(got) != 2 || got[0] != "rs1" || got[1] != "rs3" {
+		t.Fatalf("expected rs1 and rs3 to be stale, got %v", got)
+	}
+
+	if err := repos.Significance.Save(ctx, &models.Significance{SNPID: snps[0].ID, TotalScore: 60}); err != nil {
+		t.Fatalf("rescore: %v", err)
+	}
+	if _, err := db.NewDelete().Model(freq).WherePK().Exec(ctx); err != nil {
+		t.Fatalf("delete frequency: %v", err)
+	}
+	if got := stale(); len(got) != 1 || got[0] != "rs3" {
+		t.Fatalf("expected only rs3 to be stale, got %v", got)
+	}
+}
@@ -0,0 +1,60 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/uptrace/bun"
+
+	"github.com/mkoziy/genome/exporter/internal/models"
+)
+
+// ForEachUnliftedSNP calls fn with batches of the SNPs whose GRCh37
+// coordinates are unknown, in ID order, with only their IDs and GRCh38
+// coordinates loaded.
+func ForEachUnliftedSNP(ctx context.Context, db *bun.DB, batchSize int, fn func(batch []*models.SNP) error) error {
+	if batchSize <= 0 {
+		batchSize = defaultScanBatchSize
+	}
+	var afterID int64
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		var batch []*models.SNP
+		err := db.NewSelect().
+			Model(&batch).
+			Column("id", "rsid", "chromosome", "position", "reference_allele", "alternate_alleles").
+			Where("s.id > ?", afterID).
+			Where("s.grch37_position IS NULL").
+			OrderExpr("s.id ASC").
+			Limit(batchSize).
+			Scan(ctx)
+		if err != nil || len(batch) == 0 {
+			return err
+		}
+		if err := fn(batch); err != nil {
+			return err
+		}
+		afterID = batch[len(batch)-1].ID
+	}
+}
+
+// SetGRCh37Coordinates writes the GRCh37 coordinates of snps, by ID, in one
+// transaction.
+func SetGRCh37Coordinates(ctx context.Context, db *bun.DB, snps []*models.SNP) error {
+	return db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		for _, snp := range snps {
+			_, err := tx.NewUpdate().
+				Model((*models.SNP)(nil)).
+				Set("grch37_chromosome = ?", snp.GRCh37Chromosome).
+				Set("grch37_position = ?", snp.GRCh37Position).
+				Set("updated_at = CURRENT_TIMESTAMP").
+				Where("id = ?", snp.ID).
+				Exec(ctx)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
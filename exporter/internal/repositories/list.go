@@ -0,0 +1,98 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/uptrace/bun"
+
+	"github.com/mkoziy/genome/exporter/internal/models"
+)
+
+// ListSNPs walks all SNPs in id order using keyset pagination, so deep pages cost
+// the same as the first one.
+func ListSNPs(ctx context.Context, db *bun.DB, page KeysetPage) (*SNPCursorPage, error) {
+	limit := page.limit()
+
+	var snps []*models.SNP
+	err := db.NewSelect().
+		Model(&snps).
+		Relation("Significance").
+		Relation("ClinicalData").
+		Where("s.id > ?", page.After.AfterID).
+		OrderExpr("s.id ASC").
+		Limit(limit + 1).
+		Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &SNPCursorPage{SNPs: snps}
+	if len(snps) > limit {
+		result.SNPs = snps[:limit]
+		result.Next = &Cursor{AfterID: result.SNPs[limit-1].ID}
+	}
+	return result, nil
+}
+
+// ListSNPsByScore walks scored SNPs from the highest total score down, with ties
+// broken by id. Only SNPs scoring at least minScore are included.
+func ListSNPsByScore(ctx context.Context, db *bun.DB, minScore float64, page KeysetPage) (*SNPCursorPage, error) {
+	limit := page.limit()
+
+	q := db.NewSelect().
+		Model((*models.SNP)(nil)).
+		Column("s.id").
+		ColumnExpr("sig.total_score").
+		Join("JOIN snp_significance AS sig ON sig.snp_id = s.id").
+		Where("sig.total_score >= ?", minScore)
+	if page.After.AfterScore != nil {
+		q = q.Where("(sig.total_score < ? OR (sig.total_score = ? AND s.id > ?))",
+			*page.After.AfterScore, *page.After.AfterScore, page.After.AfterID)
+	}
+
+	var keys []struct {
+		ID         int64   `bun:"id"`
+		TotalScore float64 `bun:"total_score"`
+	}
+	if err := q.OrderExpr("sig.total_score DESC, s.id ASC").Limit(limit+1).Scan(ctx, &keys); err != nil {
+		return nil, err
+	}
+
+	result := &SNPCursorPage{SNPs: make([]*models.SNP, 0, len(keys))}
+	if len(keys) > limit {
+		keys = keys[:limit]
+		last := keys[limit-1]
+		score := last.TotalScore
+		result.Next = &Cursor{AfterID: last.ID, AfterScore: &score}
+	}
+	if len(keys) == 0 {
+		return result, nil
+	}
+
+	ids := make([]int64, len(keys))
+	for i, k := range keys {
+		ids[i] = k.ID
+	}
+
+	var snps []*models.SNP
+	err := db.NewSelect().
+		Model(&snps).
+		Relation("Significance").
+		Relation("ClinicalData").
+		Where("s.id IN (?)", bun.In(ids)).
+		Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[int64]*models.SNP, len(snps))
+	for _, snp := range snps {
+		byID[snp.ID] = snp
+	}
+	for _, id := range ids {
+		if snp, ok := byID[id]; ok {
+			result.SNPs = append(result.SNPs, snp)
+		}
+	}
+	return result, nil
+}
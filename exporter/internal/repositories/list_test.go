@@ -0,0 +1,82 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/mkoziy/genome/exporter/internal/models"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	score := 72.5
+	for _, c := range []Cursor{{AfterID: 42}, {AfterID: 7, AfterScore: &score}} {
+		parsed, err := ParseCursor(c.String())
+		if err != nil {
+			t.Fatalf("parse: %v", err)
+		}
+		if parsed.AfterID != c.AfterID || (c.AfterScore != nil && (parsed.AfterScore == nil || *parsed.AfterScore != *c.AfterScore)) {
+			t.Fatalf("cursor mismatch: %+v vs %+v", parsed, c)
+		}
+	}
+	if _, err := ParseCursor("!!"); err == nil {
+		t.Fatalf("expected error for malformed cursor")
+	}
+}
+
+func TestListSNPsKeyset(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+
+	snps := make([]*models.SNP, 0)
+	for i := 1; i <= 7; i++ {
+		snps = append(snps, testSNP(fmt.Sprintf("rs%d", i), "1", int64(i)))
+	}
+	if _, err := db.NewInsert().Model(&snps).Exec(ctx); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+	// Scores with ties to exercise the (score, id) cursor.
+	scores := []float64{50, 90, 50, 70, 50, 10, 90}
+	sigs := make([]*models.Significance, len(snps))
+	for i, snp := range snps {
+		sigs[i] = &models.Significance{SNPID: snp.ID, TotalScore: scores[i]}
+	}
+	if _, err := db.NewInsert().Model(&sigs).Exec(ctx); err != nil {
+		t.Fatalf("insert significance: %v", err)
+	}
+
+	seen := 0
+	page := KeysetPage{Limit: 3}
+	for {
+		res, err := ListSNPs(ctx, db, page)
+		if err != nil {
+			t.Fatalf("list: %v", err)
+		}
+		seen += len(res.SNPs)
+		if res.Next == nil {
+			break
+		}
+		page.After = *res.Next
+	}
+	if seen != 7 {
+		t.Fatalf("expected to walk 7 SNPs, got %d", seen)
+	}
+
+	order := make([]string, 0)
+	page = KeysetPage{Limit: 2}
+	for {
+		res, err := ListSNPsByScore(ctx, db, 20, page)
+		if err != nil {
+			t.Fatalf("list by score: %v", err)
+		}
+		order = append(order, rsIDs(res.SNPs)...)
+		if res.Next == nil {
+			break
+		}
+		page.After = *res.Next
+	}
+	want := []string{"rs2", "rs7", "rs4", "rs1", "rs3", "rs5"}
+	if fmt.Sprint(order) != fmt.Sprint(want) {
+		t.Fatalf("unexpected score order: %v, want %v", order, want)
+	}
+}
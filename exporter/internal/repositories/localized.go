@@ -0,0 +1,328 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/mkoziy/genome/exporter/internal/models"
+)
+
+// fallbackLanguage is the language of the database's own text, tried last.
+const fallbackLanguage = "en"
+
+// LocalizationConfig configures the languages tried for text not
+// translated into the language asked for.
+type LocalizationConfig struct {
+	// Fallbacks lists, by language code, the languages to try after it, in
+	// order, e.g. pt-BR: [pt-PT, pt]. A language without its own list falls
+	// back to its base language, pt for pt-BR. English is tried last.
+	Fallbacks map[string][]string `yaml:"fallbacks" json:"fallbacks,omitempty"`
+}
+
+// Validate checks that the fallback lists name languages.
+func (c LocalizationConfig) Validate() error {
+	var errs []error
+	for lang, chain := range c.Fallbacks {
+		if normalizeLanguage(lang) == "" {
+			errs = append(errs, errors.New("fallbacks: empty language code"))
+		}
+		for _, l := range chain {
+			if normalizeLanguage(l) == "" {
+				errs = append(errs, fmt.Errorf("fallbacks.%s: empty language code", lang))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func normalizeLanguage(lang string) string {
+	return strings.ReplaceAll(strings.TrimSpace(lang), "_", "-")
+}
+
+// Chain returns the languages to try for lang, most specific first: lang,
+// then its fallbacks, each followed by its own, then English.
+func (c LocalizationConfig) Chain(lang string) []string {
+	fallbacks := make(map[string][]string, len(c.Fallbacks))
+	for l, chain := range c.Fallbacks {
+		fallbacks[normalizeLanguage(l)] = chain
+	}
+	var chain []string
+	var visit func(l string)
+	visit = func(l string) {
+		l = normalizeLanguage(l)
+		if l == "" || slices.Contains(chain, l) {
+			return
+		}
+		chain = append(chain, l)
+		if next, ok := fallbacks[l]; ok {
+			for _, f := range next {
+				visit(f)
+			}
+		} else if base, _, ok := strings.Cut(l, "-"); ok {
+			visit(base)
+		}
+	}
+	visit(lang)
+	visit(fallbackLanguage)
+	return chain
+}
+
+// ResolvedText is a text resolved through a fallback chain, with the
+// language it was found in.
+type ResolvedText struct {
+	Text     string `json:"text"`
+	Language string `json:"language"`
+}
+
+// ResolvedTranslations are the texts of a set of SNPs in a language, each
+// from the first language of its fallback chain translating it.
+type ResolvedTranslations struct {
+	Language string   `json:"language"`
+	Chain    []string `json:"chain"`
+	// SNPs maps a SNP ID to its resolved fields by field name.
+	SNPs map[int64]map[string]ResolvedText `json:"snps"`
+	// Phenotypes maps a phenotype ID to its resolved name.
+	Phenotypes map[int64]ResolvedText `json:"phenotypes"`
+	// Messages maps a message key to its resolved text.
+	Messages map[string]ResolvedText `json:"messages,omitempty"`
+}
+
+// SNPField returns the text of field of the SNP, or fallback if no language
+// of the chain translates it.
+func (t *ResolvedTranslations) SNPField(snpID int64, field, fallback string) string {
+	if text, ok := t.SNPs[snpID][field]; ok {
+		return text.Text
+	}
+	return fallback
+}
+
+// PhenotypeName returns the name of p, untranslated if no language of the
+// chain translates it.
+func (t *ResolvedTranslations) PhenotypeName(p *models.Phenotype) string {
+	if text, ok := t.Phenotypes[p.ID]; ok {
+		return text.Text
+	}
+	return p.PhenotypeName
+}
+
+// Message returns the text of the message key, or text, its English, if no
+// language of the chain translates it.
+func (t *ResolvedTranslations) Message(key, text string) string {
+	if resolved, ok := t.Messages[key]; ok {
+		return resolved.Text
+	}
+	return text
+}
+
+// TranslationResolver looks up translations through the fallback chains of
+// a LocalizationConfig, so a partially translated language still reads
+// complete.
+type TranslationResolver struct {
+	translations TranslationRepository
+	config       LocalizationConfig
+}
+
+// NewTranslationResolver resolves the translations of translations with the
+// fallback chains of cfg.
+func NewTranslationResolver(translations TranslationRepository, cfg LocalizationConfig) *TranslationResolver {
+	return &TranslationResolver{translations: translations, config: cfg}
+}
+
+// ResolveTranslations returns the texts of the SNP snpID and its phenotypes
+// in lang.
+func (r *TranslationResolver) ResolveTranslations(ctx context.Context, snpID int64, lang string) (*ResolvedTranslations, error) {
+	return r.Resolve(ctx, lang, []int64{snpID})
+}
+
+// Resolve returns the texts of the SNPs and their phenotypes in lang,
+// loading the translations of each language of its chain once.
+func (r *TranslationResolver) Resolve(ctx context.Context, lang string, snpIDs []int64) (*ResolvedTranslations, error) {
+	resolved := &ResolvedTranslations{
+		Language:   normalizeLanguage(lang),
+		Chain:      r.config.Chain(lang),
+		SNPs:       make(map[int64]map[string]ResolvedText),
+		Phenotypes: make(map[int64]ResolvedText),
+		Messages:   make(map[string]ResolvedText),
+	}
+	if len(snpIDs) == 0 {
+		return resolved, nil
+	}
+	// Walk the chain from its end so more specific languages overwrite.
+	for i := len(resolved.Chain) - 1; i >= 0; i-- {
+		l := resolved.Chain[i]
+		t, err := r.translations.Get(ctx, l, snpIDs)
+		if err != nil {
+			return nil, err
+		}
+		for snpID, fields := range t.SNPs {
+			for field, text := range fields {
+				if text == "" {
+					continue
+				}
+				if resolved.SNPs[snpID] == nil {
+					resolved.SNPs[snpID] = make(map[string]ResolvedText)
+				}
+				resolved.SNPs[snpID][field] = ResolvedText{Text: text, Language: l}
+			}
+		}
+		for id, name := range t.Phenotypes {
+			resolved.Phenotypes[id] = ResolvedText{Text: name, Language: l}
+		}
+		for key, text := range t.Messages {
+			if text != "" {
+				resolved.Messages[key] = ResolvedText{Text: text, Language: l}
+			}
+		}
+	}
+	return resolved, nil
+}
+
+// LocalizedSNPRepository wraps another SNPRepository and returns SNPs with
+// condition and phenotype names, significance levels and score reasons
+// translated into one language and Summary filled, so readers such as the report need not join translations
+// themselves. Text not translated into the language is resolved through its
+// fallback chain, then left untranslated.
+//
+// Returned SNPs are copies; the wrapped repository's, which may be shared
+// cache entries, are left untouched. Writes pass through, so SNPs read here
+// must not be written back.
+type LocalizedSNPRepository struct {
+	next     SNPRepository
+	resolver *TranslationResolver
+	language string
+}
+
+// NewLocalizedSNPRepository localizes the SNPs of next into lang with the
+// translations of translations, falling back as cfg configures.
+func NewLocalizedSNPRepository(next SNPRepository, translations TranslationRepository, lang string, cfg LocalizationConfig) *LocalizedSNPRepository {
+	return &LocalizedSNPRepository{next: next, resolver: NewTranslationResolver(translations, cfg), language: cfg.Chain(lang)[0]}
+}
+
+// Language returns the language SNPs are localized into.
+func (r *LocalizedSNPRepository) Language() string {
+	return r.language
+}
+
+func (r *LocalizedSNPRepository) GetByRsID(ctx context.Context, rsID string) (*models.SNP, error) {
+	snp, err := r.next.GetByRsID(ctx, rsID)
+	if err != nil {
+		return nil, err
+	}
+	localized, err := r.localize(ctx, []*models.SNP{snp})
+	if err != nil {
+		return nil, err
+	}
+	return localized[0], nil
+}
+
+func (r *LocalizedSNPRepository) GetByRsIDs(ctx context.Context, rsIDs []string) (map[string]*models.SNP, error) {
+	found, err := r.next.GetByRsIDs(ctx, rsIDs)
+	if err != nil {
+		return nil, err
+	}
+	snps := make([]*models.SNP, 0, len(found))
+	for _, snp := range found {
+		snps = append(snps, snp)
+	}
+	localized, err := r.localize(ctx, snps)
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string]*models.SNP, len(localized))
+	for _, snp := range localized {
+		result[snp.RsID] = snp
+	}
+	return result, nil
+}
+
+func (r *LocalizedSNPRepository) GetByVariantKeys(ctx context.Context, keys []string) ([]*models.SNP, error) {
+	snps, err := r.next.GetByVariantKeys(ctx, keys)
+	if err != nil {
+		return nil, err
+	}
+	return r.localize(ctx, snps)
+}
+
+func (r *LocalizedSNPRepository) Upsert(ctx context.Context, snps []*models.SNP) error {
+	return r.next.Upsert(ctx, snps)
+}
+
+// ForEach localizes each batch, for exports in the reader's language.
+func (r *LocalizedSNPRepository) ForEach(ctx context.Context, batchSize int, fn func(batch []*models.SNP) error) error {
+	return r.next.ForEach(ctx, batchSize, func(batch []*models.SNP) error {
+		localized, err := r.localize(ctx, batch)
+		if err != nil {
+			return err
+		}
+		return fn(localized)
+	})
+}
+
+// ForEachStale passes through untranslated: it feeds the scorer, which
+// writes what it reads back.
+func (r *LocalizedSNPRepository) ForEachStale(ctx context.Context, batchSize int, fn func(batch []*models.SNP) error) error {
+	return r.next.ForEachStale(ctx, batchSize, fn)
+}
+
+// localize returns translated copies of snps.
+func (r *LocalizedSNPRepository) localize(ctx context.Context, snps []*models.SNP) ([]*models.SNP, error) {
+	if len(snps) == 0 {
+		return snps, nil
+	}
+	ids := make([]int64, len(snps))
+	for i, snp := range snps {
+		ids[i] = snp.ID
+	}
+	t, err := r.resolver.Resolve(ctx, r.language, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	localized := make([]*models.SNP, len(snps))
+	for i, snp := range snps {
+		c := *snp
+		c.Summary = t.SNPField(snp.ID, models.TranslationSummary, snp.Summary)
+		c.ClinicalData = make([]*models.ClinicalData, len(snp.ClinicalData))
+		for j, cd := range snp.ClinicalData {
+			row := *cd
+			row.ConditionName = t.SNPField(snp.ID, models.TranslationConditionField(cd.ConditionName), cd.ConditionName)
+			c.ClinicalData[j] = &row
+		}
+		c.RiskAlleles = make([]*models.RiskAllele, len(snp.RiskAlleles))
+		for j, risk := range snp.RiskAlleles {
+			row := *risk
+			row.ConditionName = t.SNPField(snp.ID, models.TranslationConditionField(risk.ConditionName), risk.ConditionName)
+			c.RiskAlleles[j] = &row
+		}
+		c.GenotypeEffects = make([]*models.GenotypeEffect, len(snp.GenotypeEffects))
+		for j, effect := range snp.GenotypeEffects {
+			row := *effect
+			row.ConditionName = t.SNPField(snp.ID, models.TranslationConditionField(effect.ConditionName), effect.ConditionName)
+			c.GenotypeEffects[j] = &row
+		}
+		c.ClinicalAgreements = make([]*models.ClinicalAgreement, len(snp.ClinicalAgreements))
+		for j, agreement := range snp.ClinicalAgreements {
+			row := *agreement
+			row.ConditionName = t.SNPField(snp.ID, models.TranslationConditionField(agreement.ConditionName), agreement.ConditionName)
+			c.ClinicalAgreements[j] = &row
+		}
+		if snp.Significance != nil {
+			sig := *snp.Significance
+			level := sig.LevelMessage()
+			sig.Level = t.Message(level.Key, level.Text)
+			sig.ScoreDetails.Reasons = sig.ScoreDetails.LocalizedReasons(t.Message)
+			c.Significance = &sig
+		}
+		c.Phenotypes = make([]*models.Phenotype, len(snp.Phenotypes))
+		for j, p := range snp.Phenotypes {
+			row := *p
+			row.PhenotypeName = t.PhenotypeName(p)
+			c.Phenotypes[j] = &row
+		}
+		localized[i] = &c
+	}
+	return localized, nil
+}
@@ -0,0 +1,159 @@
+package repositories
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/mkoziy/genome/exporter/internal/models"
+)
+
+func TestFallbackChain(t *testing.T) {
+	cfg := LocalizationConfig{Fallbacks: map[string][]string{
+		"pt_BR": {"pt-PT"},
+		"uk":    {"ru"},
+		"ru":    {"uk"},
+	}}
+	for lang, want := range map[string][]string{
+		"":      {"en"},
+		"de":    {"de", "en"},
+		"de-AT": {"de-AT", "de", "en"},
+		"pt-BR": {"pt-BR", "pt-PT", "pt", "en"},
+		"pt_BR": {"pt-BR", "pt-PT", "pt", "en"},
+		"uk":    {"uk", "ru", "en"},
+		"en-GB": {"en-GB", "en"},
+	} {
+		if got := cfg.Chain(lang); !slices.Equal(got, want) {
+			t.Errorf("Chain(%q) = %v, want %v", lang, got, want)
+		}
+	}
+	if err := (LocalizationConfig{Fallbacks: map[string][]string{"pt-BR": {" "}}}).Validate(); err == nil {
+		t.Error("expected an empty fallback language rejected")
+	}
+}
+
+func TestLocalizedSNPRepository(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+	repos := NewBunRepositories(db)
+
+	snp := testSNP("rs1", "1", 100)
+	if err := repos.SNPs.Upsert(ctx, []*models.SNP{snp}); err != nil {
+		t.Fatalf("upsert: %v", err)
+	}
+	clinical := []*models.ClinicalData{{SNPID: snp.ID, ClinicalSignificance: models.ClinicalPathogenic, ReviewStatus: models.ReviewExpertPanel, ConditionName: "Cystic fibrosis", Source: models.SourceClinVar}}
+	if err := repos.Clinical.Upsert(ctx, clinical); err != nil {
+		t.Fatalf("upsert clinical: %v", err)
+	}
+	phenotype := &models.Phenotype{SNPID: snp.ID, PhenotypeName: "Eye color", AssociationType: "association", Source: models.SourceGWAS}
+	if _, err := db.NewInsert().Model(phenotype).Exec(ctx); err != nil {
+		t.Fatalf("insert phenotype: %v", err)
+	}
+	risk := &models.RiskAllele{SNPID: snp.ID, Allele: "T", Effect: "pathogenic", ConditionName: "Cystic fibrosis", Source: models.SourceClinVar}
+	if _, err := db.NewInsert().Model(risk).Exec(ctx); err != nil {
+		t.Fatalf("insert risk allele: %v", err)
+	}
+	condition := models.TranslationConditionField("Cystic fibrosis")
+	err := repos.Translations.Upsert(ctx, []*models.Translation{
+		{SNPID: snp.ID, LanguageCode: "pt", FieldName: condition, TranslatedText: "Fibrose cística"},
+		{SNPID: snp.ID, LanguageCode: "en", FieldName: models.TranslationSummary, TranslatedText: "Causes cystic fibrosis."},
+	}, []*models.PhenotypeTranslation{
+		{PhenotypeID: phenotype.ID, LanguageCode: "pt-BR", TranslatedName: "Cor dos olhos"},
+	})
+	if err != nil {
+		t.Fatalf("upsert translations: %v", err)
+	}
+	sig := &models.Significance{SNPID: snp.ID, TotalScore: 85}
+	sig.ScoreDetails.AddReason(40, models.ReasonClinicalAssertion, models.LabelMessage(string(models.ReviewExpertPanel)), models.LabelMessage(string(models.ClinicalPathogenic)))
+	if err := repos.Significance.Save(ctx, sig); err != nil {
+		t.Fatalf("save significance: %v", err)
+	}
+	err = UpsertMessageTranslations(ctx, db, []*models.MessageTranslation{
+		{MessageKey: models.LevelVeryHigh.Key, LanguageCode: "pt", TranslatedText: "Muito alta"},
+		{MessageKey: models.ReasonClinicalAssertion.Key, LanguageCode: "pt", TranslatedText: "afirmação {2} ({1})"},
+		{MessageKey: "label/pathogenic", LanguageCode: "pt", TranslatedText: "patogénica"},
+	})
+	if err != nil {
+		t.Fatalf("upsert message translations: %v", err)
+	}
+
+	cache := NewCachedSNPRepository(repos.SNPs, 10, time.Minute)
+	localized := NewLocalizedSNPRepository(cache, repos.Translations, "pt-BR", LocalizationConfig{})
+	got, err := localized.GetByRsID(ctx, "rs1")
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if got.ClinicalData[0].ConditionName != "Fibrose cística" || got.RiskAlleles[0].ConditionName != "Fibrose cística" {
+		t.Fatalf("expected the pt condition name, got %+v %+v", got.ClinicalData[0], got.RiskAlleles[0])
+	}
+	if got.Phenotypes[0].PhenotypeName != "Cor dos olhos" {
+		t.Fatalf("expected the pt-BR phenotype name, got %q", got.Phenotypes[0].PhenotypeName)
+	}
+	if got.Summary != "Causes cystic fibrosis." {
+		t.Fatalf("expected the en summary as fallback, got %q", got.Summary)
+	}
+	// The review status has no translation and stays in English.
+	if level, reasons := got.Significance.SignificanceLevel(), got.Significance.ScoreDetails.Reasons; level != "Muito alta" || !slices.Equal(reasons, []string{"afirmação patogénica (expert-panel) (+40)"}) {
+		t.Fatalf("expected the pt level and reasons, got %q %q", level, reasons)
+	}
+
+	cached, err := cache.GetByRsID(ctx, "rs1")
+	if err != nil {
+		t.Fatalf("get cached: %v", err)
+	}
+	if cached.ClinicalData[0].ConditionName != "Cystic fibrosis" || cached.Summary != "" || cached.Significance.SignificanceLevel() != "Very High" {
+		t.Fatalf("expected the cached SNP to stay untranslated, got %+v", cached.ClinicalData[0])
+	}
+
+	byKey, err := NewLocalizedSNPRepository(repos.SNPs, repos.Translations, "fr", LocalizationConfig{}).GetByVariantKeys(ctx, []string{"1:100:C:T"})
+	if err != nil {
+		t.Fatalf("get by key: %v", err)
+	}
+	if len(byKey) != 1 || byKey[0].ClinicalData[0].ConditionName != "Cystic fibrosis" || byKey[0].Summary != "Causes cystic fibrosis." {
+		t.Fatalf("expected untranslated names and the en summary, got %+v", byKey)
+	}
+}
+
+func TestResolveTranslations(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+	repos := NewBunRepositories(db)
+
+	snp := testSNP("rs1", "1", 100)
+	if err := repos.SNPs.Upsert(ctx, []*models.SNP{snp}); err != nil {
+		t.Fatalf("upsert: %v", err)
+	}
+	phenotype := &models.Phenotype{SNPID: snp.ID, PhenotypeName: "Eye color", AssociationType: "association", Source: models.SourceGWAS}
+	if _, err := db.NewInsert().Model(phenotype).Exec(ctx); err != nil {
+		t.Fatalf("insert phenotype: %v", err)
+	}
+	condition := models.TranslationConditionField("Cystic fibrosis")
+	err := repos.Translations.Upsert(ctx, []*models.Translation{
+		{SNPID: snp.ID, LanguageCode: "en", FieldName: models.TranslationSummary, TranslatedText: "Causes cystic fibrosis."},
+		{SNPID: snp.ID, LanguageCode: "pt", FieldName: models.TranslationSummary, TranslatedText: "Causa fibrose cística."},
+		{SNPID: snp.ID, LanguageCode: "pt-PT", FieldName: condition, TranslatedText: "Fibrose quística"},
+		{SNPID: snp.ID, LanguageCode: "pt", FieldName: condition, TranslatedText: "Fibrose cística"},
+	}, []*models.PhenotypeTranslation{
+		{PhenotypeID: phenotype.ID, LanguageCode: "en", TranslatedName: "Eye colour"},
+	})
+	if err != nil {
+		t.Fatalf("upsert translations: %v", err)
+	}
+
+	resolver := NewTranslationResolver(repos.Translations, LocalizationConfig{Fallbacks: map[string][]string{"pt-BR": {"pt-PT", "pt"}}})
+	got, err := resolver.ResolveTranslations(ctx, snp.ID, "pt-BR")
+	if err != nil {
+		t.Fatalf("resolve: %v", err)
+	}
+	fields := got.SNPs[snp.ID]
+	if fields[models.TranslationSummary] != (ResolvedText{"Causa fibrose cística.", "pt"}) || fields[condition] != (ResolvedText{"Fibrose quística", "pt-PT"}) {
+		t.Errorf("unexpected fields: %v", fields)
+	}
+	if got.Phenotypes[phenotype.ID] != (ResolvedText{"Eye colour", "en"}) || got.PhenotypeName(phenotype) != "Eye colour" {
+		t.Errorf("expected the en phenotype name, got %v", got.Phenotypes)
+	}
+	if got.SNPField(snp.ID, "condition:Asthma", "Asthma") != "Asthma" {
+		t.Error("expected an untranslated field to fall back to its text")
+	}
+}
@@ -0,0 +1,439 @@
+// Package memory provides in-memory fakes of the repository interfaces for unit
+// tests that should not depend on a SQLite file.
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/mkoziy/genome/exporter/internal/models"
+	"github.com/mkoziy/genome/exporter/internal/repositories"
+)
+
+// Store holds all tables of the fake. Rows are copied on the way in and out so
+// callers cannot mutate stored state, mirroring a real database.
+type Store struct {
+	mu     sync.Mutex
+	nextID int64
+
+	snps                  map[int64]*models.SNP
+	rsIndex               map[string]int64
+	clinical              map[int64]*models.ClinicalData
+	references            map[int64]*models.Reference
+	populations           map[int64]*models.PopulationFreq
+	significance          map[int64]*models.Significance // keyed by snp_id
+	translations          map[int64]*models.Translation
+	phenotypeTranslations map[int64]*models.PhenotypeTranslation
+}
+
+// NewStore creates an empty store.
+func NewStore() *Store {
+	return &Store{
+		snps:                  make(map[int64]*models.SNP),
+		rsIndex:               make(map[string]int64),
+		clinical:              make(map[int64]*models.ClinicalData),
+		references:            make(map[int64]*models.Reference),
+		populations:           make(map[int64]*models.PopulationFreq),
+		significance:          make(map[int64]*models.Significance),
+		translations:          make(map[int64]*models.Translation),
+		phenotypeTranslations: make(map[int64]*models.PhenotypeTranslation),
+	}
+}
+
+// NewRepositories returns repositories backed by a fresh in-memory store.
+func NewRepositories() *repositories.Repositories {
+	return NewStore().Repositories()
+}
+
+// Repositories returns repositories backed by s.
+func (s *Store) Repositories() *repositories.Repositories {
+	return &repositories.Repositories{
+		SNPs:         snpRepo{s},
+		Clinical:     clinicalRepo{s},
+		References:   referenceRepo{s},
+		Populations:  populationRepo{s},
+		Significance: significanceRepo{s},
+		Translations: translationRepo{s},
+	}
+}
+
+// markStale flags the score of snpID for recalculation, standing in for the
+// triggers that do this in SQLite (call with lock held).
+func (s *Store) markStale(snpID int64) {
+	if sig, ok := s.significance[snpID]; ok {
+		sig.Stale = true
+	}
+}
+
+func (s *Store) id() int64 {
+	s.nextID++
+	return s.nextID
+}
+
+// loadSNP returns a copy of the SNP with relations attached (call with lock held).
+func (s *Store) loadSNP(id int64) *models.SNP {
+	stored := *s.snps[id]
+	snp := &stored
+	if sig, ok := s.significance[id]; ok {
+		c := *sig
+		snp.Significance = &c
+	}
+	snp.ClinicalData = collect(s.clinical, func(c *models.ClinicalData) bool { return c.SNPID == id })
+	snp.References = collect(s.references, func(r *models.Reference) bool { return r.SNPID == id })
+	snp.PopulationData = collect(s.populations, func(p *models.PopulationFreq) bool { return p.SNPID == id })
+	snp.Phenotypes = []*models.Phenotype{}
+	return snp
+}
+
+// collect returns copies of matching rows ordered by id.
+func collect[T any](rows map[int64]*T, match func(*T) bool) []*T {
+	ids := make([]int64, 0)
+	for id, row := range rows {
+		if match(row) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	result := make([]*T, 0, len(ids))
+	for _, id := range ids {
+		c := *rows[id]
+		result = append(result, &c)
+	}
+	return result
+}
+
+type snpRepo struct{ s *Store }
+
+func (r snpRepo) GetByRsID(_ context.Context, rsID string) (*models.SNP, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	id, ok := r.s.rsIndex[rsID]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return r.s.loadSNP(id), nil
+}
+
+func (r snpRepo) GetByRsIDs(_ context.Context, rsIDs []string) (map[string]*models.SNP, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	result := make(map[string]*models.SNP, len(rsIDs))
+	for _, rsID := range rsIDs {
+		if id, ok := r.s.rsIndex[rsID]; ok {
+			result[rsID] = r.s.loadSNP(id)
+		}
+	}
+	return result, nil
+}
+
+func (r snpRepo) GetByVariantKeys(_ context.Context, keys []string) ([]*models.SNP, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	want := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		want[key] = true
+	}
+	ids := make([]int64, 0)
+	for id, snp := range r.s.snps {
+		// The bun hook that derives keys does not run here.
+		if key := snp.CanonicalKey(); key != nil && want[*key] {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	result := make([]*models.SNP, len(ids))
+	for i, id := range ids {
+		result[i] = r.s.loadSNP(id)
+		result[i].VariantKey = result[i].CanonicalKey()
+	}
+	return result, nil
+}
+
+func (r snpRepo) Upsert(_ context.Context, snps []*models.SNP) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	now := time.Now()
+	for _, snp := range snps {
+		if id, ok := r.s.rsIndex[snp.RsID]; ok {
+			snp.ID = id
+			snp.CreatedAt = r.s.snps[id].CreatedAt
+		} else {
+			snp.ID = r.s.id()
+			snp.CreatedAt = now
+			r.s.rsIndex[snp.RsID] = snp.ID
+		}
+		snp.UpdatedAt = now
+
+		stored := *snp
+		stored.Significance, stored.ClinicalData, stored.Phenotypes, stored.References, stored.PopulationData = nil, nil, nil, nil, nil
+		r.s.snps[snp.ID] = &stored
+	}
+	return nil
+}
+
+func (r snpRepo) ForEach(ctx context.Context, batchSize int, fn func(batch []*models.SNP) error) error {
+	return r.forEach(ctx, batchSize, func(int64) bool { return true }, fn)
+}
+
+func (r snpRepo) ForEachStale(ctx context.Context, batchSize int, fn func(batch []*models.SNP) error) error {
+	return r.forEach(ctx, batchSize, func(id int64) bool {
+		sig, ok := r.s.significance[id]
+		return !ok || sig.Stale
+	}, fn)
+}
+
+// forEach batches the SNPs matching match (called with lock held) in id order.
+func (r snpRepo) forEach(ctx context.Context, batchSize int, match func(id int64) bool, fn func(batch []*models.SNP) error) error {
+	if batchSize <= 0 {
+		batchSize = 500
+	}
+
+	r.s.mu.Lock()
+	ids := make([]int64, 0, len(r.s.snps))
+	for id := range r.s.snps {
+		if match(id) {
+			ids = append(ids, id)
+		}
+	}
+	r.s.mu.Unlock()
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	for start := 0; start < len(ids); start += batchSize {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		end := start + batchSize
+		if end > len(ids) {
+			end = len(ids)
+		}
+
+		r.s.mu.Lock()
+		batch := make([]*models.SNP, 0, end-start)
+		for _, id := range ids[start:end] {
+			if _, ok := r.s.snps[id]; ok {
+				batch = append(batch, r.s.loadSNP(id))
+			}
+		}
+		r.s.mu.Unlock()
+
+		if err := fn(batch); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type clinicalRepo struct{ s *Store }
+
+func (r clinicalRepo) Upsert(_ context.Context, rows []*models.ClinicalData) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	for _, row := range rows {
+		row.ID = 0
+		for id, existing := range r.s.clinical {
+			if existing.NaturalKey() == row.NaturalKey() {
+				row.ID, row.CreatedAt = id, existing.CreatedAt
+				break
+			}
+		}
+		if row.ID == 0 {
+			row.ID, row.CreatedAt = r.s.id(), time.Now()
+		}
+		stored := *row
+		stored.SNP = nil
+		r.s.clinical[row.ID] = &stored
+		r.s.markStale(row.SNPID)
+	}
+	return nil
+}
+
+func (r clinicalRepo) ListBySNP(_ context.Context, snpID int64) ([]*models.ClinicalData, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	return collect(r.s.clinical, func(c *models.ClinicalData) bool { return c.SNPID == snpID }), nil
+}
+
+type referenceRepo struct{ s *Store }
+
+func (r referenceRepo) Upsert(_ context.Context, rows []*models.Reference) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	for _, row := range rows {
+		row.ID = 0
+		if row.PubmedID != nil {
+			for id, existing := range r.s.references {
+				if existing.SNPID == row.SNPID && existing.PubmedID != nil && *existing.PubmedID == *row.PubmedID {
+					row.ID, row.CreatedAt = id, existing.CreatedAt
+					break
+				}
+			}
+		}
+		if row.ID == 0 {
+			row.ID, row.CreatedAt = r.s.id(), time.Now()
+		}
+		stored := *row
+		stored.SNP = nil
+		r.s.references[row.ID] = &stored
+		r.s.markStale(row.SNPID)
+	}
+	return nil
+}
+
+func (r referenceRepo) ListBySNP(_ context.Context, snpID int64) ([]*models.Reference, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	return collect(r.s.references, func(ref *models.Reference) bool { return ref.SNPID == snpID }), nil
+}
+
+type populationRepo struct{ s *Store }
+
+func (r populationRepo) Upsert(_ context.Context, rows []*models.PopulationFreq) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	for _, row := range rows {
+		row.ID = 0
+		for id, existing := range r.s.populations {
+			if existing.SNPID == row.SNPID && existing.Source == row.Source &&
+				existing.PopulationCode == row.PopulationCode && existing.Allele == row.Allele {
+				row.ID, row.CreatedAt = id, existing.CreatedAt
+				break
+			}
+		}
+		if row.ID == 0 {
+			row.ID, row.CreatedAt = r.s.id(), time.Now()
+		}
+		stored := *row
+		stored.SNP = nil
+		r.s.populations[row.ID] = &stored
+		r.s.markStale(row.SNPID)
+	}
+	return nil
+}
+
+func (r populationRepo) ListBySNP(_ context.Context, snpID int64) ([]*models.PopulationFreq, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	return collect(r.s.populations, func(p *models.PopulationFreq) bool { return p.SNPID == snpID }), nil
+}
+
+type significanceRepo struct{ s *Store }
+
+func (r significanceRepo) Save(_ context.Context, sig *models.Significance) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if existing, ok := r.s.significance[sig.SNPID]; ok {
+		sig.ID = existing.ID
+	} else {
+		sig.ID = r.s.id()
+	}
+	sig.Stale = false
+	sig.CalculatedAt = time.Now()
+	stored := *sig
+	stored.SNP = nil
+	r.s.significance[sig.SNPID] = &stored
+	return nil
+}
+
+func (r significanceRepo) GetBySNP(_ context.Context, snpID int64) (*models.Significance, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	sig, ok := r.s.significance[snpID]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	c := *sig
+	return &c, nil
+}
+
+type translationRepo struct{ s *Store }
+
+func (r translationRepo) Upsert(_ context.Context, rows []*models.Translation, phenotypes []*models.PhenotypeTranslation) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	for _, row := range rows {
+		row.Normalize()
+		row.ID = 0
+		for id, existing := range r.s.translations {
+			if existing.SNPID == row.SNPID && existing.LanguageCode == row.LanguageCode && existing.FieldName == row.FieldName {
+				row.ID = id
+				break
+			}
+		}
+		if existing := r.s.translations[row.ID]; existing != nil && existing.Status.Rank() > row.Status.Rank() {
+			continue
+		}
+		if row.ID == 0 {
+			row.ID = r.s.id()
+		}
+		stored := *row
+		stored.SNP = nil
+		r.s.translations[row.ID] = &stored
+	}
+	for _, row := range phenotypes {
+		row.Normalize()
+		row.ID = 0
+		for id, existing := range r.s.phenotypeTranslations {
+			if existing.PhenotypeID == row.PhenotypeID && existing.LanguageCode == row.LanguageCode {
+				row.ID = id
+				break
+			}
+		}
+		if existing := r.s.phenotypeTranslations[row.ID]; existing != nil && existing.Status.Rank() > row.Status.Rank() {
+			continue
+		}
+		if row.ID == 0 {
+			row.ID = r.s.id()
+		}
+		stored := *row
+		stored.Phenotype = nil
+		r.s.phenotypeTranslations[row.ID] = &stored
+	}
+	return nil
+}
+
+// Get returns the translations like the bun repository. Phenotypes are not stored, so every phenotype
+// translation in lang is returned; messages are not stored either, so none are.
+func (r translationRepo) Get(_ context.Context, lang string, snpIDs []int64) (*repositories.Translations, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	wanted := make(map[int64]bool, len(snpIDs))
+	for _, id := range snpIDs {
+		wanted[id] = true
+	}
+	t := &repositories.Translations{
+		Language:   lang,
+		SNPs:       make(map[int64]map[string]string),
+		Phenotypes: make(map[int64]string),
+		Messages:   make(map[string]string),
+	}
+	rows := collect(r.s.translations, func(row *models.Translation) bool {
+		return row.LanguageCode == lang && wanted[row.SNPID]
+	})
+	for _, row := range rows {
+		if t.SNPs[row.SNPID] == nil {
+			t.SNPs[row.SNPID] = make(map[string]string)
+		}
+		t.SNPs[row.SNPID][row.FieldName] = row.TranslatedText
+	}
+	names := collect(r.s.phenotypeTranslations, func(row *models.PhenotypeTranslation) bool { return row.LanguageCode == lang })
+	for _, row := range names {
+		t.Phenotypes[row.PhenotypeID] = row.TranslatedName
+	}
+	return t, nil
+}
@@ -0,0 +1,178 @@
+package memory_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/mkoziy/genome/exporter/internal/database"
+	"github.com/mkoziy/genome/exporter/internal/migrations"
+	"github.com/mkoziy/genome/exporter/internal/models"
+	"github.com/mkoziy/genome/exporter/internal/repositories"
+	"github.com/mkoziy/genome/exporter/internal/repositories/memory"
+)
+
+// TestContract runs the same scenario against the bun and in-memory
+// implementations so the fake cannot drift from the real behaviour.
+func TestContract(t *testing.T) {
+	impls := map[string]func(t *testing.T) *repositories.Repositories{
+		"memory": func(t *testing.T) *repositories.Repositories {
+			return memory.NewRepositories()
+		},
+		"bun": func(t *testing.T) *repositories.Repositories {
+			db, err := database.NewDB("file:"+t.Name()+"?mode=memory&cache=shared", false)
+			if err != nil {
+				t.Fatalf("open db: %v", err)
+			}
+			t.Cleanup(func() { _ = db.Close() })
+			if err := migrations.RunMigrations(context.Background(), db); err != nil {
+				t.Fatalf("migrate: %v", err)
+			}
+			return repositories.NewBunRepositories(db)
+		},
+	}
+
+	for name, newRepos := range impls {
+		t.Run(name, func(t *testing.T) {
+			testContract(t, newRepos(t))
+		})
+	}
+}
+
+func testContract(t *testing.T, repos *repositories.Repositories) {
+	ctx := context.Background()
+
+	if _, err := repos.SNPs.GetByRsID(ctx, "rs404"); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("expected sql.ErrNoRows for unknown rsID, got %v", err)
+	}
+
+	snps := []*models.SNP{
+		{RsID: "rs1", Chromosome: "1", Position: 100, ReferenceAllele: "C", AlternateAlleles: models.StringArray{"T"}, VariantType: models.VariantSNV},
+		{RsID: "rs2", Chromosome: "2", Position: 200, ReferenceAllele: "A", AlternateAlleles: models.StringArray{"G"}, VariantType: models.VariantSNV},
+	}
+	if err := repos.SNPs.Upsert(ctx, snps); err != nil {
+		t.Fatalf("upsert snps: %v", err)
+	}
+	snpID := snps[0].ID
+	if snpID == 0 {
+		t.Fatalf("expected upsert to assign IDs")
+	}
+
+	clinical := func(significance models.ClinicalSignificance) []*models.ClinicalData {
+		return []*models.ClinicalData{{
+			SNPID:                snpID,
+			ClinicalSignificance: significance,
+			ReviewStatus:         models.ReviewExpertPanel,
+			ConditionName:        "Condition",
+			Source:               models.SourceClinVar,
+		}}
+	}
+	if err := repos.Clinical.Upsert(ctx, clinical(models.ClinicalUncertainSignif)); err != nil {
+		t.Fatalf("upsert clinical: %v", err)
+	}
+	if err := repos.Clinical.Upsert(ctx, clinical(models.ClinicalPathogenic)); err != nil {
+		t.Fatalf("upsert clinical again: %v", err)
+	}
+	rows, err := repos.Clinical.ListBySNP(ctx, snpID)
+	if err != nil {
+		t.Fatalf("list clinical: %v", err)
+	}
+	if len(rows) != 1 || rows[0].ClinicalSignificance != models.ClinicalPathogenic {
+		t.Fatalf("expected one updated clinical row, got %+v", rows)
+	}
+
+	if _, err := repos.Significance.GetBySNP(ctx, snpID); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("expected sql.ErrNoRows for missing score, got %v", err)
+	}
+	for _, score := range []float64{40, 80} {
+		if err := repos.Significance.Save(ctx, &models.Significance{SNPID: snpID, TotalScore: score}); err != nil {
+			t.Fatalf("save significance: %v", err)
+		}
+	}
+	sig, err := repos.Significance.GetBySNP(ctx, snpID)
+	if err != nil {
+		t.Fatalf("get significance: %v", err)
+	}
+	if sig.TotalScore != 80 {
+		t.Fatalf("expected latest score 80, got %v", sig.TotalScore)
+	}
+
+	got, err := repos.SNPs.GetByRsID(ctx, "rs1")
+	if err != nil {
+		t.Fatalf("get snp: %v", err)
+	}
+	if len(got.ClinicalData) != 1 || got.Significance == nil {
+		t.Fatalf("expected relations to be loaded, got %+v", got)
+	}
+
+	byRsID, err := repos.SNPs.GetByRsIDs(ctx, []string{"rs1", "rs2", "rs404"})
+	if err != nil {
+		t.Fatalf("get snps: %v", err)
+	}
+	if len(byRsID) != 2 {
+		t.Fatalf("expected 2 SNPs, got %d", len(byRsID))
+	}
+
+	byKey, err := repos.SNPs.GetByVariantKeys(ctx, []string{"1:100:C:T", "1:100:C:G"})
+	if err != nil {
+		t.Fatalf("get snps by key: %v", err)
+	}
+	if len(byKey) != 1 || byKey[0].RsID != "rs1" || byKey[0].VariantKey == nil || len(byKey[0].ClinicalData) != 1 {
+		t.Fatalf("expected rs1 with its key and relations, got %+v", byKey)
+	}
+
+	err = repos.Translations.Upsert(ctx, []*models.Translation{
+		{SNPID: snpID, LanguageCode: "de", FieldName: models.TranslationSummary, TranslatedText: "geprüft", Verified: true},
+		{SNPID: snpID, LanguageCode: "de", FieldName: models.TranslationSummary, TranslatedText: "maschinell"},
+		{SNPID: snpID, LanguageCode: "fr", FieldName: models.TranslationSummary, TranslatedText: "résumé"},
+	}, nil)
+	if err != nil {
+		t.Fatalf("upsert translations: %v", err)
+	}
+	tr, err := repos.Translations.Get(ctx, "de", []int64{snpID})
+	if err != nil {
+		t.Fatalf("get translations: %v", err)
+	}
+	if got := tr.SNPField(snpID, models.TranslationSummary); got != "geprüft" {
+		t.Fatalf("expected the verified translation, got %q", got)
+	}
+
+	var seen []string
+	err = repos.SNPs.ForEach(ctx, 1, func(batch []*models.SNP) error {
+		for _, s := range batch {
+			seen = append(seen, s.RsID)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("for each: %v", err)
+	}
+	if len(seen) != 2 || seen[0] != "rs1" || seen[1] != "rs2" {
+		t.Fatalf("unexpected iteration order: %v", seen)
+	}
+
+	stale := func() []string {
+		t.Helper()
+		var rsIDs []string
+		err := repos.SNPs.ForEachStale(ctx, 1, func(batch []*models.SNP) error {
+			for _, s := range batch {
+				rsIDs = append(rsIDs, s.RsID)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("for each stale: %v", err)
+		}
+		return rsIDs
+	}
+	if got := stale(); len(got) != 1 || got[0] != "rs2" {
+		t.Fatalf("expected only unscored rs2 to be stale, got %v", got)
+	}
+	if err := repos.Clinical.Upsert(ctx, clinical(models.ClinicalLikelyPathogenic)); err != nil {
+		t.Fatalf("change clinical: %v", err)
+	}
+	if got := stale(); len(got) != 2 {
+		t.Fatalf("expected changed rs1 to be stale, got %v", got)
+	}
+}
@@ -0,0 +1,234 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/uptrace/bun"
+
+	"github.com/mkoziy/genome/exporter/internal/models"
+)
+
+// DuplicateGroup is a set of SNPs sharing a variant key, i.e. the same
+// normalized coordinates and alleles, under different rsIDs. SNPs[0] is the one
+// to keep: the lowest rs number, which is the one dbSNP retains when it merges
+// records.
+type DuplicateGroup struct {
+	VariantKey      string        `json:"variant_key"`
+	Chromosome      string        `json:"chromosome"`
+	Position        int64         `json:"position"`
+	ReferenceAllele string        `json:"reference_allele"`
+	SNPs            []*models.SNP `json:"snps"`
+}
+
+// MergeResult reports what MergeDuplicates changed.
+type MergeResult struct {
+	Groups int `json:"groups"`
+	Merged int `json:"merged"`
+}
+
+// FindDuplicateSNPs returns groups of SNPs with the same variant key.
+func FindDuplicateSNPs(ctx context.Context, db *bun.DB) ([]*DuplicateGroup, error) {
+	var snps []*models.SNP
+	err := db.NewSelect().
+		Model(&snps).
+		Where(`s.variant_key IN (
+			SELECT variant_key FROM snps
+			WHERE variant_key IS NOT NULL AND deleted_at IS NULL
+			GROUP BY variant_key
+			HAVING COUNT(*) > 1)`).
+		OrderExpr("s.chromosome ASC, s.position ASC, s.variant_key ASC").
+		Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	groups := make([]*DuplicateGroup, 0)
+	index := make(map[string]*DuplicateGroup)
+	for _, snp := range snps {
+		key := *snp.VariantKey
+		group, ok := index[key]
+		if !ok {
+			group = &DuplicateGroup{VariantKey: key, Chromosome: snp.Chromosome, Position: snp.Position, ReferenceAllele: snp.ReferenceAllele}
+			index[key] = group
+			groups = append(groups, group)
+		}
+		group.SNPs = append(group.SNPs, snp)
+	}
+	for _, group := range groups {
+		sort.SliceStable(group.SNPs, func(i, j int) bool {
+			return rsNumber(group.SNPs[i].RsID) < rsNumber(group.SNPs[j].RsID)
+		})
+	}
+	return groups, nil
+}
+
+// MergeDuplicates merges every duplicate group into its lowest rsID.
+func MergeDuplicates(ctx context.Context, db *bun.DB) (*MergeResult, error) {
+	groups, err := FindDuplicateSNPs(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &MergeResult{}
+	for _, group := range groups {
+		drop := make([]int64, 0, len(group.SNPs)-1)
+		for _, snp := range group.SNPs[1:] {
+			drop = append(drop, snp.ID)
+		}
+		if err := MergeSNPs(ctx, db, group.SNPs[0].ID, drop, models.AliasReasonDuplicate); err != nil {
+			return nil, fmt.Errorf("merge into %s: %w", group.SNPs[0].RsID, err)
+		}
+		result.Groups++
+		result.Merged += len(drop)
+	}
+	return result, nil
+}
+
+// mergedTables are the child tables whose rows move to the kept SNP. Rows that
+// would collide with an existing natural key on the kept SNP are dropped.
+var mergedTables = []string{
+	"snp_clinical",
+	"snp_phenotypes",
+	"snp_populations",
+	"snp_references",
+	"risk_alleles",
+	"genotype_effects",
+	"clinical_agreements",
+	"snp_significance",
+	"snp_significance_history",
+	"snp_quality",
+	"snp_frequencies",
+	"snp_hardy_weinberg",
+	"retractions",
+}
+
+// MergeSNPs moves the child rows of dropIDs onto keepID, records the dropped
+// rsIDs as aliases of keepID and deletes the dropped SNPs, in one transaction.
+func MergeSNPs(ctx context.Context, db *bun.DB, keepID int64, dropIDs []int64, reason string) error {
+	if len(dropIDs) == 0 {
+		return nil
+	}
+
+	return db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		var dropped []*models.SNP
+		if err := tx.NewSelect().Model(&dropped).Where("s.id IN (?)", bun.In(dropIDs)).Scan(ctx); err != nil {
+			return err
+		}
+
+		for _, table := range mergedTables {
+			if _, err := tx.NewRaw("UPDATE OR IGNORE ? SET snp_id = ? WHERE snp_id IN (?)",
+				bun.Ident(table), keepID, bun.In(dropIDs)).Exec(ctx); err != nil {
+				return fmt.Errorf("move %s: %w", table, err)
+			}
+			if _, err := tx.NewRaw("DELETE FROM ? WHERE snp_id IN (?)",
+				bun.Ident(table), bun.In(dropIDs)).Exec(ctx); err != nil {
+				return fmt.Errorf("drop colliding %s: %w", table, err)
+			}
+		}
+		// The surviving score may have been the dropped SNP's, computed from other evidence.
+		if _, err := tx.NewUpdate().Model((*models.Significance)(nil)).
+			Set("stale = 1").
+			Where("snp_id = ?", keepID).
+			Exec(ctx); err != nil {
+			return fmt.Errorf("mark score stale: %w", err)
+		}
+
+		// Translations have no unique key; keep the kept SNP's text where both exist.
+		if _, err := tx.NewRaw(`DELETE FROM snp_translations WHERE snp_id IN (?0) AND EXISTS (
+			SELECT 1 FROM snp_translations AS k WHERE k.snp_id = ?1
+				AND k.language_code = snp_translations.language_code
+				AND k.field_name = snp_translations.field_name)`, bun.In(dropIDs), keepID).Exec(ctx); err != nil {
+			return fmt.Errorf("drop colliding translations: %w", err)
+		}
+		if _, err := tx.NewRaw("UPDATE snp_translations SET snp_id = ? WHERE snp_id IN (?)", keepID, bun.In(dropIDs)).Exec(ctx); err != nil {
+			return fmt.Errorf("move translations: %w", err)
+		}
+
+		if _, err := tx.NewUpdate().Model((*models.SNPAlias)(nil)).
+			Set("snp_id = ?", keepID).
+			Where("snp_id IN (?)", bun.In(dropIDs)).
+			Exec(ctx); err != nil {
+			return fmt.Errorf("repoint aliases: %w", err)
+		}
+		if len(dropped) > 0 {
+			aliases := make([]*models.SNPAlias, len(dropped))
+			for i, snp := range dropped {
+				aliases[i] = &models.SNPAlias{RsID: snp.RsID, SNPID: keepID, Reason: reason}
+			}
+			if _, err := tx.NewInsert().Model(&aliases).
+				On("CONFLICT (rsid) DO UPDATE").
+				Set("snp_id = EXCLUDED.snp_id").
+				Set("reason = EXCLUDED.reason").
+				Exec(ctx); err != nil {
+				return fmt.Errorf("record aliases: %w", err)
+			}
+		}
+
+		_, err := tx.NewDelete().Model((*models.SNP)(nil)).Where("id IN (?)", bun.In(dropIDs)).ForceDelete().Exec(ctx)
+		return err
+	})
+}
+
+// ResolveRsID returns the current rsID for rsID, following merge aliases. rsIDs
+// that are not aliases are returned unchanged.
+func ResolveRsID(ctx context.Context, db *bun.DB, rsID string) (string, error) {
+	var current string
+	err := db.NewSelect().
+		Model((*models.SNPAlias)(nil)).
+		ColumnExpr("s.rsid").
+		Join("JOIN snps AS s ON s.id = sa.snp_id").
+		Where("sa.rsid = ?", rsID).
+		Scan(ctx, &current)
+	if errors.Is(err, sql.ErrNoRows) {
+		return rsID, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return current, nil
+}
+
+// ResolveRsIDs is ResolveRsID for many rsIDs, in chunks: it maps each rsID
+// that is a merge alias to its current rsID. Other rsIDs are absent.
+func ResolveRsIDs(ctx context.Context, db *bun.DB, rsIDs []string) (map[string]string, error) {
+	result := make(map[string]string)
+	for start := 0; start < len(rsIDs); start += rsIDChunkSize {
+		var rows []struct {
+			Alias   string `bun:"alias"`
+			Current string `bun:"current"`
+		}
+		err := db.NewSelect().
+			Model((*models.SNPAlias)(nil)).
+			ColumnExpr("sa.rsid AS alias, s.rsid AS current").
+			Join("JOIN snps AS s ON s.id = sa.snp_id").
+			Where("sa.rsid IN (?)", bun.In(rsIDs[start:min(start+rsIDChunkSize, len(rsIDs))])).
+			Scan(ctx, &rows)
+		if err != nil {
+			return nil, err
+		}
+		for _, row := range rows {
+			result[row.Alias] = row.Current
+		}
+	}
+	return result, nil
+}
+
+// RsIDResolver returns ResolveRsIDs bound to db, to follow merges in
+// models.CanonicalRsIDs.
+func RsIDResolver(db *bun.DB) models.RsIDResolver {
+	return func(ctx context.Context, rsIDs []string) (map[string]string, error) {
+		return ResolveRsIDs(ctx, db, rsIDs)
+	}
+}
+
+// rsNumber parses the numeric part of an rsID; malformed IDs sort last.
+func rsNumber(rsID string) int64 {
+	if n, ok := models.RsIDNumber(rsID); ok {
+		return n
+	}
+	return 1<<63 - 1
+}
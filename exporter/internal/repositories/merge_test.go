@@ -0,0 +1,85 @@
+package repositories
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mkoziy/genome/exporter/internal/models"
+)
+
+func TestMergeDuplicates(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+
+	snps := []*models.SNP{testSNP("rs200", "1", 100), testSNP("rs100", "1", 100), testSNP("rs300", "1", 300)}
+	if _, err := db.NewInsert().Model(&snps).Exec(ctx); err != nil {
+		t.Fatalf("insert snps: %v", err)
+	}
+	keep, drop := snps[1], snps[0]
+	clinical := []*models.ClinicalData{
+		{SNPID: keep.ID, ConditionName: "Shared"},
+		{SNPID: drop.ID, ConditionName: "Shared"},
+		{SNPID: drop.ID, ConditionName: "Only on duplicate"},
+	}
+	for _, c := range clinical {
+		c.ClinicalSignificance = models.ClinicalPathogenic
+		c.ReviewStatus = models.ReviewCriteriaProvided
+		c.Source = models.SourceClinVar
+	}
+	if _, err := db.NewInsert().Model(&clinical).Exec(ctx); err != nil {
+		t.Fatalf("insert clinical: %v", err)
+	}
+	if _, err := db.NewInsert().Model(&models.Significance{SNPID: drop.ID, TotalScore: 60}).Exec(ctx); err != nil {
+		t.Fatalf("insert significance: %v", err)
+	}
+
+	groups, err := FindDuplicateSNPs(ctx, db)
+	if err != nil {
+		t.Fatalf("find: %v", err)
+	}
+	if len(groups) != 1 || len(groups[0].SNPs) != 2 || groups[0].SNPs[0].RsID != "rs100" {
+		t.Fatalf("unexpected groups: %+v", groups)
+	}
+
+	result, err := MergeDuplicates(ctx, db)
+	if err != nil {
+		t.Fatalf("merge: %v", err)
+	}
+	if result.Groups != 1 || result.Merged != 1 {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+
+	merged, err := GetSNPByRsID(ctx, db, "rs100")
+	if err != nil {
+		t.Fatalf("get merged: %v", err)
+	}
+	if len(merged.ClinicalData) != 2 || merged.Significance == nil {
+		t.Fatalf("expected child rows moved without duplicates, got %d clinical, significance %v", len(merged.ClinicalData), merged.Significance)
+	}
+
+	current, err := ResolveRsID(ctx, db, "rs200")
+	if err != nil {
+		t.Fatalf("resolve: %v", err)
+	}
+	if current != "rs100" {
+		t.Fatalf("expected rs200 to resolve to rs100, got %s", current)
+	}
+	if unchanged, _ := ResolveRsID(ctx, db, "rs300"); unchanged != "rs300" {
+		t.Fatalf("expected rs300 unchanged, got %s", unchanged)
+	}
+	resolved, err := ResolveRsIDs(ctx, db, []string{"rs100", "rs200", "rs300"})
+	if err != nil {
+		t.Fatalf("resolve many: %v", err)
+	}
+	if len(resolved) != 1 || resolved["rs200"] != "rs100" {
+		t.Fatalf("expected only rs200 resolved, got %v", resolved)
+	}
+
+	again, err := FindDuplicateSNPs(ctx, db)
+	if err != nil {
+		t.Fatalf("find again: %v", err)
+	}
+	if len(again) != 0 {
+		t.Fatalf("expected no duplicates after merge, got %d", len(again))
+	}
+}
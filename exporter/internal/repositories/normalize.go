@@ -0,0 +1,38 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/uptrace/bun"
+)
+
+// NormalizeScores sets normalized_score for every scored SNP to the percentage
+// of clinically annotated SNPs (clinical_score > 0) scoring at or below its
+// total score. Anchoring the scale to annotated SNPs keeps the 70/40
+// significance thresholds stable when large numbers of variants without
+// clinical evidence are added. Call it after each scoring run. Without any
+// annotated SNP there is no scale, and normalized scores are left NULL, as
+// they are for retracted SNPs, which take no part in the scale.
+func NormalizeScores(ctx context.Context, db bun.IDB) error {
+	// Scores carry one decimal, so the step table has at most 1001 rows.
+	_, err := db.ExecContext(ctx, `
+		WITH steps AS (
+			SELECT total_score, MAX(pct) AS pct
+			FROM (
+				SELECT total_score, 100.0 * CUME_DIST() OVER (ORDER BY total_score ASC) AS pct
+				FROM snp_significance
+				WHERE clinical_score > 0
+					AND snp_id IN (SELECT id FROM snps WHERE deleted_at IS NULL)
+			)
+			GROUP BY total_score
+		)
+		UPDATE snp_significance
+		SET normalized_score = CASE WHEN EXISTS (SELECT 1 FROM steps)
+			AND snp_id IN (SELECT id FROM snps WHERE deleted_at IS NULL) THEN COALESCE((
+			SELECT steps.pct FROM steps
+			WHERE steps.total_score <= snp_significance.total_score
+			ORDER BY steps.total_score DESC
+			LIMIT 1
+		), 0) END`)
+	return err
+}
@@ -0,0 +1,82 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/mkoziy/genome/exporter/internal/models"
+)
+
+func TestNormalizeScores(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+
+	snps := make([]*models.SNP, 0)
+	for i := 1; i <= 6; i++ {
+		snps = append(snps, testSNP(fmt.Sprintf("rs%d", i), "1", int64(i)))
+	}
+	if err := UpsertSNPs(ctx, db, snps); err != nil {
+		t.Fatalf("upsert: %v", err)
+	}
+
+	normalized := func() map[string]*float64 {
+		t.Helper()
+		var sigs []*models.Significance
+		if err := db.NewSelect().Model(&sigs).Relation("SNP").Scan(ctx); err != nil {
+			t.Fatalf("select: %v", err)
+		}
+		result := make(map[string]*float64)
+		for _, sig := range sigs {
+			result[sig.SNP.RsID] = sig.NormalizedScore
+		}
+		return result
+	}
+
+	// rs5 and rs6 have no clinical evidence.
+	sigs := []*models.Significance{
+		{SNPID: snps[0].ID, TotalScore: 10},
+		{SNPID: snps[4].ID, TotalScore: 5},
+	}
+	if _, err := db.NewInsert().Model(&sigs).Exec(ctx); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+	if err := NormalizeScores(ctx, db); err != nil {
+		t.Fatalf("normalize: %v", err)
+	}
+	if got := normalized(); got["rs1"] != nil || got["rs5"] != nil {
+		t.Fatalf("expected no normalization without annotated SNPs, got %v", got)
+	}
+
+	sigs = []*models.Significance{
+		{SNPID: snps[1].ID, TotalScore: 20, ClinicalScore: 10},
+		{SNPID: snps[2].ID, TotalScore: 40, ClinicalScore: 30},
+		{SNPID: snps[3].ID, TotalScore: 80, ClinicalScore: 40},
+		{SNPID: snps[5].ID, TotalScore: 50},
+	}
+	if _, err := db.NewInsert().Model(&sigs).Exec(ctx); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+	if err := NormalizeScores(ctx, db); err != nil {
+		t.Fatalf("normalize: %v", err)
+	}
+
+	want := map[string]float64{"rs1": 0, "rs2": 100.0 / 3, "rs3": 200.0 / 3, "rs4": 100, "rs5": 0, "rs6": 200.0 / 3}
+	got := normalized()
+	for rsID, w := range want {
+		if got[rsID] == nil || *got[rsID] < w-0.001 || *got[rsID] > w+0.001 {
+			t.Fatalf("%s: expected normalized score %.2f, got %v", rsID, w, got[rsID])
+		}
+	}
+
+	// Adding unannotated low scorers does not move the scale.
+	if _, err := db.NewUpdate().Model((*models.Significance)(nil)).Set("total_score = 1").Where("snp_id = ?", snps[5].ID).Exec(ctx); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if err := NormalizeScores(ctx, db); err != nil {
+		t.Fatalf("normalize: %v", err)
+	}
+	if got := normalized(); *got["rs3"] < 66.6 || *got["rs3"] > 66.7 {
+		t.Fatalf("expected rs3 to stay at the 67th percentile, got %v", *got["rs3"])
+	}
+}
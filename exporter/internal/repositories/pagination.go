@@ -0,0 +1,99 @@
+package repositories
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/mkoziy/genome/exporter/internal/models"
+)
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 1000
+)
+
+// Page selects a window of an offset-paginated result.
+type Page struct {
+	Limit  int
+	Offset int
+}
+
+// normalize applies the default page size and clamps out-of-range values.
+func (p Page) normalize() Page {
+	if p.Limit <= 0 {
+		p.Limit = defaultPageSize
+	}
+	if p.Limit > maxPageSize {
+		p.Limit = maxPageSize
+	}
+	if p.Offset < 0 {
+		p.Offset = 0
+	}
+	return p
+}
+
+// SNPPage is one page of SNPs together with the total number of matches.
+type SNPPage struct {
+	Total int           `json:"total"`
+	SNPs  []*models.SNP `json:"snps"`
+}
+
+// Cursor marks where the next keyset page starts. AfterScore is only used by
+// score-ordered listings.
+type Cursor struct {
+	AfterID    int64    `json:"after_id"`
+	AfterScore *float64 `json:"after_score,omitempty"`
+}
+
+// String encodes the cursor as an opaque token for API clients.
+func (c Cursor) String() string {
+	raw := strconv.FormatInt(c.AfterID, 10)
+	if c.AfterScore != nil {
+		raw = strconv.FormatFloat(*c.AfterScore, 'g', -1, 64) + ":" + raw
+	}
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// ParseCursor decodes a token produced by Cursor.String. An empty token is the start cursor.
+func ParseCursor(token string) (Cursor, error) {
+	if token == "" {
+		return Cursor{}, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return Cursor{}, fmt.Errorf("invalid cursor: %w", err)
+	}
+
+	var c Cursor
+	idPart := string(raw)
+	if scorePart, rest, ok := strings.Cut(idPart, ":"); ok {
+		score, err := strconv.ParseFloat(scorePart, 64)
+		if err != nil {
+			return Cursor{}, fmt.Errorf("invalid cursor score: %w", err)
+		}
+		c.AfterScore = &score
+		idPart = rest
+	}
+	if c.AfterID, err = strconv.ParseInt(idPart, 10, 64); err != nil {
+		return Cursor{}, fmt.Errorf("invalid cursor id: %w", err)
+	}
+	return c, nil
+}
+
+// KeysetPage selects the page following After.
+type KeysetPage struct {
+	Limit int
+	After Cursor
+}
+
+func (p KeysetPage) limit() int {
+	return Page{Limit: p.Limit}.normalize().Limit
+}
+
+// SNPCursorPage is one keyset page of SNPs. Next is nil on the last page.
+type SNPCursorPage struct {
+	SNPs []*models.SNP `json:"snps"`
+	Next *Cursor       `json:"next,omitempty"`
+}
@@ -0,0 +1,108 @@
+package repositories
+
+import (
+	"context"
+	"strings"
+
+	"github.com/uptrace/bun"
+
+	"github.com/mkoziy/genome/exporter/internal/models"
+)
+
+// DrugResponse is the pharmacogenomic evidence linking one SNP to a drug.
+// Clinical holds ClinVar drug_response assertions naming the drug; Annotations
+// holds PharmGKB phenotype rows naming it.
+type DrugResponse struct {
+	SNP         *models.SNP            `json:"snp"`
+	Clinical    []*models.ClinicalData `json:"clinical"`
+	Annotations []*models.Phenotype    `json:"annotations"`
+}
+
+// GetDrugResponseSNPs returns SNPs affecting the response to drugName, matched
+// case-insensitively against condition and phenotype names (ClinVar records
+// e.g. "clopidogrel response - Efficacy"). If rsIDs is non-empty only those SNPs
+// are considered, answering "which of these rsIDs affect clopidogrel response".
+// Results are in rsID order.
+//
+// PharmGKB and CPIC data is read from snp_phenotypes rows with source pharmgkb
+// until dedicated tables exist.
+func GetDrugResponseSNPs(ctx context.Context, db *bun.DB, drugName string, rsIDs []string) ([]*DrugResponse, error) {
+	drugName = strings.TrimSpace(drugName)
+	if drugName == "" {
+		return nil, nil
+	}
+	pattern := "%" + escapeLike(drugName) + "%"
+
+	restrict := func(q *bun.SelectQuery, snpIDColumn string) *bun.SelectQuery {
+		if len(rsIDs) == 0 {
+			return q
+		}
+		return q.Where(snpIDColumn+" IN (SELECT id FROM snps WHERE rsid IN (?))", bun.In(rsIDs))
+	}
+
+	var clinical []*models.ClinicalData
+	err := db.NewSelect().
+		Model(&clinical).
+		Where("c.clinical_significance = ?", models.ClinicalDrugResponse).
+		Where("c.condition_name LIKE ? ESCAPE '\\'", pattern).
+		Apply(func(q *bun.SelectQuery) *bun.SelectQuery { return restrict(q, "c.snp_id") }).
+		OrderExpr("c.id ASC").
+		Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var annotations []*models.Phenotype
+	err = db.NewSelect().
+		Model(&annotations).
+		Where("p.source = ?", models.SourcePharmGKB).
+		Where("p.phenotype_name LIKE ? ESCAPE '\\'", pattern).
+		Apply(func(q *bun.SelectQuery) *bun.SelectQuery { return restrict(q, "p.snp_id") }).
+		OrderExpr("p.id ASC").
+		Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	bySNP := make(map[int64]*DrugResponse)
+	snpIDs := make([]int64, 0)
+	entry := func(snpID int64) *DrugResponse {
+		if r, ok := bySNP[snpID]; ok {
+			return r
+		}
+		r := &DrugResponse{Clinical: make([]*models.ClinicalData, 0), Annotations: make([]*models.Phenotype, 0)}
+		bySNP[snpID] = r
+		snpIDs = append(snpIDs, snpID)
+		return r
+	}
+	for _, c := range clinical {
+		r := entry(c.SNPID)
+		r.Clinical = append(r.Clinical, c)
+	}
+	for _, p := range annotations {
+		r := entry(p.SNPID)
+		r.Annotations = append(r.Annotations, p)
+	}
+	if len(snpIDs) == 0 {
+		return []*DrugResponse{}, nil
+	}
+
+	var snps []*models.SNP
+	err = db.NewSelect().
+		Model(&snps).
+		Relation("Significance").
+		Where("s.id IN (?)", bun.In(snpIDs)).
+		OrderExpr("s.rsid ASC").
+		Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*DrugResponse, 0, len(snps))
+	for _, snp := range snps {
+		r := bySNP[snp.ID]
+		r.SNP = snp
+		result = append(result, r)
+	}
+	return result, nil
+}
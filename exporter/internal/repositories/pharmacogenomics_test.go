@@ -0,0 +1,49 @@
+package repositories
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mkoziy/genome/exporter/internal/models"
+)
+
+func TestGetDrugResponseSNPs(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+
+	snps := []*models.SNP{testSNP("rs4244285", "10", 1), testSNP("rs12248560", "10", 2), testSNP("rs1", "1", 3)}
+	if _, err := db.NewInsert().Model(&snps).Exec(ctx); err != nil {
+		t.Fatalf("insert snps: %v", err)
+	}
+	clinical := []*models.ClinicalData{
+		{SNPID: snps[0].ID, ClinicalSignificance: models.ClinicalDrugResponse, ConditionName: "Clopidogrel response - Efficacy"},
+		{SNPID: snps[2].ID, ClinicalSignificance: models.ClinicalPathogenic, ConditionName: "clopidogrel resistance"},
+	}
+	for _, c := range clinical {
+		c.ReviewStatus = models.ReviewExpertPanel
+		c.Source = models.SourceClinVar
+	}
+	if _, err := db.NewInsert().Model(&clinical).Exec(ctx); err != nil {
+		t.Fatalf("insert clinical: %v", err)
+	}
+	annotation := &models.Phenotype{SNPID: snps[1].ID, PhenotypeName: "clopidogrel", AssociationType: "drug_response", Source: models.SourcePharmGKB}
+	if _, err := db.NewInsert().Model(annotation).Exec(ctx); err != nil {
+		t.Fatalf("insert phenotype: %v", err)
+	}
+
+	all, err := GetDrugResponseSNPs(ctx, db, "clopidogrel", nil)
+	if err != nil {
+		t.Fatalf("query: %v", err)
+	}
+	if len(all) != 2 || all[0].SNP.RsID != "rs12248560" || len(all[0].Annotations) != 1 || len(all[1].Clinical) != 1 {
+		t.Fatalf("unexpected result: %+v", all)
+	}
+
+	subset, err := GetDrugResponseSNPs(ctx, db, "Clopidogrel", []string{"rs4244285", "rs1"})
+	if err != nil {
+		t.Fatalf("query subset: %v", err)
+	}
+	if len(subset) != 1 || subset[0].SNP.RsID != "rs4244285" {
+		t.Fatalf("unexpected subset: %+v", subset)
+	}
+}
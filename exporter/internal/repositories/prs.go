@@ -0,0 +1,123 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/uptrace/bun"
+
+	"github.com/mkoziy/genome/exporter/internal/models"
+)
+
+// prsWeightChunkSize bounds the rows of one weight insert, below SQLite's
+// limit on bound parameters.
+const prsWeightChunkSize = 1000
+
+// SavePRSModel stores a model and its weights, replacing the weights of a
+// model already imported under the same PGS ID. Distributions are kept.
+func SavePRSModel(ctx context.Context, db *bun.DB, model *models.PRSModel, weights []*models.PRSWeight) error {
+	model.VariantCount = len(weights)
+	return db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		_, err := tx.NewInsert().
+			Model(model).
+			On("CONFLICT (pgs_id) DO UPDATE").
+			Set("name = EXCLUDED.name").
+			Set("trait_reported = EXCLUDED.trait_reported").
+			Set("trait_mapped = EXCLUDED.trait_mapped").
+			Set("genome_build = EXCLUDED.genome_build").
+			Set("weight_type = EXCLUDED.weight_type").
+			Set("citation = EXCLUDED.citation").
+			Set("variant_count = EXCLUDED.variant_count").
+			Returning("id").
+			Exec(ctx)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.NewDelete().Model((*models.PRSWeight)(nil)).Where("model_id = ?", model.ID).Exec(ctx); err != nil {
+			return err
+		}
+		for _, w := range weights {
+			w.ModelID = model.ID
+		}
+		for start := 0; start < len(weights); start += prsWeightChunkSize {
+			chunk := weights[start:min(start+prsWeightChunkSize, len(weights))]
+			if _, err := tx.NewInsert().Model(&chunk).Exec(ctx); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// GetPRSModel returns the model imported under pgsID with its weights and
+// distributions, or sql.ErrNoRows.
+func GetPRSModel(ctx context.Context, db *bun.DB, pgsID string) (*models.PRSModel, error) {
+	model := new(models.PRSModel)
+	err := db.NewSelect().
+		Model(model).
+		Relation("Weights").
+		Relation("Distributions").
+		Where("pm.pgs_id = ?", pgsID).
+		Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return model, nil
+}
+
+// ListPRSModels returns every model with its distributions but not its
+// weights, by PGS ID.
+func ListPRSModels(ctx context.Context, db *bun.DB) ([]*models.PRSModel, error) {
+	list := make([]*models.PRSModel, 0)
+	err := db.NewSelect().
+		Model(&list).
+		Relation("Distributions").
+		OrderExpr("pm.pgs_id ASC").
+		Scan(ctx)
+	return list, err
+}
+
+// SavePRSDistribution stores a reference distribution, replacing the one the
+// model has for the same population.
+func SavePRSDistribution(ctx context.Context, db *bun.DB, d *models.PRSDistribution) error {
+	_, err := db.NewInsert().
+		Model(d).
+		On("CONFLICT (model_id, population) DO UPDATE").
+		Set("mean = EXCLUDED.mean").
+		Set("sd = EXCLUDED.sd").
+		Set("method = EXCLUDED.method").
+		Set("sample_size = EXCLUDED.sample_size").
+		Exec(ctx)
+	return err
+}
+
+// GetAlleleFrequencies returns the frequencies of the alleles of the rsIDs
+// in population, by rsID and allele. rsIDs without frequencies there are
+// absent.
+func GetAlleleFrequencies(ctx context.Context, db *bun.DB, rsIDs []string, population string) (map[string]map[string]float64, error) {
+	result := make(map[string]map[string]float64)
+	for start := 0; start < len(rsIDs); start += rsIDChunkSize {
+		var rows []struct {
+			RsID      string  `bun:"rsid"`
+			Allele    string  `bun:"allele"`
+			Frequency float64 `bun:"frequency"`
+		}
+		err := db.NewSelect().
+			TableExpr("snp_populations AS pop").
+			Join("JOIN snps AS s ON s.id = pop.snp_id").
+			ColumnExpr("s.rsid, pop.allele, pop.frequency").
+			Where("pop.population_code = ?", population).
+			Where("pop.deleted_at IS NULL AND s.deleted_at IS NULL").
+			Where("s.rsid IN (?)", bun.In(rsIDs[start:min(start+rsIDChunkSize, len(rsIDs))])).
+			Scan(ctx, &rows)
+		if err != nil {
+			return nil, err
+		}
+		for _, row := range rows {
+			if result[row.RsID] == nil {
+				result[row.RsID] = make(map[string]float64)
+			}
+			result[row.RsID][row.Allele] = row.Frequency
+		}
+	}
+	return result, nil
+}
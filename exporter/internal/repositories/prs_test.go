@@ -0,0 +1,76 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/mkoziy/genome/exporter/internal/models"
+)
+
+func TestSavePRSModel(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+
+	model := &models.PRSModel{PGSID: "PGS000001", TraitReported: "Breast cancer"}
+	weights := []*models.PRSWeight{
+		{RsID: "rs1", EffectAllele: "T", Weight: 0.2},
+		{RsID: "rs2", EffectAllele: "A", Weight: -0.1},
+	}
+	if err := SavePRSModel(ctx, db, model, weights); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	d := &models.PRSDistribution{ModelID: model.ID, Population: "nfe", Mean: 0, SD: 1, Method: "published"}
+	if err := SavePRSDistribution(ctx, db, d); err != nil {
+		t.Fatalf("save distribution: %v", err)
+	}
+
+	again := &models.PRSModel{PGSID: "PGS000001", TraitReported: "Breast carcinoma"}
+	if err := SavePRSModel(ctx, db, again, weights[:1]); err != nil {
+		t.Fatalf("save again: %v", err)
+	}
+	if again.ID != model.ID {
+		t.Fatalf("expected the model to be updated in place, got id %d for %d", again.ID, model.ID)
+	}
+	got, err := GetPRSModel(ctx, db, "PGS000001")
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if got.TraitReported != "Breast carcinoma" || got.VariantCount != 1 || len(got.Weights) != 1 || len(got.Distributions) != 1 {
+		t.Fatalf("expected replaced weights and a kept distribution, got %+v", got)
+	}
+
+	if _, err := GetPRSModel(ctx, db, "PGS404"); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("expected sql.ErrNoRows, got %v", err)
+	}
+	list, err := ListPRSModels(ctx, db)
+	if err != nil || len(list) != 1 || len(list[0].Weights) != 0 {
+		t.Fatalf("expected one model without weights, got %+v (%v)", list, err)
+	}
+}
+
+func TestGetAlleleFrequencies(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+
+	snp := testSNP("rs1", "1", 100)
+	if err := UpsertSNPs(ctx, db, []*models.SNP{snp}); err != nil {
+		t.Fatalf("upsert: %v", err)
+	}
+	freqs := []*models.PopulationFreq{
+		{SNPID: snp.ID, PopulationCode: "nfe", Allele: "T", Frequency: 0.3, Source: models.SourceGnomAD},
+		{SNPID: snp.ID, PopulationCode: "afr", Allele: "T", Frequency: 0.6, Source: models.SourceGnomAD},
+	}
+	if err := UpsertPopulationFreqs(ctx, db, freqs); err != nil {
+		t.Fatalf("upsert frequencies: %v", err)
+	}
+
+	got, err := GetAlleleFrequencies(ctx, db, []string{"rs1", "rs2"}, "nfe")
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if len(got) != 1 || got["rs1"]["T"] != 0.3 {
+		t.Fatalf("unexpected frequencies: %v", got)
+	}
+}
@@ -0,0 +1,117 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/uptrace/bun"
+
+	"github.com/mkoziy/genome/exporter/internal/models"
+)
+
+// DeleteResult reports how many rows DeleteBySource removed per table.
+type DeleteResult struct {
+	Clinical           int64 `json:"clinical"`
+	Phenotypes         int64 `json:"phenotypes"`
+	Populations        int64 `json:"populations"`
+	References         int64 `json:"references"`
+	RiskAlleles        int64 `json:"risk_alleles"`
+	GenotypeEffects    int64 `json:"genotype_effects"`
+	ClinicalAgreements int64 `json:"clinical_agreements"`
+	SNPs               int64 `json:"snps"`
+}
+
+// DeleteBySource removes every clinical, phenotype, population, reference, risk allele,
+// genotype effect and clinical agreement row contributed by source, retracted or not,
+// then deletes the SNPs that no longer have any annotations left (along with their
+// scores, score history, quality, frequencies, translations, aliases and retractions).
+// It runs in one transaction so a bad import can be backed out atomically.
+func DeleteBySource(ctx context.Context, db *bun.DB, source models.DataSource) (*DeleteResult, error) {
+	result := &DeleteResult{}
+
+	err := db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		// Remember which SNPs this source touched; only those can become orphans.
+		var touched []int64
+		err := tx.NewRaw(`
+			SELECT snp_id FROM snp_clinical WHERE source = ?0
+			UNION SELECT snp_id FROM snp_phenotypes WHERE source = ?0
+			UNION SELECT snp_id FROM snp_populations WHERE source = ?0
+			UNION SELECT snp_id FROM snp_references WHERE source = ?0
+			UNION SELECT snp_id FROM risk_alleles WHERE source = ?0
+			UNION SELECT snp_id FROM genotype_effects WHERE source = ?0
+			UNION SELECT snp_id FROM clinical_agreements WHERE source = ?0`, source).
+			Scan(ctx, &touched)
+		if err != nil {
+			return fmt.Errorf("collect touched snps: %w", err)
+		}
+
+		deletes := []struct {
+			model interface{}
+			count *int64
+		}{
+			{(*models.ClinicalData)(nil), &result.Clinical},
+			{(*models.Phenotype)(nil), &result.Phenotypes},
+			{(*models.PopulationFreq)(nil), &result.Populations},
+			{(*models.Reference)(nil), &result.References},
+			{(*models.RiskAllele)(nil), &result.RiskAlleles},
+			{(*models.GenotypeEffect)(nil), &result.GenotypeEffects},
+			{(*models.ClinicalAgreement)(nil), &result.ClinicalAgreements},
+		}
+		for _, d := range deletes {
+			res, err := tx.NewDelete().Model(d.model).Where("source = ?", source).ForceDelete().Exec(ctx)
+			if err != nil {
+				return err
+			}
+			if *d.count, err = res.RowsAffected(); err != nil {
+				return err
+			}
+		}
+
+		if _, err := tx.ExecContext(ctx, `DELETE FROM phenotype_translations
+			WHERE NOT EXISTS (SELECT 1 FROM snp_phenotypes AS p WHERE p.id = phenotype_translations.phenotype_id)`); err != nil {
+			return err
+		}
+
+		if len(touched) == 0 {
+			return nil
+		}
+
+		var orphans []int64
+		err = tx.NewSelect().
+			Model((*models.SNP)(nil)).
+			Column("s.id").
+			Where("s.id IN (?)", bun.In(touched)).
+			Where("NOT EXISTS (SELECT 1 FROM snp_clinical AS c WHERE c.snp_id = s.id)").
+			Where("NOT EXISTS (SELECT 1 FROM snp_phenotypes AS p WHERE p.snp_id = s.id)").
+			Where("NOT EXISTS (SELECT 1 FROM snp_populations AS pop WHERE pop.snp_id = s.id)").
+			Where("NOT EXISTS (SELECT 1 FROM snp_references AS r WHERE r.snp_id = s.id)").
+			Where("NOT EXISTS (SELECT 1 FROM risk_alleles AS ra WHERE ra.snp_id = s.id)").
+			Where("NOT EXISTS (SELECT 1 FROM genotype_effects AS ge WHERE ge.snp_id = s.id)").
+			Where("NOT EXISTS (SELECT 1 FROM clinical_agreements AS ca WHERE ca.snp_id = s.id)").
+			Scan(ctx, &orphans)
+		if err != nil {
+			return fmt.Errorf("find orphaned snps: %w", err)
+		}
+		if len(orphans) == 0 {
+			return nil
+		}
+
+		for _, model := range []interface{}{(*models.Significance)(nil), (*models.SignificanceHistory)(nil), (*models.Quality)(nil), (*models.Frequency)(nil), (*models.HardyWeinberg)(nil), (*models.Translation)(nil), (*models.SNPAlias)(nil), (*models.Retraction)(nil)} {
+			if _, err := tx.NewDelete().Model(model).Where("snp_id IN (?)", bun.In(orphans)).Exec(ctx); err != nil {
+				return err
+			}
+		}
+
+		res, err := tx.NewDelete().Model((*models.SNP)(nil)).Where("id IN (?)", bun.In(orphans)).ForceDelete().Exec(ctx)
+		if err != nil {
+			return err
+		}
+		result.SNPs, err = res.RowsAffected()
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
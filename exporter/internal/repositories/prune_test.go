@@ -0,0 +1,60 @@
+package repositories
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mkoziy/genome/exporter/internal/models"
+)
+
+func TestDeleteBySource(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+
+	shared, onlyBad := testSNP("rs1", "1", 1), testSNP("rs2", "1", 2)
+	if err := UpsertSNPs(ctx, db, []*models.SNP{shared, onlyBad}); err != nil {
+		t.Fatalf("upsert: %v", err)
+	}
+
+	clinical := []*models.ClinicalData{
+		{SNPID: shared.ID, ClinicalSignificance: models.ClinicalPathogenic, ReviewStatus: models.ReviewExpertPanel, ConditionName: "X", Source: models.SourceClinVar},
+	}
+	if err := UpsertClinicalData(ctx, db, clinical); err != nil {
+		t.Fatalf("clinical: %v", err)
+	}
+	pops := []*models.PopulationFreq{
+		{SNPID: shared.ID, PopulationCode: "EUR", Allele: "T", Frequency: 0.1, Source: models.SourceOpenSNP},
+		{SNPID: onlyBad.ID, PopulationCode: "EUR", Allele: "T", Frequency: 0.2, Source: models.SourceOpenSNP},
+	}
+	if err := UpsertPopulationFreqs(ctx, db, pops); err != nil {
+		t.Fatalf("populations: %v", err)
+	}
+	if _, err := db.NewInsert().Model(&models.Significance{SNPID: onlyBad.ID, TotalScore: 5}).Exec(ctx); err != nil {
+		t.Fatalf("significance: %v", err)
+	}
+
+	res, err := DeleteBySource(ctx, db, models.SourceOpenSNP)
+	if err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if res.Populations != 2 || res.SNPs != 1 || res.Clinical != 0 {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+
+	if _, err := GetSNPByRsID(ctx, db, "rs2"); err == nil {
+		t.Fatalf("expected orphaned rs2 to be deleted")
+	}
+	if n, _ := db.NewSelect().Model((*models.SNP)(nil)).WhereAllWithDeleted().Count(ctx); n != 1 {
+		t.Fatalf("expected rs2 deleted rather than soft deleted, %d snps left", n)
+	}
+	kept, err := GetSNPByRsID(ctx, db, "rs1")
+	if err != nil {
+		t.Fatalf("expected rs1 to survive: %v", err)
+	}
+	if len(kept.PopulationData) != 0 || len(kept.ClinicalData) != 1 {
+		t.Fatalf("unexpected remaining data for rs1: %+v", kept)
+	}
+	if n, _ := db.NewSelect().Model((*models.Significance)(nil)).Count(ctx); n != 0 {
+		t.Fatalf("expected orphan score removed, %d left", n)
+	}
+}
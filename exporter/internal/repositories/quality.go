@@ -0,0 +1,31 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/uptrace/bun"
+
+	"github.com/mkoziy/genome/exporter/internal/models"
+)
+
+// SaveQuality inserts quality scores, replacing those of SNPs already
+// assessed.
+func SaveQuality(ctx context.Context, db bun.IDB, rows []*models.Quality) error {
+	if len(rows) == 0 {
+		return nil
+	}
+	_, err := db.NewInsert().
+		Model(&rows).
+		On("CONFLICT (snp_id) DO UPDATE").
+		Set("score = EXCLUDED.score").
+		Set("field_score = EXCLUDED.field_score").
+		Set("source_score = EXCLUDED.source_score").
+		Set("review_score = EXCLUDED.review_score").
+		Set("frequency_score = EXCLUDED.frequency_score").
+		Set("missing_fields = EXCLUDED.missing_fields").
+		Set("source_count = EXCLUDED.source_count").
+		Set("population_count = EXCLUDED.population_count").
+		Set("calculated_at = CURRENT_TIMESTAMP").
+		Exec(ctx)
+	return err
+}
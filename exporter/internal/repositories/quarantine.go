@@ -0,0 +1,53 @@
+package repositories
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/uptrace/bun"
+
+	"github.com/mkoziy/genome/exporter/internal/models"
+)
+
+// quarantineRecord describes data, which failed validation with err, for the
+// quarantine table.
+func quarantineRecord(data models.SNPData, runID *string, err error) (*models.QuarantinedRecord, error) {
+	var payload any = data.Raw
+	if payload == nil {
+		payload = data
+	}
+	encoded, jerr := json.Marshal(payload)
+	if jerr != nil {
+		return nil, fmt.Errorf("encode quarantined payload: %w", jerr)
+	}
+	rec := &models.QuarantinedRecord{Source: string(data.Source), RunID: runID, Error: err.Error(), Payload: string(encoded)}
+	if data.SNP != nil {
+		rec.RecordID = data.SNP.RsID
+	}
+	return rec, nil
+}
+
+// QuarantineRecords stores records that failed validation.
+func QuarantineRecords(ctx context.Context, db bun.IDB, records []*models.QuarantinedRecord) error {
+	if len(records) == 0 {
+		return nil
+	}
+	_, err := db.NewInsert().Model(&records).Exec(ctx)
+	return err
+}
+
+// ListQuarantined returns the quarantined records of source, newest first; an
+// empty source lists every source. limit <= 0 returns all of them.
+func ListQuarantined(ctx context.Context, db bun.IDB, source string, limit int) ([]*models.QuarantinedRecord, error) {
+	records := make([]*models.QuarantinedRecord, 0)
+	q := db.NewSelect().Model(&records).OrderExpr("qr.id DESC")
+	if source != "" {
+		q = q.Where("qr.source = ?", source)
+	}
+	if limit > 0 {
+		q = q.Limit(limit)
+	}
+	err := q.Scan(ctx)
+	return records, err
+}
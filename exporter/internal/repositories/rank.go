@@ -0,0 +1,99 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/uptrace/bun"
+
+	"github.com/mkoziy/genome/exporter/internal/models"
+)
+
+// ScoreRank places one SNP's score among all scored SNPs. Rank 1 is the highest
+// score; Percentile is the share of scored SNPs scoring at or below it (0-100).
+// Rank and Percentile are nil until RefreshScoreRanks has run after scoring.
+type ScoreRank struct {
+	RsID       string   `json:"rsid"`
+	TotalScore float64  `json:"total_score"`
+	Rank       *int     `json:"rank,omitempty"`
+	Percentile *float64 `json:"percentile,omitempty"`
+	Scored     int      `json:"scored"`
+}
+
+// RefreshScoreRanks recomputes score_rank and percentile for every scored SNP.
+// Call it after each scoring run; ties share a rank. Retracted SNPs are left
+// unranked and do not count towards the others' ranks.
+func RefreshScoreRanks(ctx context.Context, db bun.IDB) error {
+	_, err := db.ExecContext(ctx, `
+		UPDATE snp_significance
+		SET score_rank = CASE WHEN r.live THEN r.score_rank END,
+			percentile = CASE WHEN r.live THEN r.percentile END
+		FROM (
+			SELECT sig.id, s.deleted_at IS NULL AS live,
+				RANK() OVER (PARTITION BY s.deleted_at IS NULL ORDER BY sig.total_score DESC) AS score_rank,
+				100.0 * CUME_DIST() OVER (PARTITION BY s.deleted_at IS NULL ORDER BY sig.total_score ASC) AS percentile
+			FROM snp_significance AS sig
+			JOIN snps AS s ON s.id = sig.snp_id
+		) AS r
+		WHERE r.id = snp_significance.id`)
+	return err
+}
+
+// GetScoreRank returns the rank and percentile of rsID among the current
+// SNPs. It returns sql.ErrNoRows if the SNP does not exist, is retracted or has
+// not been scored.
+func GetScoreRank(ctx context.Context, db *bun.DB, rsID string) (*ScoreRank, error) {
+	rank := new(ScoreRank)
+	err := db.NewSelect().
+		TableExpr("snps AS s").
+		ColumnExpr("s.rsid AS rs_id").
+		ColumnExpr("sig.total_score, sig.score_rank AS rank, sig.percentile").
+		Join("JOIN snp_significance AS sig ON sig.snp_id = s.id").
+		Where("s.rsid = ?", rsID).
+		Where("s.deleted_at IS NULL").
+		Scan(ctx, rank)
+	if err != nil {
+		return nil, err
+	}
+
+	rank.Scored, err = db.NewSelect().Model((*models.Significance)(nil)).
+		Join("JOIN snps AS s ON s.id = sig.snp_id").
+		Where("s.deleted_at IS NULL").
+		Count(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return rank, nil
+}
+
+// GetTopSNPsByGene returns the n highest-ranked SNPs in a gene.
+func GetTopSNPsByGene(ctx context.Context, db *bun.DB, geneSymbol string, n int) ([]*models.SNP, error) {
+	return topRanked(ctx, db, n, func(q *bun.SelectQuery) *bun.SelectQuery {
+		return q.Where("s.gene_symbol = ?", geneSymbol)
+	})
+}
+
+// GetTopSNPsByCondition returns the n highest-ranked SNPs annotated with a
+// condition, matched on condition_id or exact condition name.
+func GetTopSNPsByCondition(ctx context.Context, db *bun.DB, condition string, n int) ([]*models.SNP, error) {
+	return topRanked(ctx, db, n, func(q *bun.SelectQuery) *bun.SelectQuery {
+		return q.Where("EXISTS (SELECT 1 FROM snp_clinical AS c WHERE c.snp_id = s.id AND c.deleted_at IS NULL AND (c.condition_id = ?0 OR c.condition_name = ?0))", condition)
+	})
+}
+
+func topRanked(ctx context.Context, db *bun.DB, n int, where func(*bun.SelectQuery) *bun.SelectQuery) ([]*models.SNP, error) {
+	n = Page{Limit: n}.normalize().Limit
+
+	var snps []*models.SNP
+	err := db.NewSelect().
+		Model(&snps).
+		Relation("Significance").
+		Relation("ClinicalData").
+		Join("JOIN snp_significance AS sr ON sr.snp_id = s.id").
+		Where("sr.score_rank IS NOT NULL").
+		Apply(where).
+		OrderExpr("sr.score_rank ASC, s.rsid ASC").
+		Limit(n).
+		Scan(ctx)
+
+	return snps, err
+}
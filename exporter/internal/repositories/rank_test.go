@@ -0,0 +1,69 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/mkoziy/genome/exporter/internal/models"
+)
+
+func TestScoreRanks(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+
+	gene := "MTHFR"
+	snps := []*models.SNP{testSNP("rs1", "1", 1), testSNP("rs2", "1", 2), testSNP("rs3", "1", 3), testSNP("rs4", "1", 4)}
+	for _, snp := range snps[:3] {
+		snp.GeneSymbol = &gene
+	}
+	if _, err := db.NewInsert().Model(&snps).Exec(ctx); err != nil {
+		t.Fatalf("insert snps: %v", err)
+	}
+	sigs := []*models.Significance{
+		{SNPID: snps[0].ID, TotalScore: 20},
+		{SNPID: snps[1].ID, TotalScore: 80},
+		{SNPID: snps[2].ID, TotalScore: 50},
+		{SNPID: snps[3].ID, TotalScore: 95},
+	}
+	if _, err := db.NewInsert().Model(&sigs).Exec(ctx); err != nil {
+		t.Fatalf("insert significance: %v", err)
+	}
+	clinical := &models.ClinicalData{SNPID: snps[2].ID, ClinicalSignificance: models.ClinicalRiskFactor, ReviewStatus: models.ReviewCriteriaProvided, ConditionName: "Homocystinuria", Source: models.SourceClinVar}
+	if _, err := db.NewInsert().Model(clinical).Exec(ctx); err != nil {
+		t.Fatalf("insert clinical: %v", err)
+	}
+
+	if err := RefreshScoreRanks(ctx, db); err != nil {
+		t.Fatalf("refresh: %v", err)
+	}
+
+	rank, err := GetScoreRank(ctx, db, "rs2")
+	if err != nil {
+		t.Fatalf("rank: %v", err)
+	}
+	if rank.Rank == nil || *rank.Rank != 2 || rank.Percentile == nil || *rank.Percentile != 75 || rank.Scored != 4 {
+		t.Fatalf("unexpected rank: %+v", rank)
+	}
+
+	top, err := GetTopSNPsByGene(ctx, db, gene, 2)
+	if err != nil {
+		t.Fatalf("top by gene: %v", err)
+	}
+	if got := rsIDs(top); len(got) != 2 || got[0] != "rs2" || got[1] != "rs3" {
+		t.Fatalf("unexpected top by gene: %v", got)
+	}
+
+	byCondition, err := GetTopSNPsByCondition(ctx, db, "Homocystinuria", 10)
+	if err != nil {
+		t.Fatalf("top by condition: %v", err)
+	}
+	if got := rsIDs(byCondition); len(got) != 1 || got[0] != "rs3" {
+		t.Fatalf("unexpected top by condition: %v", got)
+	}
+
+	if _, err := GetScoreRank(ctx, db, "rs404"); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("expected sql.ErrNoRows, got %v", err)
+	}
+}
@@ -0,0 +1,51 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/uptrace/bun"
+
+	"github.com/mkoziy/genome/exporter/internal/models"
+	"github.com/mkoziy/genome/exporter/internal/variant"
+)
+
+// GetSNPsInRegion returns SNPs on chrom, in any of its spellings, with start <= position <= end, ordered by position,
+// along with the structural variants starting before start whose end position reaches it.
+// The lookup is served by idx_snps_chromosome_position; filter further narrows the result.
+func GetSNPsInRegion(ctx context.Context, db *bun.DB, chrom string, start, end int64, filter SignificanceFilter) ([]*models.SNP, error) {
+	return getSNPsInRegion(ctx, db, "chromosome", "position", chrom, start, end, filter)
+}
+
+// GetSNPsInGRCh37Region is GetSNPsInRegion on GRCh37, matching the SNPs
+// whose GRCh37 coordinates are known, ordered by their GRCh37 position.
+func GetSNPsInGRCh37Region(ctx context.Context, db *bun.DB, chrom string, start, end int64, filter SignificanceFilter) ([]*models.SNP, error) {
+	return getSNPsInRegion(ctx, db, "grch37_chromosome", "grch37_position", chrom, start, end, filter)
+}
+
+func getSNPsInRegion(ctx context.Context, db *bun.DB, chromColumn, posColumn, chrom string, start, end int64, filter SignificanceFilter) ([]*models.SNP, error) {
+	if start > end {
+		return nil, fmt.Errorf("invalid region %s:%d-%d: start after end", chrom, start, end)
+	}
+
+	chrom = variant.NormalizeChromosome(chrom)
+	var snps []*models.SNP
+	err := db.NewSelect().
+		Model(&snps).
+		Relation("Significance").
+		Relation("ClinicalData").
+		Where("s.? = ?", bun.Ident(chromColumn), chrom).
+		WhereGroup(" AND ", func(q *bun.SelectQuery) *bun.SelectQuery {
+			q = q.Where("s.? BETWEEN ? AND ?", bun.Ident(posColumn), start, end)
+			if posColumn == "position" {
+				// End positions are on GRCh38 only.
+				q = q.WhereOr("s.sv_type IS NOT NULL AND s.position < ? AND s.end_position >= ?", start, start)
+			}
+			return q
+		}).
+		Apply(filter.apply).
+		OrderExpr("s.? ASC, s.id ASC", bun.Ident(posColumn)).
+		Scan(ctx)
+
+	return snps, err
+}
@@ -0,0 +1,107 @@
+package repositories
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mkoziy/genome/exporter/internal/models"
+)
+
+func TestGetSNPsInRegion(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+
+	snps := []*models.SNP{
+		testSNP("rs1", "19", 100),
+		testSNP("rs2", "19", 200),
+		// Stored as "19" whatever the source wrote.
+		testSNP("rs3", "chr19", 300),
+		testSNP("rs4", "1", 200),
+	}
+	if _, err := db.NewInsert().Model(&snps).Exec(ctx); err != nil {
+		t.Fatalf("insert snps: %v", err)
+	}
+	sig := &models.Significance{SNPID: snps[1].ID, TotalScore: 75}
+	if _, err := db.NewInsert().Model(sig).Exec(ctx); err != nil {
+		t.Fatalf("insert significance: %v", err)
+	}
+	clinical := &models.ClinicalData{SNPID: snps[2].ID, ClinicalSignificance: models.ClinicalPathogenic, ReviewStatus: models.ReviewExpertPanel, ConditionName: "X", Source: models.SourceClinVar}
+	if _, err := db.NewInsert().Model(clinical).Exec(ctx); err != nil {
+		t.Fatalf("insert clinical: %v", err)
+	}
+
+	got, err := GetSNPsInRegion(ctx, db, "19", 150, 300, SignificanceFilter{})
+	if err != nil {
+		t.Fatalf("region: %v", err)
+	}
+	if len(got) != 2 || got[0].RsID != "rs2" || got[1].RsID != "rs3" {
+		t.Fatalf("unexpected region result: %v", rsIDs(got))
+	}
+	if got[0].Significance == nil || got[0].Significance.TotalScore != 75 {
+		t.Fatalf("expected significance preloaded")
+	}
+
+	if got, _ := GetSNPsInRegion(ctx, db, "chr19", 150, 300, SignificanceFilter{}); len(got) != 2 {
+		t.Fatalf("expected chr19 to name 19, got %v", rsIDs(got))
+	}
+
+	got, err = GetSNPsInRegion(ctx, db, "19", 0, 1000, SignificanceFilter{MinScore: 60})
+	if err != nil {
+		t.Fatalf("region: %v", err)
+	}
+	if len(got) != 1 || got[0].RsID != "rs2" {
+		t.Fatalf("unexpected min-score result: %v", rsIDs(got))
+	}
+
+	got, err = GetSNPsInRegion(ctx, db, "19", 0, 1000, SignificanceFilter{ClinicalSignificances: []models.ClinicalSignificance{models.ClinicalPathogenic}})
+	if err != nil {
+		t.Fatalf("region: %v", err)
+	}
+	if len(got) != 1 || got[0].RsID != "rs3" || len(got[0].ClinicalData) != 1 {
+		t.Fatalf("unexpected clinical result: %v", rsIDs(got))
+	}
+
+	if _, err := GetSNPsInRegion(ctx, db, "19", 10, 1, SignificanceFilter{}); err == nil {
+		t.Fatalf("expected error for inverted region")
+	}
+}
+
+func TestGetSNPsInRegionOverlapsStructuralVariants(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+
+	dup := testSNP("rs5", "19", 50)
+	end, length, svType := int64(250), int64(201), models.SVDuplication
+	dup.ReferenceAllele, dup.AlternateAlleles = "N", models.StringArray{svType.SymbolicAllele()}
+	dup.VariantType, dup.EndPosition, dup.SVLength, dup.SVType = models.VariantDuplication, &end, &length, &svType
+	// An indel's end position alone does not make it overlap.
+	indel := testSNP("rs6", "19", 120)
+	indelEnd := int64(160)
+	indel.EndPosition = &indelEnd
+	snps := []*models.SNP{dup, indel, testSNP("rs7", "19", 200)}
+	if _, err := db.NewInsert().Model(&snps).Exec(ctx); err != nil {
+		t.Fatalf("insert snps: %v", err)
+	}
+
+	got, err := GetSNPsInRegion(ctx, db, "19", 150, 300, SignificanceFilter{})
+	if err != nil {
+		t.Fatalf("region: %v", err)
+	}
+	if len(got) != 2 || got[0].RsID != "rs5" || got[1].RsID != "rs7" {
+		t.Fatalf("unexpected region result: %v", rsIDs(got))
+	}
+	if got[0].VariantKey != nil || got[0].HGVSGenomic == nil || *got[0].HGVSGenomic != "NC_000019.10:g.50_250dup" {
+		t.Errorf("unexpected key %v and description %v", got[0].VariantKey, got[0].HGVSGenomic)
+	}
+	if got, _ := GetSNPsInRegion(ctx, db, "19", 251, 300, SignificanceFilter{}); len(got) != 0 {
+		t.Errorf("expected nothing past the duplication's end, got %v", rsIDs(got))
+	}
+}
+
+func rsIDs(snps []*models.SNP) []string {
+	ids := make([]string, len(snps))
+	for i, s := range snps {
+		ids[i] = s.RsID
+	}
+	return ids
+}
@@ -0,0 +1,166 @@
+package repositories
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/uptrace/bun"
+
+	"github.com/mkoziy/genome/exporter/internal/models"
+)
+
+// releaseCountTables are the tables whose row counts are recorded per
+// release, and whether retractions soft delete their rows.
+var releaseCountTables = []struct {
+	name       string
+	softDelete bool
+}{
+	{"snps", true},
+	{"snp_significance", false},
+	{"snp_clinical", true},
+	{"snp_phenotypes", true},
+	{"snp_references", true},
+	{"snp_populations", true},
+	{"risk_alleles", true},
+	{"genotype_effects", true},
+	{"clinical_agreements", false},
+	{"snp_translations", false},
+}
+
+// CreateRelease records a new dataset release with current record counts and content checksum.
+// It is meant to be called as the last step of a pipeline run. Retracted rows,
+// and the rows of retracted SNPs, are not counted.
+func CreateRelease(ctx context.Context, db *bun.DB, version string, sourceVersions map[string]string) (*models.DatasetRelease, error) {
+	counts := make(models.CountMap, len(releaseCountTables))
+	for _, table := range releaseCountTables {
+		q := db.NewSelect().TableExpr(table.name + " AS t")
+		if table.softDelete {
+			q = q.Where("t.deleted_at IS NULL")
+		}
+		if table.name != "snps" {
+			q = q.Where("t.snp_id IN (SELECT id FROM snps WHERE deleted_at IS NULL)")
+		}
+		n, err := q.Count(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("count %s: %w", table.name, err)
+		}
+		counts[table.name] = n
+	}
+
+	checksum, err := ContentChecksum(ctx, db)
+	if err != nil {
+		return nil, fmt.Errorf("checksum: %w", err)
+	}
+
+	var algorithmVersion int
+	err = db.NewSelect().
+		Model((*models.Significance)(nil)).
+		ColumnExpr("COALESCE(MAX(sig.algorithm_version), 0)").
+		Scan(ctx, &algorithmVersion)
+	if err != nil {
+		return nil, fmt.Errorf("score algorithm version: %w", err)
+	}
+
+	release := &models.DatasetRelease{
+		Version:               version,
+		BuildDate:             time.Now().UTC(),
+		SourceVersions:        sourceVersions,
+		RecordCounts:          counts,
+		Checksum:              checksum,
+		ScoreAlgorithmVersion: algorithmVersion,
+	}
+	if err := release.Validate(); err != nil {
+		return nil, err
+	}
+
+	if _, err := db.NewInsert().Model(release).Exec(ctx); err != nil {
+		return nil, err
+	}
+	return release, nil
+}
+
+// GetCurrentRelease returns the most recently recorded dataset release.
+func GetCurrentRelease(ctx context.Context, db *bun.DB) (*models.DatasetRelease, error) {
+	release := new(models.DatasetRelease)
+	err := db.NewSelect().
+		Model(release).
+		OrderExpr("dr.build_date DESC, dr.id DESC").
+		Limit(1).
+		Scan(ctx)
+
+	return release, err
+}
+
+// FirstReleaseVersion is the version of a database's first release.
+const FirstReleaseVersion = "1.0.0"
+
+// NextReleaseVersion returns the version of the release to follow the current
+// one: its patch version incremented, dropping any build suffix, or for a
+// pre-release the version it precedes. Before any release it is
+// FirstReleaseVersion.
+func NextReleaseVersion(ctx context.Context, db *bun.DB) (string, error) {
+	current, err := GetCurrentRelease(ctx, db)
+	if errors.Is(err, sql.ErrNoRows) {
+		return FirstReleaseVersion, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	core, _, _ := strings.Cut(current.Version, "+")
+	core, pre, _ := strings.Cut(core, "-")
+	parts := strings.Split(core, ".")
+	if len(parts) != 3 {
+		return "", fmt.Errorf("current release version %q is not semantic", current.Version)
+	}
+	patch, err := strconv.Atoi(parts[2])
+	if err != nil {
+		return "", fmt.Errorf("current release version %q is not semantic", current.Version)
+	}
+	if pre != "" {
+		return core, nil
+	}
+	return fmt.Sprintf("%s.%s.%d", parts[0], parts[1], patch+1), nil
+}
+
+// ContentChecksum returns a SHA-256 over the SNP coordinates and scores in rsID order.
+// Unlike a file hash it is stable across VACUUM and page layout changes.
+func ContentChecksum(ctx context.Context, db *bun.DB) (string, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT s.rsid, s.chromosome, s.position, s.reference_allele, s.alternate_alleles,
+			COALESCE(sig.total_score, -1)
+		FROM snps AS s
+		LEFT JOIN snp_significance AS sig ON sig.snp_id = s.id
+		WHERE s.deleted_at IS NULL
+		ORDER BY s.rsid`)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	h := sha256.New()
+	for rows.Next() {
+		var (
+			rsID, chrom, ref, alts string
+			pos                    int64
+			score                  float64
+		)
+		if err := rows.Scan(&rsID, &chrom, &pos, &ref, &alts, &score); err != nil {
+			return "", err
+		}
+		_, _ = fmt.Fprintf(h, "%s\t%s\t%d\t%s\t%s\t%.4f\n", rsID, chrom, pos, ref, alts, score)
+	}
+	if err := rows.Err(); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
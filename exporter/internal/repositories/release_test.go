@@ -0,0 +1,110 @@
+package repositories
+
+import (
+	"context"
+	"testing"
+
+	"github.com/uptrace/bun"
+
+	"github.com/mkoziy/genome/exporter/internal/database"
+	"github.com/mkoziy/genome/exporter/internal/migrations"
+	"github.com/mkoziy/genome/exporter/internal/models"
+)
+
+func newTestDB(t *testing.T) *bun.DB {
+	t.Helper()
+	db, err := database.NewDB("file:"+t.Name()+"?mode=memory&cache=shared", false)
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	if err := migrations.RunMigrations(context.Background(), db); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	return db
+}
+
+func testSNP(rsID, chrom string, pos int64) *models.SNP {
+	return &models.SNP{
+		RsID:             rsID,
+		Chromosome:       chrom,
+		Position:         pos,
+		ReferenceAllele:  "C",
+		AlternateAlleles: models.StringArray{"T"},
+		VariantType:      models.VariantSNV,
+	}
+}
+
+func TestCreateAndGetCurrentRelease(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+
+	if err := UpsertSNPs(ctx, db, []*models.SNP{testSNP("rs1", "1", 100), testSNP("rs2", "2", 200)}); err != nil {
+		t.Fatalf("upsert: %v", err)
+	}
+
+	if _, err := CreateRelease(ctx, db, "not-semver", nil); err == nil {
+		t.Fatalf("expected error for invalid version")
+	}
+
+	first, err := CreateRelease(ctx, db, "1.0.0", map[string]string{"clinvar": "2024-03"})
+	if err != nil {
+		t.Fatalf("create release: %v", err)
+	}
+	if first.RecordCounts["snps"] != 2 {
+		t.Fatalf("expected 2 snps recorded, got %v", first.RecordCounts)
+	}
+
+	if err := UpsertSNPs(ctx, db, []*models.SNP{testSNP("rs3", "3", 300)}); err != nil {
+		t.Fatalf("upsert: %v", err)
+	}
+	second, err := CreateRelease(ctx, db, "1.1.0", map[string]string{"clinvar": "2024-04"})
+	if err != nil {
+		t.Fatalf("create release: %v", err)
+	}
+	if second.Checksum == first.Checksum {
+		t.Fatalf("expected checksum to change when content changes")
+	}
+
+	current, err := GetCurrentRelease(ctx, db)
+	if err != nil {
+		t.Fatalf("get current release: %v", err)
+	}
+	if current.Version != "1.1.0" || current.SourceVersions["clinvar"] != "2024-04" {
+		t.Fatalf("unexpected current release: %+v", current)
+	}
+
+	if _, _, err := RetractSNPs(ctx, db, []string{"rs3"}, models.Retraction{Reason: "withdrawn"}); err != nil {
+		t.Fatalf("retract: %v", err)
+	}
+	third, err := CreateRelease(ctx, db, "1.1.1", nil)
+	if err != nil {
+		t.Fatalf("create release: %v", err)
+	}
+	if third.RecordCounts["snps"] != 2 || third.Checksum != first.Checksum {
+		t.Fatalf("expected the retracted rs3 left out, got %v, checksum changed %t", third.RecordCounts, third.Checksum != first.Checksum)
+	}
+}
+
+func TestNextReleaseVersion(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+
+	if got, err := NextReleaseVersion(ctx, db); err != nil || got != FirstReleaseVersion {
+		t.Fatalf("expected %s before any release, got %q, %v", FirstReleaseVersion, got, err)
+	}
+	for _, tc := range []struct{ version, next string }{
+		{"1.4.9", "1.4.10"},
+		{"2.0.0-rc.1", "2.0.0"},
+		{"2.0.0", "2.0.1"},
+		{"2.0.1+build.5", "2.0.2"},
+		{"2.1.0-rc.2+build.6", "2.1.0"},
+	} {
+		if _, err := CreateRelease(ctx, db, tc.version, nil); err != nil {
+			t.Fatalf("create release %s: %v", tc.version, err)
+		}
+		if got, err := NextReleaseVersion(ctx, db); err != nil || got != tc.next {
+			t.Errorf("after %s: expected %s, got %q, %v", tc.version, tc.next, got, err)
+		}
+	}
+}
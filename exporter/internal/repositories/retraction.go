@@ -0,0 +1,190 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/uptrace/bun"
+
+	"github.com/mkoziy/genome/exporter/internal/models"
+)
+
+// retractedModels are the SNP annotations retractions soft delete.
+var retractedModels = []interface{}{
+	(*models.ClinicalData)(nil),
+	(*models.Phenotype)(nil),
+	(*models.Reference)(nil),
+	(*models.PopulationFreq)(nil),
+	(*models.RiskAllele)(nil),
+	(*models.GenotypeEffect)(nil),
+}
+
+// RetractSNPs soft deletes the SNPs with rsIDs and their annotations, or
+// with retraction.Source set only that source's annotations of them, and
+// records a retraction of each like retraction. It returns the retractions
+// recorded and the rsIDs of no current SNP, in one transaction.
+func RetractSNPs(ctx context.Context, db *bun.DB, rsIDs []string, retraction models.Retraction) ([]*models.Retraction, []string, error) {
+	if err := retraction.Validate(); err != nil {
+		return nil, nil, err
+	}
+
+	var (
+		recorded []*models.Retraction
+		missing  []string
+	)
+	err := db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		var snps []*models.SNP
+		if err := tx.NewSelect().Model(&snps).Column("s.id", "s.rsid").Where("s.rsid IN (?)", bun.In(rsIDs)).Scan(ctx); err != nil {
+			return fmt.Errorf("find snps: %w", err)
+		}
+		found := make(map[string]int64, len(snps))
+		for _, snp := range snps {
+			found[snp.RsID] = snp.ID
+		}
+
+		var ids []int64
+		for _, rsID := range rsIDs {
+			id, ok := found[rsID]
+			if !ok {
+				missing = append(missing, rsID)
+				continue
+			}
+			delete(found, rsID)
+			r := retraction
+			r.SNPID, r.RsID = id, rsID
+			recorded = append(recorded, &r)
+			ids = append(ids, id)
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+
+		// A retracted SNP takes its annotations with it, at the same time so
+		// restoring it brings back only those.
+		now := time.Now()
+		if retraction.Source == "" {
+			if _, err := tx.NewUpdate().Model((*models.SNP)(nil)).
+				Set("deleted_at = ?", now).
+				Where("id IN (?)", bun.In(ids)).
+				Exec(ctx); err != nil {
+				return fmt.Errorf("retract snps: %w", err)
+			}
+			if err := rerank(ctx, tx); err != nil {
+				return err
+			}
+		}
+		for _, model := range retractedModels {
+			q := tx.NewUpdate().Model(model).
+				Set("deleted_at = ?", now).
+				Where("snp_id IN (?)", bun.In(ids))
+			if retraction.Source != "" {
+				q = q.Where("source = ?", retraction.Source)
+			}
+			if _, err := q.Exec(ctx); err != nil {
+				return fmt.Errorf("retract annotations: %w", err)
+			}
+		}
+		_, err := tx.NewInsert().Model(&recorded).Exec(ctx)
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return recorded, missing, nil
+}
+
+// RestoreSNPs undoes the standing retractions of the SNPs with rsIDs, of
+// the SNPs themselves or with source set of that source's annotations of
+// them. It returns the rsIDs restored, in one transaction.
+func RestoreSNPs(ctx context.Context, db *bun.DB, rsIDs []string, source models.DataSource) ([]string, error) {
+	var restored []string
+	err := db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		var retractions []*models.Retraction
+		q := tx.NewSelect().Model(&retractions).
+			Where("rt.rsid IN (?)", bun.In(rsIDs)).
+			Where("rt.restored_at IS NULL")
+		if source == "" {
+			q = q.Where("rt.source IS NULL")
+		} else {
+			q = q.Where("rt.source = ?", source)
+		}
+		if err := q.OrderExpr("rt.id ASC").Scan(ctx); err != nil {
+			return fmt.Errorf("find retractions: %w", err)
+		}
+		if len(retractions) == 0 {
+			return nil
+		}
+
+		seen := make(map[int64]bool, len(retractions))
+		var ids, snpIDs []int64
+		for _, r := range retractions {
+			ids = append(ids, r.ID)
+			if !seen[r.SNPID] {
+				seen[r.SNPID] = true
+				snpIDs = append(snpIDs, r.SNPID)
+				restored = append(restored, r.RsID)
+			}
+		}
+
+		for _, model := range retractedModels {
+			q := tx.NewUpdate().Model(model).
+				Set("deleted_at = NULL").
+				Where("snp_id IN (?)", bun.In(snpIDs)).
+				WhereDeleted()
+			if source == "" {
+				// Annotations retracted on their own before stay retracted.
+				q = q.Where("deleted_at = (SELECT s.deleted_at FROM snps AS s WHERE s.id = snp_id)")
+			} else {
+				q = q.Where("source = ?", source)
+			}
+			if _, err := q.Exec(ctx); err != nil {
+				return fmt.Errorf("restore annotations: %w", err)
+			}
+		}
+		if source == "" {
+			if _, err := tx.NewUpdate().Model((*models.SNP)(nil)).
+				Set("deleted_at = NULL").
+				Where("id IN (?)", bun.In(snpIDs)).
+				WhereDeleted().
+				Exec(ctx); err != nil {
+				return fmt.Errorf("restore snps: %w", err)
+			}
+			if err := rerank(ctx, tx); err != nil {
+				return err
+			}
+		}
+		_, err := tx.NewUpdate().Model((*models.Retraction)(nil)).
+			Set("restored_at = CURRENT_TIMESTAMP").
+			Where("id IN (?)", bun.In(ids)).
+			Exec(ctx)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return restored, nil
+}
+
+// ListRetractions returns the standing retractions, or with all also those
+// undone, newest first.
+func ListRetractions(ctx context.Context, db bun.IDB, all bool) ([]*models.Retraction, error) {
+	retractions := make([]*models.Retraction, 0)
+	q := db.NewSelect().Model(&retractions).OrderExpr("rt.id DESC")
+	if !all {
+		q = q.Where("rt.restored_at IS NULL")
+	}
+	return retractions, q.Scan(ctx)
+}
+
+// rerank refreshes the score ranks and normalized scores, which retracting
+// or restoring a SNP shifts for all the others.
+func rerank(ctx context.Context, db bun.IDB) error {
+	if err := RefreshScoreRanks(ctx, db); err != nil {
+		return fmt.Errorf("refresh ranks: %w", err)
+	}
+	if err := NormalizeScores(ctx, db); err != nil {
+		return fmt.Errorf("normalize scores: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,165 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/mkoziy/genome/exporter/internal/models"
+)
+
+func TestRetractSNPs(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+
+	snps := []*models.SNP{testSNP("rs1", "1", 1), testSNP("rs2", "1", 2)}
+	if err := UpsertSNPs(ctx, db, snps); err != nil {
+		t.Fatalf("upsert: %v", err)
+	}
+	clinical := []*models.ClinicalData{
+		{SNPID: snps[0].ID, ClinicalSignificance: models.ClinicalBenign, ReviewStatus: models.ReviewExpertPanel, ConditionName: "Y", Source: models.SourceClinVar},
+		{SNPID: snps[1].ID, ClinicalSignificance: models.ClinicalPathogenic, ReviewStatus: models.ReviewExpertPanel, ConditionName: "X", Source: models.SourceClinVar},
+	}
+	if err := UpsertClinicalData(ctx, db, clinical); err != nil {
+		t.Fatalf("clinical: %v", err)
+	}
+	if _, err := db.NewInsert().Model(&models.Significance{SNPID: snps[1].ID, TotalScore: 50}).Exec(ctx); err != nil {
+		t.Fatalf("significance: %v", err)
+	}
+
+	recorded, missing, err := RetractSNPs(ctx, db, []string{"rs1", "rs9"}, models.Retraction{Reason: "withdrawn"})
+	if err != nil {
+		t.Fatalf("retract: %v", err)
+	}
+	if len(recorded) != 1 || recorded[0].RsID != "rs1" || len(missing) != 1 || missing[0] != "rs9" {
+		t.Fatalf("unexpected retraction: %+v, missing %v", recorded, missing)
+	}
+	if _, err := GetSNPByRsID(ctx, db, "rs1"); err == nil {
+		t.Fatalf("expected retracted rs1 to be hidden")
+	}
+	if n, _ := db.NewSelect().Model((*models.ClinicalData)(nil)).Count(ctx); n != 1 {
+		t.Fatalf("expected only the assertions of rs2 left, got %d", n)
+	}
+	if n, _ := db.NewSelect().Model((*models.SNP)(nil)).WhereAllWithDeleted().Count(ctx); n != 2 {
+		t.Fatalf("expected retracted rs1 kept, %d snps", n)
+	}
+
+	if _, _, err := RetractSNPs(ctx, db, []string{"rs2"}, models.Retraction{Reason: "withdrawn", Source: models.SourceClinVar}); err != nil {
+		t.Fatalf("retract source: %v", err)
+	}
+	snp, err := GetSNPByRsID(ctx, db, "rs2")
+	if err != nil {
+		t.Fatalf("expected rs2 to stay: %v", err)
+	}
+	if len(snp.ClinicalData) != 0 {
+		t.Fatalf("expected ClinVar assertions of rs2 hidden, got %d", len(snp.ClinicalData))
+	}
+	if snp.Significance == nil || !snp.Significance.Stale {
+		t.Fatalf("expected the score of rs2 marked stale: %+v", snp.Significance)
+	}
+
+	if _, _, err := RetractSNPs(ctx, db, []string{"rs1"}, models.Retraction{}); err == nil {
+		t.Fatalf("expected a retraction without reason to be refused")
+	}
+
+	restored, err := RestoreSNPs(ctx, db, []string{"rs1", "rs2"}, "")
+	if err != nil {
+		t.Fatalf("restore: %v", err)
+	}
+	if len(restored) != 1 || restored[0] != "rs1" {
+		t.Fatalf("expected only rs1 restored, got %v", restored)
+	}
+	if snp, err = GetSNPByRsID(ctx, db, "rs1"); err != nil || len(snp.ClinicalData) != 1 {
+		t.Fatalf("expected rs1 restored with its assertion: %v", err)
+	}
+	if _, err := RestoreSNPs(ctx, db, []string{"rs2"}, models.SourceClinVar); err != nil {
+		t.Fatalf("restore source: %v", err)
+	}
+	if snp, _ = GetSNPByRsID(ctx, db, "rs2"); len(snp.ClinicalData) != 1 {
+		t.Fatalf("expected ClinVar assertions of rs2 restored, got %d", len(snp.ClinicalData))
+	}
+
+	standing, err := ListRetractions(ctx, db, false)
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	all, _ := ListRetractions(ctx, db, true)
+	if len(standing) != 0 || len(all) != 2 {
+		t.Fatalf("expected 2 undone retractions, got %d standing of %d", len(standing), len(all))
+	}
+}
+
+func TestRetractedSNPsLeaveGeneSummaryAndRanks(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+
+	gene := "MTHFR"
+	snps := []*models.SNP{testSNP("rs1", "1", 1), testSNP("rs2", "1", 2), testSNP("rs3", "1", 3), testSNP("rs4", "1", 4)}
+	for _, snp := range snps[:3] {
+		snp.GeneSymbol = &gene
+	}
+	if _, err := db.NewInsert().Model(&snps).Exec(ctx); err != nil {
+		t.Fatalf("insert snps: %v", err)
+	}
+	sigs := []*models.Significance{
+		{SNPID: snps[0].ID, TotalScore: 20},
+		{SNPID: snps[1].ID, TotalScore: 80, ClinicalScore: 30},
+		{SNPID: snps[2].ID, TotalScore: 50, ClinicalScore: 20},
+		{SNPID: snps[3].ID, TotalScore: 95},
+	}
+	if _, err := db.NewInsert().Model(&sigs).Exec(ctx); err != nil {
+		t.Fatalf("insert significance: %v", err)
+	}
+	if err := RefreshScoreRanks(ctx, db); err != nil {
+		t.Fatalf("refresh: %v", err)
+	}
+	if err := NormalizeScores(ctx, db); err != nil {
+		t.Fatalf("normalize: %v", err)
+	}
+
+	if _, _, err := RetractSNPs(ctx, db, []string{"rs2"}, models.Retraction{Reason: "withdrawn"}); err != nil {
+		t.Fatalf("retract: %v", err)
+	}
+
+	summary, err := GetGeneSummary(ctx, db, gene)
+	if err != nil {
+		t.Fatalf("gene summary: %v", err)
+	}
+	if summary.VariantCount != 2 || summary.ScoredCount != 2 || summary.MaxScore == nil || *summary.MaxScore != 50 {
+		t.Fatalf("expected retracted rs2 left out of the summary: %+v", summary)
+	}
+
+	rank, err := GetScoreRank(ctx, db, "rs3")
+	if err != nil {
+		t.Fatalf("rank: %v", err)
+	}
+	if rank.Rank == nil || *rank.Rank != 2 || rank.Scored != 3 {
+		t.Fatalf("expected rs3 ranked second of three: %+v", rank)
+	}
+	if _, err := GetScoreRank(ctx, db, "rs2"); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("expected sql.ErrNoRows for retracted rs2, got %v", err)
+	}
+
+	var retracted models.Significance
+	if err := db.NewSelect().Model(&retracted).Where("snp_id = ?", snps[1].ID).Scan(ctx); err != nil {
+		t.Fatalf("select significance: %v", err)
+	}
+	if retracted.ScoreRank != nil || retracted.NormalizedScore != nil {
+		t.Fatalf("expected retracted rs2 unranked: %+v", retracted)
+	}
+	var normalized models.Significance
+	if err := db.NewSelect().Model(&normalized).Where("snp_id = ?", snps[2].ID).Scan(ctx); err != nil {
+		t.Fatalf("select significance: %v", err)
+	}
+	if normalized.NormalizedScore == nil || *normalized.NormalizedScore != 100 {
+		t.Fatalf("expected rs3 alone on the clinical scale: %+v", normalized.NormalizedScore)
+	}
+
+	if _, err := RestoreSNPs(ctx, db, []string{"rs2"}, ""); err != nil {
+		t.Fatalf("restore: %v", err)
+	}
+	if rank, err := GetScoreRank(ctx, db, "rs2"); err != nil || rank.Rank == nil || *rank.Rank != 2 || rank.Scored != 4 {
+		t.Fatalf("expected restored rs2 ranked second of four: %+v, %v", rank, err)
+	}
+}
@@ -0,0 +1,37 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/uptrace/bun"
+
+	"github.com/mkoziy/genome/exporter/internal/models"
+)
+
+// GetScoreHistory returns the scores a SNP held under earlier scoring algorithm
+// versions, oldest first. The current score is not included.
+func GetScoreHistory(ctx context.Context, db *bun.DB, snpID int64) ([]*models.SignificanceHistory, error) {
+	history := make([]*models.SignificanceHistory, 0)
+	err := db.NewSelect().
+		Model(&history).
+		Where("sh.snp_id = ?", snpID).
+		OrderExpr("sh.archived_at ASC, sh.id ASC").
+		Scan(ctx)
+	return history, err
+}
+
+// MarkOutdatedScores flags every score calculated by an algorithm version other
+// than version as stale, so the next incremental scoring pass recalculates it.
+// It returns the number of scores marked.
+func MarkOutdatedScores(ctx context.Context, db bun.IDB, version int) (int64, error) {
+	res, err := db.NewUpdate().
+		Model((*models.Significance)(nil)).
+		Set("stale = 1").
+		Where("algorithm_version <> ?", version).
+		Where("NOT stale").
+		Exec(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,70 @@
+package repositories
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mkoziy/genome/exporter/internal/models"
+)
+
+func TestScoreHistory(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+	repos := NewBunRepositories(db)
+
+	snps := []*models.SNP{testSNP("rs1", "1", 100), testSNP("rs2", "1", 200)}
+	if err := UpsertSNPs(ctx, db, snps); err != nil {
+		t.Fatalf("upsert: %v", err)
+	}
+	save := func(snpID int64, score float64, version int) {
+		t.Helper()
+		sig := &models.Significance{SNPID: snpID, TotalScore: score, AlgorithmVersion: version}
+		if err := repos.Significance.Save(ctx, sig); err != nil {
+			t.Fatalf("save: %v", err)
+		}
+	}
+	save(snps[0].ID, 40, 1)
+	save(snps[0].ID, 45, 1)
+	save(snps[1].ID, 10, 1)
+
+	history, err := GetScoreHistory(ctx, db, snps[0].ID)
+	if err != nil {
+		t.Fatalf("history: %v", err)
+	}
+	if len(history) != 0 {
+		t.Fatalf("expected rescoring under the same version to keep no history, got %d rows", len(history))
+	}
+
+	marked, err := MarkOutdatedScores(ctx, db, 2)
+	if err != nil {
+		t.Fatalf("mark outdated: %v", err)
+	}
+	if marked != 2 {
+		t.Fatalf("expected 2 outdated scores, got %d", marked)
+	}
+
+	save(snps[0].ID, 60, 2)
+	history, err = GetScoreHistory(ctx, db, snps[0].ID)
+	if err != nil {
+		t.Fatalf("history: %v", err)
+	}
+	if len(history) != 1 || history[0].AlgorithmVersion != 1 || history[0].TotalScore != 45 {
+		t.Fatalf("expected the version 1 score to be archived, got %+v", history)
+	}
+
+	current, err := repos.Significance.GetBySNP(ctx, snps[0].ID)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if current.AlgorithmVersion != 2 || current.TotalScore != 60 || current.Stale {
+		t.Fatalf("unexpected current score: %+v", current)
+	}
+
+	release, err := CreateRelease(ctx, db, "2.0.0", map[string]string{})
+	if err != nil {
+		t.Fatalf("create release: %v", err)
+	}
+	if release.ScoreAlgorithmVersion != 2 {
+		t.Fatalf("expected release to record algorithm version 2, got %d", release.ScoreAlgorithmVersion)
+	}
+}
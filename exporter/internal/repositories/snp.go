@@ -2,10 +2,12 @@ package repositories
 
 import (
 	"context"
+	"strings"
 
 	"github.com/uptrace/bun"
 
 	"github.com/mkoziy/genome/exporter/internal/models"
+	"github.com/mkoziy/genome/exporter/internal/variant"
 )
 
 // GetSNPByRsID fetches a SNP by rsID with related data.
@@ -15,15 +17,159 @@ func GetSNPByRsID(ctx context.Context, db *bun.DB, rsID string) (*models.SNP, er
 		Model(snp).
 		Where("rsid = ?", rsID).
 		Relation("Significance").
+		Relation("Quality").
+		Relation("Frequency").
+		Relation("HardyWeinberg").
 		Relation("ClinicalData").
 		Relation("Phenotypes").
 		Relation("References").
 		Relation("PopulationData").
+		Relation("RiskAlleles").
+		Relation("GenotypeEffects").
+		Relation("ClinicalAgreements").
+		Relation("Haplotypes.Haplotype").
 		Scan(ctx)
 
 	return snp, err
 }
 
+// rsIDChunkSize bounds the IN clause of batch rsID lookups.
+const rsIDChunkSize = 900
+
+// GetSNPsByRsIDs fetches SNPs with related data for many rsIDs, keyed by rsID.
+// Lookups are chunked so arbitrarily long inputs (e.g. a full 23andMe file) run as a
+// handful of queries; rsIDs not in the database are simply absent from the map.
+func GetSNPsByRsIDs(ctx context.Context, db *bun.DB, rsIDs []string) (map[string]*models.SNP, error) {
+	result := make(map[string]*models.SNP, len(rsIDs))
+
+	unique := make([]string, 0, len(rsIDs))
+	seen := make(map[string]bool, len(rsIDs))
+	for _, id := range rsIDs {
+		if id == "" || seen[id] {
+			continue
+		}
+		seen[id] = true
+		unique = append(unique, id)
+	}
+
+	for start := 0; start < len(unique); start += rsIDChunkSize {
+		end := start + rsIDChunkSize
+		if end > len(unique) {
+			end = len(unique)
+		}
+
+		var snps []*models.SNP
+		err := db.NewSelect().
+			Model(&snps).
+			Where("rsid IN (?)", bun.In(unique[start:end])).
+			Relation("Significance").
+			Relation("Quality").
+			Relation("Frequency").
+			Relation("HardyWeinberg").
+			Relation("ClinicalData").
+			Relation("Phenotypes").
+			Relation("References").
+			Relation("PopulationData").
+			Relation("RiskAlleles").
+			Relation("GenotypeEffects").
+			Relation("ClinicalAgreements").
+			Relation("Haplotypes.Haplotype").
+			Scan(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, snp := range snps {
+			result[snp.RsID] = snp
+		}
+	}
+
+	return result, nil
+}
+
+// GetSNPsByVariantKeys fetches SNPs with related data whose canonical
+// chrom:pos:ref:alt key is one of keys, for looking variants up by position
+// and alleles rather than rsID. Lookups are chunked like GetSNPsByRsIDs. Keys
+// shared by several SNPs, left from before keys were enforced, return all of
+// them.
+func GetSNPsByVariantKeys(ctx context.Context, db *bun.DB, keys []string) ([]*models.SNP, error) {
+	var result []*models.SNP
+	for start := 0; start < len(keys); start += rsIDChunkSize {
+		var snps []*models.SNP
+		err := db.NewSelect().
+			Model(&snps).
+			Where("variant_key IN (?)", bun.In(keys[start:min(start+rsIDChunkSize, len(keys))])).
+			Relation("Significance").
+			Relation("Quality").
+			Relation("Frequency").
+			Relation("HardyWeinberg").
+			Relation("ClinicalData").
+			Relation("Phenotypes").
+			Relation("References").
+			Relation("PopulationData").
+			Relation("RiskAlleles").
+			Relation("GenotypeEffects").
+			Relation("ClinicalAgreements").
+			Relation("Haplotypes.Haplotype").
+			Scan(ctx)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, snps...)
+	}
+	return result, nil
+}
+
+// GetSNPsByHGVS fetches SNPs with related data described by h. Genomic
+// descriptions are looked up among the genomic ones, coding among the
+// coding and protein among the protein ones. A description whose accession
+// lacks its version, such as "NM_000546:c.215C>G", matches any version; a
+// protein change on a gene, such as "TP53:p.Pro72Arg", matches the gene's
+// SNPs with that change on any of its proteins.
+func GetSNPsByHGVS(ctx context.Context, db *bun.DB, h variant.HGVS) ([]*models.SNP, error) {
+	columns := []string{"hgvs_genomic", "hgvs_coding", "hgvs_protein"}
+	switch h.Type {
+	case variant.HGVSGenomic, "m":
+		columns = columns[:1]
+	case variant.HGVSCoding:
+		columns = columns[1:2]
+	case variant.HGVSProtein:
+		columns = columns[2:]
+	}
+	suffix := ":" + h.Type + "." + h.Change
+
+	var snps []*models.SNP
+	err := db.NewSelect().
+		Model(&snps).
+		WhereGroup(" AND ", func(q *bun.SelectQuery) *bun.SelectQuery {
+			for _, column := range columns {
+				q = q.WhereOr("s.? = ?", bun.Ident(column), h.String())
+				switch {
+				case h.Unversioned():
+					q = q.WhereOr("s.? LIKE ? ESCAPE '\\'", bun.Ident(column), escapeLike(h.Accession)+".%"+escapeLike(suffix))
+				case h.Type == variant.HGVSProtein && !strings.Contains(h.Accession, "_"):
+					q = q.WhereOr("(s.gene_symbol = ? AND s.? LIKE ? ESCAPE '\\')", strings.ToUpper(h.Accession), bun.Ident(column), "%"+escapeLike(suffix))
+				}
+			}
+			return q
+		}).
+		Relation("Significance").
+		Relation("Quality").
+		Relation("Frequency").
+		Relation("HardyWeinberg").
+		Relation("ClinicalData").
+		Relation("Phenotypes").
+		Relation("References").
+		Relation("PopulationData").
+		Relation("RiskAlleles").
+		Relation("GenotypeEffects").
+		Relation("ClinicalAgreements").
+		Relation("Haplotypes.Haplotype").
+		OrderExpr("s.id ASC").
+		Scan(ctx)
+	return snps, err
+}
+
 // GetTopSignificantSNPs returns SNPs ordered by total score with pathogenic clinical annotations.
 func GetTopSignificantSNPs(ctx context.Context, db *bun.DB, limit int) ([]*models.SNP, error) {
 	var snps []*models.SNP
@@ -56,10 +202,8 @@ func InsertSNPWithData(ctx context.Context, db *bun.DB, snp *models.SNP, clinica
 			r.SNPID = snp.ID
 		}
 
-		if len(clinical) > 0 {
-			if _, err := tx.NewInsert().Model(&clinical).Exec(ctx); err != nil {
-				return err
-			}
+		if err := UpsertClinicalData(ctx, tx, clinical); err != nil {
+			return err
 		}
 
 		if len(refs) > 0 {
@@ -73,7 +217,7 @@ func InsertSNPWithData(ctx context.Context, db *bun.DB, snp *models.SNP, clinica
 }
 
 // UpsertSNPs performs a batch upsert on SNPs keyed by rsID.
-func UpsertSNPs(ctx context.Context, db *bun.DB, snps []*models.SNP) error {
+func UpsertSNPs(ctx context.Context, db bun.IDB, snps []*models.SNP) error {
 	_, err := db.NewInsert().
 		Model(&snps).
 		On("CONFLICT (rsid) DO UPDATE").
@@ -82,6 +226,20 @@ func UpsertSNPs(ctx context.Context, db *bun.DB, snps []*models.SNP) error {
 		Set("reference_allele = EXCLUDED.reference_allele").
 		Set("alternate_alleles = EXCLUDED.alternate_alleles").
 		Set("gene_symbol = EXCLUDED.gene_symbol").
+		Set("variant_key = EXCLUDED.variant_key").
+		Set("hgvs_genomic = EXCLUDED.hgvs_genomic").
+		Set("hgvs_coding = COALESCE(EXCLUDED.hgvs_coding, hgvs_coding)").
+		Set("hgvs_protein = COALESCE(EXCLUDED.hgvs_protein, hgvs_protein)").
+		// GRCh37 coordinates lifted over are kept unless the GRCh38 ones
+		// they were lifted from moved.
+		Set("grch37_chromosome = CASE WHEN EXCLUDED.grch37_position IS NOT NULL THEN EXCLUDED.grch37_chromosome " +
+			"WHEN chromosome = EXCLUDED.chromosome AND position = EXCLUDED.position THEN grch37_chromosome END").
+		Set("grch37_position = CASE WHEN EXCLUDED.grch37_position IS NOT NULL THEN EXCLUDED.grch37_position " +
+			"WHEN chromosome = EXCLUDED.chromosome AND position = EXCLUDED.position THEN grch37_position END").
+		// Sources that do not describe extents leave them as they are.
+		Set("end_position = COALESCE(EXCLUDED.end_position, end_position)").
+		Set("sv_type = COALESCE(EXCLUDED.sv_type, sv_type)").
+		Set("sv_length = COALESCE(EXCLUDED.sv_length, sv_length)").
 		Set("updated_at = CURRENT_TIMESTAMP").
 		Exec(ctx)
 
@@ -0,0 +1,47 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/mkoziy/genome/exporter/internal/models"
+)
+
+func TestGetSNPsByRsIDs(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+
+	// Enough rows to span several chunks.
+	snps := make([]*models.SNP, 0, 2*rsIDChunkSize)
+	for i := 1; i <= 2*rsIDChunkSize; i++ {
+		snps = append(snps, testSNP(fmt.Sprintf("rs%d", i), "1", int64(i)))
+	}
+	if err := UpsertSNPs(ctx, db, snps); err != nil {
+		t.Fatalf("upsert: %v", err)
+	}
+	clinical := &models.ClinicalData{SNPID: snps[0].ID, ClinicalSignificance: models.ClinicalPathogenic, ReviewStatus: models.ReviewExpertPanel, ConditionName: "X", Source: models.SourceClinVar}
+	if _, err := db.NewInsert().Model(clinical).Exec(ctx); err != nil {
+		t.Fatalf("insert clinical: %v", err)
+	}
+
+	query := make([]string, 0)
+	for i := 1; i <= 2*rsIDChunkSize; i += 2 {
+		query = append(query, fmt.Sprintf("rs%d", i))
+	}
+	query = append(query, "rs1", "rs999999999", "")
+
+	got, err := GetSNPsByRsIDs(ctx, db, query)
+	if err != nil {
+		t.Fatalf("batch lookup: %v", err)
+	}
+	if len(got) != rsIDChunkSize {
+		t.Fatalf("expected %d matches, got %d", rsIDChunkSize, len(got))
+	}
+	if _, ok := got["rs999999999"]; ok {
+		t.Fatalf("unexpected match for unknown rsID")
+	}
+	if len(got["rs1"].ClinicalData) != 1 {
+		t.Fatalf("expected clinical data preloaded for rs1")
+	}
+}
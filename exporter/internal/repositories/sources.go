@@ -0,0 +1,103 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/uptrace/bun"
+
+	"github.com/mkoziy/genome/exporter/internal/models"
+)
+
+// sourceTables are the tables whose rows record the source they came from.
+var sourceTables = []string{
+	"snp_clinical",
+	"snp_phenotypes",
+	"snp_references",
+	"snp_populations",
+	"risk_alleles",
+	"genotype_effects",
+	"clinical_agreements",
+}
+
+// RecordSourceAccess stores when a source was last fetched. A source seen
+// for the first time is stored as given; for a known one only last_accessed
+// is updated, so curated URLs and terms are kept.
+func RecordSourceAccess(ctx context.Context, db bun.IDB, source *models.SourceMetadata) error {
+	source.IsActive = true
+	_, err := db.NewInsert().
+		Model(source).
+		On("CONFLICT (source_name) DO UPDATE").
+		Set("last_accessed = EXCLUDED.last_accessed").
+		Exec(ctx)
+	return err
+}
+
+// ListSources returns the stored data sources by name.
+func ListSources(ctx context.Context, db bun.IDB) ([]*models.SourceMetadata, error) {
+	sources := make([]*models.SourceMetadata, 0)
+	err := db.NewSelect().
+		Model(&sources).
+		OrderExpr("ds.source_name ASC").
+		Scan(ctx)
+	return sources, err
+}
+
+// SourceCounts are the rows one source contributed: per table, and the
+// distinct SNPs they annotate.
+type SourceCounts struct {
+	SNPs   int
+	Tables models.CountMap
+}
+
+// CountRecordsBySource counts the rows of every table recording its source,
+// by source. Sources without rows are absent.
+func CountRecordsBySource(ctx context.Context, db bun.IDB) (map[models.DataSource]*SourceCounts, error) {
+	counts := make(map[models.DataSource]*SourceCounts)
+	get := func(source models.DataSource) *SourceCounts {
+		c, ok := counts[source]
+		if !ok {
+			c = &SourceCounts{Tables: make(models.CountMap)}
+			counts[source] = c
+		}
+		return c
+	}
+
+	parts := make([]string, len(sourceTables))
+	for i, table := range sourceTables {
+		var rows []struct {
+			Source models.DataSource `bun:"source"`
+			N      int               `bun:"n"`
+		}
+		err := db.NewSelect().
+			TableExpr("?", bun.Ident(table)).
+			ColumnExpr("source, COUNT(*) AS n").
+			Where("source IS NOT NULL AND source != ''").
+			GroupExpr("source").
+			Scan(ctx, &rows)
+		if err != nil {
+			return nil, fmt.Errorf("count %s: %w", table, err)
+		}
+		for _, r := range rows {
+			get(r.Source).Tables[table] = r.N
+		}
+		parts[i] = "SELECT snp_id, source FROM " + table
+	}
+
+	// A SNP annotated by a source in several tables counts once.
+	var snps []struct {
+		Source models.DataSource `bun:"source"`
+		N      int               `bun:"n"`
+	}
+	err := db.NewRaw("SELECT source, COUNT(DISTINCT snp_id) AS n FROM ("+strings.Join(parts, " UNION ALL ")+") AS annotated "+
+		"WHERE source IS NOT NULL AND source != '' GROUP BY source").
+		Scan(ctx, &snps)
+	if err != nil {
+		return nil, fmt.Errorf("count annotated snps: %w", err)
+	}
+	for _, r := range snps {
+		get(r.Source).SNPs = r.N
+	}
+	return counts, nil
+}
@@ -0,0 +1,88 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/uptrace/bun"
+
+	"github.com/mkoziy/genome/exporter/internal/models"
+)
+
+// subsetTables are the tables whose rows belong to a SNP through snp_id,
+// and leave a subset with it.
+var subsetTables = []string{
+	"snp_significance",
+	"snp_significance_history",
+	"snp_quality",
+	"snp_frequencies",
+	"snp_hardy_weinberg",
+	"snp_clinical",
+	"snp_phenotypes",
+	"snp_references",
+	"snp_populations",
+	"risk_alleles",
+	"genotype_effects",
+	"clinical_agreements",
+	"snp_translations",
+	"snp_aliases",
+	"retractions",
+}
+
+// Subset deletes from db every SNP filter does not match, retracted SNPs
+// among them, with the rows of every table belonging to them, leaving a
+// database of the matching SNPs alone. Catalogs keyed by rsID, such as
+// haplotypes and PRS weights, are kept whole. The audit log entries the
+// deletions write are removed again, a subset being no change of the data.
+// It returns how many SNPs were kept, in one transaction; db is meant to be
+// a copy, such as a Backup.
+func Subset(ctx context.Context, db *bun.DB, filter SNPFilter) (int, error) {
+	var kept int
+	err := db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		var lastAudit int64
+		if err := tx.NewRaw("SELECT COALESCE(MAX(id), 0) FROM audit_log").Scan(ctx, &lastAudit); err != nil {
+			return fmt.Errorf("find audit log end: %w", err)
+		}
+
+		// The filter's conditions read the rows deleted below, so the SNPs
+		// kept are settled first.
+		keep := tx.NewSelect().Model((*models.SNP)(nil)).Column("s.id").Apply(filter.apply)
+		if _, err := tx.NewRaw("CREATE TEMP TABLE subset_snps AS ?", keep).Exec(ctx); err != nil {
+			return fmt.Errorf("select kept snps: %w", err)
+		}
+		defer func() {
+			_, _ = tx.ExecContext(ctx, "DROP TABLE IF EXISTS temp.subset_snps")
+		}()
+		if err := tx.NewRaw("SELECT COUNT(*) FROM temp.subset_snps").Scan(ctx, &kept); err != nil {
+			return fmt.Errorf("count kept snps: %w", err)
+		}
+
+		for _, table := range subsetTables {
+			if _, err := tx.NewRaw("DELETE FROM ? WHERE snp_id NOT IN (SELECT id FROM temp.subset_snps)",
+				bun.Ident(table)).Exec(ctx); err != nil {
+				return fmt.Errorf("delete %s: %w", table, err)
+			}
+		}
+		if _, err := tx.ExecContext(ctx, `DELETE FROM phenotype_translations
+			WHERE NOT EXISTS (SELECT 1 FROM snp_phenotypes AS p WHERE p.id = phenotype_translations.phenotype_id)`); err != nil {
+			return fmt.Errorf("delete phenotype_translations: %w", err)
+		}
+		if _, err := tx.ExecContext(ctx, "DELETE FROM snps WHERE id NOT IN (SELECT id FROM temp.subset_snps)"); err != nil {
+			return fmt.Errorf("delete snps: %w", err)
+		}
+
+		// The change feed goes with the SNPs, along with the rows the deletions
+		// wrote to it.
+		if _, err := tx.ExecContext(ctx, "DELETE FROM change_feed WHERE snp_id NOT IN (SELECT id FROM temp.subset_snps)"); err != nil {
+			return fmt.Errorf("delete change_feed: %w", err)
+		}
+		if _, err := tx.ExecContext(ctx, "DELETE FROM audit_log WHERE id > ?", lastAudit); err != nil {
+			return fmt.Errorf("delete audit log: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return kept, nil
+}
@@ -0,0 +1,76 @@
+package repositories
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mkoziy/genome/exporter/internal/models"
+)
+
+func TestSubset(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+
+	snps := []*models.SNP{testSNP("rs1", "1", 1), testSNP("rs2", "1", 2), testSNP("rs3", "2", 3)}
+	if _, err := db.NewInsert().Model(&snps).Exec(ctx); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+	clinical := []*models.ClinicalData{
+		{SNPID: snps[0].ID, ClinicalSignificance: models.ClinicalPathogenic, ReviewStatus: models.ReviewExpertPanel, ConditionName: "A", Source: models.SourceClinVar},
+		{SNPID: snps[1].ID, ClinicalSignificance: models.ClinicalPathogenic, ReviewStatus: models.ReviewSingleSubmitter, ConditionName: "B", Source: models.SourceClinVar},
+		{SNPID: snps[2].ID, ClinicalSignificance: models.ClinicalBenign, ReviewStatus: models.ReviewExpertPanel, ConditionName: "C", Source: models.SourceClinVar},
+	}
+	if _, err := db.NewInsert().Model(&clinical).Exec(ctx); err != nil {
+		t.Fatalf("insert clinical: %v", err)
+	}
+	sigs := []*models.Significance{{SNPID: snps[0].ID, TotalScore: 80}, {SNPID: snps[1].ID, TotalScore: 70}, {SNPID: snps[2].ID, TotalScore: 10}}
+	if _, err := db.NewInsert().Model(&sigs).Exec(ctx); err != nil {
+		t.Fatalf("insert significance: %v", err)
+	}
+	audited, err := db.NewSelect().Table("audit_log").Count(ctx)
+	if err != nil {
+		t.Fatalf("count audit log: %v", err)
+	}
+
+	min60 := 60.0
+	kept, err := Subset(ctx, db, SNPFilter{
+		ClinicalSignificances: []models.ClinicalSignificance{models.ClinicalPathogenic},
+		ReviewStatuses:        []models.ReviewStatus{models.ReviewExpertPanel},
+		MinScore:              &min60,
+	})
+	if err != nil {
+		t.Fatalf("subset: %v", err)
+	}
+	if kept != 1 {
+		t.Fatalf("expected 1 SNP kept, got %d", kept)
+	}
+
+	var rsIDs []string
+	if err := db.NewSelect().Model((*models.SNP)(nil)).WhereAllWithDeleted().Column("rsid").Scan(ctx, &rsIDs); err != nil {
+		t.Fatalf("select snps: %v", err)
+	}
+	if len(rsIDs) != 1 || rsIDs[0] != "rs1" {
+		t.Fatalf("expected only rs1 left, got %v", rsIDs)
+	}
+	for _, table := range []string{"snp_clinical", "snp_significance", "change_feed"} {
+		n, err := db.NewSelect().Table(table).Where("snp_id <> ?", snps[0].ID).Count(ctx)
+		if err != nil {
+			t.Fatalf("count %s: %v", table, err)
+		}
+		if n != 0 {
+			t.Errorf("expected no %s rows of dropped SNPs, got %d", table, n)
+		}
+	}
+	if n, err := db.NewSelect().Table("audit_log").Count(ctx); err != nil || n != audited {
+		t.Fatalf("expected the audit log left at %d rows, got %d (%v)", audited, n, err)
+	}
+}
+
+func TestSNPFilterValidate(t *testing.T) {
+	if err := (SNPFilter{ClinicalSignificances: []models.ClinicalSignificance{models.ClinicalPathogenic}}).Validate(); err != nil {
+		t.Fatalf("expected a valid filter, got %v", err)
+	}
+	if err := (SNPFilter{ReviewStatuses: []models.ReviewStatus{"expert_panel"}}).Validate(); err == nil {
+		t.Fatal("expected an unknown review status to be refused")
+	}
+}
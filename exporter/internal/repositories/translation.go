@@ -0,0 +1,236 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/uptrace/bun"
+
+	"github.com/mkoziy/genome/exporter/internal/models"
+)
+
+// Translations are the translated texts of a set of SNPs in one language.
+// A nil *Translations translates nothing.
+type Translations struct {
+	Language string
+	// SNPs maps a SNP ID to its translated fields by field name.
+	SNPs map[int64]map[string]string
+	// Phenotypes maps a phenotype ID to its translated name.
+	Phenotypes map[int64]string
+	// Messages maps a message key to its translation.
+	Messages map[string]string
+}
+
+// SNPField returns the translation of field of the SNP, or "" if there is none.
+func (t *Translations) SNPField(snpID int64, field string) string {
+	if t == nil {
+		return ""
+	}
+	return t.SNPs[snpID][field]
+}
+
+// GetTranslations loads the lang translations of the SNPs and their
+// phenotypes, in chunks like GetSNPsByRsIDs, and of every message.
+func GetTranslations(ctx context.Context, db *bun.DB, lang string, snpIDs []int64) (*Translations, error) {
+	messages, err := GetMessageTranslations(ctx, db, lang)
+	if err != nil {
+		return nil, err
+	}
+	t := &Translations{
+		Language:   lang,
+		SNPs:       make(map[int64]map[string]string),
+		Phenotypes: make(map[int64]string),
+		Messages:   messages,
+	}
+	for start := 0; start < len(snpIDs); start += rsIDChunkSize {
+		ids := snpIDs[start:min(start+rsIDChunkSize, len(snpIDs))]
+
+		var fields []*models.Translation
+		err := db.NewSelect().
+			Model(&fields).
+			Where("t.language_code = ?", lang).
+			Where("t.snp_id IN (?)", bun.In(ids)).
+			Scan(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range fields {
+			if t.SNPs[f.SNPID] == nil {
+				t.SNPs[f.SNPID] = make(map[string]string)
+			}
+			t.SNPs[f.SNPID][f.FieldName] = f.TranslatedText
+		}
+
+		var names []*models.PhenotypeTranslation
+		err = db.NewSelect().
+			Model(&names).
+			Where("pt.language_code = ?", lang).
+			Where("pt.phenotype_id IN (SELECT id FROM snp_phenotypes WHERE snp_id IN (?))", bun.In(ids)).
+			Scan(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, n := range names {
+			t.Phenotypes[n.PhenotypeID] = n.TranslatedName
+		}
+	}
+	return t, nil
+}
+
+// GetMessageTranslations returns the lang translations of messages by
+// message key.
+func GetMessageTranslations(ctx context.Context, db bun.IDB, lang string) (map[string]string, error) {
+	var rows []*models.MessageTranslation
+	if err := db.NewSelect().Model(&rows).Where("mt.language_code = ?", lang).Scan(ctx); err != nil {
+		return nil, err
+	}
+	messages := make(map[string]string, len(rows))
+	for _, row := range rows {
+		messages[row.MessageKey] = row.TranslatedText
+	}
+	return messages, nil
+}
+
+// statusRank returns the SQL expression of the models.TranslationStatus
+// Rank of the status column of the table aliased alias.
+func statusRank(alias string) string {
+	expr := "CASE " + alias + ".status"
+	for _, status := range models.TranslationStatuses {
+		expr += fmt.Sprintf(" WHEN '%s' THEN %d", status, status.Rank())
+	}
+	return expr + " ELSE -1 END"
+}
+
+// ListPendingTranslations returns up to limit lang translations of SNP
+// fields not yet verified, in status if it is set, oldest first, with their
+// SNP. A reviewer works through them and advances each with
+// ReviewTranslation.
+func ListPendingTranslations(ctx context.Context, db bun.IDB, lang string, status models.TranslationStatus, limit int) ([]*models.Translation, error) {
+	rows := make([]*models.Translation, 0)
+	q := db.NewSelect().
+		Model(&rows).
+		Relation("SNP").
+		Where("t.language_code = ?", lang).
+		Where("t.status != ?", models.TranslationVerified).
+		OrderExpr("t.id ASC").
+		Limit(limit)
+	if status != "" {
+		q = q.Where("t.status = ?", status)
+	}
+	return rows, q.Scan(ctx)
+}
+
+// ListPendingPhenotypeTranslations is ListPendingTranslations for the
+// translations of phenotype names, with their phenotype.
+func ListPendingPhenotypeTranslations(ctx context.Context, db bun.IDB, lang string, status models.TranslationStatus, limit int) ([]*models.PhenotypeTranslation, error) {
+	rows := make([]*models.PhenotypeTranslation, 0)
+	q := db.NewSelect().
+		Model(&rows).
+		Relation("Phenotype").
+		Where("pt.language_code = ?", lang).
+		Where("pt.status != ?", models.TranslationVerified).
+		OrderExpr("pt.id ASC").
+		Limit(limit)
+	if status != "" {
+		q = q.Where("pt.status = ?", status)
+	}
+	return rows, q.Scan(ctx)
+}
+
+// TranslationProgress counts the translations of one language, of SNP
+// fields and phenotype names together, by status.
+type TranslationProgress struct {
+	Language string          `json:"language"`
+	Statuses models.CountMap `json:"statuses"`
+}
+
+// Pending returns the number of translations not yet verified.
+func (p *TranslationProgress) Pending() int {
+	n := 0
+	for status, count := range p.Statuses {
+		if status != string(models.TranslationVerified) {
+			n += count
+		}
+	}
+	return n
+}
+
+// GetTranslationProgress counts the translations of every language by
+// status, ordered by language.
+func GetTranslationProgress(ctx context.Context, db bun.IDB) ([]*TranslationProgress, error) {
+	var counts []struct {
+		Language string `bun:"language_code"`
+		Status   string `bun:"status"`
+		Count    int    `bun:"n"`
+	}
+	err := db.NewRaw(`SELECT language_code, status, SUM(n) AS n FROM (
+			SELECT language_code, status, COUNT(*) AS n FROM snp_translations GROUP BY language_code, status
+			UNION ALL
+			SELECT language_code, status, COUNT(*) AS n FROM phenotype_translations GROUP BY language_code, status
+			UNION ALL
+			SELECT language_code, status, COUNT(*) AS n FROM message_translations GROUP BY language_code, status
+		) GROUP BY language_code, status ORDER BY language_code`).Scan(ctx, &counts)
+	if err != nil {
+		return nil, err
+	}
+	var progress []*TranslationProgress
+	for _, c := range counts {
+		if len(progress) == 0 || progress[len(progress)-1].Language != c.Language {
+			progress = append(progress, &TranslationProgress{Language: c.Language, Statuses: models.CountMap{}})
+		}
+		progress[len(progress)-1].Statuses[c.Status] = c.Count
+	}
+	return progress, nil
+}
+
+// ReviewTranslation moves the SNP field translation with the given ID to
+// status next on behalf of reviewer, and returns it. It returns
+// sql.ErrNoRows if there is no such translation and an error if it may not
+// move to next from its status.
+func ReviewTranslation(ctx context.Context, db bun.IDB, id int64, next models.TranslationStatus, reviewer string) (*models.Translation, error) {
+	row := &models.Translation{ID: id}
+	return row, review(ctx, db, row, func() error { return row.Advance(next, reviewer, time.Now()) })
+}
+
+// ReviewPhenotypeTranslation is ReviewTranslation for the translation of a
+// phenotype name.
+func ReviewPhenotypeTranslation(ctx context.Context, db bun.IDB, id int64, next models.TranslationStatus, reviewer string) (*models.PhenotypeTranslation, error) {
+	row := &models.PhenotypeTranslation{ID: id}
+	return row, review(ctx, db, row, func() error { return row.Advance(next, reviewer, time.Now()) })
+}
+
+// review loads the translation model by primary key, advances it and
+// writes its review columns back, unless another review moved it first.
+func review(ctx context.Context, db bun.IDB, model any, advance func() error) error {
+	if err := db.NewSelect().Model(model).WherePK().Scan(ctx); err != nil {
+		return err
+	}
+	var from models.TranslationStatus
+	columns := []string{"status", "verified", "reviewer", "reviewed_at", "verified_at"}
+	switch row := model.(type) {
+	case *models.Translation:
+		from = row.Status
+		columns = append(columns, "updated_at")
+	case *models.PhenotypeTranslation:
+		from = row.Status
+	}
+	if err := advance(); err != nil {
+		return err
+	}
+	res, err := db.NewUpdate().
+		Model(model).
+		Column(columns...).
+		WherePK().
+		Where("status = ?", from).
+		Exec(ctx)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return fmt.Errorf("translation is no longer %s: it was reviewed concurrently", from)
+	}
+	return nil
+}
@@ -0,0 +1,179 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/mkoziy/genome/exporter/internal/models"
+)
+
+func TestGetTranslations(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+
+	snps := []*models.SNP{testSNP("rs1", "1", 1), testSNP("rs2", "1", 2)}
+	if _, err := db.NewInsert().Model(&snps).Exec(ctx); err != nil {
+		t.Fatalf("insert snps: %v", err)
+	}
+	phenotype := &models.Phenotype{SNPID: snps[0].ID, PhenotypeName: "Eye color", AssociationType: "association", Source: models.SourceGWAS}
+	if _, err := db.NewInsert().Model(phenotype).Exec(ctx); err != nil {
+		t.Fatalf("insert phenotype: %v", err)
+	}
+	translations := []*models.Translation{
+		{SNPID: snps[0].ID, LanguageCode: "de", FieldName: models.TranslationSummary, TranslatedText: "geprüft", Verified: true},
+		{SNPID: snps[0].ID, LanguageCode: "de", FieldName: models.TranslationSummary, TranslatedText: "maschinell"},
+		{SNPID: snps[0].ID, LanguageCode: "fr", FieldName: models.TranslationSummary, TranslatedText: "résumé"},
+		{SNPID: snps[1].ID, LanguageCode: "de", FieldName: models.TranslationSummary, TranslatedText: "nicht angefragt"},
+	}
+	// A later machine draft does not replace the verified summary.
+	for _, tr := range translations {
+		if err := UpsertTranslations(ctx, db, []*models.Translation{tr}); err != nil {
+			t.Fatalf("upsert translation: %v", err)
+		}
+	}
+	name := &models.PhenotypeTranslation{PhenotypeID: phenotype.ID, LanguageCode: "de", TranslatedName: "Augenfarbe"}
+	if _, err := db.NewInsert().Model(name).Exec(ctx); err != nil {
+		t.Fatalf("insert phenotype translation: %v", err)
+	}
+
+	got, err := GetTranslations(ctx, db, "de", []int64{snps[0].ID})
+	if err != nil {
+		t.Fatalf("get translations: %v", err)
+	}
+	if s := got.SNPField(snps[0].ID, models.TranslationSummary); s != "geprüft" {
+		t.Fatalf("expected the verified summary, got %q", s)
+	}
+	if s := got.SNPField(snps[1].ID, models.TranslationSummary); s != "" {
+		t.Fatalf("expected no summary for an SNP not asked for, got %q", s)
+	}
+	if n := got.Phenotypes[phenotype.ID]; n != "Augenfarbe" {
+		t.Fatalf("expected translated phenotype name, got %q", n)
+	}
+
+	var none *Translations
+	if none.SNPField(snps[0].ID, models.TranslationSummary) != "" {
+		t.Fatalf("expected nil translations to translate nothing")
+	}
+}
+
+func TestTranslationReview(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+
+	snp := testSNP("rs1", "1", 1)
+	if _, err := db.NewInsert().Model(snp).Exec(ctx); err != nil {
+		t.Fatalf("insert snp: %v", err)
+	}
+	phenotype := &models.Phenotype{SNPID: snp.ID, PhenotypeName: "Eye color", AssociationType: "association", Source: models.SourceGWAS}
+	if _, err := db.NewInsert().Model(phenotype).Exec(ctx); err != nil {
+		t.Fatalf("insert phenotype: %v", err)
+	}
+	translations := []*models.Translation{
+		{SNPID: snp.ID, LanguageCode: "de", FieldName: models.TranslationSummary, TranslatedText: "maschinell"},
+		{SNPID: snp.ID, LanguageCode: "de", FieldName: models.TranslationConditionField("Blue eyes"), TranslatedText: "Blaue Augen", Verified: true},
+		{SNPID: snp.ID, LanguageCode: "fr", FieldName: models.TranslationSummary, TranslatedText: "résumé"},
+	}
+	if _, err := db.NewInsert().Model(&translations).Exec(ctx); err != nil {
+		t.Fatalf("insert translations: %v", err)
+	}
+	name := &models.PhenotypeTranslation{PhenotypeID: phenotype.ID, LanguageCode: "de", TranslatedName: "Augenfarbe"}
+	if _, err := db.NewInsert().Model(name).Exec(ctx); err != nil {
+		t.Fatalf("insert phenotype translation: %v", err)
+	}
+
+	pending, err := ListPendingTranslations(ctx, db, "de", "", 10)
+	if err != nil || len(pending) != 1 || pending[0].ID != translations[0].ID || pending[0].SNP.RsID != "rs1" {
+		t.Fatalf("expected the German draft pending, got %v (%v)", pending, err)
+	}
+	names, err := ListPendingPhenotypeTranslations(ctx, db, "de", models.TranslationMachineDraft, 10)
+	if err != nil || len(names) != 1 || names[0].Phenotype.PhenotypeName != "Eye color" {
+		t.Fatalf("expected the phenotype name pending, got %v (%v)", names, err)
+	}
+
+	if _, err := ReviewTranslation(ctx, db, translations[0].ID, models.TranslationVerified, "anna"); err == nil {
+		t.Fatal("expected a draft not to be verified before review")
+	}
+	reviewed, err := ReviewTranslation(ctx, db, translations[0].ID, models.TranslationReviewed, "anna")
+	if err != nil || reviewed.Status != models.TranslationReviewed || *reviewed.Reviewer != "anna" || reviewed.ReviewedAt == nil {
+		t.Fatalf("expected the draft reviewed, got %+v (%v)", reviewed, err)
+	}
+	if pending, _ := ListPendingTranslations(ctx, db, "de", models.TranslationMachineDraft, 10); len(pending) != 0 {
+		t.Fatalf("expected no German drafts left, got %v", pending)
+	}
+	if _, err := ReviewPhenotypeTranslation(ctx, db, name.ID, models.TranslationReviewed, "anna"); err != nil {
+		t.Fatalf("review phenotype translation: %v", err)
+	}
+	if _, err := ReviewTranslation(ctx, db, 999, models.TranslationReviewed, "anna"); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("expected sql.ErrNoRows for a missing translation, got %v", err)
+	}
+
+	progress, err := GetTranslationProgress(ctx, db)
+	if err != nil || len(progress) != 2 || progress[0].Language != "de" || progress[1].Language != "fr" {
+		t.Fatalf("expected progress of de and fr, got %v (%v)", progress, err)
+	}
+	if de := progress[0]; de.Statuses[string(models.TranslationReviewed)] != 2 || de.Statuses[string(models.TranslationVerified)] != 1 || de.Pending() != 2 {
+		t.Fatalf("unexpected German progress: %v", de.Statuses)
+	}
+}
+
+func TestUpsertTranslations(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+
+	snp := testSNP("rs1", "1", 1)
+	if _, err := db.NewInsert().Model(snp).Exec(ctx); err != nil {
+		t.Fatalf("insert snp: %v", err)
+	}
+	phenotype := &models.Phenotype{SNPID: snp.ID, PhenotypeName: "Eye color", AssociationType: "association", Source: models.SourceGWAS}
+	if _, err := db.NewInsert().Model(phenotype).Exec(ctx); err != nil {
+		t.Fatalf("insert phenotype: %v", err)
+	}
+	condition := models.TranslationConditionField("Blue eyes")
+	run := func(summary, name string) {
+		t.Helper()
+		rows := []*models.Translation{
+			{SNPID: snp.ID, LanguageCode: "de", FieldName: models.TranslationSummary, TranslatedText: summary},
+			{SNPID: snp.ID, LanguageCode: "de", FieldName: condition, TranslatedText: "Blaue Augen"},
+		}
+		if err := UpsertTranslations(ctx, db, rows); err != nil {
+			t.Fatalf("upsert translations: %v", err)
+		}
+		names := []*models.PhenotypeTranslation{{PhenotypeID: phenotype.ID, LanguageCode: "de", TranslatedName: name}}
+		if err := UpsertPhenotypeTranslations(ctx, db, names); err != nil {
+			t.Fatalf("upsert phenotype translations: %v", err)
+		}
+	}
+
+	// A re-run machine translation job updates its drafts in place.
+	run("erste Fassung", "Augenfarbe")
+	run("zweite Fassung", "Farbe der Augen")
+	var rows []*models.Translation
+	if err := db.NewSelect().Model(&rows).OrderExpr("t.id").Scan(ctx); err != nil {
+		t.Fatalf("read translations: %v", err)
+	}
+	if len(rows) != 2 || rows[0].TranslatedText != "zweite Fassung" {
+		t.Fatalf("expected the draft updated in place, got %+v", rows)
+	}
+
+	// It does not replace a translation a person reviewed.
+	if _, err := ReviewTranslation(ctx, db, rows[0].ID, models.TranslationReviewed, "anna"); err != nil {
+		t.Fatalf("review: %v", err)
+	}
+	run("dritte Fassung", "Augenfarbe")
+	got, err := GetTranslations(ctx, db, "de", []int64{snp.ID})
+	if err != nil {
+		t.Fatalf("get translations: %v", err)
+	}
+	if s := got.SNPField(snp.ID, models.TranslationSummary); s != "zweite Fassung" {
+		t.Errorf("expected the reviewed summary kept, got %q", s)
+	}
+	if n := got.Phenotypes[phenotype.ID]; n != "Augenfarbe" {
+		t.Errorf("expected the phenotype draft updated, got %q", n)
+	}
+	var names int
+	if names, err = db.NewSelect().Model((*models.PhenotypeTranslation)(nil)).Count(ctx); err != nil || names != 1 {
+		t.Errorf("expected one phenotype translation, got %d (%v)", names, err)
+	}
+}
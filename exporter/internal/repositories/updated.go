@@ -0,0 +1,67 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/uptrace/bun"
+
+	"github.com/mkoziy/genome/exporter/internal/models"
+)
+
+// updatedChildTables are the tables whose rows' updated_at marks their SNP
+// as changed.
+var updatedChildTables = []string{
+	"snp_clinical",
+	"snp_phenotypes",
+	"snp_references",
+	"snp_populations",
+	"risk_alleles",
+	"genotype_effects",
+	"snp_translations",
+}
+
+// GetSNPsUpdatedSince returns one keyset page of SNPs, with all relations, that
+// changed after since: the SNP row itself was updated, its score was
+// recalculated, or one of its annotations, frequencies, risk alleles or
+// translations was added or updated.
+//
+// Consumers syncing a local copy should record the time before the first call,
+// page with the same since until Next is nil, and use the recorded time as since
+// for the following sync.
+func GetSNPsUpdatedSince(ctx context.Context, db *bun.DB, since time.Time, page KeysetPage) (*SNPCursorPage, error) {
+	limit := page.limit()
+	since = since.UTC()
+
+	var snps []*models.SNP
+	err := db.NewSelect().
+		Model(&snps).
+		Relation("Significance").
+		Relation("ClinicalData").
+		Relation("Phenotypes").
+		Relation("References").
+		Relation("PopulationData").
+		Where("s.id > ?", page.After.AfterID).
+		WhereGroup(" AND ", func(q *bun.SelectQuery) *bun.SelectQuery {
+			q = q.
+				Where("s.updated_at > ?", since).
+				WhereOr("EXISTS (SELECT 1 FROM snp_significance AS us WHERE us.snp_id = s.id AND us.calculated_at > ?)", since)
+			for _, table := range updatedChildTables {
+				q = q.WhereOr("EXISTS (SELECT 1 FROM ? AS uc WHERE uc.snp_id = s.id AND uc.updated_at > ?)", bun.Ident(table), since)
+			}
+			return q
+		}).
+		OrderExpr("s.id ASC").
+		Limit(limit + 1).
+		Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &SNPCursorPage{SNPs: snps}
+	if len(snps) > limit {
+		result.SNPs = snps[:limit]
+		result.Next = &Cursor{AfterID: result.SNPs[limit-1].ID}
+	}
+	return result, nil
+}
@@ -0,0 +1,67 @@
+package repositories
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mkoziy/genome/exporter/internal/models"
+)
+
+func TestGetSNPsUpdatedSince(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+
+	snps := []*models.SNP{testSNP("rs1", "1", 100), testSNP("rs2", "1", 200), testSNP("rs3", "1", 300), testSNP("rs4", "1", 400)}
+	if err := UpsertSNPs(ctx, db, snps); err != nil {
+		t.Fatalf("upsert: %v", err)
+	}
+	if _, err := db.ExecContext(ctx, "UPDATE snps SET updated_at = '2020-01-01 00:00:00' WHERE rsid IN ('rs1', 'rs2')"); err != nil {
+		t.Fatalf("backdate: %v", err)
+	}
+	// rs2 is unchanged itself but was rescored.
+	if _, err := db.NewInsert().Model(&models.Significance{SNPID: snps[1].ID, TotalScore: 50}).Exec(ctx); err != nil {
+		t.Fatalf("insert significance: %v", err)
+	}
+	// rs1 is unchanged itself but one of its frequencies was updated, which
+	// moves the frequency's updated_at through its hook.
+	freq := &models.PopulationFreq{SNPID: snps[0].ID, PopulationCode: "EUR", Allele: "T", Frequency: 0.2, Source: models.SourceGnomAD}
+	if _, err := db.NewInsert().Model(freq).Exec(ctx); err != nil {
+		t.Fatalf("insert frequency: %v", err)
+	}
+	if _, err := db.ExecContext(ctx, "UPDATE snp_populations SET updated_at = '2020-01-01 00:00:00'"); err != nil {
+		t.Fatalf("backdate frequency: %v", err)
+	}
+	freq.Frequency = 0.25
+	if _, err := db.NewUpdate().Model(freq).WherePK().Exec(ctx); err != nil {
+		t.Fatalf("update frequency: %v", err)
+	}
+
+	since := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	first, err := GetSNPsUpdatedSince(ctx, db, since, KeysetPage{Limit: 2})
+	if err != nil {
+		t.Fatalf("first page: %v", err)
+	}
+	if got := rsIDs(first.SNPs); len(got) != 2 || got[0] != "rs1" || got[1] != "rs2" || first.Next == nil {
+		t.Fatalf("unexpected first page: %v next=%v", got, first.Next)
+	}
+	if first.SNPs[1].Significance == nil {
+		t.Fatalf("expected relations to be loaded")
+	}
+
+	second, err := GetSNPsUpdatedSince(ctx, db, since, KeysetPage{Limit: 2, After: *first.Next})
+	if err != nil {
+		t.Fatalf("second page: %v", err)
+	}
+	if got := rsIDs(second.SNPs); len(got) != 2 || got[0] != "rs3" || got[1] != "rs4" || second.Next != nil {
+		t.Fatalf("unexpected second page: %v next=%v", got, second.Next)
+	}
+
+	none, err := GetSNPsUpdatedSince(ctx, db, time.Now().Add(time.Hour), KeysetPage{})
+	if err != nil {
+		t.Fatalf("future: %v", err)
+	}
+	if len(none.SNPs) != 0 {
+		t.Fatalf("expected no SNPs updated in the future, got %v", rsIDs(none.SNPs))
+	}
+}
@@ -0,0 +1,243 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/uptrace/bun"
+
+	"github.com/mkoziy/genome/exporter/internal/models"
+)
+
+// UpsertClinicalData inserts clinical rows, updating existing ones with the
+// same natural key (models.ClinicalData.NaturalKey), including the condition
+// name a source has renamed.
+func UpsertClinicalData(ctx context.Context, db bun.IDB, rows []*models.ClinicalData) error {
+	if len(rows) == 0 {
+		return nil
+	}
+
+	_, err := db.NewInsert().
+		Model(&rows).
+		On("CONFLICT (" + models.ClinicalNaturalKey + ") DO UPDATE").
+		Set("clinical_significance = EXCLUDED.clinical_significance").
+		Set("review_status = EXCLUDED.review_status").
+		Set("condition_name = EXCLUDED.condition_name").
+		Set("condition_id = EXCLUDED.condition_id").
+		Set("inheritance_pattern = EXCLUDED.inheritance_pattern").
+		Set("penetrance = EXCLUDED.penetrance").
+		Set("allele_origin = EXCLUDED.allele_origin").
+		Set("source_id = EXCLUDED.source_id").
+		Set("last_evaluated = EXCLUDED.last_evaluated").
+		Set("updated_at = CURRENT_TIMESTAMP").
+		Exec(ctx)
+
+	return err
+}
+
+// UpsertReferences inserts references, updating existing ones matched on (snp_id, pubmed_id).
+// References without a PubMed ID have no natural key and are always inserted.
+func UpsertReferences(ctx context.Context, db bun.IDB, rows []*models.Reference) error {
+	if len(rows) == 0 {
+		return nil
+	}
+
+	_, err := db.NewInsert().
+		Model(&rows).
+		On("CONFLICT (snp_id, pubmed_id) DO UPDATE").
+		Set("title = COALESCE(EXCLUDED.title, title)").
+		Set("authors = COALESCE(EXCLUDED.authors, authors)").
+		Set("journal = COALESCE(EXCLUDED.journal, journal)").
+		Set("publication_year = COALESCE(EXCLUDED.publication_year, publication_year)").
+		Set("doi = COALESCE(EXCLUDED.doi, doi)").
+		Set("url = COALESCE(EXCLUDED.url, url)").
+		Set("citation_count = MAX(EXCLUDED.citation_count, citation_count)").
+		Set("abstract = COALESCE(EXCLUDED.abstract, abstract)").
+		Set("updated_at = CURRENT_TIMESTAMP").
+		Exec(ctx)
+
+	return err
+}
+
+// UpsertPhenotypes inserts phenotypes, updating existing ones matched on
+// (snp_id, source, phenotype_name).
+func UpsertPhenotypes(ctx context.Context, db bun.IDB, rows []*models.Phenotype) error {
+	if len(rows) == 0 {
+		return nil
+	}
+
+	_, err := db.NewInsert().
+		Model(&rows).
+		On("CONFLICT (snp_id, source, phenotype_name) DO UPDATE").
+		Set("phenotype_id = EXCLUDED.phenotype_id").
+		Set("association_type = EXCLUDED.association_type").
+		Set("odds_ratio = EXCLUDED.odds_ratio").
+		Set("confidence_interval = EXCLUDED.confidence_interval").
+		Set("p_value = EXCLUDED.p_value").
+		Set("study_type = EXCLUDED.study_type").
+		Set("updated_at = CURRENT_TIMESTAMP").
+		Exec(ctx)
+
+	return err
+}
+
+// UpsertPopulationFreqs inserts frequencies, updating existing ones matched on
+// (snp_id, source, population_code, allele).
+func UpsertPopulationFreqs(ctx context.Context, db bun.IDB, rows []*models.PopulationFreq) error {
+	if len(rows) == 0 {
+		return nil
+	}
+
+	_, err := db.NewInsert().
+		Model(&rows).
+		On("CONFLICT (snp_id, source, population_code, allele) DO UPDATE").
+		Set("population_name = EXCLUDED.population_name").
+		Set("frequency = EXCLUDED.frequency").
+		Set("allele_count = EXCLUDED.allele_count").
+		Set("allele_number = EXCLUDED.allele_number").
+		Set("homozygote_count = EXCLUDED.homozygote_count").
+		Set("heteroplasmic_count = EXCLUDED.heteroplasmic_count").
+		Set("max_heteroplasmy = EXCLUDED.max_heteroplasmy").
+		Set("updated_at = CURRENT_TIMESTAMP").
+		Exec(ctx)
+
+	return err
+}
+
+// UpsertRiskAlleles inserts risk alleles, updating existing ones matched on
+// (snp_id, source, allele, condition_name).
+func UpsertRiskAlleles(ctx context.Context, db bun.IDB, rows []*models.RiskAllele) error {
+	if len(rows) == 0 {
+		return nil
+	}
+
+	_, err := db.NewInsert().
+		Model(&rows).
+		On("CONFLICT (snp_id, source, allele, condition_name) DO UPDATE").
+		Set("effect = EXCLUDED.effect").
+		Set("direction = EXCLUDED.direction").
+		Set("inheritance = EXCLUDED.inheritance").
+		Set("odds_ratio = EXCLUDED.odds_ratio").
+		Set("updated_at = CURRENT_TIMESTAMP").
+		Exec(ctx)
+
+	return err
+}
+
+// UpsertGenotypeEffects inserts genotype effects, updating existing ones
+// matched on (snp_id, source, genotype, condition_name).
+func UpsertGenotypeEffects(ctx context.Context, db bun.IDB, rows []*models.GenotypeEffect) error {
+	if len(rows) == 0 {
+		return nil
+	}
+
+	_, err := db.NewInsert().
+		Model(&rows).
+		On("CONFLICT (snp_id, source, genotype, condition_name) DO UPDATE").
+		Set("effect = EXCLUDED.effect").
+		Set("direction = EXCLUDED.direction").
+		Set("magnitude = EXCLUDED.magnitude").
+		Set("updated_at = CURRENT_TIMESTAMP").
+		Exec(ctx)
+
+	return err
+}
+
+// UpsertClinicalAgreements inserts clinical agreements, updating existing
+// ones matched on (snp_id, source, condition_name).
+func UpsertClinicalAgreements(ctx context.Context, db bun.IDB, rows []*models.ClinicalAgreement) error {
+	if len(rows) == 0 {
+		return nil
+	}
+
+	_, err := db.NewInsert().
+		Model(&rows).
+		On("CONFLICT (snp_id, source, condition_name) DO UPDATE").
+		Set("condition_id = EXCLUDED.condition_id").
+		Set("pathogenic_count = EXCLUDED.pathogenic_count").
+		Set("benign_count = EXCLUDED.benign_count").
+		Set("uncertain_count = EXCLUDED.uncertain_count").
+		Set("other_count = EXCLUDED.other_count").
+		Set("majority_call = EXCLUDED.majority_call").
+		Set("agreement = EXCLUDED.agreement").
+		Set("conflicting = EXCLUDED.conflicting").
+		Set("updated_at = CURRENT_TIMESTAMP").
+		Exec(ctx)
+
+	return err
+}
+
+// UpsertTranslations inserts SNP field translations, replacing existing ones
+// matched on (snp_id, language_code, field_name) unless those are further
+// through review, so re-running a machine translation job leaves reviewed
+// and verified translations alone.
+func UpsertTranslations(ctx context.Context, db bun.IDB, rows []*models.Translation) error {
+	if len(rows) == 0 {
+		return nil
+	}
+
+	_, err := db.NewInsert().
+		Model(&rows).
+		On("CONFLICT (snp_id, language_code, field_name) DO UPDATE").
+		Set("translated_text = EXCLUDED.translated_text").
+		Set("translator = EXCLUDED.translator").
+		Set("translated_at = EXCLUDED.translated_at").
+		Set("verified = EXCLUDED.verified").
+		Set("status = EXCLUDED.status").
+		Set("reviewer = EXCLUDED.reviewer").
+		Set("reviewed_at = EXCLUDED.reviewed_at").
+		Set("verified_at = EXCLUDED.verified_at").
+		Set("updated_at = CURRENT_TIMESTAMP").
+		Where(statusRank("EXCLUDED") + " >= " + statusRank("t")).
+		Exec(ctx)
+
+	return err
+}
+
+// UpsertPhenotypeTranslations inserts phenotype name translations, replacing
+// existing ones matched on (phenotype_id, language_code) like
+// UpsertTranslations.
+func UpsertPhenotypeTranslations(ctx context.Context, db bun.IDB, rows []*models.PhenotypeTranslation) error {
+	if len(rows) == 0 {
+		return nil
+	}
+
+	_, err := db.NewInsert().
+		Model(&rows).
+		On("CONFLICT (phenotype_id, language_code) DO UPDATE").
+		Set("translated_name = EXCLUDED.translated_name").
+		Set("translator = EXCLUDED.translator").
+		Set("translated_at = EXCLUDED.translated_at").
+		Set("verified = EXCLUDED.verified").
+		Set("status = EXCLUDED.status").
+		Set("reviewer = EXCLUDED.reviewer").
+		Set("reviewed_at = EXCLUDED.reviewed_at").
+		Set("verified_at = EXCLUDED.verified_at").
+		Where(statusRank("EXCLUDED") + " >= " + statusRank("pt")).
+		Exec(ctx)
+
+	return err
+}
+
+// UpsertMessageTranslations inserts message translations, replacing existing
+// ones matched on (message_key, language_code) like UpsertTranslations.
+func UpsertMessageTranslations(ctx context.Context, db bun.IDB, rows []*models.MessageTranslation) error {
+	if len(rows) == 0 {
+		return nil
+	}
+
+	_, err := db.NewInsert().
+		Model(&rows).
+		On("CONFLICT (message_key, language_code) DO UPDATE").
+		Set("translated_text = EXCLUDED.translated_text").
+		Set("translator = EXCLUDED.translator").
+		Set("translated_at = EXCLUDED.translated_at").
+		Set("verified = EXCLUDED.verified").
+		Set("status = EXCLUDED.status").
+		Set("reviewer = EXCLUDED.reviewer").
+		Set("reviewed_at = EXCLUDED.reviewed_at").
+		Set("verified_at = EXCLUDED.verified_at").
+		Where(statusRank("EXCLUDED") + " >= " + statusRank("mt")).
+		Exec(ctx)
+
+	return err
+}
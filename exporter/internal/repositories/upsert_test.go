@@ -0,0 +1,125 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/mkoziy/genome/exporter/internal/models"
+)
+
+func TestUpsertChildRowsAreIdempotent(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+
+	snp := testSNP("rs1", "1", 1)
+	if err := UpsertSNPs(ctx, db, []*models.SNP{snp}); err != nil {
+		t.Fatalf("upsert snp: %v", err)
+	}
+	pmid := "12345"
+	title := "A study"
+
+	for run := 0; run < 2; run++ {
+		significance := models.ClinicalUncertainSignif
+		if run == 1 {
+			significance = models.ClinicalPathogenic
+		}
+		clinical := []*models.ClinicalData{{SNPID: snp.ID, ClinicalSignificance: significance, ReviewStatus: models.ReviewCriteriaProvided, ConditionName: "X", Source: models.SourceClinVar}}
+		if err := UpsertClinicalData(ctx, db, clinical); err != nil {
+			t.Fatalf("upsert clinical: %v", err)
+		}
+
+		ref := &models.Reference{SNPID: snp.ID, PubmedID: &pmid, CitationCount: 10 * run}
+		if run == 0 {
+			ref.Title = &title
+		}
+		if err := UpsertReferences(ctx, db, []*models.Reference{ref}); err != nil {
+			t.Fatalf("upsert references: %v", err)
+		}
+
+		phenotypes := []*models.Phenotype{{SNPID: snp.ID, PhenotypeName: "Height", AssociationType: "gwas", Source: models.SourceOpenSNP}}
+		if err := UpsertPhenotypes(ctx, db, phenotypes); err != nil {
+			t.Fatalf("upsert phenotypes: %v", err)
+		}
+
+		pops := []*models.PopulationFreq{{SNPID: snp.ID, PopulationCode: "EUR", Allele: "T", Frequency: 0.1 * float64(run+1), Source: models.SourceGnomAD}}
+		if err := UpsertPopulationFreqs(ctx, db, pops); err != nil {
+			t.Fatalf("upsert populations: %v", err)
+		}
+	}
+
+	got, err := GetSNPByRsID(ctx, db, "rs1")
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if len(got.ClinicalData) != 1 || got.ClinicalData[0].ClinicalSignificance != models.ClinicalPathogenic {
+		t.Fatalf("expected single updated clinical row, got %+v", got.ClinicalData)
+	}
+	if len(got.References) != 1 || got.References[0].CitationCount != 10 || got.References[0].Title == nil {
+		t.Fatalf("expected merged reference, got %+v", got.References)
+	}
+	if len(got.Phenotypes) != 1 {
+		t.Fatalf("expected single phenotype, got %d", len(got.Phenotypes))
+	}
+	if len(got.PopulationData) != 1 || got.PopulationData[0].Frequency != 0.2 {
+		t.Fatalf("expected updated frequency, got %+v", got.PopulationData)
+	}
+}
+
+func TestUpsertRefusesUnknownEnumValues(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+
+	snp := testSNP("rs1", "1", 1)
+	if err := UpsertSNPs(ctx, db, []*models.SNP{snp}); err != nil {
+		t.Fatalf("upsert snp: %v", err)
+	}
+	clinical := []*models.ClinicalData{{SNPID: snp.ID, ClinicalSignificance: "Pathogenic", ReviewStatus: models.ReviewCriteriaProvided, ConditionName: "X", Source: models.SourceClinVar}}
+	if err := UpsertClinicalData(ctx, db, clinical); !errors.Is(err, models.ErrUnknownValue) {
+		t.Fatalf("expected the unknown significance refused, got %v", err)
+	}
+
+	// Values written around the models surface when read.
+	if _, err := db.ExecContext(ctx, "UPDATE snps SET variant_type = 'SNP'"); err != nil {
+		t.Fatalf("corrupt variant type: %v", err)
+	}
+	if _, err := GetSNPByRsID(ctx, db, "rs1"); !errors.Is(err, models.ErrUnknownValue) {
+		t.Fatalf("expected the stored variant type reported, got %v", err)
+	}
+}
+
+func TestUpsertClinicalDataNaturalKey(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+
+	snp := testSNP("rs1", "1", 1)
+	if err := UpsertSNPs(ctx, db, []*models.SNP{snp}); err != nil {
+		t.Fatalf("upsert snp: %v", err)
+	}
+	rcv1, rcv2, medgen := "RCV1", "RCV2", "MedGen:C1"
+	assertion := func(sourceID *string, name string) *models.ClinicalData {
+		return &models.ClinicalData{SNPID: snp.ID, ClinicalSignificance: models.ClinicalPathogenic, ReviewStatus: models.ReviewCriteriaProvided,
+			ConditionName: name, ConditionID: &medgen, Source: models.SourceClinVar, SourceID: sourceID}
+	}
+
+	// Each record of the source is an assertion of its own.
+	if err := UpsertClinicalData(ctx, db, []*models.ClinicalData{assertion(&rcv1, "Old name"), assertion(&rcv2, "Old name")}); err != nil {
+		t.Fatalf("upsert: %v", err)
+	}
+	// A renamed condition is still the same assertion.
+	if err := UpsertClinicalData(ctx, db, []*models.ClinicalData{assertion(&rcv1, "New name")}); err != nil {
+		t.Fatalf("upsert renamed: %v", err)
+	}
+
+	got, err := GetSNPByRsID(ctx, db, "rs1")
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	names := make(map[string]string)
+	for _, c := range got.ClinicalData {
+		names[*c.SourceID] = c.ConditionName
+	}
+	if len(got.ClinicalData) != 2 || names["RCV1"] != "New name" || names["RCV2"] != "Old name" {
+		t.Fatalf("expected the RCV1 assertion renamed beside RCV2, got %v", names)
+	}
+}
@@ -0,0 +1,156 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/uptrace/bun"
+
+	"github.com/mkoziy/genome/exporter/internal/models"
+)
+
+// canonicalSNPs returns the SNP to upsert for each SNP of chunk: the SNP itself,
+// or, when it is stored under another rsID (see canonicalRsIDs), a copy of the
+// row it merges into, so the stored representation of the variant is kept.
+// A gene symbol the row lacks is taken from the merged SNP.
+func canonicalSNPs(ctx context.Context, db bun.IDB, chunk []models.SNPData) ([]*models.SNP, error) {
+	targets, err := canonicalRsIDs(ctx, db, chunk)
+	if err != nil {
+		return nil, err
+	}
+
+	base := make(map[string]*models.SNP)
+	var renamed []string
+	for i, data := range chunk {
+		if targets[i] == data.SNP.RsID {
+			if _, ok := base[data.SNP.RsID]; !ok {
+				base[data.SNP.RsID] = data.SNP
+			}
+		} else {
+			renamed = append(renamed, targets[i])
+		}
+	}
+	if len(renamed) > 0 {
+		// canonicalRsIDs resolves to retracted SNPs too, so they are loaded
+		// like any other.
+		var stored []*models.SNP
+		if err := db.NewSelect().
+			Model(&stored).
+			Where("s.rsid IN (?)", bun.In(renamed)).
+			WhereAllWithDeleted().
+			Scan(ctx); err != nil {
+			return nil, fmt.Errorf("load merge targets: %w", err)
+		}
+		for _, snp := range stored {
+			base[snp.RsID] = snp
+		}
+	}
+
+	snps := make([]*models.SNP, len(chunk))
+	for i, data := range chunk {
+		if targets[i] == data.SNP.RsID {
+			snps[i] = data.SNP
+			continue
+		}
+		snp := *data.SNP
+		if b := base[targets[i]]; b != nil {
+			snp = *b
+		}
+		snp.ID, snp.RsID = 0, targets[i]
+		if !snp.HasGene() {
+			snp.GeneSymbol = data.SNP.GeneSymbol
+		}
+		snps[i] = &snp
+	}
+	return snps, nil
+}
+
+// canonicalRsIDs returns the rsID each SNP of chunk is stored under, so the
+// same variant arriving from several sources, or under several rsIDs, lands
+// on one row. That is the SNP's own rsID if it is already stored, the SNP an
+// alias of it was merged into, the stored SNP with the same variant key, or
+// else the first SNP of the chunk with that key.
+func canonicalRsIDs(ctx context.Context, db bun.IDB, chunk []models.SNPData) ([]string, error) {
+	rsIDs := make([]string, 0, len(chunk))
+	keys := make([]string, 0, len(chunk))
+	for _, data := range chunk {
+		rsIDs = append(rsIDs, data.SNP.RsID)
+		if key := data.SNP.CanonicalKey(); key != nil {
+			keys = append(keys, *key)
+		}
+	}
+
+	// Retracted SNPs keep their rsIDs and variant keys, and what arrives for
+	// them stays retracted with them.
+	var stored []string
+	if err := db.NewSelect().
+		Model((*models.SNP)(nil)).
+		Column("rsid").
+		Where("rsid IN (?)", bun.In(rsIDs)).
+		WhereAllWithDeleted().
+		Scan(ctx, &stored); err != nil {
+		return nil, fmt.Errorf("load stored rsIDs: %w", err)
+	}
+	isStored := make(map[string]bool, len(stored))
+	for _, rsID := range stored {
+		isStored[rsID] = true
+	}
+
+	var aliases []struct {
+		Alias  string `bun:"alias"`
+		Target string `bun:"target"`
+	}
+	if err := db.NewSelect().
+		Model((*models.SNPAlias)(nil)).
+		ColumnExpr("sa.rsid AS alias, s.rsid AS target").
+		Join("JOIN snps AS s ON s.id = sa.snp_id").
+		Where("sa.rsid IN (?)", bun.In(rsIDs)).
+		Scan(ctx, &aliases); err != nil {
+		return nil, fmt.Errorf("load aliases: %w", err)
+	}
+	aliasOf := make(map[string]string, len(aliases))
+	for _, a := range aliases {
+		aliasOf[a.Alias] = a.Target
+	}
+
+	owner := make(map[string]string)
+	if len(keys) > 0 {
+		var owners []*models.SNP
+		if err := db.NewSelect().
+			Model(&owners).
+			Column("rsid", "variant_key").
+			Where("variant_key IN (?)", bun.In(keys)).
+			WhereAllWithDeleted().
+			Scan(ctx); err != nil {
+			return nil, fmt.Errorf("load variant keys: %w", err)
+		}
+		// Duplicates left from before keys were enforced resolve to the rsID
+		// MergeDuplicates would keep.
+		for _, snp := range owners {
+			key := *snp.VariantKey
+			if cur, ok := owner[key]; !ok || rsNumber(snp.RsID) < rsNumber(cur) {
+				owner[key] = snp.RsID
+			}
+		}
+	}
+
+	targets := make([]string, len(chunk))
+	for i, data := range chunk {
+		rsID := data.SNP.RsID
+		key := data.SNP.CanonicalKey()
+		switch {
+		case isStored[rsID]:
+			targets[i] = rsID
+		case aliasOf[rsID] != "":
+			targets[i] = aliasOf[rsID]
+		case key != nil && owner[*key] != "":
+			targets[i] = owner[*key]
+		default:
+			targets[i] = rsID
+			if key != nil {
+				owner[*key] = rsID
+			}
+		}
+	}
+	return targets, nil
+}
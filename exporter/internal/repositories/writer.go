@@ -0,0 +1,343 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/uptrace/bun"
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/mkoziy/genome/exporter/internal/models"
+	"github.com/mkoziy/genome/exporter/internal/tracing"
+)
+
+// BatchWriterConfig controls chunking and retries of a BatchWriter.
+type BatchWriterConfig struct {
+	ChunkSize int `yaml:"chunk_size" json:"chunk_size"`
+	// BulkChunkSize replaces ChunkSize during a bulk load, where larger
+	// transactions pay off since no indexes but the unique ones are updated.
+	BulkChunkSize  int           `yaml:"bulk_chunk_size" json:"bulk_chunk_size"`
+	MaxRetries     int           `yaml:"max_retries" json:"max_retries"`
+	InitialBackoff time.Duration `yaml:"initial_backoff" json:"initial_backoff"`
+}
+
+// DefaultBatchWriterConfig returns sensible defaults for full loads.
+func DefaultBatchWriterConfig() BatchWriterConfig {
+	return BatchWriterConfig{
+		ChunkSize:      1000,
+		BulkChunkSize:  20000,
+		MaxRetries:     3,
+		InitialBackoff: 100 * time.Millisecond,
+	}
+}
+
+// BatchWriteStats summarises a BatchWriter run.
+type BatchWriteStats struct {
+	SNPs int `json:"snps"`
+	// Quarantined counts bundles that failed validation and were stored in
+	// the quarantine table instead.
+	Quarantined int `json:"quarantined"`
+	Chunks      int `json:"chunks"`
+	Retries     int `json:"retries"`
+}
+
+// BatchWriter upserts SNP bundles received on a channel, committing one
+// transaction per chunk instead of one per SNP. Bundles failing
+// models.SNPData.Validate are quarantined in the same transaction.
+type BatchWriter struct {
+	db       txRunner
+	cfg      BatchWriterConfig
+	onCommit func(BatchWriteStats)
+	runID    *string
+}
+
+// NewBatchWriter creates a writer; zero config fields fall back to defaults.
+func NewBatchWriter(db *bun.DB, cfg BatchWriterConfig) *BatchWriter {
+	def := DefaultBatchWriterConfig()
+	if cfg.ChunkSize <= 0 {
+		cfg.ChunkSize = def.ChunkSize
+	}
+	if cfg.BulkChunkSize <= 0 {
+		cfg.BulkChunkSize = def.BulkChunkSize
+	}
+	if cfg.MaxRetries <= 0 {
+		cfg.MaxRetries = def.MaxRetries
+	}
+	if cfg.InitialBackoff <= 0 {
+		cfg.InitialBackoff = def.InitialBackoff
+	}
+	return &BatchWriter{db: db, cfg: cfg}
+}
+
+// txRunner starts the transactions chunks are written in: a *bun.DB, or the
+// bun.Conn a bulk load pinned.
+type txRunner interface {
+	RunInTx(ctx context.Context, opts *sql.TxOptions, fn func(ctx context.Context, tx bun.Tx) error) error
+}
+
+// WithBulkLoad writes chunks of BulkChunkSize on conn, the connection of a
+// database.BulkLoad.
+func (w *BatchWriter) WithBulkLoad(conn bun.Conn) *BatchWriter {
+	w.db = conn
+	w.cfg.ChunkSize = w.cfg.BulkChunkSize
+	return w
+}
+
+// ChunkSize is how many bundles the writer commits per transaction.
+func (w *BatchWriter) ChunkSize() int {
+	return w.cfg.ChunkSize
+}
+
+// OnCommit registers fn to be called with the running totals after each chunk
+// commits, e.g. to persist a resume point only once the data behind it is written.
+func (w *BatchWriter) OnCommit(fn func(BatchWriteStats)) *BatchWriter {
+	w.onCommit = fn
+	return w
+}
+
+// WithRunID tags quarantined records with the run that received them.
+func (w *BatchWriter) WithRunID(runID string) *BatchWriter {
+	if runID != "" {
+		w.runID = &runID
+	}
+	return w
+}
+
+// Run consumes in until it is closed, writing full chunks as they fill and the
+// remainder at the end. A chunk that still fails after MaxRetries stops the run;
+// the caller should then cancel ctx so producers do not block on the channel.
+//
+// When ctx is cancelled, Run still commits the partial chunk and whatever is
+// already waiting on in, so a shutdown keeps everything received, then returns
+// ctx's error.
+func (w *BatchWriter) Run(ctx context.Context, in <-chan models.SNPData) (BatchWriteStats, error) {
+	var stats BatchWriteStats
+	chunk := make([]models.SNPData, 0, w.cfg.ChunkSize)
+	writeCtx := ctx
+
+	flush := func() error {
+		if len(chunk) == 0 {
+			return nil
+		}
+		valid, quarantined, err := w.validate(ctx, chunk)
+		if err != nil {
+			return err
+		}
+		retries, err := w.writeChunk(writeCtx, valid, quarantined)
+		stats.Retries += retries
+		if err != nil {
+			return fmt.Errorf("write chunk %d (%d SNPs): %w", stats.Chunks+1, len(chunk), err)
+		}
+		stats.Chunks++
+		stats.SNPs += len(valid)
+		stats.Quarantined += len(quarantined)
+		chunk = chunk[:0]
+		if w.onCommit != nil {
+			w.onCommit(stats)
+		}
+		return nil
+	}
+
+	add := func(data models.SNPData) error {
+		if data.SNP == nil {
+			return nil
+		}
+		chunk = append(chunk, data)
+		if len(chunk) >= w.cfg.ChunkSize {
+			return flush()
+		}
+		return nil
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			writeCtx = context.WithoutCancel(ctx)
+			for {
+				select {
+				case data, ok := <-in:
+					if ok {
+						if err := add(data); err != nil {
+							return stats, err
+						}
+						continue
+					}
+				default:
+				}
+				if err := flush(); err != nil {
+					return stats, err
+				}
+				return stats, ctx.Err()
+			}
+		case data, ok := <-in:
+			if !ok {
+				return stats, flush()
+			}
+			if err := add(data); err != nil {
+				return stats, err
+			}
+		}
+	}
+}
+
+// validate splits chunk into the bundles to write and quarantine records for
+// the rest.
+func (w *BatchWriter) validate(ctx context.Context, chunk []models.SNPData) ([]models.SNPData, []*models.QuarantinedRecord, error) {
+	valid := make([]models.SNPData, 0, len(chunk))
+	var quarantined []*models.QuarantinedRecord
+	for _, data := range chunk {
+		verr := data.Validate()
+		if verr == nil {
+			valid = append(valid, data)
+			continue
+		}
+		rec, err := quarantineRecord(data, w.runID, verr)
+		if err != nil {
+			return nil, nil, err
+		}
+		slog.WarnContext(ctx, "Record quarantined", "record", rec.RecordID, "record_source", rec.Source, "error", verr)
+		quarantined = append(quarantined, rec)
+	}
+	return valid, quarantined, nil
+}
+
+// writeChunk writes chunk and quarantines records in one transaction, retrying
+// with exponential backoff.
+func (w *BatchWriter) writeChunk(ctx context.Context, chunk []models.SNPData, quarantined []*models.QuarantinedRecord) (retries int, err error) {
+	ctx, span := tracing.Start(ctx, "write.chunk", attribute.Int("snps", len(chunk)), attribute.Int("quarantined", len(quarantined)))
+	defer func() {
+		span.SetAttributes(attribute.Int("retries", retries))
+		tracing.End(span, err)
+	}()
+
+	backoff := w.cfg.InitialBackoff
+	for attempt := 0; ; attempt++ {
+		err := w.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+			for _, rec := range quarantined {
+				// IDs may be left over from a rolled back attempt.
+				rec.ID = 0
+			}
+			if err := QuarantineRecords(ctx, tx, quarantined); err != nil {
+				return fmt.Errorf("quarantine: %w", err)
+			}
+			return writeSNPData(ctx, tx, chunk)
+		})
+		if err == nil || attempt >= w.cfg.MaxRetries || ctx.Err() != nil {
+			return attempt, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return attempt, ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// writeSNPData upserts the SNPs of chunk and then their child rows. A SNP whose
+// variant is already stored under another rsID is merged into that row, with
+// its rsID recorded as an alias.
+func writeSNPData(ctx context.Context, db bun.IDB, chunk []models.SNPData) error {
+	if len(chunk) == 0 {
+		return nil
+	}
+	for _, data := range chunk {
+		// IDs may be left over from a rolled back attempt.
+		data.SNP.ID = 0
+	}
+	snps, err := canonicalSNPs(ctx, db, chunk)
+	if err != nil {
+		return err
+	}
+	if err := UpsertSNPs(ctx, db, snps); err != nil {
+		return fmt.Errorf("upsert snps: %w", err)
+	}
+
+	var aliases []*models.SNPAlias
+	for i, data := range chunk {
+		data.SNP.ID = snps[i].ID
+		if snps[i] != data.SNP {
+			aliases = append(aliases, &models.SNPAlias{RsID: data.SNP.RsID, SNPID: snps[i].ID, Reason: models.AliasReasonVariantKey})
+		}
+	}
+	if len(aliases) > 0 {
+		if _, err := db.NewInsert().Model(&aliases).On("CONFLICT (rsid) DO NOTHING").Exec(ctx); err != nil {
+			return fmt.Errorf("record aliases: %w", err)
+		}
+	}
+
+	var (
+		clinical    []*models.ClinicalData
+		references  []*models.Reference
+		phenotypes  []*models.Phenotype
+		populations []*models.PopulationFreq
+		risks       []*models.RiskAllele
+		effects     []*models.GenotypeEffect
+		agreements  []*models.ClinicalAgreement
+	)
+	for _, data := range chunk {
+		snpID := data.SNP.ID
+		for i := range data.Clinical {
+			row := data.Clinical[i]
+			row.ID, row.SNPID = 0, snpID
+			clinical = append(clinical, &row)
+		}
+		for i := range data.References {
+			row := data.References[i]
+			row.ID, row.SNPID = 0, snpID
+			references = append(references, &row)
+		}
+		for i := range data.Phenotypes {
+			row := data.Phenotypes[i]
+			row.ID, row.SNPID = 0, snpID
+			phenotypes = append(phenotypes, &row)
+		}
+		for i := range data.PopulationData {
+			row := data.PopulationData[i]
+			row.ID, row.SNPID = 0, snpID
+			populations = append(populations, &row)
+		}
+		for i := range data.RiskAlleles {
+			row := data.RiskAlleles[i]
+			row.ID, row.SNPID = 0, snpID
+			risks = append(risks, &row)
+		}
+		for i := range data.GenotypeEffects {
+			row := data.GenotypeEffects[i]
+			row.ID, row.SNPID = 0, snpID
+			effects = append(effects, &row)
+		}
+		for i := range data.Agreements {
+			row := data.Agreements[i]
+			row.ID, row.SNPID = 0, snpID
+			agreements = append(agreements, &row)
+		}
+	}
+
+	if err := UpsertClinicalData(ctx, db, clinical); err != nil {
+		return fmt.Errorf("upsert clinical: %w", err)
+	}
+	if err := UpsertReferences(ctx, db, references); err != nil {
+		return fmt.Errorf("upsert references: %w", err)
+	}
+	if err := UpsertPhenotypes(ctx, db, phenotypes); err != nil {
+		return fmt.Errorf("upsert phenotypes: %w", err)
+	}
+	if err := UpsertPopulationFreqs(ctx, db, populations); err != nil {
+		return fmt.Errorf("upsert populations: %w", err)
+	}
+	if err := UpsertRiskAlleles(ctx, db, risks); err != nil {
+		return fmt.Errorf("upsert risk alleles: %w", err)
+	}
+	if err := UpsertGenotypeEffects(ctx, db, effects); err != nil {
+		return fmt.Errorf("upsert genotype effects: %w", err)
+	}
+	if err := UpsertClinicalAgreements(ctx, db, agreements); err != nil {
+		return fmt.Errorf("upsert clinical agreements: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,350 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mkoziy/genome/exporter/internal/database"
+	"github.com/mkoziy/genome/exporter/internal/models"
+)
+
+func TestBatchWriterChunksAndUpserts(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+
+	var committed []int
+	send := func() BatchWriteStats {
+		committed = nil
+		in := make(chan models.SNPData)
+		go func() {
+			defer close(in)
+			for i := 1; i <= 5; i++ {
+				in <- models.SNPData{
+					SNP: testSNP(fmt.Sprintf("rs%d", i), "1", int64(i*100)),
+					Clinical: []models.ClinicalData{{
+						ClinicalSignificance: models.ClinicalPathogenic,
+						ReviewStatus:         models.ReviewExpertPanel,
+						ConditionName:        "Condition",
+						Source:               models.SourceClinVar,
+					}},
+					RiskAlleles: []models.RiskAllele{{
+						Allele:        "T",
+						Effect:        string(models.ClinicalPathogenic),
+						ConditionName: "Condition",
+						Source:        models.SourceClinVar,
+					}},
+					GenotypeEffects: []models.GenotypeEffect{{
+						Genotype:      "T/T",
+						Effect:        string(models.ClinicalPathogenic),
+						Direction:     models.EffectIncreased,
+						ConditionName: "Condition",
+						Source:        models.SourceClinVar,
+					}},
+					Agreements: []models.ClinicalAgreement{{
+						ConditionName:   "Condition",
+						PathogenicCount: 2,
+						MajorityCall:    models.ClinicalPathogenic,
+						Agreement:       1,
+						Source:          models.SourceClinVar,
+					}},
+				}
+			}
+		}()
+
+		writer := NewBatchWriter(db, BatchWriterConfig{ChunkSize: 2}).OnCommit(func(stats BatchWriteStats) {
+			committed = append(committed, stats.SNPs)
+		})
+		stats, err := writer.Run(ctx, in)
+		if err != nil {
+			t.Fatalf("run: %v", err)
+		}
+		return stats
+	}
+
+	stats := send()
+	if stats.SNPs != 5 || stats.Chunks != 3 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+	if fmt.Sprint(committed) != "[2 4 5]" {
+		t.Fatalf("expected a commit callback per chunk, got %v", committed)
+	}
+
+	// Writing the same bundles again must update rather than duplicate.
+	send()
+	for table, want := range map[string]int{"snps": 5, "snp_clinical": 5, "risk_alleles": 5, "genotype_effects": 5, "clinical_agreements": 5} {
+		n, err := db.NewSelect().Table(table).Count(ctx)
+		if err != nil {
+			t.Fatalf("count %s: %v", table, err)
+		}
+		if n != want {
+			t.Fatalf("expected %d rows in %s, got %d", want, table, n)
+		}
+	}
+	snp, err := GetSNPByRsID(ctx, db, "rs1")
+	if err != nil || len(snp.RiskAlleles) != 1 || len(snp.GenotypeEffects) != 1 || len(snp.ClinicalAgreements) != 1 {
+		t.Fatalf("expected rs1 loaded with its risk allele, genotype effect and agreement, got %+v (%v)", snp, err)
+	}
+}
+
+func TestBatchWriterRetriesFailedChunk(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+
+	if _, err := db.ExecContext(ctx, `
+		CREATE TRIGGER fail_insert BEFORE INSERT ON snps WHEN NEW.rsid = 'rs666'
+		BEGIN SELECT RAISE(ABORT, 'boom'); END`); err != nil {
+		t.Fatalf("create trigger: %v", err)
+	}
+
+	in := make(chan models.SNPData, 2)
+	in <- models.SNPData{SNP: testSNP("rs1", "1", 100)}
+	in <- models.SNPData{SNP: testSNP("rs666", "1", 200)}
+	close(in)
+
+	w := NewBatchWriter(db, BatchWriterConfig{ChunkSize: 10, MaxRetries: 2, InitialBackoff: time.Millisecond})
+	stats, err := w.Run(ctx, in)
+	if err == nil {
+		t.Fatalf("expected error")
+	}
+	if stats.Retries != 2 || stats.SNPs != 0 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+
+	// The whole chunk is rolled back.
+	n, err := db.NewSelect().Table("snps").Count(ctx)
+	if err != nil {
+		t.Fatalf("count: %v", err)
+	}
+	if n != 0 {
+		t.Fatalf("expected no snps after rollback, got %d", n)
+	}
+}
+
+func TestBatchWriterCommitsReceivedSNPsOnCancel(t *testing.T) {
+	db := newTestDB(t)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	// Two SNPs wait on the channel and one more arrives before the cancel;
+	// none fills a chunk, and all must still be committed.
+	in := make(chan models.SNPData, 2)
+	in <- models.SNPData{SNP: testSNP("rs1", "1", 100)}
+	in <- models.SNPData{SNP: testSNP("rs2", "1", 200)}
+	var committed []int
+	writer := NewBatchWriter(db, BatchWriterConfig{ChunkSize: 10}).OnCommit(func(stats BatchWriteStats) {
+		committed = append(committed, stats.SNPs)
+	})
+	go func() {
+		in <- models.SNPData{SNP: testSNP("rs3", "1", 300)}
+		cancel()
+	}()
+
+	stats, err := writer.Run(ctx, in)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if stats.SNPs != 3 || fmt.Sprint(committed) != "[3]" {
+		t.Fatalf("expected the 3 received SNPs committed once, got %+v %v", stats, committed)
+	}
+	n, err := db.NewSelect().Table("snps").Count(context.Background())
+	if err != nil || n != 3 {
+		t.Fatalf("expected 3 snps written, got %d (%v)", n, err)
+	}
+}
+
+func TestBatchWriterMergesSameVariantUnderDifferentRsIDs(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+
+	if err := UpsertSNPs(ctx, db, []*models.SNP{testSNP("rs100", "1", 100)}); err != nil {
+		t.Fatalf("upsert: %v", err)
+	}
+
+	clinical := func(source models.DataSource) []models.ClinicalData {
+		return []models.ClinicalData{{
+			ConditionName:        "Shared condition",
+			ClinicalSignificance: models.ClinicalPathogenic,
+			ReviewStatus:         models.ReviewCriteriaProvided,
+			Source:               source,
+		}}
+	}
+	// The same variant written with a chr prefix and padding, under a new rsID,
+	// and a new variant arriving twice within one chunk.
+	padded := testSNP("rs900", "chr1", 99)
+	padded.ReferenceAllele, padded.AlternateAlleles = "GCA", models.StringArray{"GTA"}
+	in := make(chan models.SNPData, 3)
+	in <- models.SNPData{SNP: padded, Clinical: clinical(models.SourceDbSNP)}
+	in <- models.SNPData{SNP: testSNP("rs300", "2", 300), Clinical: clinical(models.SourceClinVar)}
+	in <- models.SNPData{SNP: testSNP("rs200", "2", 300), Clinical: clinical(models.SourceDbSNP)}
+	close(in)
+
+	if _, err := NewBatchWriter(db, BatchWriterConfig{ChunkSize: 10}).Run(ctx, in); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+
+	n, err := db.NewSelect().Table("snps").Count(ctx)
+	if err != nil {
+		t.Fatalf("count: %v", err)
+	}
+	if n != 2 {
+		t.Fatalf("expected one row per variant, got %d", n)
+	}
+	for alias, want := range map[string]string{"rs900": "rs100", "rs200": "rs300"} {
+		current, err := ResolveRsID(ctx, db, alias)
+		if err != nil || current != want {
+			t.Fatalf("expected %s to resolve to %s, got %q (%v)", alias, want, current, err)
+		}
+	}
+	kept, err := GetSNPByRsID(ctx, db, "rs100")
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if kept.Chromosome != "1" || kept.Position != 100 || kept.ReferenceAllele != "C" {
+		t.Fatalf("expected the stored representation kept, got %s:%d %s", kept.Chromosome, kept.Position, kept.ReferenceAllele)
+	}
+	merged, err := GetSNPByRsID(ctx, db, "rs300")
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if len(merged.ClinicalData) != 2 {
+		t.Fatalf("expected clinical rows from both sources, got %d", len(merged.ClinicalData))
+	}
+	if merged.VariantKey == nil || *merged.VariantKey != "2:300:C:T" {
+		t.Fatalf("unexpected variant key %v", merged.VariantKey)
+	}
+}
+
+func TestBatchWriterMergesIntoRetractedSNP(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+
+	if err := UpsertSNPs(ctx, db, []*models.SNP{testSNP("rs100", "1", 100)}); err != nil {
+		t.Fatalf("upsert: %v", err)
+	}
+	if _, _, err := RetractSNPs(ctx, db, []string{"rs100"}, models.Retraction{Reason: "withdrawn"}); err != nil {
+		t.Fatalf("retract: %v", err)
+	}
+
+	padded := testSNP("rs900", "chr1", 99)
+	padded.ReferenceAllele, padded.AlternateAlleles = "GCA", models.StringArray{"GTA"}
+	in := make(chan models.SNPData, 1)
+	in <- models.SNPData{SNP: padded}
+	close(in)
+	if _, err := NewBatchWriter(db, BatchWriterConfig{ChunkSize: 10}).Run(ctx, in); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+
+	var kept []*models.SNP
+	if err := db.NewSelect().Model(&kept).WhereAllWithDeleted().Scan(ctx); err != nil {
+		t.Fatalf("select: %v", err)
+	}
+	if len(kept) != 1 || kept[0].RsID != "rs100" {
+		t.Fatalf("expected rs900 merged into rs100, got %d rows", len(kept))
+	}
+	if kept[0].Chromosome != "1" || kept[0].Position != 100 || kept[0].ReferenceAllele != "C" {
+		t.Fatalf("expected the retracted row's representation kept, got %s:%d %s", kept[0].Chromosome, kept[0].Position, kept[0].ReferenceAllele)
+	}
+	if kept[0].DeletedAt == nil {
+		t.Fatalf("expected rs100 to stay retracted")
+	}
+}
+
+func TestBatchWriterBulkLoad(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+
+	bulk, err := database.BeginBulkLoad(ctx, db)
+	if err != nil {
+		t.Fatalf("begin: %v", err)
+	}
+	in := make(chan models.SNPData, 5)
+	for i := 1; i <= 5; i++ {
+		in <- models.SNPData{SNP: testSNP(fmt.Sprintf("rs%d", i), "1", int64(i*100))}
+	}
+	close(in)
+	stats, err := NewBatchWriter(db, BatchWriterConfig{ChunkSize: 1, BulkChunkSize: 2}).WithBulkLoad(bulk.Conn()).Run(ctx, in)
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if stats.SNPs != 5 || stats.Chunks != 3 {
+		t.Fatalf("expected 5 SNPs in chunks of 2, got %+v", stats)
+	}
+
+	hasIndex := func() bool {
+		n, err := db.NewSelect().Table("sqlite_master").Where("type = 'index' AND name = 'idx_snps_gene_symbol'").Count(ctx)
+		if err != nil {
+			t.Fatalf("count indexes: %v", err)
+		}
+		return n == 1
+	}
+	if hasIndex() {
+		t.Fatalf("expected secondary indexes dropped during the load")
+	}
+	if err := bulk.Finish(ctx); err != nil {
+		t.Fatalf("finish: %v", err)
+	}
+	if !hasIndex() {
+		t.Fatalf("expected secondary indexes rebuilt")
+	}
+}
+
+func TestBatchWriterQuarantinesInvalidRecords(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+
+	noCondition := models.SNPData{
+		SNP: testSNP("rs2", "1", 200),
+		Clinical: []models.ClinicalData{{
+			ClinicalSignificance: models.ClinicalPathogenic,
+			ReviewStatus:         models.ReviewExpertPanel,
+			Source:               models.SourceClinVar,
+		}},
+		Source: models.SourceClinVar,
+		Raw:    map[string]string{"accession": "RCV000000002"},
+	}
+	noPosition := models.SNPData{SNP: testSNP("rs3", "1", 0), Source: models.SourceDbSNP}
+	// Quarantined ahead of the others, an empty record ID must not blank theirs.
+	noRsID := models.SNPData{SNP: testSNP("", "1", 300), Source: models.SourceClinVar}
+
+	in := make(chan models.SNPData, 4)
+	in <- models.SNPData{SNP: testSNP("rs1", "1", 100)}
+	in <- noRsID
+	in <- noCondition
+	in <- noPosition
+	close(in)
+
+	stats, err := NewBatchWriter(db, BatchWriterConfig{ChunkSize: 10}).WithRunID("run-1").Run(ctx, in)
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if stats.SNPs != 1 || stats.Quarantined != 3 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+	if n, err := db.NewSelect().Table("snps").Count(ctx); err != nil || n != 1 {
+		t.Fatalf("expected only the valid SNP written, got %d (%v)", n, err)
+	}
+
+	records, err := ListQuarantined(ctx, db, string(models.SourceClinVar), 0)
+	if err != nil {
+		t.Fatalf("list quarantined: %v", err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("expected 2 quarantined clinvar records, got %d", len(records))
+	}
+	rec := records[0]
+	if rec.RecordID != "rs2" || rec.RunID == nil || *rec.RunID != "run-1" {
+		t.Fatalf("unexpected record: %+v", rec)
+	}
+	if !strings.Contains(rec.Error, "condition name") || rec.Payload != `{"accession":"RCV000000002"}` {
+		t.Fatalf("expected error and raw payload, got %q %q", rec.Error, rec.Payload)
+	}
+
+	// Without a raw record the mapped bundle is kept instead.
+	records, err = ListQuarantined(ctx, db, string(models.SourceDbSNP), 0)
+	if err != nil || len(records) != 1 || !strings.Contains(records[0].Payload, `"rs3"`) {
+		t.Fatalf("expected the mapped bundle quarantined, got %+v (%v)", records, err)
+	}
+}
@@ -0,0 +1,54 @@
+package schedule
+
+import (
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config lists the jobs of a schedule file:
+//
+//	jobs:
+//	  - name: clinvar-weekly
+//	    cron: "0 3 * * 0"
+//	    stages: [clinvar, scoring]
+//	    incremental: true
+//	  - name: rescore-daily
+//	    cron: "@daily"
+//	    stages: [scoring]
+type Config struct {
+	Jobs []JobConfig `yaml:"jobs" json:"jobs"`
+}
+
+// JobConfig describes one scheduled pipeline run.
+type JobConfig struct {
+	Name string `yaml:"name" json:"name"`
+	Cron string `yaml:"cron" json:"cron"`
+	// Stages are the pipeline stages the job runs; the rest are disabled.
+	// Empty runs every stage.
+	Stages []string `yaml:"stages" json:"stages"`
+	// Incremental only downloads what changed since each stage last completed.
+	Incremental bool `yaml:"incremental" json:"incremental"`
+	// Full rescores every SNP instead of only changed ones.
+	Full bool `yaml:"full" json:"full"`
+}
+
+// LoadConfig loads YAML bytes into a Config, checking every cron expression.
+func LoadConfig(data []byte) (Config, error) {
+	var cfg Config
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return Config{}, err
+	}
+	if len(cfg.Jobs) == 0 {
+		return Config{}, fmt.Errorf("no jobs configured")
+	}
+	for _, job := range cfg.Jobs {
+		if job.Name == "" {
+			return Config{}, fmt.Errorf("job with cron %q has no name", job.Cron)
+		}
+		if _, err := Parse(job.Cron); err != nil {
+			return Config{}, fmt.Errorf("job %s: %w", job.Name, err)
+		}
+	}
+	return cfg, nil
+}
@@ -0,0 +1,168 @@
+package schedule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// descriptors are the shorthand expressions Parse accepts.
+var descriptors = map[string]string{
+	"@hourly":  "0 * * * *",
+	"@daily":   "0 0 * * *",
+	"@weekly":  "0 0 * * 0",
+	"@monthly": "0 0 1 * *",
+	"@yearly":  "0 0 1 1 *",
+}
+
+// field is the set of values one cron field matches, as a bitmask.
+type field uint64
+
+func (f field) has(v int) bool { return f&(1<<uint(v)) != 0 }
+
+type fieldRange struct {
+	name     string
+	min, max int
+}
+
+var fieldRanges = []fieldRange{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	{"day of week", 0, 6},
+}
+
+// Cron is a parsed five-field cron expression: minute, hour, day of month,
+// month and day of week.
+type Cron struct {
+	expr                          string
+	minute, hour, dom, month, dow field
+	// As in cron(8), when both day fields are restricted a time matching
+	// either of them matches.
+	domStar, dowStar bool
+}
+
+// Parse parses a standard cron expression such as "0 3 * * 0" (Sundays at
+// 03:00), supporting lists, ranges, steps and the @hourly, @daily, @weekly,
+// @monthly and @yearly shorthands. Day of week 7 is Sunday as well as 0.
+func Parse(expr string) (*Cron, error) {
+	spec := strings.TrimSpace(expr)
+	if d, ok := descriptors[spec]; ok {
+		spec = d
+	}
+	parts := strings.Fields(spec)
+	if len(parts) != len(fieldRanges) {
+		return nil, fmt.Errorf("cron %q: want 5 fields, got %d", expr, len(parts))
+	}
+
+	fields := make([]field, len(parts))
+	for i, part := range parts {
+		r := fieldRanges[i]
+		if i == 4 {
+			r.max = 7
+		}
+		f, err := parseField(part, r)
+		if err != nil {
+			return nil, fmt.Errorf("cron %q: %w", expr, err)
+		}
+		fields[i] = f
+	}
+	if fields[4].has(7) {
+		fields[4] |= 1
+	}
+
+	return &Cron{
+		expr:    expr,
+		minute:  fields[0],
+		hour:    fields[1],
+		dom:     fields[2],
+		month:   fields[3],
+		dow:     fields[4],
+		domStar: strings.HasPrefix(parts[2], "*"),
+		dowStar: strings.HasPrefix(parts[4], "*"),
+	}, nil
+}
+
+func parseField(spec string, r fieldRange) (field, error) {
+	var f field
+	for _, item := range strings.Split(spec, ",") {
+		rangeSpec, step := item, 1
+		if i := strings.Index(item, "/"); i >= 0 {
+			n, err := strconv.Atoi(item[i+1:])
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("%s: invalid step in %q", r.name, item)
+			}
+			rangeSpec, step = item[:i], n
+		}
+
+		lo, hi := r.min, r.max
+		switch {
+		case rangeSpec == "*":
+		case strings.Contains(rangeSpec, "-"):
+			bounds := strings.SplitN(rangeSpec, "-", 2)
+			var err1, err2 error
+			lo, err1 = strconv.Atoi(bounds[0])
+			hi, err2 = strconv.Atoi(bounds[1])
+			if err1 != nil || err2 != nil {
+				return 0, fmt.Errorf("%s: invalid range %q", r.name, item)
+			}
+		default:
+			n, err := strconv.Atoi(rangeSpec)
+			if err != nil {
+				return 0, fmt.Errorf("%s: invalid value %q", r.name, item)
+			}
+			lo, hi = n, n
+			if step > 1 {
+				hi = r.max
+			}
+		}
+		if lo < r.min || hi > r.max || lo > hi {
+			return 0, fmt.Errorf("%s: %q out of range %d-%d", r.name, item, r.min, r.max)
+		}
+		for v := lo; v <= hi; v += step {
+			f |= 1 << uint(v)
+		}
+	}
+	return f, nil
+}
+
+// String returns the expression Parse was given.
+func (c *Cron) String() string { return c.expr }
+
+// Next returns the first time after t that matches, in t's location. It
+// returns the zero time if nothing matches within five years, as with
+// "0 0 30 2 *".
+func (c *Cron) Next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		if !c.month.has(int(t.Month())) {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !c.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !c.hour.has(t.Hour()) {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+		if !c.minute.has(t.Minute()) {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+func (c *Cron) dayMatches(t time.Time) bool {
+	dom, dow := c.dom.has(t.Day()), c.dow.has(int(t.Weekday()))
+	if c.domStar || c.dowStar {
+		return dom && dow
+	}
+	return dom || dow
+}
@@ -0,0 +1,52 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCronNext(t *testing.T) {
+	// 2025-01-01 is a Wednesday.
+	from := time.Date(2025, 1, 1, 10, 30, 45, 0, time.UTC)
+	cases := map[string]string{
+		"* * * * *":      "2025-01-01T10:31:00Z",
+		"*/15 * * * *":   "2025-01-01T10:45:00Z",
+		"0 3 * * 0":      "2025-01-05T03:00:00Z",
+		"0 3 * * 7":      "2025-01-05T03:00:00Z",
+		"@weekly":        "2025-01-05T00:00:00Z",
+		"@daily":         "2025-01-02T00:00:00Z",
+		"0 9-17/4 * * *": "2025-01-01T13:00:00Z",
+		"30 2 1,15 * *":  "2025-01-15T02:30:00Z",
+		"0 0 29 2 *":     "2028-02-29T00:00:00Z",
+		// Both day fields restricted: the 10th or any Monday.
+		"0 0 10 * 1": "2025-01-06T00:00:00Z",
+	}
+	for expr, want := range cases {
+		c, err := Parse(expr)
+		if err != nil {
+			t.Errorf("%s: %v", expr, err)
+			continue
+		}
+		if got := c.Next(from).Format(time.RFC3339); got != want {
+			t.Errorf("%s: next after %s is %s, want %s", expr, from.Format(time.RFC3339), got, want)
+		}
+	}
+}
+
+func TestCronNeverMatching(t *testing.T) {
+	c, err := Parse("0 0 30 2 *")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if next := c.Next(time.Now()); !next.IsZero() {
+		t.Fatalf("expected no next time, got %s", next)
+	}
+}
+
+func TestParseRejectsInvalidExpressions(t *testing.T) {
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "*/0 * * * *", "5-1 * * * *", "a * * * *", "@often"} {
+		if _, err := Parse(expr); err == nil {
+			t.Errorf("%q: expected error", expr)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package schedule
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/mkoziy/genome/exporter/internal/logging"
+)
+
+// Job is work run on a cron schedule.
+type Job struct {
+	Name string
+	Cron *Cron
+	Run  func(ctx context.Context) error
+}
+
+// Scheduler runs jobs when their schedules fire. A job whose previous run is
+// still going when it fires again skips that firing, and jobs never run
+// concurrently with each other, since they write the same database.
+type Scheduler struct {
+	jobs []Job
+	now  func() time.Time
+
+	// exclusive is held by the running job.
+	exclusive sync.Mutex
+	mu        sync.Mutex
+	pending   map[string]bool
+}
+
+// New validates that job names are unique and every job has a schedule.
+func New(jobs ...Job) (*Scheduler, error) {
+	names := make(map[string]bool, len(jobs))
+	for _, job := range jobs {
+		if job.Name == "" || job.Cron == nil || job.Run == nil {
+			return nil, fmt.Errorf("job %q: name, schedule and run are required", job.Name)
+		}
+		if names[job.Name] {
+			return nil, fmt.Errorf("duplicate job %q", job.Name)
+		}
+		names[job.Name] = true
+	}
+	if len(jobs) == 0 {
+		return nil, errors.New("no jobs to schedule")
+	}
+	return &Scheduler{jobs: jobs, now: time.Now, pending: make(map[string]bool)}, nil
+}
+
+// Run fires jobs until ctx is cancelled, then waits for the running job to
+// return. It always returns ctx's error.
+func (s *Scheduler) Run(ctx context.Context) error {
+	next := make([]time.Time, len(s.jobs))
+	for i, job := range s.jobs {
+		next[i] = job.Cron.Next(s.now())
+		s.logNext(job, next[i])
+	}
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	for {
+		due := time.Time{}
+		for _, t := range next {
+			if !t.IsZero() && (due.IsZero() || t.Before(due)) {
+				due = t
+			}
+		}
+		if due.IsZero() {
+			<-ctx.Done()
+			return ctx.Err()
+		}
+
+		timer := time.NewTimer(time.Until(due))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+
+		now := s.now()
+		for i, job := range s.jobs {
+			if next[i].IsZero() || next[i].After(now) {
+				continue
+			}
+			if s.start(ctx, &wg, job) {
+				slog.InfoContext(ctx, "Scheduled job started", "job", job.Name)
+			} else {
+				slog.WarnContext(ctx, "Scheduled job skipped: previous run still in progress", "job", job.Name)
+			}
+			next[i] = job.Cron.Next(now)
+			s.logNext(job, next[i])
+		}
+	}
+}
+
+// start runs job in the background once no other job is running. It reports
+// false, without running it, if the job is already running or waiting to.
+func (s *Scheduler) start(ctx context.Context, wg *sync.WaitGroup, job Job) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.pending[job.Name] {
+		return false
+	}
+	s.pending[job.Name] = true
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer func() {
+			s.mu.Lock()
+			delete(s.pending, job.Name)
+			s.mu.Unlock()
+		}()
+
+		s.exclusive.Lock()
+		defer s.exclusive.Unlock()
+		if ctx.Err() != nil {
+			return
+		}
+		ctx := logging.With(ctx, "job", job.Name)
+		start := s.now()
+		if err := job.Run(ctx); err != nil {
+			slog.ErrorContext(ctx, "Scheduled job failed", "duration", s.now().Sub(start).Round(time.Second), "error", err)
+			return
+		}
+		slog.InfoContext(ctx, "Scheduled job finished", "duration", s.now().Sub(start).Round(time.Second))
+	}()
+	return true
+}
+
+func (s *Scheduler) logNext(job Job, next time.Time) {
+	if next.IsZero() {
+		slog.Warn("Scheduled job will never run", "job", job.Name, "cron", job.Cron.String())
+		return
+	}
+	slog.Info("Scheduled job next run", "job", job.Name, "cron", job.Cron.String(), "next", next.Format(time.RFC3339))
+}
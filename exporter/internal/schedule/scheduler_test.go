@@ -0,0 +1,70 @@
+package schedule
+
+import (
+	"context"
+	"sync"
+	"testing"
+)
+
+func TestSchedulerSkipsOverlappingRuns(t *testing.T) {
+	cron, err := Parse("@hourly")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	release := make(chan struct{})
+	started := make(chan string, 4)
+	job := func(name string) Job {
+		return Job{Name: name, Cron: cron, Run: func(ctx context.Context) error {
+			started <- name
+			<-release
+			return nil
+		}}
+	}
+	s, err := New(job("clinvar"), job("pubmed"))
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	ctx := context.Background()
+	if !s.start(ctx, &wg, s.jobs[0]) {
+		t.Fatalf("first run was skipped")
+	}
+	<-started
+	if s.start(ctx, &wg, s.jobs[0]) {
+		t.Fatalf("second run of a running job was not skipped")
+	}
+	// Another job waits for the running one instead of writing concurrently.
+	if !s.start(ctx, &wg, s.jobs[1]) {
+		t.Fatalf("other job was skipped")
+	}
+	select {
+	case name := <-started:
+		t.Fatalf("%s started while another job was running", name)
+	default:
+	}
+
+	close(release)
+	wg.Wait()
+	if name := <-started; name != "pubmed" {
+		t.Fatalf("expected pubmed to run next, got %s", name)
+	}
+	if !s.start(ctx, &wg, s.jobs[0]) {
+		t.Fatalf("finished job could not run again")
+	}
+	wg.Wait()
+}
+
+func TestNewRejectsInvalidJobs(t *testing.T) {
+	cron, _ := Parse("@daily")
+	run := func(context.Context) error { return nil }
+	if _, err := New(); err == nil {
+		t.Errorf("no jobs: expected error")
+	}
+	if _, err := New(Job{Name: "a", Cron: cron, Run: run}, Job{Name: "a", Cron: cron, Run: run}); err == nil {
+		t.Errorf("duplicate: expected error")
+	}
+	if _, err := New(Job{Name: "a", Run: run}); err == nil {
+		t.Errorf("missing schedule: expected error")
+	}
+}
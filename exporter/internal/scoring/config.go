@@ -0,0 +1,45 @@
+package scoring
+
+import (
+	"gopkg.in/yaml.v3"
+)
+
+// Config holds the tunable inputs of the scorer.
+type Config struct {
+	// HighImpactJournals lists journals whose studies count as high impact.
+	// Names are matched case-insensitively, ignoring punctuation.
+	HighImpactJournals []string `yaml:"high_impact_journals" json:"high_impact_journals"`
+	// RecencyHalfLifeYears is the publication age at which a study counts half.
+	RecencyHalfLifeYears float64 `yaml:"recency_half_life_years" json:"recency_half_life_years"`
+}
+
+// DefaultConfig returns the configuration used when none is supplied.
+func DefaultConfig() Config {
+	return Config{
+		HighImpactJournals: []string{
+			"Nature", "Science", "Cell", "N Engl J Med", "Lancet", "JAMA", "Nat Genet",
+			"Nat Med", "Am J Hum Genet", "Genet Med", "PLoS Genet", "Hum Mol Genet",
+		},
+		RecencyHalfLifeYears: 10,
+	}
+}
+
+// LoadConfig loads YAML bytes into a Config with defaults applied.
+func LoadConfig(data []byte) (Config, error) {
+	var cfg Config
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return Config{}, err
+	}
+	return applyDefaults(cfg), nil
+}
+
+func applyDefaults(cfg Config) Config {
+	def := DefaultConfig()
+	if cfg.HighImpactJournals == nil {
+		cfg.HighImpactJournals = def.HighImpactJournals
+	}
+	if cfg.RecencyHalfLifeYears <= 0 {
+		cfg.RecencyHalfLifeYears = def.RecencyHalfLifeYears
+	}
+	return cfg
+}
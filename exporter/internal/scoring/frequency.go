@@ -0,0 +1,137 @@
+package scoring
+
+import (
+	"sort"
+
+	"github.com/mkoziy/genome/exporter/internal/models"
+)
+
+// frequencyGroup is the frequencies one source reports for one population.
+type frequencyGroup struct {
+	source models.DataSource
+	code   string
+}
+
+// frequencyPick is the group chosen for a superpopulation or the global
+// frequency, with what it was chosen on.
+type frequencyPick struct {
+	group    frequencyGroup
+	rank     int
+	codeRank int
+}
+
+// before reports whether p is preferred to q: the higher ranked source, then
+// the preferred code, then by name so the choice does not depend on order.
+func (p *frequencyPick) before(q *frequencyPick) bool {
+	if q == nil {
+		return true
+	}
+	if p.rank != q.rank {
+		return p.rank < q.rank
+	}
+	if p.codeRank != q.codeRank {
+		return p.codeRank < q.codeRank
+	}
+	if p.group.source != q.group.source {
+		return p.group.source < q.group.source
+	}
+	return p.group.code < q.group.code
+}
+
+// groupFrequencies returns the allele frequencies of freqs by source and
+// population, averaging the rows of one source repeating an allele of a
+// population.
+func groupFrequencies(freqs []*models.PopulationFreq) map[frequencyGroup]map[string]float64 {
+	type key struct {
+		group  frequencyGroup
+		allele string
+	}
+	sums := make(map[key]float64)
+	counts := make(map[key]int)
+	for _, freq := range freqs {
+		k := key{frequencyGroup{freq.Source, freq.PopulationCode}, freq.Allele}
+		sums[k] += freq.Frequency
+		counts[k]++
+	}
+	alleles := make(map[frequencyGroup]map[string]float64)
+	for k, sum := range sums {
+		if alleles[k.group] == nil {
+			alleles[k.group] = make(map[string]float64)
+		}
+		alleles[k.group][k.allele] = sum / float64(counts[k])
+	}
+	return alleles
+}
+
+// GlobalAlleleFrequencies returns the frequency of each allele over all
+// samples of the first source of models.FrequencySources to report them,
+// as Frequencies picks the global MAF, or nil if none does.
+func GlobalAlleleFrequencies(freqs []*models.PopulationFreq) map[string]float64 {
+	alleles := groupFrequencies(freqs)
+	var global *frequencyPick
+	for group := range alleles {
+		pick := &frequencyPick{group: group, rank: models.FrequencyRank(group.source)}
+		if models.IsGlobalPopulation(group.code) && pick.before(global) {
+			global = pick
+		}
+	}
+	if global == nil {
+		return nil
+	}
+	return alleles[global.group]
+}
+
+// Frequencies aggregates freqs, the population frequencies of the SNP with
+// ID snpID, into its canonical frequency. The global MAF and the MAF of each
+// superpopulation come from the first source of models.FrequencySources to
+// report them, from its preferred code for the superpopulation; the rows of
+// one source repeating an allele of a population are averaged. The result
+// is ready for repositories.SaveFrequencies.
+func Frequencies(snpID int64, freqs []*models.PopulationFreq) *models.Frequency {
+	f := &models.Frequency{SNPID: snpID}
+	alleles := groupFrequencies(freqs)
+
+	var global *frequencyPick
+	superpopulations := make(map[string]*frequencyPick)
+	for group := range alleles {
+		pick := &frequencyPick{group: group, rank: models.FrequencyRank(group.source)}
+		if models.IsGlobalPopulation(group.code) {
+			if pick.before(global) {
+				global = pick
+			}
+			continue
+		}
+		var superpopulation string
+		superpopulation, pick.codeRank = models.Superpopulation(group.code)
+		if pick.before(superpopulations[superpopulation]) {
+			superpopulations[superpopulation] = pick
+		}
+	}
+
+	if global != nil {
+		maf := populationMAF(alleles[global.group])
+		f.GlobalMAF, f.GlobalSource = &maf, global.group.source
+	}
+	if len(superpopulations) == 0 {
+		return f
+	}
+
+	codes := make([]string, 0, len(superpopulations))
+	for code := range superpopulations {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+
+	f.SuperpopulationMAFs = make(models.FrequencyMap, len(codes))
+	for _, code := range codes {
+		maf := populationMAF(alleles[superpopulations[code].group])
+		f.SuperpopulationMAFs[code] = maf
+		if f.MaxMAF == nil || maf > *f.MaxMAF {
+			f.MaxMAF, f.MaxSuperpopulation = &maf, code
+		}
+		if f.MinMAF == nil || maf < *f.MinMAF {
+			f.MinMAF, f.MinSuperpopulation = &maf, code
+		}
+	}
+	return f
+}
@@ -0,0 +1,63 @@
+package scoring
+
+import (
+	"math"
+	"testing"
+
+	"github.com/mkoziy/genome/exporter/internal/models"
+)
+
+func sourcedFreq(source models.DataSource, population, allele string, f float64) *models.PopulationFreq {
+	p := freq(population, allele, f)
+	p.Source = source
+	return p
+}
+
+func TestFrequencies(t *testing.T) {
+	f := Frequencies(7, []*models.PopulationFreq{
+		sourcedFreq(models.SourceALFA, "Total", "T", 0.2),
+		sourcedFreq(models.SourceGnomAD, "all", "T", 0.1),
+		// gnomAD's EUR is its non-Finnish Europeans, whatever ALFA says.
+		sourcedFreq(models.SourceGnomAD, "fin", "T", 0.4),
+		sourcedFreq(models.SourceGnomAD, "nfe", "T", 0.05),
+		sourcedFreq(models.SourceALFA, "European", "T", 0.3),
+		// Only ALFA measured Africans; its repeated rows are averaged.
+		sourcedFreq(models.SourceALFA, "African", "T", 0.2),
+		sourcedFreq(models.SourceALFA, "African", "T", 0.3),
+		// Populations of no superpopulation stand on their own.
+		sourcedFreq(models.SourceDbSNP, "GnomAD_exomes", "T", 0.8),
+	})
+
+	if f.SNPID != 7 {
+		t.Errorf("expected snp 7, got %d", f.SNPID)
+	}
+	if f.GlobalMAF == nil || math.Abs(*f.GlobalMAF-0.1) > 1e-9 || f.GlobalSource != models.SourceGnomAD {
+		t.Errorf("expected gnomAD's global MAF 0.1, got %v from %q", f.GlobalMAF, f.GlobalSource)
+	}
+	want := map[string]float64{
+		models.SuperpopulationAFR: 0.25,
+		models.SuperpopulationEUR: 0.05,
+		"GnomAD_exomes":           0.2,
+	}
+	if len(f.SuperpopulationMAFs) != len(want) {
+		t.Fatalf("expected %v, got %v", want, f.SuperpopulationMAFs)
+	}
+	for code, maf := range want {
+		if got, ok := f.SuperpopulationMAFs[code]; !ok || math.Abs(got-maf) > 1e-9 {
+			t.Errorf("%s: expected %v, got %v", code, maf, f.SuperpopulationMAFs[code])
+		}
+	}
+	if f.MaxSuperpopulation != models.SuperpopulationAFR || math.Abs(*f.MaxMAF-0.25) > 1e-9 {
+		t.Errorf("expected AFR the highest at 0.25, got %s at %v", f.MaxSuperpopulation, *f.MaxMAF)
+	}
+	if f.MinSuperpopulation != models.SuperpopulationEUR || math.Abs(*f.MinMAF-0.05) > 1e-9 {
+		t.Errorf("expected EUR the lowest at 0.05, got %s at %v", f.MinSuperpopulation, *f.MinMAF)
+	}
+}
+
+func TestFrequenciesNone(t *testing.T) {
+	f := Frequencies(1, nil)
+	if f.GlobalMAF != nil || f.MaxMAF != nil || f.MinMAF != nil || f.SuperpopulationMAFs != nil {
+		t.Fatalf("expected no frequencies, got %+v", f)
+	}
+}
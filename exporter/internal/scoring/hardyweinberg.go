@@ -0,0 +1,159 @@
+package scoring
+
+import (
+	"math"
+	"sort"
+
+	"github.com/mkoziy/genome/exporter/internal/models"
+	"github.com/mkoziy/genome/exporter/internal/variant"
+)
+
+// GenotypeFrequencies returns the frequencies Hardy–Weinberg equilibrium
+// expects, for an allele of frequency p, of the genotypes homozygous for
+// it, heterozygous and without it: p², 2pq and q².
+func GenotypeFrequencies(p float64) (homozygous, heterozygous, none float64) {
+	q := 1 - p
+	return p * p, 2 * p * q, q * q
+}
+
+// GenotypeProbability returns how common the genotype of alleles is among
+// everyone, as equilibrium expects from the global allele frequencies of
+// snp; see GlobalAlleleFrequencies. The reference allele's frequency is
+// what the other alleles leave where no source reports it. It returns false
+// for genotypes of neither one nor two alleles, and for alleles of unknown
+// frequency.
+func GenotypeProbability(snp *models.SNP, alleles []string) (float64, bool) {
+	if len(alleles) != 1 && len(alleles) != 2 {
+		return 0, false
+	}
+	freqs := GlobalAlleleFrequencies(snp.PopulationData)
+	if freqs == nil {
+		return 0, false
+	}
+	frequency := func(allele string) (float64, bool) {
+		if f, ok := freqs[allele]; ok {
+			return f, true
+		}
+		if allele != snp.ReferenceAllele {
+			return 0, false
+		}
+		rest := 1.0
+		for _, f := range freqs {
+			rest -= f
+		}
+		return rest, rest >= 0
+	}
+
+	p, ok := frequency(alleles[0])
+	if !ok {
+		return 0, false
+	}
+	if len(alleles) == 1 {
+		return p, true
+	}
+	if alleles[1] == alleles[0] {
+		return p * p, true
+	}
+	q, ok := frequency(alleles[1])
+	if !ok {
+		return 0, false
+	}
+	return 2 * p * q, true
+}
+
+// HardyWeinberg tests the genotypes counted in each population of snp
+// against Hardy–Weinberg equilibrium, for the frequencies reporting their
+// AlleleCount, AlleleNumber and HomozygoteCount. Only autosomal SNPs are
+// tested, the copies of X, Y and MT differing between people. A source
+// repeating an allele of a population is represented by its row of most
+// samples. The results are ready for repositories.SaveHardyWeinberg.
+func HardyWeinberg(snp *models.SNP) []*models.HardyWeinberg {
+	switch variant.NormalizeChromosome(snp.Chromosome) {
+	case "X", "Y", "MT":
+		return nil
+	}
+
+	type key struct {
+		source       models.DataSource
+		code, allele string
+	}
+	tested := make(map[key]*models.HardyWeinberg)
+	for _, freq := range snp.PopulationData {
+		h, ok := hardyWeinberg(snp.ID, freq)
+		if !ok {
+			continue
+		}
+		k := key{freq.Source, freq.PopulationCode, freq.Allele}
+		if prev, ok := tested[k]; !ok || h.Samples > prev.Samples {
+			tested[k] = h
+		}
+	}
+
+	rows := make([]*models.HardyWeinberg, 0, len(tested))
+	for _, h := range tested {
+		rows = append(rows, h)
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		a, b := rows[i], rows[j]
+		if a.Source != b.Source {
+			return a.Source < b.Source
+		}
+		if a.PopulationCode != b.PopulationCode {
+			return a.PopulationCode < b.PopulationCode
+		}
+		return a.Allele < b.Allele
+	})
+	return rows
+}
+
+// hardyWeinberg tests the genotypes freq counts, or returns false if it
+// does not count them or its counts contradict each other.
+func hardyWeinberg(snpID int64, freq *models.PopulationFreq) (*models.HardyWeinberg, bool) {
+	if freq.AlleleCount == nil || freq.AlleleNumber == nil || freq.HomozygoteCount == nil {
+		return nil, false
+	}
+	count, number, homozygotes := *freq.AlleleCount, *freq.AlleleNumber, *freq.HomozygoteCount
+	if number <= 0 || number%2 != 0 || count < 0 || count > number || homozygotes < 0 {
+		return nil, false
+	}
+	samples := number / 2
+	heterozygotes := count - 2*homozygotes
+	nonCarriers := samples - homozygotes - heterozygotes
+	if heterozygotes < 0 || nonCarriers < 0 {
+		return nil, false
+	}
+
+	p := float64(count) / float64(number)
+	hom, het, none := GenotypeFrequencies(p)
+	h := &models.HardyWeinberg{
+		SNPID:                 snpID,
+		Source:                freq.Source,
+		PopulationCode:        freq.PopulationCode,
+		Allele:                freq.Allele,
+		AlleleFrequency:       p,
+		Samples:               samples,
+		Homozygotes:           homozygotes,
+		Heterozygotes:         heterozygotes,
+		NonCarriers:           nonCarriers,
+		ExpectedHomozygotes:   hom * float64(samples),
+		ExpectedHeterozygotes: het * float64(samples),
+		ExpectedNonCarriers:   none * float64(samples),
+		PValue:                1,
+	}
+	if h.ExpectedHeterozygotes == 0 {
+		// Monomorphic: every sample has the same genotype, as expected.
+		return h, true
+	}
+	inbreeding := 1 - float64(heterozygotes)/h.ExpectedHeterozygotes
+	h.Inbreeding = &inbreeding
+	for _, c := range [][2]float64{
+		{float64(homozygotes), h.ExpectedHomozygotes},
+		{float64(heterozygotes), h.ExpectedHeterozygotes},
+		{float64(nonCarriers), h.ExpectedNonCarriers},
+	} {
+		h.ChiSquare += (c[0] - c[1]) * (c[0] - c[1]) / c[1]
+	}
+	// The chi-square distribution of one degree of freedom.
+	h.PValue = math.Erfc(math.Sqrt(h.ChiSquare / 2))
+	return h, true
+}
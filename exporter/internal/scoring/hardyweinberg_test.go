@@ -0,0 +1,88 @@
+package scoring
+
+import (
+	"math"
+	"testing"
+
+	"github.com/mkoziy/genome/exporter/internal/models"
+)
+
+func countedFreq(population string, count, number, homozygotes int) *models.PopulationFreq {
+	p := sourcedFreq(models.SourceGnomAD, population, "T", float64(count)/float64(number))
+	p.AlleleCount, p.AlleleNumber, p.HomozygoteCount = &count, &number, &homozygotes
+	return p
+}
+
+func TestHardyWeinberg(t *testing.T) {
+	uncounted := sourcedFreq(models.SourceGnomAD, "eas", "T", 0.2)
+	snp := &models.SNP{ID: 3, Chromosome: "7", PopulationData: []*models.PopulationFreq{
+		// 25 TT, 50 CT and 25 CC: in equilibrium.
+		countedFreq("afr", 100, 200, 25),
+		// 50 TT and 50 CC: no heterozygotes at all.
+		countedFreq("nfe", 100, 200, 50),
+		// The same population counted again, in fewer samples.
+		countedFreq("nfe", 10, 20, 0),
+		// More homozygotes than carriers.
+		countedFreq("amr", 10, 200, 6),
+		uncounted,
+	}}
+
+	rows := HardyWeinberg(snp)
+	if len(rows) != 2 {
+		t.Fatalf("expected 2 tests, got %d", len(rows))
+	}
+	afr, nfe := rows[0], rows[1]
+	if afr.PopulationCode != "afr" || afr.SNPID != 3 || afr.Heterozygotes != 50 || afr.NonCarriers != 25 {
+		t.Errorf("unexpected afr test %+v", afr)
+	}
+	if afr.ChiSquare != 0 || afr.PValue != 1 || afr.Inbreeding == nil || *afr.Inbreeding != 0 || afr.Deviates() {
+		t.Errorf("expected afr in equilibrium, got chi² %v, p %v", afr.ChiSquare, afr.PValue)
+	}
+	if nfe.Samples != 100 || math.Abs(nfe.ExpectedHeterozygotes-50) > 1e-9 || math.Abs(nfe.ChiSquare-100) > 1e-9 {
+		t.Errorf("unexpected nfe test %+v", nfe)
+	}
+	if !nfe.Deviates() || nfe.Inbreeding == nil || *nfe.Inbreeding != 1 {
+		t.Errorf("expected nfe to deviate with F 1, got p %v", nfe.PValue)
+	}
+
+	snp.Chromosome = "chrX"
+	if rows := HardyWeinberg(snp); len(rows) != 0 {
+		t.Errorf("expected X untested, got %d tests", len(rows))
+	}
+}
+
+func TestHardyWeinbergMonomorphic(t *testing.T) {
+	snp := &models.SNP{Chromosome: "1", PopulationData: []*models.PopulationFreq{countedFreq("afr", 0, 100, 0)}}
+	rows := HardyWeinberg(snp)
+	if len(rows) != 1 || rows[0].PValue != 1 || rows[0].Inbreeding != nil || rows[0].NonCarriers != 50 {
+		t.Fatalf("expected a monomorphic site in equilibrium, got %+v", rows)
+	}
+}
+
+func TestGenotypeProbability(t *testing.T) {
+	snp := &models.SNP{ReferenceAllele: "C", PopulationData: []*models.PopulationFreq{
+		sourcedFreq(models.SourceALFA, "Total", "T", 0.5),
+		sourcedFreq(models.SourceGnomAD, "all", "T", 0.1),
+	}}
+	tests := []struct {
+		alleles []string
+		want    float64
+		ok      bool
+	}{
+		{[]string{"C", "C"}, 0.81, true},
+		{[]string{"C", "T"}, 0.18, true},
+		{[]string{"T", "T"}, 0.01, true},
+		{[]string{"T"}, 0.1, true},
+		{[]string{"C", "G"}, 0, false},
+		{nil, 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := GenotypeProbability(snp, tt.alleles)
+		if ok != tt.ok || math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("%v: expected %v, %v, got %v, %v", tt.alleles, tt.want, tt.ok, got, ok)
+		}
+	}
+	if _, ok := GenotypeProbability(&models.SNP{ReferenceAllele: "C"}, []string{"C", "C"}); ok {
+		t.Error("expected no probability without frequencies")
+	}
+}
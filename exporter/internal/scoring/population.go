@@ -0,0 +1,99 @@
+package scoring
+
+import (
+	"math"
+	"sort"
+	"strconv"
+
+	"github.com/mkoziy/genome/exporter/internal/models"
+)
+
+// Population dimension calibration. Prevalence rewards variants that reach many
+// carriers; rarity rewards variants too rare to be benign polymorphisms; an
+// ancestry-specific bonus flags variants common in one population and rare in
+// another, which generic frequency filters misjudge.
+const (
+	commonMAF = models.CommonMAF
+	rareMAF   = models.RareMAF
+
+	maxPrevalence = 12.0
+	maxRarity     = 8.0
+	ancestryBonus = 8.0
+)
+
+// populationMAF is the minor allele frequency within one population. A single
+// reported allele is treated as biallelic; for several alleles the unreported
+// remainder counts as one more allele, and the MAF is the frequency of the
+// second most common allele.
+func populationMAF(alleles map[string]float64) float64 {
+	if len(alleles) == 1 {
+		for _, f := range alleles {
+			return math.Min(f, 1-f)
+		}
+	}
+
+	freqs := make([]float64, 0, len(alleles)+1)
+	var total float64
+	for _, f := range alleles {
+		freqs = append(freqs, f)
+		total += f
+	}
+	if rest := 1 - total; rest > 1e-9 {
+		freqs = append(freqs, rest)
+	}
+	if len(freqs) < 2 {
+		return 0
+	}
+	sort.Sort(sort.Reverse(sort.Float64Slice(freqs)))
+	return freqs[1]
+}
+
+// scorePopulation scores the MAFs of the superpopulations Frequencies
+// aggregates freqs into, or failing any the global MAF, and combines
+// prevalence, rarity and ancestry specificity.
+func scorePopulation(freqs []*models.PopulationFreq, details *models.ScoreBreakdown) float64 {
+	f := Frequencies(0, freqs)
+	p := &details.PopulationDetails
+	maxCode, minCode := f.MaxSuperpopulation, f.MinSuperpopulation
+	switch {
+	case f.MaxMAF != nil:
+		p.PopulationCount, p.MaxMAF, p.MinMAF = len(f.SuperpopulationMAFs), *f.MaxMAF, *f.MinMAF
+	case f.GlobalMAF != nil:
+		p.PopulationCount, p.MaxMAF, p.MinMAF = 1, *f.GlobalMAF, *f.GlobalMAF
+		maxCode, minCode = "global", "global"
+	default:
+		return 0
+	}
+	if p.MaxMAF == 0 {
+		// Monomorphic wherever measured: nothing varies, so nothing to score.
+		return 0
+	}
+
+	prevalence := round(maxPrevalence * math.Min(p.MaxMAF/commonMAF, 1))
+	details.AddReason(prevalence, models.ReasonMAF, percent(p.MaxMAF), maxCode)
+
+	// Rarity grows from nothing at 1% to full at 0.01%.
+	rarity := round(maxRarity * clamp((-math.Log10(p.MaxMAF)-2)/2))
+	if p.PopulationCount == 1 {
+		details.AddReason(rarity, models.ReasonRareIn, maxCode)
+	} else {
+		details.AddReason(rarity, models.ReasonRareInAll, p.PopulationCount)
+	}
+
+	var ancestry float64
+	if p.MaxMAF >= commonMAF && p.MinMAF < rareMAF {
+		p.AncestrySpecific = true
+		ancestry = ancestryBonus
+		details.AddReason(ancestry, models.ReasonAncestrySpecific, maxCode, percent(p.MinMAF), minCode)
+	}
+
+	return math.Min(round(prevalence+rarity+ancestry), MaxPopulation)
+}
+
+func percent(f float64) string {
+	return strconv.FormatFloat(f*100, 'g', 2, 64) + "%"
+}
+
+func clamp(v float64) float64 {
+	return math.Max(0, math.Min(v, 1))
+}
@@ -0,0 +1,102 @@
+package scoring
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/mkoziy/genome/exporter/internal/models"
+)
+
+func freq(population, allele string, f float64) *models.PopulationFreq {
+	return &models.PopulationFreq{PopulationCode: population, Allele: allele, Frequency: f}
+}
+
+func TestPopulationMAF(t *testing.T) {
+	tests := []struct {
+		name    string
+		alleles map[string]float64
+		want    float64
+	}{
+		{"single alternate allele", map[string]float64{"T": 0.3}, 0.3},
+		{"single major allele", map[string]float64{"C": 0.9}, 0.1},
+		{"monomorphic", map[string]float64{"C": 1}, 0},
+		{"absent allele", map[string]float64{"T": 0}, 0},
+		{"biallelic", map[string]float64{"C": 0.6, "T": 0.4}, 0.4},
+		{"multi-allelic", map[string]float64{"C": 0.7, "T": 0.2, "G": 0.1}, 0.2},
+		{"multi-allelic with unreported reference", map[string]float64{"T": 0.15, "G": 0.05}, 0.15},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := populationMAF(tt.alleles); math.Abs(got-tt.want) > 1e-9 {
+				t.Fatalf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestScorePopulation(t *testing.T) {
+	tests := []struct {
+		name    string
+		freqs   []*models.PopulationFreq
+		score   float64
+		details models.PopulationScoring
+		reasons []string
+	}{
+		{
+			name: "no data",
+		},
+		{
+			name:    "monomorphic",
+			freqs:   []*models.PopulationFreq{freq("EUR", "T", 0), freq("AFR", "C", 1)},
+			details: models.PopulationScoring{PopulationCount: 2},
+		},
+		{
+			name:    "common everywhere",
+			freqs:   []*models.PopulationFreq{freq("EUR", "T", 0.3), freq("AFR", "T", 0.2)},
+			score:   12,
+			details: models.PopulationScoring{MaxMAF: 0.3, MinMAF: 0.2, PopulationCount: 2},
+			reasons: []string{"MAF 30% in EUR (+12)"},
+		},
+		{
+			name:    "ultra-rare",
+			freqs:   []*models.PopulationFreq{freq("EUR", "T", 0.00005)},
+			score:   8,
+			details: models.PopulationScoring{MaxMAF: 0.00005, MinMAF: 0.00005, PopulationCount: 1},
+			reasons: []string{"rare in EUR (+8)"},
+		},
+		{
+			name:    "ancestry-specific",
+			freqs:   []*models.PopulationFreq{freq("AFR", "G", 0.12), freq("EAS", "G", 0.001), freq("EUR", "G", 0.02)},
+			score:   20,
+			details: models.PopulationScoring{MaxMAF: 0.12, MinMAF: 0.001, PopulationCount: 3, AncestrySpecific: true},
+			reasons: []string{"MAF 12% in AFR (+12)", "common in AFR but 0.1% in EAS (+8)"},
+		},
+		{
+			name: "multi-allelic sources averaged",
+			freqs: []*models.PopulationFreq{
+				freq("EUR", "C", 0.7), freq("EUR", "T", 0.25), freq("EUR", "T", 0.15), freq("EUR", "G", 0.1),
+			},
+			score:   12,
+			details: models.PopulationScoring{MaxMAF: 0.2, MinMAF: 0.2, PopulationCount: 1},
+			reasons: []string{"MAF 20% in EUR (+12)"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var details models.ScoreBreakdown
+			score := scorePopulation(tt.freqs, &details)
+			if score != tt.score {
+				t.Fatalf("expected score %v, got %v", tt.score, score)
+			}
+			got := details.PopulationDetails
+			if math.Abs(got.MaxMAF-tt.details.MaxMAF) > 1e-9 || math.Abs(got.MinMAF-tt.details.MinMAF) > 1e-9 ||
+				got.PopulationCount != tt.details.PopulationCount || got.AncestrySpecific != tt.details.AncestrySpecific {
+				t.Fatalf("unexpected details: %+v", got)
+			}
+			if !reflect.DeepEqual(details.Reasons, tt.reasons) {
+				t.Fatalf("unexpected reasons: %q", details.Reasons)
+			}
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package scoring
+
+import (
+	"math"
+
+	"github.com/mkoziy/genome/exporter/internal/models"
+)
+
+// Quality calibration: each dimension is worth up to a quarter of the 100
+// points, full at these counts.
+const (
+	maxQualityDimension = 25.0
+	qualitySources      = 3
+	qualityPopulations  = 5
+)
+
+// qualityFields are the descriptive fields of a SNP counted by Quality, by
+// column name.
+var qualityFields = []struct {
+	name    string
+	present func(snp *models.SNP) bool
+}{
+	{"gene_symbol", func(snp *models.SNP) bool { return snp.GeneSymbol != nil && *snp.GeneSymbol != "" }},
+	{"functional_class", func(snp *models.SNP) bool { return snp.FunctionalClass != nil }},
+	{"hgvs_coding", func(snp *models.SNP) bool { return snp.HGVSCoding != nil }},
+	{"hgvs_protein", func(snp *models.SNP) bool { return snp.HGVSProtein != nil }},
+	{"grch37_position", func(snp *models.SNP) bool { return snp.GRCh37Position != nil }},
+}
+
+// Quality assesses how completely snp is characterized from its preloaded
+// clinical, phenotype, reference, population and risk allele relations.
+// The result is ready for repositories.SaveQuality.
+func Quality(snp *models.SNP) *models.Quality {
+	q := &models.Quality{SNPID: snp.ID}
+
+	for _, field := range qualityFields {
+		if !field.present(snp) {
+			q.MissingFields = append(q.MissingFields, field.name)
+		}
+	}
+	present := len(qualityFields) - len(q.MissingFields)
+	q.FieldScore = round(maxQualityDimension * float64(present) / float64(len(qualityFields)))
+
+	sources := make(map[models.DataSource]bool)
+	var review float64
+	for _, c := range snp.ClinicalData {
+		sources[c.Source] = true
+		review = math.Max(review, reviewWeights[c.ReviewStatus])
+	}
+	for _, p := range snp.Phenotypes {
+		sources[p.Source] = true
+	}
+	for _, r := range snp.References {
+		sources[r.Source] = true
+	}
+	populations := make(map[string]bool)
+	for _, f := range snp.PopulationData {
+		sources[f.Source] = true
+		populations[f.PopulationCode] = true
+	}
+	for _, r := range snp.RiskAlleles {
+		sources[r.Source] = true
+	}
+	// References stored before their source was recorded have none.
+	delete(sources, "")
+
+	q.SourceCount, q.PopulationCount = len(sources), len(populations)
+	q.SourceScore = round(maxQualityDimension * float64(min(q.SourceCount, qualitySources)) / qualitySources)
+	q.ReviewScore = round(maxQualityDimension * review)
+	q.FrequencyScore = round(maxQualityDimension * float64(min(q.PopulationCount, qualityPopulations)) / qualityPopulations)
+	q.Score = round(q.FieldScore + q.SourceScore + q.ReviewScore + q.FrequencyScore)
+	return q
+}
@@ -0,0 +1,48 @@
+package scoring
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/mkoziy/genome/exporter/internal/models"
+)
+
+func TestQuality(t *testing.T) {
+	gene := "CFTR"
+	snp := &models.SNP{
+		ID:         1,
+		GeneSymbol: &gene,
+		ClinicalData: []*models.ClinicalData{
+			{Source: models.SourceClinVar, ReviewStatus: models.ReviewExpertPanel},
+			{Source: models.SourceClinVar, ReviewStatus: models.ReviewSingleSubmitter},
+		},
+		References: []*models.Reference{{}},
+		PopulationData: []*models.PopulationFreq{
+			{Source: models.SourceGnomAD, PopulationCode: "EUR", Allele: "T"},
+			{Source: models.SourceGnomAD, PopulationCode: "EUR", Allele: "C"},
+			{Source: models.SourceGnomAD, PopulationCode: "AFR", Allele: "T"},
+		},
+	}
+
+	q := Quality(snp)
+	if q.SNPID != 1 || q.SourceCount != 2 || q.PopulationCount != 2 {
+		t.Fatalf("unexpected counts: %+v", q)
+	}
+	want := []string{"functional_class", "hgvs_coding", "hgvs_protein", "grch37_position"}
+	if !reflect.DeepEqual([]string(q.MissingFields), want) {
+		t.Fatalf("unexpected missing fields %q", q.MissingFields)
+	}
+	if q.FieldScore != 5 || q.SourceScore != 16.7 || q.ReviewScore != 25*reviewWeights[models.ReviewExpertPanel] || q.FrequencyScore != 10 {
+		t.Fatalf("unexpected dimensions: %+v", q)
+	}
+	if q.Score != round(q.FieldScore+q.SourceScore+q.ReviewScore+q.FrequencyScore) {
+		t.Fatalf("score %v is not the sum of its dimensions", q.Score)
+	}
+}
+
+func TestQualityEmpty(t *testing.T) {
+	q := Quality(&models.SNP{ID: 1})
+	if q.Score != 0 || len(q.MissingFields) != len(qualityFields) || q.IsWellCharacterized() {
+		t.Fatalf("unexpected quality of a bare SNP: %+v", q)
+	}
+}
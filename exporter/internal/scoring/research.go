@@ -0,0 +1,63 @@
+package scoring
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/mkoziy/genome/exporter/internal/models"
+)
+
+// Research dimension split: recency-weighted study count, total citations and
+// studies in high-impact journals.
+const (
+	maxStudies    = 12.0
+	maxCitations  = 10.0
+	maxHighImpact = 8.0
+)
+
+// scoreResearch counts PubMed studies, discounting each by its age so a
+// finding backed by recent work outranks one cited once in the 1990s.
+// References without a publication year count as one half-life old.
+func (s *Scorer) scoreResearch(refs []*models.Reference, details *models.ScoreBreakdown) float64 {
+	r := &details.ResearchDetails
+	year := s.now().Year()
+	for _, ref := range refs {
+		r.CitationTotal += ref.CitationCount
+		if ref.Journal != nil && s.journals[normalizeJournal(*ref.Journal)] {
+			r.HighImpactStudies++
+		}
+		if ref.PubmedID == nil {
+			continue
+		}
+		r.PubmedCount++
+		age := s.cfg.RecencyHalfLifeYears
+		if ref.PublicationYear != nil {
+			age = math.Max(0, float64(year-*ref.PublicationYear))
+		}
+		r.WeightedStudies += math.Pow(0.5, age/s.cfg.RecencyHalfLifeYears)
+	}
+	r.WeightedStudies = round(r.WeightedStudies)
+
+	studies := round(math.Min(3*r.WeightedStudies, maxStudies))
+	details.AddReason(studies, plural(r.PubmedCount, models.ReasonPubMedReference, models.ReasonPubMedReferences),
+		r.PubmedCount, strconv.FormatFloat(r.WeightedStudies, 'f', -1, 64))
+
+	// Every tenfold increase in citations adds 5 points: 10 → 5, 100 → 10.
+	citations := round(math.Min(5*math.Log10(1+float64(r.CitationTotal)), maxCitations))
+	details.AddReason(citations, plural(r.CitationTotal, models.ReasonCitation, models.ReasonCitations), r.CitationTotal)
+
+	highImpact := math.Min(4*float64(r.HighImpactStudies), maxHighImpact)
+	details.AddReason(highImpact, plural(r.HighImpactStudies, models.ReasonHighImpact, models.ReasonHighImpacts), r.HighImpactStudies)
+
+	return round(studies + citations + highImpact)
+}
+
+// normalizeJournal lowercases name and drops punctuation, so "N. Engl. J. Med."
+// matches "N Engl J Med".
+func normalizeJournal(name string) string {
+	return strings.Join(strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}), " ")
+}
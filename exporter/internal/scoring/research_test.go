@@ -0,0 +1,79 @@
+package scoring
+
+import (
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/mkoziy/genome/exporter/internal/models"
+)
+
+func TestScoreResearch(t *testing.T) {
+	s := New(Config{HighImpactJournals: []string{"N Engl J Med"}, RecencyHalfLifeYears: 10})
+	s.now = func() time.Time { return time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC) }
+
+	pmid := func(id string) *string { return &id }
+	year := func(y int) *int { return &y }
+	journal := func(j string) *string { return &j }
+	refs := []*models.Reference{
+		{PubmedID: pmid("1"), PublicationYear: year(2024), Journal: journal("N. Engl. J. Med."), CitationCount: 40},
+		{PubmedID: pmid("2"), PublicationYear: year(2014), Journal: journal("Some Journal"), CitationCount: 9},
+		{PubmedID: pmid("3"), PublicationYear: year(2004)},
+		{Journal: journal("n engl j med")}, // no PubMed ID: not a study, but still high impact
+	}
+
+	var details models.ScoreBreakdown
+	score := s.scoreResearch(refs, &details)
+
+	r := details.ResearchDetails
+	if r.PubmedCount != 3 || r.WeightedStudies != 1.8 || r.CitationTotal != 49 || r.HighImpactStudies != 2 {
+		t.Fatalf("unexpected details: %+v", r)
+	}
+	want := []string{
+		"3 PubMed references, 1.8 recency-weighted (+5.4)",
+		"49 citations (+8.5)",
+		"2 high-impact journal studies (+8)",
+	}
+	if !reflect.DeepEqual(details.Reasons, want) {
+		t.Fatalf("unexpected reasons:\n got %q\nwant %q", details.Reasons, want)
+	}
+	if score != 21.9 {
+		t.Fatalf("expected 21.9, got %v", score)
+	}
+}
+
+func TestScoreResearchCaps(t *testing.T) {
+	refs := make([]*models.Reference, 0)
+	for i := 0; i < 10; i++ {
+		id, y, j := "x", time.Now().Year(), "Nature"
+		refs = append(refs, &models.Reference{PubmedID: &id, PublicationYear: &y, Journal: &j, CitationCount: 1000})
+	}
+
+	var details models.ScoreBreakdown
+	if score := New(DefaultConfig()).scoreResearch(refs, &details); score != MaxResearch {
+		t.Fatalf("expected capped score %v, got %v", MaxResearch, score)
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	data, err := os.ReadFile("../../config/scoring.yaml")
+	if err != nil {
+		t.Fatalf("read config: %v", err)
+	}
+	cfg, err := LoadConfig(data)
+	if err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+	if len(cfg.HighImpactJournals) == 0 || cfg.RecencyHalfLifeYears != 10 {
+		t.Fatalf("unexpected config: %+v", cfg)
+	}
+
+	cfg, err = LoadConfig([]byte("high_impact_journals: [Nature]\n"))
+	if err != nil {
+		t.Fatalf("load partial config: %v", err)
+	}
+	if len(cfg.HighImpactJournals) != 1 || cfg.RecencyHalfLifeYears != DefaultConfig().RecencyHalfLifeYears {
+		t.Fatalf("expected defaults for unset fields, got %+v", cfg)
+	}
+}
@@ -0,0 +1,154 @@
+package scoring
+
+import (
+	"math"
+	"time"
+
+	"github.com/mkoziy/genome/exporter/internal/models"
+)
+
+// AlgorithmVersion identifies the scoring formula. Bump it whenever a change
+// would give an unchanged SNP a different score, so stored scores from the old
+// formula are recalculated and archived.
+const AlgorithmVersion = 3
+
+// Maximum points per dimension; they add up to a 0-100 total.
+const (
+	MaxClinical   = 40.0
+	MaxResearch   = 30.0
+	MaxPopulation = 20.0
+	MaxFunctional = 10.0
+)
+
+// clinicalPoints is the full-confidence value of each assertion.
+var clinicalPoints = map[models.ClinicalSignificance]float64{
+	models.ClinicalPathogenic:       40,
+	models.ClinicalLikelyPathogenic: 30,
+	models.ClinicalRiskFactor:       25,
+	models.ClinicalDrugResponse:     20,
+	models.ClinicalProtective:       15,
+	models.ClinicalAssociation:      15,
+	models.ClinicalUncertainSignif:  10,
+	models.ClinicalLikelyBenign:     5,
+	models.ClinicalBenign:           5,
+	models.ClinicalOther:            5,
+}
+
+// reviewWeights discount assertions by how thoroughly they were reviewed.
+var reviewWeights = map[models.ReviewStatus]float64{
+	models.ReviewPracticeGuideline: 1.0,
+	models.ReviewExpertPanel:       1.0,
+	models.ReviewMultipleSubmitter: 0.9,
+	models.ReviewCriteriaProvided:  0.8,
+	models.ReviewSingleSubmitter:   0.7,
+	models.ReviewNoAssertion:       0.5,
+}
+
+// Scorer calculates significance scores under one Config.
+type Scorer struct {
+	cfg      Config
+	journals map[string]bool
+	now      func() time.Time
+}
+
+// New returns a Scorer using cfg, with defaults applied to unset fields.
+func New(cfg Config) *Scorer {
+	cfg = applyDefaults(cfg)
+	journals := make(map[string]bool, len(cfg.HighImpactJournals))
+	for _, journal := range cfg.HighImpactJournals {
+		journals[normalizeJournal(journal)] = true
+	}
+	return &Scorer{cfg: cfg, journals: journals, now: time.Now}
+}
+
+// Score calculates the significance of snp with the default configuration.
+func Score(snp *models.SNP) *models.Significance {
+	return New(DefaultConfig()).Score(snp)
+}
+
+// Score calculates the significance of snp from its preloaded clinical,
+// clinical agreement, reference and population relations. The result is ready for
+// SignificanceRepository.Save; ScoreDetails.Reasons lists every contribution
+// in the order the dimensions are scored.
+func (s *Scorer) Score(snp *models.SNP) *models.Significance {
+	sig := &models.Significance{SNPID: snp.ID, AlgorithmVersion: AlgorithmVersion}
+	details := &sig.ScoreDetails
+
+	sig.ClinicalScore = scoreClinical(snp.ClinicalData, snp.ClinicalAgreements, details)
+	sig.ResearchScore = s.scoreResearch(snp.References, details)
+	sig.PopulationScore = scorePopulation(snp.PopulationData, details)
+	sig.FunctionalScore = scoreFunctional(snp, details)
+	sig.TotalScore = round(sig.ClinicalScore + sig.ResearchScore + sig.PopulationScore + sig.FunctionalScore)
+	return sig
+}
+
+// scoreClinical scores the best clinical assertion, discounted by the share
+// of the submissions on its condition that classify the SNP otherwise.
+func scoreClinical(rows []*models.ClinicalData, agreements []*models.ClinicalAgreement, details *models.ScoreBreakdown) float64 {
+	var best float64
+	var bestRow *models.ClinicalData
+	conditions := make(map[string]bool)
+	for _, row := range rows {
+		conditions[row.ConditionName] = true
+		if row.IsPathogenic() {
+			details.ClinicalDetails.HasPathogenic = true
+		}
+		weight := reviewWeights[row.ReviewStatus]
+		if points := clinicalPoints[row.ClinicalSignificance] * weight; points > best {
+			best, bestRow = points, row
+			details.ClinicalDetails.ReviewStatusScore = weight
+		}
+	}
+	details.ClinicalDetails.ConditionCount = len(conditions)
+
+	best = round(math.Min(best, MaxClinical))
+	if bestRow == nil {
+		return best
+	}
+	details.AddReason(best, models.ReasonClinicalAssertion, models.LabelMessage(string(bestRow.ReviewStatus)), models.LabelMessage(string(bestRow.ClinicalSignificance)))
+	for _, a := range agreements {
+		if !a.Conflicting || a.Source != bestRow.Source || a.ConditionKey() != bestRow.ConditionKey() {
+			continue
+		}
+		details.ClinicalDetails.Conflicting = true
+		against := a.Against(bestRow.ClinicalSignificance)
+		if against == 0 {
+			break
+		}
+		penalty := round(best * float64(against) / float64(a.PathogenicCount+a.BenignCount+a.UncertainCount))
+		details.AddReason(-penalty, plural(against, models.ReasonDissentingSubmission, models.ReasonDissentingSubmissions), against)
+		return best - penalty
+	}
+	return best
+}
+
+func scoreFunctional(snp *models.SNP, details *models.ScoreBreakdown) float64 {
+	f := &details.FunctionalDetails
+	f.IsProteinChanging = snp.IsProteinCoding()
+	f.IsRegulatory = snp.FunctionalClass != nil &&
+		(*snp.FunctionalClass == models.FuncRegulatory || *snp.FunctionalClass == models.FuncUTR5 || *snp.FunctionalClass == models.FuncUTR3)
+
+	var points float64
+	switch {
+	case f.IsProteinChanging:
+		points = MaxFunctional
+	case f.IsRegulatory:
+		points = MaxFunctional / 2
+	}
+	if points > 0 {
+		details.AddReason(points, models.ReasonFunctionalVariant, models.LabelMessage(string(*snp.FunctionalClass)))
+	}
+	return points
+}
+
+func plural(n int, one, many models.Message) models.Message {
+	if n == 1 {
+		return one
+	}
+	return many
+}
+
+// round keeps one decimal so scores and reasons agree when displayed.
+func round(v float64) float64 {
+	return math.Round(v*10) / 10
+}
@@ -0,0 +1,109 @@
+package scoring
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/mkoziy/genome/exporter/internal/models"
+)
+
+func TestScore(t *testing.T) {
+	missense := models.FuncMissense
+	pmid := "123"
+	snp := &models.SNP{
+		ID:              7,
+		FunctionalClass: &missense,
+		ClinicalData: []*models.ClinicalData{
+			{ClinicalSignificance: models.ClinicalUncertainSignif, ReviewStatus: models.ReviewSingleSubmitter, ConditionName: "A"},
+			{ClinicalSignificance: models.ClinicalPathogenic, ReviewStatus: models.ReviewExpertPanel, ConditionName: "B"},
+		},
+		References: []*models.Reference{
+			{PubmedID: &pmid, CitationCount: 999},
+			{CitationCount: 0},
+		},
+		PopulationData: []*models.PopulationFreq{
+			{PopulationCode: "EUR", Frequency: 0.998},
+			{PopulationCode: "AFR", Frequency: 0.001},
+		},
+	}
+
+	sig := Score(snp)
+	if sig.SNPID != 7 {
+		t.Fatalf("expected SNPID 7, got %d", sig.SNPID)
+	}
+	if sig.ClinicalScore != 40 || sig.ResearchScore != 11.5 || sig.PopulationScore != 3.3 || sig.FunctionalScore != 10 {
+		t.Fatalf("unexpected dimension scores: %+v", sig)
+	}
+	if sig.TotalScore != 64.8 {
+		t.Fatalf("expected total 64.8, got %v", sig.TotalScore)
+	}
+
+	want := []string{
+		"expert-panel pathogenic assertion (+40)",
+		"1 PubMed reference, 0.5 recency-weighted (+1.5)",
+		"999 citations (+10)",
+		"MAF 0.2% in EUR (+0.5)",
+		"rare in all 2 populations (+2.8)",
+		"missense variant (+10)",
+	}
+	if got := sig.ScoreDetails.Reasons; !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected reasons:\n got %q\nwant %q", got, want)
+	}
+	if d := sig.ScoreDetails.ClinicalDetails; !d.HasPathogenic || d.ConditionCount != 2 || d.ReviewStatusScore != 1 {
+		t.Fatalf("unexpected clinical details: %+v", d)
+	}
+}
+
+func TestScoreReviewDiscount(t *testing.T) {
+	snp := &models.SNP{ClinicalData: []*models.ClinicalData{
+		{ClinicalSignificance: models.ClinicalLikelyPathogenic, ReviewStatus: models.ReviewSingleSubmitter},
+	}}
+
+	sig := Score(snp)
+	if sig.ClinicalScore != 21 {
+		t.Fatalf("expected 30 * 0.7 = 21, got %v", sig.ClinicalScore)
+	}
+	want := []string{"single-submitter likely pathogenic assertion (+21)"}
+	if got := sig.ScoreDetails.Reasons; !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected reasons: %q", got)
+	}
+}
+
+func TestScoreConflictingSubmissions(t *testing.T) {
+	snp := &models.SNP{
+		ClinicalData: []*models.ClinicalData{
+			{ClinicalSignificance: models.ClinicalPathogenic, ReviewStatus: models.ReviewExpertPanel, ConditionName: "A", Source: models.SourceClinVar},
+		},
+		ClinicalAgreements: []*models.ClinicalAgreement{
+			{ConditionName: "B", PathogenicCount: 1, BenignCount: 3, Conflicting: true, Agreement: 0.75, Source: models.SourceClinVar},
+		},
+	}
+	if sig := Score(snp); sig.ClinicalScore != 40 || sig.ScoreDetails.ClinicalDetails.Conflicting {
+		t.Fatalf("expected a conflict on another condition ignored, got %v", sig.ClinicalScore)
+	}
+
+	snp.ClinicalAgreements[0].ConditionName = "A"
+	sig := Score(snp)
+	if sig.ClinicalScore != 10 || !sig.ScoreDetails.ClinicalDetails.Conflicting {
+		t.Fatalf("expected 40 discounted by the 3 in 4 classifying it benign to 10, got %v", sig.ClinicalScore)
+	}
+	want := []string{"expert-panel pathogenic assertion (+40)", "3 conflicting submissions (-30)"}
+	if got := sig.ScoreDetails.Reasons; !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected reasons: %q", got)
+	}
+
+	// The condition is matched on its ID, whatever each row names it.
+	id := "MONDO:0000001"
+	snp.ClinicalData[0].ConditionID = &id
+	snp.ClinicalAgreements[0].ConditionID, snp.ClinicalAgreements[0].ConditionName = &id, "A, renamed"
+	if sig := Score(snp); sig.ClinicalScore != 10 {
+		t.Fatalf("expected a conflict on the same condition ID to discount, got %v", sig.ClinicalScore)
+	}
+}
+
+func TestScoreNoEvidence(t *testing.T) {
+	sig := Score(&models.SNP{})
+	if sig.TotalScore != 0 || len(sig.ScoreDetails.Reasons) != 0 {
+		t.Fatalf("expected empty score, got %v with %q", sig.TotalScore, sig.ScoreDetails.Reasons)
+	}
+}
@@ -0,0 +1,147 @@
+package search
+
+import (
+	"slices"
+	"time"
+
+	"github.com/mkoziy/genome/exporter/internal/models"
+)
+
+// Document is a SNP as indexed: its coordinates and score flattened, and its
+// clinical assertions and phenotype associations as nested documents, so a
+// query can match a significance and a condition of the same assertion.
+type Document struct {
+	RsID             string                  `json:"rsid"`
+	Chromosome       string                  `json:"chromosome"`
+	Position         int64                   `json:"position"`
+	ReferenceAllele  string                  `json:"reference_allele"`
+	AlternateAlleles []string                `json:"alternate_alleles"`
+	VariantKey       *string                 `json:"variant_key,omitempty"`
+	HGVS             []string                `json:"hgvs,omitempty"`
+	GeneSymbol       *string                 `json:"gene_symbol,omitempty"`
+	GeneID           *string                 `json:"gene_id,omitempty"`
+	VariantType      models.VariantType      `json:"variant_type"`
+	FunctionalClass  *models.FunctionalClass `json:"functional_class,omitempty"`
+	Score            *float64                `json:"score,omitempty"`
+	Percentile       *float64                `json:"percentile,omitempty"`
+	// MaxFrequency is the highest allele frequency in any population.
+	MaxFrequency *float64 `json:"max_frequency,omitempty"`
+	// Sources are the sources anything about the SNP came from.
+	Sources    []models.DataSource `json:"sources"`
+	PubmedIDs  []string            `json:"pubmed_ids,omitempty"`
+	UpdatedAt  time.Time           `json:"updated_at"`
+	Clinical   []ClinicalDocument  `json:"clinical"`
+	Phenotypes []PhenotypeDocument `json:"phenotypes"`
+}
+
+// ClinicalDocument is a clinical assertion nested in a Document.
+type ClinicalDocument struct {
+	Significance  models.ClinicalSignificance `json:"significance"`
+	ReviewStatus  models.ReviewStatus         `json:"review_status"`
+	Condition     string                      `json:"condition"`
+	ConditionID   *string                     `json:"condition_id,omitempty"`
+	Inheritance   *string                     `json:"inheritance,omitempty"`
+	Source        models.DataSource           `json:"source"`
+	LastEvaluated *time.Time                  `json:"last_evaluated,omitempty"`
+}
+
+// PhenotypeDocument is a phenotype association nested in a Document.
+type PhenotypeDocument struct {
+	Name            string            `json:"name"`
+	PhenotypeID     *string           `json:"phenotype_id,omitempty"`
+	AssociationType string            `json:"association_type"`
+	OddsRatio       *float64          `json:"odds_ratio,omitempty"`
+	PValue          *float64          `json:"p_value,omitempty"`
+	StudyType       *string           `json:"study_type,omitempty"`
+	Source          models.DataSource `json:"source"`
+}
+
+// NewDocument builds the document of snp, which must have its relations
+// loaded.
+func NewDocument(snp *models.SNP) *Document {
+	doc := &Document{
+		RsID:             snp.RsID,
+		Chromosome:       snp.Chromosome,
+		Position:         snp.Position,
+		ReferenceAllele:  snp.ReferenceAllele,
+		AlternateAlleles: []string(snp.AlternateAlleles),
+		VariantKey:       snp.VariantKey,
+		GeneSymbol:       snp.GeneSymbol,
+		GeneID:           snp.GeneID,
+		VariantType:      snp.VariantType,
+		FunctionalClass:  snp.FunctionalClass,
+		UpdatedAt:        snp.UpdatedAt,
+		Clinical:         make([]ClinicalDocument, 0, len(snp.ClinicalData)),
+		Phenotypes:       make([]PhenotypeDocument, 0, len(snp.Phenotypes)),
+	}
+	if doc.AlternateAlleles == nil {
+		doc.AlternateAlleles = []string{}
+	}
+	for _, hgvs := range []*string{snp.HGVSGenomic, snp.HGVSCoding, snp.HGVSProtein} {
+		if hgvs != nil {
+			doc.HGVS = append(doc.HGVS, *hgvs)
+		}
+	}
+	if sig := snp.Significance; sig != nil {
+		doc.Score = &sig.TotalScore
+		doc.Percentile = sig.Percentile
+	}
+
+	sources := make(map[models.DataSource]bool)
+	for _, c := range snp.ClinicalData {
+		doc.Clinical = append(doc.Clinical, ClinicalDocument{
+			Significance:  c.ClinicalSignificance,
+			ReviewStatus:  c.ReviewStatus,
+			Condition:     c.ConditionName,
+			ConditionID:   c.ConditionID,
+			Inheritance:   c.InheritancePattern,
+			Source:        c.Source,
+			LastEvaluated: c.LastEvaluated,
+		})
+		sources[c.Source] = true
+	}
+	for _, p := range snp.Phenotypes {
+		doc.Phenotypes = append(doc.Phenotypes, PhenotypeDocument{
+			Name:            p.PhenotypeName,
+			PhenotypeID:     p.PhenotypeID,
+			AssociationType: p.AssociationType,
+			OddsRatio:       nullable(p.OddsRatio),
+			PValue:          nullable(p.PValue),
+			StudyType:       p.StudyType,
+			Source:          p.Source,
+		})
+		sources[p.Source] = true
+	}
+	for _, pop := range snp.PopulationData {
+		if doc.MaxFrequency == nil || pop.Frequency > *doc.MaxFrequency {
+			freq := pop.Frequency
+			doc.MaxFrequency = &freq
+		}
+		sources[pop.Source] = true
+	}
+	for _, ref := range snp.References {
+		if ref.PubmedID != nil && !slices.Contains(doc.PubmedIDs, *ref.PubmedID) {
+			doc.PubmedIDs = append(doc.PubmedIDs, *ref.PubmedID)
+		}
+		if ref.Source != "" {
+			sources[ref.Source] = true
+		}
+	}
+	for _, r := range snp.RiskAlleles {
+		sources[r.Source] = true
+	}
+
+	doc.Sources = make([]models.DataSource, 0, len(sources))
+	for source := range sources {
+		doc.Sources = append(doc.Sources, source)
+	}
+	slices.Sort(doc.Sources)
+	return doc
+}
+
+func nullable(n *models.NullableFloat64) *float64 {
+	if n == nil || !n.Valid {
+		return nil
+	}
+	return &n.Float64
+}
@@ -0,0 +1,91 @@
+package search
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/uptrace/bun"
+
+	"github.com/mkoziy/genome/exporter/internal/models"
+	"github.com/mkoziy/genome/exporter/internal/repositories"
+)
+
+// Stats counts the documents written.
+type Stats struct {
+	Indexed int `json:"indexed"`
+	Deleted int `json:"deleted"`
+	// Cursor is the ID of the last change applied by Sync.
+	Cursor int64 `json:"cursor,omitempty"`
+}
+
+// Export indexes every SNP of db, batchSize per bulk request, creating the
+// index first if it does not exist.
+func Export(ctx context.Context, db *bun.DB, c *Client, batchSize int) (*Stats, error) {
+	if _, err := c.EnsureIndex(ctx); err != nil {
+		return nil, err
+	}
+	stats := &Stats{}
+	err := repositories.ForEachSNP(ctx, db, batchSize, func(batch []*models.SNP) error {
+		b := &Bulk{Index: make([]*Document, 0, len(batch))}
+		for _, snp := range batch {
+			b.Index = append(b.Index, NewDocument(snp))
+		}
+		if err := c.Send(ctx, b); err != nil {
+			return err
+		}
+		stats.Indexed += len(b.Index)
+		return nil
+	})
+	return stats, err
+}
+
+// Sync applies the change feed after the change with ID since to the index,
+// limit changes per bulk request: each SNP changed is indexed again, and
+// each SNP deleted has its document deleted. Stats.Cursor is the change to
+// resume after, also when an error stops the sync part way.
+func Sync(ctx context.Context, db *bun.DB, c *Client, since int64, limit int) (*Stats, error) {
+	if _, err := c.EnsureIndex(ctx); err != nil {
+		return nil, err
+	}
+	stats := &Stats{Cursor: since}
+	for {
+		changes, err := repositories.ListChanges(ctx, db, stats.Cursor, limit)
+		if err != nil {
+			return stats, fmt.Errorf("list changes: %w", err)
+		}
+		if len(changes) == 0 {
+			return stats, nil
+		}
+
+		rsIDs := make(map[int64]string)
+		var ids []int64
+		for _, change := range changes {
+			if _, ok := rsIDs[change.SNPID]; !ok {
+				ids = append(ids, change.SNPID)
+				rsIDs[change.SNPID] = ""
+			}
+			// Rows deleted along with their SNP no longer know its rsID.
+			if change.RsID != nil {
+				rsIDs[change.SNPID] = *change.RsID
+			}
+		}
+		current, err := repositories.GetSNPsByIDs(ctx, db, ids)
+		if err != nil {
+			return stats, fmt.Errorf("load snps: %w", err)
+		}
+		b := &Bulk{}
+		for _, id := range ids {
+			if snp, ok := current[id]; ok {
+				b.Index = append(b.Index, NewDocument(snp))
+			} else if rsIDs[id] != "" {
+				b.Delete = append(b.Delete, rsIDs[id])
+			}
+		}
+		if err := c.Send(ctx, b); err != nil {
+			return stats, err
+		}
+		stats.Indexed += len(b.Index)
+		stats.Deleted += len(b.Delete)
+		stats.Cursor = changes[len(changes)-1].ID
+	}
+}
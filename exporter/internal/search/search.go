@@ -0,0 +1,282 @@
+// Package search bulk-indexes SNPs into Elasticsearch or OpenSearch, with
+// their clinical assertions and phenotype associations as nested documents,
+// for variant browsers that query the data by text and facets.
+package search
+
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Mapping is the index settings and mappings the documents are written for.
+// EnsureIndex creates the index with it; it may also be installed by hand,
+// e.g. as the template of an alias's dated indices.
+//
+//go:embed mapping.json
+var Mapping []byte
+
+// Defaults of Config.
+const (
+	DefaultIndex   = "snps"
+	DefaultTimeout = time.Minute
+)
+
+// Config locates the cluster and index. Username and Password, or APIKey,
+// authenticate when the cluster requires it.
+type Config struct {
+	URL      string        `yaml:"url" json:"url,omitempty"`
+	Index    string        `yaml:"index" json:"index"`
+	Username string        `yaml:"username" json:"username,omitempty"`
+	Password string        `yaml:"password" json:"-"`
+	APIKey   string        `yaml:"api_key" json:"-"`
+	Timeout  time.Duration `yaml:"timeout" json:"timeout"`
+}
+
+// Validate reports every invalid setting at once, naming fields relative to c.
+func (c Config) Validate() error {
+	var errs []error
+	if c.URL != "" {
+		if u, err := url.Parse(c.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("url: want an http or https URL, got %q", c.URL))
+		}
+	}
+	if c.Index != "" && (strings.ToLower(c.Index) != c.Index || strings.ContainsAny(c.Index, ` "*\<|,>/?#:`)) {
+		errs = append(errs, fmt.Errorf("index: invalid index name %q", c.Index))
+	}
+	if c.APIKey != "" && c.Username != "" {
+		errs = append(errs, errors.New("api_key: set either api_key or username, not both"))
+	}
+	if c.Timeout < 0 {
+		errs = append(errs, errors.New("timeout: must not be negative"))
+	}
+	return errors.Join(errs...)
+}
+
+// Client talks to the REST API of a cluster.
+type Client struct {
+	cfg    Config
+	client *http.Client
+}
+
+// NewClient creates a client for cfg, which must name a URL.
+func NewClient(cfg Config) (*Client, error) {
+	if cfg.URL == "" {
+		return nil, errors.New("search url is not configured")
+	}
+	if cfg.Index == "" {
+		cfg.Index = DefaultIndex
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = DefaultTimeout
+	}
+	return &Client{cfg: cfg, client: http.DefaultClient}, nil
+}
+
+// WithHTTPClient replaces the client requests are sent with.
+func (c *Client) WithHTTPClient(client *http.Client) *Client {
+	c.client = client
+	return c
+}
+
+// Index returns the name of the index written to.
+func (c *Client) Index() string {
+	return c.cfg.Index
+}
+
+// EnsureIndex creates the index with Mapping unless it exists, and reports
+// whether it did. An existing index is left as it is.
+func (c *Client) EnsureIndex(ctx context.Context) (bool, error) {
+	resp, err := c.do(ctx, http.MethodHead, "/"+url.PathEscape(c.cfg.Index), "", nil)
+	if err != nil {
+		return false, err
+	}
+	_ = resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return false, nil
+	case http.StatusNotFound:
+	default:
+		return false, fmt.Errorf("check index %s: %s", c.cfg.Index, resp.Status)
+	}
+
+	resp, err = c.do(ctx, http.MethodPut, "/"+url.PathEscape(c.cfg.Index), "application/json", Mapping)
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	if resp.StatusCode >= 300 {
+		return false, fmt.Errorf("create index %s: %s", c.cfg.Index, errorReason(resp))
+	}
+	return true, nil
+}
+
+// Bulk is one request to the bulk API: documents to index and rsIDs whose
+// documents to delete.
+type Bulk struct {
+	Index  []*Document
+	Delete []string
+}
+
+// Len returns the number of actions in b.
+func (b *Bulk) Len() int {
+	return len(b.Index) + len(b.Delete)
+}
+
+// BulkError lists the actions of a bulk request the cluster rejected.
+type BulkError struct {
+	// Failed maps the rsID of each rejected action to the reason.
+	Failed map[string]string
+}
+
+func (e *BulkError) Error() string {
+	ids := slices.Sorted(maps.Keys(e.Failed))
+	if len(ids) == 1 {
+		return fmt.Sprintf("bulk: %s: %s", ids[0], e.Failed[ids[0]])
+	}
+	return fmt.Sprintf("bulk: %d actions failed, e.g. %s: %s", len(ids), ids[0], e.Failed[ids[0]])
+}
+
+// Send writes b with one bulk request. Documents are keyed by rsID, so
+// indexing a SNP again replaces its document. Deleting a document that does
+// not exist is not an error. Actions the cluster rejects are returned as a
+// *BulkError; the others are applied.
+func (c *Client) Send(ctx context.Context, b *Bulk) error {
+	if b.Len() == 0 {
+		return nil
+	}
+	var body bytes.Buffer
+	enc := json.NewEncoder(&body)
+	type action struct {
+		ID string `json:"_id"`
+	}
+	for _, doc := range b.Index {
+		if err := enc.Encode(map[string]action{"index": {ID: doc.RsID}}); err != nil {
+			return err
+		}
+		if err := enc.Encode(doc); err != nil {
+			return fmt.Errorf("encode %s: %w", doc.RsID, err)
+		}
+	}
+	for _, id := range b.Delete {
+		if err := enc.Encode(map[string]action{"delete": {ID: id}}); err != nil {
+			return err
+		}
+	}
+
+	resp, err := c.do(ctx, http.MethodPost, "/"+url.PathEscape(c.cfg.Index)+"/_bulk", "application/x-ndjson", body.Bytes())
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("bulk: %s", errorReason(resp))
+	}
+
+	var outcome struct {
+		Errors bool                `json:"errors"`
+		Items  []map[string]result `json:"items"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&outcome); err != nil {
+		return fmt.Errorf("decode bulk response: %w", err)
+	}
+	if !outcome.Errors {
+		return nil
+	}
+	failed := make(map[string]string)
+	for _, item := range outcome.Items {
+		for op, r := range item {
+			if r.Status < 300 || (op == "delete" && r.Status == http.StatusNotFound) {
+				continue
+			}
+			failed[r.ID] = r.reason()
+		}
+	}
+	if len(failed) == 0 {
+		return nil
+	}
+	return &BulkError{Failed: failed}
+}
+
+// result is the outcome of one bulk action.
+type result struct {
+	ID     string `json:"_id"`
+	Status int    `json:"status"`
+	Error  *struct {
+		Type   string `json:"type"`
+		Reason string `json:"reason"`
+	} `json:"error"`
+}
+
+func (r result) reason() string {
+	if r.Error == nil {
+		return http.StatusText(r.Status)
+	}
+	return r.Error.Type + ": " + r.Error.Reason
+}
+
+func (c *Client) do(ctx context.Context, method, path, contentType string, body []byte) (*http.Response, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.cfg.Timeout)
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimSuffix(c.cfg.URL, "/")+path, bytes.NewReader(body))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	switch {
+	case c.cfg.APIKey != "":
+		req.Header.Set("Authorization", "ApiKey "+c.cfg.APIKey)
+	case c.cfg.Username != "":
+		req.SetBasicAuth(c.cfg.Username, c.cfg.Password)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("%s %s: %w", method, path, err)
+	}
+	resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+// cancelBody releases the request's timeout once the body is closed.
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	defer b.cancel()
+	return b.ReadCloser.Close()
+}
+
+// errorReason describes a failed response by the error the cluster returned,
+// or its status.
+func errorReason(resp *http.Response) string {
+	var body struct {
+		Error struct {
+			Type   string `json:"type"`
+			Reason string `json:"reason"`
+		} `json:"error"`
+	}
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if json.Unmarshal(data, &body) == nil && body.Error.Reason != "" {
+		return fmt.Sprintf("%s: %s: %s", resp.Status, body.Error.Type, body.Error.Reason)
+	}
+	return resp.Status
+}
@@ -0,0 +1,262 @@
+package search
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/uptrace/bun"
+
+	"github.com/mkoziy/genome/exporter/internal/database"
+	"github.com/mkoziy/genome/exporter/internal/migrations"
+	"github.com/mkoziy/genome/exporter/internal/models"
+)
+
+// fakeCluster keeps the documents of one index in memory, rejecting those
+// whose rsID is in reject.
+type fakeCluster struct {
+	t       *testing.T
+	mu      sync.Mutex
+	created bool
+	mapping map[string]any
+	docs    map[string]*Document
+	auth    string
+	reject  map[string]bool
+}
+
+func newFakeCluster(t *testing.T) (*fakeCluster, *httptest.Server) {
+	f := &fakeCluster{t: t, docs: make(map[string]*Document), reject: make(map[string]bool)}
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+	return f, srv
+}
+
+func (f *fakeCluster) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.auth = r.Header.Get("Authorization")
+	switch {
+	case r.Method == http.MethodHead && r.URL.Path == "/snps":
+		if !f.created {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	case r.Method == http.MethodPut && r.URL.Path == "/snps":
+		if err := json.NewDecoder(r.Body).Decode(&f.mapping); err != nil {
+			f.t.Errorf("decode mapping: %v", err)
+		}
+		f.created = true
+	case r.Method == http.MethodPost && r.URL.Path == "/snps/_bulk":
+		f.bulk(w, r.Body)
+	default:
+		http.Error(w, `{"error":{"type":"illegal_argument_exception","reason":"unexpected request"}}`, http.StatusBadRequest)
+	}
+}
+
+func (f *fakeCluster) bulk(w http.ResponseWriter, body io.Reader) {
+	type item struct {
+		ID     string `json:"_id"`
+		Status int    `json:"status"`
+		Error  any    `json:"error,omitempty"`
+	}
+	var items []map[string]item
+	anyErrors := false
+	lines := bufio.NewScanner(body)
+	lines.Buffer(nil, 1<<20)
+	for lines.Scan() {
+		var action map[string]struct {
+			ID string `json:"_id"`
+		}
+		if err := json.Unmarshal(lines.Bytes(), &action); err != nil {
+			f.t.Fatalf("decode action: %v", err)
+		}
+		if a, ok := action["delete"]; ok {
+			status := http.StatusOK
+			if _, ok := f.docs[a.ID]; !ok {
+				status = http.StatusNotFound
+			}
+			delete(f.docs, a.ID)
+			items = append(items, map[string]item{"delete": {ID: a.ID, Status: status}})
+			continue
+		}
+		id := action["index"].ID
+		lines.Scan()
+		var doc Document
+		if err := json.Unmarshal(lines.Bytes(), &doc); err != nil {
+			f.t.Fatalf("decode document: %v", err)
+		}
+		if f.reject[id] {
+			anyErrors = true
+			items = append(items, map[string]item{"index": {ID: id, Status: http.StatusBadRequest,
+				Error: map[string]string{"type": "mapper_parsing_exception", "reason": "failed to parse"}}})
+			continue
+		}
+		f.docs[id] = &doc
+		items = append(items, map[string]item{"index": {ID: id, Status: http.StatusCreated}})
+	}
+	_ = json.NewEncoder(w).Encode(map[string]any{"errors": anyErrors, "items": items})
+}
+
+func newTestDB(t *testing.T) *bun.DB {
+	t.Helper()
+	db, err := database.NewDB("file:"+t.Name()+"?mode=memory&cache=shared", false)
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	if err := migrations.RunMigrations(context.Background(), db); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	return db
+}
+
+func insertSNP(t *testing.T, db *bun.DB, rsID string) *models.SNP {
+	t.Helper()
+	ctx := context.Background()
+	snp := &models.SNP{RsID: rsID, Chromosome: "7", Position: 117559590, ReferenceAllele: "C",
+		AlternateAlleles: models.StringArray{"T"}, VariantType: models.VariantSNV}
+	if _, err := db.NewInsert().Model(snp).Exec(ctx); err != nil {
+		t.Fatalf("insert snp: %v", err)
+	}
+	clinical := &models.ClinicalData{SNPID: snp.ID, ClinicalSignificance: models.ClinicalPathogenic,
+		ReviewStatus: models.ReviewCriteriaProvided, ConditionName: "Cystic fibrosis", Source: models.SourceClinVar}
+	if _, err := db.NewInsert().Model(clinical).Exec(ctx); err != nil {
+		t.Fatalf("insert clinical: %v", err)
+	}
+	phenotype := &models.Phenotype{SNPID: snp.ID, PhenotypeName: "Lung function", AssociationType: "association",
+		PValue: &models.NullableFloat64{Float64: 1e-9, Valid: true}, Source: models.SourceGWAS}
+	if _, err := db.NewInsert().Model(phenotype).Exec(ctx); err != nil {
+		t.Fatalf("insert phenotype: %v", err)
+	}
+	return snp
+}
+
+func TestExportIndexesNestedDocuments(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+	insertSNP(t, db, "rs113993960")
+	insertSNP(t, db, "rs2")
+	cluster, srv := newFakeCluster(t)
+
+	c, err := NewClient(Config{URL: srv.URL, APIKey: "key"})
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+	stats, err := Export(ctx, db, c, 1)
+	if err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	if stats.Indexed != 2 || len(cluster.docs) != 2 {
+		t.Fatalf("expected 2 documents indexed, got %+v with %d stored", stats, len(cluster.docs))
+	}
+	if cluster.auth != "ApiKey key" {
+		t.Errorf("expected API key auth, got %q", cluster.auth)
+	}
+	props := cluster.mapping["mappings"].(map[string]any)["properties"].(map[string]any)
+	if props["clinical"].(map[string]any)["type"] != "nested" || props["phenotypes"].(map[string]any)["type"] != "nested" {
+		t.Errorf("expected nested clinical and phenotypes in the mapping, got %v", props)
+	}
+
+	doc := cluster.docs["rs113993960"]
+	if len(doc.Clinical) != 1 || doc.Clinical[0].Significance != models.ClinicalPathogenic || doc.Clinical[0].Condition != "Cystic fibrosis" {
+		t.Errorf("unexpected clinical documents: %+v", doc.Clinical)
+	}
+	if len(doc.Phenotypes) != 1 || doc.Phenotypes[0].PValue == nil || *doc.Phenotypes[0].PValue != 1e-9 {
+		t.Errorf("unexpected phenotype documents: %+v", doc.Phenotypes)
+	}
+	if len(doc.Sources) != 2 || doc.Sources[0] != models.SourceClinVar {
+		t.Errorf("unexpected sources: %v", doc.Sources)
+	}
+
+	// The index now exists and is left alone.
+	if created, err := c.EnsureIndex(ctx); err != nil || created {
+		t.Errorf("expected the existing index kept, got created %v (%v)", created, err)
+	}
+}
+
+func TestSyncAppliesChangeFeed(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+	kept := insertSNP(t, db, "rs1")
+	gone := insertSNP(t, db, "rs2")
+	cluster, srv := newFakeCluster(t)
+	c, _ := NewClient(Config{URL: srv.URL, Username: "exporter", Password: "secret"})
+
+	stats, err := Sync(ctx, db, c, 0, 100)
+	if err != nil || stats.Indexed != 2 || len(cluster.docs) != 2 {
+		t.Fatalf("expected both SNPs indexed, got %+v (%v)", stats, err)
+	}
+	if !strings.HasPrefix(cluster.auth, "Basic ") {
+		t.Errorf("expected basic auth, got %q", cluster.auth)
+	}
+
+	if _, err := db.NewUpdate().Model((*models.ClinicalData)(nil)).
+		Set("clinical_significance = ?", models.ClinicalUncertainSignif).
+		Where("snp_id = ?", kept.ID).Exec(ctx); err != nil {
+		t.Fatalf("update clinical: %v", err)
+	}
+	for _, model := range []any{(*models.ClinicalData)(nil), (*models.Phenotype)(nil)} {
+		if _, err := db.NewDelete().Model(model).Where("snp_id = ?", gone.ID).Exec(ctx); err != nil {
+			t.Fatalf("delete rows: %v", err)
+		}
+	}
+	if _, err := db.NewDelete().Model(gone).WherePK().Exec(ctx); err != nil {
+		t.Fatalf("delete snp: %v", err)
+	}
+
+	next, err := Sync(ctx, db, c, stats.Cursor, 100)
+	if err != nil || next.Indexed != 1 || next.Deleted != 1 || next.Cursor <= stats.Cursor {
+		t.Fatalf("expected one SNP reindexed and one deleted, got %+v (%v)", next, err)
+	}
+	if _, ok := cluster.docs["rs2"]; ok {
+		t.Error("expected rs2 deleted from the index")
+	}
+	if got := cluster.docs["rs1"].Clinical[0].Significance; got != models.ClinicalUncertainSignif {
+		t.Errorf("expected rs1 reindexed with its new significance, got %s", got)
+	}
+
+	// Nothing changed since.
+	if idle, err := Sync(ctx, db, c, next.Cursor, 100); err != nil || idle.Indexed+idle.Deleted != 0 || idle.Cursor != next.Cursor {
+		t.Errorf("expected nothing to sync, got %+v (%v)", idle, err)
+	}
+}
+
+func TestSendReportsRejectedDocuments(t *testing.T) {
+	cluster, srv := newFakeCluster(t)
+	cluster.reject["rs2"] = true
+	c, _ := NewClient(Config{URL: srv.URL})
+
+	err := c.Send(context.Background(), &Bulk{
+		Index:  []*Document{{RsID: "rs1"}, {RsID: "rs2"}},
+		Delete: []string{"rs404"},
+	})
+	var bulkErr *BulkError
+	if !errors.As(err, &bulkErr) || len(bulkErr.Failed) != 1 || !strings.Contains(bulkErr.Failed["rs2"], "mapper_parsing_exception") {
+		t.Fatalf("expected only rs2 rejected, got %v", err)
+	}
+	if _, ok := cluster.docs["rs1"]; !ok {
+		t.Error("expected rs1 indexed despite rs2 being rejected")
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	valid := Config{URL: "https://search.example.org:9200", Index: "snps-2026"}
+	if err := valid.Validate(); err != nil {
+		t.Fatalf("expected valid config, got %v", err)
+	}
+	for name, cfg := range map[string]Config{
+		"url":         {URL: "search.example.org"},
+		"index":       {Index: "SNPs"},
+		"credentials": {APIKey: "key", Username: "exporter"},
+	} {
+		if err := cfg.Validate(); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
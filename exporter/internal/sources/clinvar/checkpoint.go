@@ -0,0 +1,44 @@
+package clinvar
+
+// Checkpoint records how far each query of a fetch has progressed so an
+// interrupted download can resume instead of starting again from retstart=0.
+type Checkpoint struct {
+	Queries map[string]*QueryCheckpoint `json:"queries"`
+}
+
+// QueryCheckpoint is the progress of one query. Every batch before RetStart has
+// been emitted. Offsets are only meaningful while the query's result count is
+// unchanged, so a resumed query whose Count differs starts over; rows are
+// upserted, so refetching is safe.
+type QueryCheckpoint struct {
+	Count    int   `json:"count"`
+	RetStart int   `json:"retstart"`
+	MaxID    int64 `json:"max_id"`
+	Done     bool  `json:"done"`
+}
+
+// CheckpointFunc receives a copy of the checkpoint after each batch, together with
+// the number of SNPs emitted so far. The checkpoint is only durable once those
+// SNPs have been written, so callers should persist it after the write commits.
+type CheckpointFunc func(cp Checkpoint, emitted int)
+
+func (c *Checkpoint) query(query string) *QueryCheckpoint {
+	if c.Queries == nil {
+		c.Queries = make(map[string]*QueryCheckpoint)
+	}
+	qc, ok := c.Queries[query]
+	if !ok {
+		qc = &QueryCheckpoint{}
+		c.Queries[query] = qc
+	}
+	return qc
+}
+
+func (c *Checkpoint) clone() Checkpoint {
+	out := Checkpoint{Queries: make(map[string]*QueryCheckpoint, len(c.Queries))}
+	for query, qc := range c.Queries {
+		copied := *qc
+		out.Queries[query] = &copied
+	}
+	return out
+}
@@ -11,7 +11,11 @@ import (
 	"strings"
 	"time"
 
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
 	"github.com/mkoziy/genome/exporter/internal/ratelimit"
+	"github.com/mkoziy/genome/exporter/internal/tracing"
 )
 
 const defaultBaseURL = "https://eutils.ncbi.nlm.nih.gov/entrez/eutils"
@@ -40,9 +44,25 @@ func NewClient(limiter ratelimit.Limiter, apiKey, email string) *Client {
 	}
 }
 
+// WithTransport makes requests through rt, e.g. an httpcache.Transport shared
+// with other sources. A nil rt uses http.DefaultTransport.
+func (c *Client) WithTransport(rt http.RoundTripper) *Client {
+	c.httpClient.Transport = rt
+	return c
+}
+
 // Search performs an ESearch query.
 func (c *Client) Search(ctx context.Context, query string, retStart, retMax int) (*SearchResponse, error) {
-	if err := c.limiter.Wait(ctx); err != nil {
+	return c.SearchModifiedSince(ctx, query, time.Time{}, retStart, retMax)
+}
+
+// SearchModifiedSince performs an ESearch query restricted to records modified
+// on or after since's date. A zero since searches all records.
+func (c *Client) SearchModifiedSince(ctx context.Context, query string, since time.Time, retStart, retMax int) (_ *SearchResponse, err error) {
+	ctx, span := tracing.Start(ctx, "clinvar.search",
+		attribute.String("query", query), attribute.Int("retstart", retStart), attribute.Int("retmax", retMax))
+	defer func() { tracing.End(span, err) }()
+	if err := c.wait(ctx, span); err != nil {
 		return nil, err
 	}
 
@@ -52,6 +72,11 @@ func (c *Client) Search(ctx context.Context, query string, retStart, retMax int)
 	params.Set("retstart", fmt.Sprintf("%d", retStart))
 	params.Set("retmax", fmt.Sprintf("%d", retMax))
 	params.Set("retmode", "json")
+	if !since.IsZero() {
+		params.Set("datetype", "mdat")
+		params.Set("mindate", since.UTC().Format("2006/01/02"))
+		params.Set("maxdate", "3000")
+	}
 	params.Set("tool", toolName)
 	if c.email != "" {
 		params.Set("email", c.email)
@@ -75,6 +100,7 @@ func (c *Client) Search(ctx context.Context, query string, retStart, retMax int)
 		_ = resp.Body.Close()
 	}()
 
+	span.SetAttributes(attribute.Int("http.status_code", resp.StatusCode))
 	if resp.StatusCode != http.StatusOK {
 		return nil, fmt.Errorf("unexpected status: %d", resp.StatusCode)
 	}
@@ -89,11 +115,13 @@ func (c *Client) Search(ctx context.Context, query string, retStart, retMax int)
 }
 
 // Fetch retrieves full variant details by IDs.
-func (c *Client) Fetch(ctx context.Context, ids []string) ([]ClinVarSet, error) {
+func (c *Client) Fetch(ctx context.Context, ids []string) (_ []ClinVarSet, err error) {
 	if len(ids) == 0 {
 		return nil, nil
 	}
-	if err := c.limiter.Wait(ctx); err != nil {
+	ctx, span := tracing.Start(ctx, "clinvar.fetch", attribute.Int("ids", len(ids)))
+	defer func() { tracing.End(span, err) }()
+	if err := c.wait(ctx, span); err != nil {
 		return nil, err
 	}
 
@@ -125,6 +153,7 @@ func (c *Client) Fetch(ctx context.Context, ids []string) ([]ClinVarSet, error)
 		_ = resp.Body.Close()
 	}()
 
+	span.SetAttributes(attribute.Int("http.status_code", resp.StatusCode))
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
 		return nil, fmt.Errorf("unexpected status %d: %s", resp.StatusCode, string(body))
@@ -140,6 +169,15 @@ func (c *Client) Fetch(ctx context.Context, ids []string) ([]ClinVarSet, error)
 	return wrapper.Sets, nil
 }
 
+// wait blocks until the rate limiter allows a request, recording on span how
+// long that took.
+func (c *Client) wait(ctx context.Context, span trace.Span) error {
+	start := time.Now()
+	err := c.limiter.Wait(ctx)
+	span.SetAttributes(attribute.Int64("ratelimit.wait_ms", time.Since(start).Milliseconds()))
+	return err
+}
+
 func joinIDs(ids []string) string {
 	return strings.Join(ids, ",")
 }
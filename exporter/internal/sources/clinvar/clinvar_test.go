@@ -3,12 +3,24 @@ package clinvar
 import (
 	"context"
 	"encoding/xml"
+	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/mkoziy/genome/exporter/internal/cassette"
 	"github.com/mkoziy/genome/exporter/internal/models"
+	"github.com/mkoziy/genome/exporter/internal/progress"
 )
 
 // mockLimiter is a no-op limiter for tests.
@@ -20,6 +32,16 @@ func (mockLimiter) Reserve() time.Duration       { return 0 }
 func (mockLimiter) RetryAfter(int) time.Duration { return 0 }
 func (mockLimiter) Reset()                       {}
 
+// fetchByQuery collects the SNPs streamQuery emits for query.
+func (f *Fetcher) fetchByQuery(ctx context.Context, query string, seen map[string]bool) ([]SNPData, error) {
+	var result []SNPData
+	err := f.streamQuery(ctx, query, seen, func(data SNPData) error {
+		result = append(result, data)
+		return nil
+	}, progress.NewTracker(f.reporter, sourceName))
+	return result, err
+}
+
 func TestJoinIDs(t *testing.T) {
 	ids := []string{"1", "2", "3"}
 	expected := "1,2,3"
@@ -79,6 +101,9 @@ func TestMapToSNPAndClinical(t *testing.T) {
 	        <XRef Type="MedGen" ID="C0002395" DB="MedGen" />
 	      </Trait>
 	    </TraitSet>
+	    <AttributeSet>
+	      <Attribute Type="ModeOfInheritance">Autosomal dominant inheritance</Attribute>
+	    </AttributeSet>
 	    <ObservedIn>
 	      <Sample>
 	        <Origin>germline</Origin>
@@ -129,6 +154,19 @@ func TestMapToSNPAndClinical(t *testing.T) {
 	if clin[0].ConditionID == nil || *clin[0].ConditionID != "C0002395" {
 		t.Fatalf("unexpected condition id: %v", clin[0].ConditionID)
 	}
+	if clin[0].InheritancePattern == nil || *clin[0].InheritancePattern != "Autosomal dominant inheritance" {
+		t.Fatalf("unexpected inheritance: %v", clin[0].InheritancePattern)
+	}
+
+	risks := MapToRiskAlleles(snp, clin)
+	if len(risks) != 1 || risks[0].Allele != "T" || risks[0].Effect != "pathogenic" || risks[0].IsRecessive() ||
+		risks[0].Direction != models.EffectIncreased {
+		t.Fatalf("expected T as a dominant pathogenic risk allele, got %+v", risks)
+	}
+	clin[0].ClinicalSignificance = models.ClinicalBenign
+	if risks := MapToRiskAlleles(snp, clin); len(risks) != 0 {
+		t.Fatalf("expected no risk allele from a benign assertion, got %+v", risks)
+	}
 
 	refs := MapToReferences(cvSet, 1)
 	if len(refs) != 2 {
@@ -136,6 +174,186 @@ func TestMapToSNPAndClinical(t *testing.T) {
 	}
 }
 
+func TestMapToSNPHGVS(t *testing.T) {
+	xmlData := `
+	<ClinVarSet>
+	  <ReferenceClinVarAssertion>
+	    <MeasureSet Type="Variant">
+	      <Measure Type="single nucleotide variant">
+	        <Name>
+	          <ElementValue Type="Preferred">NM_000041.4(APOE):c.388T&gt;C (p.Cys130Arg)</ElementValue>
+	        </Name>
+	        <AttributeSet>
+	          <Attribute Type="HGVS, coding, RefSeq">NM_001302688.2:c.466T&gt;C</Attribute>
+	        </AttributeSet>
+	        <AttributeSet>
+	          <Attribute Type="HGVS, protein, RefSeq">NP_001289617.1:p.Cys156Arg</Attribute>
+	        </AttributeSet>
+	        <AttributeSet>
+	          <Attribute Type="HGVS, protein, RefSeq">NP_000032.1:p.Cys130Arg</Attribute>
+	        </AttributeSet>
+	        <SequenceLocation Assembly="GRCh38" Chr="19" start="44908684" stop="44908684" referenceAllele="T" alternateAllele="C" />
+	        <XRef Type="rs" DB="dbSNP" ID="rs429358" />
+	      </Measure>
+	    </MeasureSet>
+	  </ReferenceClinVarAssertion>
+	</ClinVarSet>`
+
+	var cvSet ClinVarSet
+	if err := xml.Unmarshal([]byte(xmlData), &cvSet); err != nil {
+		t.Fatalf("failed to unmarshal: %v", err)
+	}
+	snp, err := MapToSNP(cvSet)
+	if err != nil {
+		t.Fatalf("MapToSNP error: %v", err)
+	}
+	if snp.HGVSCoding == nil || *snp.HGVSCoding != "NM_000041.4:c.388T>C" {
+		t.Errorf("expected the preferred transcript's description, got %v", snp.HGVSCoding)
+	}
+	if snp.HGVSProtein == nil || *snp.HGVSProtein != "NP_000032.1:p.Cys130Arg" {
+		t.Errorf("expected the preferred transcript's protein change, got %v", snp.HGVSProtein)
+	}
+	if hgvs := snp.GenomicHGVS(); hgvs == nil || *hgvs != "NC_000019.10:g.44908684T>C" {
+		t.Errorf("unexpected genomic description %v", hgvs)
+	}
+
+	// Without protein attributes, the change is described on the gene.
+	cvSet.ReferenceClinVarAssertion.MeasureSet.Measure[0].AttributeSet = nil
+	snp, err = MapToSNP(cvSet)
+	if err != nil {
+		t.Fatalf("MapToSNP error: %v", err)
+	}
+	if snp.HGVSProtein == nil || *snp.HGVSProtein != "APOE:p.Cys130Arg" {
+		t.Errorf("expected the change on the gene, got %v", snp.HGVSProtein)
+	}
+}
+
+func TestMapToSNPStructuralVariants(t *testing.T) {
+	measure := func(typ, location string) ClinVarSet {
+		xmlData := `
+	<ClinVarSet>
+	  <ReferenceClinVarAssertion>
+	    <MeasureSet Type="Variant">
+	      <Measure Type="` + typ + `">
+	        ` + location + `
+	        <XRef Type="rs" DB="dbSNP" ID="rs1555461056" />
+	      </Measure>
+	    </MeasureSet>
+	  </ReferenceClinVarAssertion>
+	</ClinVarSet>`
+		var cvSet ClinVarSet
+		if err := xml.Unmarshal([]byte(xmlData), &cvSet); err != nil {
+			t.Fatalf("failed to unmarshal: %v", err)
+		}
+		return cvSet
+	}
+
+	snp, err := MapToSNP(measure("copy number gain", `<SequenceLocation Assembly="GRCh38" Chr="17" innerStart="43044295" innerStop="43125483" variantLength="81189" />`))
+	if err != nil {
+		t.Fatalf("MapToSNP error: %v", err)
+	}
+	if snp.VariantType != models.VariantCNV || snp.SVType == nil || *snp.SVType != models.SVDuplication {
+		t.Fatalf("expected a copy-number gain, got %s %v", snp.VariantType, snp.SVType)
+	}
+	if snp.Position != 43044295 || snp.EndPosition == nil || *snp.EndPosition != 43125483 || snp.SVLength == nil || *snp.SVLength != 81189 {
+		t.Fatalf("unexpected extent %d-%v, length %v", snp.Position, snp.EndPosition, snp.SVLength)
+	}
+	if snp.ReferenceAllele != "N" || snp.AlternateAlleles[0] != "<DUP>" {
+		t.Errorf("expected symbolic alleles, got %s>%v", snp.ReferenceAllele, snp.AlternateAlleles)
+	}
+	if err := snp.Validate(); err != nil {
+		t.Error(err)
+	}
+
+	// A small deletion is not structural, but keeps its end.
+	snp, err = MapToSNP(measure("Deletion", `<SequenceLocation Assembly="GRCh38" Chr="7" start="117559590" stop="117559592" referenceAllele="CTT" alternateAllele="-" />`))
+	if err != nil {
+		t.Fatalf("MapToSNP error: %v", err)
+	}
+	if snp.VariantType != models.VariantDeletion || snp.SVType != nil || snp.EndPosition == nil || *snp.EndPosition != 117559592 {
+		t.Errorf("expected a small deletion ending at 117559592, got %s %v %v", snp.VariantType, snp.SVType, snp.EndPosition)
+	}
+}
+
+func TestMapToAgreements(t *testing.T) {
+	xmlData := `
+	<ClinVarSet>
+	  <ReferenceClinVarAssertion>
+	    <ClinVarAccession Acc="RCV000019456" Version="3" Type="RCV" />
+	    <ClinicalSignificance>
+	      <ReviewStatus>criteria provided, conflicting classifications</ReviewStatus>
+	      <Description>Conflicting classifications of pathogenicity</Description>
+	    </ClinicalSignificance>
+	    <TraitSet Type="Disease">
+	      <Trait Type="Disease">
+	        <Name><ElementValue Type="Preferred">Hereditary breast ovarian cancer syndrome</ElementValue></Name>
+	        <XRef ID="C0677776" DB="MedGen" />
+	      </Trait>
+	    </TraitSet>
+	  </ReferenceClinVarAssertion>
+	  <ClinVarAssertion>
+	    <ClinVarSubmissionID submitter="Lab A" />
+	    <ClinVarAccession Acc="SCV000000001" Type="SCV" />
+	    <ClinicalSignificance><Description>Pathogenic</Description></ClinicalSignificance>
+	  </ClinVarAssertion>
+	  <ClinVarAssertion>
+	    <ClinVarSubmissionID submitter="Lab B" />
+	    <ClinVarAccession Acc="SCV000000002" Type="SCV" />
+	    <ClinicalSignificance><Description>Likely pathogenic</Description></ClinicalSignificance>
+	  </ClinVarAssertion>
+	  <ClinVarAssertion>
+	    <ClinVarSubmissionID submitter="Lab C" />
+	    <ClinVarAccession Acc="SCV000000003" Type="SCV" />
+	    <ClinicalSignificance><Description>Uncertain significance</Description></ClinicalSignificance>
+	  </ClinVarAssertion>
+	</ClinVarSet>`
+	var cvSet ClinVarSet
+	if err := xml.Unmarshal([]byte(xmlData), &cvSet); err != nil {
+		t.Fatalf("failed to unmarshal: %v", err)
+	}
+	if len(cvSet.ClinVarAssertion) != 3 || cvSet.ClinVarAssertion[0].ClinVarSubmissionID.Submitter != "Lab A" {
+		t.Fatalf("expected 3 submissions, got %+v", cvSet.ClinVarAssertion)
+	}
+
+	agreements := MapToAgreements(cvSet, MapToClinical(cvSet, 1))
+	if len(agreements) != 1 {
+		t.Fatalf("expected 1 agreement, got %d", len(agreements))
+	}
+	a := agreements[0]
+	if a.ConditionName != "Hereditary breast ovarian cancer syndrome" || a.ConditionID == nil || *a.ConditionID != "C0677776" {
+		t.Errorf("unexpected condition %s %v", a.ConditionName, a.ConditionID)
+	}
+	if a.PathogenicCount != 2 || a.UncertainCount != 1 || a.MajorityCall != models.ClinicalPathogenic || !a.Conflicting {
+		t.Errorf("expected 2 pathogenic against 1 uncertain, conflicting, got %+v", a)
+	}
+	if err := a.Validate(); err != nil {
+		t.Error(err)
+	}
+
+	cvSet.ClinVarAssertion = nil
+	if agreements := MapToAgreements(cvSet, MapToClinical(cvSet, 1)); len(agreements) != 0 {
+		t.Errorf("expected no agreements without submissions, got %+v", agreements)
+	}
+}
+
+func TestExtractRsIDNormalizes(t *testing.T) {
+	cases := []struct {
+		xrefs []XRef
+		want  string
+	}{
+		{[]XRef{{Type: "rs", DB: "dbSNP", ID: "rs429358"}}, "rs429358"},
+		{[]XRef{{Type: "rs", DB: "dbSNP", ID: "429358"}}, "rs429358"},
+		{[]XRef{{Type: "MIM", DB: "OMIM", ID: "107741"}, {Type: "rs", DB: "dbSNP", ID: "RS7412"}}, "rs7412"},
+		{[]XRef{{Type: "Allelic variant", DB: "OMIM", ID: "107741.0001"}}, ""},
+		{[]XRef{{Type: "rs", DB: "dbSNP", ID: "n/a"}}, ""},
+	}
+	for _, c := range cases {
+		if got := extractRsID(c.xrefs); got != c.want {
+			t.Errorf("extractRsID(%+v) = %q, want %q", c.xrefs, got, c.want)
+		}
+	}
+}
+
 func TestClientSearchAndFetch(t *testing.T) {
 	// simple mock server that responds to esearch and efetch
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -212,3 +430,504 @@ func TestFetcherDeduplicates(t *testing.T) {
 		t.Fatalf("expected esearch called twice, got %d", calls)
 	}
 }
+
+func TestFetcherRecordsSpans(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/esearch.fcgi":
+			_, _ = w.Write([]byte(`{"esearchresult":{"count":"1","retmax":"1","retstart":"0","idlist":["1"]}}`))
+		case "/efetch.fcgi":
+			_, _ = w.Write([]byte(`<ClinVarResult-Set><ClinVarSet><ReferenceClinVarAssertion><ClinVarAccession Acc="VCV000000001" Version="1" Type="Variation" /><MeasureSet Type="Variant"><Measure Type="SNV"><SequenceLocation Assembly="GRCh38" Chr="19" start="44908684" stop="44908685" referenceAllele="C" alternateAllele="T" /><XRef Type="rs" DB="dbSNP" ID="rs429358" /></Measure></MeasureSet></ReferenceClinVarAssertion></ClinVarSet></ClinVarResult-Set>`))
+		}
+	}))
+	defer ts.Close()
+	origBase := baseURL
+	baseURL = ts.URL
+	t.Cleanup(func() { baseURL = origBase })
+
+	recorder := tracetest.NewSpanRecorder()
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(prev) })
+
+	client := &Client{httpClient: ts.Client(), limiter: mockLimiter{}}
+	if _, err := NewFetcher(client).fetchByQuery(context.Background(), "test", make(map[string]bool)); err != nil {
+		t.Fatalf("fetch: %v", err)
+	}
+
+	// Each span's name, prefixed by its parent's.
+	byID := make(map[string]string)
+	for _, span := range recorder.Ended() {
+		byID[span.SpanContext().SpanID().String()] = span.Name()
+	}
+	var got []string
+	for _, span := range recorder.Ended() {
+		name := span.Name()
+		if parent, ok := byID[span.Parent().SpanID().String()]; ok {
+			name = parent + ">" + name
+		}
+		got = append(got, name)
+	}
+	sort.Strings(got)
+	want := "[clinvar.batch>clinvar.fetch clinvar.batch>clinvar.search clinvar.query clinvar.query>clinvar.batch clinvar.query>clinvar.map clinvar.query>clinvar.search]"
+	if fmt.Sprint(got) != want {
+		t.Fatalf("unexpected spans:\n got %v\nwant %s", got, want)
+	}
+}
+
+func TestFetcherConcurrentBatches(t *testing.T) {
+	const workers = 3
+	var (
+		mu       sync.Mutex
+		inFlight int
+		peak     int
+	)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/esearch.fcgi":
+			start, _ := strconv.Atoi(r.URL.Query().Get("retstart"))
+			id := strconv.Itoa(start/batchSize + 1)
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = fmt.Fprintf(w, `{"esearchresult":{"count":"%d","retmax":"1","retstart":"%d","idlist":["%s"],"webenv":"","querykey":""}}`, workers*batchSize, start, id)
+		case "/efetch.fcgi":
+			// Hold each fetch until all workers are fetching, proving they overlap.
+			mu.Lock()
+			inFlight++
+			if inFlight > peak {
+				peak = inFlight
+			}
+			mu.Unlock()
+			deadline := time.Now().Add(2 * time.Second)
+			for time.Now().Before(deadline) {
+				mu.Lock()
+				all := peak == workers
+				mu.Unlock()
+				if all {
+					break
+				}
+				time.Sleep(5 * time.Millisecond)
+			}
+			mu.Lock()
+			inFlight--
+			mu.Unlock()
+
+			w.Header().Set("Content-Type", "application/xml")
+			_, _ = fmt.Fprintf(w, `<ClinVarResult-Set><ClinVarSet><ReferenceClinVarAssertion><ClinVarAccession Acc="VCV00000000%[1]s" Version="1" Type="Variation" /><ClinicalSignificance><ReviewStatus>reviewed by expert panel</ReviewStatus><Description>Pathogenic</Description></ClinicalSignificance><MeasureSet Type="Variant"><Measure Type="SNV"><SequenceLocation Assembly="GRCh38" Chr="1" start="%[1]s00" stop="%[1]s00" referenceAllele="C" alternateAllele="T" /><XRef Type="rs" DB="dbSNP" ID="rs%[1]s" /></Measure></MeasureSet></ReferenceClinVarAssertion></ClinVarSet></ClinVarResult-Set>`, r.URL.Query().Get("id"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	origBase := baseURL
+	baseURL = ts.URL
+	t.Cleanup(func() { baseURL = origBase })
+	client := &Client{httpClient: ts.Client(), limiter: mockLimiter{}}
+	fetcher := NewFetcher(client).WithWorkers(workers)
+
+	data, err := fetcher.fetchByQuery(context.Background(), "test", make(map[string]bool))
+	if err != nil {
+		t.Fatalf("fetcher error: %v", err)
+	}
+	if len(data) != workers {
+		t.Fatalf("expected %d SNPs, one per batch, got %d", workers, len(data))
+	}
+	if peak != workers {
+		t.Fatalf("expected %d concurrent fetches, got %d", workers, peak)
+	}
+}
+
+func TestFetcherKeepsEarliestBatchOfDuplicate(t *testing.T) {
+	const workers = 3
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/esearch.fcgi":
+			start, _ := strconv.Atoi(r.URL.Query().Get("retstart"))
+			id := strconv.Itoa(start/batchSize + 1)
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = fmt.Fprintf(w, `{"esearchresult":{"count":"%d","retmax":"1","retstart":"%d","idlist":["%s"],"webenv":"","querykey":""}}`, workers*batchSize, start, id)
+		case "/efetch.fcgi":
+			// The first batch finishes last.
+			id := r.URL.Query().Get("id")
+			if id == "1" {
+				time.Sleep(100 * time.Millisecond)
+			}
+			w.Header().Set("Content-Type", "application/xml")
+			_, _ = fmt.Fprintf(w, `<ClinVarResult-Set><ClinVarSet><ReferenceClinVarAssertion><ClinVarAccession Acc="VCV00000000%[1]s" Version="1" Type="Variation" /><MeasureSet Type="Variant"><Measure Type="SNV"><SequenceLocation Assembly="GRCh38" Chr="1" start="%[1]s00" stop="%[1]s00" referenceAllele="C" alternateAllele="T" /><XRef Type="rs" DB="dbSNP" ID="rs1" /></Measure></MeasureSet></ReferenceClinVarAssertion></ClinVarSet></ClinVarResult-Set>`, id)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	origBase := baseURL
+	baseURL = ts.URL
+	t.Cleanup(func() { baseURL = origBase })
+	client := &Client{httpClient: ts.Client(), limiter: mockLimiter{}}
+	fetcher := NewFetcher(client).WithWorkers(workers)
+
+	data, err := fetcher.fetchByQuery(context.Background(), "test", make(map[string]bool))
+	if err != nil {
+		t.Fatalf("fetcher error: %v", err)
+	}
+	if len(data) != 1 {
+		t.Fatalf("expected 1 unique SNP, got %d", len(data))
+	}
+	if data[0].SNP.Position != 100 {
+		t.Fatalf("expected the first batch's record, got position %d", data[0].SNP.Position)
+	}
+}
+
+func TestFetcherStreamStopsOnCancel(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/esearch.fcgi":
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"esearchresult":{"count":"1","retmax":"1","retstart":"0","idlist":["1"],"webenv":"","querykey":""}}`))
+		case "/efetch.fcgi":
+			w.Header().Set("Content-Type", "application/xml")
+			_, _ = w.Write([]byte(`<ClinVarResult-Set><ClinVarSet><ReferenceClinVarAssertion><ClinVarAccession Acc="VCV000000001" Version="1" Type="Variation" /><ClinicalSignificance><ReviewStatus>reviewed by expert panel</ReviewStatus><Description>Pathogenic</Description></ClinicalSignificance><MeasureSet Type="Variant"><Measure Type="SNV"><SequenceLocation Assembly="GRCh38" Chr="19" start="44908684" stop="44908685" referenceAllele="C" alternateAllele="T" /><XRef Type="rs" DB="dbSNP" ID="rs429358" /></Measure></MeasureSet></ReferenceClinVarAssertion></ClinVarSet></ClinVarResult-Set>`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	origBase := baseURL
+	baseURL = ts.URL
+	t.Cleanup(func() { baseURL = origBase })
+	client := &Client{httpClient: ts.Client(), limiter: mockLimiter{}}
+
+	// Nobody reads out, so the stream must give up when ctx is cancelled.
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	err := NewFetcher(client).StreamSignificantSNPs(ctx, make(chan SNPData))
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+}
+
+func TestFetcherStreamAppliesBackpressure(t *testing.T) {
+	const batches = 50
+	var fetches atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/esearch.fcgi":
+			start, _ := strconv.Atoi(r.URL.Query().Get("retstart"))
+			_, _ = fmt.Fprintf(w, `{"esearchresult":{"count":"%d","retstart":"%d","idlist":["%d"]}}`, batches*batchSize, start, start/batchSize+1)
+		case "/efetch.fcgi":
+			fetches.Add(1)
+			_, _ = fmt.Fprintf(w, `<ClinVarResult-Set><ClinVarSet><ReferenceClinVarAssertion><ClinVarAccession Acc="VCV%[1]s" Version="1" Type="Variation" /><MeasureSet Type="Variant"><Measure Type="SNV"><SequenceLocation Assembly="GRCh38" Chr="1" start="%[1]s00" stop="%[1]s00" referenceAllele="C" alternateAllele="T" /><XRef Type="rs" DB="dbSNP" ID="rs%[1]s" /></Measure></MeasureSet></ReferenceClinVarAssertion></ClinVarSet></ClinVarResult-Set>`, r.URL.Query().Get("id"))
+		}
+	}))
+	defer ts.Close()
+	origBase := baseURL
+	baseURL = ts.URL
+	t.Cleanup(func() { baseURL = origBase })
+	client := &Client{httpClient: ts.Client(), limiter: mockLimiter{}}
+
+	// The consumer takes one SNP and then stalls. Downloads must stop once
+	// the channels between the stages are full: one batch waiting to be sent
+	// on out, and for each stage one in its channel and one held by its worker.
+	ctx, cancel := context.WithCancel(context.Background())
+	out := make(chan SNPData)
+	done := make(chan error, 1)
+	go func() { done <- NewFetcher(client).WithWorkers(1).StreamSignificantSNPs(ctx, out) }()
+	<-out
+	for last := int32(-1); last != fetches.Load(); {
+		last = fetches.Load()
+		time.Sleep(50 * time.Millisecond)
+	}
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if n := fetches.Load(); n > 6 {
+		t.Fatalf("expected downloads to stall behind the consumer, got %d of %d batches fetched", n, batches)
+	}
+}
+
+// recordingFailures records reports and returns abort once a failure is seen.
+type recordingFailures struct {
+	mu        sync.Mutex
+	succeeded int
+	failures  []Failure
+	abort     error
+}
+
+func (h *recordingFailures) Succeeded(records int) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.succeeded += records
+	return nil
+}
+
+func (h *recordingFailures) Failed(f Failure) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.failures = append(h.failures, f)
+	return h.abort
+}
+
+func TestFetcherReportsFailedBatches(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/esearch.fcgi":
+			start, _ := strconv.Atoi(r.URL.Query().Get("retstart"))
+			id := strconv.Itoa(start/batchSize + 1)
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = fmt.Fprintf(w, `{"esearchresult":{"count":"%d","retmax":"1","retstart":"%d","idlist":["%s"],"webenv":"","querykey":""}}`, 3*batchSize, start, id)
+		case "/efetch.fcgi":
+			id := r.URL.Query().Get("id")
+			if id == "2" {
+				http.Error(w, "upstream unavailable", http.StatusBadGateway)
+				return
+			}
+			w.Header().Set("Content-Type", "application/xml")
+			_, _ = fmt.Fprintf(w, `<ClinVarResult-Set><ClinVarSet><ReferenceClinVarAssertion><ClinVarAccession Acc="VCV00000000%[1]s" Version="1" Type="Variation" /><ClinicalSignificance><ReviewStatus>reviewed by expert panel</ReviewStatus><Description>Pathogenic</Description></ClinicalSignificance><MeasureSet Type="Variant"><Measure Type="SNV"><SequenceLocation Assembly="GRCh38" Chr="1" start="%[1]s00" stop="%[1]s00" referenceAllele="C" alternateAllele="T" /><XRef Type="rs" DB="dbSNP" ID="rs%[1]s" /></Measure></MeasureSet></ReferenceClinVarAssertion></ClinVarSet></ClinVarResult-Set>`, id)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	origBase := baseURL
+	baseURL = ts.URL
+	t.Cleanup(func() { baseURL = origBase })
+	client := &Client{httpClient: ts.Client(), limiter: mockLimiter{}}
+
+	t.Run("tolerated", func(t *testing.T) {
+		h := &recordingFailures{}
+		data, err := NewFetcher(client).WithWorkers(1).WithFailureHandler(h).fetchByQuery(context.Background(), "test", make(map[string]bool))
+		if err != nil {
+			t.Fatalf("fetcher error: %v", err)
+		}
+		if len(data) != 2 || h.succeeded != 2 {
+			t.Fatalf("expected the 2 good batches, got %d SNPs and %d reported", len(data), h.succeeded)
+		}
+		if len(h.failures) != 1 {
+			t.Fatalf("expected 1 failure, got %+v", h.failures)
+		}
+		f := h.failures[0]
+		if !f.Request || f.Start != batchSize || f.Records != 1 || len(f.IDs) != 1 || f.IDs[0] != "2" || f.Err == nil {
+			t.Fatalf("unexpected failure %+v", f)
+		}
+	})
+
+	t.Run("aborted", func(t *testing.T) {
+		abort := errors.New("too many errors")
+		h := &recordingFailures{abort: abort}
+		_, err := NewFetcher(client).WithWorkers(1).WithFailureHandler(h).fetchByQuery(context.Background(), "test", make(map[string]bool))
+		if !errors.Is(err, abort) {
+			t.Fatalf("expected the handler's error, got %v", err)
+		}
+	})
+}
+
+func TestFetcherResumesFromCheckpoint(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		fetched []string
+	)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/esearch.fcgi":
+			start, _ := strconv.Atoi(r.URL.Query().Get("retstart"))
+			id := strconv.Itoa(start/batchSize + 1)
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = fmt.Fprintf(w, `{"esearchresult":{"count":"%d","retmax":"1","retstart":"%d","idlist":["%s"],"webenv":"","querykey":""}}`, 3*batchSize, start, id)
+		case "/efetch.fcgi":
+			id := r.URL.Query().Get("id")
+			mu.Lock()
+			fetched = append(fetched, id)
+			mu.Unlock()
+			w.Header().Set("Content-Type", "application/xml")
+			_, _ = fmt.Fprintf(w, `<ClinVarResult-Set><ClinVarSet><ReferenceClinVarAssertion><ClinVarAccession Acc="VCV00000000%[1]s" Version="1" Type="Variation" /><ClinicalSignificance><ReviewStatus>reviewed by expert panel</ReviewStatus><Description>Pathogenic</Description></ClinicalSignificance><MeasureSet Type="Variant"><Measure Type="SNV"><SequenceLocation Assembly="GRCh38" Chr="1" start="%[1]s00" stop="%[1]s00" referenceAllele="C" alternateAllele="T" /><XRef Type="rs" DB="dbSNP" ID="rs%[1]s" /></Measure></MeasureSet></ReferenceClinVarAssertion></ClinVarSet></ClinVarResult-Set>`, id)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	origBase := baseURL
+	baseURL = ts.URL
+	t.Cleanup(func() { baseURL = origBase })
+	client := &Client{httpClient: ts.Client(), limiter: mockLimiter{}}
+
+	cp := &Checkpoint{Queries: map[string]*QueryCheckpoint{
+		"test": {Count: 3 * batchSize, RetStart: batchSize, MaxID: 1},
+	}}
+	var last Checkpoint
+	var lastEmitted int
+	fetcher := NewFetcher(client).WithCheckpoint(cp, func(c Checkpoint, emitted int) {
+		last, lastEmitted = c, emitted
+	})
+
+	data, err := fetcher.fetchByQuery(context.Background(), "test", make(map[string]bool))
+	if err != nil {
+		t.Fatalf("fetcher error: %v", err)
+	}
+	if len(data) != 2 || len(fetched) != 2 {
+		t.Fatalf("expected only the 2 remaining batches, got %d SNPs from %v", len(data), fetched)
+	}
+	qc := last.Queries["test"]
+	if qc == nil || !qc.Done || qc.RetStart != 3*batchSize || qc.MaxID != 3 || lastEmitted != 2 {
+		t.Fatalf("unexpected final checkpoint %+v (emitted %d)", qc, lastEmitted)
+	}
+
+	// A finished query is skipped entirely.
+	fetched = nil
+	if _, err := fetcher.fetchByQuery(context.Background(), "test", make(map[string]bool)); err != nil {
+		t.Fatalf("fetcher error: %v", err)
+	}
+	if len(fetched) != 0 {
+		t.Fatalf("expected completed query to be skipped, fetched %v", fetched)
+	}
+
+	// A changed result count invalidates the offsets.
+	cp.Queries["test"] = &QueryCheckpoint{Count: 2 * batchSize, RetStart: batchSize}
+	data, err = fetcher.fetchByQuery(context.Background(), "test", make(map[string]bool))
+	if err != nil {
+		t.Fatalf("fetcher error: %v", err)
+	}
+	if len(data) != 3 {
+		t.Fatalf("expected restart from 0 to fetch all 3 batches, got %d", len(data))
+	}
+}
+
+func TestFetcherPlanOnlySearches(t *testing.T) {
+	var fetches int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/esearch.fcgi":
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"esearchresult":{"count":"1200","retmax":"1","retstart":"0","idlist":["1"],"webenv":"","querykey":""}}`))
+		default:
+			fetches++
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	origBase := baseURL
+	baseURL = ts.URL
+	t.Cleanup(func() { baseURL = origBase })
+	client := &Client{httpClient: ts.Client(), limiter: mockLimiter{}}
+
+	plans, err := NewFetcher(client).Plan(context.Background())
+	if err != nil {
+		t.Fatalf("plan error: %v", err)
+	}
+	if len(plans) != len(DefaultQueries()) {
+		t.Fatalf("expected a plan per query, got %d", len(plans))
+	}
+	// 1200 variants are 3 batches: the count search plus a search and fetch each.
+	if plans[0].Count != 1200 || plans[0].Requests != 7 {
+		t.Fatalf("unexpected plan: %+v", plans[0])
+	}
+	if fetches != 0 {
+		t.Fatalf("expected no efetch calls, got %d", fetches)
+	}
+
+	plans, err = NewFetcher(client).WithQueries([]string{"BRCA1[gene]"}).Plan(context.Background())
+	if err != nil || len(plans) != 1 || plans[0].Query != "BRCA1[gene]" {
+		t.Fatalf("expected only the configured query planned, got %+v (%v)", plans, err)
+	}
+}
+
+func TestFetcherModifiedSinceFiltersSearches(t *testing.T) {
+	var searches []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/esearch.fcgi":
+			q := r.URL.Query()
+			searches = append(searches, q.Get("datetype")+" "+q.Get("mindate"))
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"esearchresult":{"count":"0","retmax":"0","retstart":"0","idlist":[],"webenv":"","querykey":""}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	origBase := baseURL
+	baseURL = ts.URL
+	t.Cleanup(func() { baseURL = origBase })
+	client := &Client{httpClient: ts.Client(), limiter: mockLimiter{}}
+
+	cp := &Checkpoint{}
+	since := time.Date(2025, 3, 4, 23, 0, 0, 0, time.UTC)
+	fetcher := NewFetcher(client).WithModifiedSince(since).WithCheckpoint(cp, nil)
+	if _, err := fetcher.fetchByQuery(context.Background(), "test", make(map[string]bool)); err != nil {
+		t.Fatalf("fetcher error: %v", err)
+	}
+	if len(searches) != 1 || searches[0] != "mdat 2025/03/04" {
+		t.Fatalf("expected a modification-date filtered search, got %v", searches)
+	}
+	// A completed incremental query must not mark the full query as done.
+	if _, ok := cp.Queries["test"]; ok {
+		t.Fatalf("incremental progress stored under the full query key: %v", cp.Queries)
+	}
+}
+
+// TestFetcherReplaysRecordedSession replays a recorded E-utilities session;
+// set RECORD_CASSETTES=1 to re-record it against the live API.
+func TestFetcherReplaysRecordedSession(t *testing.T) {
+	rec := cassette.Open(t, "testdata/cassettes/apoe_risk_factors.yaml")
+	client := NewClient(mockLimiter{}, "", "").WithTransport(rec)
+
+	query := `APOE[gene] AND ("risk factor"[CLNSIG] OR "protective"[CLNSIG])`
+	data, err := NewFetcher(client).fetchByQuery(context.Background(), query, make(map[string]bool))
+	if err != nil {
+		t.Fatalf("fetcher error: %v", err)
+	}
+
+	bySNP := make(map[string]SNPData, len(data))
+	for _, d := range data {
+		bySNP[d.SNP.RsID] = d
+	}
+	e4, ok := bySNP["rs429358"]
+	if len(data) != 2 || !ok || bySNP["rs7412"].SNP == nil {
+		t.Fatalf("expected rs429358 and rs7412, got %d SNPs", len(data))
+	}
+	if e4.SNP.Position != 44908684 || len(e4.Clinical) != 1 || e4.Clinical[0].ClinicalSignificance != models.ClinicalRiskFactor {
+		t.Fatalf("unexpected rs429358 mapping: %+v %+v", e4.SNP, e4.Clinical)
+	}
+	references := len(e4.References) + len(bySNP["rs7412"].References)
+	if references == 0 {
+		t.Fatalf("expected the cited references mapped")
+	}
+
+	// Profiles without references drop them.
+	rec = cassette.Open(t, "testdata/cassettes/apoe_risk_factors.yaml")
+	client = NewClient(mockLimiter{}, "", "").WithTransport(rec)
+	data, err = NewFetcher(client).WithReferences(false).fetchByQuery(context.Background(), query, make(map[string]bool))
+	if err != nil {
+		t.Fatalf("fetcher error: %v", err)
+	}
+	for _, d := range data {
+		if len(d.References) != 0 {
+			t.Fatalf("expected no references, got %+v", d.References)
+		}
+	}
+}
+
+func TestFetchRsIDKeepsOnlyTheVariant(t *testing.T) {
+	rec := cassette.Open(t, "testdata/cassettes/rs429358.yaml")
+	client := NewClient(mockLimiter{}, "", "").WithTransport(rec)
+
+	// The search also matches rs7412's record, which is dropped.
+	data, failures, err := NewFetcher(client).FetchRsID(context.Background(), "rs429358")
+	if err != nil {
+		t.Fatalf("fetch: %v", err)
+	}
+	if len(failures) != 0 || len(data) != 1 || data[0].SNP.RsID != "rs429358" {
+		t.Fatalf("expected only rs429358, got %d records and failures %+v", len(data), failures)
+	}
+
+	for _, bad := range []string{"429358", "rs", "rs42x", "APOE[gene]"} {
+		if _, _, err := NewFetcher(client).FetchRsID(context.Background(), bad); err == nil {
+			t.Fatalf("expected %q rejected", bad)
+		}
+	}
+}
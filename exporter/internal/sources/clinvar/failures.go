@@ -0,0 +1,30 @@
+package clinvar
+
+// Failure describes records a fetch lost, either because a search or fetch
+// request failed or because a fetched record could not be mapped.
+type Failure struct {
+	Query string `json:"query"`
+	// Start is the offset of the batch within the query's results.
+	Start int `json:"start"`
+	// IDs are the ClinVar UIDs of the lost records, when the batch's search
+	// succeeded.
+	IDs []string `json:"ids,omitempty"`
+	// Accession identifies the record that could not be mapped.
+	Accession string `json:"accession,omitempty"`
+	// Records is how many records were lost.
+	Records int `json:"records"`
+	// Request is set when an API request failed, as opposed to a mapping error.
+	Request bool  `json:"request"`
+	Err     error `json:"-"`
+}
+
+// FailureHandler is told how each batch went. A non-nil error from either
+// method aborts the fetch with that error; without a handler failed batches
+// and records are logged and skipped. Batches are fetched concurrently, so
+// implementations must be safe for concurrent use.
+type FailureHandler interface {
+	// Succeeded reports a batch whose requests succeeded and whose records
+	// mapped, apart from any reported separately as failures.
+	Succeeded(records int) error
+	Failed(f Failure) error
+}
@@ -3,105 +3,532 @@ package clinvar
 import (
 	"context"
 	"fmt"
-	"log"
+	"log/slog"
 	"strconv"
+	"sync"
+	"time"
 
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/mkoziy/genome/exporter/internal/logging"
 	"github.com/mkoziy/genome/exporter/internal/models"
+	"github.com/mkoziy/genome/exporter/internal/progress"
+	"github.com/mkoziy/genome/exporter/internal/tracing"
 )
 
+// DefaultWorkers is the number of batches fetched concurrently per query. The
+// client's limiter still caps the overall request rate; workers only overlap
+// request latency and XML decoding.
+const DefaultWorkers = 4
+
+// batchSize is the number of variants requested per search and fetch call.
+const batchSize = 500
+
+// sourceName identifies ClinVar in progress reports and logs.
+const sourceName = "clinvar"
+
+func batchCount(variants int) int {
+	return (variants + batchSize - 1) / batchSize
+}
+
 // Fetcher orchestrates ClinVar data fetching.
 type Fetcher struct {
-	client *Client
+	client       *Client
+	workers      int
+	checkpoint   *Checkpoint
+	onCheckpoint CheckpointFunc
+	emitted      int
+	reporter     progress.Reporter
+	since        time.Time
+	queries      []string
+	failures     FailureHandler
+	noReferences bool
 }
 
 // NewFetcher creates a new ClinVar fetcher.
 func NewFetcher(client *Client) *Fetcher {
-	return &Fetcher{client: client}
+	return &Fetcher{client: client, workers: DefaultWorkers, reporter: progress.Discard}
 }
 
-// FetchSignificantSNPs fetches all significant SNPs from ClinVar.
-func (f *Fetcher) FetchSignificantSNPs(ctx context.Context) ([]SNPData, error) {
-	queries := []string{QueryPathogenicVariants(), QueryRiskFactorVariants(), QueryDrugResponseVariants()}
+// WithWorkers sets how many batches are fetched concurrently; n < 1 is ignored.
+func (f *Fetcher) WithWorkers(n int) *Fetcher {
+	if n > 0 {
+		f.workers = n
+	}
+	return f
+}
 
-	allData := make([]SNPData, 0)
+// WithQueries replaces the built-in significant-variant queries with queries.
+// An empty list keeps the defaults.
+func (f *Fetcher) WithQueries(queries []string) *Fetcher {
+	f.queries = queries
+	return f
+}
+
+// WithReferences sets whether the PubMed references ClinVar cites are kept,
+// which they are by default.
+func (f *Fetcher) WithReferences(keep bool) *Fetcher {
+	f.noReferences = !keep
+	return f
+}
+
+// WithCheckpoint resumes from cp, which is updated in place as batches
+// complete, and reports progress to fn after each batch. fn may be nil.
+func (f *Fetcher) WithCheckpoint(cp *Checkpoint, fn CheckpointFunc) *Fetcher {
+	f.checkpoint = cp
+	f.onCheckpoint = fn
+	return f
+}
+
+// WithModifiedSince restricts every query to variants modified on or after
+// since, for incremental syncs.
+func (f *Fetcher) WithModifiedSince(since time.Time) *Fetcher {
+	f.since = since
+	return f
+}
+
+// WithFailureHandler reports failed requests and records to h, which decides
+// whether the fetch carries on.
+func (f *Fetcher) WithFailureHandler(h FailureHandler) *Fetcher {
+	f.failures = h
+	return f
+}
+
+// WithProgress reports download progress to r.
+func (f *Fetcher) WithProgress(r progress.Reporter) *Fetcher {
+	if r != nil {
+		f.reporter = r
+	}
+	return f
+}
+
+// StreamSignificantSNPs sends significant SNPs to out as their batches arrive,
+// so a consumer such as repositories.BatchWriter can write while later batches
+// are still downloading. out is not closed. Nothing is accumulated: at most a
+// few batches per worker are held in memory, and a consumer that falls behind
+// holds up the downloads. Only the rsIDs already sent are remembered, to skip
+// records that several queries match.
+func (f *Fetcher) StreamSignificantSNPs(ctx context.Context, out chan<- SNPData) error {
+	return f.streamSignificantSNPs(ctx, func(data SNPData) error {
+		select {
+		case out <- data:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+}
+
+func (f *Fetcher) streamSignificantSNPs(ctx context.Context, emit func(SNPData) error) error {
 	seen := make(map[string]bool)
+	if f.checkpoint == nil {
+		f.checkpoint = &Checkpoint{}
+	}
 
-	for _, query := range queries {
-		log.Printf("Fetching ClinVar variants for query: %s", query)
+	tracker := progress.NewTracker(f.reporter, sourceName)
+	for _, query := range f.significantQueries() {
+		if err := f.streamQuery(ctx, query, seen, emit, tracker); err != nil {
+			return fmt.Errorf("fetch query: %w", err)
+		}
+	}
+
+	return nil
+}
 
-		data, err := f.fetchByQuery(ctx, query, seen)
+// QueryPlan describes what fetching one query would involve.
+type QueryPlan struct {
+	Query    string `json:"query"`
+	Count    int    `json:"count"`
+	Requests int    `json:"requests"`
+}
+
+// Plan runs only the initial search of each query StreamSignificantSNPs would
+// run, reporting how many variants it matches and how many API requests
+// fetching them would take. Nothing is fetched.
+func (f *Fetcher) Plan(ctx context.Context) ([]QueryPlan, error) {
+	queries := f.significantQueries()
+	plans := make([]QueryPlan, 0, len(queries))
+	for _, query := range queries {
+		searchResp, err := f.client.SearchModifiedSince(ctx, query, f.since, 0, 1)
 		if err != nil {
-			return nil, fmt.Errorf("fetch query: %w", err)
+			return nil, fmt.Errorf("search %q: %w", query, err)
 		}
+		count, _ := strconv.Atoi(searchResp.Count)
+		// The initial count search, then a search and a fetch per batch.
+		plans = append(plans, QueryPlan{Query: query, Count: count, Requests: 1 + 2*batchCount(count)})
+	}
+	return plans, nil
+}
 
-		allData = append(allData, data...)
-		log.Printf("Fetched %d unique variants so far", len(allData))
+// significantQueries are the queries StreamSignificantSNPs runs, in order.
+func (f *Fetcher) significantQueries() []string {
+	if len(f.queries) > 0 {
+		return f.queries
 	}
+	return DefaultQueries()
+}
 
-	return allData, nil
+// DefaultQueries are the queries run when none are configured: pathogenic,
+// risk factor and drug response variants.
+func DefaultQueries() []string {
+	return []string{QueryPathogenicVariants(), QueryRiskFactorVariants(), QueryDrugResponseVariants()}
 }
 
-func (f *Fetcher) fetchByQuery(ctx context.Context, query string, seen map[string]bool) ([]SNPData, error) {
-	const batchSize = 500
+// streamQuery fetches every batch of query with f.workers workers and passes
+// SNPs not yet in seen to emit. Batches may complete out of order but their
+// records are emitted in batch order, so when two ClinVar records share an
+// rsID the one of the earliest batch is kept. The query's checkpoint advances
+// past each batch as it is emitted.
+func (f *Fetcher) streamQuery(ctx context.Context, query string, seen map[string]bool, emit func(SNPData) error, tracker *progress.Tracker) (err error) {
+	if f.checkpoint == nil {
+		f.checkpoint = &Checkpoint{}
+	}
+	qc := f.checkpoint.query(f.checkpointKey(query))
+	if qc.Done {
+		return nil
+	}
+	ctx = logging.With(ctx, logging.KeySource, sourceName, logging.KeyQuery, query)
+	ctx, span := tracing.Start(ctx, "clinvar.query", attribute.String("query", query), attribute.Int("retstart", qc.RetStart))
+	defer func() { tracing.End(span, err) }()
 
-	searchResp, err := f.client.Search(ctx, query, 0, 1)
+	searchResp, err := f.client.SearchModifiedSince(ctx, query, f.since, 0, 1)
 	if err != nil {
-		return nil, fmt.Errorf("initial search: %w", err)
+		return fmt.Errorf("initial search: %w", err)
 	}
 
 	totalCount, _ := strconv.Atoi(searchResp.Count)
-	log.Printf("Found %d variants", totalCount)
+	if qc.Count != totalCount {
+		if qc.RetStart > 0 {
+			slog.WarnContext(ctx, "ClinVar result count changed, restarting query", "previous", qc.Count, "count", totalCount)
+		}
+		*qc = QueryCheckpoint{Count: totalCount}
+	}
+	span.SetAttributes(attribute.Int("count", totalCount))
+	tracker.Start(query, totalCount, batchCount(totalCount), min(qc.RetStart, totalCount), qc.RetStart/batchSize)
 
-	result := make([]SNPData, 0)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
 
-	for start := 0; start < totalCount; start += batchSize {
-		select {
-		case <-ctx.Done():
-			return result, ctx.Err()
-		default:
+	// Batches flow through three stages joined by channels holding at most
+	// f.workers batches each: fetch workers download raw records, map workers
+	// turn them into SNPData and the loop below emits them. A slow consumer of
+	// emit therefore stalls the downloads instead of letting batches pile up.
+	// The loop emits batches in order, so window bounds the batches started
+	// but not yet emitted, those waiting on an earlier one among them.
+	window := make(chan struct{}, 2*f.workers)
+	starts := make(chan int)
+	go func() {
+		defer close(starts)
+		for start := qc.RetStart; start < totalCount; start += batchSize {
+			select {
+			case window <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			select {
+			case starts <- start:
+			case <-ctx.Done():
+				return
+			}
 		}
+	}()
 
-		searchResp, err := f.client.Search(ctx, query, start, batchSize)
-		if err != nil {
-			log.Printf("Error searching batch at %d: %v", start, err)
-			continue
+	raws := make(chan rawBatch, f.workers)
+	var fetchers sync.WaitGroup
+	for i := 0; i < f.workers; i++ {
+		fetchers.Add(1)
+		go func() {
+			defer fetchers.Done()
+			for start := range starts {
+				raw := f.fetchBatch(ctx, query, start, totalCount)
+				select {
+				case raws <- raw:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		fetchers.Wait()
+		close(raws)
+	}()
+
+	batches := make(chan fetchedBatch, f.workers)
+	var mappers sync.WaitGroup
+	for i := 0; i < f.workers; i++ {
+		mappers.Add(1)
+		go func() {
+			defer mappers.Done()
+			for raw := range raws {
+				batch := f.mapBatch(ctx, query, raw)
+				select {
+				case batches <- batch:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		mappers.Wait()
+		close(batches)
+	}()
+
+	pending := make(map[int]fetchedBatch)
+	for batch := range batches {
+		// A batch that failed because ctx was cancelled must not advance the checkpoint.
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if batch.err != nil {
+			return batch.err
+		}
+
+		// Of records sharing an rsID the one of the earliest batch is kept,
+		// whichever worker finishes first, so a query yields the same data
+		// on every run.
+		pending[batch.start] = batch
+		for {
+			next, ok := pending[qc.RetStart]
+			if !ok {
+				break
+			}
+			delete(pending, qc.RetStart)
+			for _, data := range next.data {
+				if seen[data.SNP.RsID] {
+					continue
+				}
+				seen[data.SNP.RsID] = true
+				if err := emit(data); err != nil {
+					return err
+				}
+				f.emitted++
+			}
+
+			qc.RetStart += batchSize
+			if next.maxID > qc.MaxID {
+				qc.MaxID = next.maxID
+			}
+			qc.Done = qc.RetStart >= totalCount
+			f.saveCheckpoint()
+			tracker.Batch(min(batchSize, totalCount-next.start))
+			<-window
 		}
-		if len(searchResp.IdList) == 0 {
-			break
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	// A query with no results never produces a batch.
+	if !qc.Done {
+		qc.Done = true
+		f.saveCheckpoint()
+	}
+	tracker.Done()
+	return nil
+}
+
+// checkpointKey keeps the progress of incremental and full fetches of the same
+// query apart.
+func (f *Fetcher) checkpointKey(query string) string {
+	if f.since.IsZero() {
+		return query
+	}
+	return query + " modified since " + f.since.UTC().Format("2006-01-02")
+}
+
+func (f *Fetcher) saveCheckpoint() {
+	if f.onCheckpoint != nil {
+		f.onCheckpoint(f.checkpoint.clone(), f.emitted)
+	}
+}
+
+// rawBatch holds the records downloaded for the batch at offset start.
+// fetched is false when the batch matched nothing or its requests failed, and
+// err is set when the failure handler aborted the fetch.
+type rawBatch struct {
+	start   int
+	maxID   int64
+	sets    []ClinVarSet
+	fetched bool
+	err     error
+}
+
+// fetchedBatch is the mapped result of the batch at offset start. err is set
+// when the failure handler aborted the fetch.
+type fetchedBatch struct {
+	start int
+	maxID int64
+	data  []SNPData
+	err   error
+}
+
+// fetchBatch searches and fetches the batch at start. Failed requests are
+// logged and reported to the failure handler, which decides whether to carry
+// on; without one they are skipped so one bad response does not abort a full
+// download.
+func (f *Fetcher) fetchBatch(ctx context.Context, query string, start int, total int) (batch rawBatch) {
+	ctx, span := tracing.Start(ctx, "clinvar.batch", attribute.Int("start", start))
+	defer func() {
+		span.SetAttributes(attribute.Int("records", len(batch.sets)))
+		tracing.End(span, batch.err)
+	}()
+	batch.start = start
+	searchResp, err := f.client.SearchModifiedSince(ctx, query, f.since, start, batchSize)
+	if err != nil {
+		slog.ErrorContext(ctx, "Searching batch failed", "start", start, "error", err)
+		batch.err = f.failed(ctx, Failure{Query: query, Start: start, Records: min(batchSize, total-start), Request: true, Err: err})
+		return batch
+	}
+	if len(searchResp.IdList) == 0 {
+		return batch
+	}
+
+	for _, id := range searchResp.IdList {
+		if n, err := strconv.ParseInt(id, 10, 64); err == nil && n > batch.maxID {
+			batch.maxID = n
 		}
+	}
+
+	cvSets, err := f.client.Fetch(ctx, searchResp.IdList)
+	if err != nil {
+		slog.ErrorContext(ctx, "Fetching batch failed", "start", start, "ids", len(searchResp.IdList), "error", err)
+		batch.err = f.failed(ctx, Failure{Query: query, Start: start, IDs: searchResp.IdList, Records: len(searchResp.IdList), Request: true, Err: err})
+		return batch
+	}
+	batch.sets, batch.fetched = cvSets, true
+	return batch
+}
 
-		cvSets, err := f.client.Fetch(ctx, searchResp.IdList)
+// mapBatch maps the records of raw, reporting those that cannot be mapped
+// and then the batch's success to the failure handler.
+func (f *Fetcher) mapBatch(ctx context.Context, query string, raw rawBatch) fetchedBatch {
+	batch := fetchedBatch{start: raw.start, maxID: raw.maxID, err: raw.err}
+	if !raw.fetched {
+		return batch
+	}
+	var failures []Failure
+	batch.data, failures = f.mapSets(ctx, query, raw.start, raw.sets)
+	for _, fail := range failures {
+		if batch.err = f.failed(ctx, fail); batch.err != nil {
+			return batch
+		}
+	}
+	if f.failures != nil {
+		batch.err = f.failures.Succeeded(len(batch.data))
+	}
+	return batch
+}
+
+// mapSets maps fetched records, returning those that cannot be mapped as
+// failures of the batch at start of query.
+func (f *Fetcher) mapSets(ctx context.Context, query string, start int, cvSets []ClinVarSet) ([]SNPData, []Failure) {
+	ctx, span := tracing.Start(ctx, "clinvar.map", attribute.Int("records", len(cvSets)))
+	defer span.End()
+	data := make([]SNPData, 0, len(cvSets))
+	var failures []Failure
+	for _, cvSet := range cvSets {
+		snp, err := MapToSNP(cvSet)
 		if err != nil {
-			log.Printf("Error fetching batch: %v", err)
+			acc := cvSet.ReferenceClinVarAssertion.ClinVarAccession.Acc
+			slog.ErrorContext(ctx, "Mapping record failed", "accession", acc, "error", err)
+			failures = append(failures, Failure{Query: query, Start: start, Accession: acc, Records: 1, Err: err})
 			continue
 		}
 
-		for _, cvSet := range cvSets {
-			snp, err := MapToSNP(cvSet)
-			if err != nil {
-				log.Printf("Error mapping SNP: %v", err)
-				continue
-			}
-			if seen[snp.RsID] {
-				continue
-			}
-			seen[snp.RsID] = true
+		clinical := MapToClinical(cvSet, 0)
+		var references []models.Reference
+		if !f.noReferences {
+			references = MapToReferences(cvSet, 0)
+		}
 
-			clinical := MapToClinical(cvSet, 0)
-			references := MapToReferences(cvSet, 0)
+		risks := MapToRiskAlleles(snp, clinical)
+		data = append(data, SNPData{
+			SNP:             snp,
+			Clinical:        clinical,
+			References:      references,
+			RiskAlleles:     risks,
+			GenotypeEffects: models.DeriveGenotypeEffects(snp, risks),
+			Agreements:      MapToAgreements(cvSet, clinical),
+			Source:          models.SourceClinVar,
+			Raw:             cvSet,
+		})
+	}
+	span.SetAttributes(attribute.Int("failures", len(failures)))
+	return data, failures
+}
 
-			result = append(result, SNPData{SNP: snp, Clinical: clinical, References: references})
+// FetchIDs fetches and maps the records with the given ClinVar UIDs, for
+// retrying records whose fetch failed. Records that cannot be mapped are
+// returned as failures; a failed request is returned as the error.
+func (f *Fetcher) FetchIDs(ctx context.Context, ids []string) ([]SNPData, []Failure, error) {
+	ctx = logging.With(ctx, logging.KeySource, sourceName)
+	var (
+		data     []SNPData
+		failures []Failure
+	)
+	for start := 0; start < len(ids); start += batchSize {
+		cvSets, err := f.client.Fetch(ctx, ids[start:min(start+batchSize, len(ids))])
+		if err != nil {
+			return nil, nil, fmt.Errorf("fetch: %w", err)
 		}
+		batchData, batchFailures := f.mapSets(ctx, "", 0, cvSets)
+		data = append(data, batchData...)
+		failures = append(failures, batchFailures...)
+	}
+	return data, failures, nil
+}
 
-		log.Printf("Processed %d/%d variants", start+len(cvSets), totalCount)
+// FetchSearch fetches and maps the batch at start of query's results, for
+// retrying a batch whose search failed or a record searched for by accession.
+// It returns like FetchIDs.
+func (f *Fetcher) FetchSearch(ctx context.Context, query string, start int) ([]SNPData, []Failure, error) {
+	ctx = logging.With(ctx, logging.KeySource, sourceName, logging.KeyQuery, query)
+	searchResp, err := f.client.Search(ctx, query, start, batchSize)
+	if err != nil {
+		return nil, nil, fmt.Errorf("search: %w", err)
+	}
+	if len(searchResp.IdList) == 0 {
+		return nil, nil, nil
+	}
+	data, failures, err := f.FetchIDs(ctx, searchResp.IdList)
+	for i := range failures {
+		failures[i].Query, failures[i].Start = query, start
+	}
+	return data, failures, err
+}
+
+// FetchRsID fetches and maps the records of a single variant. ClinVar files a
+// variant under one record per condition group, so several may be returned;
+// records the search matched for mentioning rsID alongside another variant
+// are dropped. It returns like FetchIDs.
+func (f *Fetcher) FetchRsID(ctx context.Context, rsID string) ([]SNPData, []Failure, error) {
+	rsID, err := models.NormalizeRsID(rsID)
+	if err != nil {
+		return nil, nil, err
 	}
+	data, failures, err := f.FetchSearch(ctx, rsID, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	matched := data[:0]
+	for _, d := range data {
+		if d.SNP.RsID == rsID {
+			matched = append(matched, d)
+		}
+	}
+	return matched, failures, nil
+}
 
-	return result, nil
+// failed reports fail to the failure handler. Requests that failed because
+// ctx was cancelled are not failures of the source and are not reported.
+func (f *Fetcher) failed(ctx context.Context, fail Failure) error {
+	if f.failures == nil || ctx.Err() != nil {
+		return nil
+	}
+	return f.failures.Failed(fail)
 }
 
 // SNPData bundles all related data for a SNP.
-type SNPData struct {
-	SNP        *models.SNP
-	Clinical   []models.ClinicalData
-	References []models.Reference
-}
+type SNPData = models.SNPData
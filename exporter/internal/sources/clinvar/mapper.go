@@ -2,10 +2,12 @@ package clinvar
 
 import (
 	"fmt"
+	"slices"
 	"strings"
 	"time"
 
 	"github.com/mkoziy/genome/exporter/internal/models"
+	"github.com/mkoziy/genome/exporter/internal/variant"
 )
 
 // MapToSNP converts ClinVarSet to SNP model.
@@ -21,29 +23,37 @@ func MapToSNP(cvSet ClinVarSet) (*models.SNP, error) {
 		return nil, fmt.Errorf("no rsID found")
 	}
 
-	seqLoc := findGRCh38Location(measure.SequenceLocation)
+	seqLoc := findLocation(measure.SequenceLocation, "GRCh38")
 	if seqLoc == nil {
 		return nil, fmt.Errorf("no GRCh38 location found")
 	}
 
 	geneSymbol := extractGeneSymbol(measure.MeasureRelationship)
 
-	varType := models.VariantSNV
-	if measure.Type != "" {
-		varType = models.VariantType(measure.Type)
-	}
+	varType, svType := mapVariantType(measure.Type)
 
 	funcClass := extractFunctionalClass(measure.AttributeSet)
+	coding, protein := extractHGVS(measure)
 
+	start, stop := seqLoc.span()
 	snp := &models.SNP{
 		RsID:             rsID,
 		Chromosome:       seqLoc.Chr,
-		Position:         seqLoc.Start,
+		Position:         start,
 		ReferenceAllele:  seqLoc.ReferenceAllele,
 		AlternateAlleles: models.StringArray{seqLoc.AlternateAllele},
 		GeneSymbol:       geneSymbol,
 		VariantType:      varType,
 		FunctionalClass:  funcClass,
+		HGVSCoding:       coding,
+		HGVSProtein:      protein,
+	}
+	if stop > start {
+		snp.EndPosition = &stop
+	}
+	mapStructural(snp, svType, seqLoc.VariantLength)
+	if loc37 := findLocation(measure.SequenceLocation, "GRCh37"); loc37 != nil && loc37.Start > 0 {
+		snp.GRCh37Chromosome, snp.GRCh37Position = &loc37.Chr, &loc37.Start
 	}
 	return snp, nil
 }
@@ -61,6 +71,7 @@ func MapToClinical(cvSet ClinVarSet, snpID int64) []models.ClinicalData {
 		}
 		conditionID := extractConditionID(trait.XRef)
 		lastEval := parseDate(clinSig.DateLastEvaluated)
+		inheritance := extractInheritance(ref.AttributeSet)
 
 		clinical := models.ClinicalData{
 			SNPID:                snpID,
@@ -68,6 +79,7 @@ func MapToClinical(cvSet ClinVarSet, snpID int64) []models.ClinicalData {
 			ReviewStatus:         mapReviewStatus(clinSig.ReviewStatus),
 			ConditionName:        conditionName,
 			ConditionID:          conditionID,
+			InheritancePattern:   inheritance,
 			Source:               models.SourceClinVar,
 			SourceID:             &cvSet.ReferenceClinVarAssertion.ClinVarAccession.Acc,
 			LastEvaluated:        lastEval,
@@ -77,6 +89,66 @@ func MapToClinical(cvSet ClinVarSet, snpID int64) []models.ClinicalData {
 	return result
 }
 
+// MapToAgreements summarizes the classifications of the submissions behind
+// each clinical assertion of MapToClinical: the submissions of a record are
+// on its conditions together, so each condition gets the same counts.
+// Records with no submissions give none.
+func MapToAgreements(cvSet ClinVarSet, clinical []models.ClinicalData) []models.ClinicalAgreement {
+	if len(cvSet.ClinVarAssertion) == 0 {
+		return nil
+	}
+	calls := make([]models.ClinicalSignificance, 0, len(cvSet.ClinVarAssertion))
+	for _, scv := range cvSet.ClinVarAssertion {
+		calls = append(calls, mapClinicalSignificance(scv.ClinicalSignificance.Description))
+	}
+	result := make([]models.ClinicalAgreement, 0, len(clinical))
+	for _, c := range clinical {
+		agreement := models.ClinicalAgreement{
+			SNPID:         c.SNPID,
+			ConditionName: c.ConditionName,
+			ConditionID:   c.ConditionID,
+			Source:        models.SourceClinVar,
+		}
+		agreement.Summarize(calls)
+		result = append(result, agreement)
+	}
+	return result
+}
+
+// riskSignificances are the significances that attribute an effect to the
+// alternate allele.
+var riskSignificances = []models.ClinicalSignificance{
+	models.ClinicalPathogenic, models.ClinicalLikelyPathogenic, models.ClinicalRiskFactor,
+	models.ClinicalProtective, models.ClinicalDrugResponse, models.ClinicalAssociation,
+}
+
+// MapToRiskAlleles returns the alternate alleles of snp as risk alleles of
+// each clinical assertion attributing an effect to them. Benign and
+// uncertain assertions give none.
+func MapToRiskAlleles(snp *models.SNP, clinical []models.ClinicalData) []models.RiskAllele {
+	result := make([]models.RiskAllele, 0)
+	for _, c := range clinical {
+		if !slices.Contains(riskSignificances, c.ClinicalSignificance) {
+			continue
+		}
+		for _, alt := range snp.AlternateAlleles {
+			if alt == "" {
+				continue
+			}
+			result = append(result, models.RiskAllele{
+				SNPID:         c.SNPID,
+				Allele:        alt,
+				Effect:        string(c.ClinicalSignificance),
+				Direction:     c.ClinicalSignificance.Direction(),
+				ConditionName: c.ConditionName,
+				Inheritance:   c.InheritancePattern,
+				Source:        models.SourceClinVar,
+			})
+		}
+	}
+	return result
+}
+
 // MapToReferences extracts PubMed references.
 func MapToReferences(cvSet ClinVarSet, snpID int64) []models.Reference {
 	refs := make([]models.Reference, 0)
@@ -85,7 +157,7 @@ func MapToReferences(cvSet ClinVarSet, snpID int64) []models.Reference {
 	for _, citation := range cvSet.ReferenceClinVarAssertion.ClinicalSignificance.Citation {
 		pmid := extractPubMedID(citation.ID)
 		if pmid != "" && !seen[pmid] {
-			refs = append(refs, models.Reference{SNPID: snpID, PubmedID: &pmid})
+			refs = append(refs, models.Reference{SNPID: snpID, PubmedID: &pmid, Source: models.SourceClinVar})
 			seen[pmid] = true
 		}
 	}
@@ -95,7 +167,7 @@ func MapToReferences(cvSet ClinVarSet, snpID int64) []models.Reference {
 			for _, citation := range obsData.Citation {
 				pmid := extractPubMedID(citation.ID)
 				if pmid != "" && !seen[pmid] {
-					refs = append(refs, models.Reference{SNPID: snpID, PubmedID: &pmid})
+					refs = append(refs, models.Reference{SNPID: snpID, PubmedID: &pmid, Source: models.SourceClinVar})
 					seen[pmid] = true
 				}
 			}
@@ -106,18 +178,76 @@ func MapToReferences(cvSet ClinVarSet, snpID int64) []models.Reference {
 
 // Helpers
 
+// extractRsID returns the dbSNP rsID among xrefs in canonical form. ClinVar
+// writes the ID of a Type="rs" xref with or without the rs prefix.
 func extractRsID(xrefs []XRef) string {
 	for _, xref := range xrefs {
-		if xref.DB == "dbSNP" && strings.HasPrefix(xref.ID, "rs") {
-			return xref.ID
+		if xref.DB != "dbSNP" || (xref.Type != "rs" && !strings.HasPrefix(xref.ID, "rs")) {
+			continue
+		}
+		if rsID, err := models.NormalizeRsID(xref.ID); err == nil {
+			return rsID
 		}
 	}
 	return ""
 }
 
-func findGRCh38Location(locs []SequenceLocation) *SequenceLocation {
+// mapVariantType returns the variant type of a ClinVar variant type, and
+// the structural variant type it is if it is large enough. Types with no
+// counterpart, such as microsatellites, are VariantOther.
+func mapVariantType(clinvarType string) (models.VariantType, models.StructuralVariantType) {
+	switch strings.ToLower(strings.TrimSpace(clinvarType)) {
+	case "", "snv", "single nucleotide variant":
+		return models.VariantSNV, ""
+	case "insertion":
+		return models.VariantInsertion, models.SVInsertion
+	case "deletion":
+		return models.VariantDeletion, models.SVDeletion
+	case "indel":
+		return models.VariantIndel, ""
+	case "duplication", "tandem duplication":
+		return models.VariantDuplication, models.SVDuplication
+	case "inversion":
+		return models.VariantInversion, models.SVInversion
+	case "copy number gain":
+		return models.VariantCNV, models.SVDuplication
+	case "copy number loss":
+		return models.VariantCNV, models.SVDeletion
+	}
+	return models.VariantOther, ""
+}
+
+// mapStructural fills in the structural variant fields of snp if it is one:
+// a copy-number variant or inversion, or an insertion, deletion or
+// duplication of at least models.StructuralVariantMinLength bases or of
+// unspelled sequence. length is ClinVar's variant length, if it gives one;
+// otherwise the span's. Variants whose sequence ClinVar leaves out get the
+// symbolic allele of their type, on an N reference base.
+func mapStructural(snp *models.SNP, svType models.StructuralVariantType, length int64) {
+	if svType == "" || snp.Position <= 0 {
+		return
+	}
+	symbolic := snp.ReferenceAllele == "" && (len(snp.AlternateAlleles) == 0 || snp.AlternateAlleles[0] == "")
+	if length <= 0 {
+		length = snp.End() - snp.Position + 1
+		if svType == models.SVInsertion && !symbolic {
+			length = int64(len(snp.AlternateAlleles[0]))
+		}
+	}
+	if !symbolic && snp.VariantType != models.VariantCNV && svType != models.SVInversion && length < models.StructuralVariantMinLength {
+		return
+	}
+	end := snp.End()
+	snp.EndPosition, snp.SVType, snp.SVLength = &end, &svType, &length
+	if symbolic {
+		snp.ReferenceAllele = "N"
+		snp.AlternateAlleles = models.StringArray{svType.SymbolicAllele()}
+	}
+}
+
+func findLocation(locs []SequenceLocation, assembly string) *SequenceLocation {
 	for _, loc := range locs {
-		if loc.Assembly == "GRCh38" {
+		if loc.Assembly == assembly {
 			return &loc
 		}
 	}
@@ -134,11 +264,63 @@ func extractGeneSymbol(rels []MeasureRelationship) *string {
 	return nil
 }
 
+// extractFunctionalClass returns the functional class of the first molecular
+// consequence naming one, nil if none does.
 func extractFunctionalClass(attrs []AttributeSet) *models.FunctionalClass {
 	for _, attr := range attrs {
 		if attr.Attribute.Type == "MolecularConsequence" {
-			class := models.FunctionalClass(strings.ToLower(attr.Attribute.Value))
-			return &class
+			if class, ok := models.ParseFunctionalClass(attr.Attribute.Value); ok {
+				return &class
+			}
+		}
+	}
+	return nil
+}
+
+// extractHGVS returns the coding and protein HGVS descriptions of a
+// measure on ClinVar's preferred transcript, the one its preferred name is
+// on. The protein change is on the transcript's protein if an HGVS
+// attribute names it, and otherwise on the gene. Measures named otherwise
+// fall back to their first RefSeq descriptions.
+func extractHGVS(measure Measure) (coding, protein *string) {
+	var name, preferredProtein variant.HGVS
+	for _, n := range measure.Name {
+		if n.ElementValue.Type == "Preferred" {
+			name, preferredProtein, _ = variant.ParsePreferredName(n.ElementValue.Value)
+		}
+	}
+	if name.Type == variant.HGVSCoding {
+		coding = hgvsString(name)
+	}
+	for _, attr := range measure.AttributeSet {
+		h, err := variant.ParseHGVS(attr.Attribute.Value)
+		if err != nil {
+			continue
+		}
+		switch {
+		case attr.Attribute.Type == "HGVS, coding, RefSeq" && coding == nil:
+			coding = hgvsString(h)
+		case attr.Attribute.Type == "HGVS, protein, RefSeq" && h.Type == variant.HGVSProtein:
+			if (preferredProtein.Change == "" && protein == nil) || h.Change == preferredProtein.Change {
+				protein = hgvsString(h)
+			}
+		}
+	}
+	if protein == nil && preferredProtein.Change != "" {
+		protein = hgvsString(preferredProtein)
+	}
+	return coding, protein
+}
+
+func hgvsString(h variant.HGVS) *string {
+	s := h.String()
+	return &s
+}
+
+func extractInheritance(attrs []AttributeSet) *string {
+	for _, attr := range attrs {
+		if attr.Attribute.Type == "ModeOfInheritance" && attr.Attribute.Value != "" {
+			return &attr.Attribute.Value
 		}
 	}
 	return nil
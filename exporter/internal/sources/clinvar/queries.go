@@ -88,3 +88,10 @@ func QueryHighEvidenceVariants() string {
 		WithReviewStatus("practice guideline", "reviewed by expert panel").
 		Build()
 }
+
+func QueryExpertPanelPathogenicVariants() string {
+	return NewQueryBuilder().
+		WithClinicalSignificance("pathogenic").
+		WithReviewStatus("practice guideline", "reviewed by expert panel").
+		Build()
+}
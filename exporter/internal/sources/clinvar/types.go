@@ -16,6 +16,9 @@ type SearchResponse struct {
 type ClinVarSet struct {
 	XMLName                   xml.Name                  `xml:"ClinVarSet"`
 	ReferenceClinVarAssertion ReferenceClinVarAssertion `xml:"ReferenceClinVarAssertion"`
+	// ClinVarAssertion are the submissions the reference assertion
+	// aggregates, one per submitter.
+	ClinVarAssertion []ClinVarAssertion `xml:"ClinVarAssertion"`
 }
 
 // ReferenceClinVarAssertion contains the main variant information
@@ -24,9 +27,22 @@ type ReferenceClinVarAssertion struct {
 	ClinicalSignificance ClinicalSignificance `xml:"ClinicalSignificance"`
 	MeasureSet           MeasureSet           `xml:"MeasureSet"`
 	TraitSet             TraitSet             `xml:"TraitSet"`
+	AttributeSet         []AttributeSet       `xml:"AttributeSet"`
 	ObservedIn           []ObservedIn         `xml:"ObservedIn"`
 }
 
+// ClinVarAssertion is one submitter's assertion (SCV)
+type ClinVarAssertion struct {
+	ClinVarAccession     ClinVarAccession     `xml:"ClinVarAccession"`
+	ClinVarSubmissionID  ClinVarSubmissionID  `xml:"ClinVarSubmissionID"`
+	ClinicalSignificance ClinicalSignificance `xml:"ClinicalSignificance"`
+}
+
+// ClinVarSubmissionID names the submitter of an assertion
+type ClinVarSubmissionID struct {
+	Submitter string `xml:"submitter,attr"`
+}
+
 // ClinVarAccession contains the variant ID
 type ClinVarAccession struct {
 	Acc     string `xml:"Acc,attr"`
@@ -99,6 +115,20 @@ type SequenceLocation struct {
 	Stop            int64  `xml:"stop,attr"`
 	ReferenceAllele string `xml:"referenceAllele,attr"`
 	AlternateAllele string `xml:"alternateAllele,attr"`
+	// InnerStart and InnerStop bound copy-number variants whose breakpoints
+	// are imprecise, which have no start and stop.
+	InnerStart    int64 `xml:"innerStart,attr"`
+	InnerStop     int64 `xml:"innerStop,attr"`
+	VariantLength int64 `xml:"variantLength,attr"`
+}
+
+// span returns the first and last positions of the location, from its
+// inner bounds if it has no start.
+func (l *SequenceLocation) span() (int64, int64) {
+	if l.Start > 0 {
+		return l.Start, max(l.Stop, l.Start)
+	}
+	return l.InnerStart, max(l.InnerStop, l.InnerStart)
 }
 
 // XRef contains external references (like rsID)
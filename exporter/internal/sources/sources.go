@@ -0,0 +1,121 @@
+// Package sources lets data sources beyond the built-in ClinVar one be
+// compiled in. A source package registers a factory from an init function;
+// blank-importing it from the command makes the source configurable under
+// sources.<name> and adds it to the pipeline as a stage of its own, recorded,
+// rate limited and written like ClinVar:
+//
+//	func init() {
+//		sources.Register("mylab", func() sources.Source { return &source{} })
+//	}
+package sources
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"sync"
+
+	"github.com/mkoziy/genome/exporter/internal/models"
+	"github.com/mkoziy/genome/exporter/internal/ratelimit"
+)
+
+// Source downloads SNP records from one data source.
+type Source interface {
+	// Name is the source's stage name and key under sources in the config.
+	Name() string
+	// Configure is called once before the first Fetch.
+	Configure(cfg Config) error
+	// Fetch downloads every record, passing each to sink, and returns once
+	// done or when ctx is cancelled.
+	Fetch(ctx context.Context, sink Sink) error
+}
+
+// VariantFetcher is implemented by sources that can look up a single
+// variant, which fetch-one then queries alongside ClinVar.
+type VariantFetcher interface {
+	// FetchRsID returns the records the source has for rsID, given in
+	// canonical form, none if it has no data on the variant.
+	FetchRsID(ctx context.Context, rsID string) ([]models.SNPData, error)
+}
+
+// Config is a source's section of the application config.
+type Config struct {
+	Queries []string
+	APIKey  string
+	Email   string
+	Workers int
+	// Options are the source-specific settings under options.
+	Options map[string]any
+	// Limiter paces requests at the source's configured rate limit. Every
+	// request to the source's API must wait on it.
+	Limiter ratelimit.Limiter
+	// Transport is the shared HTTP transport, e.g. the on-disk response
+	// cache, or nil for http.DefaultTransport.
+	Transport http.RoundTripper
+}
+
+// Sink receives fetched records. Records failing validation are quarantined
+// rather than written, so a source need not drop them itself; that includes
+// rsIDs not in canonical form, which a source normalizes with
+// models.NormalizeRsID.
+type Sink interface {
+	// Put queues data for writing, blocking while the writer is behind.
+	Put(ctx context.Context, data models.SNPData) error
+}
+
+// ChanSink is a Sink sending records on a channel.
+type ChanSink chan<- models.SNPData
+
+// Put sends data unless ctx is cancelled first.
+func (c ChanSink) Put(ctx context.Context, data models.SNPData) error {
+	select {
+	case c <- data:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+var (
+	mu       sync.RWMutex
+	registry = make(map[string]func() Source)
+)
+
+// Register makes a source available under name. It panics if name is empty
+// or already registered, as database/sql.Register does, since both are
+// programming errors caught at startup.
+func Register(name string, factory func() Source) {
+	mu.Lock()
+	defer mu.Unlock()
+	if name == "" || factory == nil {
+		panic("sources: Register needs a name and a factory")
+	}
+	if _, ok := registry[name]; ok {
+		panic("sources: Register called twice for " + name)
+	}
+	registry[name] = factory
+}
+
+// New returns a new instance of the named source.
+func New(name string) (Source, error) {
+	mu.RLock()
+	factory, ok := registry[name]
+	mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown source %q", name)
+	}
+	return factory(), nil
+}
+
+// Names lists the registered sources in order.
+func Names() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
@@ -0,0 +1,82 @@
+package sources
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/mkoziy/genome/exporter/internal/models"
+)
+
+type testSource struct {
+	cfg Config
+}
+
+func (s *testSource) Name() string { return "test" }
+
+func (s *testSource) Configure(cfg Config) error {
+	s.cfg = cfg
+	return nil
+}
+
+func (s *testSource) Fetch(ctx context.Context, sink Sink) error {
+	for _, q := range s.cfg.Queries {
+		if err := sink.Put(ctx, models.SNPData{SNP: &models.SNP{RsID: q}}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestRegistry(t *testing.T) {
+	Register("test", func() Source { return &testSource{} })
+	defer func() {
+		mu.Lock()
+		delete(registry, "test")
+		mu.Unlock()
+	}()
+
+	if names := Names(); len(names) != 1 || names[0] != "test" {
+		t.Fatalf("unexpected names: %v", names)
+	}
+	src, err := New("test")
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	if other, _ := New("test"); other == src {
+		t.Fatalf("expected a new instance per call")
+	}
+	if _, err := New("missing"); err == nil {
+		t.Fatalf("expected error for an unregistered source")
+	}
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Fatalf("expected registering a name twice to panic")
+			}
+		}()
+		Register("test", func() Source { return &testSource{} })
+	}()
+}
+
+func TestChanSinkStopsOnCancel(t *testing.T) {
+	src := &testSource{}
+	if err := src.Configure(Config{Queries: []string{"rs1", "rs2"}}); err != nil {
+		t.Fatalf("configure: %v", err)
+	}
+
+	out := make(chan models.SNPData)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- src.Fetch(ctx, ChanSink(out)) }()
+
+	if got := (<-out).SNP.RsID; got != "rs1" {
+		t.Fatalf("expected rs1, got %s", got)
+	}
+	// Nothing reads rs2, so Put blocks until the fetch is cancelled.
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected cancellation, got %v", err)
+	}
+}
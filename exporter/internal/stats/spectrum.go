@@ -0,0 +1,140 @@
+package stats
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/uptrace/bun"
+
+	"github.com/mkoziy/genome/exporter/internal/models"
+)
+
+// SpectrumEdges bound the bins of the frequency spectrum, from MAF 0 to 0.5,
+// the last bin taking in 0.5 itself. They are finer where most variants of
+// clinical interest are rare, and include models.RareMAF and
+// models.CommonMAF so each bin falls in one frequency class.
+var SpectrumEdges = []float64{0, 0.001, 0.005, 0.01, 0.02, 0.05, 0.1, 0.2, 0.3, 0.4, 0.5}
+
+// SpectrumBin is one bin of a frequency spectrum, counting the SNPs whose
+// global MAF is in [Start, End).
+type SpectrumBin struct {
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+	Count int     `json:"count"`
+}
+
+// FrequencyGroup is the site frequency spectrum of a group of SNPs, folded
+// on their global MAF, and how many are rare, of low frequency, common and
+// without a global MAF.
+type FrequencyGroup struct {
+	SNPs         int           `json:"snps"`
+	Bins         []SpectrumBin `json:"bins"`
+	Rare         int           `json:"rare"`
+	LowFrequency int           `json:"low_frequency"`
+	Common       int           `json:"common"`
+	Unmeasured   int           `json:"unmeasured"`
+}
+
+func newFrequencyGroup() *FrequencyGroup {
+	g := &FrequencyGroup{Bins: make([]SpectrumBin, len(SpectrumEdges)-1)}
+	for i := range g.Bins {
+		g.Bins[i] = SpectrumBin{Start: SpectrumEdges[i], End: SpectrumEdges[i+1]}
+	}
+	return g
+}
+
+// add counts count SNPs into bin, -1 for those without a global MAF.
+func (g *FrequencyGroup) add(bin, count int) {
+	g.SNPs += count
+	if bin < 0 {
+		g.Unmeasured += count
+		return
+	}
+	g.Bins[bin].Count += count
+	switch end := g.Bins[bin].End; {
+	case end <= models.RareMAF:
+		g.Rare += count
+	case end <= models.CommonMAF:
+		g.LowFrequency += count
+	default:
+		g.Common += count
+	}
+}
+
+// FrequencySpectrum is the frequency spectrum of all SNPs, of those on each
+// chromosome and of those with each clinical significance. A SNP with
+// assertions of several significances counts under each.
+type FrequencySpectrum struct {
+	All                  *FrequencyGroup            `json:"all"`
+	Chromosomes          map[string]*FrequencyGroup `json:"chromosomes"`
+	ClinicalSignificance map[string]*FrequencyGroup `json:"clinical_significance"`
+}
+
+// spectrumBin returns the SQL expression of the SpectrumEdges bin of
+// f.global_maf, -1 where it is null.
+func spectrumBin() string {
+	var b strings.Builder
+	b.WriteString("CASE WHEN f.global_maf IS NULL THEN -1")
+	last := len(SpectrumEdges) - 2
+	for i := 0; i < last; i++ {
+		fmt.Fprintf(&b, " WHEN f.global_maf < %s THEN %d", strconv.FormatFloat(SpectrumEdges[i+1], 'g', -1, 64), i)
+	}
+	fmt.Fprintf(&b, " ELSE %d END", last)
+	return b.String()
+}
+
+type binCount struct {
+	Key   string `bun:"key"`
+	Bin   int    `bun:"bin"`
+	Count int    `bun:"count"`
+}
+
+// GetFrequencySpectrum returns the frequency spectrum of the SNPs from the
+// global MAFs the frequency stage aggregated; SNPs never aggregated count
+// as unmeasured.
+func GetFrequencySpectrum(ctx context.Context, db bun.IDB) (*FrequencySpectrum, error) {
+	spectrum := &FrequencySpectrum{
+		All:                  newFrequencyGroup(),
+		Chromosomes:          make(map[string]*FrequencyGroup),
+		ClinicalSignificance: make(map[string]*FrequencyGroup),
+	}
+	bin := spectrumBin()
+
+	var rows []binCount
+	if err := db.NewRaw(`
+		SELECT s.chromosome AS key, `+bin+` AS bin, COUNT(*) AS count
+		FROM snps AS s
+		LEFT JOIN snp_frequencies AS f ON f.snp_id = s.id
+		WHERE s.deleted_at IS NULL
+		GROUP BY key, bin`).Scan(ctx, &rows); err != nil {
+		return nil, fmt.Errorf("spectrum by chromosome: %w", err)
+	}
+	for _, r := range rows {
+		if spectrum.Chromosomes[r.Key] == nil {
+			spectrum.Chromosomes[r.Key] = newFrequencyGroup()
+		}
+		spectrum.Chromosomes[r.Key].add(r.Bin, r.Count)
+		spectrum.All.add(r.Bin, r.Count)
+	}
+
+	rows = nil
+	if err := db.NewRaw(`
+		SELECT c.clinical_significance AS key, `+bin+` AS bin, COUNT(DISTINCT c.snp_id) AS count
+		FROM snp_clinical AS c
+		JOIN snps AS s ON s.id = c.snp_id
+		LEFT JOIN snp_frequencies AS f ON f.snp_id = c.snp_id
+		WHERE c.deleted_at IS NULL AND s.deleted_at IS NULL
+		GROUP BY key, bin`).Scan(ctx, &rows); err != nil {
+		return nil, fmt.Errorf("spectrum by clinical significance: %w", err)
+	}
+	for _, r := range rows {
+		if spectrum.ClinicalSignificance[r.Key] == nil {
+			spectrum.ClinicalSignificance[r.Key] = newFrequencyGroup()
+		}
+		spectrum.ClinicalSignificance[r.Key].add(r.Bin, r.Count)
+	}
+
+	return spectrum, nil
+}
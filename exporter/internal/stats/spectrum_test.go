@@ -0,0 +1,47 @@
+package stats
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mkoziy/genome/exporter/internal/models"
+)
+
+func TestGetFrequencySpectrum(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+	seed(t, db)
+
+	maf := func(f float64) *float64 { return &f }
+	freqs := []*models.Frequency{
+		{SNPID: 1, GlobalMAF: maf(0.004)},
+		{SNPID: 2, GlobalMAF: maf(0.5)},
+		{SNPID: 3, GlobalMAF: maf(0.03)},
+		// Aggregated, but no source measured it over all samples.
+		{SNPID: 4},
+	}
+	if _, err := db.NewInsert().Model(&freqs).Exec(ctx); err != nil {
+		t.Fatalf("insert frequencies: %v", err)
+	}
+
+	spectrum, err := GetFrequencySpectrum(ctx, db)
+	if err != nil {
+		t.Fatalf("spectrum: %v", err)
+	}
+	all := spectrum.All
+	if all.SNPs != 4 || all.Rare != 1 || all.LowFrequency != 1 || all.Common != 1 || all.Unmeasured != 1 {
+		t.Fatalf("unexpected classes: %+v", all)
+	}
+	if len(all.Bins) != len(SpectrumEdges)-1 || all.Bins[1].Count != 1 || all.Bins[4].Count != 1 || all.Bins[len(all.Bins)-1].Count != 1 {
+		t.Fatalf("unexpected bins: %+v", all.Bins)
+	}
+	if x := spectrum.Chromosomes["X"]; x == nil || x.LowFrequency != 1 || x.Unmeasured != 1 {
+		t.Fatalf("unexpected X classes: %+v", x)
+	}
+	if p := spectrum.ClinicalSignificance[string(models.ClinicalPathogenic)]; p == nil || p.SNPs != 1 || p.Rare != 1 {
+		t.Fatalf("unexpected pathogenic classes: %+v", p)
+	}
+	if b := spectrum.ClinicalSignificance[string(models.ClinicalBenign)]; b == nil || b.Common != 1 {
+		t.Fatalf("unexpected benign classes: %+v", b)
+	}
+}
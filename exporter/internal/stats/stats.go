@@ -0,0 +1,189 @@
+package stats
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/uptrace/bun"
+
+	"github.com/mkoziy/genome/exporter/internal/models"
+)
+
+// countedTables are the tables reported by GetCounts.
+var countedTables = []string{
+	"snps",
+	"snp_significance",
+	"snp_clinical",
+	"snp_phenotypes",
+	"snp_references",
+	"snp_populations",
+	"snp_translations",
+	"phenotype_translations",
+	"message_translations",
+	"failed_items",
+	"quarantined_records",
+	"retractions",
+}
+
+// Counts holds row counts broken down by table, chromosome, clinical significance and source.
+type Counts struct {
+	Tables               map[string]int `json:"tables"`
+	Chromosomes          map[string]int `json:"chromosomes"`
+	ClinicalSignificance map[string]int `json:"clinical_significance"`
+	Sources              map[string]int `json:"sources"`
+}
+
+// ScoreBucket is one bucket of the score distribution, covering [Start, End).
+type ScoreBucket struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+	Count int `json:"count"`
+}
+
+// ScoreDistribution summarizes snp_significance.total_score.
+type ScoreDistribution struct {
+	Scored  int            `json:"scored"`
+	Min     float64        `json:"min"`
+	Max     float64        `json:"max"`
+	Mean    float64        `json:"mean"`
+	Levels  map[string]int `json:"levels"`
+	Buckets []ScoreBucket  `json:"buckets"`
+}
+
+// Coverage reports how many SNPs carry each kind of related data.
+type Coverage struct {
+	TotalSNPs          int `json:"total_snps"`
+	WithScore          int `json:"with_score"`
+	WithClinical       int `json:"with_clinical"`
+	WithPopulationData int `json:"with_population_data"`
+	WithReferences     int `json:"with_references"`
+	WithTranslations   int `json:"with_translations"`
+}
+
+// Fraction returns n as a fraction of all SNPs.
+func (c *Coverage) Fraction(n int) float64 {
+	if c.TotalSNPs == 0 {
+		return 0
+	}
+	return float64(n) / float64(c.TotalSNPs)
+}
+
+type keyCount struct {
+	Key   string `bun:"key"`
+	Count int    `bun:"count"`
+}
+
+// GetCounts returns row counts per table, SNPs per chromosome, clinical rows per
+// significance and annotation rows per source.
+func GetCounts(ctx context.Context, db *bun.DB) (*Counts, error) {
+	counts := &Counts{Tables: make(map[string]int, len(countedTables))}
+
+	for _, table := range countedTables {
+		n, err := db.NewSelect().Table(table).Count(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("count %s: %w", table, err)
+		}
+		counts.Tables[table] = n
+	}
+
+	var err error
+	if counts.Chromosomes, err = groupCounts(ctx, db,
+		"SELECT chromosome AS key, COUNT(*) AS count FROM snps WHERE deleted_at IS NULL GROUP BY chromosome"); err != nil {
+		return nil, fmt.Errorf("count chromosomes: %w", err)
+	}
+	if counts.ClinicalSignificance, err = groupCounts(ctx, db,
+		"SELECT clinical_significance AS key, COUNT(*) AS count FROM snp_clinical WHERE deleted_at IS NULL GROUP BY clinical_significance"); err != nil {
+		return nil, fmt.Errorf("count clinical significance: %w", err)
+	}
+	if counts.Sources, err = groupCounts(ctx, db, `
+		SELECT source AS key, COUNT(*) AS count FROM (
+			SELECT source FROM snp_clinical WHERE deleted_at IS NULL
+			UNION ALL SELECT source FROM snp_phenotypes WHERE deleted_at IS NULL
+			UNION ALL SELECT source FROM snp_populations WHERE deleted_at IS NULL
+			UNION ALL SELECT source FROM snp_references WHERE source IS NOT NULL AND deleted_at IS NULL
+		) GROUP BY source`); err != nil {
+		return nil, fmt.Errorf("count sources: %w", err)
+	}
+
+	return counts, nil
+}
+
+// GetScoreDistribution returns summary statistics, SignificanceLevel counts and
+// 10-point buckets of the total score.
+func GetScoreDistribution(ctx context.Context, db *bun.DB) (*ScoreDistribution, error) {
+	dist := &ScoreDistribution{Levels: make(map[string]int), Buckets: make([]ScoreBucket, 0)}
+
+	err := db.NewSelect().
+		Model((*models.Significance)(nil)).
+		ColumnExpr("COUNT(*), COALESCE(MIN(total_score), 0), COALESCE(MAX(total_score), 0), COALESCE(AVG(total_score), 0)").
+		Scan(ctx, &dist.Scored, &dist.Min, &dist.Max, &dist.Mean)
+	if err != nil {
+		return nil, err
+	}
+
+	// Whole-point counts are enough to derive both levels and buckets, since
+	// every threshold is an integer.
+	var points []struct {
+		Score int `bun:"score"`
+		Count int `bun:"count"`
+	}
+	err = db.NewSelect().
+		Model((*models.Significance)(nil)).
+		ColumnExpr("CAST(total_score AS INTEGER) AS score, COUNT(*) AS count").
+		GroupExpr("score").
+		OrderExpr("score").
+		Scan(ctx, &points)
+	if err != nil {
+		return nil, err
+	}
+
+	buckets := make(map[int]int)
+	for _, p := range points {
+		level := (&models.Significance{TotalScore: float64(p.Score)}).SignificanceLevel()
+		dist.Levels[level] += p.Count
+
+		start := p.Score / 10 * 10
+		if start >= 100 {
+			start = 90
+		}
+		buckets[start] += p.Count
+	}
+	for start := 0; start < 100; start += 10 {
+		if n, ok := buckets[start]; ok {
+			dist.Buckets = append(dist.Buckets, ScoreBucket{Start: start, End: start + 10, Count: n})
+		}
+	}
+
+	return dist, nil
+}
+
+// GetCoverage returns how many SNPs have scores, clinical annotations, population
+// data, references and translations.
+func GetCoverage(ctx context.Context, db *bun.DB) (*Coverage, error) {
+	cov := &Coverage{}
+	err := db.NewRaw(`
+		SELECT
+			(SELECT COUNT(*) FROM snps WHERE deleted_at IS NULL),
+			(SELECT COUNT(DISTINCT snp_id) FROM snp_significance WHERE snp_id IN (SELECT id FROM snps WHERE deleted_at IS NULL)),
+			(SELECT COUNT(DISTINCT snp_id) FROM snp_clinical WHERE deleted_at IS NULL),
+			(SELECT COUNT(DISTINCT snp_id) FROM snp_populations WHERE deleted_at IS NULL),
+			(SELECT COUNT(DISTINCT snp_id) FROM snp_references WHERE deleted_at IS NULL),
+			(SELECT COUNT(DISTINCT snp_id) FROM snp_translations WHERE snp_id IN (SELECT id FROM snps WHERE deleted_at IS NULL))`).
+		Scan(ctx, &cov.TotalSNPs, &cov.WithScore, &cov.WithClinical, &cov.WithPopulationData, &cov.WithReferences, &cov.WithTranslations)
+	if err != nil {
+		return nil, err
+	}
+	return cov, nil
+}
+
+func groupCounts(ctx context.Context, db *bun.DB, query string) (map[string]int, error) {
+	var rows []keyCount
+	if err := db.NewRaw(query).Scan(ctx, &rows); err != nil {
+		return nil, err
+	}
+	result := make(map[string]int, len(rows))
+	for _, r := range rows {
+		result[r.Key] = r.Count
+	}
+	return result, nil
+}
@@ -0,0 +1,116 @@
+package stats
+
+import (
+	"context"
+	"testing"
+
+	"github.com/uptrace/bun"
+
+	"github.com/mkoziy/genome/exporter/internal/database"
+	"github.com/mkoziy/genome/exporter/internal/migrations"
+	"github.com/mkoziy/genome/exporter/internal/models"
+)
+
+func newTestDB(t *testing.T) *bun.DB {
+	t.Helper()
+	db, err := database.NewDB("file:"+t.Name()+"?mode=memory&cache=shared", false)
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	if err := migrations.RunMigrations(context.Background(), db); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	return db
+}
+
+func seed(t *testing.T, db *bun.DB) {
+	t.Helper()
+	ctx := context.Background()
+
+	snps := []*models.SNP{
+		{RsID: "rs1", Chromosome: "1", Position: 1, ReferenceAllele: "A", AlternateAlleles: models.StringArray{"G"}, VariantType: models.VariantSNV},
+		{RsID: "rs2", Chromosome: "1", Position: 2, ReferenceAllele: "A", AlternateAlleles: models.StringArray{"G"}, VariantType: models.VariantSNV},
+		{RsID: "rs3", Chromosome: "X", Position: 3, ReferenceAllele: "A", AlternateAlleles: models.StringArray{"G"}, VariantType: models.VariantSNV},
+		{RsID: "rs4", Chromosome: "X", Position: 4, ReferenceAllele: "A", AlternateAlleles: models.StringArray{"G"}, VariantType: models.VariantSNV},
+	}
+	if _, err := db.NewInsert().Model(&snps).Exec(ctx); err != nil {
+		t.Fatalf("insert snps: %v", err)
+	}
+	clinical := []*models.ClinicalData{
+		{SNPID: snps[0].ID, ClinicalSignificance: models.ClinicalPathogenic, ReviewStatus: models.ReviewExpertPanel, ConditionName: "A", Source: models.SourceClinVar},
+		{SNPID: snps[1].ID, ClinicalSignificance: models.ClinicalBenign, ReviewStatus: models.ReviewExpertPanel, ConditionName: "B", Source: models.SourceClinVar},
+	}
+	if _, err := db.NewInsert().Model(&clinical).Exec(ctx); err != nil {
+		t.Fatalf("insert clinical: %v", err)
+	}
+	pops := []*models.PopulationFreq{{SNPID: snps[0].ID, PopulationCode: "EUR", Allele: "G", Frequency: 0.1, Source: models.SourceGnomAD}}
+	if _, err := db.NewInsert().Model(&pops).Exec(ctx); err != nil {
+		t.Fatalf("insert populations: %v", err)
+	}
+	sigs := []*models.Significance{
+		{SNPID: snps[0].ID, TotalScore: 85},
+		{SNPID: snps[1].ID, TotalScore: 100},
+		{SNPID: snps[2].ID, TotalScore: 15},
+	}
+	if _, err := db.NewInsert().Model(&sigs).Exec(ctx); err != nil {
+		t.Fatalf("insert significance: %v", err)
+	}
+}
+
+func TestGetCounts(t *testing.T) {
+	db := newTestDB(t)
+	seed(t, db)
+
+	counts, err := GetCounts(context.Background(), db)
+	if err != nil {
+		t.Fatalf("counts: %v", err)
+	}
+	if counts.Tables["snps"] != 4 || counts.Tables["snp_clinical"] != 2 {
+		t.Fatalf("unexpected table counts: %v", counts.Tables)
+	}
+	if counts.Chromosomes["X"] != 2 {
+		t.Fatalf("unexpected chromosome counts: %v", counts.Chromosomes)
+	}
+	if counts.ClinicalSignificance[string(models.ClinicalPathogenic)] != 1 {
+		t.Fatalf("unexpected significance counts: %v", counts.ClinicalSignificance)
+	}
+	if counts.Sources["clinvar"] != 2 || counts.Sources["gnomad"] != 1 {
+		t.Fatalf("unexpected source counts: %v", counts.Sources)
+	}
+}
+
+func TestGetScoreDistribution(t *testing.T) {
+	db := newTestDB(t)
+	seed(t, db)
+
+	dist, err := GetScoreDistribution(context.Background(), db)
+	if err != nil {
+		t.Fatalf("distribution: %v", err)
+	}
+	if dist.Scored != 3 || dist.Min != 15 || dist.Max != 100 {
+		t.Fatalf("unexpected summary: %+v", dist)
+	}
+	if dist.Levels["Very High"] != 2 || dist.Levels["Minimal"] != 1 {
+		t.Fatalf("unexpected levels: %v", dist.Levels)
+	}
+	if len(dist.Buckets) != 3 || dist.Buckets[2].Start != 90 {
+		t.Fatalf("unexpected buckets: %+v", dist.Buckets)
+	}
+}
+
+func TestGetCoverage(t *testing.T) {
+	db := newTestDB(t)
+	seed(t, db)
+
+	cov, err := GetCoverage(context.Background(), db)
+	if err != nil {
+		t.Fatalf("coverage: %v", err)
+	}
+	if cov.TotalSNPs != 4 || cov.WithScore != 3 || cov.WithPopulationData != 1 {
+		t.Fatalf("unexpected coverage: %+v", cov)
+	}
+	if got := cov.Fraction(cov.WithClinical); got != 0.5 {
+		t.Fatalf("expected clinical coverage 0.5, got %v", got)
+	}
+}
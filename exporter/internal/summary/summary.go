@@ -0,0 +1,140 @@
+package summary
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/uptrace/bun"
+
+	"github.com/mkoziy/genome/exporter/internal/models"
+	"github.com/mkoziy/genome/exporter/internal/stats"
+)
+
+// histogramBucketSize is the width of each score histogram bucket.
+const histogramBucketSize = 10
+
+var pathogenic = []models.ClinicalSignificance{models.ClinicalPathogenic, models.ClinicalLikelyPathogenic}
+
+// Refresh rebuilds all summary tables from the current contents of the snps tables.
+// The rebuild runs in a single transaction so readers never observe a partial refresh.
+func Refresh(ctx context.Context, db *bun.DB) error {
+	return db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		steps := []struct {
+			name  string
+			model interface{}
+			query string
+			args  []interface{}
+		}{
+			{
+				name:  "chromosomes",
+				model: (*models.ChromosomeCount)(nil),
+				query: `INSERT INTO summary_chromosomes (chromosome, snp_count, pathogenic_count, refreshed_at)
+					SELECT s.chromosome, COUNT(*),
+						SUM(CASE WHEN EXISTS (
+							SELECT 1 FROM snp_clinical AS c WHERE c.snp_id = s.id AND c.clinical_significance IN (?) AND c.deleted_at IS NULL
+						) THEN 1 ELSE 0 END),
+						CURRENT_TIMESTAMP
+					FROM snps AS s
+					WHERE s.deleted_at IS NULL
+					GROUP BY s.chromosome`,
+				args: []interface{}{bun.In(pathogenic)},
+			},
+			{
+				name:  "clinical significance",
+				model: (*models.ClinicalSignificanceCount)(nil),
+				query: `INSERT INTO summary_clinical_significance (clinical_significance, record_count, snp_count, refreshed_at)
+					SELECT c.clinical_significance, COUNT(*), COUNT(DISTINCT c.snp_id), CURRENT_TIMESTAMP
+					FROM snp_clinical AS c
+					WHERE c.deleted_at IS NULL
+					GROUP BY c.clinical_significance`,
+			},
+			{
+				name:  "genes",
+				model: (*models.GeneCount)(nil),
+				query: `INSERT INTO summary_genes (gene_symbol, snp_count, pathogenic_count, max_score, refreshed_at)
+					SELECT s.gene_symbol, COUNT(*),
+						SUM(CASE WHEN EXISTS (
+							SELECT 1 FROM snp_clinical AS c WHERE c.snp_id = s.id AND c.clinical_significance IN (?) AND c.deleted_at IS NULL
+						) THEN 1 ELSE 0 END),
+						MAX(sig.total_score),
+						CURRENT_TIMESTAMP
+					FROM snps AS s
+					LEFT JOIN snp_significance AS sig ON sig.snp_id = s.id
+					WHERE s.gene_symbol IS NOT NULL AND s.gene_symbol <> '' AND s.deleted_at IS NULL
+					GROUP BY s.gene_symbol`,
+				args: []interface{}{bun.In(pathogenic)},
+			},
+			{
+				name:  "score histogram",
+				model: (*models.ScoreHistogramBin)(nil),
+				query: `INSERT INTO summary_score_histogram (bucket_start, bucket_end, snp_count, refreshed_at)
+					SELECT b.bucket * ?0, b.bucket * ?0 + ?0, COUNT(*), CURRENT_TIMESTAMP
+					FROM (
+						SELECT MIN(CAST(total_score / ?0 AS INTEGER), 100 / ?0 - 1) AS bucket
+						FROM snp_significance
+						WHERE snp_id IN (SELECT id FROM snps WHERE deleted_at IS NULL)
+					) AS b
+					GROUP BY b.bucket`,
+				args: []interface{}{histogramBucketSize},
+			},
+		}
+
+		for _, step := range steps {
+			if _, err := tx.NewDelete().Model(step.model).Where("1 = 1").Exec(ctx); err != nil {
+				return fmt.Errorf("clear %s summary: %w", step.name, err)
+			}
+			if _, err := tx.ExecContext(ctx, step.query, step.args...); err != nil {
+				return fmt.Errorf("refresh %s summary: %w", step.name, err)
+			}
+		}
+
+		return refreshFrequencies(ctx, tx)
+	})
+}
+
+// refreshFrequencies rebuilds the frequency spectrum and class summaries
+// from stats.GetFrequencySpectrum.
+func refreshFrequencies(ctx context.Context, tx bun.Tx) error {
+	spectrum, err := stats.GetFrequencySpectrum(ctx, tx)
+	if err != nil {
+		return err
+	}
+
+	bins := make([]*models.FrequencySpectrumBin, 0)
+	classes := make([]*models.FrequencyClassCount, 0)
+	add := func(grouping, name string, g *stats.FrequencyGroup) {
+		for _, b := range g.Bins {
+			bins = append(bins, &models.FrequencySpectrumBin{
+				Grouping: grouping, GroupName: name, BinStart: b.Start, BinEnd: b.End, SNPCount: b.Count,
+			})
+		}
+		classes = append(classes, &models.FrequencyClassCount{
+			Grouping: grouping, GroupName: name,
+			RareCount: g.Rare, LowFrequencyCount: g.LowFrequency, CommonCount: g.Common, UnmeasuredCount: g.Unmeasured,
+		})
+	}
+	add(models.FrequencyGroupingAll, "", spectrum.All)
+	for name, g := range spectrum.Chromosomes {
+		add(models.FrequencyGroupingChromosome, name, g)
+	}
+	for name, g := range spectrum.ClinicalSignificance {
+		add(models.FrequencyGroupingClinicalSignificance, name, g)
+	}
+
+	for _, step := range []struct {
+		name  string
+		model interface{}
+		rows  interface{}
+	}{
+		{"frequency spectrum", (*models.FrequencySpectrumBin)(nil), &bins},
+		{"frequency classes", (*models.FrequencyClassCount)(nil), &classes},
+	} {
+		if _, err := tx.NewDelete().Model(step.model).Where("1 = 1").Exec(ctx); err != nil {
+			return fmt.Errorf("clear %s summary: %w", step.name, err)
+		}
+		if _, err := tx.NewInsert().Model(step.rows).Exec(ctx); err != nil {
+			return fmt.Errorf("refresh %s summary: %w", step.name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package summary
+
+import (
+	"context"
+	"testing"
+
+	"github.com/uptrace/bun"
+
+	"github.com/mkoziy/genome/exporter/internal/database"
+	"github.com/mkoziy/genome/exporter/internal/migrations"
+	"github.com/mkoziy/genome/exporter/internal/models"
+)
+
+func newTestDB(t *testing.T) *bun.DB {
+	t.Helper()
+	db, err := database.NewDB("file:"+t.Name()+"?mode=memory&cache=shared", false)
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	if err := migrations.RunMigrations(context.Background(), db); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	return db
+}
+
+func TestRefresh(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+
+	gene := "APOE"
+	snps := []*models.SNP{
+		{RsID: "rs1", Chromosome: "19", Position: 1, ReferenceAllele: "C", AlternateAlleles: models.StringArray{"T"}, GeneSymbol: &gene, VariantType: models.VariantSNV},
+		{RsID: "rs2", Chromosome: "19", Position: 2, ReferenceAllele: "A", AlternateAlleles: models.StringArray{"G"}, GeneSymbol: &gene, VariantType: models.VariantSNV},
+		{RsID: "rs3", Chromosome: "1", Position: 3, ReferenceAllele: "G", AlternateAlleles: models.StringArray{"A"}, VariantType: models.VariantSNV},
+	}
+	if _, err := db.NewInsert().Model(&snps).Exec(ctx); err != nil {
+		t.Fatalf("insert snps: %v", err)
+	}
+	clinical := []*models.ClinicalData{
+		{SNPID: snps[0].ID, ClinicalSignificance: models.ClinicalPathogenic, ReviewStatus: models.ReviewExpertPanel, ConditionName: "A", Source: models.SourceClinVar},
+		{SNPID: snps[0].ID, ClinicalSignificance: models.ClinicalPathogenic, ReviewStatus: models.ReviewExpertPanel, ConditionName: "B", Source: models.SourceClinVar},
+		{SNPID: snps[2].ID, ClinicalSignificance: models.ClinicalBenign, ReviewStatus: models.ReviewSingleSubmitter, ConditionName: "C", Source: models.SourceClinVar},
+	}
+	if _, err := db.NewInsert().Model(&clinical).Exec(ctx); err != nil {
+		t.Fatalf("insert clinical: %v", err)
+	}
+	sigs := []*models.Significance{
+		{SNPID: snps[0].ID, TotalScore: 85},
+		{SNPID: snps[1].ID, TotalScore: 100},
+		{SNPID: snps[2].ID, TotalScore: 12},
+	}
+	if _, err := db.NewInsert().Model(&sigs).Exec(ctx); err != nil {
+		t.Fatalf("insert significance: %v", err)
+	}
+
+	// Refreshing twice must not duplicate rows.
+	for i := 0; i < 2; i++ {
+		if err := Refresh(ctx, db); err != nil {
+			t.Fatalf("refresh: %v", err)
+		}
+	}
+
+	var chroms []models.ChromosomeCount
+	if err := db.NewSelect().Model(&chroms).Order("chromosome").Scan(ctx); err != nil {
+		t.Fatalf("select chromosomes: %v", err)
+	}
+	if len(chroms) != 2 || chroms[1].Chromosome != "19" || chroms[1].SNPCount != 2 || chroms[1].PathogenicCount != 1 {
+		t.Fatalf("unexpected chromosome summary: %+v", chroms)
+	}
+
+	var clin []models.ClinicalSignificanceCount
+	if err := db.NewSelect().Model(&clin).Where("clinical_significance = ?", models.ClinicalPathogenic).Scan(ctx); err != nil {
+		t.Fatalf("select clinical: %v", err)
+	}
+	if len(clin) != 1 || clin[0].RecordCount != 2 || clin[0].SNPCount != 1 {
+		t.Fatalf("unexpected clinical summary: %+v", clin)
+	}
+
+	var genes []models.GeneCount
+	if err := db.NewSelect().Model(&genes).Scan(ctx); err != nil {
+		t.Fatalf("select genes: %v", err)
+	}
+	if len(genes) != 1 || genes[0].SNPCount != 2 || genes[0].MaxScore == nil || *genes[0].MaxScore != 100 {
+		t.Fatalf("unexpected gene summary: %+v", genes)
+	}
+
+	var bins []models.ScoreHistogramBin
+	if err := db.NewSelect().Model(&bins).Order("bucket_start").Scan(ctx); err != nil {
+		t.Fatalf("select histogram: %v", err)
+	}
+	// A perfect score of 100 falls into the last bucket rather than its own.
+	if len(bins) != 3 || bins[0].BucketStart != 10 || bins[1].BucketStart != 80 || bins[2].BucketStart != 90 || bins[2].BucketEnd != 100 {
+		t.Fatalf("unexpected histogram: %+v", bins)
+	}
+
+	var classes []models.FrequencyClassCount
+	if err := db.NewSelect().Model(&classes).Order("grouping", "group_name").Scan(ctx); err != nil {
+		t.Fatalf("select frequency classes: %v", err)
+	}
+	// all, two chromosomes and two clinical significances; nothing was
+	// aggregated, so every SNP is unmeasured.
+	if len(classes) != 5 || classes[0].Grouping != models.FrequencyGroupingAll || classes[0].UnmeasuredCount != 3 {
+		t.Fatalf("unexpected frequency classes: %+v", classes)
+	}
+	spectrum, err := db.NewSelect().Model((*models.FrequencySpectrumBin)(nil)).Where("grouping = ?", models.FrequencyGroupingChromosome).Count(ctx)
+	if err != nil {
+		t.Fatalf("count frequency spectrum: %v", err)
+	}
+	if spectrum != 2*10 {
+		t.Fatalf("expected the ten bins of two chromosomes, got %d", spectrum)
+	}
+}
@@ -0,0 +1,105 @@
+// Package tracing sets up OpenTelemetry tracing and gives the pipeline,
+// sources and repositories one tracer to start spans with, so the time a run
+// spends searching, fetching, mapping and writing can be inspected in Jaeger,
+// Tempo or any other OTLP backend.
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ExporterOTLP sends spans to a collector over OTLP/HTTP.
+const ExporterOTLP = "otlp"
+
+// instrumentation names the tracer spans are started with.
+const instrumentation = "github.com/mkoziy/genome/exporter"
+
+// Config selects where spans are exported. Tracing is off unless Exporter is
+// set.
+type Config struct {
+	// Exporter is ExporterOTLP or empty to disable tracing.
+	Exporter string `yaml:"exporter" json:"exporter"`
+	// Endpoint is the collector's host:port. When empty the
+	// OTEL_EXPORTER_OTLP_ENDPOINT environment variable or localhost:4318 is
+	// used.
+	Endpoint string `yaml:"endpoint" json:"endpoint,omitempty"`
+	// Insecure sends spans over plain HTTP.
+	Insecure    bool   `yaml:"insecure" json:"insecure"`
+	ServiceName string `yaml:"service_name" json:"service_name"`
+	// SampleRatio is the fraction of runs traced, from 0 to 1.
+	SampleRatio float64 `yaml:"sample_ratio" json:"sample_ratio"`
+}
+
+// DefaultConfig leaves tracing off; once enabled every run is traced.
+func DefaultConfig() Config {
+	return Config{ServiceName: "exporter", SampleRatio: 1}
+}
+
+// Validate reports every invalid setting at once, naming fields relative to c.
+func (c Config) Validate() error {
+	var errs []error
+	switch c.Exporter {
+	case "", ExporterOTLP:
+	default:
+		errs = append(errs, fmt.Errorf("exporter: unknown exporter %q (want %s or empty)", c.Exporter, ExporterOTLP))
+	}
+	if c.SampleRatio < 0 || c.SampleRatio > 1 {
+		errs = append(errs, errors.New("sample_ratio: must be between 0 and 1"))
+	}
+	return errors.Join(errs...)
+}
+
+// Setup installs a tracer provider exporting spans as cfg selects. The
+// returned function flushes buffered spans and must be called before the
+// process exits. With tracing off spans are not recorded and shutdown does
+// nothing.
+func Setup(ctx context.Context, cfg Config) (shutdown func(context.Context) error, err error) {
+	if cfg.Exporter == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+	var opts []otlptracehttp.Option
+	if cfg.Endpoint != "" {
+		opts = append(opts, otlptracehttp.WithEndpoint(cfg.Endpoint))
+	}
+	if cfg.Insecure {
+		opts = append(opts, otlptracehttp.WithInsecure())
+	}
+	exporter, err := otlptracehttp.New(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("create otlp exporter: %w", err)
+	}
+	res := resource.NewSchemaless(attribute.String("service.name", cfg.ServiceName))
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	return provider.Shutdown, nil
+}
+
+// Start starts a span named name as a child of the span in ctx, if any.
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentation).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// End marks span as failed if err is not nil, then ends it.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,53 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestStartAndEndRecordSpans(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(prev) })
+
+	ctx, parent := Start(context.Background(), "pipeline.run", attribute.String("run_id", "run-1"))
+	_, child := Start(ctx, "write.chunk")
+	End(child, errors.New("disk full"))
+	End(parent, nil)
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("expected 2 spans, got %d", len(spans))
+	}
+	chunk, run := spans[0], spans[1]
+	if chunk.Name() != "write.chunk" || chunk.Parent().SpanID() != run.SpanContext().SpanID() {
+		t.Fatalf("expected write.chunk as a child of pipeline.run, got %s under %s", chunk.Name(), chunk.Parent().SpanID())
+	}
+	if chunk.Status().Code != codes.Error || chunk.Status().Description != "disk full" || len(chunk.Events()) != 1 {
+		t.Fatalf("expected the error recorded, got %+v", chunk.Status())
+	}
+	if run.Status().Code != codes.Unset || len(run.Attributes()) != 1 {
+		t.Fatalf("unexpected run span: %+v %v", run.Status(), run.Attributes())
+	}
+}
+
+func TestSetupDisabled(t *testing.T) {
+	shutdown, err := Setup(context.Background(), DefaultConfig())
+	if err != nil {
+		t.Fatalf("setup: %v", err)
+	}
+	if err := shutdown(context.Background()); err != nil {
+		t.Fatalf("shutdown: %v", err)
+	}
+	if err := (Config{Exporter: "zipkin", SampleRatio: 2}).Validate(); err == nil {
+		t.Fatalf("expected invalid config")
+	}
+}
@@ -0,0 +1,200 @@
+package translate
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/uptrace/bun"
+
+	"github.com/mkoziy/genome/exporter/internal/models"
+)
+
+// The kinds of text a machine translation job may be limited to.
+const (
+	FieldSummary   = "summary"
+	FieldCondition = "condition"
+	FieldPhenotype = "phenotype"
+	FieldMessage   = "message"
+)
+
+// Fields lists the kinds of translatable text.
+var Fields = []string{FieldSummary, FieldCondition, FieldPhenotype, FieldMessage}
+
+// unitField returns the kind of text key names.
+func unitField(key string) (string, error) {
+	rsID, field, phenotypeID, err := parseKey(key)
+	switch {
+	case err != nil:
+		return "", err
+	case phenotypeID != 0:
+		return FieldPhenotype, nil
+	case rsID == "":
+		return FieldMessage, nil
+	case strings.HasPrefix(field, models.TranslationConditionField("")):
+		return FieldCondition, nil
+	}
+	return field, nil
+}
+
+// Job is the machine translation of the untranslated text of the SNPs into
+// one language. Identical texts, such as a condition many SNPs share, are
+// translated once.
+type Job struct {
+	Language string
+	// Units are the untranslated units, in SNP order.
+	Units []Unit
+	// Texts are the distinct texts to translate, glossary terms replaced by
+	// placeholders, in the order of their first unit.
+	Texts []string
+	// Characters counts the characters of Texts, as providers bill them.
+	Characters int
+
+	protected []*Protected
+	text      []int // index in Texts of the text of each unit
+}
+
+// Plan returns the job translating the text of the given kinds, all when
+// fields is empty, not yet translated into lang, reading batchSize SNPs at
+// a time.
+func Plan(ctx context.Context, db *bun.DB, lang string, fields []string, batchSize int) (*Job, error) {
+	wanted := make(map[string]bool, len(fields))
+	for _, f := range fields {
+		if !isField(f) {
+			return nil, fmt.Errorf("unknown field %q (want one of %s)", f, strings.Join(Fields, ", "))
+		}
+		wanted[f] = true
+	}
+	glossary, err := LoadGlossary(ctx, db, lang)
+	if err != nil {
+		return nil, err
+	}
+	units, err := Collect(ctx, db, lang, true, batchSize)
+	if err != nil {
+		return nil, err
+	}
+	job := &Job{Language: lang}
+	seen := make(map[string]int)
+	for _, u := range units {
+		field, err := unitField(u.Key)
+		if err != nil {
+			return nil, err
+		}
+		if len(wanted) > 0 && !wanted[field] || u.Source == "" {
+			continue
+		}
+		p := glossary.Protect(u.Source)
+		i, ok := seen[p.Text]
+		if !ok {
+			i = len(job.Texts)
+			seen[p.Text] = i
+			job.Texts = append(job.Texts, p.Text)
+			job.Characters += utf8.RuneCountInString(p.Text)
+		}
+		job.Units = append(job.Units, u)
+		job.protected = append(job.protected, p)
+		job.text = append(job.text, i)
+	}
+	return job, nil
+}
+
+func isField(f string) bool {
+	for _, field := range Fields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+// Estimate is what a job is expected to cost.
+type Estimate struct {
+	Language   string  `json:"language"`
+	Strings    int     `json:"strings"`
+	Texts      int     `json:"texts"`
+	Characters int     `json:"characters"`
+	Requests   int     `json:"requests"`
+	Cost       float64 `json:"cost"`
+}
+
+// Estimate returns the requests and cost of running the job with cfg.
+func (j *Job) Estimate(cfg MachineConfig) Estimate {
+	return Estimate{
+		Language:   j.Language,
+		Strings:    len(j.Units),
+		Texts:      len(j.Texts),
+		Characters: j.Characters,
+		Requests:   (len(j.Texts) + machineBatchSize(cfg) - 1) / machineBatchSize(cfg),
+		Cost:       cfg.Cost(j.Characters),
+	}
+}
+
+func machineBatchSize(cfg MachineConfig) int {
+	if cfg.BatchSize <= 0 {
+		return DefaultMachineBatchSize
+	}
+	return cfg.BatchSize
+}
+
+// RunStats counts what a job did.
+type RunStats struct {
+	Language string `json:"language"`
+	// Translated counts the texts the provider translated.
+	Translated int `json:"translated"`
+	ImportStats
+	// Failed lists the keys of units whose machine translation dropped a
+	// glossary term; they are left untranslated.
+	Failed []string `json:"failed,omitempty"`
+}
+
+// Run translates the job's texts with p, cfg.BatchSize texts per request,
+// and writes each batch's translations as machine drafts by
+// "machine:<provider>", so an interrupted run keeps what it paid for. Units
+// whose source changed since the job was planned are skipped as stale.
+func (j *Job) Run(ctx context.Context, db *bun.DB, p Provider, cfg MachineConfig) (*RunStats, error) {
+	stats := &RunStats{Language: j.Language}
+	translator := "machine:" + p.Name()
+	size := machineBatchSize(cfg)
+	targets := make([]string, len(j.Texts))
+	next := 0 // the first unit not yet written
+	for start := 0; start < len(j.Texts); start += size {
+		end := min(start+size, len(j.Texts))
+		translated, err := p.Translate(ctx, j.Texts[start:end], SourceLanguage, j.Language)
+		if err != nil {
+			return stats, fmt.Errorf("translate into %s: %w", j.Language, err)
+		}
+		stats.Translated += len(translated)
+		copy(targets[start:], translated)
+
+		// Texts are numbered in the order of their first unit, so the units
+		// up to the first with a text of a later batch are now translated.
+		var units []Unit
+		for ; next < len(j.Units) && j.text[next] < end; next++ {
+			u := j.Units[next]
+			target, err := j.protected[next].Restore(targets[j.text[next]])
+			if err != nil {
+				stats.Failed = append(stats.Failed, u.Key)
+				continue
+			}
+			u.Target, u.Status = target, models.TranslationMachineDraft
+			units = append(units, u)
+		}
+		imported, err := Import(ctx, db, j.Language, translator, units)
+		if err != nil {
+			return stats, err
+		}
+		stats.add(imported)
+	}
+	return stats, nil
+}
+
+func (s *RunStats) add(o *ImportStats) {
+	s.Imported += o.Imported
+	s.Confirmed += o.Confirmed
+	s.Unchanged += o.Unchanged
+	s.Untranslated += o.Untranslated
+	s.Stale = append(s.Stale, o.Stale...)
+	s.Violations = append(s.Violations, o.Violations...)
+	s.Malformed = append(s.Malformed, o.Malformed...)
+}
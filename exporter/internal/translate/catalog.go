@@ -0,0 +1,536 @@
+// Package translate moves the database's translatable text in and out of
+// the files translators work in: the English summaries, condition names and
+// phenotype names of the SNPs and the messages of scores, such as
+// significance levels, each with its translation into a language, written
+// as XLIFF or gettext PO for CAT tools and read back into snp_translations,
+// phenotype_translations and message_translations.
+package translate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/uptrace/bun"
+
+	"github.com/mkoziy/genome/exporter/internal/models"
+	"github.com/mkoziy/genome/exporter/internal/repositories"
+)
+
+// SourceLanguage is the language of the database's own text.
+const SourceLanguage = "en"
+
+// Unit is a translatable text with its translation.
+type Unit struct {
+	// Key identifies the text; see SNPKey, PhenotypeKey and MessageKey.
+	Key    string
+	Source string
+	Target string
+	// Status is the review status of Target: "" when there is none. In a
+	// file read back, machine_draft marks a translation the translator did
+	// not confirm and reviewed one they did.
+	Status models.TranslationStatus
+	// Note tells the translator where the text is shown.
+	Note string
+}
+
+// SNPKey returns the key of the translation of field of the SNP rsID, such
+// as "rs334/summary".
+func SNPKey(rsID, field string) string {
+	return rsID + "/" + field
+}
+
+// PhenotypeKey returns the key of the translation of the name of the
+// phenotype id, such as "phenotype/42".
+func PhenotypeKey(id int64) string {
+	return "phenotype/" + strconv.FormatInt(id, 10)
+}
+
+// MessageKey returns the key of the translation of the message key, such as
+// "message/level/very_high".
+func MessageKey(key string) string {
+	return "message/" + key
+}
+
+// parseKey splits a key into the SNP field, the phenotype or the message it
+// names. A message's key has no rsID; field is the message key.
+func parseKey(key string) (rsID, field string, phenotypeID int64, err error) {
+	if message, ok := strings.CutPrefix(key, "message/"); ok {
+		if message == "" {
+			return "", "", 0, fmt.Errorf("invalid key %q", key)
+		}
+		return "", message, 0, nil
+	}
+	if id, ok := strings.CutPrefix(key, "phenotype/"); ok {
+		phenotypeID, err = strconv.ParseInt(id, 10, 64)
+		if err != nil || phenotypeID <= 0 {
+			return "", "", 0, fmt.Errorf("invalid key %q", key)
+		}
+		return "", "", phenotypeID, nil
+	}
+	rsID, field, ok := strings.Cut(key, "/")
+	if !ok || rsID == "" || field == "" {
+		return "", "", 0, fmt.Errorf("invalid key %q", key)
+	}
+	return rsID, field, 0, nil
+}
+
+// Collect returns the units of the messages and every SNP for translation
+// into lang, in SNP order, reading batchSize SNPs at a time: those not yet translated and,
+// unless untranslatedOnly, those whose translation is not yet verified.
+// Each carries its current translation.
+func Collect(ctx context.Context, db *bun.DB, lang string, untranslatedOnly bool, batchSize int) ([]Unit, error) {
+	units := make([]Unit, 0)
+	err := walk(ctx, db, lang, batchSize, func(u Unit) error {
+		if u.Status == "" || !untranslatedOnly && u.Status != models.TranslationVerified {
+			units = append(units, u)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return units, nil
+}
+
+// walk calls fn with the unit of every message, then of every translatable
+// text of the SNPs, in SNP order, carrying its lang translation if there is
+// one.
+func walk(ctx context.Context, db *bun.DB, lang string, batchSize int, fn func(Unit) error) error {
+	if lang == SourceLanguage {
+		return fmt.Errorf("%s is the source language", lang)
+	}
+	var messages []*models.MessageTranslation
+	if err := db.NewSelect().Model(&messages).Where("mt.language_code = ?", lang).Scan(ctx); err != nil {
+		return err
+	}
+	current := make(map[string]*models.MessageTranslation, len(messages))
+	for _, row := range messages {
+		current[row.MessageKey] = row
+	}
+	for _, m := range models.Messages() {
+		u := Unit{Key: MessageKey(m.Key), Source: m.Text, Note: m.Note}
+		if row := current[m.Key]; row != nil {
+			u.Target, u.Status = row.TranslatedText, row.Status
+		}
+		if err := fn(u); err != nil {
+			return err
+		}
+	}
+	return repositories.ForEachSNP(ctx, db, batchSize, func(batch []*models.SNP) error {
+		fields, names, err := loadTranslations(ctx, db, lang, batch)
+		if err != nil {
+			return err
+		}
+		// Summaries are written as translations into the source language.
+		sources, _, err := loadTranslations(ctx, db, SourceLanguage, batch)
+		if err != nil {
+			return err
+		}
+		add := func(u Unit, current *models.Translation) error {
+			if current != nil {
+				u.Target, u.Status = current.TranslatedText, current.Status
+			}
+			return fn(u)
+		}
+		for _, snp := range batch {
+			summary := SNPKey(snp.RsID, models.TranslationSummary)
+			if source := sources[summary]; source != nil && source.TranslatedText != "" {
+				if err := add(Unit{Key: summary, Source: source.TranslatedText, Note: "Plain-language summary of " + snp.RsID}, fields[summary]); err != nil {
+					return err
+				}
+			}
+			var conditions []string
+			for _, cd := range snp.ClinicalData {
+				conditions = append(conditions, cd.ConditionName)
+			}
+			for _, risk := range snp.RiskAlleles {
+				conditions = append(conditions, risk.ConditionName)
+			}
+			seen := make(map[string]bool)
+			for _, name := range conditions {
+				key := SNPKey(snp.RsID, models.TranslationConditionField(name))
+				if name == "" || seen[key] {
+					continue
+				}
+				seen[key] = true
+				if err := add(Unit{Key: key, Source: name, Note: "Condition " + snp.RsID + " is associated with"}, fields[key]); err != nil {
+					return err
+				}
+			}
+			for _, p := range snp.Phenotypes {
+				var current *models.Translation
+				if name := names[p.ID]; name != nil {
+					current = &models.Translation{TranslatedText: name.TranslatedName, Status: name.Status}
+				}
+				if err := add(Unit{Key: PhenotypeKey(p.ID), Source: p.PhenotypeName, Note: "Phenotype associated with " + snp.RsID}, current); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// loadTranslations returns the lang translations of the batch's fields by
+// key and of its phenotypes' names by phenotype ID.
+func loadTranslations(ctx context.Context, db bun.IDB, lang string, batch []*models.SNP) (map[string]*models.Translation, map[int64]*models.PhenotypeTranslation, error) {
+	rsIDs := make(map[int64]string, len(batch))
+	var snpIDs, phenotypeIDs []int64
+	for _, snp := range batch {
+		rsIDs[snp.ID] = snp.RsID
+		snpIDs = append(snpIDs, snp.ID)
+		for _, p := range snp.Phenotypes {
+			phenotypeIDs = append(phenotypeIDs, p.ID)
+		}
+	}
+	fields := make(map[string]*models.Translation)
+	names := make(map[int64]*models.PhenotypeTranslation)
+	if len(snpIDs) == 0 {
+		return fields, names, nil
+	}
+
+	var rows []*models.Translation
+	err := db.NewSelect().
+		Model(&rows).
+		Where("t.language_code = ?", lang).
+		Where("t.snp_id IN (?)", bun.In(snpIDs)).
+		Scan(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, row := range rows {
+		fields[SNPKey(rsIDs[row.SNPID], row.FieldName)] = row
+	}
+	if len(phenotypeIDs) == 0 {
+		return fields, names, nil
+	}
+	var nameRows []*models.PhenotypeTranslation
+	err = db.NewSelect().
+		Model(&nameRows).
+		Where("pt.language_code = ?", lang).
+		Where("pt.phenotype_id IN (?)", bun.In(phenotypeIDs)).
+		Scan(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, row := range nameRows {
+		names[row.PhenotypeID] = row
+	}
+	return fields, names, nil
+}
+
+// ImportStats counts what Import did with the units of a file.
+type ImportStats struct {
+	// Imported counts the translations written or changed.
+	Imported int `json:"imported"`
+	// Confirmed counts the unchanged machine drafts the translator
+	// confirmed, now reviewed.
+	Confirmed int `json:"confirmed"`
+	Unchanged int `json:"unchanged"`
+	// Untranslated counts the units left without a target.
+	Untranslated int `json:"untranslated"`
+	// Stale lists the keys of units whose source text changed since the
+	// file was written, or which no longer exist; they are not imported.
+	Stale []string `json:"stale,omitempty"`
+	// Violations lists the glossary terms translations lack; those are
+	// imported as machine drafts, however the translator marked them.
+	Violations []Violation `json:"violations,omitempty"`
+	// Malformed lists the keys of message translations that lack a
+	// placeholder of their source, such as {1}; they are not imported.
+	Malformed []string `json:"malformed,omitempty"`
+}
+
+// Import writes the translations of units into lang on behalf of
+// translator, in one transaction. A translation that differs from the
+// current one replaces it as a machine draft, or as reviewed by translator
+// if they confirmed it and it keeps to the glossary of lang; verification
+// stays a separate step. Units whose source text no longer matches the
+// database are skipped as stale.
+func Import(ctx context.Context, db *bun.DB, lang, translator string, units []Unit) (*ImportStats, error) {
+	if lang == "" || translator == "" {
+		return nil, errors.New("import needs a language and a translator")
+	} else if lang == SourceLanguage {
+		return nil, fmt.Errorf("%s is the source language", lang)
+	}
+	stats := &ImportStats{}
+	err := db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		glossary, err := LoadGlossary(ctx, tx, lang)
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		for _, u := range units {
+			if u.Target == "" {
+				stats.Untranslated++
+				continue
+			}
+			rsID, field, phenotypeID, err := parseKey(u.Key)
+			if err != nil {
+				return err
+			}
+			if rsID == "" && phenotypeID == 0 && !keepsPlaceholders(u.Source, u.Target) {
+				stats.Malformed = append(stats.Malformed, u.Key)
+				continue
+			}
+			if violations := glossary.Check(u.Source, u.Target); len(violations) > 0 {
+				for _, v := range violations {
+					v.Key = u.Key
+					stats.Violations = append(stats.Violations, v)
+				}
+				u.Status = models.TranslationMachineDraft
+			}
+			var outcome importOutcome
+			switch {
+			case phenotypeID != 0:
+				outcome, err = importPhenotypeName(ctx, tx, lang, translator, phenotypeID, u, now)
+			case rsID == "":
+				outcome, err = importMessage(ctx, tx, lang, translator, field, u, now)
+			default:
+				outcome, err = importSNPField(ctx, tx, lang, translator, rsID, field, u, now)
+			}
+			if err != nil {
+				return fmt.Errorf("import %s: %w", u.Key, err)
+			}
+			switch outcome {
+			case outcomeImported:
+				stats.Imported++
+			case outcomeConfirmed:
+				stats.Confirmed++
+			case outcomeUnchanged:
+				stats.Unchanged++
+			case outcomeStale:
+				stats.Stale = append(stats.Stale, u.Key)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+type importOutcome int
+
+const (
+	outcomeImported importOutcome = iota
+	outcomeConfirmed
+	outcomeUnchanged
+	outcomeStale
+)
+
+// decide compares the unit with the current translation, whose status is ""
+// if there is none, and advances the review of row, the translation to
+// write, if the translator confirmed it.
+func decide(u Unit, currentText string, currentStatus models.TranslationStatus, translator string, now time.Time, row interface {
+	Advance(models.TranslationStatus, string, time.Time) error
+}) (importOutcome, error) {
+	confirmed := u.Status == models.TranslationReviewed || u.Status == models.TranslationVerified
+	if currentStatus != "" && currentText == u.Target {
+		if !confirmed || currentStatus != models.TranslationMachineDraft {
+			return outcomeUnchanged, nil
+		}
+		return outcomeConfirmed, row.Advance(models.TranslationReviewed, translator, now)
+	}
+	if confirmed {
+		return outcomeImported, row.Advance(models.TranslationReviewed, translator, now)
+	}
+	return outcomeImported, nil
+}
+
+func importSNPField(ctx context.Context, tx bun.Tx, lang, translator, rsID, field string, u Unit, now time.Time) (importOutcome, error) {
+	snp := &models.SNP{}
+	err := tx.NewSelect().Model(snp).Where("s.rsid = ?", rsID).Scan(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return outcomeStale, nil
+	} else if err != nil {
+		return 0, err
+	}
+	source, ok, err := snpFieldSource(ctx, tx, snp, field)
+	if err != nil {
+		return 0, err
+	} else if !ok || source != u.Source {
+		return outcomeStale, nil
+	}
+
+	current, err := fieldTranslation(ctx, tx, snp.ID, lang, field)
+	if err != nil {
+		return 0, err
+	}
+
+	row := &models.Translation{
+		ID:             current.ID,
+		SNPID:          snp.ID,
+		LanguageCode:   lang,
+		FieldName:      field,
+		TranslatedText: u.Target,
+		Translator:     &translator,
+		TranslatedAt:   now.UTC(),
+		Status:         models.TranslationMachineDraft,
+	}
+	if current.Status != "" && current.TranslatedText == u.Target {
+		row = &current
+	}
+	outcome, err := decide(u, current.TranslatedText, current.Status, translator, now, row)
+	if err != nil || outcome == outcomeUnchanged {
+		return outcome, err
+	}
+	if current.ID == 0 {
+		_, err = tx.NewInsert().Model(row).Exec(ctx)
+		return outcome, err
+	}
+	_, err = tx.NewUpdate().
+		Model(row).
+		Column("translated_text", "translator", "translated_at", "verified", "status", "reviewer", "reviewed_at", "verified_at", "updated_at").
+		WherePK().
+		Exec(ctx)
+	return outcome, err
+}
+
+// fieldTranslation returns the lang translation of field of the SNP, zero if
+// there is none.
+func fieldTranslation(ctx context.Context, tx bun.Tx, snpID int64, lang, field string) (models.Translation, error) {
+	var row models.Translation
+	err := tx.NewSelect().
+		Model(&row).
+		Where("t.snp_id = ?", snpID).
+		Where("t.language_code = ?", lang).
+		Where("t.field_name = ?", field).
+		Scan(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Translation{}, nil
+	}
+	return row, err
+}
+
+// snpFieldSource returns the source text of field of snp, and whether the
+// SNP still has the field.
+func snpFieldSource(ctx context.Context, tx bun.Tx, snp *models.SNP, field string) (string, bool, error) {
+	if field == models.TranslationSummary {
+		summary, err := fieldTranslation(ctx, tx, snp.ID, SourceLanguage, field)
+		return summary.TranslatedText, summary.TranslatedText != "", err
+	}
+	name, ok := strings.CutPrefix(field, models.TranslationConditionField(""))
+	if !ok {
+		return "", false, nil
+	}
+	var named bool
+	err := tx.NewRaw(
+		"SELECT EXISTS (SELECT 1 FROM snp_clinical WHERE snp_id = ? AND condition_name = ? AND deleted_at IS NULL) OR EXISTS (SELECT 1 FROM risk_alleles WHERE snp_id = ? AND condition_name = ? AND deleted_at IS NULL)",
+		snp.ID, name, snp.ID, name,
+	).Scan(ctx, &named)
+	return name, named, err
+}
+
+func importPhenotypeName(ctx context.Context, tx bun.Tx, lang, translator string, id int64, u Unit, now time.Time) (importOutcome, error) {
+	phenotype := &models.Phenotype{ID: id}
+	err := tx.NewSelect().Model(phenotype).WherePK().Scan(ctx)
+	if errors.Is(err, sql.ErrNoRows) || err == nil && phenotype.PhenotypeName != u.Source {
+		return outcomeStale, nil
+	} else if err != nil {
+		return 0, err
+	}
+
+	var current models.PhenotypeTranslation
+	err = tx.NewSelect().
+		Model(&current).
+		Where("pt.phenotype_id = ?", id).
+		Where("pt.language_code = ?", lang).
+		Scan(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		current = models.PhenotypeTranslation{}
+	} else if err != nil {
+		return 0, err
+	}
+
+	row := &models.PhenotypeTranslation{
+		ID:             current.ID,
+		PhenotypeID:    id,
+		LanguageCode:   lang,
+		TranslatedName: u.Target,
+		Translator:     &translator,
+		TranslatedAt:   now.UTC(),
+		Status:         models.TranslationMachineDraft,
+	}
+	if current.Status != "" && current.TranslatedName == u.Target {
+		row = &current
+	}
+	outcome, err := decide(u, current.TranslatedName, current.Status, translator, now, row)
+	if err != nil || outcome == outcomeUnchanged {
+		return outcome, err
+	}
+	if current.ID == 0 {
+		_, err = tx.NewInsert().Model(row).Exec(ctx)
+		return outcome, err
+	}
+	_, err = tx.NewUpdate().
+		Model(row).
+		Column("translated_name", "translator", "translated_at", "verified", "status", "reviewer", "reviewed_at", "verified_at").
+		WherePK().
+		Exec(ctx)
+	return outcome, err
+}
+
+// placeholderPattern matches the placeholders of a message, such as {1}.
+var placeholderPattern = regexp.MustCompile(`\{[0-9]+\}`)
+
+// keepsPlaceholders reports whether target has every placeholder of source.
+func keepsPlaceholders(source, target string) bool {
+	for _, ph := range placeholderPattern.FindAllString(source, -1) {
+		if !strings.Contains(target, ph) {
+			return false
+		}
+	}
+	return true
+}
+
+func importMessage(ctx context.Context, tx bun.Tx, lang, translator, key string, u Unit, now time.Time) (importOutcome, error) {
+	if m, ok := models.LookupMessage(key); !ok || m.Text != u.Source {
+		return outcomeStale, nil
+	}
+
+	var current models.MessageTranslation
+	err := tx.NewSelect().
+		Model(&current).
+		Where("mt.message_key = ?", key).
+		Where("mt.language_code = ?", lang).
+		Scan(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		current = models.MessageTranslation{}
+	} else if err != nil {
+		return 0, err
+	}
+
+	row := &models.MessageTranslation{
+		ID:             current.ID,
+		MessageKey:     key,
+		LanguageCode:   lang,
+		TranslatedText: u.Target,
+		Translator:     &translator,
+		TranslatedAt:   now.UTC(),
+		Status:         models.TranslationMachineDraft,
+	}
+	if current.Status != "" && current.TranslatedText == u.Target {
+		row = &current
+	}
+	outcome, err := decide(u, current.TranslatedText, current.Status, translator, now, row)
+	if err != nil || outcome == outcomeUnchanged {
+		return outcome, err
+	}
+	if current.ID == 0 {
+		_, err = tx.NewInsert().Model(row).Exec(ctx)
+		return outcome, err
+	}
+	_, err = tx.NewUpdate().
+		Model(row).
+		Column("translated_text", "translator", "translated_at", "verified", "status", "reviewer", "reviewed_at", "verified_at").
+		WherePK().
+		Exec(ctx)
+	return outcome, err
+}
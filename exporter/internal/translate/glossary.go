@@ -0,0 +1,210 @@
+package translate
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/uptrace/bun"
+
+	"github.com/mkoziy/genome/exporter/internal/models"
+	"github.com/mkoziy/genome/exporter/internal/repositories"
+)
+
+// Glossary is the glossary of one language, matching its terms in source
+// text as whole words, longest first, so "likely pathogenic" is matched
+// rather than the "pathogenic" in it.
+type Glossary struct {
+	Language string
+	terms    []glossaryTerm
+}
+
+type glossaryTerm struct {
+	*models.GlossaryTerm
+	pattern *regexp.Regexp
+}
+
+// NewGlossary returns the glossary of lang made of terms; terms of other
+// languages are ignored.
+func NewGlossary(lang string, terms []*models.GlossaryTerm) *Glossary {
+	g := &Glossary{Language: lang}
+	for _, t := range terms {
+		if t.LanguageCode != lang {
+			continue
+		}
+		g.terms = append(g.terms, glossaryTerm{t, termPattern(t.Term, t.CaseSensitive)})
+	}
+	sort.SliceStable(g.terms, func(i, j int) bool {
+		return utf8.RuneCountInString(g.terms[i].Term) > utf8.RuneCountInString(g.terms[j].Term)
+	})
+	return g
+}
+
+// LoadGlossary returns the glossary of lang stored in db.
+func LoadGlossary(ctx context.Context, db bun.IDB, lang string) (*Glossary, error) {
+	terms, err := repositories.ListGlossaryTerms(ctx, db, lang)
+	if err != nil {
+		return nil, err
+	}
+	return NewGlossary(lang, terms), nil
+}
+
+func termPattern(s string, caseSensitive bool) *regexp.Regexp {
+	expr := regexp.QuoteMeta(s)
+	if !caseSensitive {
+		expr = "(?i)" + expr
+	}
+	return regexp.MustCompile(expr)
+}
+
+// Len returns the number of terms of the glossary.
+func (g *Glossary) Len() int {
+	return len(g.terms)
+}
+
+// match is an occurrence of a term in a text.
+type match struct {
+	start, end int
+	term       *models.GlossaryTerm
+}
+
+// matches returns the occurrences of the glossary's terms in text as whole
+// words, in text order, a longer term winning over the shorter ones within
+// it.
+func (g *Glossary) matches(text string) []match {
+	var found []match
+	overlaps := func(start, end int) bool {
+		for _, m := range found {
+			if start < m.end && m.start < end {
+				return true
+			}
+		}
+		return false
+	}
+	for _, t := range g.terms {
+		for _, loc := range t.pattern.FindAllStringIndex(text, -1) {
+			if isWord(text, loc[0], loc[1]) && !overlaps(loc[0], loc[1]) {
+				found = append(found, match{loc[0], loc[1], t.GlossaryTerm})
+			}
+		}
+	}
+	sort.Slice(found, func(i, j int) bool { return found[i].start < found[j].start })
+	return found
+}
+
+// isWord reports whether text[start:end] is not part of a longer word:
+// neither side touches a letter or digit.
+func isWord(text string, start, end int) bool {
+	before, _ := utf8.DecodeLastRuneInString(text[:start])
+	after, _ := utf8.DecodeRuneInString(text[end:])
+	wordy := func(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) }
+	return (start == 0 || !wordy(before)) && (end == len(text) || !wordy(after))
+}
+
+// Violation is a glossary term in a source text whose translation lacks the
+// term's fixed translation.
+type Violation struct {
+	Key         string                   `json:"key,omitempty"`
+	Status      models.TranslationStatus `json:"status,omitempty"`
+	Term        string                   `json:"term"`
+	Translation string                   `json:"translation"`
+	Target      string                   `json:"target"`
+}
+
+// Check returns a violation for each term of the glossary in source whose
+// translation target does not contain. Translations are looked for as text
+// rather than as words, so inflected forms such as German "pathogene" of
+// "pathogen" pass.
+func (g *Glossary) Check(source, target string) []Violation {
+	var violations []Violation
+	seen := make(map[*models.GlossaryTerm]bool)
+	for _, m := range g.matches(source) {
+		if seen[m.term] {
+			continue
+		}
+		seen[m.term] = true
+		want := m.term.Translation
+		ok := strings.Contains(target, want)
+		if !m.term.CaseSensitive {
+			ok = strings.Contains(strings.ToLower(target), strings.ToLower(want))
+		}
+		if !ok {
+			violations = append(violations, Violation{Term: m.term.Term, Translation: want, Target: target})
+		}
+	}
+	return violations
+}
+
+// placeholder returns the placeholder of the i-th protected term. Machine
+// translation services pass such bracketed tokens through untranslated.
+func placeholder(i int) string {
+	return "⟦" + strconv.Itoa(i) + "⟧"
+}
+
+// Protected is a text prepared for machine translation, its glossary terms
+// replaced by placeholders so the service cannot mistranslate them.
+type Protected struct {
+	Text  string
+	terms []*models.GlossaryTerm
+}
+
+// Protect replaces the glossary terms in text by placeholders; Restore
+// puts their fixed translations in the machine translation of the result.
+func (g *Glossary) Protect(text string) *Protected {
+	p := &Protected{}
+	var b strings.Builder
+	last := 0
+	for i, m := range g.matches(text) {
+		b.WriteString(text[last:m.start])
+		b.WriteString(placeholder(i))
+		p.terms = append(p.terms, m.term)
+		last = m.end
+	}
+	b.WriteString(text[last:])
+	p.Text = b.String()
+	return p
+}
+
+// Restore replaces the placeholders in translated, the machine translation
+// of p.Text, by the fixed translations of the terms they stand for. It
+// fails if the service dropped a placeholder, leaving a term untranslated.
+func (p *Protected) Restore(translated string) (string, error) {
+	for i, term := range p.terms {
+		ph := placeholder(i)
+		if !strings.Contains(translated, ph) {
+			return "", fmt.Errorf("machine translation dropped glossary term %q", term.Term)
+		}
+		translated = strings.ReplaceAll(translated, ph, term.Translation)
+	}
+	return translated, nil
+}
+
+// CheckTranslations checks the lang translations of every SNP against the
+// glossary of lang, reading batchSize SNPs at a time, and returns the
+// violations found, keyed like units.
+func CheckTranslations(ctx context.Context, db *bun.DB, lang string, batchSize int) ([]Violation, error) {
+	violations := make([]Violation, 0)
+	g, err := LoadGlossary(ctx, db, lang)
+	if err != nil || g.Len() == 0 {
+		return violations, err
+	}
+	err = walk(ctx, db, lang, batchSize, func(u Unit) error {
+		if u.Target == "" {
+			return nil
+		}
+		for _, v := range g.Check(u.Source, u.Target) {
+			v.Key, v.Status = u.Key, u.Status
+			violations = append(violations, v)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return violations, nil
+}
@@ -0,0 +1,264 @@
+package translate
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/mkoziy/genome/exporter/internal/ratelimit"
+)
+
+// Machine translation providers.
+const (
+	ProviderDeepL  = "deepl"
+	ProviderGoogle = "google"
+)
+
+// Providers lists the machine translation providers supported.
+var Providers = []string{ProviderDeepL, ProviderGoogle}
+
+// Defaults of MachineConfig.
+const (
+	DefaultMachineBatchSize = 50
+	DefaultMachineTimeout   = time.Minute
+)
+
+// providerDefaults are the API endpoints and list prices, in US dollars per
+// million characters, of the providers.
+var providerDefaults = map[string]struct {
+	url  string
+	cost float64
+}{
+	ProviderDeepL:  {"https://api.deepl.com", 25},
+	ProviderGoogle: {"https://translation.googleapis.com", 20},
+}
+
+// MachineConfig selects and configures the machine translation provider.
+type MachineConfig struct {
+	Provider string `yaml:"provider" json:"provider,omitempty"`
+	// URL overrides the provider's API endpoint, e.g. with
+	// https://api-free.deepl.com for DeepL's free plan.
+	URL    string `yaml:"url" json:"url,omitempty"`
+	APIKey string `yaml:"api_key" json:"-"`
+	// BatchSize is the number of texts sent per request.
+	BatchSize int `yaml:"batch_size" json:"batch_size"`
+	// CostPerMillionCharacters estimates the cost of a translation run;
+	// it defaults to the provider's list price.
+	CostPerMillionCharacters float64          `yaml:"cost_per_million_characters" json:"cost_per_million_characters,omitempty"`
+	RateLimit                ratelimit.Config `yaml:"rate_limit" json:"rate_limit"`
+	Timeout                  time.Duration    `yaml:"timeout" json:"timeout"`
+}
+
+// Validate reports every invalid setting at once, naming fields relative to c.
+func (c MachineConfig) Validate() error {
+	var errs []error
+	if _, ok := providerDefaults[c.Provider]; c.Provider != "" && !ok {
+		errs = append(errs, fmt.Errorf("provider: unknown provider %q (want one of %s)", c.Provider, strings.Join(Providers, ", ")))
+	}
+	if c.URL != "" {
+		if u, err := url.Parse(c.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("url: want an http or https URL, got %q", c.URL))
+		}
+	}
+	switch c.RateLimit.Strategy {
+	case "", ratelimit.StrategyTokenBucket, ratelimit.StrategyFixedWindow, ratelimit.StrategyFixedDelay:
+	default:
+		errs = append(errs, fmt.Errorf("rate_limit.strategy: unknown strategy %q", c.RateLimit.Strategy))
+	}
+	if c.BatchSize < 0 {
+		errs = append(errs, errors.New("batch_size: must not be negative"))
+	}
+	if c.CostPerMillionCharacters < 0 {
+		errs = append(errs, errors.New("cost_per_million_characters: must not be negative"))
+	}
+	if c.Timeout < 0 {
+		errs = append(errs, errors.New("timeout: must not be negative"))
+	}
+	return errors.Join(errs...)
+}
+
+// Cost returns the estimated cost of translating chars characters.
+func (c MachineConfig) Cost(chars int) float64 {
+	rate := c.CostPerMillionCharacters
+	if rate == 0 {
+		rate = providerDefaults[c.Provider].cost
+	}
+	return float64(chars) * rate / 1e6
+}
+
+// Provider machine-translates texts.
+type Provider interface {
+	// Name identifies the provider; translations are recorded as by
+	// "machine:<name>".
+	Name() string
+	// Translate returns the translations of texts from source into target,
+	// in order.
+	Translate(ctx context.Context, texts []string, source, target string) ([]string, error)
+}
+
+// NewProvider returns the provider cfg selects, waiting on limiter before
+// each request.
+func NewProvider(cfg MachineConfig, limiter ratelimit.Limiter) (Provider, error) {
+	defaults, ok := providerDefaults[cfg.Provider]
+	if !ok {
+		return nil, fmt.Errorf("no machine translation provider configured (want one of %s)", strings.Join(Providers, ", "))
+	}
+	if cfg.APIKey == "" {
+		return nil, fmt.Errorf("%s: api_key is not configured", cfg.Provider)
+	}
+	if cfg.URL == "" {
+		cfg.URL = defaults.url
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = DefaultMachineTimeout
+	}
+	c := &apiClient{
+		cfg:     cfg,
+		client:  &http.Client{Timeout: cfg.Timeout},
+		limiter: limiter,
+		retries: cfg.RateLimit.WithDefaults().MaxRetries,
+	}
+	if cfg.Provider == ProviderGoogle {
+		return &google{c}, nil
+	}
+	return &deepl{c}, nil
+}
+
+// apiClient posts JSON to a provider's API, retrying rate-limited and
+// failed requests as the limiter's backoff allows.
+type apiClient struct {
+	cfg     MachineConfig
+	client  *http.Client
+	limiter ratelimit.Limiter
+	retries int
+}
+
+// statusError is an unexpected HTTP status of a provider.
+type statusError struct {
+	status int
+	body   string
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("unexpected status %d: %s", e.status, e.body)
+}
+
+func (e *statusError) retryable() bool {
+	return e.status == http.StatusTooManyRequests || e.status >= 500
+}
+
+func (c *apiClient) post(ctx context.Context, path string, header http.Header, body, out any) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	for attempt := 0; ; attempt++ {
+		if err := c.limiter.Wait(ctx); err != nil {
+			return err
+		}
+		err := c.do(ctx, path, header, data, out)
+		var status *statusError
+		if err == nil || !errors.As(err, &status) || !status.retryable() || attempt >= c.retries {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(c.limiter.RetryAfter(attempt + 1)):
+		}
+	}
+}
+
+func (c *apiClient) do(ctx context.Context, path string, header http.Header, data []byte, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimRight(c.cfg.URL, "/")+path, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header = header.Clone()
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("execute request: %w", err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return &statusError{resp.StatusCode, strings.TrimSpace(string(body))}
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("decode response: %w", err)
+	}
+	return nil
+}
+
+// deepl translates with the DeepL API.
+type deepl struct{ *apiClient }
+
+func (d *deepl) Name() string { return ProviderDeepL }
+
+func (d *deepl) Translate(ctx context.Context, texts []string, source, target string) ([]string, error) {
+	header := http.Header{"Authorization": {"DeepL-Auth-Key " + d.cfg.APIKey}}
+	body := map[string]any{
+		"text":        texts,
+		"source_lang": strings.ToUpper(source),
+		"target_lang": strings.ToUpper(target),
+	}
+	var resp struct {
+		Translations []struct {
+			Text string `json:"text"`
+		} `json:"translations"`
+	}
+	if err := d.post(ctx, "/v2/translate", header, body, &resp); err != nil {
+		return nil, err
+	}
+	if len(resp.Translations) != len(texts) {
+		return nil, fmt.Errorf("deepl returned %d translations of %d texts", len(resp.Translations), len(texts))
+	}
+	out := make([]string, len(texts))
+	for i, t := range resp.Translations {
+		out[i] = t.Text
+	}
+	return out, nil
+}
+
+// google translates with the Cloud Translation API (v2).
+type google struct{ *apiClient }
+
+func (g *google) Name() string { return ProviderGoogle }
+
+func (g *google) Translate(ctx context.Context, texts []string, source, target string) ([]string, error) {
+	header := http.Header{"X-Goog-Api-Key": {g.cfg.APIKey}}
+	body := map[string]any{
+		"q":      texts,
+		"source": source,
+		"target": target,
+		"format": "text",
+	}
+	var resp struct {
+		Data struct {
+			Translations []struct {
+				TranslatedText string `json:"translatedText"`
+			} `json:"translations"`
+		} `json:"data"`
+	}
+	if err := g.post(ctx, "/language/translate/v2", header, body, &resp); err != nil {
+		return nil, err
+	}
+	if len(resp.Data.Translations) != len(texts) {
+		return nil, fmt.Errorf("google returned %d translations of %d texts", len(resp.Data.Translations), len(texts))
+	}
+	out := make([]string, len(texts))
+	for i, t := range resp.Data.Translations {
+		out[i] = t.TranslatedText
+	}
+	return out, nil
+}
@@ -0,0 +1,191 @@
+package translate
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/mkoziy/genome/exporter/internal/models"
+)
+
+// weblate syncs with a gettext component of a Weblate project, keyed by
+// msgctxt. The component must allow adding new strings.
+type weblate struct{ platformClient }
+
+func (w *weblate) Name() string { return PlatformWeblate }
+
+func (w *weblate) languageCode(lang string) string {
+	if code, ok := w.cfg.LanguageCodes[lang]; ok {
+		return code
+	}
+	return strings.ReplaceAll(lang, "-", "_")
+}
+
+func (w *weblate) filePath(lang string) string {
+	return fmt.Sprintf("/api/translations/%s/%s/%s/file/",
+		url.PathEscape(w.cfg.Project), url.PathEscape(w.cfg.Component), url.PathEscape(w.languageCode(lang)))
+}
+
+// Push uploads each catalog to the component's translation into its
+// language, adding the strings it lacks with their translations, drafts as
+// needing editing. A translation the component lacks is started first.
+func (w *weblate) Push(ctx context.Context, catalogs []Catalog) (int, error) {
+	pushed := 0
+	for _, cat := range catalogs {
+		n, err := w.upload(ctx, cat)
+		if isNotFound(err) {
+			start := map[string]string{"language_code": w.languageCode(cat.Language)}
+			path := fmt.Sprintf("/api/components/%s/%s/translations/", url.PathEscape(w.cfg.Project), url.PathEscape(w.cfg.Component))
+			if err := w.do(ctx, http.MethodPost, path, nil, start, nil); err != nil {
+				return pushed, fmt.Errorf("start %s translation: %w", cat.Language, err)
+			}
+			n, err = w.upload(ctx, cat)
+		}
+		if err != nil {
+			return pushed, fmt.Errorf("upload %s: %w", cat.Language, err)
+		}
+		pushed += n
+	}
+	return pushed, nil
+}
+
+func (w *weblate) upload(ctx context.Context, cat Catalog) (int, error) {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	if err := form.WriteField("method", "add"); err != nil {
+		return 0, err
+	}
+	file, err := form.CreateFormFile("file", "genome-"+cat.Language+".po")
+	if err != nil {
+		return 0, err
+	}
+	if err := WritePO(file, cat.Language, cat.Units); err != nil {
+		return 0, err
+	}
+	if err := form.Close(); err != nil {
+		return 0, err
+	}
+	var resp struct {
+		Accepted int `json:"accepted"`
+	}
+	header := http.Header{"Content-Type": {form.FormDataContentType()}}
+	err = w.do(ctx, http.MethodPost, w.filePath(cat.Language), header, &body, &resp)
+	return resp.Accepted, err
+}
+
+// Pull downloads the component's translation into lang as PO; strings
+// needing editing come back as machine drafts.
+func (w *weblate) Pull(ctx context.Context, lang string) ([]Unit, error) {
+	units, err := w.download(ctx, strings.TrimRight(w.cfg.URL, "/")+w.filePath(lang)+"?format=po")
+	if isNotFound(err) {
+		return nil, nil
+	}
+	return units, err
+}
+
+// crowdin syncs with a gettext source file of a Crowdin project, keyed by
+// msgctxt. Only approved translations are pulled.
+type crowdin struct{ platformClient }
+
+func (c *crowdin) Name() string { return PlatformCrowdin }
+
+// Push uploads the strings of the catalogs as the source file, replacing
+// the previous one while keeping the translations of unchanged strings.
+func (c *crowdin) Push(ctx context.Context, catalogs []Catalog) (int, error) {
+	if len(catalogs) == 0 {
+		return 0, nil
+	}
+	// Every catalog holds the same strings; the source file has them
+	// untranslated.
+	sources := make([]Unit, len(catalogs[0].Units))
+	for i, u := range catalogs[0].Units {
+		sources[i] = Unit{Key: u.Key, Source: u.Source, Note: u.Note}
+	}
+	var pot bytes.Buffer
+	if err := WritePO(&pot, "", sources); err != nil {
+		return 0, err
+	}
+
+	var storage struct {
+		Data struct {
+			ID int64 `json:"id"`
+		} `json:"data"`
+	}
+	header := http.Header{"Content-Type": {"application/octet-stream"}, "Crowdin-Api-Filename": {c.cfg.Component}}
+	if err := c.do(ctx, http.MethodPost, "/storages", header, &pot, &storage); err != nil {
+		return 0, fmt.Errorf("upload source file: %w", err)
+	}
+
+	fileID, err := c.fileID(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if fileID == 0 {
+		body := map[string]any{"storageId": storage.Data.ID, "name": c.cfg.Component, "type": "gettext"}
+		err = c.do(ctx, http.MethodPost, "/projects/"+url.PathEscape(c.cfg.Project)+"/files", nil, body, nil)
+	} else {
+		body := map[string]any{"storageId": storage.Data.ID, "updateOption": "keep_translations"}
+		err = c.do(ctx, http.MethodPut, "/projects/"+url.PathEscape(c.cfg.Project)+"/files/"+strconv.FormatInt(fileID, 10), nil, body, nil)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("update source file: %w", err)
+	}
+	return len(sources), nil
+}
+
+// fileID returns the ID of the project's source file, 0 if it has none yet.
+func (c *crowdin) fileID(ctx context.Context) (int64, error) {
+	var files struct {
+		Data []struct {
+			Data struct {
+				ID   int64  `json:"id"`
+				Name string `json:"name"`
+			} `json:"data"`
+		} `json:"data"`
+	}
+	query := url.Values{"filter": {c.cfg.Component}, "limit": {"500"}}
+	if err := c.do(ctx, http.MethodGet, "/projects/"+url.PathEscape(c.cfg.Project)+"/files?"+query.Encode(), nil, nil, &files); err != nil {
+		return 0, fmt.Errorf("list files: %w", err)
+	}
+	for _, f := range files.Data {
+		if f.Data.Name == c.cfg.Component {
+			return f.Data.ID, nil
+		}
+	}
+	return 0, nil
+}
+
+// Pull builds the source file's approved translations into lang and
+// downloads them.
+func (c *crowdin) Pull(ctx context.Context, lang string) ([]Unit, error) {
+	fileID, err := c.fileID(ctx)
+	if err != nil || fileID == 0 {
+		return nil, err
+	}
+	var build struct {
+		Data struct {
+			URL string `json:"url"`
+		} `json:"data"`
+	}
+	body := map[string]any{"targetLanguageId": c.languageCode(lang), "exportApprovedOnly": true}
+	path := fmt.Sprintf("/projects/%s/translations/builds/files/%d", url.PathEscape(c.cfg.Project), fileID)
+	if err := c.do(ctx, http.MethodPost, path, nil, body, &build); err != nil {
+		return nil, fmt.Errorf("build translations: %w", err)
+	}
+	units, err := c.download(ctx, build.Data.URL)
+	if err != nil {
+		return nil, err
+	}
+	// Only approved translations are exported, whatever flags they carry.
+	for i := range units {
+		if units[i].Target != "" {
+			units[i].Status = models.TranslationReviewed
+		}
+	}
+	return units, nil
+}
@@ -0,0 +1,225 @@
+package translate
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/mkoziy/genome/exporter/internal/models"
+)
+
+// WritePO writes units as a gettext PO catalog translating into lang. The
+// key of each unit is its msgctxt, since the same English text may be
+// translated differently for different SNPs; machine drafts are marked
+// fuzzy, for the translator to confirm or correct.
+func WritePO(w io.Writer, lang string, units []Unit) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "# Translations of the genome database into %s.\n", lang)
+	bw.WriteString("msgid \"\"\n")
+	writePOString(bw, "msgstr", "Language: "+lang+"\nMIME-Version: 1.0\nContent-Type: text/plain; charset=UTF-8\nContent-Transfer-Encoding: 8bit\n")
+	for _, u := range units {
+		bw.WriteString("\n")
+		for _, line := range strings.Split(u.Note, "\n") {
+			if line != "" {
+				fmt.Fprintf(bw, "#. %s\n", line)
+			}
+		}
+		if u.Status == models.TranslationMachineDraft {
+			bw.WriteString("#, fuzzy\n")
+		}
+		writePOString(bw, "msgctxt", u.Key)
+		writePOString(bw, "msgid", u.Source)
+		writePOString(bw, "msgstr", u.Target)
+	}
+	return bw.Flush()
+}
+
+// writePOString writes the keyword and the quoted s, split after each
+// newline as msgfmt does.
+func writePOString(w *bufio.Writer, keyword, s string) {
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	if len(lines) <= 1 {
+		fmt.Fprintf(w, "%s %s\n", keyword, poQuote(s))
+		return
+	}
+	fmt.Fprintf(w, "%s \"\"\n", keyword)
+	for _, line := range lines {
+		fmt.Fprintln(w, poQuote(line))
+	}
+}
+
+func poQuote(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			b.WriteString(`\"`)
+		case '\\':
+			b.WriteString(`\\`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '\r':
+			b.WriteString(`\r`)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// poEntry is an entry of a PO catalog being read.
+type poEntry struct {
+	fuzzy   bool
+	notes   []string
+	strings map[string]*strings.Builder
+}
+
+// ReadPO reads the units of a PO catalog and the language of its header.
+// A translation is confirmed, and read as reviewed, unless it is fuzzy.
+// Plural forms are not supported; obsolete entries are ignored.
+func ReadPO(r io.Reader) (string, []Unit, error) {
+	lang, units, err := readPO(r)
+	if err != nil {
+		return "", nil, err
+	}
+	if lang == "" {
+		return "", nil, fmt.Errorf("PO header has no Language")
+	}
+	return lang, units, nil
+}
+
+// readPO is ReadPO for catalogs whose language is known otherwise, such as
+// those downloaded for a language: the header's language is returned, ""
+// if it has none.
+func readPO(r io.Reader) (string, []Unit, error) {
+	var (
+		lang    string
+		units   []Unit
+		entry   *poEntry
+		current *strings.Builder
+		lineNo  int
+	)
+	flush := func() error {
+		if entry == nil {
+			return nil
+		}
+		e := entry
+		entry, current = nil, nil
+		text := func(keyword string) (string, bool) {
+			b, ok := e.strings[keyword]
+			if !ok {
+				return "", false
+			}
+			return b.String(), true
+		}
+		if _, ok := text("msgid_plural"); ok {
+			return fmt.Errorf("line %d: plural forms are not supported", lineNo)
+		}
+		id, ok := text("msgid")
+		if !ok {
+			return fmt.Errorf("line %d: entry without msgid", lineNo)
+		}
+		ctxt, _ := text("msgctxt")
+		str, _ := text("msgstr")
+		if id == "" && ctxt == "" {
+			for _, line := range strings.Split(str, "\n") {
+				if v, ok := strings.CutPrefix(line, "Language:"); ok {
+					lang = strings.TrimSpace(v)
+				}
+			}
+			return nil
+		}
+		u := Unit{Key: ctxt, Source: id, Note: strings.Join(e.notes, "\n")}
+		if str != "" {
+			u.Target, u.Status = str, models.TranslationReviewed
+			if e.fuzzy {
+				u.Status = models.TranslationMachineDraft
+			}
+		}
+		units = append(units, u)
+		return nil
+	}
+
+	lines := bufio.NewScanner(r)
+	lines.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for lines.Scan() {
+		lineNo++
+		line := strings.TrimSpace(lines.Text())
+		switch {
+		case line == "":
+			if err := flush(); err != nil {
+				return "", nil, err
+			}
+			continue
+		case strings.HasPrefix(line, "#~"):
+			continue
+		case strings.HasPrefix(line, "#"):
+			// A comment after the strings starts the next entry.
+			if current != nil {
+				if err := flush(); err != nil {
+					return "", nil, err
+				}
+			}
+			if entry == nil {
+				entry = &poEntry{strings: make(map[string]*strings.Builder)}
+			}
+			if flags, ok := strings.CutPrefix(line, "#,"); ok {
+				for _, flag := range strings.Split(flags, ",") {
+					if strings.TrimSpace(flag) == "fuzzy" {
+						entry.fuzzy = true
+					}
+				}
+			} else if note, ok := strings.CutPrefix(line, "#."); ok {
+				entry.notes = append(entry.notes, strings.TrimSpace(note))
+			}
+			continue
+		case strings.HasPrefix(line, `"`):
+			if current == nil {
+				return "", nil, fmt.Errorf("line %d: string outside an entry", lineNo)
+			}
+			s, err := strconv.Unquote(line)
+			if err != nil {
+				return "", nil, fmt.Errorf("line %d: invalid string %s", lineNo, line)
+			}
+			current.WriteString(s)
+			continue
+		}
+
+		keyword, quoted, ok := strings.Cut(line, " ")
+		if !ok {
+			return "", nil, fmt.Errorf("line %d: unexpected %q", lineNo, line)
+		}
+		// A msgctxt or msgid after a msgstr starts the next entry.
+		if (keyword == "msgctxt" || keyword == "msgid") && entry != nil && entry.strings["msgstr"] != nil {
+			if err := flush(); err != nil {
+				return "", nil, err
+			}
+		}
+		if entry == nil {
+			entry = &poEntry{strings: make(map[string]*strings.Builder)}
+		}
+		s, err := strconv.Unquote(strings.TrimSpace(quoted))
+		if err != nil {
+			return "", nil, fmt.Errorf("line %d: invalid string %s", lineNo, quoted)
+		}
+		current = &strings.Builder{}
+		current.WriteString(s)
+		entry.strings[keyword] = current
+	}
+	if err := lines.Err(); err != nil {
+		return "", nil, err
+	}
+	if err := flush(); err != nil {
+		return "", nil, err
+	}
+	return lang, units, nil
+}
@@ -0,0 +1,293 @@
+package translate
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// Translation platforms the database syncs with.
+const (
+	PlatformWeblate = "weblate"
+	PlatformCrowdin = "crowdin"
+)
+
+// Platforms lists the translation platforms supported.
+var Platforms = []string{PlatformWeblate, PlatformCrowdin}
+
+// Defaults of SyncConfig.
+const (
+	DefaultCrowdinURL  = "https://api.crowdin.com/api/v2"
+	DefaultCrowdinFile = "genome.pot"
+	DefaultSyncTimeout = 5 * time.Minute
+)
+
+// SyncConfig connects the database to a Weblate or Crowdin project.
+type SyncConfig struct {
+	Platform string `yaml:"platform" json:"platform,omitempty"`
+	// URL is the Weblate instance, such as https://hosted.weblate.org, or
+	// the Crowdin API, which defaults to DefaultCrowdinURL.
+	URL   string `yaml:"url" json:"url,omitempty"`
+	Token string `yaml:"token" json:"-"`
+	// Project is the Weblate project slug or the numeric Crowdin project ID.
+	Project string `yaml:"project" json:"project,omitempty"`
+	// Component is the Weblate component slug, or the name of the Crowdin
+	// source file, DefaultCrowdinFile by default.
+	Component string `yaml:"component" json:"component,omitempty"`
+	// Languages are the languages synced.
+	Languages []string `yaml:"languages" json:"languages,omitempty"`
+	// LanguageCodes maps languages to the platform's codes where they
+	// differ, such as es to es-ES on Crowdin. Weblate codes default to the
+	// language with underscores, such as pt_BR.
+	LanguageCodes map[string]string `yaml:"language_codes" json:"language_codes,omitempty"`
+	Timeout       time.Duration     `yaml:"timeout" json:"timeout"`
+}
+
+// Validate reports every invalid setting at once, naming fields relative to c.
+func (c SyncConfig) Validate() error {
+	if c.Platform == "" {
+		return nil
+	}
+	var errs []error
+	if !slices.Contains(Platforms, c.Platform) {
+		errs = append(errs, fmt.Errorf("platform: unknown platform %q (want one of %s)", c.Platform, strings.Join(Platforms, ", ")))
+	}
+	if c.URL == "" && c.Platform == PlatformWeblate {
+		errs = append(errs, errors.New("url: required for weblate"))
+	} else if c.URL != "" {
+		if u, err := url.Parse(c.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("url: want an http or https URL, got %q", c.URL))
+		}
+	}
+	if c.Project == "" {
+		errs = append(errs, errors.New("project: required"))
+	}
+	if c.Component == "" && c.Platform == PlatformWeblate {
+		errs = append(errs, errors.New("component: required for weblate"))
+	}
+	if len(c.Languages) == 0 {
+		errs = append(errs, errors.New("languages: at least one language is required"))
+	}
+	for i, lang := range c.Languages {
+		if lang == "" || lang == SourceLanguage {
+			errs = append(errs, fmt.Errorf("languages[%d]: want a language other than %s, got %q", i, SourceLanguage, lang))
+		}
+	}
+	if c.Timeout < 0 {
+		errs = append(errs, errors.New("timeout: must not be negative"))
+	}
+	return errors.Join(errs...)
+}
+
+// Catalog is the units of every translatable text with their translations
+// into one language.
+type Catalog struct {
+	Language string
+	Units    []Unit
+}
+
+// Platform is a translation management platform translators work in.
+type Platform interface {
+	// Name identifies the platform; translations pulled from it are
+	// recorded as by it.
+	Name() string
+	// Push sends the platform the strings of the catalogs it does not have
+	// yet, and returns how many it took in.
+	Push(ctx context.Context, catalogs []Catalog) (int, error)
+	// Pull returns the translations into lang completed on the platform,
+	// confirmed ones reviewed, and none if it has none yet.
+	Pull(ctx context.Context, lang string) ([]Unit, error)
+}
+
+// NewPlatform returns the platform cfg selects.
+func NewPlatform(cfg SyncConfig) (Platform, error) {
+	if cfg.Platform == "" {
+		return nil, errors.New("no translation platform configured")
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	if cfg.Token == "" {
+		return nil, fmt.Errorf("%s: token is not configured", cfg.Platform)
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = DefaultSyncTimeout
+	}
+	client := &http.Client{Timeout: cfg.Timeout}
+	if cfg.Platform == PlatformCrowdin {
+		if cfg.URL == "" {
+			cfg.URL = DefaultCrowdinURL
+		}
+		if cfg.Component == "" {
+			cfg.Component = DefaultCrowdinFile
+		}
+		return &crowdin{platformClient{cfg, client, "Bearer " + cfg.Token}}, nil
+	}
+	return &weblate{platformClient{cfg, client, "Token " + cfg.Token}}, nil
+}
+
+// SyncStats counts what a sync did.
+type SyncStats struct {
+	Platform string `json:"platform"`
+	// Pushed counts the strings the platform took in.
+	Pushed int `json:"pushed"`
+	// Pulled counts what importing each language's translations did.
+	Pulled map[string]*ImportStats `json:"pulled,omitempty"`
+}
+
+// SyncOptions select what a sync does.
+type SyncOptions struct {
+	Languages []string
+	// Push and Pull select the directions synced.
+	Push, Pull bool
+	// BatchSize is the number of SNPs read at a time.
+	BatchSize int
+}
+
+// Sync pulls the translations into each language completed on p into db,
+// as by the platform, then pushes p the strings it does not have yet, with
+// their current translations. Pulling first means the strings pushed carry
+// what translators just completed; strings whose English text changed on
+// the way are skipped as stale and pushed anew.
+func Sync(ctx context.Context, db *bun.DB, p Platform, opts SyncOptions) (*SyncStats, error) {
+	stats := &SyncStats{Platform: p.Name(), Pulled: make(map[string]*ImportStats)}
+	if opts.Pull {
+		for _, lang := range opts.Languages {
+			units, err := p.Pull(ctx, lang)
+			if err != nil {
+				return stats, fmt.Errorf("pull %s: %w", lang, err)
+			}
+			imported, err := Import(ctx, db, lang, p.Name(), units)
+			if err != nil {
+				return stats, fmt.Errorf("import %s: %w", lang, err)
+			}
+			stats.Pulled[lang] = imported
+		}
+	}
+	if opts.Push {
+		catalogs := make([]Catalog, 0, len(opts.Languages))
+		for _, lang := range opts.Languages {
+			units := make([]Unit, 0)
+			err := walk(ctx, db, lang, opts.BatchSize, func(u Unit) error {
+				units = append(units, u)
+				return nil
+			})
+			if err != nil {
+				return stats, err
+			}
+			catalogs = append(catalogs, Catalog{Language: lang, Units: units})
+		}
+		pushed, err := p.Push(ctx, catalogs)
+		stats.Pushed = pushed
+		if err != nil {
+			return stats, fmt.Errorf("push: %w", err)
+		}
+	}
+	return stats, nil
+}
+
+// platformClient calls a platform's REST API.
+type platformClient struct {
+	cfg    SyncConfig
+	client *http.Client
+	auth   string
+}
+
+// languageCode returns the platform's code of lang.
+func (c *platformClient) languageCode(lang string) string {
+	if code, ok := c.cfg.LanguageCodes[lang]; ok {
+		return code
+	}
+	return lang
+}
+
+// do sends a request with body, JSON-encoded unless it is a *bytes.Buffer
+// sent as header's Content-Type, and decodes the JSON response into out if
+// it is set.
+func (c *platformClient) do(ctx context.Context, method, path string, header http.Header, body any, out any) error {
+	var reader io.Reader
+	header = header.Clone()
+	switch b := body.(type) {
+	case nil:
+	case *bytes.Buffer:
+		reader = b
+	default:
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		if header == nil {
+			header = http.Header{}
+		}
+		reader = bytes.NewReader(data)
+		header.Set("Content-Type", "application/json")
+	}
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimRight(c.cfg.URL, "/")+path, reader)
+	if err != nil {
+		return err
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	req.Header.Set("Authorization", c.auth)
+	req.Header.Set("Accept", "application/json")
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("execute request: %w", err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return &statusError{resp.StatusCode, strings.TrimSpace(string(data))}
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("decode response: %w", err)
+	}
+	return nil
+}
+
+// download reads the PO catalog at rawURL, sending credentials only to the
+// platform's own API.
+func (c *platformClient) download(ctx context.Context, rawURL string) ([]Unit, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	if strings.HasPrefix(rawURL, strings.TrimRight(c.cfg.URL, "/")+"/") {
+		req.Header.Set("Authorization", c.auth)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("execute request: %w", err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	if resp.StatusCode != http.StatusOK {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, &statusError{resp.StatusCode, strings.TrimSpace(string(data))}
+	}
+	_, units, err := readPO(resp.Body)
+	return units, err
+}
+
+// isNotFound reports whether err is a 404 of a platform.
+func isNotFound(err error) bool {
+	var status *statusError
+	return errors.As(err, &status) && status.status == http.StatusNotFound
+}
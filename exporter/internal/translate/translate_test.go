@@ -0,0 +1,521 @@
+package translate
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/uptrace/bun"
+
+	"github.com/mkoziy/genome/exporter/internal/database"
+	"github.com/mkoziy/genome/exporter/internal/migrations"
+	"github.com/mkoziy/genome/exporter/internal/models"
+	"github.com/mkoziy/genome/exporter/internal/ratelimit"
+	"github.com/mkoziy/genome/exporter/internal/repositories"
+)
+
+func newTestDB(t *testing.T) (*bun.DB, *models.SNP, *models.Phenotype) {
+	t.Helper()
+	ctx := context.Background()
+	db, err := database.NewDB("file:"+t.Name()+"?mode=memory&cache=shared", false)
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	if err := migrations.RunMigrations(ctx, db); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	snp := &models.SNP{RsID: "rs334", Chromosome: "11", Position: 5227002, ReferenceAllele: "T",
+		AlternateAlleles: models.StringArray{"A"}, VariantType: models.VariantSNV}
+	if _, err := db.NewInsert().Model(snp).Exec(ctx); err != nil {
+		t.Fatalf("insert snp: %v", err)
+	}
+	summary := &models.Translation{SNPID: snp.ID, LanguageCode: SourceLanguage, FieldName: models.TranslationSummary, TranslatedText: "Causes sickle cell anemia."}
+	if _, err := db.NewInsert().Model(summary).Exec(ctx); err != nil {
+		t.Fatalf("insert summary: %v", err)
+	}
+	clinical := &models.ClinicalData{SNPID: snp.ID, ClinicalSignificance: models.ClinicalPathogenic,
+		ReviewStatus: models.ReviewCriteriaProvided, ConditionName: "Sickle cell anemia", Source: models.SourceClinVar}
+	if _, err := db.NewInsert().Model(clinical).Exec(ctx); err != nil {
+		t.Fatalf("insert clinical: %v", err)
+	}
+	phenotype := &models.Phenotype{SNPID: snp.ID, PhenotypeName: "Malaria resistance", AssociationType: "association", Source: models.SourceGWAS}
+	if _, err := db.NewInsert().Model(phenotype).Exec(ctx); err != nil {
+		t.Fatalf("insert phenotype: %v", err)
+	}
+	return db, snp, phenotype
+}
+
+func TestCollectAndImport(t *testing.T) {
+	ctx := context.Background()
+	db, snp, phenotype := newTestDB(t)
+	draft := &models.Translation{SNPID: snp.ID, LanguageCode: "de", FieldName: models.TranslationSummary, TranslatedText: "Verursacht Sichelzellanämie."}
+	if _, err := db.NewInsert().Model(draft).Exec(ctx); err != nil {
+		t.Fatalf("insert translation: %v", err)
+	}
+
+	units, err := Collect(ctx, db, "de", false, 0)
+	if err != nil {
+		t.Fatalf("collect: %v", err)
+	}
+	// The messages come first, then the texts of the SNPs.
+	messages := len(models.Messages())
+	if len(units) < messages || units[0].Key != MessageKey(models.LevelVeryHigh.Key) || units[0].Source != "Very High" {
+		t.Fatalf("expected the messages first, got %+v", units)
+	}
+	units = units[messages:]
+	keys := make([]string, len(units))
+	for i, u := range units {
+		keys[i] = u.Key
+	}
+	want := []string{"rs334/summary", "rs334/condition:Sickle cell anemia", PhenotypeKey(phenotype.ID)}
+	if !reflect.DeepEqual(keys, want) {
+		t.Fatalf("expected units %v, got %v", want, keys)
+	}
+	if units[0].Target != draft.TranslatedText || units[0].Status != models.TranslationMachineDraft {
+		t.Errorf("expected the draft carried, got %+v", units[0])
+	}
+	if untranslated, _ := Collect(ctx, db, "de", true, 0); len(untranslated) != messages+2 {
+		t.Errorf("expected 2 untranslated units, got %v", untranslated)
+	}
+
+	// The translator confirms the draft, translates the condition, leaves
+	// the phenotype and translates a summary that has since changed.
+	units[0].Status = models.TranslationReviewed
+	units[1].Target, units[1].Status = "Sichelzellanämie", models.TranslationReviewed
+	stale := Unit{Key: "rs334/summary", Source: "An older summary.", Target: "Alt", Status: models.TranslationReviewed}
+	stats, err := Import(ctx, db, "de", "anna", append(units, stale))
+	if err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	if stats.Imported != 1 || stats.Confirmed != 1 || stats.Untranslated != 1 || len(stats.Stale) != 1 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+
+	var rows []*models.Translation
+	if err := db.NewSelect().Model(&rows).Where("language_code = 'de'").OrderExpr("id").Scan(ctx); err != nil {
+		t.Fatalf("read translations: %v", err)
+	}
+	if len(rows) != 2 || rows[0].Status != models.TranslationReviewed || *rows[0].Reviewer != "anna" {
+		t.Fatalf("expected the draft reviewed, got %+v", rows)
+	}
+	if rows[1].FieldName != "condition:Sickle cell anemia" || rows[1].Status != models.TranslationReviewed || *rows[1].Translator != "anna" {
+		t.Errorf("expected the condition imported as reviewed, got %+v", rows[1])
+	}
+
+	stats, err = Import(ctx, db, "de", "anna", units[:2])
+	if err != nil || stats.Unchanged != 2 {
+		t.Errorf("expected a second import unchanged, got %+v (%v)", stats, err)
+	}
+}
+
+func TestFileRoundTrips(t *testing.T) {
+	units := []Unit{
+		{Key: "rs334/summary", Source: "Causes \"sickle\" cell\nanemia.", Target: "Verursacht\nSichelzellanämie.", Status: models.TranslationMachineDraft, Note: "Plain-language summary of rs334"},
+		{Key: "rs334/condition:Sickle cell anemia", Source: "Sickle cell anemia", Target: "Sichelzellanämie", Status: models.TranslationReviewed, Note: "Condition rs334 is associated with"},
+		{Key: "phenotype/7", Source: "Malaria resistance", Note: "Phenotype associated with rs334"},
+	}
+	for _, format := range []struct {
+		name  string
+		write func(*bytes.Buffer) error
+		read  func(*bytes.Buffer) (string, []Unit, error)
+	}{
+		{"xliff", func(b *bytes.Buffer) error { return WriteXLIFF(b, "de", units) }, func(b *bytes.Buffer) (string, []Unit, error) { return ReadXLIFF(b) }},
+		{"po", func(b *bytes.Buffer) error { return WritePO(b, "de", units) }, func(b *bytes.Buffer) (string, []Unit, error) { return ReadPO(b) }},
+	} {
+		t.Run(format.name, func(t *testing.T) {
+			var b bytes.Buffer
+			if err := format.write(&b); err != nil {
+				t.Fatalf("write: %v", err)
+			}
+			written := b.String()
+			lang, got, err := format.read(&b)
+			if err != nil {
+				t.Fatalf("read: %v\n%s", err, written)
+			}
+			if lang != "de" || !reflect.DeepEqual(got, units) {
+				t.Errorf("expected the units back, got %s %+v\n%s", lang, got, written)
+			}
+		})
+	}
+}
+
+func TestImportMessages(t *testing.T) {
+	ctx := context.Background()
+	db, _, _ := newTestDB(t)
+	assertion := models.ReasonClinicalAssertion
+	units := []Unit{
+		{Key: MessageKey(models.LevelVeryHigh.Key), Source: "Very High", Target: "Sehr hoch", Status: models.TranslationReviewed},
+		{Key: MessageKey(assertion.Key), Source: assertion.Text, Target: "{2} Befund ({1})"},
+		{Key: MessageKey(models.ReasonMAF.Key), Source: models.ReasonMAF.Text, Target: "MAF in {2}"},
+		{Key: MessageKey("level/extreme"), Source: "Extreme", Target: "Extrem"},
+	}
+	stats, err := Import(ctx, db, "de", "anna", units)
+	if err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	if stats.Imported != 2 || !reflect.DeepEqual(stats.Malformed, []string{units[2].Key}) || !reflect.DeepEqual(stats.Stale, []string{units[3].Key}) {
+		t.Fatalf("expected the template without {1} malformed and the unknown message stale, got %+v", stats)
+	}
+	messages, err := repositories.GetMessageTranslations(ctx, db, "de")
+	if err != nil {
+		t.Fatalf("get messages: %v", err)
+	}
+	if len(messages) != 2 || messages[models.LevelVeryHigh.Key] != "Sehr hoch" {
+		t.Errorf("unexpected messages %v", messages)
+	}
+	collected, err := Collect(ctx, db, "de", true, 0)
+	if err != nil || collected[0].Key != MessageKey(models.LevelHigh.Key) {
+		t.Errorf("expected the translated level left out of the untranslated units, got %+v (%v)", collected[:1], err)
+	}
+}
+
+func TestReadPOFromCATTool(t *testing.T) {
+	po := `# Saved by a CAT tool
+msgid ""
+msgstr ""
+"Language: pt-BR\n"
+"Plural-Forms: nplurals=2; plural=(n > 1);\n"
+
+#. Plain-language summary of rs334
+#: rs334
+#, fuzzy, no-c-format
+msgctxt "rs334/summary"
+msgid "Causes sickle cell anemia."
+msgstr "Causa anemia "
+"falciforme."
+
+#~ msgctxt "rs1/summary"
+#~ msgid "Obsolete."
+#~ msgstr "Obsoleto."
+msgctxt "phenotype/7"
+msgid "Malaria resistance"
+msgstr "Resistência à malária"
+`
+	lang, units, err := ReadPO(strings.NewReader(po))
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if lang != "pt-BR" || len(units) != 2 {
+		t.Fatalf("unexpected catalog %s %+v", lang, units)
+	}
+	if units[0].Target != "Causa anemia falciforme." || units[0].Status != models.TranslationMachineDraft {
+		t.Errorf("unexpected fuzzy unit: %+v", units[0])
+	}
+	if units[1].Key != "phenotype/7" || units[1].Status != models.TranslationReviewed {
+		t.Errorf("unexpected unit: %+v", units[1])
+	}
+
+	if _, _, err := ReadPO(strings.NewReader("msgid \"a\"\nmsgid_plural \"as\"\nmsgstr[0] \"\"\n")); err == nil {
+		t.Error("expected plural forms rejected")
+	}
+}
+
+func TestGlossary(t *testing.T) {
+	g := NewGlossary("de", []*models.GlossaryTerm{
+		{Term: "pathogenic", LanguageCode: "de", Translation: "pathogen"},
+		{Term: "likely pathogenic", LanguageCode: "de", Translation: "wahrscheinlich pathogen"},
+		{Term: "BRCA1", LanguageCode: "de", Translation: "BRCA1", CaseSensitive: true},
+		{Term: "benign", LanguageCode: "fr", Translation: "bénin"},
+	})
+	if g.Len() != 3 {
+		t.Fatalf("expected the other language's term ignored, got %d terms", g.Len())
+	}
+
+	source := "A likely pathogenic variant of BRCA1, not of BRCA12 or brca1."
+	if v := g.Check(source, "Eine wahrscheinlich pathogene Variante von BRCA1."); len(v) != 0 {
+		t.Errorf("expected an inflected translation to pass, got %v", v)
+	}
+	v := g.Check(source, "Eine wahrscheinlich krankmachende Variante von Brca1.")
+	if len(v) != 2 || v[0].Term != "likely pathogenic" || v[1].Term != "BRCA1" {
+		t.Errorf("expected both terms flagged, got %v", v)
+	}
+	if v := g.Check("Pathogenic.", "Pathogen."); len(v) != 0 {
+		t.Errorf("expected terms matched in any case, got %v", v)
+	}
+
+	p := g.Protect(source)
+	if p.Text != "A ⟦0⟧ variant of ⟦1⟧, not of BRCA12 or brca1." {
+		t.Fatalf("unexpected protected text %q", p.Text)
+	}
+	restored, err := p.Restore("Eine ⟦0⟧e Variante von ⟦1⟧, nicht von BRCA12 oder brca1.")
+	if err != nil || restored != "Eine wahrscheinlich pathogene Variante von BRCA1, nicht von BRCA12 oder brca1." {
+		t.Errorf("unexpected restored text %q (%v)", restored, err)
+	}
+	if _, err := p.Restore("Eine Variante von ⟦1⟧."); err == nil {
+		t.Error("expected a dropped placeholder reported")
+	}
+}
+
+func TestImportKeepsToGlossary(t *testing.T) {
+	ctx := context.Background()
+	db, _, _ := newTestDB(t)
+	terms := []*models.GlossaryTerm{{Term: "sickle cell", LanguageCode: "de", Translation: "Sichelzell"}}
+	if err := repositories.SaveGlossaryTerms(ctx, db, terms); err != nil {
+		t.Fatalf("save glossary: %v", err)
+	}
+
+	units := []Unit{
+		{Key: "rs334/summary", Source: "Causes sickle cell anemia.", Target: "Verursacht Blutarmut.", Status: models.TranslationReviewed},
+		{Key: "rs334/condition:Sickle cell anemia", Source: "Sickle cell anemia", Target: "Sichelzellanämie", Status: models.TranslationReviewed},
+	}
+	stats, err := Import(ctx, db, "de", "anna", units)
+	if err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	if stats.Imported != 2 || len(stats.Violations) != 1 || stats.Violations[0].Key != "rs334/summary" {
+		t.Fatalf("expected the summary flagged, got %+v", stats)
+	}
+	var statuses []string
+	if err := db.NewSelect().Model((*models.Translation)(nil)).Column("status").Where("language_code = 'de'").OrderExpr("id").Scan(ctx, &statuses); err != nil {
+		t.Fatalf("read statuses: %v", err)
+	}
+	if len(statuses) != 2 || statuses[0] != string(models.TranslationMachineDraft) || statuses[1] != string(models.TranslationReviewed) {
+		t.Errorf("expected the flagged translation left a draft, got %v", statuses)
+	}
+
+	violations, err := CheckTranslations(ctx, db, "de", 0)
+	if err != nil || len(violations) != 1 || violations[0].Term != "sickle cell" || violations[0].Status != models.TranslationMachineDraft {
+		t.Errorf("expected the check to flag the summary, got %v (%v)", violations, err)
+	}
+}
+
+func TestMachineTranslate(t *testing.T) {
+	ctx := context.Background()
+	db, _, _ := newTestDB(t)
+	// A second SNP shares the condition, translated once for both.
+	other := &models.SNP{RsID: "rs33930165", Chromosome: "11", Position: 5226925, ReferenceAllele: "C",
+		AlternateAlleles: models.StringArray{"T"}, VariantType: models.VariantSNV}
+	if _, err := db.NewInsert().Model(other).Exec(ctx); err != nil {
+		t.Fatalf("insert snp: %v", err)
+	}
+	clinical := &models.ClinicalData{SNPID: other.ID, ClinicalSignificance: models.ClinicalPathogenic,
+		ReviewStatus: models.ReviewCriteriaProvided, ConditionName: "Sickle cell anemia", Source: models.SourceClinVar}
+	if _, err := db.NewInsert().Model(clinical).Exec(ctx); err != nil {
+		t.Fatalf("insert clinical: %v", err)
+	}
+	terms := []*models.GlossaryTerm{{Term: "sickle cell", LanguageCode: "de", Translation: "Sichelzell"}}
+	if err := repositories.SaveGlossaryTerms(ctx, db, terms); err != nil {
+		t.Fatalf("save glossary: %v", err)
+	}
+
+	var requests [][]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Text       []string `json:"text"`
+			TargetLang string   `json:"target_lang"`
+		}
+		if r.URL.Path != "/v2/translate" || r.Header.Get("Authorization") != "DeepL-Auth-Key k" {
+			t.Errorf("unexpected request %s %v", r.URL.Path, r.Header)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.TargetLang != "DE" {
+			t.Errorf("unexpected body %+v (%v)", body, err)
+		}
+		requests = append(requests, body.Text)
+		if len(requests) == 1 {
+			http.Error(w, "slow down", http.StatusTooManyRequests)
+			return
+		}
+		var resp struct {
+			Translations []map[string]string `json:"translations"`
+		}
+		for _, text := range body.Text {
+			// The service drops the placeholder of the condition.
+			if strings.HasPrefix(text, "⟦0⟧ ") {
+				text = strings.TrimPrefix(text, "⟦0⟧ ")
+			}
+			resp.Translations = append(resp.Translations, map[string]string{"text": "DE " + text})
+		}
+		_ = json.NewEncoder(w).Encode(resp)
+	}))
+	defer srv.Close()
+
+	if job, err := Plan(ctx, db, "de", []string{FieldCondition}, 0); err != nil || len(job.Units) != 2 || len(job.Texts) != 1 {
+		t.Fatalf("expected both conditions, got %+v (%v)", job, err)
+	}
+	if _, err := Plan(ctx, db, "de", []string{"gene"}, 0); err == nil {
+		t.Error("expected an unknown field rejected")
+	}
+	if job, err := Plan(ctx, db, "de", nil, 0); err != nil || len(job.Units) != len(models.Messages())+4 {
+		t.Fatalf("expected the messages and the SNPs' texts, got %+v (%v)", job, err)
+	}
+	job, err := Plan(ctx, db, "de", []string{FieldSummary, FieldCondition, FieldPhenotype}, 0)
+	if err != nil {
+		t.Fatalf("plan: %v", err)
+	}
+	want := []string{"Causes ⟦0⟧ anemia.", "⟦0⟧ anemia", "Malaria resistance"}
+	if len(job.Units) != 4 || !reflect.DeepEqual(job.Texts, want) {
+		t.Fatalf("unexpected job %+v", job)
+	}
+	cfg := MachineConfig{Provider: ProviderDeepL, URL: srv.URL, APIKey: "k", BatchSize: 2,
+		RateLimit: ratelimit.Config{RequestsPerSec: 1000, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond}}
+	if e := job.Estimate(cfg); e.Characters != 46 || e.Requests != 2 || e.Cost != 46*25/1e6 {
+		t.Errorf("unexpected estimate %+v", e)
+	}
+
+	p, err := NewProvider(cfg, ratelimit.NewLimiter(cfg.RateLimit))
+	if err != nil {
+		t.Fatalf("provider: %v", err)
+	}
+	stats, err := job.Run(ctx, db, p, cfg)
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if len(requests) != 3 || stats.Translated != 3 || stats.Imported != 2 || len(stats.Failed) != 2 {
+		t.Fatalf("expected the rate-limited batch retried and the conditions failed, got %d requests, %+v", len(requests), stats)
+	}
+	var rows []*models.Translation
+	if err := db.NewSelect().Model(&rows).Where("language_code = 'de'").Scan(ctx); err != nil {
+		t.Fatalf("read translations: %v", err)
+	}
+	if len(rows) != 1 || rows[0].TranslatedText != "DE Causes Sichelzell anemia." || rows[0].Status != models.TranslationMachineDraft || *rows[0].Translator != "machine:deepl" {
+		t.Errorf("expected the summary drafted with the glossary term, got %+v", rows)
+	}
+	if job, err := Plan(ctx, db, "de", []string{FieldCondition}, 0); err != nil || len(job.Units) != 2 {
+		t.Errorf("expected only the failed conditions left, got %+v (%v)", job, err)
+	}
+}
+
+func TestSyncWeblate(t *testing.T) {
+	ctx := context.Background()
+	db, _, _ := newTestDB(t)
+	translated := []Unit{{Key: SNPKey("rs334", models.TranslationSummary), Source: "Causes sickle cell anemia.",
+		Target: "Verursacht Sichelzellanämie.", Status: models.TranslationReviewed}}
+
+	started := false
+	var uploads []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Token tok" {
+			t.Errorf("unexpected auth %q", r.Header.Get("Authorization"))
+		}
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/api/translations/genome/snps/de/file/":
+			_ = WritePO(w, "de", translated)
+		case r.Method == http.MethodPost && r.URL.Path == "/api/components/genome/snps/translations/":
+			var body map[string]string
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body["language_code"] != "pt_BR" {
+				t.Errorf("unexpected translation started %v (%v)", body, err)
+			}
+			started = true
+			w.WriteHeader(http.StatusCreated)
+		case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/file/"):
+			lang := strings.Split(r.URL.Path, "/")[5]
+			if lang == "pt_BR" && !started {
+				http.NotFound(w, r)
+				return
+			}
+			file, _, err := r.FormFile("file")
+			if err != nil || r.FormValue("method") != "add" {
+				t.Fatalf("unexpected upload (%v)", err)
+			}
+			_, units, err := ReadPO(file)
+			if err != nil {
+				t.Fatalf("read upload: %v", err)
+			}
+			uploads = append(uploads, lang)
+			_ = json.NewEncoder(w).Encode(map[string]int{"accepted": len(units)})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	if err := (SyncConfig{Platform: PlatformWeblate, Project: "genome", Languages: []string{"en"}}).Validate(); err == nil {
+		t.Error("expected a weblate config without url, component or target language rejected")
+	}
+	cfg := SyncConfig{Platform: PlatformWeblate, URL: srv.URL, Token: "tok", Project: "genome", Component: "snps", Languages: []string{"de", "pt-BR"}}
+	p, err := NewPlatform(cfg)
+	if err != nil {
+		t.Fatalf("platform: %v", err)
+	}
+	stats, err := Sync(ctx, db, p, SyncOptions{Languages: cfg.Languages, Push: true, Pull: true})
+	if err != nil {
+		t.Fatalf("sync: %v", err)
+	}
+	if stats.Pulled["de"].Imported != 1 || stats.Pulled["pt-BR"].Imported != 0 {
+		t.Errorf("expected the German summary pulled, got %+v", stats.Pulled)
+	}
+	if !started || !reflect.DeepEqual(uploads, []string{"de", "pt_BR"}) || stats.Pushed != 2*(len(models.Messages())+3) {
+		t.Errorf("expected both catalogs pushed, the Portuguese after starting it, got %v, %d pushed", uploads, stats.Pushed)
+	}
+	var row models.Translation
+	if err := db.NewSelect().Model(&row).Where("language_code = 'de'").Scan(ctx); err != nil {
+		t.Fatalf("read translation: %v", err)
+	}
+	if row.TranslatedText != "Verursacht Sichelzellanämie." || row.Status != models.TranslationReviewed || *row.Translator != PlatformWeblate {
+		t.Errorf("unexpected translation %+v", row)
+	}
+}
+
+func TestSyncCrowdin(t *testing.T) {
+	ctx := context.Background()
+	db, _, phenotype := newTestDB(t)
+
+	var srv *httptest.Server
+	var updated bool
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/download/de.po" {
+			if r.Header.Get("Authorization") != "" {
+				t.Error("expected no credentials sent with the download")
+			}
+			_ = WritePO(w, "de", []Unit{{Key: PhenotypeKey(phenotype.ID), Source: "Malaria resistance",
+				Target: "Malariaresistenz", Status: models.TranslationMachineDraft}})
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer tok" {
+			t.Errorf("unexpected auth %q", r.Header.Get("Authorization"))
+		}
+		switch r.Method + " " + r.URL.Path {
+		case "POST /api/storages":
+			if r.Header.Get("Crowdin-Api-Filename") != DefaultCrowdinFile {
+				t.Errorf("unexpected file name %q", r.Header.Get("Crowdin-Api-Filename"))
+			}
+			_, _ = w.Write([]byte(`{"data": {"id": 7}}`))
+		case "GET /api/projects/42/files":
+			_, _ = w.Write([]byte(`{"data": [{"data": {"id": 3, "name": "genome.pot"}}]}`))
+		case "PUT /api/projects/42/files/3":
+			var body map[string]any
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body["storageId"] != float64(7) || body["updateOption"] != "keep_translations" {
+				t.Errorf("unexpected update %v (%v)", body, err)
+			}
+			updated = true
+			_, _ = w.Write([]byte(`{"data": {}}`))
+		case "POST /api/projects/42/translations/builds/files/3":
+			var body map[string]any
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body["targetLanguageId"] != "de" || body["exportApprovedOnly"] != true {
+				t.Errorf("unexpected build %v (%v)", body, err)
+			}
+			_, _ = w.Write([]byte(`{"data": {"url": "` + srv.URL + `/download/de.po"}}`))
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	p, err := NewPlatform(SyncConfig{Platform: PlatformCrowdin, URL: srv.URL + "/api", Token: "tok", Project: "42", Languages: []string{"de"}})
+	if err != nil {
+		t.Fatalf("platform: %v", err)
+	}
+	stats, err := Sync(ctx, db, p, SyncOptions{Languages: []string{"de"}, Push: true, Pull: true})
+	if err != nil {
+		t.Fatalf("sync: %v", err)
+	}
+	if stats.Pulled["de"].Imported != 1 || stats.Pushed != len(models.Messages())+3 || !updated {
+		t.Errorf("expected the approved translation pulled and the source file updated, got %+v", stats)
+	}
+	var row models.PhenotypeTranslation
+	if err := db.NewSelect().Model(&row).Scan(ctx); err != nil {
+		t.Fatalf("read translation: %v", err)
+	}
+	if row.TranslatedName != "Malariaresistenz" || row.Status != models.TranslationReviewed {
+		t.Errorf("expected the approved translation reviewed, got %+v", row)
+	}
+}
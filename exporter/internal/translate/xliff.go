@@ -0,0 +1,110 @@
+package translate
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+
+	"github.com/mkoziy/genome/exporter/internal/models"
+)
+
+// xliffNamespace is the namespace of XLIFF 1.2, the version CAT tools all
+// read.
+const xliffNamespace = "urn:oasis:names:tc:xliff:document:1.2"
+
+type xliffDocument struct {
+	XMLName xml.Name    `xml:"urn:oasis:names:tc:xliff:document:1.2 xliff"`
+	Version string      `xml:"version,attr"`
+	Files   []xliffFile `xml:"file"`
+}
+
+type xliffFile struct {
+	Original       string      `xml:"original,attr"`
+	SourceLanguage string      `xml:"source-language,attr"`
+	TargetLanguage string      `xml:"target-language,attr"`
+	Datatype       string      `xml:"datatype,attr"`
+	Units          []xliffUnit `xml:"body>trans-unit"`
+}
+
+type xliffUnit struct {
+	ID       string       `xml:"id,attr"`
+	Approved string       `xml:"approved,attr,omitempty"`
+	Source   string       `xml:"source"`
+	Target   *xliffTarget `xml:"target"`
+	Note     string       `xml:"note,omitempty"`
+}
+
+type xliffTarget struct {
+	State string `xml:"state,attr,omitempty"`
+	Text  string `xml:",chardata"`
+}
+
+// xliffStates maps the review statuses to the XLIFF target states written.
+var xliffStates = map[models.TranslationStatus]string{
+	models.TranslationMachineDraft: "needs-review-translation",
+	models.TranslationReviewed:     "translated",
+	models.TranslationVerified:     "signed-off",
+}
+
+// WriteXLIFF writes units as an XLIFF 1.2 document translating into lang.
+// Machine drafts are marked needs-review-translation, for the translator to
+// confirm or correct.
+func WriteXLIFF(w io.Writer, lang string, units []Unit) error {
+	file := xliffFile{Original: "genome", SourceLanguage: SourceLanguage, TargetLanguage: lang, Datatype: "plaintext"}
+	for _, u := range units {
+		xu := xliffUnit{ID: u.Key, Source: u.Source, Note: u.Note}
+		if u.Status != "" {
+			xu.Target = &xliffTarget{State: xliffStates[u.Status], Text: u.Target}
+		}
+		file.Units = append(file.Units, xu)
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(xliffDocument{Version: "1.2", Files: []xliffFile{file}}); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// ReadXLIFF reads the units of an XLIFF 1.2 document and the language it
+// translates into. A target is confirmed, and read as reviewed, when its
+// unit is approved or its state is translated, final or signed-off.
+func ReadXLIFF(r io.Reader) (string, []Unit, error) {
+	var doc xliffDocument
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return "", nil, fmt.Errorf("parse XLIFF: %w", err)
+	}
+	if doc.XMLName.Space != xliffNamespace || doc.Version != "1.2" {
+		return "", nil, fmt.Errorf("unsupported XLIFF version %q", doc.Version)
+	}
+	var lang string
+	var units []Unit
+	for _, f := range doc.Files {
+		if lang != "" && f.TargetLanguage != lang {
+			return "", nil, fmt.Errorf("XLIFF translates into both %s and %s", lang, f.TargetLanguage)
+		}
+		lang = f.TargetLanguage
+		for _, xu := range f.Units {
+			u := Unit{Key: xu.ID, Source: xu.Source, Note: xu.Note}
+			if xu.Target != nil && xu.Target.Text != "" {
+				u.Target, u.Status = xu.Target.Text, models.TranslationMachineDraft
+				switch xu.Target.State {
+				case "translated", "final", "signed-off":
+					u.Status = models.TranslationReviewed
+				}
+				if xu.Approved == "yes" {
+					u.Status = models.TranslationReviewed
+				}
+			}
+			units = append(units, u)
+		}
+	}
+	if lang == "" {
+		return "", nil, fmt.Errorf("XLIFF has no target language")
+	}
+	return lang, units, nil
+}
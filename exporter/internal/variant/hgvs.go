@@ -0,0 +1,200 @@
+package variant
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// HGVS coordinate types the exporter stores.
+const (
+	HGVSGenomic = "g"
+	HGVSCoding  = "c"
+	HGVSProtein = "p"
+)
+
+// HGVS is a variant description in HGVS nomenclature, such as
+// "NM_000546.6(TP53):c.215C>G": the reference sequence it is described on,
+// optionally with the gene, the coordinate type and the change.
+type HGVS struct {
+	// Accession is the reference sequence, such as NM_000546.6, or a gene
+	// symbol for protein changes ClinVar names without their protein's
+	// accession.
+	Accession string
+	Gene      string
+	// Type is the coordinate type: g, c, n, m, r or p.
+	Type   string
+	Change string
+}
+
+// String returns the canonical form of h, without the gene, such as
+// "NM_000546.6:c.215C>G"; stored notations are in this form.
+func (h HGVS) String() string {
+	return h.Accession + ":" + h.Type + "." + h.Change
+}
+
+// Unversioned reports whether the accession lacks its version, as in
+// "NM_000546:c.215C>G", which names any version of the transcript.
+func (h HGVS) Unversioned() bool {
+	return accessionPattern.MatchString(h.Accession) && !strings.Contains(h.Accession, ".")
+}
+
+var (
+	hgvsPattern      = regexp.MustCompile(`^([A-Za-z0-9_.-]+?)(?:\(([A-Za-z0-9_.-]+)\))?:([cgmnrp])\.(.+)$`)
+	accessionPattern = regexp.MustCompile(`^[A-Z]{2}_\d+(\.\d+)?$`)
+	// A position is a number, optionally with an intronic offset or in the
+	// 5' or 3' UTR, and a range two positions joined by _.
+	nucleotideChange = regexp.MustCompile(`^(?:[-*]?\d+(?:[+-]\d+)?)(?:_[-*]?\d+(?:[+-]\d+)?)?` +
+		`(?:[ACGTUN]>[ACGTUN]|delins[ACGTUN]+|del[ACGTUN]*|dup[ACGTUN]*|ins[ACGTUN]+|inv|=)$`)
+	proteinChange = regexp.MustCompile(`^(?:\(.+\)|[A-Z](?:[a-z]{2})?\d+.*|=|\?|0)$`)
+)
+
+// ParseHGVS parses a variant description such as "NM_000546.6:c.215C>G",
+// with or without the gene in parentheses after the accession. Nucleotide
+// changes are upper-cased; protein changes are kept as written, since
+// three-letter amino acid codes are case-sensitive.
+func ParseHGVS(s string) (HGVS, error) {
+	m := hgvsPattern.FindStringSubmatch(strings.TrimSpace(s))
+	if m == nil {
+		return HGVS{}, fmt.Errorf("hgvs: %q is not an accession:type.change description", s)
+	}
+	h := HGVS{Accession: m[1], Gene: m[2], Type: m[3], Change: m[4]}
+	if strings.Contains(h.Accession, "_") {
+		h.Accession = strings.ToUpper(h.Accession)
+	}
+	if h.Type == HGVSProtein {
+		if !proteinChange.MatchString(h.Change) {
+			return HGVS{}, fmt.Errorf("hgvs: %q is not a protein change", h.Change)
+		}
+		return h, nil
+	}
+	h.Change = strings.ToUpper(h.Change)
+	// The keywords are lower case; only the bases around them are not.
+	for _, kw := range []string{"DELINS", "DEL", "DUP", "INS", "INV"} {
+		h.Change = strings.Replace(h.Change, kw, strings.ToLower(kw), 1)
+	}
+	if !nucleotideChange.MatchString(h.Change) {
+		return HGVS{}, fmt.Errorf("hgvs: %q is not a nucleotide change", h.Change)
+	}
+	return h, nil
+}
+
+// ParsePreferredName splits a ClinVar preferred variant name, such as
+// "NM_000041.4(APOE):c.388T>C (p.Cys130Arg)", into its coding description
+// and its protein change, which ClinVar gives without the protein's
+// accession and so is described on the gene: "APOE:p.Cys130Arg". Either
+// is empty if the name lacks it.
+func ParsePreferredName(name string) (coding, protein HGVS, err error) {
+	name = strings.TrimSpace(name)
+	if i := strings.LastIndex(name, " ("); i >= 0 && strings.HasSuffix(name, ")") {
+		change, ok := strings.CutPrefix(name[i+2:len(name)-1], "p.")
+		name = name[:i]
+		if ok {
+			protein = HGVS{Type: HGVSProtein, Change: change}
+		}
+	}
+	coding, err = ParseHGVS(name)
+	if err != nil {
+		return HGVS{}, HGVS{}, err
+	}
+	if protein.Change != "" {
+		if protein.Accession = coding.Gene; protein.Accession == "" || !proteinChange.MatchString(protein.Change) {
+			protein = HGVS{}
+		}
+	}
+	return coding, protein, nil
+}
+
+// refSeqChromosomes are the RefSeq accessions of the GRCh38 chromosomes.
+var refSeqChromosomes = map[string]string{
+	"1": "NC_000001.11", "2": "NC_000002.12", "3": "NC_000003.12", "4": "NC_000004.12",
+	"5": "NC_000005.10", "6": "NC_000006.12", "7": "NC_000007.14", "8": "NC_000008.11",
+	"9": "NC_000009.12", "10": "NC_000010.11", "11": "NC_000011.10", "12": "NC_000012.12",
+	"13": "NC_000013.11", "14": "NC_000014.9", "15": "NC_000015.10", "16": "NC_000016.10",
+	"17": "NC_000017.11", "18": "NC_000018.10", "19": "NC_000019.10", "20": "NC_000020.11",
+	"21": "NC_000021.9", "22": "NC_000022.11", "X": "NC_000023.11", "Y": "NC_000024.10",
+	"MT": "NC_012920.1",
+}
+
+// GenomicHGVS returns the genomic description of a GRCh38 variant with a
+// single alternate allele, such as "NC_000019.10:g.44908684T>C", and false
+// if the chromosome is not a GRCh38 one or the alleles are not bases.
+// Alleles are trimmed like Key's; an insertion written without an anchor
+// base, with - for the reference allele, goes before pos, as ClinVar places
+// them. The mitochondrion's positions are described with m. rather than g.
+//
+// Telling a duplication from an insertion, or shifting an indel to its 3'
+// position as HGVS prescribes, would need the reference sequence, so
+// insertions are described as such at the position given.
+func GenomicHGVS(chrom string, pos int64, ref, alt string) (string, bool) {
+	chrom = NormalizeChromosome(chrom)
+	accession, ok := refSeqChromosomes[chrom]
+	alleles := []string{normalizeAllele(ref), normalizeAllele(alt)}
+	if !ok || pos <= 0 || alleles[0] == "" || alleles[1] == "" || alleles[0] == alleles[1] {
+		return "", false
+	}
+	for _, a := range alleles {
+		if strings.Trim(a, "ACGTN-") != "" || (len(a) > 1 && strings.Contains(a, missingAllele)) {
+			return "", false
+		}
+	}
+	pos = trim(alleles, pos)
+	if alleles[0] != missingAllele && alleles[1] != missingAllele && alleles[0][0] == alleles[1][0] {
+		// An anchored indel: the anchor base is unchanged.
+		alleles[0], alleles[1] = alleles[0][1:], alleles[1][1:]
+		pos++
+	}
+	ref, alt = strings.TrimPrefix(alleles[0], missingAllele), strings.TrimPrefix(alleles[1], missingAllele)
+
+	prefix := accession + ":g."
+	if chrom == "MT" {
+		prefix = accession + ":m."
+	}
+	span := func(start, end int64) string {
+		if start == end {
+			return strconv.FormatInt(start, 10)
+		}
+		return strconv.FormatInt(start, 10) + "_" + strconv.FormatInt(end, 10)
+	}
+	end := pos + int64(len(ref)) - 1
+	switch {
+	case ref == "":
+		return prefix + span(pos-1, pos) + "ins" + alt, true
+	case alt == "":
+		return prefix + span(pos, end) + "del", true
+	case len(ref) == 1 && len(alt) == 1:
+		return prefix + strconv.FormatInt(pos, 10) + ref + ">" + alt, true
+	default:
+		return prefix + span(pos, end) + "delins" + alt, true
+	}
+}
+
+// StructuralHGVS returns the genomic description of a GRCh38 structural
+// variant spanning start to end whose sequence is not spelled out, such as
+// "NC_000017.11:g.43044295_43125483del". change is HGVS's name of it: del,
+// dup or inv. It returns false if the chromosome is not a GRCh38 one or the
+// span is empty.
+func StructuralHGVS(chrom string, start, end int64, change string) (string, bool) {
+	chrom = NormalizeChromosome(chrom)
+	accession, ok := refSeqChromosomes[chrom]
+	if !ok || start <= 0 || end < start {
+		return "", false
+	}
+	switch change {
+	case "del", "dup", "inv":
+	default:
+		return "", false
+	}
+	prefix := accession + ":g."
+	if chrom == "MT" {
+		prefix = accession + ":m."
+	}
+	if start == end {
+		if change == "inv" {
+			return "", false
+		}
+		return prefix + strconv.FormatInt(start, 10) + change, true
+	}
+	return prefix + strconv.FormatInt(start, 10) + "_" + strconv.FormatInt(end, 10) + change, true
+}
@@ -0,0 +1,105 @@
+package variant
+
+import "testing"
+
+func TestParseHGVS(t *testing.T) {
+	cases := []struct {
+		in          string
+		want        string
+		gene        string
+		unversioned bool
+	}{
+		{"NM_000546.6:c.215C>G", "NM_000546.6:c.215C>G", "", false},
+		{"NM_000546.6(TP53):c.215C>G", "NM_000546.6:c.215C>G", "TP53", false},
+		{" nm_000546:c.215c>g ", "NM_000546:c.215C>G", "", true},
+		{"NC_000019.10:g.44908684T>C", "NC_000019.10:g.44908684T>C", "", false},
+		{"NM_007294.4:c.5266dupC", "NM_007294.4:c.5266dupC", "", false},
+		{"NM_007294.4:c.68_69delAG", "NM_007294.4:c.68_69delAG", "", false},
+		{"NM_000492.4:c.1521_1523DELCTT", "NM_000492.4:c.1521_1523delCTT", "", false},
+		{"NM_000059.4:c.-26G>A", "NM_000059.4:c.-26G>A", "", false},
+		{"NM_000059.4:c.8487+1G>A", "NM_000059.4:c.8487+1G>A", "", false},
+		{"NM_000059.4:c.100_101delinsTT", "NM_000059.4:c.100_101delinsTT", "", false},
+		{"NP_000537.3:p.Pro72Arg", "NP_000537.3:p.Pro72Arg", "", false},
+		{"APOE:p.Cys130Arg", "APOE:p.Cys130Arg", "", false},
+	}
+	for _, c := range cases {
+		h, err := ParseHGVS(c.in)
+		if err != nil {
+			t.Errorf("%q: %v", c.in, err)
+			continue
+		}
+		if h.String() != c.want || h.Gene != c.gene || h.Unversioned() != c.unversioned {
+			t.Errorf("%q: got %q gene %q unversioned %v; want %q gene %q unversioned %v",
+				c.in, h.String(), h.Gene, h.Unversioned(), c.want, c.gene, c.unversioned)
+		}
+	}
+
+	for _, in := range []string{"rs429358", "NM_000546.6", "NM_000546.6:c.", "NM_000546.6:c.215", "NM_000546.6:c.215C>Q", "NM_000546.6:x.215C>G", "NP_000537.3:p.pro72arg"} {
+		if h, err := ParseHGVS(in); err == nil {
+			t.Errorf("%q: expected an error, got %q", in, h)
+		}
+	}
+}
+
+func TestParsePreferredName(t *testing.T) {
+	coding, protein, err := ParsePreferredName("NM_000041.4(APOE):c.388T>C (p.Cys130Arg)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if coding.String() != "NM_000041.4:c.388T>C" || coding.Gene != "APOE" {
+		t.Errorf("coding = %q (%s)", coding, coding.Gene)
+	}
+	if protein.String() != "APOE:p.Cys130Arg" {
+		t.Errorf("protein = %q", protein)
+	}
+
+	coding, protein, err = ParsePreferredName("NM_000059.4(BRCA2):c.8487+1G>A")
+	if err != nil || coding.String() != "NM_000059.4:c.8487+1G>A" || protein.Change != "" {
+		t.Errorf("intronic: got %q, %q, %v", coding, protein, err)
+	}
+
+	if _, _, err := ParsePreferredName("GRCh38/hg38 17q21.31(chr17:45495836-46707123)x3"); err == nil {
+		t.Error("expected an error for a copy number name")
+	}
+}
+
+func TestGenomicHGVS(t *testing.T) {
+	cases := []struct {
+		name     string
+		chrom    string
+		pos      int64
+		ref, alt string
+		want     string
+	}{
+		{"snv", "19", 44908684, "T", "C", "NC_000019.10:g.44908684T>C"},
+		{"chr prefix", "chrX", 100, "a", "g", "NC_000023.11:g.100A>G"},
+		{"mitochondrion", "chrM", 3243, "A", "G", "NC_012920.1:m.3243A>G"},
+		{"anchored deletion", "1", 100, "GAT", "G", "NC_000001.11:g.101_102del"},
+		{"single base deletion", "1", 100, "GA", "G", "NC_000001.11:g.101del"},
+		{"unanchored deletion", "1", 101, "A", "-", "NC_000001.11:g.101del"},
+		{"anchored insertion", "1", 100, "G", "GTT", "NC_000001.11:g.100_101insTT"},
+		{"unanchored insertion", "1", 101, "-", "TT", "NC_000001.11:g.100_101insTT"},
+		{"delins", "1", 100, "AC", "GT", "NC_000001.11:g.100_101delinsGT"},
+		{"padded substitution", "1", 99, "CAG", "CTG", "NC_000001.11:g.100A>T"},
+	}
+	for _, c := range cases {
+		got, ok := GenomicHGVS(c.chrom, c.pos, c.ref, c.alt)
+		if !ok || got != c.want {
+			t.Errorf("%s: got %q, %v; want %q", c.name, got, ok, c.want)
+		}
+	}
+
+	for name, args := range map[string]struct {
+		chrom    string
+		ref, alt string
+	}{
+		"unplaced contig": {"Un_KI270742v1", "A", "G"},
+		"no alt":          {"1", "A", ""},
+		"same allele":     {"1", "A", "A"},
+		"symbolic allele": {"1", "A", "<DEL>"},
+	} {
+		if got, ok := GenomicHGVS(args.chrom, 100, args.ref, args.alt); ok {
+			t.Errorf("%s: expected no description, got %q", name, got)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+// Package variant builds canonical keys identifying a variant independently
+// of the rsID a source files it under, and reads and writes its HGVS
+// descriptions.
+package variant
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// missingAllele stands for the empty allele of an insertion or deletion written
+// without an anchor base, as ClinVar does with "-".
+const missingAllele = "-"
+
+// NormalizeChromosome returns chrom without a "chr" prefix and in upper case,
+// with the mitochondrion as MT and the numeric aliases 23 and 24 as X and Y.
+func NormalizeChromosome(chrom string) string {
+	c := strings.ToUpper(strings.TrimSpace(chrom))
+	c = strings.TrimPrefix(c, "CHR")
+	switch c {
+	case "M":
+		return "MT"
+	case "23":
+		return "X"
+	case "24":
+		return "Y"
+	}
+	return c
+}
+
+// Key returns the canonical chrom:pos:ref:alt key of a variant, e.g.
+// "19:44908684:T:C", and false if there are not enough coordinates to build
+// one. Multiple alternate alleles are sorted and joined with commas.
+//
+// Alleles are upper-cased and trimmed of the bases all of them share at the
+// end and then at the start, keeping at least one base, with pos advanced
+// past the trimmed leading bases. This makes the same variant written with
+// different amounts of flanking sequence compare equal. Left-aligning indels
+// in repeats would need the reference sequence and is not done.
+func Key(chrom string, pos int64, ref string, alts []string) (string, bool) {
+	chrom = NormalizeChromosome(chrom)
+	if chrom == "" || pos <= 0 || ref == "" || len(alts) == 0 {
+		return "", false
+	}
+
+	alleles := make([]string, 0, 1+len(alts))
+	alleles = append(alleles, normalizeAllele(ref))
+	seen := make(map[string]bool, len(alts))
+	for _, alt := range alts {
+		a := normalizeAllele(alt)
+		if a == "" || seen[a] {
+			continue
+		}
+		seen[a] = true
+		alleles = append(alleles, a)
+	}
+	if alleles[0] == "" || len(alleles) == 1 {
+		return "", false
+	}
+	pos = trim(alleles, pos)
+
+	sorted := alleles[1:]
+	sort.Strings(sorted)
+	return chrom + ":" + strconv.FormatInt(pos, 10) + ":" + alleles[0] + ":" + strings.Join(sorted, ","), true
+}
+
+// UnanchoredKey returns the key of a biallelic indel written with a leading
+// anchor base, as VCF writes them, after dropping the anchor the way ClinVar
+// writes indels, with - for the empty allele: 1:100:GA:G becomes 1:101:A:-.
+// It returns false for variants that are not such an indel, whose Key is
+// the only form.
+func UnanchoredKey(chrom string, pos int64, ref, alt string) (string, bool) {
+	alleles := []string{normalizeAllele(ref), normalizeAllele(alt)}
+	if alleles[0] == "" || alleles[1] == "" {
+		return "", false
+	}
+	pos = trim(alleles, pos)
+	ref, alt = alleles[0], alleles[1]
+	if len(ref) == len(alt) || min(len(ref), len(alt)) != 1 || ref[0] != alt[0] {
+		return "", false
+	}
+	ref, alt = ref[1:], alt[1:]
+	if ref == "" {
+		ref = missingAllele
+	}
+	if alt == "" {
+		alt = missingAllele
+	}
+	return Key(chrom, pos+1, ref, []string{alt})
+}
+
+func normalizeAllele(a string) string {
+	a = strings.ToUpper(strings.TrimSpace(a))
+	if a == "." {
+		return missingAllele
+	}
+	return a
+}
+
+// trim removes the bases shared by every allele, first from the end and then
+// from the start, keeping one base in each, and returns the adjusted position.
+// Alleles written as missing have no bases to share.
+func trim(alleles []string, pos int64) int64 {
+	for _, a := range alleles {
+		if a == missingAllele {
+			return pos
+		}
+	}
+	for shared(alleles, func(a string) byte { return a[len(a)-1] }) {
+		for i, a := range alleles {
+			alleles[i] = a[:len(a)-1]
+		}
+	}
+	for shared(alleles, func(a string) byte { return a[0] }) {
+		for i, a := range alleles {
+			alleles[i] = a[1:]
+		}
+		pos++
+	}
+	return pos
+}
+
+// shared reports whether every allele is longer than one base and base returns
+// the same for all of them.
+func shared(alleles []string, base func(string) byte) bool {
+	for _, a := range alleles {
+		if len(a) < 2 || base(a) != base(alleles[0]) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,71 @@
+package variant
+
+import "testing"
+
+func TestKey(t *testing.T) {
+	cases := []struct {
+		name  string
+		chrom string
+		pos   int64
+		ref   string
+		alts  []string
+		want  string
+	}{
+		{"snv", "19", 44908684, "T", []string{"C"}, "19:44908684:T:C"},
+		{"chr prefix and case", "chr19", 44908684, "t", []string{"c"}, "19:44908684:T:C"},
+		{"mitochondrion", "chrM", 3243, "A", []string{"G"}, "MT:3243:A:G"},
+		{"numeric x", "23", 100, "A", []string{"G"}, "X:100:A:G"},
+		{"multi-allelic sorted and deduplicated", "1", 100, "A", []string{"T", "C", "T"}, "1:100:A:C,T"},
+		{"shared suffix", "1", 100, "CAG", []string{"TAG"}, "1:100:C:T"},
+		{"shared prefix", "1", 100, "GCA", []string{"GTA"}, "1:101:C:T"},
+		{"anchored deletion kept", "1", 100, "GA", []string{"G"}, "1:100:GA:G"},
+		{"padded deletion", "1", 99, "TGAC", []string{"TGC"}, "1:100:GA:G"},
+		{"unanchored deletion", "1", 101, "A", []string{"-"}, "1:101:A:-"},
+	}
+	for _, c := range cases {
+		got, ok := Key(c.chrom, c.pos, c.ref, c.alts)
+		if !ok || got != c.want {
+			t.Errorf("%s: got %q, %v; want %q", c.name, got, ok, c.want)
+		}
+	}
+
+	for name, args := range map[string]struct {
+		chrom string
+		pos   int64
+		ref   string
+		alts  []string
+	}{
+		"no chromosome": {"", 1, "A", []string{"G"}},
+		"no position":   {"1", 0, "A", []string{"G"}},
+		"no alt":        {"1", 1, "A", nil},
+		"only ref":      {"1", 1, "A", []string{""}},
+	} {
+		if key, ok := Key(args.chrom, args.pos, args.ref, args.alts); ok {
+			t.Errorf("%s: expected no key, got %q", name, key)
+		}
+	}
+}
+
+func TestUnanchoredKey(t *testing.T) {
+	cases := []struct {
+		name     string
+		pos      int64
+		ref, alt string
+		want     string
+	}{
+		{"deletion", 100, "GA", "G", "1:101:A:-"},
+		{"insertion", 100, "G", "GTT", "1:101:-:TT"},
+		{"padded deletion", 99, "TGAC", "TGC", "1:101:A:-"},
+	}
+	for _, c := range cases {
+		got, ok := UnanchoredKey("1", c.pos, c.ref, c.alt)
+		if !ok || got != c.want {
+			t.Errorf("%s: got %q, %v; want %q", c.name, got, ok, c.want)
+		}
+	}
+	for _, alleles := range [][2]string{{"A", "G"}, {"GA", "TC"}, {"A", "-"}, {"GA", "TAA"}} {
+		if key, ok := UnanchoredKey("1", 100, alleles[0], alleles[1]); ok {
+			t.Errorf("%v: expected no unanchored key, got %q", alleles, key)
+		}
+	}
+}
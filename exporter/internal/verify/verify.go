@@ -0,0 +1,307 @@
+package verify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/uptrace/bun"
+
+	"github.com/mkoziy/genome/exporter/internal/models"
+)
+
+// Exit codes returned by Report.ExitCode, suitable for CI of data releases.
+const (
+	ExitOK     = 0
+	ExitIssues = 1
+	ExitError  = 2
+)
+
+// sampleSize caps how many offending row IDs are reported per finding.
+const sampleSize = 20
+
+// Finding describes one failed check.
+type Finding struct {
+	Check   string  `json:"check"`
+	Table   string  `json:"table"`
+	Message string  `json:"message"`
+	Count   int     `json:"count"`
+	RowIDs  []int64 `json:"row_ids,omitempty"`
+}
+
+// Report collects the results of a verification run.
+type Report struct {
+	Checks   int       `json:"checks"`
+	Findings []Finding `json:"findings"`
+}
+
+// OK returns true if no check failed.
+func (r *Report) OK() bool {
+	return len(r.Findings) == 0
+}
+
+// ExitCode maps the report to a process exit code.
+func (r *Report) ExitCode() int {
+	if r.OK() {
+		return ExitOK
+	}
+	return ExitIssues
+}
+
+// WriteText writes a human-readable summary of the report.
+func (r *Report) WriteText(w io.Writer) error {
+	if r.OK() {
+		_, err := fmt.Fprintf(w, "OK: %d checks passed\n", r.Checks)
+		return err
+	}
+	for _, f := range r.Findings {
+		if _, err := fmt.Fprintf(w, "FAIL [%s] %s: %s (%d rows)", f.Check, f.Table, f.Message, f.Count); err != nil {
+			return err
+		}
+		if len(f.RowIDs) > 0 {
+			if _, err := fmt.Fprintf(w, " ids=%v", f.RowIDs); err != nil {
+				return err
+			}
+		}
+		if _, err := fmt.Fprintln(w); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintf(w, "%d of %d checks failed\n", len(r.Findings), r.Checks)
+	return err
+}
+
+// WriteJSON writes the report as JSON.
+func (r *Report) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// rowCheck selects rows of table matching where as offending rows.
+type rowCheck struct {
+	check   string
+	table   string
+	message string
+	where   string
+	args    []interface{}
+}
+
+// childTables are the tables linked to snps through snp_id.
+var childTables = []string{
+	"snp_significance",
+	"snp_significance_history",
+	"snp_quality",
+	"snp_frequencies",
+	"snp_hardy_weinberg",
+	"snp_clinical",
+	"snp_phenotypes",
+	"snp_references",
+	"snp_populations",
+	"risk_alleles",
+	"genotype_effects",
+	"clinical_agreements",
+	"snp_translations",
+	"snp_aliases",
+	"retractions",
+}
+
+// sourceTables are the tables carrying a DataSource column.
+var sourceTables = []string{
+	"snp_clinical",
+	"snp_phenotypes",
+	"snp_populations",
+	"snp_references",
+	"risk_alleles",
+	"genotype_effects",
+	"clinical_agreements",
+	"snp_hardy_weinberg",
+}
+
+func rowChecks() []rowCheck {
+	checks := make([]rowCheck, 0)
+
+	for _, table := range childTables {
+		checks = append(checks, rowCheck{
+			check:   "orphan",
+			table:   table,
+			message: "snp_id does not reference an existing SNP",
+			where:   "NOT EXISTS (SELECT 1 FROM snps AS s WHERE s.id = t.snp_id)",
+		})
+	}
+	checks = append(checks, rowCheck{
+		check:   "orphan",
+		table:   "phenotype_translations",
+		message: "phenotype_id does not reference an existing phenotype",
+		where:   "NOT EXISTS (SELECT 1 FROM snp_phenotypes AS p WHERE p.id = t.phenotype_id)",
+	})
+
+	checks = append(checks,
+		rowCheck{
+			check:   "enum",
+			table:   "snp_clinical",
+			message: "unknown clinical_significance",
+			where:   "t.clinical_significance NOT IN (?)",
+			args:    []interface{}{bun.In(models.ClinicalSignificances)},
+		},
+		rowCheck{
+			check:   "enum",
+			table:   "snp_clinical",
+			message: "unknown review_status",
+			where:   "t.review_status NOT IN (?)",
+			args:    []interface{}{bun.In(models.ReviewStatuses)},
+		},
+		rowCheck{
+			check:   "enum",
+			table:   "snps",
+			message: "unknown variant_type",
+			where:   "t.variant_type NOT IN (?)",
+			args:    []interface{}{bun.In(models.VariantTypes)},
+		},
+		rowCheck{
+			check:   "enum",
+			table:   "snps",
+			message: "unknown functional_class",
+			where:   "t.functional_class IS NOT NULL AND t.functional_class NOT IN (?)",
+			args:    []interface{}{bun.In(models.FunctionalClasses)},
+		},
+	)
+	for _, table := range sourceTables {
+		checks = append(checks, rowCheck{
+			check:   "enum",
+			table:   table,
+			message: "unknown source",
+			where:   "t.source IS NOT NULL AND t.source NOT IN (?)",
+			args:    []interface{}{bun.In(models.DataSources)},
+		})
+	}
+
+	checks = append(checks,
+		rowCheck{
+			check:   "malformed",
+			table:   "snps",
+			message: "rsid is not of the form rs<number>",
+			where:   "t.rsid NOT GLOB 'rs[0-9]*' OR substr(t.rsid, 3) GLOB '*[^0-9]*'",
+		},
+		rowCheck{
+			check:   "malformed",
+			table:   "snps",
+			message: "missing chromosome, position or reference allele",
+			where:   "t.chromosome = '' OR t.position <= 0 OR t.reference_allele = ''",
+		},
+		rowCheck{
+			check:   "malformed",
+			table:   "snps",
+			message: "alternate_alleles is empty or not a JSON array",
+			where:   "json_valid(t.alternate_alleles) = 0 OR json_type(t.alternate_alleles) <> 'array' OR json_array_length(t.alternate_alleles) = 0",
+		},
+		rowCheck{
+			check:   "malformed",
+			table:   "snp_significance",
+			message: "total_score outside 0-100",
+			where:   "t.total_score < 0 OR t.total_score > 100",
+		},
+		rowCheck{
+			check:   "malformed",
+			table:   "snp_quality",
+			message: "score outside 0-100",
+			where:   "t.score < 0 OR t.score > 100",
+		},
+		rowCheck{
+			check:   "malformed",
+			table:   "snp_populations",
+			message: "frequency outside 0-1",
+			where:   "t.frequency < 0 OR t.frequency > 1",
+		},
+		rowCheck{
+			check:   "malformed",
+			table:   "snp_frequencies",
+			message: "global_maf outside 0-0.5",
+			where:   "t.global_maf < 0 OR t.global_maf > 0.5",
+		},
+	)
+
+	return checks
+}
+
+// Run executes all checks against db. A non-nil error means verification
+// itself could not complete; data problems are reported as findings.
+func Run(ctx context.Context, db *bun.DB) (*Report, error) {
+	report := &Report{Findings: make([]Finding, 0)}
+
+	integrity, err := integrityCheck(ctx, db)
+	if err != nil {
+		return nil, fmt.Errorf("integrity check: %w", err)
+	}
+	report.Checks++
+	if integrity != nil {
+		report.Findings = append(report.Findings, *integrity)
+	}
+
+	for _, c := range rowChecks() {
+		finding, err := runRowCheck(ctx, db, c)
+		if err != nil {
+			return nil, fmt.Errorf("%s check on %s: %w", c.check, c.table, err)
+		}
+		report.Checks++
+		if finding != nil {
+			report.Findings = append(report.Findings, *finding)
+		}
+	}
+
+	return report, nil
+}
+
+func integrityCheck(ctx context.Context, db *bun.DB) (*Finding, error) {
+	var results []string
+	if err := db.NewRaw("PRAGMA integrity_check").Scan(ctx, &results); err != nil {
+		return nil, err
+	}
+	if len(results) == 1 && results[0] == "ok" {
+		return nil, nil
+	}
+
+	finding := &Finding{
+		Check:   "integrity",
+		Table:   "*",
+		Message: "PRAGMA integrity_check reported problems",
+		Count:   len(results),
+	}
+	if len(results) > 0 {
+		finding.Message += ": " + results[0]
+	}
+	return finding, nil
+}
+
+func runRowCheck(ctx context.Context, db *bun.DB, c rowCheck) (*Finding, error) {
+	count, err := db.NewSelect().
+		TableExpr("? AS t", bun.Ident(c.table)).
+		Where(c.where, c.args...).
+		Count(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if count == 0 {
+		return nil, nil
+	}
+
+	var ids []int64
+	if err := db.NewSelect().
+		TableExpr("? AS t", bun.Ident(c.table)).
+		Column("t.id").
+		Where(c.where, c.args...).
+		OrderExpr("t.id").
+		Limit(sampleSize).
+		Scan(ctx, &ids); err != nil {
+		return nil, err
+	}
+
+	return &Finding{
+		Check:   c.check,
+		Table:   c.table,
+		Message: c.message,
+		Count:   count,
+		RowIDs:  ids,
+	}, nil
+}
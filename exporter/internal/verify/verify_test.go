@@ -0,0 +1,90 @@
+package verify
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/uptrace/bun"
+
+	"github.com/mkoziy/genome/exporter/internal/database"
+	"github.com/mkoziy/genome/exporter/internal/migrations"
+	"github.com/mkoziy/genome/exporter/internal/models"
+)
+
+func newTestDB(t *testing.T) *bun.DB {
+	t.Helper()
+	db, err := database.NewDB("file:"+t.Name()+"?mode=memory&cache=shared", false)
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	if err := migrations.RunMigrations(context.Background(), db); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	return db
+}
+
+func TestRunCleanDatabase(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+
+	snp := &models.SNP{RsID: "rs429358", Chromosome: "19", Position: 44908684, ReferenceAllele: "C", AlternateAlleles: models.StringArray{"T"}, VariantType: models.VariantSNV}
+	if _, err := db.NewInsert().Model(snp).Exec(ctx); err != nil {
+		t.Fatalf("insert snp: %v", err)
+	}
+
+	report, err := Run(ctx, db)
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if !report.OK() || report.ExitCode() != ExitOK {
+		t.Fatalf("expected clean report, got %+v", report.Findings)
+	}
+}
+
+func TestRunReportsProblems(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+
+	// The models refuse unknown enum values, so they are written directly,
+	// as a database from before they did or another writer may hold them.
+	if _, err := db.ExecContext(ctx, `INSERT INTO snps (rsid, chromosome, position, reference_allele, alternate_alleles, variant_type)
+		VALUES ('RS1', '1', 10, 'A', '["G"]', 'single nucleotide variant')`); err != nil {
+		t.Fatalf("insert snp: %v", err)
+	}
+	if _, err := db.ExecContext(ctx, `INSERT INTO snp_clinical (snp_id, clinical_significance, review_status, condition_name, source)
+		VALUES (999, 'pathogenicish', 'reviewed_by_expert_panel', 'X', 'clinvar')`); err != nil {
+		t.Fatalf("insert clinical: %v", err)
+	}
+
+	report, err := Run(ctx, db)
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if report.ExitCode() != ExitIssues {
+		t.Fatalf("expected exit code %d, got %d", ExitIssues, report.ExitCode())
+	}
+
+	found := make(map[string]bool)
+	for _, f := range report.Findings {
+		found[f.Check+" "+f.Table] = true
+	}
+	for _, key := range []string{"orphan snp_clinical", "enum snp_clinical", "enum snps", "malformed snps"} {
+		if !found[key] {
+			t.Errorf("expected finding %q", key)
+		}
+	}
+	if found["orphan snp_significance"] {
+		t.Errorf("unexpected finding for empty snp_significance table")
+	}
+
+	var buf bytes.Buffer
+	if err := report.WriteText(&buf); err != nil {
+		t.Fatalf("write text: %v", err)
+	}
+	if !strings.Contains(buf.String(), "FAIL [orphan] snp_clinical") {
+		t.Fatalf("unexpected text report: %s", buf.String())
+	}
+}
@@ -0,0 +1,137 @@
+package warehouse
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// BigQueryScript is the name of the shell script that uploads an export and
+// runs its load jobs.
+const BigQueryScript = "load.sh"
+
+// bigQueryTypes maps column types to BigQuery's.
+var bigQueryTypes = map[Type]string{
+	String:     "STRING",
+	Int64:      "INT64",
+	Float64:    "FLOAT64",
+	Timestamp:  "TIMESTAMP",
+	Bool:       "BOOL",
+	StringList: "STRING",
+}
+
+// BigQueryTarget is where an export is loaded into BigQuery from.
+type BigQueryTarget struct {
+	// GCSPrefix is the gs://bucket/path the data files are uploaded under.
+	GCSPrefix string
+	Project   string
+	Dataset   string
+}
+
+// Validate checks that every location is set and GCSPrefix is a gs:// URI.
+func (t BigQueryTarget) Validate() error {
+	var errs []error
+	if bucket, _, _ := strings.Cut(strings.TrimPrefix(t.GCSPrefix, "gs://"), "/"); !strings.HasPrefix(t.GCSPrefix, "gs://") || bucket == "" {
+		errs = append(errs, fmt.Errorf("gcs prefix: want gs://bucket/path, got %q", t.GCSPrefix))
+	}
+	if t.Project == "" {
+		errs = append(errs, errors.New("project: required"))
+	}
+	if t.Dataset == "" {
+		errs = append(errs, errors.New("dataset: required"))
+	}
+	return errors.Join(errs...)
+}
+
+// uri returns the gs:// URI the data file of table is uploaded to.
+func (t BigQueryTarget) uri(table *Table) string {
+	return strings.TrimSuffix(t.GCSPrefix, "/") + "/" + DataFile(table)
+}
+
+// BigQueryField is a field of a BigQuery table schema, as read by bq load
+// --schema and the load job API.
+type BigQueryField struct {
+	Name        string `json:"name"`
+	Type        string `json:"type"`
+	Mode        string `json:"mode"`
+	Description string `json:"description,omitempty"`
+}
+
+// BigQuerySchema returns the schema of t.
+func BigQuerySchema(t *Table) []BigQueryField {
+	fields := make([]BigQueryField, len(t.Columns))
+	for i, col := range t.Columns {
+		mode := "NULLABLE"
+		switch {
+		case col.Type == StringList:
+			mode = "REPEATED"
+		case col.Required:
+			mode = "REQUIRED"
+		}
+		fields[i] = BigQueryField{Name: col.Name, Type: bigQueryTypes[col.Type], Mode: mode, Description: col.Description}
+	}
+	return fields
+}
+
+// BigQueryLoadJob returns the body of a jobs.insert request loading the data
+// file of table into target, replacing the table's rows.
+func BigQueryLoadJob(target BigQueryTarget, table *Table) map[string]any {
+	return map[string]any{
+		"configuration": map[string]any{
+			"load": map[string]any{
+				"sourceUris":   []string{target.uri(table)},
+				"sourceFormat": "NEWLINE_DELIMITED_JSON",
+				"destinationTable": map[string]string{
+					"projectId": target.Project,
+					"datasetId": target.Dataset,
+					"tableId":   table.Name,
+				},
+				"destinationTableProperties": map[string]string{"description": table.Description},
+				"schema":                     map[string]any{"fields": BigQuerySchema(table)},
+				"createDisposition":          "CREATE_IF_NEEDED",
+				"writeDisposition":           "WRITE_TRUNCATE",
+			},
+		},
+	}
+}
+
+// WriteBigQuery writes to dir, which holds the files of an export, the
+// schema (<table>.schema.json) and load job (<table>.job.json) of every
+// table, and a script that uploads the data files to GCS with gcloud and
+// loads them with bq.
+func WriteBigQuery(dir string, target BigQueryTarget) error {
+	if err := target.Validate(); err != nil {
+		return err
+	}
+	var script strings.Builder
+	script.WriteString("#!/bin/sh\n# Uploads the files of the export and loads them into BigQuery; run from its directory.\nset -eu\n\n")
+	fmt.Fprintf(&script, "bq --project_id=%s mk -f --dataset %s\n", shellQuote(target.Project), shellQuote(target.Project+":"+target.Dataset))
+	for _, t := range Tables {
+		if err := writeJSON(filepath.Join(dir, t.Name+".schema.json"), BigQuerySchema(t)); err != nil {
+			return err
+		}
+		if err := writeJSON(filepath.Join(dir, t.Name+".job.json"), BigQueryLoadJob(target, t)); err != nil {
+			return err
+		}
+		fmt.Fprintf(&script, "\ngcloud storage cp %s %s\n", shellQuote(DataFile(t)), shellQuote(target.uri(t)))
+		fmt.Fprintf(&script, "bq --project_id=%s load --replace --source_format=NEWLINE_DELIMITED_JSON %s %s %s\n",
+			shellQuote(target.Project), shellQuote(target.Dataset+"."+t.Name), shellQuote(target.uri(t)), shellQuote(t.Name+".schema.json"))
+	}
+	return os.WriteFile(filepath.Join(dir, BigQueryScript), []byte(script.String()), 0o755)
+}
+
+func writeJSON(path string, v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+// shellQuote quotes s for a POSIX shell.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
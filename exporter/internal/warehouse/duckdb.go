@@ -0,0 +1,104 @@
+package warehouse
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// DuckDBScript is the name of the script Export's files are loaded with.
+const DuckDBScript = "load.sql"
+
+// duckDBTypes maps column types to DuckDB's.
+var duckDBTypes = map[Type]string{
+	String:     "VARCHAR",
+	Int64:      "BIGINT",
+	Float64:    "DOUBLE",
+	Timestamp:  "TIMESTAMP",
+	Bool:       "BOOLEAN",
+	StringList: "VARCHAR[]",
+}
+
+// WriteDuckDBScript writes the SQL that creates every table in a DuckDB
+// database and loads it from its data file. The files are named relative to
+// the directory DuckDB runs in.
+func WriteDuckDBScript(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString("-- Creates the tables and loads the files of the export; run from its directory.\n")
+	for _, t := range Tables {
+		fmt.Fprintf(&b, "\nCREATE OR REPLACE TABLE %s (\n", t.Name)
+		for i, col := range t.Columns {
+			fmt.Fprintf(&b, "    %s %s", col.Name, duckDBTypes[col.Type])
+			if col.Required {
+				b.WriteString(" NOT NULL")
+			}
+			if i < len(t.Columns)-1 {
+				b.WriteByte(',')
+			}
+			b.WriteByte('\n')
+		}
+		b.WriteString(");\n")
+		fmt.Fprintf(&b, "INSERT INTO %s SELECT * FROM read_json(%s, format = 'newline_delimited', columns = {", t.Name, sqlString(DataFile(t)))
+		for i, col := range t.Columns {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			fmt.Fprintf(&b, "%s: %s", sqlString(col.Name), sqlString(duckDBTypes[col.Type]))
+		}
+		b.WriteString("});\n")
+		fmt.Fprintf(&b, "COMMENT ON TABLE %s IS %s;\n", t.Name, sqlString(t.Description))
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// BuildDuckDB writes the load script to dir, which holds the files of an
+// export, and runs it with the DuckDB CLI at bin to create the database at
+// path. An existing database at path is replaced.
+func BuildDuckDB(ctx context.Context, bin, dir, path string) error {
+	script := filepath.Join(dir, DuckDBScript)
+	f, err := os.Create(script)
+	if err != nil {
+		return err
+	}
+	if err := WriteDuckDBScript(f); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	tmp := abs + ".tmp"
+	_ = os.Remove(tmp)
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, bin, "-bail", "-c", ".read "+DuckDBScript, tmp)
+	cmd.Dir = dir
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		_ = os.Remove(tmp)
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("duckdb: %w: %s", err, msg)
+		}
+		return fmt.Errorf("duckdb: %w", err)
+	}
+	if err := os.Rename(tmp, abs); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("rename database: %w", err)
+	}
+	return nil
+}
+
+// sqlString quotes s as an SQL string literal.
+func sqlString(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
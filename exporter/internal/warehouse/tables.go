@@ -0,0 +1,309 @@
+package warehouse
+
+import (
+	"time"
+
+	"github.com/mkoziy/genome/exporter/internal/models"
+)
+
+// Type is the type of a column, mapped to each warehouse's own.
+type Type int
+
+// Column types.
+const (
+	String Type = iota
+	Int64
+	Float64
+	Timestamp
+	Bool
+	// StringList is a list of strings, never null.
+	StringList
+)
+
+// Column is a column of a Table.
+type Column struct {
+	Name        string
+	Type        Type
+	Required    bool
+	Description string
+}
+
+// Table is a table of the export: the SNPs, or one kind of annotation with
+// a row per annotation carrying the SNP's ID and rsID to join on.
+type Table struct {
+	Name        string
+	Description string
+	Columns     []Column
+	// rows returns the rows of snp, their values in the order of Columns.
+	rows func(snp *models.SNP) [][]any
+}
+
+// snpKey are the leading columns of every annotation table.
+var snpKey = []Column{
+	{Name: "snp_id", Type: Int64, Required: true, Description: "ID of the SNP in snps"},
+	{Name: "rsid", Type: String, Required: true, Description: "dbSNP rsID of the SNP"},
+}
+
+// Tables are the tables exported, named like those of the SQLite database.
+var Tables = []*Table{
+	{
+		Name:        "snps",
+		Description: "SNPs with their coordinates and significance score",
+		Columns: []Column{
+			{Name: "snp_id", Type: Int64, Required: true},
+			{Name: "rsid", Type: String, Required: true, Description: "dbSNP rsID"},
+			{Name: "chromosome", Type: String, Required: true},
+			{Name: "position", Type: Int64, Required: true},
+			{Name: "grch37_chromosome", Type: String, Description: "chromosome on GRCh37, null when not known there"},
+			{Name: "grch37_position", Type: Int64},
+			{Name: "end_position", Type: Int64, Description: "last position spanned on GRCh38, for structural variants and some indels"},
+			{Name: "sv_type", Type: String, Description: "structural variant type as VCF's SVTYPE, null for small variants"},
+			{Name: "sv_length", Type: Int64},
+			{Name: "reference_allele", Type: String, Required: true},
+			{Name: "alternate_alleles", Type: StringList},
+			{Name: "variant_key", Type: String, Description: "normalized chrom:pos:ref:alt key"},
+			{Name: "hgvs_genomic", Type: String, Description: "HGVS description on GRCh38"},
+			{Name: "hgvs_coding", Type: String, Description: "HGVS description on the preferred transcript"},
+			{Name: "hgvs_protein", Type: String},
+			{Name: "gene_symbol", Type: String},
+			{Name: "gene_id", Type: String},
+			{Name: "variant_type", Type: String, Required: true},
+			{Name: "functional_class", Type: String},
+			{Name: "total_score", Type: Float64, Description: "significance score, null when not scored"},
+			{Name: "clinical_score", Type: Float64},
+			{Name: "research_score", Type: Float64},
+			{Name: "population_score", Type: Float64},
+			{Name: "functional_score", Type: Float64},
+			{Name: "percentile", Type: Float64},
+			{Name: "quality_score", Type: Float64, Description: "data quality from 0 to 100, null when not assessed"},
+			{Name: "global_maf", Type: Float64, Description: "minor allele frequency over all samples, from the preferred source reporting it"},
+			{Name: "max_maf", Type: Float64, Description: "highest minor allele frequency among the superpopulations"},
+			{Name: "created_at", Type: Timestamp, Required: true},
+			{Name: "updated_at", Type: Timestamp, Required: true},
+		},
+		rows: func(snp *models.SNP) [][]any {
+			var total, clinical, research, population, functional, percentile *float64
+			if sig := snp.Significance; sig != nil {
+				total, clinical, research = &sig.TotalScore, &sig.ClinicalScore, &sig.ResearchScore
+				population, functional, percentile = &sig.PopulationScore, &sig.FunctionalScore, sig.Percentile
+			}
+			var quality *float64
+			if snp.Quality != nil {
+				quality = &snp.Quality.Score
+			}
+			var globalMAF, maxMAF *float64
+			if snp.Frequency != nil {
+				globalMAF, maxMAF = snp.Frequency.GlobalMAF, snp.Frequency.MaxMAF
+			}
+			return [][]any{{
+				snp.ID, snp.RsID, snp.Chromosome, snp.Position, snp.GRCh37Chromosome, snp.GRCh37Position, snp.EndPosition, snp.SVType, snp.SVLength, snp.ReferenceAllele, []string(snp.AlternateAlleles),
+				snp.VariantKey, snp.HGVSGenomic, snp.HGVSCoding, snp.HGVSProtein, snp.GeneSymbol, snp.GeneID, snp.VariantType, snp.FunctionalClass,
+				total, clinical, research, population, functional, percentile, quality, globalMAF, maxMAF,
+				snp.CreatedAt, snp.UpdatedAt,
+			}}
+		},
+	},
+	{
+		Name:        "snp_clinical",
+		Description: "Clinical assertions about SNPs",
+		Columns: append(snpKey[:len(snpKey):len(snpKey)],
+			Column{Name: "clinical_significance", Type: String, Required: true},
+			Column{Name: "review_status", Type: String, Required: true},
+			Column{Name: "condition_name", Type: String, Required: true},
+			Column{Name: "condition_id", Type: String},
+			Column{Name: "inheritance_pattern", Type: String},
+			Column{Name: "penetrance", Type: String},
+			Column{Name: "allele_origin", Type: String},
+			Column{Name: "source", Type: String, Required: true},
+			Column{Name: "source_id", Type: String},
+			Column{Name: "last_evaluated", Type: Timestamp},
+		),
+		rows: func(snp *models.SNP) [][]any {
+			rows := make([][]any, 0, len(snp.ClinicalData))
+			for _, c := range snp.ClinicalData {
+				rows = append(rows, []any{snp.ID, snp.RsID, c.ClinicalSignificance, c.ReviewStatus, c.ConditionName, c.ConditionID,
+					c.InheritancePattern, c.Penetrance, c.AlleleOrigin, c.Source, c.SourceID, c.LastEvaluated})
+			}
+			return rows
+		},
+	},
+	{
+		Name:        "snp_phenotypes",
+		Description: "Phenotype associations of SNPs",
+		Columns: append(snpKey[:len(snpKey):len(snpKey)],
+			Column{Name: "phenotype_name", Type: String, Required: true},
+			Column{Name: "phenotype_id", Type: String},
+			Column{Name: "association_type", Type: String, Required: true},
+			Column{Name: "odds_ratio", Type: Float64},
+			Column{Name: "confidence_interval", Type: String},
+			Column{Name: "p_value", Type: Float64},
+			Column{Name: "study_type", Type: String},
+			Column{Name: "source", Type: String, Required: true},
+		),
+		rows: func(snp *models.SNP) [][]any {
+			rows := make([][]any, 0, len(snp.Phenotypes))
+			for _, p := range snp.Phenotypes {
+				rows = append(rows, []any{snp.ID, snp.RsID, p.PhenotypeName, p.PhenotypeID, p.AssociationType,
+					nullable(p.OddsRatio), p.ConfidenceInterval, nullable(p.PValue), p.StudyType, p.Source})
+			}
+			return rows
+		},
+	},
+	{
+		Name:        "snp_references",
+		Description: "Publications about SNPs",
+		Columns: append(snpKey[:len(snpKey):len(snpKey)],
+			Column{Name: "pubmed_id", Type: String},
+			Column{Name: "title", Type: String},
+			Column{Name: "journal", Type: String},
+			Column{Name: "publication_year", Type: Int64},
+			Column{Name: "doi", Type: String},
+			Column{Name: "citation_count", Type: Int64, Required: true},
+			Column{Name: "source", Type: String},
+		),
+		rows: func(snp *models.SNP) [][]any {
+			rows := make([][]any, 0, len(snp.References))
+			for _, r := range snp.References {
+				var source *models.DataSource
+				if r.Source != "" {
+					source = &r.Source
+				}
+				rows = append(rows, []any{snp.ID, snp.RsID, r.PubmedID, r.Title, r.Journal, r.PublicationYear,
+					r.DOI, r.CitationCount, source})
+			}
+			return rows
+		},
+	},
+	{
+		Name:        "snp_populations",
+		Description: "Allele frequencies of SNPs in populations",
+		Columns: append(snpKey[:len(snpKey):len(snpKey)],
+			Column{Name: "population_code", Type: String, Required: true},
+			Column{Name: "population_name", Type: String},
+			Column{Name: "allele", Type: String, Required: true},
+			Column{Name: "frequency", Type: Float64, Required: true},
+			Column{Name: "allele_count", Type: Int64},
+			Column{Name: "allele_number", Type: Int64},
+			Column{Name: "homozygote_count", Type: Int64},
+			Column{Name: "heteroplasmic_count", Type: Int64, Description: "samples carrying the allele in only some mitochondrial copies"},
+			Column{Name: "max_heteroplasmy", Type: Float64, Description: "largest share of mitochondrial copies the allele was found in"},
+			Column{Name: "source", Type: String, Required: true},
+		),
+		rows: func(snp *models.SNP) [][]any {
+			rows := make([][]any, 0, len(snp.PopulationData))
+			for _, p := range snp.PopulationData {
+				rows = append(rows, []any{snp.ID, snp.RsID, p.PopulationCode, p.PopulationName, p.Allele, p.Frequency,
+					p.AlleleCount, p.AlleleNumber, p.HomozygoteCount, p.HeteroplasmicCount, nullable(p.MaxHeteroplasmy), p.Source})
+			}
+			return rows
+		},
+	},
+	{
+		Name:        "risk_alleles",
+		Description: "Alleles of SNPs and what carrying them means",
+		Columns: append(snpKey[:len(snpKey):len(snpKey)],
+			Column{Name: "allele", Type: String, Required: true},
+			Column{Name: "effect", Type: String, Required: true},
+			Column{Name: "direction", Type: String, Description: "increased, decreased or none; null when not known"},
+			Column{Name: "condition_name", Type: String, Required: true},
+			Column{Name: "inheritance", Type: String},
+			Column{Name: "odds_ratio", Type: Float64},
+			Column{Name: "source", Type: String, Required: true},
+		),
+		rows: func(snp *models.SNP) [][]any {
+			rows := make([][]any, 0, len(snp.RiskAlleles))
+			for _, r := range snp.RiskAlleles {
+				rows = append(rows, []any{snp.ID, snp.RsID, r.Allele, r.Effect, direction(r.Direction), r.ConditionName, r.Inheritance,
+					nullable(r.OddsRatio), r.Source})
+			}
+			return rows
+		},
+	},
+	{
+		Name:        "genotype_effects",
+		Description: "Genotypes of SNPs and what having them means",
+		Columns: append(snpKey[:len(snpKey):len(snpKey)],
+			Column{Name: "genotype", Type: String, Required: true, Description: "alleles sorted and joined by /"},
+			Column{Name: "effect", Type: String, Required: true},
+			Column{Name: "direction", Type: String},
+			Column{Name: "condition_name", Type: String, Required: true},
+			Column{Name: "magnitude", Type: Float64, Description: "odds ratio against the homozygous reference genotype"},
+			Column{Name: "source", Type: String, Required: true},
+		),
+		rows: func(snp *models.SNP) [][]any {
+			rows := make([][]any, 0, len(snp.GenotypeEffects))
+			for _, e := range snp.GenotypeEffects {
+				rows = append(rows, []any{snp.ID, snp.RsID, e.Genotype, e.Effect, direction(e.Direction), e.ConditionName,
+					nullable(e.Magnitude), e.Source})
+			}
+			return rows
+		},
+	},
+	{
+		Name:        "clinical_agreements",
+		Description: "How far the submitters of clinical assertions on SNPs agree, per condition",
+		Columns: append(snpKey[:len(snpKey):len(snpKey)],
+			Column{Name: "condition_name", Type: String, Required: true},
+			Column{Name: "condition_id", Type: String},
+			Column{Name: "pathogenic_count", Type: Int64, Required: true, Description: "submissions classifying it pathogenic or likely pathogenic"},
+			Column{Name: "benign_count", Type: Int64, Required: true, Description: "submissions classifying it benign or likely benign"},
+			Column{Name: "uncertain_count", Type: Int64, Required: true},
+			Column{Name: "other_count", Type: Int64, Required: true},
+			Column{Name: "majority_call", Type: String, Description: "pathogenic, benign or uncertain_significance; null on a tie"},
+			Column{Name: "agreement", Type: Float64, Required: true, Description: "share of the classifying submissions making the majority call"},
+			Column{Name: "conflicting", Type: Bool, Required: true},
+			Column{Name: "source", Type: String, Required: true},
+		),
+		rows: func(snp *models.SNP) [][]any {
+			rows := make([][]any, 0, len(snp.ClinicalAgreements))
+			for _, a := range snp.ClinicalAgreements {
+				var majority *models.ClinicalSignificance
+				if a.MajorityCall != "" {
+					majority = &a.MajorityCall
+				}
+				rows = append(rows, []any{snp.ID, snp.RsID, a.ConditionName, a.ConditionID, a.PathogenicCount, a.BenignCount,
+					a.UncertainCount, a.OtherCount, majority, a.Agreement, a.Conflicting, a.Source})
+			}
+			return rows
+		},
+	},
+}
+
+// timestampLayout is how timestamps are written: in UTC without a zone,
+// which both DuckDB and BigQuery read as a TIMESTAMP.
+const timestampLayout = "2006-01-02 15:04:05.999999"
+
+// value returns v as written to a row: times in timestampLayout, nil
+// pointers as null and nil lists as empty.
+func value(v any) any {
+	switch v := v.(type) {
+	case time.Time:
+		return v.UTC().Format(timestampLayout)
+	case *time.Time:
+		if v == nil {
+			return nil
+		}
+		return v.UTC().Format(timestampLayout)
+	case []string:
+		if v == nil {
+			return []string{}
+		}
+	}
+	return v
+}
+
+// direction returns d, or nil for an unknown direction.
+func direction(d models.EffectDirection) *string {
+	if d == "" {
+		return nil
+	}
+	s := string(d)
+	return &s
+}
+
+func nullable(n *models.NullableFloat64) *float64 {
+	if n == nil || !n.Valid {
+		return nil
+	}
+	return &n.Float64
+}
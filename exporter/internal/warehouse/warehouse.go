@@ -0,0 +1,107 @@
+// Package warehouse exports the database for analytics warehouses: a
+// newline-delimited JSON file per table, with the scripts and job
+// definitions that load them into a DuckDB file or BigQuery, so analysts
+// query the data without touching SQLite.
+package warehouse
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/uptrace/bun"
+
+	"github.com/mkoziy/genome/exporter/internal/models"
+	"github.com/mkoziy/genome/exporter/internal/repositories"
+)
+
+// DataFile returns the name of the file the rows of t are written to.
+func DataFile(t *Table) string {
+	return t.Name + ".jsonl"
+}
+
+// Stats counts the rows written to each table.
+type Stats struct {
+	Rows map[string]int `json:"rows"`
+}
+
+// Export writes the rows of every table to dir, created if needed, reading
+// batchSize SNPs at a time.
+func Export(ctx context.Context, db *bun.DB, dir string, batchSize int) (*Stats, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	files := make([]*os.File, len(Tables))
+	writers := make([]*bufio.Writer, len(Tables))
+	defer func() {
+		for _, f := range files {
+			if f != nil {
+				_ = f.Close()
+			}
+		}
+	}()
+	for i, t := range Tables {
+		f, err := os.Create(filepath.Join(dir, DataFile(t)))
+		if err != nil {
+			return nil, err
+		}
+		files[i], writers[i] = f, bufio.NewWriter(f)
+	}
+
+	stats := &Stats{Rows: make(map[string]int, len(Tables))}
+	var line bytes.Buffer
+	err := repositories.ForEachSNP(ctx, db, batchSize, func(batch []*models.SNP) error {
+		for _, snp := range batch {
+			for i, t := range Tables {
+				for _, row := range t.rows(snp) {
+					line.Reset()
+					if err := encodeRow(&line, t, row); err != nil {
+						return fmt.Errorf("%s of %s: %w", t.Name, snp.RsID, err)
+					}
+					if _, err := writers[i].Write(line.Bytes()); err != nil {
+						return err
+					}
+					stats.Rows[t.Name]++
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for i, t := range Tables {
+		if err := writers[i].Flush(); err != nil {
+			return nil, fmt.Errorf("write %s: %w", DataFile(t), err)
+		}
+		err := files[i].Close()
+		files[i] = nil
+		if err != nil {
+			return nil, fmt.Errorf("write %s: %w", DataFile(t), err)
+		}
+	}
+	return stats, nil
+}
+
+// encodeRow writes row as a JSON object with the columns of t in order,
+// followed by a newline.
+func encodeRow(buf *bytes.Buffer, t *Table, row []any) error {
+	buf.WriteByte('{')
+	for i, col := range t.Columns {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		data, err := json.Marshal(value(row[i]))
+		if err != nil {
+			return fmt.Errorf("%s: %w", col.Name, err)
+		}
+		fmt.Fprintf(buf, "%q:", col.Name)
+		buf.Write(data)
+	}
+	buf.WriteString("}\n")
+	return nil
+}
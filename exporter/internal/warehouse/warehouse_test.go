@@ -0,0 +1,188 @@
+package warehouse
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/uptrace/bun"
+
+	"github.com/mkoziy/genome/exporter/internal/database"
+	"github.com/mkoziy/genome/exporter/internal/migrations"
+	"github.com/mkoziy/genome/exporter/internal/models"
+)
+
+func newSourceDB(t *testing.T) *bun.DB {
+	t.Helper()
+	ctx := context.Background()
+	db, err := database.NewDB("file:"+t.Name()+"?mode=memory&cache=shared", false)
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	if err := migrations.RunMigrations(ctx, db); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	gene := "CFTR"
+	snps := []*models.SNP{
+		{RsID: "rs113993960", Chromosome: "7", Position: 117559590, ReferenceAllele: "C", AlternateAlleles: models.StringArray{"T"}, VariantType: models.VariantSNV, GeneSymbol: &gene},
+		{RsID: "rs2", Chromosome: "1", Position: 2, ReferenceAllele: "A", AlternateAlleles: models.StringArray{"G", "T"}, VariantType: models.VariantSNV},
+	}
+	if _, err := db.NewInsert().Model(&snps).Exec(ctx); err != nil {
+		t.Fatalf("insert snps: %v", err)
+	}
+	if _, err := db.NewInsert().Model(&models.Significance{SNPID: snps[0].ID, TotalScore: 90}).Exec(ctx); err != nil {
+		t.Fatalf("insert significance: %v", err)
+	}
+	clinical := &models.ClinicalData{SNPID: snps[0].ID, ClinicalSignificance: models.ClinicalPathogenic,
+		ReviewStatus: models.ReviewCriteriaProvided, ConditionName: "Cystic fibrosis", Source: models.SourceClinVar}
+	if _, err := db.NewInsert().Model(clinical).Exec(ctx); err != nil {
+		t.Fatalf("insert clinical: %v", err)
+	}
+	phenotype := &models.Phenotype{SNPID: snps[1].ID, PhenotypeName: "Height", AssociationType: "association",
+		OddsRatio: &models.NullableFloat64{Float64: 1.2, Valid: true}, Source: models.SourceGWAS}
+	if _, err := db.NewInsert().Model(phenotype).Exec(ctx); err != nil {
+		t.Fatalf("insert phenotype: %v", err)
+	}
+	return db
+}
+
+func readRows(t *testing.T, path string) []map[string]any {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("open %s: %v", path, err)
+	}
+	defer func() { _ = f.Close() }()
+	var rows []map[string]any
+	lines := bufio.NewScanner(f)
+	for lines.Scan() {
+		var row map[string]any
+		if err := json.Unmarshal(lines.Bytes(), &row); err != nil {
+			t.Fatalf("decode %s: %v", path, err)
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+func TestExportWritesATableFilePerTable(t *testing.T) {
+	db := newSourceDB(t)
+	dir := t.TempDir()
+
+	stats, err := Export(context.Background(), db, dir, 1)
+	if err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	if stats.Rows["snps"] != 2 || stats.Rows["snp_clinical"] != 1 || stats.Rows["snp_phenotypes"] != 1 || stats.Rows["risk_alleles"] != 0 {
+		t.Fatalf("unexpected row counts: %v", stats.Rows)
+	}
+
+	snps := readRows(t, filepath.Join(dir, "snps.jsonl"))
+	if len(snps) != 2 || snps[0]["rsid"] != "rs113993960" || snps[0]["total_score"] != 90.0 || snps[1]["total_score"] != nil {
+		t.Fatalf("unexpected snps: %v", snps)
+	}
+	if len(snps[1]) != len(Tables[0].Columns) || len(snps[1]["alternate_alleles"].([]any)) != 2 {
+		t.Errorf("expected every column written, got %v", snps[1])
+	}
+	if created, _ := snps[0]["created_at"].(string); strings.ContainsAny(created, "TZ") {
+		t.Errorf("expected a zoneless timestamp, got %q", created)
+	}
+	phenotypes := readRows(t, filepath.Join(dir, "snp_phenotypes.jsonl"))
+	if len(phenotypes) != 1 || phenotypes[0]["rsid"] != "rs2" || phenotypes[0]["odds_ratio"] != 1.2 || phenotypes[0]["p_value"] != nil {
+		t.Errorf("unexpected phenotypes: %v", phenotypes)
+	}
+	if info, err := os.Stat(filepath.Join(dir, "risk_alleles.jsonl")); err != nil || info.Size() != 0 {
+		t.Errorf("expected an empty risk_alleles file, got %v (%v)", info, err)
+	}
+}
+
+func TestWriteDuckDBScript(t *testing.T) {
+	var b strings.Builder
+	if err := WriteDuckDBScript(&b); err != nil {
+		t.Fatalf("write script: %v", err)
+	}
+	script := b.String()
+	for _, want := range []string{
+		"CREATE OR REPLACE TABLE snps (",
+		"alternate_alleles VARCHAR[],",
+		"rsid VARCHAR NOT NULL,",
+		"INSERT INTO snp_clinical SELECT * FROM read_json('snp_clinical.jsonl'",
+	} {
+		if !strings.Contains(script, want) {
+			t.Errorf("expected script to contain %q", want)
+		}
+	}
+}
+
+func TestBuildDuckDB(t *testing.T) {
+	bin, err := exec.LookPath("duckdb")
+	if err != nil {
+		t.Skip("duckdb CLI not installed")
+	}
+	db := newSourceDB(t)
+	dir := t.TempDir()
+	if _, err := Export(context.Background(), db, dir, 0); err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "genome.duckdb")
+	if err := BuildDuckDB(context.Background(), bin, dir, path); err != nil {
+		t.Fatalf("build: %v", err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("expected the database written: %v", err)
+	}
+}
+
+func TestWriteBigQuery(t *testing.T) {
+	dir := t.TempDir()
+	target := BigQueryTarget{GCSPrefix: "gs://genome-exports/2026-10/", Project: "genome-analytics", Dataset: "snps"}
+	if err := WriteBigQuery(dir, target); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	var fields []BigQueryField
+	data, err := os.ReadFile(filepath.Join(dir, "snps.schema.json"))
+	if err != nil || json.Unmarshal(data, &fields) != nil {
+		t.Fatalf("read schema: %v", err)
+	}
+	modes := make(map[string]string)
+	for _, f := range fields {
+		modes[f.Name] = f.Type + " " + f.Mode
+	}
+	if modes["rsid"] != "STRING REQUIRED" || modes["alternate_alleles"] != "STRING REPEATED" || modes["total_score"] != "FLOAT64 NULLABLE" {
+		t.Errorf("unexpected schema: %v", modes)
+	}
+
+	var job struct {
+		Configuration struct {
+			Load struct {
+				SourceURIs       []string          `json:"sourceUris"`
+				DestinationTable map[string]string `json:"destinationTable"`
+			} `json:"load"`
+		} `json:"configuration"`
+	}
+	data, err = os.ReadFile(filepath.Join(dir, "snp_clinical.job.json"))
+	if err != nil || json.Unmarshal(data, &job) != nil {
+		t.Fatalf("read job: %v", err)
+	}
+	load := job.Configuration.Load
+	if len(load.SourceURIs) != 1 || load.SourceURIs[0] != "gs://genome-exports/2026-10/snp_clinical.jsonl" || load.DestinationTable["tableId"] != "snp_clinical" {
+		t.Errorf("unexpected load job: %+v", load)
+	}
+
+	script, err := os.ReadFile(filepath.Join(dir, BigQueryScript))
+	if err != nil || !strings.Contains(string(script), "bq --project_id='genome-analytics' load --replace") {
+		t.Errorf("unexpected script: %s (%v)", script, err)
+	}
+
+	if err := WriteBigQuery(dir, BigQueryTarget{GCSPrefix: "s3://bucket"}); err == nil {
+		t.Error("expected an invalid target rejected")
+	}
+}
@@ -0,0 +1,225 @@
+// Package genomedb reads a SNP database generated by the exporter. It is the
+// supported way for other Go programs to embed the database: the database
+// is opened read-only and its rows are returned as this package's own
+// types, which keep their shape as the exporter's schema evolves.
+//
+//	db, err := genomedb.Open("genome.db")
+//	if err != nil {
+//		return err
+//	}
+//	defer db.Close()
+//	snp, err := db.GetSNP(ctx, "rs4244285")
+package genomedb
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect/sqlitedialect"
+	"github.com/uptrace/bun/driver/sqliteshim"
+
+	"github.com/mkoziy/genome/exporter/internal/models"
+	"github.com/mkoziy/genome/exporter/internal/repositories"
+	"github.com/mkoziy/genome/exporter/internal/variant"
+)
+
+// ErrNotFound is returned for an rsID the database does not have.
+var ErrNotFound = errors.New("genomedb: not found")
+
+// maxResults bounds the SNPs returned by Search unless SearchOptions.Limit
+// says otherwise.
+const maxResults = 100
+
+// DB is an open database. It is safe for concurrent use.
+type DB struct {
+	db *bun.DB
+}
+
+// Open opens the database file at path read-only.
+func Open(path string) (*DB, error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, fmt.Errorf("genomedb: %w", err)
+	}
+	sqldb, err := sql.Open(sqliteshim.ShimName, "file:"+(&url.URL{Path: path}).EscapedPath()+"?mode=ro")
+	if err != nil {
+		return nil, fmt.Errorf("genomedb: open %s: %w", path, err)
+	}
+	db := bun.NewDB(sqldb, sqlitedialect.New())
+	var tables int
+	err = db.NewSelect().
+		TableExpr("sqlite_master").
+		ColumnExpr("COUNT(*)").
+		Where("type = 'table' AND name = 'snps'").
+		Scan(context.Background(), &tables)
+	if err == nil && tables == 0 {
+		err = errors.New("no snps table")
+	}
+	if err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("genomedb: %s is not a SNP database: %w", path, err)
+	}
+	return &DB{db: db}, nil
+}
+
+// Close closes the database.
+func (d *DB) Close() error {
+	return d.db.Close()
+}
+
+// GetSNP returns the SNP of an rsID with all it is annotated with, or
+// ErrNotFound.
+func (d *DB) GetSNP(ctx context.Context, rsID string) (*SNP, error) {
+	snp, err := repositories.GetSNPByRsID(ctx, d.db, normalizeRsID(rsID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return fromModel(snp), nil
+}
+
+// GetSNPs returns the SNPs of many rsIDs, keyed by rsID, with all they are
+// annotated with. rsIDs the database does not have are absent.
+func (d *DB) GetSNPs(ctx context.Context, rsIDs []string) (map[string]*SNP, error) {
+	normalized := make([]string, len(rsIDs))
+	for i, id := range rsIDs {
+		normalized[i] = normalizeRsID(id)
+	}
+	found, err := repositories.GetSNPsByRsIDs(ctx, d.db, normalized)
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string]*SNP, len(found))
+	for rsID, snp := range found {
+		result[rsID] = fromModel(snp)
+	}
+	return result, nil
+}
+
+// FindByRegion returns the SNPs on chrom from start to end, inclusive, on
+// GRCh38, ordered by position, with their scores and clinical assertions.
+// Structural variants starting before start are included if they reach it.
+// Chromosomes are named without a "chr" prefix, with MT for the
+// mitochondrion.
+func (d *DB) FindByRegion(ctx context.Context, chrom string, start, end int64) ([]*SNP, error) {
+	chrom = strings.TrimPrefix(strings.TrimPrefix(chrom, "chr"), "Chr")
+	snps, err := repositories.GetSNPsInRegion(ctx, d.db, chrom, start, end, repositories.SignificanceFilter{})
+	if err != nil {
+		return nil, err
+	}
+	return fromModels(snps), nil
+}
+
+// FindByGRCh37Region is FindByRegion on GRCh37: it returns the SNPs whose
+// GRCh37 position, where known, is on chrom from start to end, ordered by
+// that position.
+func (d *DB) FindByGRCh37Region(ctx context.Context, chrom string, start, end int64) ([]*SNP, error) {
+	chrom = strings.TrimPrefix(strings.TrimPrefix(chrom, "chr"), "Chr")
+	snps, err := repositories.GetSNPsInGRCh37Region(ctx, d.db, chrom, start, end, repositories.SignificanceFilter{})
+	if err != nil {
+		return nil, err
+	}
+	return fromModels(snps), nil
+}
+
+// SearchOptions tune Search.
+type SearchOptions struct {
+	// MinScore leaves out SNPs scored below it, and unscored ones when set.
+	MinScore float64
+	// Limit bounds the SNPs returned; 0 means 100.
+	Limit int
+}
+
+// Search finds SNPs by rsID, HGVS description, gene symbol or condition, in
+// that order of precedence: a query that is an rsID returns its SNP, one
+// that is an HGVS description, such as "NM_000546.6:c.215C>G", the SNPs it
+// describes, one naming a gene the gene's SNPs by position, and anything
+// else the SNPs annotated with a matching condition name or ID, best
+// matching names first. A description's transcript may be given without
+// its version. SNPs carry their scores and clinical assertions.
+func (d *DB) Search(ctx context.Context, query string, opts SearchOptions) ([]*SNP, error) {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return nil, nil
+	}
+	limit := opts.Limit
+	if limit <= 0 {
+		limit = maxResults
+	}
+	keep := func(list []*SNP) []*SNP {
+		kept := make([]*SNP, 0, min(len(list), limit))
+		for _, snp := range list {
+			if opts.MinScore > 0 && (snp.Score == nil || *snp.Score < opts.MinScore) {
+				continue
+			}
+			if kept = append(kept, snp); len(kept) == limit {
+				break
+			}
+		}
+		return kept
+	}
+
+	// A bare number is an rsID to GetSNP, but may be part of a condition ID
+	// here.
+	if rsID := normalizeRsID(query); models.IsRsID(rsID) && strings.HasPrefix(strings.ToLower(strings.TrimSpace(query)), "rs") {
+		snp, err := d.GetSNP(ctx, rsID)
+		if errors.Is(err, ErrNotFound) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		return keep([]*SNP{snp}), nil
+	}
+
+	if h, err := variant.ParseHGVS(query); err == nil {
+		snps, err := repositories.GetSNPsByHGVS(ctx, d.db, h)
+		if err != nil {
+			return nil, err
+		}
+		return keep(fromModels(snps)), nil
+	}
+
+	page, err := repositories.GetSNPsByGene(ctx, d.db, strings.ToUpper(query), repositories.GeneQueryOptions{
+		SignificanceFilter: repositories.SignificanceFilter{MinScore: opts.MinScore},
+		Page:               repositories.Page{Limit: limit},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(page.SNPs) > 0 {
+		return keep(fromModels(page.SNPs)), nil
+	}
+
+	matches, err := repositories.SearchByCondition(ctx, d.db, query)
+	if err != nil {
+		return nil, err
+	}
+	var list []*SNP
+	seen := make(map[string]bool)
+	for _, m := range matches {
+		for _, snp := range m.SNPs {
+			if !seen[snp.RsID] {
+				seen[snp.RsID] = true
+				list = append(list, fromModel(snp))
+			}
+		}
+	}
+	return keep(list), nil
+}
+
+// normalizeRsID returns an rsID such as RS123 in canonical form, and
+// anything that is not one unchanged.
+func normalizeRsID(s string) string {
+	if rsID, err := models.NormalizeRsID(s); err == nil {
+		return rsID
+	}
+	return s
+}
@@ -0,0 +1,157 @@
+package genomedb
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/mkoziy/genome/exporter/internal/database"
+	"github.com/mkoziy/genome/exporter/internal/migrations"
+	"github.com/mkoziy/genome/exporter/internal/models"
+	"github.com/mkoziy/genome/exporter/internal/repositories"
+)
+
+func strPtr(s string) *string { return &s }
+
+func int64Ptr(n int64) *int64 { return &n }
+
+// newTestFile writes a database of two CYP2C19 variants and returns its path.
+func newTestFile(t *testing.T) string {
+	t.Helper()
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "genome.db")
+	db, err := database.NewDB(path, false)
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	defer func() {
+		_ = db.Close()
+	}()
+	if err := migrations.RunMigrations(ctx, db); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	snps := []*models.SNP{
+		{RsID: "rs4244285", Chromosome: "10", Position: 94781859, ReferenceAllele: "G", AlternateAlleles: models.StringArray{"A"}, GeneSymbol: strPtr("CYP2C19"), VariantType: models.VariantSNV,
+			HGVSCoding: strPtr("NM_000769.4:c.681G>A"), HGVSProtein: strPtr("NP_000760.1:p.Pro227="),
+			GRCh37Chromosome: strPtr("10"), GRCh37Position: int64Ptr(96541616)},
+		{RsID: "rs12248560", Chromosome: "10", Position: 94761900, ReferenceAllele: "C", AlternateAlleles: models.StringArray{"T"}, GeneSymbol: strPtr("CYP2C19"), VariantType: models.VariantSNV},
+	}
+	if err := repositories.UpsertSNPs(ctx, db, snps); err != nil {
+		t.Fatalf("upsert: %v", err)
+	}
+	repos := repositories.NewBunRepositories(db)
+	if err := repos.Clinical.Upsert(ctx, []*models.ClinicalData{{
+		SNPID: snps[0].ID, ClinicalSignificance: models.ClinicalDrugResponse, ReviewStatus: models.ReviewPracticeGuideline,
+		ConditionName: "Clopidogrel response", ConditionID: strPtr("CN077956"), Source: models.SourceClinVar,
+	}}); err != nil {
+		t.Fatalf("upsert clinical: %v", err)
+	}
+	if err := repos.Significance.Save(ctx, &models.Significance{SNPID: snps[0].ID, TotalScore: 72}); err != nil {
+		t.Fatalf("save score: %v", err)
+	}
+	return path
+}
+
+func TestOpen(t *testing.T) {
+	if _, err := Open(filepath.Join(t.TempDir(), "missing.db")); err == nil {
+		t.Fatal("expected a missing file to be an error")
+	}
+
+	db, err := Open(newTestFile(t))
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer func() {
+		_ = db.Close()
+	}()
+	if _, err := db.db.Exec("DELETE FROM snps"); err == nil {
+		t.Fatal("expected the database to be read-only")
+	}
+}
+
+func TestLookups(t *testing.T) {
+	ctx := context.Background()
+	db, err := Open(newTestFile(t))
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer func() {
+		_ = db.Close()
+	}()
+
+	snp, err := db.GetSNP(ctx, "RS4244285")
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if snp.Gene != "CYP2C19" || snp.Score == nil || *snp.Score != 72 || snp.Level != "High" ||
+		len(snp.Clinical) != 1 || snp.Clinical[0].ConditionID != "CN077956" {
+		t.Fatalf("unexpected SNP: %+v", snp)
+	}
+	if _, err := db.GetSNP(ctx, "rs1"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+
+	many, err := db.GetSNPs(ctx, []string{"rs4244285", "rs12248560", "rs1"})
+	if err != nil || len(many) != 2 {
+		t.Fatalf("expected two SNPs, got %v (%v)", many, err)
+	}
+
+	region, err := db.FindByRegion(ctx, "chr10", 94700000, 94800000)
+	if err != nil || len(region) != 2 || region[0].RsID != "rs12248560" {
+		t.Fatalf("expected both SNPs by position, got %+v (%v)", region, err)
+	}
+
+	region, err = db.FindByGRCh37Region(ctx, "chr10", 96541000, 96542000)
+	if err != nil || len(region) != 1 || region[0].RsID != "rs4244285" || region[0].GRCh37Position != 96541616 {
+		t.Fatalf("expected rs4244285 by its GRCh37 position, got %+v (%v)", region, err)
+	}
+	if region, err := db.FindByRegion(ctx, "10", 96541000, 96542000); err != nil || len(region) != 0 {
+		t.Fatalf("expected GRCh37 positions not to match GRCh38 ones, got %+v (%v)", region, err)
+	}
+}
+
+func TestSearch(t *testing.T) {
+	ctx := context.Background()
+	db, err := Open(newTestFile(t))
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer func() {
+		_ = db.Close()
+	}()
+
+	tests := []struct {
+		query string
+		opts  SearchOptions
+		want  []string
+	}{
+		{query: "rs12248560", want: []string{"rs12248560"}},
+		{query: "rs1", want: nil},
+		{query: "NM_000769.4(CYP2C19):c.681G>A", want: []string{"rs4244285"}},
+		{query: "nm_000769:c.681g>a", want: []string{"rs4244285"}},
+		{query: "NM_000769.2:c.681G>A", want: nil},
+		{query: "NC_000010.11:g.94781859G>A", want: []string{"rs4244285"}},
+		{query: "CYP2C19:p.Pro227=", want: []string{"rs4244285"}},
+		{query: "cyp2c19", want: []string{"rs12248560", "rs4244285"}},
+		{query: "CYP2C19", opts: SearchOptions{MinScore: 50}, want: []string{"rs4244285"}},
+		{query: "CYP2C19", opts: SearchOptions{Limit: 1}, want: []string{"rs12248560"}},
+		{query: "clopidogrel", want: []string{"rs4244285"}},
+		{query: "warfarin", want: nil},
+	}
+	for _, tt := range tests {
+		got, err := db.Search(ctx, tt.query, tt.opts)
+		if err != nil {
+			t.Fatalf("search %q: %v", tt.query, err)
+		}
+		var rsIDs []string
+		for _, snp := range got {
+			rsIDs = append(rsIDs, snp.RsID)
+		}
+		if !slices.Equal(rsIDs, tt.want) {
+			t.Errorf("search %q %+v: got %v, want %v", tt.query, tt.opts, rsIDs, tt.want)
+		}
+	}
+}
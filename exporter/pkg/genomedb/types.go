@@ -0,0 +1,304 @@
+package genomedb
+
+import (
+	"time"
+
+	"github.com/mkoziy/genome/exporter/internal/models"
+)
+
+// SNP is a variant with what the database knows of it. Slices a lookup does
+// not load are nil.
+type SNP struct {
+	RsID       string `json:"rsid"`
+	Chromosome string `json:"chromosome"`
+	// Position is on GRCh38, 1-based.
+	Position        int64    `json:"position"`
+	Ref             string   `json:"ref"`
+	Alts            []string `json:"alts"`
+	Gene            string   `json:"gene,omitempty"`
+	VariantType     string   `json:"variant_type"`
+	FunctionalClass string   `json:"functional_class,omitempty"`
+	// HGVS descriptions of the variant: genomic on GRCh38, such as
+	// "NC_000019.10:g.44908684T>C", and coding and protein on ClinVar's
+	// preferred transcript, such as "NM_000041.4:c.388T>C".
+	HGVSGenomic string `json:"hgvs_genomic,omitempty"`
+	HGVSCoding  string `json:"hgvs_coding,omitempty"`
+	HGVSProtein string `json:"hgvs_protein,omitempty"`
+	// GRCh37Chromosome and GRCh37Position locate the variant on GRCh37,
+	// empty if it is not known there.
+	GRCh37Chromosome string `json:"grch37_chromosome,omitempty"`
+	GRCh37Position   int64  `json:"grch37_position,omitempty"`
+	// End is the last position the variant spans on GRCh38, for variants
+	// spanning more than their reference allele shows.
+	End int64 `json:"end,omitempty"`
+	// SVType and SVLength describe structural variants: their kind as VCF
+	// names it, such as DEL, DUP or CNV, and how many bases they affect.
+	SVType   string `json:"sv_type,omitempty"`
+	SVLength int64  `json:"sv_length,omitempty"`
+	// Score is the significance score from 0 to 100, nil if the variant has
+	// not been scored.
+	Score *float64 `json:"score,omitempty"`
+	// Level is the score's band: Very High, High, Moderate, Low or Minimal.
+	Level string `json:"level,omitempty"`
+	// Reasons explain the contributions to the score.
+	Reasons []string `json:"reasons,omitempty"`
+	// Quality is how completely the variant is characterized, from 0 to 100,
+	// nil if it has not been assessed.
+	Quality *float64 `json:"quality,omitempty"`
+	// GlobalMAF is the minor allele frequency over all samples, from the
+	// first of gnomAD, 1000 Genomes and ALFA to report it; MaxMAF is the
+	// highest in any superpopulation. Each is nil if unknown.
+	GlobalMAF    *float64      `json:"global_maf,omitempty"`
+	MaxMAF       *float64      `json:"max_maf,omitempty"`
+	Clinical     []Assertion   `json:"clinical,omitempty"`
+	Associations []Association `json:"associations,omitempty"`
+	Frequencies  []Frequency   `json:"frequencies,omitempty"`
+	References   []Reference   `json:"references,omitempty"`
+	RiskAlleles  []RiskAllele  `json:"risk_alleles,omitempty"`
+	// GenotypeEffects are what having each genotype of the variant means.
+	GenotypeEffects []GenotypeEffect `json:"genotype_effects,omitempty"`
+	// Agreements say how far the submitters of Clinical agree, per
+	// condition.
+	Agreements []Agreement `json:"agreements,omitempty"`
+	UpdatedAt  time.Time   `json:"updated_at"`
+}
+
+// Assertion is a clinical significance asserted for the variant, e.g. by a
+// ClinVar submitter.
+type Assertion struct {
+	// Significance is e.g. pathogenic, likely_benign or drug_response.
+	Significance string `json:"significance"`
+	// ReviewStatus is how well the assertion is supported, e.g.
+	// reviewed_by_expert_panel.
+	ReviewStatus string `json:"review_status"`
+	Condition    string `json:"condition"`
+	// ConditionID is e.g. a MedGen concept ID.
+	ConditionID string `json:"condition_id,omitempty"`
+	Inheritance string `json:"inheritance,omitempty"`
+	Source      string `json:"source"`
+}
+
+// Association is a trait or drug response associated with the variant, e.g.
+// by a GWAS.
+type Association struct {
+	Phenotype string   `json:"phenotype"`
+	Type      string   `json:"type"`
+	OddsRatio *float64 `json:"odds_ratio,omitempty"`
+	PValue    *float64 `json:"p_value,omitempty"`
+	Source    string   `json:"source"`
+}
+
+// Frequency is how common an allele of the variant is in a population.
+type Frequency struct {
+	Population string  `json:"population"`
+	Allele     string  `json:"allele"`
+	Frequency  float64 `json:"frequency"`
+	Source     string  `json:"source"`
+}
+
+// Reference is a publication about the variant.
+type Reference struct {
+	PubMedID      string `json:"pubmed_id,omitempty"`
+	DOI           string `json:"doi,omitempty"`
+	Title         string `json:"title,omitempty"`
+	Authors       string `json:"authors,omitempty"`
+	Journal       string `json:"journal,omitempty"`
+	Year          int    `json:"year,omitempty"`
+	URL           string `json:"url,omitempty"`
+	CitationCount int    `json:"citation_count"`
+}
+
+// RiskAllele is an allele carrying an effect on a condition.
+type RiskAllele struct {
+	Allele string `json:"allele"`
+	// Effect is e.g. pathogenic for a ClinVar assertion, or the association
+	// type of a GWAS hit.
+	Effect string `json:"effect"`
+	// Direction is increased, decreased or none, empty if not known.
+	Direction   string   `json:"direction,omitempty"`
+	Condition   string   `json:"condition"`
+	Inheritance string   `json:"inheritance,omitempty"`
+	OddsRatio   *float64 `json:"odds_ratio,omitempty"`
+	Source      string   `json:"source"`
+}
+
+// GenotypeEffect is what having a genotype of a variant means for a
+// condition.
+type GenotypeEffect struct {
+	// Genotype is the alleles sorted and joined by "/", such as "C/T".
+	Genotype  string `json:"genotype"`
+	Effect    string `json:"effect"`
+	Direction string `json:"direction,omitempty"`
+	Condition string `json:"condition"`
+	// Magnitude is an odds ratio against the homozygous reference genotype,
+	// when known.
+	Magnitude *float64 `json:"magnitude,omitempty"`
+	Source    string   `json:"source"`
+}
+
+// Agreement is how far the submitters of clinical assertions about the
+// variant agree on a condition.
+type Agreement struct {
+	Condition   string `json:"condition"`
+	ConditionID string `json:"condition_id,omitempty"`
+	// Pathogenic, Benign and Uncertain count the submissions classifying
+	// the variant (likely) pathogenic, (likely) benign and of uncertain
+	// significance; Other those taking no side, such as risk factors.
+	Pathogenic int `json:"pathogenic"`
+	Benign     int `json:"benign"`
+	Uncertain  int `json:"uncertain"`
+	Other      int `json:"other"`
+	// MajorityCall is pathogenic, benign or uncertain_significance, empty
+	// if no side has the most submissions.
+	MajorityCall string `json:"majority_call,omitempty"`
+	// Agreement is the share of the submissions taking a side that make
+	// the majority call, from 0 to 1.
+	Agreement   float64 `json:"agreement"`
+	Conflicting bool    `json:"conflicting"`
+	Source      string  `json:"source"`
+}
+
+func fromModels(snps []*models.SNP) []*SNP {
+	list := make([]*SNP, len(snps))
+	for i, snp := range snps {
+		list[i] = fromModel(snp)
+	}
+	return list
+}
+
+func fromModel(m *models.SNP) *SNP {
+	snp := &SNP{
+		RsID:        m.RsID,
+		Chromosome:  m.Chromosome,
+		Position:    m.Position,
+		Ref:         m.ReferenceAllele,
+		Alts:        append([]string(nil), m.AlternateAlleles...),
+		Gene:        deref(m.GeneSymbol),
+		VariantType: string(m.VariantType),
+		HGVSGenomic: deref(m.HGVSGenomic),
+		HGVSCoding:  deref(m.HGVSCoding),
+		HGVSProtein: deref(m.HGVSProtein),
+		UpdatedAt:   m.UpdatedAt,
+	}
+	if m.GRCh37Chromosome != nil && m.GRCh37Position != nil {
+		snp.GRCh37Chromosome, snp.GRCh37Position = *m.GRCh37Chromosome, *m.GRCh37Position
+	}
+	if m.EndPosition != nil {
+		snp.End = *m.EndPosition
+	}
+	if m.SVType != nil {
+		snp.SVType = string(*m.SVType)
+	}
+	if m.SVLength != nil {
+		snp.SVLength = *m.SVLength
+	}
+	if m.FunctionalClass != nil {
+		snp.FunctionalClass = string(*m.FunctionalClass)
+	}
+	if sig := m.Significance; sig != nil {
+		score := sig.LevelScore()
+		snp.Score = &score
+		snp.Level = sig.SignificanceLevel()
+		snp.Reasons = sig.ScoreDetails.Reasons
+	}
+	if q := m.Quality; q != nil {
+		quality := q.Score
+		snp.Quality = &quality
+	}
+	if f := m.Frequency; f != nil {
+		snp.GlobalMAF, snp.MaxMAF = f.GlobalMAF, f.MaxMAF
+	}
+	for _, c := range m.ClinicalData {
+		snp.Clinical = append(snp.Clinical, Assertion{
+			Significance: string(c.ClinicalSignificance),
+			ReviewStatus: string(c.ReviewStatus),
+			Condition:    c.ConditionName,
+			ConditionID:  deref(c.ConditionID),
+			Inheritance:  deref(c.InheritancePattern),
+			Source:       string(c.Source),
+		})
+	}
+	for _, p := range m.Phenotypes {
+		snp.Associations = append(snp.Associations, Association{
+			Phenotype: p.PhenotypeName,
+			Type:      p.AssociationType,
+			OddsRatio: nullable(p.OddsRatio),
+			PValue:    nullable(p.PValue),
+			Source:    string(p.Source),
+		})
+	}
+	for _, f := range m.PopulationData {
+		snp.Frequencies = append(snp.Frequencies, Frequency{
+			Population: f.PopulationCode,
+			Allele:     f.Allele,
+			Frequency:  f.Frequency,
+			Source:     string(f.Source),
+		})
+	}
+	for _, r := range m.References {
+		ref := Reference{
+			PubMedID:      deref(r.PubmedID),
+			DOI:           deref(r.DOI),
+			Title:         deref(r.Title),
+			Authors:       deref(r.Authors),
+			Journal:       deref(r.Journal),
+			URL:           deref(r.URL),
+			CitationCount: r.CitationCount,
+		}
+		if r.PublicationYear != nil {
+			ref.Year = *r.PublicationYear
+		}
+		snp.References = append(snp.References, ref)
+	}
+	for _, r := range m.RiskAlleles {
+		snp.RiskAlleles = append(snp.RiskAlleles, RiskAllele{
+			Allele:      r.Allele,
+			Effect:      r.Effect,
+			Direction:   string(r.Direction),
+			Condition:   r.ConditionName,
+			Inheritance: deref(r.Inheritance),
+			OddsRatio:   nullable(r.OddsRatio),
+			Source:      string(r.Source),
+		})
+	}
+	for _, e := range m.GenotypeEffects {
+		snp.GenotypeEffects = append(snp.GenotypeEffects, GenotypeEffect{
+			Genotype:  e.Genotype,
+			Effect:    e.Effect,
+			Direction: string(e.Direction),
+			Condition: e.ConditionName,
+			Magnitude: nullable(e.Magnitude),
+			Source:    string(e.Source),
+		})
+	}
+	for _, a := range m.ClinicalAgreements {
+		snp.Agreements = append(snp.Agreements, Agreement{
+			Condition:    a.ConditionName,
+			ConditionID:  deref(a.ConditionID),
+			Pathogenic:   a.PathogenicCount,
+			Benign:       a.BenignCount,
+			Uncertain:    a.UncertainCount,
+			Other:        a.OtherCount,
+			MajorityCall: string(a.MajorityCall),
+			Agreement:    a.Agreement,
+			Conflicting:  a.Conflicting,
+			Source:       string(a.Source),
+		})
+	}
+	return snp
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+func nullable(n *models.NullableFloat64) *float64 {
+	if n == nil || !n.Valid {
+		return nil
+	}
+	v := n.Float64
+	return &v
+}